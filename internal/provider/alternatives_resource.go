@@ -0,0 +1,299 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &alternativesResource{}
+
+// alternativesNamePattern matches the names of the link groups, e.g. java or x-www-browser.
+var alternativesNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+func newAlternativesResource(p *internalProvider) resource.Resource {
+	return &alternativesResource{
+		provider: p,
+	}
+}
+
+// alternativesResource defines the resource implementation.
+type alternativesResource struct {
+	provider *internalProvider
+}
+
+type alternativesResourceModel struct {
+	Name     types.String `tfsdk:"name"`
+	Path     types.String `tfsdk:"path"`
+	Link     types.String `tfsdk:"link"`
+	Priority types.Int64  `tfsdk:"priority"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *alternativesResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_alternatives"
+}
+
+func (r *alternativesResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Alternatives resource that selects the alternative of a link group of the Debian " +
+			"alternatives system, e.g. the default java or editor, with update-alternatives --set. With link, the " +
+			"alternative is first registered with update-alternatives --install, e.g. for a JDK installed from an " +
+			"archive. Destroying the resource removes the alternative it registered, or returns the group to " +
+			"automatic mode otherwise",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the link group, e.g. 'java' or 'editor'",
+				Validators:  []validator.String{alternativesName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the selected alternative, e.g. '/usr/lib/jvm/java-17-openjdk-amd64/bin/java'",
+				Validators:  []validator.String{absolutePath()},
+			},
+			"link": schema.StringAttribute{
+				Optional:    true,
+				Description: "The generic link of the group, e.g. '/usr/bin/java'. When set, the alternative is registered with this link before it is selected. Required for alternatives that are not registered by their package",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"priority": schema.Int64Attribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     int64default.StaticInt64(50),
+				Description: "The priority of the registered alternative, which only matters when the group returns to automatic mode. Only used with link. Defaults to 50",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *alternativesResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *alternativesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan alternativesResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	if err := r.apply(ctx, plan); err != nil {
+		resp.Diagnostics.AddError("Failed to set the alternative", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *alternativesResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state alternativesResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	value, found, err := r.selected(ctx, state.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to query the alternatives", err.Error())
+		return
+	}
+
+	// The group was removed, e.g. with the packages providing its alternatives
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	state.Path = types.StringValue(value)
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *alternativesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state alternativesResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.apply(ctx, plan); err != nil {
+		resp.Diagnostics.AddError("Failed to set the alternative", err.Error())
+		return
+	}
+
+	// The previously registered alternative is removed, now that another one is selected
+	if !state.Link.IsNull() && state.Path != plan.Path {
+		if err := r.remove(ctx, state); err != nil {
+			resp.Diagnostics.AddError("Failed to remove the previous alternative", err.Error())
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *alternativesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state alternativesResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !state.Link.IsNull() {
+		if err := r.remove(ctx, state); err != nil {
+			resp.Diagnostics.AddError("Failed to remove the alternative", err.Error())
+		}
+
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("update-alternatives", "--auto", state.Name.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to return the alternatives to automatic mode", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// apply registers the alternative when the model has a link, then selects it.
+func (r *alternativesResource) apply(ctx context.Context, model alternativesResourceModel) error {
+	name, alternativePath := model.Name.ValueString(), model.Path.ValueString()
+
+	if !model.Link.IsNull() {
+		// --install updates the priority of an alternative that is already registered
+		command := clients.ShellCommand("update-alternatives", "--install", model.Link.ValueString(), name, alternativePath, strconv.FormatInt(model.Priority.ValueInt64(), 10))
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+		if err != nil {
+			return fmt.Errorf("failed to register %s. Err=%w\nout = %s", alternativePath, err, out)
+		}
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("update-alternatives", "--set", name, alternativePath)))
+	if err != nil {
+		return fmt.Errorf("failed to select %s, it must be registered first, see link. Err=%w\nout = %s", alternativePath, err, out)
+	}
+
+	return nil
+}
+
+// remove unregisters the alternative of the model. The group returns to automatic mode when it was selected.
+func (r *alternativesResource) remove(ctx context.Context, model alternativesResourceModel) error {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("update-alternatives", "--remove", model.Name.ValueString(), model.Path.ValueString())))
+	if err != nil {
+		return fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// selected returns the selected alternative of the link group, and whether the group exists.
+func (r *alternativesResource) selected(ctx context.Context, name string) (string, bool, error) {
+	result, err := r.provider.machineAccessClient.Run(ctx, clients.ShellCommand("update-alternatives", "--query", name))
+
+	// update-alternatives exits with 2 when the group does not exist
+	if clients.IsExitCode(err, 2) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+	}
+
+	return parseAlternativesValue(result.Stdout), true, nil
+}
+
+// parseAlternativesValue returns the selected alternative from the output of update-alternatives --query, made of a
+// stanza describing the group followed by a stanza per alternative, e.g.
+//
+//	Name: editor
+//	Status: manual
+//	Value: /usr/bin/vim.basic
+//
+//	Alternative: /bin/nano
+//	Priority: 40
+func parseAlternativesValue(out string) string {
+	for line := range strings.SplitSeq(out, "\n") {
+		if value, ok := strings.CutPrefix(line, "Value:"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// alternativesName validates the names of alternatives link groups.
+func alternativesName() validator.String {
+	return stringValidator{
+		description: "value must be the name of a link group, e.g. 'java'",
+		check: func(value string) string {
+			if !alternativesNamePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must be the name of a link group, e.g. 'java' or 'editor'", value)
+			}
+
+			return ""
+		},
+	}
+}
@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testAlternativesQuery = `Name: java
+Link: /usr/bin/java
+Status: manual
+Best: /usr/lib/jvm/java-21-openjdk-amd64/bin/java
+Value: /usr/lib/jvm/java-17-openjdk-amd64/bin/java
+
+Alternative: /usr/lib/jvm/java-17-openjdk-amd64/bin/java
+Priority: 1711
+
+Alternative: /usr/lib/jvm/java-21-openjdk-amd64/bin/java
+Priority: 2111
+`
+
+func TestAlternativesResourceWithMock(t *testing.T) {
+	t.Run("create selects the alternative", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newAlternativesResource(newTestProvider(mock)), testAlternativesModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"update-alternatives --set java /usr/lib/jvm/java-17-openjdk-amd64/bin/java"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create registers the alternative with a link", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		plan := testAlternativesModel()
+		plan.Path = types.StringValue("/opt/jdk-22/bin/java")
+		plan.Link = types.StringValue("/usr/bin/java")
+
+		// Act
+		_, diags := testResourceCreate(t, newAlternativesResource(newTestProvider(mock)), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{
+			"update-alternatives --install /usr/bin/java java /opt/jdk-22/bin/java 50",
+			"update-alternatives --set java /opt/jdk-22/bin/java",
+		}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects another selected alternative", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("update-alternatives --query java", clients.MockResponse{Stdout: testAlternativesQuery})
+
+		state := testAlternativesModel()
+		state.Path = types.StringValue("/usr/lib/jvm/java-21-openjdk-amd64/bin/java")
+
+		// Act
+		state, _, diags := testResourceRead(t, newAlternativesResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Path.ValueString() != "/usr/lib/jvm/java-17-openjdk-amd64/bin/java" {
+			t.Fatalf("unexpected path: %s", state.Path)
+		}
+	})
+
+	t.Run("read removes a missing group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("update-alternatives --query java", clients.MockResponse{ExitCode: 2, Stderr: "update-alternatives: error: no alternatives for java"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newAlternativesResource(newTestProvider(mock)), testAlternativesModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete returns the group to automatic mode", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newAlternativesResource(newTestProvider(mock)), testAlternativesModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"update-alternatives --auto java"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func testAlternativesModel() alternativesResourceModel {
+	return alternativesResourceModel{
+		Name:     types.StringValue("java"),
+		Path:     types.StringValue("/usr/lib/jvm/java-17-openjdk-amd64/bin/java"),
+		Link:     types.StringNull(),
+		Priority: types.Int64Value(50),
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &apparmorProfileResource{}
+
+// apparmorDirectory is the directory of the AppArmor profiles, where disable/ links the disabled ones.
+const apparmorDirectory = "/etc/apparmor.d"
+
+// apparmorProfilePattern matches the names of the profile files, e.g. usr.sbin.nginx.
+var apparmorProfilePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*$`)
+
+// apparmorModeCommands are the commands of apparmor-utils switching a profile to each mode.
+var apparmorModeCommands = map[string]string{
+	"enforce":  "aa-enforce",
+	"complain": "aa-complain",
+	"disabled": "aa-disable",
+}
+
+func newApparmorProfileResource(p *internalProvider) resource.Resource {
+	return &apparmorProfileResource{
+		provider: p,
+	}
+}
+
+// apparmorProfileResource defines the resource implementation.
+type apparmorProfileResource struct {
+	provider *internalProvider
+}
+
+type apparmorProfileResourceModel struct {
+	Profile types.String `tfsdk:"profile"`
+	Mode    types.String `tfsdk:"mode"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *apparmorProfileResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_apparmor_profile"
+}
+
+func (r *apparmorProfileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "AppArmor profile resource that sets the enforcement mode of a profile of " + apparmorDirectory +
+			" with aa-enforce, aa-complain or aa-disable, from the apparmor-utils package. The mode persists across " +
+			"reboots. Destroying the resource leaves the profile in its current mode",
+
+		Attributes: map[string]schema.Attribute{
+			"profile": schema.StringAttribute{
+				Required:    true,
+				Description: "The file name of the profile in " + apparmorDirectory + ", e.g. 'usr.sbin.nginx'",
+				Validators:  []validator.String{apparmorProfile()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"mode": schema.StringAttribute{
+				Required:    true,
+				Description: "The mode of the profile, one of 'enforce', 'complain', where violations are only logged, or 'disabled', where the profile is unloaded",
+				Validators:  []validator.String{oneOf("enforce", "complain", "disabled")},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *apparmorProfileResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *apparmorProfileResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan apparmorProfileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	if err := r.setMode(ctx, plan); err != nil {
+		resp.Diagnostics.AddError("Failed to set the AppArmor profile mode", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *apparmorProfileResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state apparmorProfileResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	profilePath := path.Join(apparmorDirectory, state.Profile.ValueString())
+
+	_, err := r.provider.machineAccessClient.Stat(ctx, profilePath, true)
+	if clients.IsFileNotFound(err) {
+		// The profile was removed, e.g. with the package it came with
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stat the AppArmor profile", err.Error())
+		return
+	}
+
+	mode, err := r.mode(ctx, state.Profile.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the AppArmor profile mode", err.Error())
+		return
+	}
+
+	state.Mode = types.StringValue(mode)
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *apparmorProfileResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan apparmorProfileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.setMode(ctx, plan); err != nil {
+		resp.Diagnostics.AddError("Failed to set the AppArmor profile mode", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *apparmorProfileResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// the profile is left in its current mode, as its previous mode is unknown
+}
+
+// setMode switches the profile to the mode of the model.
+func (r *apparmorProfileResource) setMode(ctx context.Context, model apparmorProfileResourceModel) error {
+	command := clients.ShellCommand(apparmorModeCommands[model.Mode.ValueString()], path.Join(apparmorDirectory, model.Profile.ValueString()))
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+	if err != nil {
+		return fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// mode returns the mode of the profile: disabled when it is linked in disable/ or not loaded, otherwise the mode
+// aa-status reports for the profiles defined in the file.
+func (r *apparmorProfileResource) mode(ctx context.Context, profile string) (string, error) {
+	_, err := r.provider.machineAccessClient.Stat(ctx, path.Join(apparmorDirectory, "disable", profile), false)
+	if err == nil {
+		return "disabled", nil
+	}
+
+	if !clients.IsFileNotFound(err) {
+		return "", err
+	}
+
+	names, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("apparmor_parser", "-N", path.Join(apparmorDirectory, profile))))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the profile names. Err=%w\nout = %s", err, names)
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("aa-status --json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the AppArmor status. Err=%w\nout = %s", err, out)
+	}
+
+	var status struct {
+		Profiles map[string]string `json:"profiles"`
+	}
+
+	if err := json.Unmarshal([]byte(out), &status); err != nil {
+		return "", fmt.Errorf("failed to parse the output of aa-status: %w", err)
+	}
+
+	for name := range strings.SplitSeq(strings.TrimSpace(names), "\n") {
+		if mode, ok := status.Profiles[strings.TrimSpace(name)]; ok {
+			return mode, nil
+		}
+	}
+
+	return "disabled", nil
+}
+
+// apparmorProfile validates the file names of AppArmor profiles.
+func apparmorProfile() validator.String {
+	return stringValidator{
+		description: "value must be the file name of a profile in " + apparmorDirectory,
+		check: func(value string) string {
+			if !apparmorProfilePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must be the file name of a profile in %s, e.g. 'usr.sbin.nginx'", value, apparmorDirectory)
+			}
+
+			return ""
+		},
+	}
+}
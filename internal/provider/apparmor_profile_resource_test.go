@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestApparmorProfileResourceWithMock(t *testing.T) {
+	t.Run("create sets the mode of the profile", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newApparmorProfileResource(newTestProvider(mock)), testApparmorProfileModel("complain"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"aa-complain /etc/apparmor.d/usr.sbin.nginx"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read reports the mode from aa-status", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/apparmor.d/usr.sbin.nginx", "profile nginx /usr/sbin/nginx {}\n", clients.FileInfo{Mode: "644"}).
+			On("apparmor_parser -N /etc/apparmor.d/usr.sbin.nginx", clients.MockResponse{Stdout: "nginx\n"}).
+			On("aa-status --json", clients.MockResponse{Stdout: `{"version": "2", "profiles": {"nginx": "complain", "/usr/bin/man": "enforce"}, "processes": {}}`})
+
+		// Act
+		state, _, diags := testResourceRead(t, newApparmorProfileResource(newTestProvider(mock)), testApparmorProfileModel("enforce"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Mode.ValueString() != "complain" {
+			t.Fatalf("unexpected mode: %s", state.Mode)
+		}
+	})
+
+	t.Run("read reports a profile linked in disable as disabled", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/apparmor.d/usr.sbin.nginx", "profile nginx /usr/sbin/nginx {}\n", clients.FileInfo{Mode: "644"}).
+			WithFile("/etc/apparmor.d/disable/usr.sbin.nginx", "", clients.FileInfo{Mode: "777"})
+
+		// Act
+		state, _, diags := testResourceRead(t, newApparmorProfileResource(newTestProvider(mock)), testApparmorProfileModel("enforce"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Mode.ValueString() != "disabled" || len(mock.Commands) != 0 {
+			t.Fatalf("unexpected mode %s, commands: %v", state.Mode, mock.Commands)
+		}
+	})
+
+	t.Run("read removes a missing profile", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newApparmorProfileResource(newTestProvider(mock)), testApparmorProfileModel("enforce"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+}
+
+func testApparmorProfileModel(mode string) apparmorProfileResourceModel {
+	return apparmorProfileResourceModel{
+		Profile: types.StringValue("usr.sbin.nginx"),
+		Mode:    types.StringValue(mode),
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listdefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// defaultAptOptions keeps the configuration files modified on the machine when a package upgrade ships new ones,
+// instead of prompting for it, which hangs the apply as nobody answers.
+func defaultAptOptions() types.List {
+	return types.ListValueMust(types.StringType, []attr.Value{
+		types.StringValue("Dpkg::Options::=--force-confdef"),
+		types.StringValue("Dpkg::Options::=--force-confold"),
+	})
+}
+
+// aptOptionsAttribute is the apt_options attribute of the resources running apt-get.
+func aptOptionsAttribute() schema.ListAttribute {
+	return schema.ListAttribute{
+		Optional:    true,
+		Computed:    true,
+		ElementType: types.StringType,
+		Default:     listdefault.StaticValue(defaultAptOptions()),
+		Description: "The configuration options passed to each apt-get command with -o, e.g. 'Dpkg::Options::=--force-confnew' or 'Acquire::Retries=3'. " +
+			"The commands always run with DEBIAN_FRONTEND=noninteractive, so that they never prompt. " +
+			"Defaults to 'Dpkg::Options::=--force-confdef' and 'Dpkg::Options::=--force-confold', which keep the modified configuration files on upgrades instead of prompting",
+		Validators: []validator.List{listOf(aptOption())},
+	}
+}
+
+// aptGet returns the apt-get command with the arguments, run without prompts and with the options of apt_options.
+func aptGet(options []string, args ...string) string {
+	arguments := []string{}
+	for _, option := range options {
+		arguments = append(arguments, "-o", option)
+	}
+
+	return "DEBIAN_FRONTEND=noninteractive " + clients.ShellCommand("apt-get", append(arguments, args...)...)
+}
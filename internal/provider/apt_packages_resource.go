@@ -9,17 +9,17 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
-	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &aptPackagesResource{}
-var _ resource.ResourceWithImportState = &aptPackagesResource{}
 
 func newAptPackagesResource(p *internalProvider) resource.Resource {
 	return &aptPackagesResource{
@@ -33,7 +33,12 @@ type aptPackagesResource struct {
 }
 
 type aptPackagesResourceModel struct {
-	Package []*aptPackagesResourcePackageModel `tfsdk:"package"`
+	Package    []*aptPackagesResourcePackageModel `tfsdk:"package"`
+	AptOptions types.List                         `tfsdk:"apt_options"`
+	Timeouts   *timeoutsModel                     `tfsdk:"timeouts"`
+	Retry      *retryModel                        `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 type aptPackagesResourcePackageModel struct {
@@ -56,6 +61,7 @@ func (aptPackages *aptPackagesResource) Schema(_ context.Context, _ resource.Sch
 						"name": schema.StringAttribute{
 							Required:    true,
 							Description: "The name of the apt package",
+							Validators:  []validator.String{notBlank()},
 						},
 						"absent": schema.BoolAttribute{
 							Optional:    true,
@@ -64,8 +70,13 @@ func (aptPackages *aptPackagesResource) Schema(_ context.Context, _ resource.Sch
 					},
 				},
 			},
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+		Attributes: map[string]schema.Attribute{
+			"apt_options": aptOptionsAttribute(),
+			"target_host": targetHostAttribute(),
 		},
-		Attributes: map[string]schema.Attribute{},
 	}
 }
 
@@ -77,6 +88,8 @@ func (aptPackages *aptPackagesResource) Configure(_ context.Context, _ resource.
 }
 
 func (aptPackages *aptPackagesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = aptPackages.provider.mutating(ctx, aptPackages, "create", req.Plan)
+
 	var plan aptPackagesResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -86,6 +99,18 @@ func (aptPackages *aptPackagesResource) Create(ctx context.Context, req resource
 		return
 	}
 
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	aptOptions := stringList(ctx, plan.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	currentlyInstalledPackages, err := aptPackages.listCurrentlyInstalledPackages(ctx)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list currently installed apt packages", err.Error())
@@ -114,7 +139,7 @@ func (aptPackages *aptPackagesResource) Create(ctx context.Context, req resource
 		}
 	}
 
-	err = aptPackages.ensureInstalled(ctx, toInsall)
+	err = aptPackages.ensureInstalled(ctx, aptOptions, plan.Retry, toInsall)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to install apt packages", err.Error())
 		return
@@ -140,7 +165,7 @@ func (aptPackages *aptPackagesResource) Create(ctx context.Context, req resource
 		}
 	}
 
-	err = aptPackages.ensureRemoved(ctx, toRemove)
+	err = aptPackages.ensureRemoved(ctx, aptOptions, toRemove)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to remove apt packages", err.Error())
 		return
@@ -159,6 +184,8 @@ func (aptPackages *aptPackagesResource) Read(_ context.Context, _ resource.ReadR
 }
 
 func (aptPackages *aptPackagesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = aptPackages.provider.mutating(ctx, aptPackages, "update", req.Plan)
+
 	var oldModel aptPackagesResourceModel
 
 	diags := req.State.Get(ctx, &oldModel)
@@ -177,6 +204,18 @@ func (aptPackages *aptPackagesResource) Update(ctx context.Context, req resource
 		return
 	}
 
+	ctx, cancel, err := withTimeout(ctx, newModel.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	aptOptions := stringList(ctx, newModel.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	currentlyInstalledPackages, err := aptPackages.listCurrentlyInstalledPackages(ctx)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list currently installed apt packages", err.Error())
@@ -225,7 +264,7 @@ func (aptPackages *aptPackagesResource) Update(ctx context.Context, req resource
 		delete(toRemoveSet, pkg)
 	}
 
-	err = aptPackages.ensureInstalled(ctx, toInsall)
+	err = aptPackages.ensureInstalled(ctx, aptOptions, newModel.Retry, toInsall)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to install apt packages", err.Error())
 		return
@@ -254,7 +293,7 @@ func (aptPackages *aptPackagesResource) Update(ctx context.Context, req resource
 		toRemove = append(toRemove, key)
 	}
 
-	err = aptPackages.ensureRemoved(ctx, toRemove)
+	err = aptPackages.ensureRemoved(ctx, aptOptions, toRemove)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to remove apt packages", err.Error())
 		return
@@ -269,6 +308,8 @@ func (aptPackages *aptPackagesResource) Update(ctx context.Context, req resource
 }
 
 func (aptPackages *aptPackagesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = aptPackages.provider.mutating(ctx, aptPackages, "delete", req.State)
+
 	var plan aptPackagesResourceModel
 
 	diags := req.State.Get(ctx, &plan)
@@ -278,6 +319,18 @@ func (aptPackages *aptPackagesResource) Delete(ctx context.Context, req resource
 		return
 	}
 
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	aptOptions := stringList(ctx, plan.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	currentlyInstalledPackages, err := aptPackages.listCurrentlyInstalledPackages(ctx)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list currently installed apt packages", err.Error())
@@ -304,19 +357,15 @@ func (aptPackages *aptPackagesResource) Delete(ctx context.Context, req resource
 		}
 	}
 
-	err = aptPackages.ensureRemoved(ctx, toRemove)
+	err = aptPackages.ensureRemoved(ctx, aptOptions, toRemove)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to remove apt packages", err.Error())
 		return
 	}
 }
 
-func (aptPackages *aptPackagesResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
-}
-
 func (aptPackages *aptPackagesResource) listCurrentlyInstalledPackages(ctx context.Context) ([]string, error) {
-	out, err := aptPackages.provider.machineAccessClient.RunCommand(ctx, "sudo apt list --installed")
+	out, err := aptPackages.provider.machineAccessClient.RunCommand(ctx, aptPackages.provider.sudo("apt list --installed"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list installed apt packages. Err=%w\nout = %s", err, string(out))
 	}
@@ -333,18 +382,20 @@ func (aptPackages *aptPackagesResource) listCurrentlyInstalledPackages(ctx conte
 	return packages, nil
 }
 
-func (aptPackages *aptPackagesResource) ensureRemoved(ctx context.Context, toRemoved []string) error {
+func (aptPackages *aptPackagesResource) ensureRemoved(ctx context.Context, options []string, toRemoved []string) error {
 	if len(toRemoved) == 0 {
 		tflog.Debug(ctx, "No apt packages to remove")
 		return nil
 	}
 
-	out, err := aptPackages.provider.machineAccessClient.RunCommand(ctx, "sudo apt-get remove -y "+strings.Join(toRemoved, " "))
+	defer aptPackages.provider.lock(ctx, aptLock)()
+
+	out, err := aptPackages.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), aptPackages.provider.sudo(aptGet(options, append([]string{"remove", "-y"}, toRemoved...)...)))
 	if err != nil {
 		return fmt.Errorf("failed to remove apt packages. Err=%w\nout = %s", err, string(out))
 	}
 
-	out, err = aptPackages.provider.machineAccessClient.RunCommand(ctx, "sudo apt autoremove -y")
+	out, err = aptPackages.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), aptPackages.provider.sudo(aptGet(options, "autoremove", "-y")))
 	if err != nil {
 		return fmt.Errorf("failed to auto-remove apt packages. Err=%s\nout = %s", err, string(out))
 	}
@@ -352,13 +403,23 @@ func (aptPackages *aptPackagesResource) ensureRemoved(ctx context.Context, toRem
 	return nil
 }
 
-func (aptPackages *aptPackagesResource) ensureInstalled(ctx context.Context, toInstall []string) error {
+func (aptPackages *aptPackagesResource) ensureInstalled(ctx context.Context, options []string, policy *retryModel, toInstall []string) error {
 	if len(toInstall) == 0 {
 		tflog.Debug(ctx, "No apt packages to install")
 		return nil
 	}
 
-	out, err := aptPackages.provider.machineAccessClient.RunCommand(ctx, "sudo apt update && sudo apt-get install -y "+strings.Join(toInstall, " "))
+	if err := aptPackages.provider.configureAptProxy(ctx); err != nil {
+		return fmt.Errorf("failed to configure the apt proxy: %w", err)
+	}
+
+	defer aptPackages.provider.lock(ctx, aptLock)()
+
+	command := aptPackages.provider.sudo(aptPackages.provider.withProxy(aptGet(options, "update") + " && " + aptGet(options, append([]string{"install", "-y"}, toInstall...)...)))
+
+	out, err := retry(ctx, policy, func() (string, error) {
+		return aptPackages.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), command)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to install apt packages. Err=%w\nout = %s", err, string(out))
 	}
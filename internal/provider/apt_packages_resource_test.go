@@ -6,10 +6,13 @@ import (
 	"io"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -358,3 +361,76 @@ resource "setup_apt_packages" "docker_packages" {
 }
 `, dockerGpgKey)
 }
+
+func TestAptPackagesResourceWithMock(t *testing.T) {
+	t.Run("create runs apt-get without prompts and with the apt options", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("apt list --installed", clients.MockResponse{Stdout: "Listing...\ncurl/noble,now 8.5.0 amd64 [installed]\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newAptPackagesResource(newTestProvider(mock)), aptPackagesResourceModel{
+			Package: []*aptPackagesResourcePackageModel{
+				{Name: types.StringValue("curl"), Absent: types.BoolValue(false)},
+				{Name: types.StringValue("nginx"), Absent: types.BoolValue(false)},
+			},
+			AptOptions: defaultAptOptions(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "DEBIAN_FRONTEND=noninteractive apt-get -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold update && " +
+			"DEBIAN_FRONTEND=noninteractive apt-get -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold install -y nginx"
+		if mock.Commands[len(mock.Commands)-1] != expected {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create retries an install failing on the network", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("install -y nginx$", clients.MockResponse{Stdout: "Temporary failure resolving 'archive.ubuntu.com'", ExitCode: 100, Once: true})
+
+		// Act
+		_, diags := testResourceCreate(t, newAptPackagesResource(newTestProvider(mock)), aptPackagesResourceModel{
+			Package:    []*aptPackagesResourcePackageModel{{Name: types.StringValue("nginx"), Absent: types.BoolValue(false)}},
+			AptOptions: types.ListNull(types.StringType),
+			Retry:      &retryModel{Attempts: types.Int64Value(2), Delay: types.StringValue("0s"), OnOutputRegex: types.StringValue("Temporary failure")},
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		installs := slices.DeleteFunc(slices.Clone(mock.Commands), func(command string) bool { return !strings.HasSuffix(command, "install -y nginx") })
+		if len(installs) != 2 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("delete removes the installed packages with the apt options", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("apt list --installed", clients.MockResponse{Stdout: "Listing...\nnginx/noble,now 1.24.0 amd64 [installed]\n"})
+
+		// Act
+		diags := testResourceDelete(t, newAptPackagesResource(newTestProvider(mock)), aptPackagesResourceModel{
+			Package:    []*aptPackagesResourcePackageModel{{Name: types.StringValue("nginx"), Absent: types.BoolValue(false)}},
+			AptOptions: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("Acquire::Retries=3")}),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "DEBIAN_FRONTEND=noninteractive apt-get -o Acquire::Retries=3 remove -y nginx") ||
+			!slices.Contains(mock.Commands, "DEBIAN_FRONTEND=noninteractive apt-get -o Acquire::Retries=3 autoremove -y") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
@@ -5,7 +5,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -18,6 +20,7 @@ import (
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &aptRepositoryResource{}
 var _ resource.ResourceWithImportState = &aptRepositoryResource{}
+var _ resource.ResourceWithModifyPlan = &aptRepositoryResource{}
 
 func newAptRepositoryResource(p *internalProvider) resource.Resource {
 	return &aptRepositoryResource{
@@ -31,9 +34,14 @@ type aptRepositoryResource struct {
 }
 
 type aptRepositoryResourceModel struct {
+	ID   types.String `tfsdk:"id"`
 	Key  types.String `tfsdk:"key"`
 	Name types.String `tfsdk:"name"`
 	URL  types.String `tfsdk:"url"`
+
+	AptOptions types.List `tfsdk:"apt_options"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (aptRepository *aptRepositoryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -42,20 +50,29 @@ func (aptRepository *aptRepositoryResource) Metadata(_ context.Context, req reso
 
 func (aptRepository *aptRepositoryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Apt key resource",
+		MarkdownDescription: "Apt repository resource that adds a repository signed by its own key in /etc/apt/keyrings, " +
+			"instead of the deprecated apt-key trusted keyring. It can be imported with its name, e.g. " +
+			"`terraform import setup_apt_repository.docker docker`, the key and the url being read from " +
+			"/etc/apt/keyrings/<name>.asc and /etc/apt/sources.list.d/<name>.list",
 		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The name of the apt repository",
+			},
 			"key": schema.StringAttribute{
 				Required:    true,
 				Description: "The apt key to add",
 			},
 			"name": schema.StringAttribute{
-				Optional:    true,
-				Description: "The name of the apt repository",
+				Required:    true,
+				Description: "The name of the apt repository, which names its key and sources list files",
 			},
 			"url": schema.StringAttribute{
 				Optional:    true,
 				Description: "The url of the apt repository",
 			},
+			"apt_options": aptOptionsAttribute(),
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -78,7 +95,28 @@ func (aptRepository *aptRepositoryResource) Configure(_ context.Context, req res
 	aptRepository.provider = provider
 }
 
+func (aptRepository *aptRepositoryResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var name types.String
+
+	diags := req.Plan.GetAttribute(ctx, path.Root("name"), &name)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The id is the name, which is only unknown when it comes from another resource
+	diags = resp.Plan.SetAttribute(ctx, path.Root("id"), name)
+	resp.Diagnostics.Append(diags...)
+}
+
 func (aptRepository *aptRepositoryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = aptRepository.provider.mutating(ctx, aptRepository, "create", req.Plan)
+
 	var plan aptRepositoryResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -93,7 +131,7 @@ func (aptRepository *aptRepositoryResource) Create(ctx context.Context, req reso
 	// https://www.geeksforgeeks.org/install-and-use-docker-on-ubuntu-2204/
 
 	// 1. Make sure that /etc/apt/keyrings/ exists
-	_, err := aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo install -d -m 0755 /etc/apt/keyrings/")
+	_, err := aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo("install -d -m 0755 /etc/apt/keyrings/"))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to create /etc/apt/keyrings/ folder", err.Error())
 		return
@@ -129,14 +167,27 @@ func (aptRepository *aptRepositoryResource) Create(ctx context.Context, req reso
 	//   "deb [arch=$arch signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu \
 	//   $(flavor) stable" | \
 	//   sudo tee /etc/apt/sources.list.d/docker.list > /dev/null
-	_, err = aptRepository.provider.machineAccessClient.RunCommand(ctx, `echo "deb [arch=`+arch+` signed-by=/etc/apt/keyrings/`+plan.Name.ValueString()+`.asc] `+plan.URL.ValueString()+` `+flavor+` stable" | sudo tee /etc/apt/sources.list.d/`+plan.Name.ValueString()+`.list > /dev/null`)
+	_, err = aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(aptSourceListCommand(plan.Name.ValueString(), plan.URL.ValueString(), arch, flavor)))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to add repository to sources.list.d", err.Error())
 		return
 	}
 
 	// 6. Update apt package cache to ensure the repository is accessible
-	updateOutput, err := aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo apt update")
+	aptOptions := stringList(ctx, plan.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err = aptRepository.provider.configureAptProxy(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to configure the apt proxy", err.Error())
+		return
+	}
+
+	defer aptRepository.provider.lock(ctx, aptLock)()
+
+	updateOutput, err := aptRepository.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), aptRepository.provider.sudo(aptRepository.provider.withProxy(aptGet(aptOptions, "update"))))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to update apt package cache after adding repository", "This usually means the repository URL is invalid, the GPG key is incorrect, or the repository doesn't support your system architecture/distribution.\n\nRepository: "+plan.URL.ValueString()+" "+flavor+"\nArchitecture: "+arch+"\n\nError: "+err.Error()+"\n\nOutput: "+string(updateOutput))
 		return
@@ -149,6 +200,8 @@ func (aptRepository *aptRepositoryResource) Create(ctx context.Context, req reso
 		return
 	}
 
+	plan.ID = plan.Name
+
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 
@@ -158,6 +211,8 @@ func (aptRepository *aptRepositoryResource) Create(ctx context.Context, req reso
 }
 
 func (aptRepository *aptRepositoryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = aptRepository.provider.onHost(ctx, req.State)
+
 	var state aptRepositoryResourceModel
 
 	diags := req.State.Get(ctx, &state)
@@ -167,32 +222,58 @@ func (aptRepository *aptRepositoryResource) Read(ctx context.Context, req resour
 		return
 	}
 
-	// Check if the repository key file exists
+	// The key and the url are read back from the files, which is also how an imported repository gets them
 	keyPath := "/etc/apt/keyrings/" + state.Name.ValueString() + ".asc"
 
-	_, err := aptRepository.provider.machineAccessClient.RunCommand(ctx, "test -f "+keyPath)
-	if err != nil {
+	key, err := aptRepository.provider.machineAccessClient.ReadFile(ctx, keyPath, true)
+	if clients.IsFileNotFound(err) {
 		// Key file doesn't exist, remove from state
 		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	// Check if the repository source list exists
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read key file", err.Error())
+		return
+	}
+
 	sourceListPath := "/etc/apt/sources.list.d/" + state.Name.ValueString() + ".list"
 
-	_, err = aptRepository.provider.machineAccessClient.RunCommand(ctx, "test -f "+sourceListPath)
-	if err != nil {
+	sourceList, err := aptRepository.provider.machineAccessClient.ReadFile(ctx, sourceListPath, true)
+	if clients.IsFileNotFound(err) {
 		// Source list doesn't exist, remove from state
 		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	// Repository exists, keep the current state
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read source list", err.Error())
+		return
+	}
+
+	url, err := parseAptSourceListURL(sourceList)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse source list "+sourceListPath, err.Error())
+		return
+	}
+
+	state.ID = state.Name
+	state.Key = types.StringValue(key)
+	state.URL = types.StringValue(url)
+
+	// The options are not stored on the machine, an imported repository gets the default ones
+	if state.AptOptions.IsNull() {
+		state.AptOptions = defaultAptOptions()
+	}
+
+	// Repository exists, refresh the state
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 }
 
 func (aptRepository *aptRepositoryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = aptRepository.provider.mutating(ctx, aptRepository, "update", req.Plan)
+
 	var plan aptRepositoryResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -216,7 +297,7 @@ func (aptRepository *aptRepositoryResource) Update(ctx context.Context, req reso
 		// Remove old key file
 		oldKeyPath := "/etc/apt/keyrings/" + state.Name.ValueString() + ".asc"
 
-		_, err := aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo rm -f "+oldKeyPath)
+		_, err := aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(clients.ShellCommand("rm", "-f", oldKeyPath)))
 		if err != nil {
 			resp.Diagnostics.AddWarning("Failed to remove old key file", err.Error())
 		}
@@ -224,7 +305,7 @@ func (aptRepository *aptRepositoryResource) Update(ctx context.Context, req reso
 		// Remove old source list
 		oldSourceListPath := "/etc/apt/sources.list.d/" + state.Name.ValueString() + ".list"
 
-		_, err = aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo rm -f "+oldSourceListPath)
+		_, err = aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(clients.ShellCommand("rm", "-f", oldSourceListPath)))
 		if err != nil {
 			resp.Diagnostics.AddWarning("Failed to remove old source list", err.Error())
 		}
@@ -255,17 +336,21 @@ func (aptRepository *aptRepositoryResource) Update(ctx context.Context, req reso
 	flavor := strings.ReplaceAll(string(flavorResponse), "\n", "")
 
 	// Update the repository source list
-	_, err = aptRepository.provider.machineAccessClient.RunCommand(ctx, `echo "deb [arch=`+arch+` signed-by=/etc/apt/keyrings/`+plan.Name.ValueString()+`.asc] `+plan.URL.ValueString()+` `+flavor+` stable" | sudo tee /etc/apt/sources.list.d/`+plan.Name.ValueString()+`.list > /dev/null`)
+	_, err = aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(aptSourceListCommand(plan.Name.ValueString(), plan.URL.ValueString(), arch, flavor)))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to update repository source list", err.Error())
 		return
 	}
 
+	plan.ID = plan.Name
+
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 }
 
 func (aptRepository *aptRepositoryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = aptRepository.provider.mutating(ctx, aptRepository, "delete", req.State)
+
 	var state aptRepositoryResourceModel
 
 	diags := req.State.Get(ctx, &state)
@@ -278,7 +363,7 @@ func (aptRepository *aptRepositoryResource) Delete(ctx context.Context, req reso
 	// Remove the key file
 	keyPath := "/etc/apt/keyrings/" + state.Name.ValueString() + ".asc"
 
-	_, err := aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo rm -f "+keyPath)
+	_, err := aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(clients.ShellCommand("rm", "-f", keyPath)))
 	if err != nil {
 		resp.Diagnostics.AddWarning("Failed to remove key file", err.Error())
 	}
@@ -286,13 +371,20 @@ func (aptRepository *aptRepositoryResource) Delete(ctx context.Context, req reso
 	// Remove the source list file
 	sourceListPath := "/etc/apt/sources.list.d/" + state.Name.ValueString() + ".list"
 
-	_, err = aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo rm -f "+sourceListPath)
+	_, err = aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(clients.ShellCommand("rm", "-f", sourceListPath)))
 	if err != nil {
 		resp.Diagnostics.AddWarning("Failed to remove source list file", err.Error())
 	}
 
 	// Update apt package cache to reflect the changes
-	_, err = aptRepository.provider.machineAccessClient.RunCommand(ctx, "sudo apt-get update")
+	aptOptions := stringList(ctx, state.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	defer aptRepository.provider.lock(ctx, aptLock)()
+
+	_, err = aptRepository.provider.machineAccessClient.Run(ctx, aptRepository.provider.sudo(aptRepository.provider.withProxy(aptGet(aptOptions, "update"))))
 	if err != nil {
 		resp.Diagnostics.AddWarning("Failed to update apt package cache", err.Error())
 	}
@@ -300,4 +392,43 @@ func (aptRepository *aptRepositoryResource) Delete(ctx context.Context, req reso
 
 func (aptRepository *aptRepositoryResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+}
+
+// parseAptSourceListURL returns the url of the first deb line of a one-line-style sources list, e.g.
+// deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable
+func parseAptSourceListURL(sourceList string) (string, error) {
+	for _, line := range strings.Split(sourceList, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "deb" {
+			continue
+		}
+
+		fields = fields[1:]
+
+		// Skip the options, which may contain spaces between their brackets
+		if strings.HasPrefix(fields[0], "[") {
+			for len(fields) > 0 && !strings.HasSuffix(fields[0], "]") {
+				fields = fields[1:]
+			}
+
+			if len(fields) > 0 {
+				fields = fields[1:]
+			}
+		}
+
+		if len(fields) > 0 {
+			return fields[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("no deb line found")
+}
+
+// aptSourceListCommand returns the command writing the one-line-style sources list of the repository, e.g.
+// deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable
+func aptSourceListCommand(name string, url string, arch string, flavor string) string {
+	line := "deb [arch=" + arch + " signed-by=/etc/apt/keyrings/" + name + ".asc] " + url + " " + flavor + " stable"
+
+	return clients.ShellCommand("echo", line) + " | " + clients.ShellCommand("tee", "/etc/apt/sources.list.d/"+name+".list") + " > /dev/null"
 }
@@ -9,6 +9,7 @@ import (
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -64,6 +65,12 @@ func TestAptRepositoryResource(t *testing.T) {
 						},
 					),
 				},
+				{
+					ResourceName:      "setup_apt_repository.repo",
+					ImportState:       true,
+					ImportStateId:     "docker",
+					ImportStateVerify: true,
+				},
 				{
 					Config: testProviderConfig(setup, "test", "localhost") + testAptRepositoryResourceConfigWithHTTPKey(
 						t, "docker",
@@ -349,3 +356,72 @@ EOT
 }
 `, name, key, url)
 }
+
+func TestAptRepositoryResourceWithMock(t *testing.T) {
+	t.Run("read reconstructs the key and the url of an imported repository", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/apt/keyrings/docker.asc", "-----BEGIN PGP PUBLIC KEY BLOCK-----\n", clients.FileInfo{Mode: "644"}).
+			WithFile("/etc/apt/sources.list.d/docker.list", "deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newAptRepositoryResource(newTestProvider(mock)), aptRepositoryResourceModel{
+			ID:         types.StringValue("docker"),
+			Name:       types.StringValue("docker"),
+			Key:        types.StringNull(),
+			URL:        types.StringNull(),
+			AptOptions: types.ListNull(types.StringType),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: %v, %v", diags, removed)
+		}
+
+		if state.Key.ValueString() != "-----BEGIN PGP PUBLIC KEY BLOCK-----\n" || state.URL.ValueString() != "https://download.docker.com/linux/ubuntu" || !state.AptOptions.Equal(defaultAptOptions()) {
+			t.Fatalf("unexpected state: %v", state)
+		}
+	})
+
+	t.Run("read removes the resource when the sources list is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/apt/keyrings/docker.asc", "key", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newAptRepositoryResource(newTestProvider(mock)), aptRepositoryResourceModel{
+			ID:         types.StringValue("docker"),
+			Name:       types.StringValue("docker"),
+			Key:        types.StringValue("key"),
+			URL:        types.StringValue("https://download.docker.com/linux/ubuntu"),
+			AptOptions: defaultAptOptions(),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed: %v", diags)
+		}
+	})
+}
+
+func TestParseAptSourceListURL(t *testing.T) {
+	tests := map[string]string{
+		"deb https://example.com/debian bookworm main\n":                                              "https://example.com/debian",
+		"# comment\ndeb [arch=amd64 signed-by=/etc/apt/keyrings/a.asc] https://a.example stable main": "https://a.example",
+		"deb [ arch=amd64 ] http://b.example/ubuntu noble stable":                                     "http://b.example/ubuntu",
+	}
+
+	for sourceList, expected := range tests {
+		// Act
+		url, err := parseAptSourceListURL(sourceList)
+
+		// Assert
+		if err != nil || url != expected {
+			t.Errorf("unexpected url for %q: %s, %v", sourceList, url, err)
+		}
+	}
+
+	if _, err := parseAptSourceListURL("# empty\n"); err == nil {
+		t.Error("expected an error for a sources list without deb line")
+	}
+}
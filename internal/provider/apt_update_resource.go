@@ -0,0 +1,253 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// aptUpdateStampPath is touched after each successful update of the package cache, as done by the apt configuration
+// of Ubuntu, so that its age is known.
+const aptUpdateStampPath = "/var/lib/apt/periodic/update-success-stamp"
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &aptUpdateResource{}
+var _ resource.ResourceWithModifyPlan = &aptUpdateResource{}
+
+func newAptUpdateResource(p *internalProvider) resource.Resource {
+	return &aptUpdateResource{
+		provider: p,
+	}
+}
+
+// aptUpdateResource defines the resource implementation.
+type aptUpdateResource struct {
+	provider *internalProvider
+}
+
+type aptUpdateResourceModel struct {
+	Triggers   types.Map    `tfsdk:"triggers"`
+	MaxAge     types.String `tfsdk:"max_age"`
+	AptOptions types.List   `tfsdk:"apt_options"`
+	UpdatedAt  types.String `tfsdk:"updated_at"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *aptUpdateResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_apt_update"
+}
+
+func (r *aptUpdateResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Apt update resource that updates the package cache with apt-get update when it is created, " +
+			"and again whenever triggers change, e.g. `{ docker = setup_apt_repository.docker.url }`. The resources " +
+			"installing packages can then depend on it, so that the cache is updated once per apply rather than by each " +
+			"of them. Destroying the resource leaves the cache as it is",
+
+		Attributes: map[string]schema.Attribute{
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Arbitrary values that cause an update when changed, e.g. the urls of the repositories",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"max_age": schema.StringAttribute{
+				Optional: true,
+				Description: "The age of the package cache, e.g. '24h', below which the update is skipped, as it is recent enough. " +
+					"The cache is also updated again when it gets older than max_age, at the next apply. " +
+					"By default, the cache is only updated on creation and when triggers change",
+				Validators: []validator.String{duration()},
+			},
+			"apt_options": aptOptionsAttribute(),
+			"updated_at": schema.StringAttribute{
+				Computed:    true,
+				Description: "The time of the last successful update of the package cache, in RFC 3339 format",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *aptUpdateResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *aptUpdateResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		// The resource is created or destroyed
+		return
+	}
+
+	var plan aptUpdateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state aptUpdateResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.MaxAge.IsNull() || plan.MaxAge.IsUnknown() {
+		return
+	}
+
+	// The update time is refreshed by Read, a cache older than max_age is updated by replacing the resource
+	if r.expired(state.UpdatedAt, plan.MaxAge) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("updated_at"), types.StringUnknown())...)
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("updated_at"))
+	}
+}
+
+func (r *aptUpdateResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan aptUpdateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	aptOptions := stringList(ctx, plan.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	updatedAt := r.readUpdatedAt(ctx)
+
+	if !plan.MaxAge.IsNull() && !r.expired(updatedAt, plan.MaxAge) {
+		tflog.Info(ctx, "The package cache was updated at "+updatedAt.ValueString()+", less than "+plan.MaxAge.ValueString()+" ago, skipping the update")
+	} else {
+		if err := r.provider.configureAptProxy(ctx); err != nil {
+			resp.Diagnostics.AddError("Failed to configure the apt proxy", err.Error())
+			return
+		}
+
+		defer r.provider.lock(ctx, aptLock)()
+
+		command := aptGet(aptOptions, "update") + " && mkdir -p /var/lib/apt/periodic && touch " + aptUpdateStampPath
+
+		out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(command)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update the package cache", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+
+		updatedAt = r.readUpdatedAt(ctx)
+		if updatedAt.IsNull() {
+			updatedAt = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+		}
+	}
+
+	plan.UpdatedAt = updatedAt
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *aptUpdateResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state aptUpdateResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The cache may have been updated since, e.g. by another apply or by unattended-upgrades
+	if updatedAt := r.readUpdatedAt(ctx); !updatedAt.IsNull() {
+		state.UpdatedAt = updatedAt
+	}
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *aptUpdateResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan aptUpdateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Changing max_age or the options does not update the cache again
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *aptUpdateResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// nothing to do, the package cache is left as is
+}
+
+// readUpdatedAt returns the time of the last successful update of the package cache, or null when it is unknown.
+func (r *aptUpdateResource) readUpdatedAt(ctx context.Context) types.String {
+	info, err := r.provider.machineAccessClient.Stat(ctx, aptUpdateStampPath, true)
+	if err != nil {
+		if !clients.IsFileNotFound(err) {
+			tflog.Warn(ctx, "Failed to read the time of the last update of the package cache: "+err.Error())
+		}
+
+		return types.StringNull()
+	}
+
+	return types.StringValue(info.ModTime.UTC().Format(time.RFC3339))
+}
+
+// expired returns true when the package cache was updated more than max age ago, or when the time of its update is
+// unknown.
+func (r *aptUpdateResource) expired(updatedAt types.String, maxAge types.String) bool {
+	age, err := time.ParseDuration(maxAge.ValueString())
+	if err != nil {
+		return true
+	}
+
+	updated, err := time.Parse(time.RFC3339, updatedAt.ValueString())
+	if err != nil {
+		return true
+	}
+
+	return time.Since(updated) > age
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func testAptUpdateModel(maxAge types.String, updatedAt types.String) aptUpdateResourceModel {
+	return aptUpdateResourceModel{
+		Triggers:   types.MapNull(types.StringType),
+		MaxAge:     maxAge,
+		AptOptions: types.ListNull(types.StringType),
+		UpdatedAt:  updatedAt,
+	}
+}
+
+func TestAptUpdateResourceWithMock(t *testing.T) {
+	t.Run("create updates the package cache", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		state, diags := testResourceCreate(t, newAptUpdateResource(newTestProvider(mock)), testAptUpdateModel(types.StringNull(), types.StringUnknown()))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "DEBIAN_FRONTEND=noninteractive apt-get update && mkdir -p /var/lib/apt/periodic && touch /var/lib/apt/periodic/update-success-stamp"
+		if !slices.Contains(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if state.UpdatedAt.IsNull() || state.UpdatedAt.IsUnknown() {
+			t.Fatalf("unexpected update time: %v", state.UpdatedAt)
+		}
+	})
+
+	t.Run("create skips the update of a package cache younger than max_age", func(t *testing.T) {
+		// Arrange
+		updatedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(aptUpdateStampPath, "", clients.FileInfo{Mode: "644", ModTime: updatedAt})
+
+		// Act
+		state, diags := testResourceCreate(t, newAptUpdateResource(newTestProvider(mock)), testAptUpdateModel(types.StringValue("24h"), types.StringUnknown()))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if state.UpdatedAt.ValueString() != updatedAt.Format(time.RFC3339) {
+			t.Fatalf("unexpected update time: %v", state.UpdatedAt)
+		}
+	})
+
+	t.Run("create updates a package cache older than max_age", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(aptUpdateStampPath, "", clients.FileInfo{Mode: "644", ModTime: time.Now().Add(-48 * time.Hour)})
+
+		// Act
+		_, diags := testResourceCreate(t, newAptUpdateResource(newTestProvider(mock)), testAptUpdateModel(types.StringValue("24h"), types.StringUnknown()))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("plan replaces the resource when the package cache gets older than max_age", func(t *testing.T) {
+		// Arrange
+		state := testAptUpdateModel(types.StringValue("24h"), types.StringValue(time.Now().Add(-48*time.Hour).UTC().Format(time.RFC3339)))
+
+		// Act
+		plan, diags := testResourceModifyPlan(t, newAptUpdateResource(newTestProvider(clients.NewMockMachineAccessClient())).(*aptUpdateResource), &state, state)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !plan.UpdatedAt.IsUnknown() {
+			t.Fatalf("expected the update time to be unknown: %v", plan.UpdatedAt)
+		}
+	})
+
+	t.Run("plan keeps a package cache younger than max_age", func(t *testing.T) {
+		// Arrange
+		state := testAptUpdateModel(types.StringValue("24h"), types.StringValue(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)))
+
+		// Act
+		plan, diags := testResourceModifyPlan(t, newAptUpdateResource(newTestProvider(clients.NewMockMachineAccessClient())).(*aptUpdateResource), &state, state)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !plan.UpdatedAt.Equal(state.UpdatedAt) {
+			t.Fatalf("unexpected update time: %v", plan.UpdatedAt)
+		}
+	})
+
+	t.Run("read refreshes the update time", func(t *testing.T) {
+		// Arrange
+		updatedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(aptUpdateStampPath, "", clients.FileInfo{Mode: "644", ModTime: updatedAt})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newAptUpdateResource(newTestProvider(mock)), testAptUpdateModel(types.StringNull(), types.StringValue("2026-01-01T00:00:00Z")))
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: %v, %v", diags, removed)
+		}
+
+		if state.UpdatedAt.ValueString() != "2026-03-01T12:00:00Z" {
+			t.Fatalf("unexpected update time: %v", state.UpdatedAt)
+		}
+	})
+}
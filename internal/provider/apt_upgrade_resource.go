@@ -0,0 +1,244 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &aptUpgradeResource{}
+
+func newAptUpgradeResource(p *internalProvider) resource.Resource {
+	return &aptUpgradeResource{
+		provider: p,
+	}
+}
+
+// aptUpgradeResource defines the resource implementation.
+type aptUpgradeResource struct {
+	provider *internalProvider
+}
+
+type aptUpgradeResourceModel struct {
+	Mode             types.String `tfsdk:"mode"`
+	Triggers         types.Map    `tfsdk:"triggers"`
+	UpdateCache      types.Bool   `tfsdk:"update_cache"`
+	Autoremove       types.Bool   `tfsdk:"autoremove"`
+	AptOptions       types.List   `tfsdk:"apt_options"`
+	PackagesUpgraded types.List   `tfsdk:"packages_upgraded"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *aptUpgradeResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_apt_upgrade"
+}
+
+func (r *aptUpgradeResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Apt upgrade resource that upgrades the installed packages when it is created, and again " +
+			"whenever triggers change, e.g. `{ cycle = \"2026-10\" }` for a monthly patch cycle. A setup_reboot can " +
+			"depend on it to reboot into the upgraded kernel. Destroying the resource leaves the packages as they are",
+
+		Attributes: map[string]schema.Attribute{
+			"mode": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("upgrade"),
+				Description: "How the packages are upgraded: 'upgrade' upgrades them without installing or removing other packages, " +
+					"'full-upgrade' also installs and removes packages to satisfy the new dependencies, and 'security' only upgrades " +
+					"the packages with an upgrade from a security repository, e.g. noble-security. Defaults to 'upgrade'",
+				Validators: []validator.String{oneOf("upgrade", "full-upgrade", "security")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Arbitrary values that cause an upgrade when changed, e.g. the name of the patch cycle",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"update_cache": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether the package cache is updated with apt-get update before the upgrade. Defaults to true",
+			},
+			"autoremove": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the packages that are no longer needed, e.g. the previous kernels, are removed after the upgrade. Defaults to false",
+			},
+			"apt_options": aptOptionsAttribute(),
+			"packages_upgraded": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The names of the packages that were upgraded, empty when they were all up to date",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *aptUpgradeResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *aptUpgradeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan aptUpgradeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	aptOptions := stringList(ctx, plan.AptOptions, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		resp.Diagnostics.AddError("Failed to configure the apt proxy", err.Error())
+		return
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	if plan.UpdateCache.ValueBool() {
+		out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(aptGet(aptOptions, "update"))))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update the package cache", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	mode := plan.Mode.ValueString()
+
+	// The upgrade is simulated first, which lists the upgraded packages along with the repositories they come from
+	simulated := mode
+	if mode == "security" {
+		simulated = "upgrade"
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(aptGet(aptOptions, "-s", simulated)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list the packages to upgrade", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	upgrades := parseAptSimulatedUpgrades(out, mode == "security")
+
+	if len(upgrades) == 0 {
+		tflog.Info(ctx, "All the packages are up to date")
+	} else {
+		command := aptGet(aptOptions, mode, "-y")
+		if mode == "security" {
+			command = aptGet(aptOptions, append([]string{"install", "--only-upgrade", "-y"}, upgrades...)...)
+		}
+
+		out, err = r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(command)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to upgrade the packages", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	if plan.Autoremove.ValueBool() {
+		out, err = r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(aptGet(aptOptions, "autoremove", "-y")))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to remove the packages that are no longer needed", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	plan.PackagesUpgraded, diags = types.ListValueFrom(ctx, types.StringType, upgrades)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *aptUpgradeResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
+	// the upgrade only happens on creation, there is nothing to read back
+}
+
+func (r *aptUpgradeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan aptUpgradeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Changing the options does not upgrade the packages again
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *aptUpgradeResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// nothing to do, the packages are left as they are
+}
+
+// parseAptSimulatedUpgrades returns the names of the packages upgraded by a simulation of apt-get, from its lines
+// like the following, the packages newly installed having no previous version. With securityOnly, only the packages
+// with a version from a security repository are returned.
+//
+//	Inst libssl3t64 [3.0.13-0ubuntu3.1] (3.0.13-0ubuntu3.4 Ubuntu:24.04/noble-updates, Ubuntu:24.04/noble-security [amd64])
+func parseAptSimulatedUpgrades(out string, securityOnly bool) []string {
+	upgrades := []string{}
+
+	for line := range strings.SplitSeq(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "Inst" || !strings.HasPrefix(fields[2], "[") {
+			continue
+		}
+
+		if securityOnly && !strings.Contains(strings.ToLower(line), "security") {
+			continue
+		}
+
+		upgrades = append(upgrades, fields[1])
+	}
+
+	return upgrades
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testAptSimulatedUpgrade = `NOTE: This is only a simulation!
+Reading package lists...
+Inst libssl3t64 [3.0.13-0ubuntu3.1] (3.0.13-0ubuntu3.4 Ubuntu:24.04/noble-updates, Ubuntu:24.04/noble-security [amd64])
+Inst curl [8.5.0-2ubuntu10.1] (8.5.0-2ubuntu10.4 Ubuntu:24.04/noble-updates [amd64])
+Inst linux-image-6.8.0-48-generic (6.8.0-48.48 Ubuntu:24.04/noble-updates [amd64])
+Conf libssl3t64 (3.0.13-0ubuntu3.4 Ubuntu:24.04/noble-updates, Ubuntu:24.04/noble-security [amd64])
+`
+
+func testAptUpgradeModel(mode string) aptUpgradeResourceModel {
+	return aptUpgradeResourceModel{
+		Mode:             types.StringValue(mode),
+		Triggers:         types.MapNull(types.StringType),
+		UpdateCache:      types.BoolValue(true),
+		Autoremove:       types.BoolValue(false),
+		AptOptions:       types.ListNull(types.StringType),
+		PackagesUpgraded: types.ListUnknown(types.StringType),
+	}
+}
+
+func TestAptUpgradeResourceWithMock(t *testing.T) {
+	t.Run("create upgrades the packages", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("DEBIAN_FRONTEND=noninteractive apt-get -s full-upgrade", clients.MockResponse{Stdout: testAptSimulatedUpgrade})
+
+		// Act
+		state, diags := testResourceCreate(t, newAptUpgradeResource(newTestProvider(mock)), testAptUpgradeModel("full-upgrade"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{
+			"DEBIAN_FRONTEND=noninteractive apt-get update",
+			"DEBIAN_FRONTEND=noninteractive apt-get -s full-upgrade",
+			"DEBIAN_FRONTEND=noninteractive apt-get full-upgrade -y",
+		}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if !state.PackagesUpgraded.Equal(types.ListValueMust(types.StringType, []attr.Value{types.StringValue("libssl3t64"), types.StringValue("curl")})) {
+			t.Fatalf("unexpected upgraded packages: %v", state.PackagesUpgraded)
+		}
+	})
+
+	t.Run("create only upgrades the packages from security repositories in security mode", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("DEBIAN_FRONTEND=noninteractive apt-get -s upgrade", clients.MockResponse{Stdout: testAptSimulatedUpgrade})
+
+		model := testAptUpgradeModel("security")
+		model.UpdateCache = types.BoolValue(false)
+		model.Autoremove = types.BoolValue(true)
+
+		// Act
+		_, diags := testResourceCreate(t, newAptUpgradeResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{
+			"DEBIAN_FRONTEND=noninteractive apt-get -s upgrade",
+			"DEBIAN_FRONTEND=noninteractive apt-get install --only-upgrade -y libssl3t64",
+			"DEBIAN_FRONTEND=noninteractive apt-get autoremove -y",
+		}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does nothing when the packages are up to date", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		state, diags := testResourceCreate(t, newAptUpgradeResource(newTestProvider(mock)), testAptUpgradeModel("upgrade"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 2 || len(state.PackagesUpgraded.Elements()) != 0 {
+			t.Fatalf("unexpected upgrade: %v, %v", mock.Commands, state.PackagesUpgraded)
+		}
+	})
+}
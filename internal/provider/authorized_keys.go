@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"strings"
+)
+
+// authorizedKey is an entry of an authorized_keys file, see the AUTHORIZED_KEYS FILE FORMAT section of sshd(8).
+type authorizedKey struct {
+	Options string
+	Type    string
+	Data    string
+	Comment string
+}
+
+// isKeyType returns whether the field is a key type, as opposed to the options that may start an entry.
+func isKeyType(field string) bool {
+	return strings.HasPrefix(field, "ssh-") ||
+		strings.HasPrefix(field, "ecdsa-sha2-") ||
+		strings.HasPrefix(field, "sk-ssh-") ||
+		strings.HasPrefix(field, "sk-ecdsa-sha2-")
+}
+
+// parseAuthorizedKey parses an entry of an authorized_keys file. It returns false for empty lines, comments and lines
+// that are not a key.
+func parseAuthorizedKey(line string) (authorizedKey, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return authorizedKey{}, false
+	}
+
+	var key authorizedKey
+
+	first, rest := splitAuthorizedKeyField(line)
+	if !isKeyType(first) {
+		// Options are a single field, whose quoted values may contain spaces, e.g. command="echo hello"
+		key.Options = first
+		first, rest = splitAuthorizedKeyField(rest)
+	}
+
+	if !isKeyType(first) {
+		return authorizedKey{}, false
+	}
+
+	key.Type = first
+	key.Data, key.Comment = splitAuthorizedKeyField(rest)
+
+	if key.Data == "" {
+		return authorizedKey{}, false
+	}
+
+	return key, true
+}
+
+// splitAuthorizedKeyField returns the first field of the line and the rest of it. Spaces between double quotes do not
+// end the field.
+func splitAuthorizedKeyField(line string) (string, string) {
+	line = strings.TrimLeft(line, " \t")
+	quoted := false
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && quoted && i+1 < len(line):
+			i++
+		case line[i] == '"':
+			quoted = !quoted
+		case (line[i] == ' ' || line[i] == '\t') && !quoted:
+			return line[:i], strings.TrimSpace(line[i:])
+		}
+	}
+
+	return line, ""
+}
+
+// sameKey returns whether both entries are for the same key, whatever their options and comments.
+func (key authorizedKey) sameKey(other authorizedKey) bool {
+	return key.Type == other.Type && key.Data == other.Data
+}
+
+func (key authorizedKey) String() string {
+	fields := []string{}
+	if key.Options != "" {
+		fields = append(fields, key.Options)
+	}
+
+	fields = append(fields, key.Type, key.Data)
+	if key.Comment != "" {
+		fields = append(fields, key.Comment)
+	}
+
+	return strings.Join(fields, " ")
+}
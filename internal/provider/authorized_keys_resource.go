@@ -0,0 +1,465 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &authorizedKeysResource{}
+var _ resource.ResourceWithImportState = &authorizedKeysResource{}
+var _ resource.ResourceWithUpgradeState = &authorizedKeysResource{}
+
+func newAuthorizedKeysResource(p *internalProvider) resource.Resource {
+	return &authorizedKeysResource{
+		provider: p,
+	}
+}
+
+// authorizedKeysResource defines the resource implementation.
+type authorizedKeysResource struct {
+	provider *internalProvider
+}
+
+type authorizedKeysResourceModel struct {
+	Path      types.String `tfsdk:"path"`
+	Keys      types.Set    `tfsdk:"keys"`
+	Exclusive types.Bool   `tfsdk:"exclusive"`
+	Mode      types.String `tfsdk:"mode"`
+	Owner     types.String `tfsdk:"owner"`
+	Group     types.String `tfsdk:"group"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *authorizedKeysResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_authorized_keys"
+}
+
+func (r *authorizedKeysResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Authorized keys resource that manages the keys of an authorized_keys file as a whole. " +
+			"With exclusive = true, keys not listed are removed, including keys added outside of terraform. " +
+			"Destroying the resource removes the listed keys and leaves the file in place",
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the authorized_keys file, e.g. /home/deploy/.ssh/authorized_keys. The directory is created with mode 700 when missing",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"keys": schema.SetAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The entries of the file, each in the format '[options] type data [comment]', e.g. 'no-pty ssh-ed25519 AAAA... deploy'",
+			},
+			"exclusive": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the keys not listed are removed from the file. Defaults to false, which leaves the other keys untouched",
+			},
+			"mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("600"),
+				Description: "The mode of the file. Defaults to 600",
+				Validators:  []validator.String{octalMode()},
+			},
+			"owner": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The owner of the file, a user name or a numeric UID. Defaults to the owner of the existing file, or to the connecting user",
+				Validators:  []validator.String{accountName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"group": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The group of the file, a group name or a numeric GID. Defaults to the group of the existing file, or to the group of the connecting user",
+				Validators:  []validator.String{accountName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *authorizedKeysResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *authorizedKeysResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan authorizedKeysResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *authorizedKeysResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model authorizedKeysResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	info, err := r.provider.machineAccessClient.Stat(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be created again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stat authorized_keys file", err.Error())
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read authorized_keys file", err.Error())
+		return
+	}
+
+	managed, diags := r.keys(ctx, model.Keys)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// After an import all the keys of the file are managed
+	all := model.Exclusive.ValueBool() || model.Keys.IsNull()
+
+	keys := []string{}
+
+	for _, line := range lines {
+		existing, ok := parseAuthorizedKey(line)
+		if !ok {
+			continue
+		}
+
+		if all || containsAuthorizedKey(managed, existing) {
+			keys = append(keys, strings.TrimSpace(line))
+		}
+	}
+
+	model.Keys, diags = types.SetValueFrom(ctx, types.StringType, keys)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if model.Exclusive.IsNull() {
+		model.Exclusive = types.BoolValue(false)
+	}
+
+	model.Mode = types.StringValue(info.Mode)
+	model.Owner = ownershipValue(ctx, r.provider.machineAccessClient, "passwd", model.Owner, info.Owner)
+	model.Group = ownershipValue(ctx, r.provider.machineAccessClient, "group", model.Group, info.Group)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *authorizedKeysResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan authorizedKeysResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state authorizedKeysResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	previous, diags := r.keys(ctx, state.Keys)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, previous, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *authorizedKeysResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model authorizedKeysResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	managed, diags := r.keys(ctx, model.Keys)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	defer r.provider.lockFile(ctx, model.Path.ValueString())()
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read authorized_keys file", err.Error())
+		return
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	kept := []string{}
+
+	for _, line := range lines {
+		existing, ok := parseAuthorizedKey(line)
+		if !ok || !containsAuthorizedKey(managed, existing) {
+			kept = append(kept, line)
+		}
+	}
+
+	owner, group, err := r.provider.lookupOwnership(ctx, model.Owner, model.Group)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to look up the owner of the authorized_keys file", err.Error())
+		return
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, model.Path.ValueString(), model.Mode.ValueString(), owner, group, joinLines(kept))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to write authorized_keys file", err.Error())
+		return
+	}
+}
+
+func (r *authorizedKeysResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: ownershipStateUpgrader(),
+	}
+}
+
+func (r *authorizedKeysResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("path"), req, resp)
+}
+
+// apply writes the planned keys to the file. The keys that were managed before and are not planned anymore are
+// removed, as well as all the other keys when exclusive is set. The owner and group of the plan are resolved.
+func (r *authorizedKeysResource) apply(ctx context.Context, plan *authorizedKeysResourceModel, previous []authorizedKey, diags *diag.Diagnostics) {
+	planned, d := r.keys(ctx, plan.Keys)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var plannedLines []string
+
+	diags.Append(plan.Keys.ElementsAs(ctx, &plannedLines, false)...)
+
+	if diags.HasError() {
+		return
+	}
+
+	for i, key := range planned {
+		for _, other := range planned[:i] {
+			if key.sameKey(other) {
+				diags.AddError("Duplicate key", fmt.Sprintf("The key %s %s is listed more than once", key.Type, key.Data))
+				return
+			}
+		}
+	}
+
+	filePath := plan.Path.ValueString()
+
+	defer r.provider.lockFile(ctx, filePath)()
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		diags.AddError("Failed to read authorized_keys file", err.Error())
+		return
+	}
+
+	newLines := []string{}
+
+	if !plan.Exclusive.ValueBool() {
+		for _, line := range lines {
+			existing, ok := parseAuthorizedKey(line)
+			if !ok || (!containsAuthorizedKey(planned, existing) && !containsAuthorizedKey(previous, existing)) {
+				newLines = append(newLines, line)
+			}
+		}
+	}
+
+	for _, line := range plannedLines {
+		newLines = append(newLines, strings.TrimSpace(line))
+	}
+
+	err = r.resolveOwnership(ctx, plan)
+	if err != nil {
+		diags.AddError("Failed to resolve the owner of the authorized_keys file", err.Error())
+		return
+	}
+
+	owner, group, err := r.provider.lookupOwnership(ctx, plan.Owner, plan.Group)
+	if err != nil {
+		diags.AddError("Failed to look up the owner of the authorized_keys file", err.Error())
+		return
+	}
+
+	if strings.Contains(filePath, "/") {
+		dir := filePath[:strings.LastIndex(filePath, "/")]
+
+		// sshd refuses keys in a directory writable by others, so a missing directory is created for the owner only
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(fmt.Sprintf("[ -d %[1]s ] || { mkdir -p -m 700 %[1]s && chown %[2]s %[1]s; }", clients.ShellQuote(dir), clients.ShellQuote(owner+":"+group))))
+		if err != nil {
+			diags.AddError("Failed to create authorized_keys directory", "Err="+err.Error()+"\nout = "+out)
+			return
+		}
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, filePath, plan.Mode.ValueString(), owner, group, joinLines(newLines))
+	if err != nil {
+		diags.AddError("Failed to write authorized_keys file", err.Error())
+		return
+	}
+}
+
+// resolveOwnership sets the owner and group that are not configured to those of the existing file, or of the
+// connecting user when the file does not exist.
+func (r *authorizedKeysResource) resolveOwnership(ctx context.Context, plan *authorizedKeysResourceModel) error {
+	if !plan.Owner.IsUnknown() && !plan.Group.IsUnknown() {
+		return nil
+	}
+
+	uid, gid, err := ownerOrConnectingUser(ctx, r.provider.machineAccessClient, plan.Path.ValueString())
+	if err != nil {
+		return err
+	}
+
+	if plan.Owner.IsUnknown() {
+		plan.Owner = types.StringValue(uid)
+	}
+
+	if plan.Group.IsUnknown() {
+		plan.Group = types.StringValue(gid)
+	}
+
+	return nil
+}
+
+// keys parses the entries of the set.
+func (r *authorizedKeysResource) keys(ctx context.Context, set types.Set) ([]authorizedKey, diag.Diagnostics) {
+	keys := []authorizedKey{}
+
+	if set.IsNull() || set.IsUnknown() {
+		return keys, nil
+	}
+
+	var lines []string
+
+	diags := set.ElementsAs(ctx, &lines, false)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	for _, line := range lines {
+		key, ok := parseAuthorizedKey(line)
+		if !ok {
+			diags.AddError("Invalid key", "The key must be in the format '[options] type data [comment]', got: "+line)
+			return nil, diags
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, diags
+}
+
+// containsAuthorizedKey returns whether the keys contain the same key as the entry.
+func containsAuthorizedKey(keys []authorizedKey, entry authorizedKey) bool {
+	for _, key := range keys {
+		if key.sameKey(entry) {
+			return true
+		}
+	}
+
+	return false
+}
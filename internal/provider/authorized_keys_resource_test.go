@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestAuthorizedKeysResource(t *testing.T) {
+	t.Run("Test exclusive management of authorized_keys", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sshClient.RunCommand(context.Background(), "mkdir -p /tmp/exclusive && echo 'ssh-rsa AAAAB3NzaC1yc2Eunmanaged intruder' > /tmp/exclusive/authorized_keys")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_authorized_keys" "test" {
+  path      = "/tmp/exclusive/authorized_keys"
+  exclusive = true
+  keys = [
+    "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5first admin",
+    "no-pty,from=\"10.0.0.0/8\" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5second backup",
+  ]
+}
+`,
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_authorized_keys.test", "keys.#", "2"),
+						resource.TestCheckResourceAttr("setup_authorized_keys.test", "mode", "600"),
+						func(_ *terraform.State) error {
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/exclusive/authorized_keys")
+							if err != nil {
+								return fmt.Errorf("authorized_keys file not found")
+							}
+
+							expected := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5first admin\nno-pty,from=\"10.0.0.0/8\" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5second backup\n"
+							if content != expected {
+								return fmt.Errorf("unexpected authorized_keys content: %s", content)
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func TestAuthorizedKeysResourceWithMock(t *testing.T) {
+	keys := types.SetValueMust(types.StringType, []attr.Value{types.StringValue("no-pty ssh-ed25519 AAAAC3Nza deploy")})
+
+	t.Run("create keeps the unmanaged keys when not exclusive", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/authorized_keys", "# team\nssh-rsa AAAAB3Nza other\nssh-ed25519 AAAAC3Nza old comment\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		// Act
+		state, diags := testResourceCreate(t, newAuthorizedKeysResource(newTestProvider(mock)), authorizedKeysResourceModel{
+			Path:      types.StringValue("/home/deploy/.ssh/authorized_keys"),
+			Keys:      keys,
+			Exclusive: types.BoolValue(false),
+			Mode:      types.StringValue("600"),
+			Owner:     types.StringUnknown(),
+			Group:     types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "# team\nssh-rsa AAAAB3Nza other\nno-pty ssh-ed25519 AAAAC3Nza deploy\n"
+		if mock.Files["/home/deploy/.ssh/authorized_keys"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/deploy/.ssh/authorized_keys"])
+		}
+
+		if state.Owner.ValueString() != "1001" || state.Group.ValueString() != "1001" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("create purges the unmanaged keys when exclusive", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/authorized_keys", "ssh-rsa AAAAB3Nza other\n", clients.FileInfo{Mode: "644", Owner: 1001, Group: 1001})
+
+		// Act
+		_, diags := testResourceCreate(t, newAuthorizedKeysResource(newTestProvider(mock)), authorizedKeysResourceModel{
+			Path:      types.StringValue("/home/deploy/.ssh/authorized_keys"),
+			Keys:      keys,
+			Exclusive: types.BoolValue(true),
+			Mode:      types.StringValue("600"),
+			Owner:     types.StringValue("1001"),
+			Group:     types.StringValue("1001"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/home/deploy/.ssh/authorized_keys"] != "no-pty ssh-ed25519 AAAAC3Nza deploy\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/deploy/.ssh/authorized_keys"])
+		}
+
+		if mock.FileInfos["/home/deploy/.ssh/authorized_keys"].Mode != "600" {
+			t.Fatalf("unexpected mode: %s", mock.FileInfos["/home/deploy/.ssh/authorized_keys"].Mode)
+		}
+	})
+
+	t.Run("create fails on an invalid key", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newAuthorizedKeysResource(newTestProvider(mock)), authorizedKeysResourceModel{
+			Path:      types.StringValue("/home/deploy/.ssh/authorized_keys"),
+			Keys:      types.SetValueMust(types.StringType, []attr.Value{types.StringValue("not a key")}),
+			Exclusive: types.BoolValue(true),
+			Mode:      types.StringValue("600"),
+			Owner:     types.StringValue("1001"),
+			Group:     types.StringValue("1001"),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("read reports the unmanaged keys when exclusive", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/authorized_keys", "no-pty ssh-ed25519 AAAAC3Nza deploy\nssh-rsa AAAAB3Nza added\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newAuthorizedKeysResource(newTestProvider(mock)), authorizedKeysResourceModel{
+			Path:      types.StringValue("/home/deploy/.ssh/authorized_keys"),
+			Keys:      keys,
+			Exclusive: types.BoolValue(true),
+			Mode:      types.StringValue("600"),
+			Owner:     types.StringValue("1001"),
+			Group:     types.StringValue("1001"),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if len(state.Keys.Elements()) != 2 {
+			t.Fatalf("unexpected keys: %v", state.Keys)
+		}
+	})
+
+	t.Run("delete removes only the managed keys", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/authorized_keys", "ssh-ed25519 AAAAC3Nza deploy\nssh-rsa AAAAB3Nza other\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		// Act
+		diags := testResourceDelete(t, newAuthorizedKeysResource(newTestProvider(mock)), authorizedKeysResourceModel{
+			Path:      types.StringValue("/home/deploy/.ssh/authorized_keys"),
+			Keys:      keys,
+			Exclusive: types.BoolValue(false),
+			Mode:      types.StringValue("600"),
+			Owner:     types.StringValue("1001"),
+			Group:     types.StringValue("1001"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/home/deploy/.ssh/authorized_keys"] != "ssh-rsa AAAAB3Nza other\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/deploy/.ssh/authorized_keys"])
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestParseAuthorizedKey(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected authorizedKey
+		ok       bool
+	}{
+		{
+			line:     "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGbA8VjAq",
+			expected: authorizedKey{Type: "ssh-ed25519", Data: "AAAAC3NzaC1lZDI1NTE5AAAAIGbA8VjAq"},
+			ok:       true,
+		},
+		{
+			line:     "  ssh-rsa AAAAB3NzaC1yc2E user@host with spaces ",
+			expected: authorizedKey{Type: "ssh-rsa", Data: "AAAAB3NzaC1yc2E", Comment: "user@host with spaces"},
+			ok:       true,
+		},
+		{
+			line: `no-port-forwarding,command="echo \"hello world\"" ecdsa-sha2-nistp256 AAAAE2VjZHNh backup`,
+			expected: authorizedKey{
+				Options: `no-port-forwarding,command="echo \"hello world\""`,
+				Type:    "ecdsa-sha2-nistp256",
+				Data:    "AAAAE2VjZHNh",
+				Comment: "backup",
+			},
+			ok: true,
+		},
+		{line: "# ssh-rsa AAAAB3NzaC1yc2E", ok: false},
+		{line: "", ok: false},
+		{line: "not a key", ok: false},
+		{line: "ssh-rsa", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			// Act
+			key, ok := parseAuthorizedKey(test.line)
+
+			// Assert
+			if ok != test.ok || key != test.expected {
+				t.Fatalf("unexpected result: ok=%v, key=%+v", ok, key)
+			}
+		})
+	}
+}
+
+func TestAuthorizedKeyString(t *testing.T) {
+	// Arrange
+	key := authorizedKey{Options: `from="10.0.0.0/8"`, Type: "ssh-ed25519", Data: "AAAAC3Nza", Comment: "deploy key"}
+
+	// Act
+	line := key.String()
+
+	// Assert
+	if line != `from="10.0.0.0/8" ssh-ed25519 AAAAC3Nza deploy key` {
+		t.Fatalf("unexpected line: %s", line)
+	}
+
+	parsed, ok := parseAuthorizedKey(line)
+	if !ok || parsed != key {
+		t.Fatalf("unexpected parsed key: ok=%v, key=%+v", ok, parsed)
+	}
+}
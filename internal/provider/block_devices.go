@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+)
+
+// blockDeviceSignature returns the properties of the filesystem, RAID member, LVM physical volume or partition table
+// found on the device by probing it directly, e.g. TYPE=ext4 and LABEL=data. The properties are empty when the
+// device holds no known signature.
+func blockDeviceSignature(ctx context.Context, p *internalProvider, device string) (map[string]string, error) {
+	result, err := p.machineAccessClient.Run(ctx, p.sudo(clients.ShellCommand("blkid", "-p", "-o", "export", device)))
+
+	// blkid exits with 2 when it finds nothing on the device
+	if clients.IsExitCode(err, 2) {
+		return map[string]string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s. Err=%w\nout = %s", device, err, result.Stdout+result.Stderr)
+	}
+
+	return parseExportedProperties(result.Stdout), nil
+}
+
+// requireBlankDevices returns an error when one of the devices holds a signature, so that resources never format
+// or add to an array a device whose data could still be needed.
+func requireBlankDevices(ctx context.Context, p *internalProvider, devices []string) error {
+	for _, device := range devices {
+		signature, err := blockDeviceSignature(ctx, p, device)
+		if err != nil {
+			return err
+		}
+
+		if err := requireBlankSignature(device, signature); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// requireBlankSignature returns an error when the signature of the device, as returned by blockDeviceSignature, is
+// not empty.
+func requireBlankSignature(device string, signature map[string]string) error {
+	kind := signature["TYPE"]
+	if kind == "" {
+		kind = signature["PTTYPE"]
+	}
+
+	if kind != "" {
+		return fmt.Errorf("refusing to overwrite %s which holds data (%s). If its data is not needed anymore, "+
+			"wipe it first with `wipefs -a %s`", device, kind, device)
+	}
+
+	return nil
+}
+
+// parseExportedProperties parses the KEY=VALUE lines printed by the --export options of blkid and mdadm.
+func parseExportedProperties(out string) map[string]string {
+	properties := map[string]string{}
+
+	for line := range strings.SplitSeq(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		// blkid escapes the spaces and special characters of the values with backslashes
+		properties[key] = strings.ReplaceAll(value, `\`, "")
+	}
+
+	return properties
+}
@@ -0,0 +1,239 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &blockDevicesDataSource{}
+)
+
+// lsblkCommand lists the block devices as a flat JSON list, with full paths and sizes in bytes.
+const lsblkCommand = "lsblk --json --bytes --paths --list -o NAME,TYPE,SIZE,SERIAL,WWN,MODEL,FSTYPE,LABEL,UUID,MOUNTPOINT,PKNAME"
+
+func newBlockDevicesDataSource(p *internalProvider) datasource.DataSource {
+	return &blockDevicesDataSource{
+		provider: p,
+	}
+}
+
+type blockDevicesDataSource struct {
+	provider *internalProvider
+}
+
+type blockDevicesDataSourceModel struct {
+	Devices  []blockDeviceModel `tfsdk:"devices"`
+	BySerial types.Map          `tfsdk:"by_serial"`
+	ByWWN    types.Map          `tfsdk:"by_wwn"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+type blockDeviceModel struct {
+	Path       types.String `tfsdk:"path"`
+	Type       types.String `tfsdk:"type"`
+	Size       types.Int64  `tfsdk:"size"`
+	Serial     types.String `tfsdk:"serial"`
+	WWN        types.String `tfsdk:"wwn"`
+	Model      types.String `tfsdk:"model"`
+	FSType     types.String `tfsdk:"fs_type"`
+	Label      types.String `tfsdk:"label"`
+	UUID       types.String `tfsdk:"uuid"`
+	Mountpoint types.String `tfsdk:"mountpoint"`
+	Parent     types.String `tfsdk:"parent"`
+}
+
+// lsblkDevice is a device printed by lsblkCommand. Older versions of lsblk print the sizes as strings.
+type lsblkDevice struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Size       json.RawMessage `json:"size"`
+	Serial     *string         `json:"serial"`
+	WWN        *string         `json:"wwn"`
+	Model      *string         `json:"model"`
+	FSType     *string         `json:"fstype"`
+	Label      *string         `json:"label"`
+	UUID       *string         `json:"uuid"`
+	Mountpoint *string         `json:"mountpoint"`
+	Parent     *string         `json:"pkname"`
+}
+
+func (d *blockDevicesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_block_devices"
+}
+
+func (d *blockDevicesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Lists the disks, partitions, RAID arrays and logical volumes of the remote system with lsblk. " +
+			"Use by_serial or by_wwn to reference disks by their hardware identity in storage resources, e.g. " +
+			"setup_partition and setup_raid, rather than by /dev names which can change between boots",
+
+		Attributes: map[string]schema.Attribute{
+			"devices": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The block devices, in the order of lsblk",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"path": schema.StringAttribute{
+							Computed:    true,
+							Description: "The path of the device, e.g. '/dev/sda1'",
+						},
+						"type": schema.StringAttribute{
+							Computed:    true,
+							Description: "The type of the device, e.g. 'disk', 'part', 'raid1' or 'lvm'",
+						},
+						"size": schema.Int64Attribute{
+							Computed:    true,
+							Description: "The size of the device in bytes",
+						},
+						"serial": schema.StringAttribute{
+							Computed:    true,
+							Description: "The serial number of the disk, null when unknown",
+						},
+						"wwn": schema.StringAttribute{
+							Computed:    true,
+							Description: "The World Wide Name of the disk, null when unknown",
+						},
+						"model": schema.StringAttribute{
+							Computed:    true,
+							Description: "The model of the disk, null when unknown",
+						},
+						"fs_type": schema.StringAttribute{
+							Computed:    true,
+							Description: "The type of the filesystem or signature on the device, e.g. 'ext4' or 'LVM2_member', null when blank",
+						},
+						"label": schema.StringAttribute{
+							Computed:    true,
+							Description: "The label of the filesystem, null when none",
+						},
+						"uuid": schema.StringAttribute{
+							Computed:    true,
+							Description: "The UUID of the filesystem, null when none",
+						},
+						"mountpoint": schema.StringAttribute{
+							Computed:    true,
+							Description: "Where the device is mounted, null when it is not",
+						},
+						"parent": schema.StringAttribute{
+							Computed:    true,
+							Description: "The path of the parent device, e.g. the disk of a partition, null for disks",
+						},
+					},
+				},
+			},
+			"by_serial": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The paths of the disks by serial number",
+			},
+			"by_wwn": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The paths of the disks by World Wide Name",
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *blockDevicesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	result, err := d.provider.machineAccessClient.Run(ctx, lsblkCommand)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list the block devices", fmt.Sprintf("Err=%s\nout = %s", err, result.Stdout+result.Stderr))
+		return
+	}
+
+	var out struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+
+	if err := json.Unmarshal([]byte(result.Stdout), &out); err != nil {
+		resp.Diagnostics.AddError("Failed to parse the output of lsblk", err.Error())
+		return
+	}
+
+	model := blockDevicesDataSourceModel{Devices: []blockDeviceModel{}}
+	bySerial, byWWN := map[string]string{}, map[string]string{}
+
+	for _, device := range out.BlockDevices {
+		size, err := strconv.ParseInt(strings.Trim(string(device.Size), `"`), 10, 64)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to parse the size of "+device.Name, err.Error())
+			return
+		}
+
+		model.Devices = append(model.Devices, blockDeviceModel{
+			Path:       types.StringValue(device.Name),
+			Type:       types.StringValue(device.Type),
+			Size:       types.Int64Value(size),
+			Serial:     lsblkValue(device.Serial),
+			WWN:        lsblkValue(device.WWN),
+			Model:      lsblkValue(device.Model),
+			FSType:     lsblkValue(device.FSType),
+			Label:      lsblkValue(device.Label),
+			UUID:       lsblkValue(device.UUID),
+			Mountpoint: lsblkValue(device.Mountpoint),
+			Parent:     lsblkValue(device.Parent),
+		})
+
+		// Partitions inherit the identifiers of their disk, only the disks are indexed
+		if device.Type != "disk" {
+			continue
+		}
+
+		if serial := lsblkValue(device.Serial); !serial.IsNull() {
+			bySerial[serial.ValueString()] = device.Name
+		}
+
+		if wwn := lsblkValue(device.WWN); !wwn.IsNull() {
+			byWWN[wwn.ValueString()] = device.Name
+		}
+	}
+
+	bySerialValue, diags := types.MapValueFrom(ctx, types.StringType, bySerial)
+	resp.Diagnostics.Append(diags...)
+
+	byWWNValue, diags := types.MapValueFrom(ctx, types.StringType, byWWN)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	model.BySerial = bySerialValue
+	model.ByWWN = byWWNValue
+
+	// The data source is built from the output, the target host is the one of the configuration
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("target_host"), &model.TargetHost)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// lsblkValue returns the trimmed value printed by lsblk, null when it is missing or blank.
+func lsblkValue(value *string) types.String {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return types.StringNull()
+	}
+
+	return types.StringValue(strings.TrimSpace(*value))
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testLsblkOutput = `{
+   "blockdevices": [
+      {"name":"/dev/sda", "type":"disk", "size":512110190592, "serial":"S4EVNX0R123456 ", "wwn":"0x5002538e40a1b2c3", "model":"Samsung SSD 860", "fstype":null, "label":null, "uuid":null, "mountpoint":null, "pkname":null},
+      {"name":"/dev/sda1", "type":"part", "size":"536870912", "serial":null, "wwn":"0x5002538e40a1b2c3", "model":null, "fstype":"vfat", "label":null, "uuid":"6C1A-2B3D", "mountpoint":"/boot/efi", "pkname":"/dev/sda"},
+      {"name":"/dev/vdb", "type":"disk", "size":10737418240, "serial":"", "wwn":null, "model":null, "fstype":null, "label":null, "uuid":null, "mountpoint":null, "pkname":null}
+   ]
+}`
+
+func TestBlockDevicesDataSourceWithMock(t *testing.T) {
+	t.Run("read lists the devices and indexes the disks", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(lsblkCommand, clients.MockResponse{Stdout: testLsblkOutput})
+
+		config := blockDevicesDataSourceModel{BySerial: types.MapNull(types.StringType), ByWWN: types.MapNull(types.StringType)}
+
+		// Act
+		state, diags := testDataSourceRead(t, newBlockDevicesDataSource(newTestProvider(mock)), config)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(state.Devices) != 3 {
+			t.Fatalf("unexpected devices: %v", state.Devices)
+		}
+
+		partition := state.Devices[1]
+		if partition.Size.ValueInt64() != 536870912 || partition.Mountpoint.ValueString() != "/boot/efi" || partition.Parent.ValueString() != "/dev/sda" {
+			t.Fatalf("unexpected partition: %+v", partition)
+		}
+
+		if !state.Devices[2].Serial.IsNull() {
+			t.Fatalf("expected a blank serial to be null: %s", state.Devices[2].Serial)
+		}
+
+		if state.BySerial.String() != `{"S4EVNX0R123456":"/dev/sda"}` {
+			t.Fatalf("unexpected by_serial: %s", state.BySerial)
+		}
+
+		if state.ByWWN.String() != `{"0x5002538e40a1b2c3":"/dev/sda"}` {
+			t.Fatalf("unexpected by_wwn: %s", state.ByWWN)
+		}
+	})
+
+	t.Run("read keeps the target host of the configuration", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(lsblkCommand, clients.MockResponse{Stdout: testLsblkOutput})
+
+		config := blockDevicesDataSourceModel{BySerial: types.MapNull(types.StringType), ByWWN: types.MapNull(types.StringType), TargetHost: types.StringValue("web1")}
+
+		// Act
+		state, diags := testDataSourceRead(t, newBlockDevicesDataSource(newTestProvider(mock)), config)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.TargetHost.ValueString() != "web1" {
+			t.Fatalf("unexpected target host: %s", state.TargetHost)
+		}
+	})
+
+	t.Run("read fails when lsblk fails", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(lsblkCommand, clients.MockResponse{ExitCode: 127, Stderr: "lsblk: not found"})
+
+		config := blockDevicesDataSourceModel{BySerial: types.MapNull(types.StringType), ByWWN: types.MapNull(types.StringType)}
+
+		// Act
+		_, diags := testDataSourceRead(t, newBlockDevicesDataSource(newTestProvider(mock)), config)
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,319 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const blockMarkPlaceholder = "{mark}"
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &blockInFileResource{}
+var _ resource.ResourceWithModifyPlan = &blockInFileResource{}
+
+func newBlockInFileResource(p *internalProvider) resource.Resource {
+	return &blockInFileResource{
+		provider: p,
+	}
+}
+
+// blockInFileResource defines the resource implementation.
+type blockInFileResource struct {
+	provider *internalProvider
+}
+
+type blockInFileResourceModel struct {
+	Path         types.String `tfsdk:"path"`
+	Block        types.String `tfsdk:"block"`
+	Marker       types.String `tfsdk:"marker"`
+	InsertAfter  types.String `tfsdk:"insert_after"`
+	InsertBefore types.String `tfsdk:"insert_before"`
+	Create       types.Bool   `tfsdk:"create"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *blockInFileResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_blockinfile"
+}
+
+func (r *blockInFileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Block in file resource that maintains a block of lines between BEGIN and END marker lines, " +
+			"like the blockinfile module of Ansible. The whole block is replaced when it changes, and removed when the " +
+			"resource is destroyed",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the file",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"block": schema.StringAttribute{
+				Required:    true,
+				Description: "The lines of the block, without the markers",
+			},
+			"marker": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("# {mark} TERRAFORM MANAGED BLOCK"),
+				Description: "The marker lines, where {mark} is replaced by BEGIN and END. Must be unique in the file for each block. Defaults to '# {mark} TERRAFORM MANAGED BLOCK'",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"insert_after": schema.StringAttribute{
+				Optional: true,
+				Description: "Where a missing block is inserted: after the last line matching this regular expression, or at " +
+					"the end of the file with EOF. Falls back to the end of the file when nothing matches. Defaults to EOF",
+			},
+			"insert_before": schema.StringAttribute{
+				Optional: true,
+				Description: "Where a missing block is inserted: before the first line matching this regular expression, or " +
+					"at the beginning of the file with BOF. Falls back to the end of the file when nothing matches. Conflicts with insert_after",
+			},
+			"create": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether a missing file is created, with mode 644 and owned by the connecting user. Defaults to false",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *blockInFileResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *blockInFileResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan blockInFileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Marker.IsUnknown() || plan.InsertAfter.IsUnknown() || plan.InsertBefore.IsUnknown() {
+		return
+	}
+
+	err := plan.validate()
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid blockinfile configuration", err.Error())
+	}
+}
+
+func (r *blockInFileResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan blockInFileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *blockInFileResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model blockInFileResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read file", err.Error())
+		return
+	}
+
+	begin, end := model.markers()
+
+	start, stop, found := findMarkedBlock(lines, begin, end)
+	if !found {
+		// The block, or the file, was removed outside of terraform, it must be added again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// A trailing newline of the configured block is not part of its lines, so it is not a change
+	actual := strings.Join(lines[start+1:stop], "\n")
+	if actual != strings.TrimSuffix(model.Block.ValueString(), "\n") {
+		model.Block = types.StringValue(actual + "\n")
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *blockInFileResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan blockInFileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *blockInFileResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model blockInFileResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	filePath := model.Path.ValueString()
+
+	defer r.provider.lockFile(ctx, filePath)()
+
+	info, err := r.provider.machineAccessClient.Stat(ctx, filePath, true)
+	if clients.IsFileNotFound(err) {
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stat file", err.Error())
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read file", err.Error())
+		return
+	}
+
+	begin, end := model.markers()
+
+	start, stop, found := findMarkedBlock(lines, begin, end)
+	if !found {
+		return
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, filePath, info.Mode, strconv.FormatInt(info.Owner, 10), strconv.FormatInt(info.Group, 10), joinLines(append(lines[:start], lines[stop+1:]...)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to write file", err.Error())
+		return
+	}
+}
+
+// apply replaces the block in the file, or inserts it when the file does not have it yet.
+func (r *blockInFileResource) apply(ctx context.Context, plan blockInFileResourceModel, diags *diag.Diagnostics) {
+	err := plan.validate()
+	if err != nil {
+		diags.AddError("Invalid blockinfile configuration", err.Error())
+		return
+	}
+
+	begin, end := plan.markers()
+
+	block := []string{begin}
+	if content := strings.TrimSuffix(plan.Block.ValueString(), "\n"); content != "" {
+		block = append(block, strings.Split(content, "\n")...)
+	}
+
+	block = append(block, end)
+
+	err = r.provider.editFile(ctx, plan.Path.ValueString(), plan.Create.ValueBool(), func(lines []string) ([]string, bool) {
+		start, stop, found := findMarkedBlock(lines, begin, end)
+		if found {
+			return append(append(append([]string{}, lines[:start]...), block...), lines[stop+1:]...), true
+		}
+
+		index := insertionIndex(lines, plan.InsertAfter.ValueString(), plan.InsertBefore.ValueString())
+
+		return append(append(append([]string{}, lines[:index]...), block...), lines[index:]...), true
+	})
+	if err != nil {
+		diags.AddError("Failed to write file", err.Error())
+		return
+	}
+}
+
+// validate checks the marker and the insertion anchors.
+func (model blockInFileResourceModel) validate() error {
+	if !strings.Contains(model.Marker.ValueString(), blockMarkPlaceholder) {
+		return errors.New("the marker must contain " + blockMarkPlaceholder + ", got: " + model.Marker.ValueString())
+	}
+
+	return validateInsertionAnchors(model.InsertAfter.ValueString(), model.InsertBefore.ValueString())
+}
+
+// markers returns the begin and end marker lines of the block.
+func (model blockInFileResourceModel) markers() (string, string) {
+	marker := model.Marker.ValueString()
+
+	return strings.ReplaceAll(marker, blockMarkPlaceholder, "BEGIN"), strings.ReplaceAll(marker, blockMarkPlaceholder, "END")
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestBlockInFileResource(t *testing.T) {
+	t.Run("Test add then replace a block", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkContent := func(expected string) resource.TestCheckFunc {
+			return func(_ *terraform.State) error {
+				content, err := sshClient.RunCommand(context.Background(), "cat /tmp/blockinfile.conf")
+				if err != nil {
+					return fmt.Errorf("file not found")
+				}
+
+				if content != expected {
+					return fmt.Errorf("unexpected content: %s", content)
+				}
+
+				return nil
+			}
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_blockinfile" "test" {
+  path   = "/tmp/blockinfile.conf"
+  create = true
+  block  = <<EOT
+upstream app {
+    server 10.0.0.1;
+}
+EOT
+}
+`,
+					Check: checkContent("# BEGIN TERRAFORM MANAGED BLOCK\nupstream app {\n    server 10.0.0.1;\n}\n# END TERRAFORM MANAGED BLOCK\n"),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_blockinfile" "test" {
+  path   = "/tmp/blockinfile.conf"
+  create = true
+  block  = "upstream app {}\n"
+}
+`,
+					Check: checkContent("# BEGIN TERRAFORM MANAGED BLOCK\nupstream app {}\n# END TERRAFORM MANAGED BLOCK\n"),
+				},
+			},
+		})
+	})
+}
+
+func TestBlockInFileResourceWithMock(t *testing.T) {
+	t.Run("create inserts the block before the anchor", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/fstab", "/dev/sda1 / ext4\n# swap\n/swapfile none swap\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newBlockInFileResource(newTestProvider(mock)), blockInFileResourceModel{
+			Path:         types.StringValue("/etc/fstab"),
+			Block:        types.StringValue("/dev/sdb1 /data ext4\n/dev/sdb2 /logs ext4\n"),
+			Marker:       types.StringValue("# {mark} data disks"),
+			InsertAfter:  types.StringNull(),
+			InsertBefore: types.StringValue("^# swap"),
+			Create:       types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "/dev/sda1 / ext4\n# BEGIN data disks\n/dev/sdb1 /data ext4\n/dev/sdb2 /logs ext4\n# END data disks\n# swap\n/swapfile none swap\n"
+		if mock.Files["/etc/fstab"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/fstab"])
+		}
+	})
+
+	t.Run("create replaces the existing block", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/hosts", "127.0.0.1 localhost\n# BEGIN TERRAFORM MANAGED BLOCK\n10.0.0.1 old\n10.0.0.2 older\n# END TERRAFORM MANAGED BLOCK\n::1 localhost\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newBlockInFileResource(newTestProvider(mock)), blockInFileResourceModel{
+			Path:         types.StringValue("/etc/hosts"),
+			Block:        types.StringValue("10.0.0.3 db"),
+			Marker:       types.StringValue("# {mark} TERRAFORM MANAGED BLOCK"),
+			InsertAfter:  types.StringNull(),
+			InsertBefore: types.StringNull(),
+			Create:       types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "127.0.0.1 localhost\n# BEGIN TERRAFORM MANAGED BLOCK\n10.0.0.3 db\n# END TERRAFORM MANAGED BLOCK\n::1 localhost\n"
+		if mock.Files["/etc/hosts"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/hosts"])
+		}
+	})
+
+	t.Run("create fails on a missing file without create", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newBlockInFileResource(newTestProvider(mock)), blockInFileResourceModel{
+			Path:         types.StringValue("/etc/missing"),
+			Block:        types.StringValue("key=value"),
+			Marker:       types.StringValue("# {mark} TERRAFORM MANAGED BLOCK"),
+			InsertAfter:  types.StringNull(),
+			InsertBefore: types.StringNull(),
+			Create:       types.BoolValue(false),
+		})
+
+		// Assert
+		if !diags.HasError() || len(mock.Files) != 0 {
+			t.Fatalf("expected an error without any file written, got: %v, %v", diags, mock.Files)
+		}
+	})
+
+	t.Run("read detects a changed block", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/hosts", "# BEGIN TERRAFORM MANAGED BLOCK\n10.0.0.9 changed\n# END TERRAFORM MANAGED BLOCK\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newBlockInFileResource(newTestProvider(mock)), blockInFileResourceModel{
+			Path:         types.StringValue("/etc/hosts"),
+			Block:        types.StringValue("10.0.0.3 db\n"),
+			Marker:       types.StringValue("# {mark} TERRAFORM MANAGED BLOCK"),
+			InsertAfter:  types.StringNull(),
+			InsertBefore: types.StringNull(),
+			Create:       types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Block.ValueString() != "10.0.0.9 changed\n" {
+			t.Fatalf("unexpected block: %q", state.Block.ValueString())
+		}
+	})
+
+	t.Run("delete removes only the block", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/hosts", "127.0.0.1 localhost\n# BEGIN TERRAFORM MANAGED BLOCK\n10.0.0.3 db\n# END TERRAFORM MANAGED BLOCK\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		diags := testResourceDelete(t, newBlockInFileResource(newTestProvider(mock)), blockInFileResourceModel{
+			Path:         types.StringValue("/etc/hosts"),
+			Block:        types.StringValue("10.0.0.3 db\n"),
+			Marker:       types.StringValue("# {mark} TERRAFORM MANAGED BLOCK"),
+			InsertAfter:  types.StringNull(),
+			InsertBefore: types.StringNull(),
+			Create:       types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/hosts"] != "127.0.0.1 localhost\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/hosts"])
+		}
+	})
+}
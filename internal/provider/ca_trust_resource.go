@@ -0,0 +1,275 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	debianCATrustDirectory = "/usr/local/share/ca-certificates"
+	rhelCATrustDirectory   = "/etc/pki/ca-trust/source/anchors"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &caTrustResource{}
+var _ resource.ResourceWithModifyPlan = &caTrustResource{}
+
+var caTrustNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func newCATrustResource(p *internalProvider) resource.Resource {
+	return &caTrustResource{
+		provider: p,
+	}
+}
+
+// caTrustResource defines the resource implementation.
+type caTrustResource struct {
+	provider *internalProvider
+}
+
+type caTrustResourceModel struct {
+	Name        types.String `tfsdk:"name"`
+	Certificate types.String `tfsdk:"certificate"`
+	Path        types.String `tfsdk:"path"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *caTrustResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_ca_trust"
+}
+
+func (r *caTrustResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "CA trust resource that adds a CA certificate to the system trust store, with " +
+			"update-ca-certificates on Debian based systems or update-ca-trust on RHEL based systems. " +
+			"Destroying the resource removes the certificate from the trust store",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the certificate file in the trust store, without extension, e.g. internal-root-ca",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"certificate": schema.StringAttribute{
+				Required:    true,
+				Description: "The PEM encoded CA certificate",
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the certificate file in the trust store",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *caTrustResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *caTrustResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan caTrustResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !plan.Name.IsUnknown() && !caTrustNamePattern.MatchString(plan.Name.ValueString()) {
+		resp.Diagnostics.AddAttributeError(path.Root("name"), "Invalid name", "The name must only contain letters, digits, dots, dashes and underscores, got: "+plan.Name.ValueString())
+	}
+
+	if !plan.Certificate.IsUnknown() {
+		err := validatePEMCertificates(plan.Certificate.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("certificate"), "Invalid certificate", err.Error())
+		}
+	}
+}
+
+func (r *caTrustResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan caTrustResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	directory, err := r.trustDirectory(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to find the trust store", err.Error())
+		return
+	}
+
+	plan.Path = types.StringValue(directory + "/" + plan.Name.ValueString() + ".crt")
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to add the CA certificate", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *caTrustResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model caTrustResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	certificate, err := r.provider.machineAccessClient.ReadFile(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The certificate was removed outside of terraform, it must be added again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read CA certificate", err.Error())
+		return
+	}
+
+	model.Certificate = types.StringValue(certificate)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *caTrustResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan caTrustResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update the CA certificate", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *caTrustResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model caTrustResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	command := "rm -f " + clients.ShellQuote(model.Path.ValueString()) + " && " + caTrustUpdateCommand(model.Path.ValueString())
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the CA certificate", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+// install writes the certificate to the trust store and updates it.
+func (r *caTrustResource) install(ctx context.Context, model caTrustResourceModel) error {
+	err := validatePEMCertificates(model.Certificate.ValueString())
+	if err != nil {
+		return err
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, model.Path.ValueString(), "644", "0", "0", model.Certificate.ValueString())
+	if err != nil {
+		return err
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(caTrustUpdateCommand(model.Path.ValueString())))
+	if err != nil {
+		return fmt.Errorf("failed to update the trust store: %w, out = %s", err, out)
+	}
+
+	return nil
+}
+
+// trustDirectory returns the directory of the trust store of the host, depending on the tool that updates it.
+func (r *caTrustResource) trustDirectory(ctx context.Context) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "if command -v update-ca-certificates >/dev/null 2>&1; then echo debian; elif command -v update-ca-trust >/dev/null 2>&1; then echo rhel; fi")
+	if err != nil {
+		return "", fmt.Errorf("%w, out = %s", err, out)
+	}
+
+	switch strings.TrimSpace(out) {
+	case "debian":
+		return debianCATrustDirectory, nil
+	case "rhel":
+		return rhelCATrustDirectory, nil
+	default:
+		return "", errors.New("neither update-ca-certificates nor update-ca-trust is installed, install the ca-certificates package")
+	}
+}
+
+// caTrustUpdateCommand returns the command that updates the trust store containing the certificate file.
+func caTrustUpdateCommand(certificatePath string) string {
+	if strings.HasPrefix(certificatePath, rhelCATrustDirectory+"/") {
+		return "update-ca-trust extract"
+	}
+
+	return "update-ca-certificates"
+}
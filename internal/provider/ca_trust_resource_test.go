@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestCATrustResource(t *testing.T) {
+	t.Run("Test add a CA certificate to the trust store", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+		certificate, _ := testSelfSignedCertificate(t, time.Now().Add(24*time.Hour))
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sshClient.RunCommand(context.Background(), "sudo apt-get install -y ca-certificates")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + fmt.Sprintf(`
+resource "setup_ca_trust" "test" {
+  name        = "internal-root-ca"
+  certificate = <<EOT
+%sEOT
+}
+`, certificate),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_ca_trust.test", "path", "/usr/local/share/ca-certificates/internal-root-ca.crt"),
+						func(_ *terraform.State) error {
+							_, err := sshClient.RunCommand(context.Background(), "grep -q \"$(sed -n 2p /usr/local/share/ca-certificates/internal-root-ca.crt)\" /etc/ssl/certs/ca-certificates.crt")
+							if err != nil {
+								return fmt.Errorf("the certificate is not in the trust store")
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func TestCATrustResourceWithMock(t *testing.T) {
+	certificate, _ := testSelfSignedCertificate(t, time.Now().Add(24*time.Hour))
+
+	t.Run("create uses update-ca-certificates on Debian", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^if command -v update-ca-certificates", clients.MockResponse{Stdout: "debian\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newCATrustResource(newTestProvider(mock)), caTrustResourceModel{
+			Name:        types.StringValue("internal-root-ca"),
+			Certificate: types.StringValue(certificate),
+			Path:        types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Path.ValueString() != "/usr/local/share/ca-certificates/internal-root-ca.crt" {
+			t.Fatalf("unexpected path: %s", state.Path)
+		}
+
+		if mock.Files[state.Path.ValueString()] != certificate {
+			t.Fatalf("unexpected files: %v", mock.Files)
+		}
+
+		if mock.Commands[len(mock.Commands)-1] != "update-ca-certificates" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create uses update-ca-trust on RHEL", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^if command -v update-ca-certificates", clients.MockResponse{Stdout: "rhel\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newCATrustResource(newTestProvider(mock)), caTrustResourceModel{
+			Name:        types.StringValue("internal-root-ca"),
+			Certificate: types.StringValue(certificate),
+			Path:        types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Path.ValueString() != "/etc/pki/ca-trust/source/anchors/internal-root-ca.crt" {
+			t.Fatalf("unexpected path: %s", state.Path)
+		}
+
+		if mock.Commands[len(mock.Commands)-1] != "update-ca-trust extract" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create fails without a trust store", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newCATrustResource(newTestProvider(mock)), caTrustResourceModel{
+			Name:        types.StringValue("internal-root-ca"),
+			Certificate: types.StringValue(certificate),
+			Path:        types.StringUnknown(),
+		})
+
+		// Assert
+		if !diags.HasError() || len(mock.Files) != 0 {
+			t.Fatalf("expected an error without any file written, got: %v, %v", diags, mock.Files)
+		}
+	})
+
+	t.Run("delete removes the certificate and updates the trust store", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newCATrustResource(newTestProvider(mock)), caTrustResourceModel{
+			Name:        types.StringValue("internal-root-ca"),
+			Certificate: types.StringValue(certificate),
+			Path:        types.StringValue("/etc/pki/ca-trust/source/anchors/internal-root-ca.crt"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 || !strings.HasSuffix(mock.Commands[0], "&& update-ca-trust extract") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// capabilitiesProbe holds the capabilities of a host once they are probed.
+type capabilitiesProbe struct {
+	mutex        sync.Mutex
+	probed       bool
+	capabilities clients.Capabilities
+}
+
+// capabilities returns the capabilities of the Linux host of the context, probed on first use, so that the commands
+// adapt to minimal images, e.g. Alpine with BusyBox. The capabilities of Debian and Ubuntu are assumed when the probe
+// fails, which is not kept, so that the next caller probes the host again, e.g. after a transient connection failure.
+func (p *internalProvider) capabilities(ctx context.Context) clients.Capabilities {
+	p.hostCapabilitiesMutex.Lock()
+
+	if p.hostCapabilities == nil {
+		p.hostCapabilities = map[string]*capabilitiesProbe{}
+	}
+
+	probe, ok := p.hostCapabilities[clients.HostFrom(ctx)]
+	if !ok {
+		probe = &capabilitiesProbe{}
+		p.hostCapabilities[clients.HostFrom(ctx)] = probe
+	}
+
+	p.hostCapabilitiesMutex.Unlock()
+
+	// The concurrent callers wait for the probe rather than probing the host too
+	probe.mutex.Lock()
+	defer probe.mutex.Unlock()
+
+	if probe.probed {
+		return probe.capabilities
+	}
+
+	// The probe only reads, it also runs during the changes of a dry run
+	out, err := p.machineAccessClient.RunCommand(clients.WithoutMutation(ctx), clients.CapabilitiesProbe)
+	if err != nil {
+		tflog.Warn(ctx, fmt.Sprintf("Failed to probe the capabilities of the host, assuming bash and GNU coreutils. Err=%s\nout = %s", err, out))
+
+		return clients.DefaultCapabilities
+	}
+
+	probe.capabilities = clients.ParseCapabilities(out)
+	probe.probed = true
+
+	tflog.Debug(ctx, fmt.Sprintf("Capabilities of the host: %+v", probe.capabilities))
+
+	return probe.capabilities
+}
+
+// shadowUtilsRequired is the error of the operations that need a command of the shadow utilities, which BusyBox has
+// no equivalent applet for.
+func shadowUtilsRequired(command string) error {
+	return fmt.Errorf("%s is not installed on the host, install the shadow utilities, e.g. the shadow package on Alpine", command)
+}
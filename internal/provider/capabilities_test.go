@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+)
+
+func TestProviderCapabilities(t *testing.T) {
+	t.Run("probes the capabilities of each host once", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(clients.CapabilitiesProbe, clients.MockResponse{Stdout: "busybox\n"})
+
+		p := &internalProvider{machineAccessClient: mock}
+
+		// Act
+		first := p.capabilities(context.Background())
+		second := p.capabilities(context.Background())
+		other := p.capabilities(clients.WithHost(context.Background(), "worker"))
+
+		// Assert
+		if first != (clients.Capabilities{BusyBox: true}) || second != first || other != first {
+			t.Fatalf("unexpected capabilities: %+v, %+v, %+v", first, second, other)
+		}
+
+		if len(mock.Commands) != 2 {
+			t.Fatalf("expected a probe per host, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("assumes the default capabilities when the probe fails", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(clients.CapabilitiesProbe, clients.MockResponse{Err: errors.New("connection reset")})
+
+		p := &internalProvider{machineAccessClient: mock}
+
+		// Act
+		capabilities := p.capabilities(context.Background())
+
+		// Assert
+		if capabilities != clients.DefaultCapabilities {
+			t.Fatalf("unexpected capabilities: %+v", capabilities)
+		}
+	})
+
+	t.Run("probes the host again after a failed probe", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(clients.CapabilitiesProbe, clients.MockResponse{Err: errors.New("connection reset"), Once: true}).
+			On(clients.CapabilitiesProbe, clients.MockResponse{Stdout: "busybox\n"})
+
+		p := &internalProvider{machineAccessClient: mock}
+
+		// Act
+		failed := p.capabilities(context.Background())
+		probed := p.capabilities(context.Background())
+		cached := p.capabilities(context.Background())
+
+		// Assert
+		if failed != clients.DefaultCapabilities || probed != (clients.Capabilities{BusyBox: true}) || cached != probed {
+			t.Fatalf("unexpected capabilities: %+v, %+v, %+v", failed, probed, cached)
+		}
+
+		if len(mock.Commands) != 2 {
+			t.Fatalf("expected the failed probe to be run again once, got: %v", mock.Commands)
+		}
+	})
+}
@@ -0,0 +1,394 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	tfpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &certbotResource{}
+var _ resource.ResourceWithModifyPlan = &certbotResource{}
+
+// certbotLiveDirectory is the directory where certbot links the current files of each certificate.
+const certbotLiveDirectory = "/etc/letsencrypt/live"
+
+// certbotInstallCommand installs certbot from the distribution packages unless it is already installed. The package
+// also installs the systemd timer renewing the certificates.
+const certbotInstallCommand = "command -v certbot >/dev/null || (apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y certbot)"
+
+func newCertbotResource(p *internalProvider) resource.Resource {
+	return &certbotResource{
+		provider: p,
+	}
+}
+
+// certbotResource defines the resource implementation.
+type certbotResource struct {
+	provider *internalProvider
+}
+
+type certbotResourceModel struct {
+	Domains         types.List   `tfsdk:"domains"`
+	CertName        types.String `tfsdk:"cert_name"`
+	Email           types.String `tfsdk:"email"`
+	Mode            types.String `tfsdk:"mode"`
+	WebrootPath     types.String `tfsdk:"webroot_path"`
+	Staging         types.Bool   `tfsdk:"staging"`
+	ReloadService   types.String `tfsdk:"reload_service"`
+	CertificatePath types.String `tfsdk:"certificate_path"`
+	PrivateKeyPath  types.String `tfsdk:"private_key_path"`
+	ChainPath       types.String `tfsdk:"chain_path"`
+	FullchainPath   types.String `tfsdk:"fullchain_path"`
+	NotAfter        types.String `tfsdk:"not_after"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *certbotResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_certbot"
+}
+
+func (r *certbotResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Certbot resource that installs certbot on Debian and Ubuntu and obtains an ACME certificate, " +
+			"e.g. from Let's Encrypt, for the given domains. An existing certificate with the same name is adopted. " +
+			"Certbot renews the certificate by itself with its systemd timer, and reloads reload_service after each " +
+			"renewal. Changing the domains or the challenge settings reissues the certificate. Destroying the resource " +
+			"deletes the certificate with certbot delete, while certbot itself is kept",
+
+		Attributes: map[string]schema.Attribute{
+			"domains": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The domains of the certificate, e.g. ['example.com', 'www.example.com']. They must resolve to the machine",
+				Validators:  []validator.List{listOf(notBlank())},
+			},
+			"cert_name": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The name of the certificate in certbot, which names its directory in /etc/letsencrypt/live. Defaults to the first domain",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"email": schema.StringAttribute{
+				Optional:    true,
+				Description: "The email address registered with the ACME account, which receives the expiry notices. The account is registered without email when unset",
+				Validators:  []validator.String{notBlank()},
+			},
+			"mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("standalone"),
+				Description: "How the HTTP challenge is answered, either 'standalone', where certbot listens on port 80 itself, or 'webroot', where the running web server serves the files certbot writes in webroot_path. Defaults to standalone",
+				Validators:  []validator.String{oneOf("standalone", "webroot")},
+			},
+			"webroot_path": schema.StringAttribute{
+				Optional:    true,
+				Description: "The document root of the web server serving the domains, e.g. /var/www/html. Required in webroot mode",
+				Validators:  []validator.String{absolutePath()},
+			},
+			"staging": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the certificate is obtained from the staging environment of Let's Encrypt, which is not trusted but has much higher rate limits. Defaults to false",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"reload_service": schema.StringAttribute{
+				Optional:    true,
+				Description: "The systemd service reloaded after the certificate is obtained or renewed, e.g. nginx",
+				Validators:  []validator.String{notBlank()},
+			},
+			"certificate_path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the certificate, without its chain",
+			},
+			"private_key_path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the private key",
+			},
+			"chain_path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the intermediate certificates",
+			},
+			"fullchain_path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the certificate followed by the intermediate certificates, as most web servers expect it",
+			},
+			"not_after": schema.StringAttribute{
+				Computed:    true,
+				Description: "The expiration date of the certificate, in RFC 3339 format",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *certbotResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *certbotResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan certbotResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Mode.ValueString() == "webroot" && plan.WebrootPath.IsNull() {
+		resp.Diagnostics.AddAttributeError(tfpath.Root("webroot_path"), "Missing webroot path", "webroot_path must be set in webroot mode")
+		return
+	}
+
+	if plan.CertName.IsUnknown() {
+		// The name defaults to the first domain, which is only unknown when it comes from another resource
+		domain := types.StringUnknown()
+		if elements := plan.Domains.Elements(); len(elements) > 0 {
+			if first, ok := elements[0].(types.String); ok && !first.IsNull() {
+				domain = first
+			}
+		}
+
+		plan.CertName = domain
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tfpath.Root("cert_name"), domain)...)
+	}
+
+	// The files are known as soon as the name is, so that other resources can use them in their plan
+	if plan.CertName.IsUnknown() {
+		return
+	}
+
+	paths := certbotPaths(plan.CertName.ValueString())
+	for _, attribute := range []string{"certificate_path", "private_key_path", "chain_path", "fullchain_path"} {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tfpath.Root(attribute), types.StringValue(paths[attribute]))...)
+	}
+}
+
+func (r *certbotResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan certbotResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		resp.Diagnostics.AddError("Failed to configure the apt proxy", err.Error())
+		return
+	}
+
+	unlock := r.provider.lock(ctx, aptLock)
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(certbotInstallCommand)))
+	unlock()
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install certbot", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	// An existing certificate with the same domains is kept until it is due for renewal
+	r.obtain(ctx, &plan, "--keep-until-expiring", &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *certbotResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state certbotResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	certificate, err := r.provider.machineAccessClient.ReadFile(ctx, state.CertificatePath.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The certificate was deleted outside of terraform, it must be obtained again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read certificate file", err.Error())
+		return
+	}
+
+	// The certificate changes with each renewal, only its expiration date is tracked
+	state.NotAfter = types.StringNull()
+	if notAfter, err := certificateNotAfter(certificate); err == nil {
+		state.NotAfter = types.StringValue(notAfter)
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *certbotResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan certbotResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// certbot only records the new settings in the renewal configuration when it issues a certificate
+	r.obtain(ctx, &plan, "--force-renewal", &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *certbotResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state certbotResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("certbot", "delete", "--non-interactive", "--cert-name", state.CertName.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to delete the certificate", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// obtain runs certbot certonly with the settings of the plan and the given renewal flag, then sets the expiration
+// date of the certificate in the plan.
+func (r *certbotResource) obtain(ctx context.Context, plan *certbotResourceModel, renewal string, diags *diag.Diagnostics) {
+	command, err := certbotCommand(ctx, *plan, renewal)
+	if err != nil {
+		diags.AddError("Failed to read the domains", err.Error())
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(command)))
+	if err != nil {
+		diags.AddError("Failed to obtain the certificate", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	certificate, err := r.provider.machineAccessClient.ReadFile(ctx, plan.CertificatePath.ValueString(), true)
+	if err != nil {
+		diags.AddError("Failed to read certificate file", err.Error())
+		return
+	}
+
+	notAfter, err := certificateNotAfter(certificate)
+	if err != nil {
+		diags.AddError("Invalid certificate", err.Error())
+		return
+	}
+
+	plan.NotAfter = types.StringValue(notAfter)
+}
+
+// certbotCommand returns the certbot certonly command obtaining the certificate of the model.
+func certbotCommand(ctx context.Context, model certbotResourceModel, renewal string) (string, error) {
+	var domains []string
+
+	if diags := model.Domains.ElementsAs(ctx, &domains, false); diags.HasError() {
+		return "", fmt.Errorf("%v", diags)
+	}
+
+	args := []string{"certonly", "--non-interactive", "--agree-tos", renewal, "--cert-name", model.CertName.ValueString()}
+	for _, domain := range domains {
+		args = append(args, "-d", domain)
+	}
+
+	if model.Mode.ValueString() == "webroot" {
+		args = append(args, "--webroot", "-w", model.WebrootPath.ValueString())
+	} else {
+		args = append(args, "--standalone")
+	}
+
+	if model.Email.IsNull() {
+		args = append(args, "--register-unsafely-without-email")
+	} else {
+		args = append(args, "--email", model.Email.ValueString())
+	}
+
+	if model.Staging.ValueBool() {
+		args = append(args, "--staging")
+	}
+
+	// The hook is recorded in the renewal configuration, so that the service is also reloaded by the renewals
+	if !model.ReloadService.IsNull() {
+		args = append(args, "--deploy-hook", "systemctl try-reload-or-restart "+clients.ShellQuote(model.ReloadService.ValueString()))
+	}
+
+	return clients.ShellCommand("certbot", args...), nil
+}
+
+// certbotPaths returns the paths of the files of the certificate by attribute.
+func certbotPaths(certName string) map[string]string {
+	directory := path.Join(certbotLiveDirectory, certName)
+
+	return map[string]string{
+		"certificate_path": path.Join(directory, "cert.pem"),
+		"private_key_path": path.Join(directory, "privkey.pem"),
+		"chain_path":       path.Join(directory, "chain.pem"),
+		"fullchain_path":   path.Join(directory, "fullchain.pem"),
+	}
+}
@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestCertbotResourceWithMock(t *testing.T) {
+	t.Run("plan defaults the name to the first domain and exposes the paths", func(t *testing.T) {
+		// Arrange
+		plan := testCertbotModel()
+		plan.CertName = types.StringUnknown()
+
+		// Act
+		planned, diags := testResourceModifyPlan(t, newCertbotResource(newTestProvider(clients.NewMockMachineAccessClient())).(*certbotResource), nil, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if planned.CertName.ValueString() != "example.com" || planned.FullchainPath.ValueString() != "/etc/letsencrypt/live/example.com/fullchain.pem" {
+			t.Fatalf("unexpected plan: %+v", planned)
+		}
+	})
+
+	t.Run("plan requires the webroot path in webroot mode", func(t *testing.T) {
+		// Arrange
+		plan := testCertbotModel()
+		plan.Mode = types.StringValue("webroot")
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newCertbotResource(newTestProvider(clients.NewMockMachineAccessClient())).(*certbotResource), nil, plan)
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("create installs certbot and obtains the certificate", func(t *testing.T) {
+		// Arrange
+		certificate, _ := testSelfSignedCertificate(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/letsencrypt/live/example.com/cert.pem", certificate, clients.FileInfo{Mode: "644"})
+
+		plan := testCertbotModel()
+		plan.Mode = types.StringValue("webroot")
+		plan.WebrootPath = types.StringValue("/var/www/html")
+		plan.Email = types.StringValue("admin@example.com")
+		plan.ReloadService = types.StringValue("nginx")
+
+		// Act
+		state, diags := testResourceCreate(t, newCertbotResource(newTestProvider(mock)), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{
+			certbotInstallCommand,
+			"certbot certonly --non-interactive --agree-tos --keep-until-expiring --cert-name example.com -d example.com -d www.example.com " +
+				"--webroot -w /var/www/html --email admin@example.com --deploy-hook 'systemctl try-reload-or-restart '\\''nginx'\\'''",
+		}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if state.NotAfter.ValueString() != "2030-01-02T03:04:05Z" {
+			t.Fatalf("unexpected not_after: %s", state.NotAfter)
+		}
+	})
+
+	t.Run("update reissues the certificate", func(t *testing.T) {
+		// Arrange
+		certificate, _ := testSelfSignedCertificate(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/letsencrypt/live/example.com/cert.pem", certificate, clients.FileInfo{Mode: "644"})
+
+		plan := testCertbotModel()
+		plan.Domains = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("example.com")})
+
+		// Act
+		_, diags := testResourceUpdate(t, newCertbotResource(newTestProvider(mock)), testCertbotModel(), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{"certbot certonly --non-interactive --agree-tos --force-renewal --cert-name example.com -d example.com --standalone --register-unsafely-without-email"}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read removes a deleted certificate", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newCertbotResource(newTestProvider(mock)), testCertbotModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete deletes the certificate", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newCertbotResource(newTestProvider(mock)), testCertbotModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"certbot delete --non-interactive --cert-name example.com"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func testCertbotModel() certbotResourceModel {
+	paths := certbotPaths("example.com")
+
+	return certbotResourceModel{
+		Domains:         types.ListValueMust(types.StringType, []attr.Value{types.StringValue("example.com"), types.StringValue("www.example.com")}),
+		CertName:        types.StringValue("example.com"),
+		Email:           types.StringNull(),
+		Mode:            types.StringValue("standalone"),
+		WebrootPath:     types.StringNull(),
+		Staging:         types.BoolValue(false),
+		ReloadService:   types.StringNull(),
+		CertificatePath: types.StringValue(paths["certificate_path"]),
+		PrivateKeyPath:  types.StringValue(paths["private_key_path"]),
+		ChainPath:       types.StringValue(paths["chain_path"]),
+		FullchainPath:   types.StringValue(paths["fullchain_path"]),
+		NotAfter:        types.StringValue("2030-01-02T03:04:05Z"),
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &chocolateyPackageResource{}
+var _ resource.ResourceWithImportState = &chocolateyPackageResource{}
+
+// Exit codes of chocolatey telling that the operation succeeded but a reboot is needed to complete it.
+var chocolateyRebootExitCodes = []int{1641, 3010}
+
+func newChocolateyPackageResource(p *internalProvider) resource.Resource {
+	return &chocolateyPackageResource{
+		provider: p,
+	}
+}
+
+// chocolateyPackageResource defines the resource implementation.
+type chocolateyPackageResource struct {
+	provider *internalProvider
+}
+
+type chocolateyPackageResourceModel struct {
+	Name     types.String   `tfsdk:"name"`
+	Version  types.String   `tfsdk:"version"`
+	Timeouts *timeoutsModel `tfsdk:"timeouts"`
+	Retry    *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *chocolateyPackageResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_chocolatey_package"
+}
+
+func (r *chocolateyPackageResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Chocolatey package resource that installs a package on a Windows host. Requires the provider to be configured with os_family = \"windows\" and Chocolatey 2 or later on the host",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the chocolatey package",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"version": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The version of the chocolatey package. If not specified, the latest version is installed and the installed version is tracked",
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *chocolateyPackageResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *chocolateyPackageResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan chocolateyPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyWindows, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *chocolateyPackageResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model chocolateyPackageResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, model.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed chocolatey package version", err.Error())
+		return
+	}
+
+	if !found {
+		// Package is not installed anymore, remove from state
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	model.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *chocolateyPackageResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan chocolateyPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *chocolateyPackageResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model chocolateyPackageResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	err = r.choco(ctx, "uninstall "+clients.PowershellQuote(model.Name.ValueString()), &resp.Diagnostics)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to uninstall chocolatey package", err.Error())
+		return
+	}
+}
+
+func (r *chocolateyPackageResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+}
+
+// apply installs or upgrades the package to the planned version, and sets the installed version in the model.
+func (r *chocolateyPackageResource) apply(ctx context.Context, model *chocolateyPackageResourceModel, diags *diag.Diagnostics) {
+	// upgrade installs the package when it is missing
+	args := "upgrade " + clients.PowershellQuote(model.Name.ValueString())
+	if !model.Version.IsNull() && !model.Version.IsUnknown() && model.Version.ValueString() != "" {
+		args += " --version " + clients.PowershellQuote(model.Version.ValueString()) + " --allow-downgrade"
+	}
+
+	_, err := retry(ctx, model.Retry, func() (string, error) {
+		return "", r.choco(clients.WithStreamedOutput(ctx), args, diags)
+	})
+	if err != nil {
+		diags.AddError("Failed to install chocolatey package", err.Error())
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, model.Name.ValueString())
+	if err != nil {
+		diags.AddError("Failed to get installed chocolatey package version", err.Error())
+		return
+	}
+
+	if !found {
+		diags.AddError("Chocolatey package not found after installation", "The package "+model.Name.ValueString()+" is not listed by choco list")
+		return
+	}
+
+	model.Version = types.StringValue(version)
+}
+
+// choco runs chocolatey non-interactively. Exit codes telling that a reboot is required are reported as a warning.
+func (r *chocolateyPackageResource) choco(ctx context.Context, args string, diags *diag.Diagnostics) error {
+	result, err := r.provider.machineAccessClient.Run(ctx, "choco "+args+" -y --no-progress; exit $LASTEXITCODE")
+
+	if clients.IsExitCode(err, chocolateyRebootExitCodes...) {
+		diags.AddWarning("Reboot required", fmt.Sprintf("choco %s succeeded but a reboot is required to complete it", args))
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("choco %s failed. Err=%w\nout = %s", args, err, result.Stdout)
+	}
+
+	return nil
+}
+
+// installedVersion returns the installed version of the package, and whether the package is installed at all.
+func (r *chocolateyPackageResource) installedVersion(ctx context.Context, name string) (string, bool, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "choco list --exact --limit-output "+clients.PowershellQuote(name)+"; exit $LASTEXITCODE")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list chocolatey packages. Err=%w\nout = %s", err, out)
+	}
+
+	// --limit-output prints one 'name|version' line per package
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "|", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], name) {
+			return parts[1], true, nil
+		}
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestChocolateyPackageResourceWithMock(t *testing.T) {
+	t.Run("create installs the package and tracks its version", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^choco list ", clients.MockResponse{Stdout: "git|2.47.0\n"})
+
+		p := newTestProvider(mock)
+		p.osFamily = clients.OSFamilyWindows
+
+		// Act
+		state, diags := testResourceCreate(t, newChocolateyPackageResource(p), chocolateyPackageResourceModel{
+			Name:    types.StringValue("git"),
+			Version: types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Version.ValueString() != "2.47.0" {
+			t.Fatalf("unexpected version: %s", state.Version.ValueString())
+		}
+
+		if !strings.HasPrefix(mock.Commands[0], "choco upgrade 'git' -y") {
+			t.Fatalf("unexpected command: %s", mock.Commands[0])
+		}
+	})
+
+	t.Run("a reboot exit code is a warning", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^choco upgrade ", clients.MockResponse{ExitCode: 3010}).
+			OnMatch("^choco list ", clients.MockResponse{Stdout: "dotnet|8.0.0\n"})
+
+		p := newTestProvider(mock)
+		p.osFamily = clients.OSFamilyWindows
+
+		// Act
+		_, diags := testResourceCreate(t, newChocolateyPackageResource(p), chocolateyPackageResourceModel{
+			Name:    types.StringValue("dotnet"),
+			Version: types.StringValue("8.0.0"),
+		})
+
+		// Assert
+		if diags.HasError() || diags.WarningsCount() != 1 {
+			t.Fatalf("expected a single warning, got: %v", diags)
+		}
+	})
+
+	t.Run("create fails on linux hosts", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newChocolateyPackageResource(newTestProvider(mock)), chocolateyPackageResourceModel{
+			Name:    types.StringValue("git"),
+			Version: types.StringUnknown(),
+		})
+
+		// Assert
+		if !diags.HasError() || len(mock.Commands) != 0 {
+			t.Fatalf("expected an error without any command, got: %v, %v", diags, mock.Commands)
+		}
+	})
+}
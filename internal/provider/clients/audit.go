@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+type mutationKey struct{}
+
+// Mutation describes the change of a resource during which commands are run.
+type Mutation struct {
+	// Resource is the type of the resource, e.g. setup_file.
+	Resource string
+	// Operation is one of create, update or delete.
+	Operation string
+}
+
+// WithMutation marks the context of the Create, Update or Delete of a resource, so that the commands run with it are
+// known to change the machine, as opposed to the commands reading its state.
+func WithMutation(ctx context.Context, resource string, operation string) context.Context {
+	return context.WithValue(ctx, mutationKey{}, Mutation{Resource: resource, Operation: operation})
+}
+
+// WithoutMutation marks the commands run with the context as reads, e.g. the probes of the machine run during a
+// change, so that they are neither recorded in the audit log nor skipped in a dry run.
+func WithoutMutation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mutationKey{}, nil)
+}
+
+func mutationFrom(ctx context.Context) (Mutation, bool) {
+	mutation, ok := ctx.Value(mutationKey{}).(Mutation)
+	return mutation, ok
+}
+
+// AuditEntry is a command recorded in the audit log.
+type AuditEntry struct {
+	Timestamp string `json:"timestamp"`
+	// Host is the name of the host selected with WithHost, empty for the default host.
+	Host      string `json:"host,omitempty"`
+	Resource  string `json:"resource"`
+	Operation string `json:"operation"`
+	Command   string `json:"command"`
+	// ExitCode is -1 when the command could not be run, e.g. on a connection failure.
+	ExitCode int `json:"exit_code"`
+}
+
+// AuditOptions configures where the audit log is written.
+type AuditOptions struct {
+	// Path is the local file the entries are appended to, one JSON object per line.
+	Path string
+	// Syslog sends the entries to the syslog of the machine with logger.
+	Syslog bool
+	// Become is the privilege escalation the commands are wrapped with, so that its password is masked.
+	Become Become
+}
+
+// NewAuditedMachineAccessClient returns a client that records the commands run, the files written and the files copied
+// during the changes of resources, as marked with WithMutation. The commands run to read the state of the machine are
+// not recorded.
+func NewAuditedMachineAccessClient(inner MachineAccessClient, options AuditOptions) MachineAccessClient {
+	return &auditedMachineAccessClient{inner: inner, options: options}
+}
+
+type auditedMachineAccessClient struct {
+	inner   MachineAccessClient
+	options AuditOptions
+	mutex   sync.Mutex
+}
+
+func (audited *auditedMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
+	out, err := audited.inner.RunCommand(ctx, command)
+	audited.record(ctx, command, err)
+
+	return out, err
+}
+
+func (audited *auditedMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	result, err := audited.inner.Run(ctx, command)
+	audited.record(ctx, command, err)
+
+	return result, err
+}
+
+func (audited *auditedMachineAccessClient) WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error {
+	err := audited.inner.WriteFile(ctx, path, mode, owner, group, content)
+	audited.record(ctx, fmt.Sprintf("write %s (mode %s, owner %s:%s, %d bytes)", path, mode, owner, group, len(content)), err)
+
+	return err
+}
+
+func (audited *auditedMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	return audited.inner.ReadFile(ctx, path, followSymlinks)
+}
+
+func (audited *auditedMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	return audited.inner.Stat(ctx, path, followSymlinks)
+}
+
+func (audited *auditedMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
+	err := audited.inner.CopyFile(ctx, localPath, remotePath)
+	audited.record(ctx, fmt.Sprintf("copy %s to %s", localPath, remotePath), err)
+
+	return err
+}
+
+func (audited *auditedMachineAccessClient) GetDockerClient(ctx context.Context) (*client.Client, error) {
+	return audited.inner.GetDockerClient(ctx)
+}
+
+// record writes the entry of the command when it was run during a change. A failure to write the audit log is logged
+// rather than failing the change, which already happened.
+func (audited *auditedMachineAccessClient) record(ctx context.Context, command string, err error) {
+	mutation, ok := mutationFrom(ctx)
+	if !ok {
+		return
+	}
+
+	exitCode, ok := ExitCode(err)
+	if !ok && err != nil {
+		exitCode = -1
+	}
+
+	line, err := json.Marshal(AuditEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Host:      HostFrom(ctx),
+		Resource:  mutation.Resource,
+		Operation: mutation.Operation,
+		Command:   redactSensitiveValues(ctx, audited.options.Become.redact(command)),
+		ExitCode:  exitCode,
+	})
+	if err != nil {
+		tflog.Warn(ctx, "Failed to encode the audit log entry: "+err.Error())
+		return
+	}
+
+	if audited.options.Path != "" {
+		if err := audited.append(line); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to write the audit log %s: %v", audited.options.Path, err))
+		}
+	}
+
+	if audited.options.Syslog {
+		// The entry is sent with the inner client, so that sending it is not recorded in turn
+		if _, err := audited.inner.Run(ctx, ShellCommand("logger", "-t", "terraform-provider-setup", "--", string(line))); err != nil {
+			tflog.Warn(ctx, "Failed to send the audit log entry to syslog: "+err.Error())
+		}
+	}
+}
+
+// append appends the line to the audit log file, the resources being applied in parallel.
+func (audited *auditedMachineAccessClient) append(line []byte) error {
+	audited.mutex.Lock()
+	defer audited.mutex.Unlock()
+
+	file, err := os.OpenFile(audited.options.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 - the path is configured in the provider
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,114 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAuditLog(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+
+	content, err := os.ReadFile(path) // #nosec G304 - the path is a test file
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	assert.NoError(t, err)
+
+	entries := []AuditEntry{}
+
+	for line := range strings.SplitSeq(strings.TrimSpace(string(content)), "\n") {
+		var entry AuditEntry
+
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestAuditedMachineAccessClient(t *testing.T) {
+	t.Run("the commands of changes are recorded with their exit code", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		mock := NewMockMachineAccessClient().On("systemctl restart nginx", MockResponse{ExitCode: 3})
+		client := NewAuditedMachineAccessClient(mock, AuditOptions{Path: path})
+
+		ctx := WithMutation(context.Background(), "setup_file", "create")
+
+		// Act
+		_, _ = client.Run(ctx, "systemctl restart nginx")
+		err := client.WriteFile(ctx, "/etc/motd", "644", "0", "0", "welcome\n")
+
+		// Assert
+		assert.NoError(t, err)
+
+		entries := readAuditLog(t, path)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "setup_file", entries[0].Resource)
+		assert.Equal(t, "create", entries[0].Operation)
+		assert.Equal(t, "systemctl restart nginx", entries[0].Command)
+		assert.Equal(t, 3, entries[0].ExitCode)
+		assert.NotEmpty(t, entries[0].Timestamp)
+		assert.Equal(t, "write /etc/motd (mode 644, owner 0:0, 8 bytes)", entries[1].Command)
+		assert.Equal(t, 0, entries[1].ExitCode)
+	})
+
+	t.Run("the commands reading the state are not recorded", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		client := NewAuditedMachineAccessClient(NewMockMachineAccessClient(), AuditOptions{Path: path})
+
+		// Act
+		_, err := client.RunCommand(context.Background(), "cat /etc/hostname")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, readAuditLog(t, path))
+	})
+
+	t.Run("the passwords are masked", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		become := Become{Password: "sudo-secret"}
+		client := NewAuditedMachineAccessClient(NewMockMachineAccessClient(), AuditOptions{Path: path, Become: become})
+
+		ctx := WithSensitiveValues(WithMutation(context.Background(), "setup_ssh_key", "update"), "passphrase")
+
+		// Act
+		_, err := client.Run(ctx, become.Wrap("ssh-keygen -p -P old -N "+ShellQuote("passphrase")))
+
+		// Assert
+		assert.NoError(t, err)
+
+		entries := readAuditLog(t, path)
+		assert.Len(t, entries, 1)
+		assert.NotContains(t, entries[0].Command, "sudo-secret")
+		assert.NotContains(t, entries[0].Command, "passphrase")
+	})
+
+	t.Run("the entries are sent to syslog", func(t *testing.T) {
+		// Arrange
+		mock := NewMockMachineAccessClient()
+		client := NewAuditedMachineAccessClient(mock, AuditOptions{Syslog: true})
+
+		ctx := WithMutation(context.Background(), "setup_apt_packages", "delete")
+
+		// Act
+		_, err := client.RunCommand(ctx, "apt-get remove -y nginx")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, mock.Commands, 2)
+		assert.True(t, strings.HasPrefix(mock.Commands[1], "logger -t terraform-provider-setup -- "), mock.Commands[1])
+		assert.Contains(t, mock.Commands[1], `"command":"apt-get remove -y nginx"`)
+	})
+}
@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Become describes how commands that need elevated privileges are run on the remote machine. The zero value runs them
+// with sudo as root, without a password.
+type Become struct {
+	// Disabled runs privileged commands as the connecting user, e.g. when connecting as root to a host without sudo.
+	Disabled bool
+	// Method is either "sudo" (the default) or "doas".
+	Method string
+	// User is the user to run the commands as. Defaults to root.
+	User string
+	// Password is fed to sudo -S. doas cannot read a password from stdin, so it is only supported with sudo.
+	Password string
+}
+
+// Validate checks that the configuration can be used.
+func (b Become) Validate() error {
+	switch b.Method {
+	case "", "sudo":
+		return nil
+	case "doas":
+		if b.Password != "" {
+			return fmt.Errorf("a password is not supported with doas, configure it with nopass instead")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported become method '%s', must be one of sudo or doas", b.Method)
+	}
+}
+
+// Wrap returns the command so that it runs with elevated privileges. The command is run as a whole through sh -c, so
+// pipelines and command lists are elevated entirely.
+func (b Become) Wrap(command string) string {
+	if b.Disabled {
+		return command
+	}
+
+	userFlag := ""
+	if b.User != "" {
+		userFlag = " -u " + ShellQuote(b.User)
+	}
+
+	if b.Method == "doas" {
+		return "doas" + userFlag + " sh -c " + ShellQuote(command)
+	}
+
+	if b.Password == "" {
+		return "sudo" + userFlag + " -- sh -c " + ShellQuote(command)
+	}
+
+	// sudo reads the password from the first line of stdin. The command itself gets no stdin, so that the password is
+	// never seen by it when sudo did not ask for one.
+	return "printf '%s\\n' " + ShellQuote(b.Password) + " | sudo -S -p ''" + userFlag + " -- sh -c " + ShellQuote("exec </dev/null; "+command)
+}
+
+// redact hides the password in the given text, e.g. before a command is logged.
+func (b Become) redact(text string) string {
+	if b.Password == "" {
+		return text
+	}
+
+	return strings.ReplaceAll(text, ShellQuote(b.Password), "'***'")
+}
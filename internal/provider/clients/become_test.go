@@ -0,0 +1,73 @@
+package clients
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBecomeWrap(t *testing.T) {
+	t.Run("sudo without password", func(t *testing.T) {
+		// Act
+		command := Become{}.Wrap("echo hello | tee /tmp/out")
+
+		// Assert
+		assert.Equal(t, `sudo -- sh -c 'echo hello | tee /tmp/out'`, command)
+	})
+
+	t.Run("sudo as another user with password", func(t *testing.T) {
+		// Act
+		command := Become{User: "app", Password: "it's secret"}.Wrap("whoami")
+
+		// Assert
+		assert.Equal(t, `printf '%s\n' 'it'\''s secret' | sudo -S -p '' -u 'app' -- sh -c 'exec </dev/null; whoami'`, command)
+	})
+
+	t.Run("doas", func(t *testing.T) {
+		// Act
+		command := Become{Method: "doas"}.Wrap("whoami")
+
+		// Assert
+		assert.Equal(t, `doas sh -c 'whoami'`, command)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// Act
+		command := Become{Disabled: true}.Wrap("whoami")
+
+		// Assert
+		assert.Equal(t, "whoami", command)
+	})
+
+	t.Run("quoting survives the shell", func(t *testing.T) {
+		// Arrange
+		command := Become{Disabled: true}.Wrap(`echo "a b" 'c'"'"'d'`)
+
+		// Act
+		out, err := exec.Command("sh", "-c", "sh -c "+ShellQuote(command)).CombinedOutput() // #nosec G204 - this is only used for testing
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "a b c'd\n", string(out))
+	})
+
+	t.Run("password is redacted", func(t *testing.T) {
+		// Arrange
+		become := Become{Password: "secret"}
+
+		// Act
+		redacted := become.redact(become.Wrap("whoami"))
+
+		// Assert
+		assert.NotContains(t, redacted, "secret")
+	})
+
+	t.Run("password is not supported with doas", func(t *testing.T) {
+		// Act
+		err := Become{Method: "doas", Password: "secret"}.Validate()
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,83 @@
+package clients
+
+import "strings"
+
+// Capabilities describes the userland of a Linux machine that commands adapt to, e.g. Alpine and other minimal images
+// that have BusyBox and neither bash nor useradd.
+type Capabilities struct {
+	// Bash is set when bash is installed.
+	Bash bool
+	// BusyBox is set when the core utilities, e.g. stat, are applets of BusyBox rather than GNU coreutils.
+	BusyBox bool
+	// ShadowUtils is set when useradd, usermod and groupadd are installed. Without them, the accounts are managed with
+	// the adduser, deluser, addgroup and delgroup applets of BusyBox.
+	ShadowUtils bool
+	// Architecture is the architecture of the userland in the naming of Docker and Go, e.g. amd64 or arm64, empty when
+	// it is unknown.
+	Architecture string
+}
+
+// DefaultCapabilities are those of Debian and Ubuntu, assumed when they can not be probed.
+var DefaultCapabilities = Capabilities{Bash: true, ShadowUtils: true}
+
+// CapabilitiesProbe is the POSIX sh command printing the capabilities of the machine, parsed by ParseCapabilities.
+// useradd is looked for in the sbin directories as well, as they are not in the PATH of unprivileged users on Debian.
+// The architecture of dpkg is preferred to the one of the kernel, as a 64 bits kernel may run a 32 bits userland.
+const CapabilitiesProbe = "command -v bash >/dev/null 2>&1 && echo bash; " +
+	"{ [ -x /usr/sbin/useradd ] || [ -x /sbin/useradd ] || command -v useradd >/dev/null 2>&1; } && echo useradd; " +
+	"stat --help 2>&1 | grep -q BusyBox && echo busybox; " +
+	"echo \"arch=$(dpkg --print-architecture 2>/dev/null || uname -m)\"; true"
+
+// ParseCapabilities parses the output of CapabilitiesProbe.
+func ParseCapabilities(out string) Capabilities {
+	capabilities := Capabilities{}
+
+	for _, line := range strings.Fields(out) {
+		switch line {
+		case "bash":
+			capabilities.Bash = true
+		case "useradd":
+			capabilities.ShadowUtils = true
+		case "busybox":
+			capabilities.BusyBox = true
+		default:
+			if architecture, found := strings.CutPrefix(line, "arch="); found {
+				capabilities.Architecture = NormalizeArchitecture(architecture)
+			}
+		}
+	}
+
+	return capabilities
+}
+
+// LoginShell returns the shell given to the users created without one, bash when it is installed and sh otherwise.
+func (c Capabilities) LoginShell() string {
+	if c.Bash {
+		return "/bin/bash"
+	}
+
+	return "/bin/sh"
+}
+
+// architectures maps the names given by uname -m and dpkg to those of Docker and Go.
+var architectures = map[string]string{
+	"x86_64":  "amd64",
+	"i386":    "386",
+	"i686":    "386",
+	"aarch64": "arm64",
+	"armv6l":  "arm",
+	"armv7l":  "arm",
+	"armhf":   "arm",
+	"armel":   "arm",
+	"ppc64el": "ppc64le",
+}
+
+// NormalizeArchitecture returns the architecture in the naming of Docker and Go, e.g. arm64 for aarch64. The names
+// which are the same in every naming, e.g. s390x or riscv64, are returned as they are.
+func NormalizeArchitecture(architecture string) string {
+	if normalized, ok := architectures[architecture]; ok {
+		return normalized
+	}
+
+	return architecture
+}
@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Run("parses the output of the probe", func(t *testing.T) {
+		// Act
+		capabilities := ParseCapabilities("bash\nuseradd\narch=amd64\n")
+
+		// Assert
+		if capabilities != (Capabilities{Bash: true, ShadowUtils: true, Architecture: "amd64"}) || capabilities.LoginShell() != "/bin/bash" {
+			t.Fatalf("unexpected capabilities: %+v", capabilities)
+		}
+	})
+
+	t.Run("a BusyBox machine without bash logs in with sh", func(t *testing.T) {
+		// Act
+		capabilities := ParseCapabilities("busybox\narch=aarch64\n")
+
+		// Assert
+		if capabilities != (Capabilities{BusyBox: true, Architecture: "arm64"}) || capabilities.LoginShell() != "/bin/sh" {
+			t.Fatalf("unexpected capabilities: %+v", capabilities)
+		}
+	})
+
+	t.Run("normalizes the architectures of uname and dpkg", func(t *testing.T) {
+		for architecture, expected := range map[string]string{"x86_64": "amd64", "armhf": "arm", "armv7l": "arm", "arm64": "arm64", "riscv64": "riscv64"} {
+			// Act
+			normalized := NormalizeArchitecture(architecture)
+
+			// Assert
+			if normalized != expected {
+				t.Errorf("expected %s for %s, got %s", expected, architecture, normalized)
+			}
+		}
+	})
+
+	t.Run("the probe runs with a POSIX sh", func(t *testing.T) {
+		// Arrange
+		client, err := CreateLocalMachineAccessClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		out, err := client.RunCommand(t.Context(), CapabilitiesProbe)
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ParseCapabilities(out).Architecture == "" {
+			t.Fatalf("the architecture was not probed: %s", out)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package clients
+
+import "context"
+
+const (
+	// DockerSocket is the socket of the Docker API served by dockerd.
+	DockerSocket = "/var/run/docker.sock"
+	// PodmanSocket is the socket of the Docker compatible API served by podman, as root, once podman.socket is enabled.
+	PodmanSocket = "/run/podman/podman.sock"
+)
+
+type dockerSocketKey struct{}
+
+// WithDockerSocket selects the unix socket of the machine to which the clients returned by GetDockerClient with the
+// context connect, e.g. PodmanSocket to manage the images and containers of podman with the Docker API.
+func WithDockerSocket(ctx context.Context, socket string) context.Context {
+	return context.WithValue(ctx, dockerSocketKey{}, socket)
+}
+
+// DockerSocketFrom returns the socket selected with WithDockerSocket, or DockerSocket when none is selected.
+func DockerSocketFrom(ctx context.Context) string {
+	socket, _ := ctx.Value(dockerSocketKey{}).(string)
+	if socket == "" {
+		return DockerSocket
+	}
+
+	return socket
+}
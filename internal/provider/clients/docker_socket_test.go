@@ -0,0 +1,26 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerSocket(t *testing.T) {
+	t.Run("the socket of docker is used by default", func(t *testing.T) {
+		// Act
+		socket := DockerSocketFrom(context.Background())
+
+		// Assert
+		assert.Equal(t, "/var/run/docker.sock", socket)
+	})
+
+	t.Run("the selected socket is used", func(t *testing.T) {
+		// Act
+		socket := DockerSocketFrom(WithDockerSocket(context.Background(), PodmanSocket))
+
+		// Assert
+		assert.Equal(t, "/run/podman/podman.sock", socket)
+	})
+}
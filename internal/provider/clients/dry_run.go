@@ -0,0 +1,78 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// NewDryRunMachineAccessClient returns a client that logs the commands, the file writes and the file copies of the
+// changes of resources, as marked with WithMutation, instead of running them. They succeed with an empty output. The
+// commands reading the state of the machine are run as usual.
+func NewDryRunMachineAccessClient(inner MachineAccessClient, become Become) MachineAccessClient {
+	return &dryRunMachineAccessClient{inner: inner, become: become}
+}
+
+type dryRunMachineAccessClient struct {
+	inner  MachineAccessClient
+	become Become
+}
+
+func (dryRun *dryRunMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
+	if dryRun.skip(ctx, command) {
+		return "", nil
+	}
+
+	return dryRun.inner.RunCommand(ctx, command)
+}
+
+func (dryRun *dryRunMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	if dryRun.skip(ctx, command) {
+		return CommandResult{}, nil
+	}
+
+	return dryRun.inner.Run(ctx, command)
+}
+
+func (dryRun *dryRunMachineAccessClient) WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error {
+	if dryRun.skip(ctx, fmt.Sprintf("write %s (mode %s, owner %s:%s, %d bytes)", path, mode, owner, group, len(content))) {
+		return nil
+	}
+
+	return dryRun.inner.WriteFile(ctx, path, mode, owner, group, content)
+}
+
+func (dryRun *dryRunMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	return dryRun.inner.ReadFile(ctx, path, followSymlinks)
+}
+
+func (dryRun *dryRunMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	return dryRun.inner.Stat(ctx, path, followSymlinks)
+}
+
+func (dryRun *dryRunMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
+	if dryRun.skip(ctx, fmt.Sprintf("copy %s to %s", localPath, remotePath)) {
+		return nil
+	}
+
+	return dryRun.inner.CopyFile(ctx, localPath, remotePath)
+}
+
+func (dryRun *dryRunMachineAccessClient) GetDockerClient(ctx context.Context) (*client.Client, error) {
+	return dryRun.inner.GetDockerClient(ctx)
+}
+
+// skip logs the command and returns true when it would be run during a change.
+func (dryRun *dryRunMachineAccessClient) skip(ctx context.Context, command string) bool {
+	mutation, ok := mutationFrom(ctx)
+	if !ok {
+		return false
+	}
+
+	tflog.Info(ctx, fmt.Sprintf("Dry run, not running for the %s of %s: %s", mutation.Operation, mutation.Resource,
+		redactSensitiveValues(ctx, dryRun.become.redact(command))))
+
+	return true
+}
@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunMachineAccessClient(t *testing.T) {
+	t.Run("the commands of changes are logged instead of run", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		mock := NewMockMachineAccessClient()
+		client := NewDryRunMachineAccessClient(mock, Become{})
+
+		ctx := WithMutation(tflogtest.RootLogger(context.Background(), &logs), "setup_file", "create")
+
+		// Act
+		_, runErr := client.RunCommand(ctx, "systemctl restart nginx")
+		writeErr := client.WriteFile(ctx, "/etc/motd", "644", "0", "0", "welcome\n")
+
+		// Assert
+		assert.NoError(t, runErr)
+		assert.NoError(t, writeErr)
+		assert.Empty(t, mock.Commands)
+		assert.Empty(t, mock.Files)
+
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "Dry run, not running for the create of setup_file: systemctl restart nginx", entries[0]["@message"])
+		assert.Equal(t, "Dry run, not running for the create of setup_file: write /etc/motd (mode 644, owner 0:0, 8 bytes)", entries[1]["@message"])
+	})
+
+	t.Run("the commands reading the state are run", func(t *testing.T) {
+		// Arrange
+		mock := NewMockMachineAccessClient().On("cat /etc/hostname", MockResponse{Stdout: "web1\n"})
+		client := NewDryRunMachineAccessClient(mock, Become{})
+
+		// Act
+		out, err := client.RunCommand(context.Background(), "cat /etc/hostname")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "web1\n", out)
+		assert.Equal(t, []string{"cat /etc/hostname"}, mock.Commands)
+	})
+
+	t.Run("the passwords are masked", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		become := Become{Password: "sudo-secret"}
+		client := NewDryRunMachineAccessClient(NewMockMachineAccessClient(), become)
+
+		ctx := WithMutation(tflogtest.RootLogger(context.Background(), &logs), "setup_user", "update")
+
+		// Act
+		_, err := client.Run(ctx, become.Wrap("usermod -L alice"))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NotContains(t, logs.String(), "sudo-secret")
+	})
+}
@@ -0,0 +1,174 @@
+package clients
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fileNotFoundExitCode is the exit code of the file commands when the file does not exist, to tell it apart from other
+// failures such as a permission error.
+const fileNotFoundExitCode = 44
+
+// FileInfo is the metadata of a file returned by Stat.
+type FileInfo struct {
+	// Type is one of "file", "directory" or "symlink", or the type reported by stat for other file types.
+	Type    string
+	Size    int64
+	Mode    string
+	Owner   int64
+	Group   int64
+	ModTime time.Time
+	// LinkTarget is the target of the symlink, when the file is a symlink and symlinks are not followed.
+	LinkTarget string
+}
+
+// FileNotFoundError is returned by ReadFile and Stat when the file does not exist.
+type FileNotFoundError struct {
+	Path string
+}
+
+func (e FileNotFoundError) Error() string {
+	return fmt.Sprintf("file %s not found", e.Path)
+}
+
+// IsFileNotFound returns whether the error is, or wraps, a FileNotFoundError.
+func IsFileNotFound(err error) bool {
+	var notFound FileNotFoundError
+	return errors.As(err, &notFound)
+}
+
+type runFunc func(ctx context.Context, command string) (CommandResult, error)
+
+// existsTest returns the shell test that the path exists. A dangling symlink only exists when symlinks are not
+// followed.
+func existsTest(path string, followSymlinks bool) string {
+	if followSymlinks {
+		return fmt.Sprintf("[ -e %s ]", path)
+	}
+
+	return fmt.Sprintf("{ [ -e %[1]s ] || [ -L %[1]s ]; }", path)
+}
+
+// readFile returns the content of the file. A symlink is read through when followSymlinks is set, and is an error
+// otherwise. The content is transferred base64 encoded when encoded is set, for transports that are not binary safe.
+func readFile(ctx context.Context, run runFunc, path string, followSymlinks bool, encoded bool) (string, error) {
+	quoted := ShellQuote(path)
+
+	command := fmt.Sprintf("%s || exit %d; ", existsTest(quoted, followSymlinks), fileNotFoundExitCode)
+	if !followSymlinks {
+		command += fmt.Sprintf("if [ -L %s ]; then echo 'is a symbolic link' >&2; exit 1; fi; ", quoted)
+	}
+
+	if encoded {
+		command += "base64 < " + quoted
+	} else {
+		command += "cat -- " + quoted
+	}
+
+	result, err := run(ctx, command)
+	if err != nil {
+		if IsExitCode(err, fileNotFoundExitCode) {
+			return "", FileNotFoundError{Path: path}
+		}
+
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	if !encoded {
+		return result.Stdout, nil
+	}
+
+	content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(result.Stdout), ""))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode content of %s: %w", path, err)
+	}
+
+	return string(content), nil
+}
+
+// statFile returns the metadata of the file. The metadata of the symlink itself is returned when followSymlinks is not
+// set.
+func statFile(ctx context.Context, run runFunc, path string, followSymlinks bool) (FileInfo, error) {
+	quoted := ShellQuote(path)
+
+	// %F is last as it may contain spaces, e.g. "regular file"
+	command := fmt.Sprintf("%s || exit %d; ", existsTest(quoted, followSymlinks), fileNotFoundExitCode)
+	if followSymlinks {
+		command += fmt.Sprintf("stat -L -c '%%s %%a %%u %%g %%Y %%F' -- %s", quoted)
+	} else {
+		command += fmt.Sprintf("stat -c '%%s %%a %%u %%g %%Y %%F' -- %[1]s && if [ -L %[1]s ]; then readlink -- %[1]s; fi", quoted)
+	}
+
+	result, err := run(ctx, command)
+	if err != nil {
+		if IsExitCode(err, fileNotFoundExitCode) {
+			return FileInfo{}, FileNotFoundError{Path: path}
+		}
+
+		return FileInfo{}, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	return ParseStat(result.Stdout)
+}
+
+// ParseStat parses the output of stat -c '%s %a %u %g %Y %F', which GNU coreutils and BusyBox print alike, optionally
+// followed by the target of a symlink on the next line.
+func ParseStat(out string) (FileInfo, error) {
+	lines := strings.SplitN(strings.TrimRight(out, "\n"), "\n", 2)
+
+	fields := strings.SplitN(lines[0], " ", 6)
+	if len(fields) != 6 {
+		return FileInfo{}, fmt.Errorf("unexpected stat output: %s", out)
+	}
+
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to parse size in stat output %s: %w", out, err)
+	}
+
+	owner, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to parse owner in stat output %s: %w", out, err)
+	}
+
+	group, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to parse group in stat output %s: %w", out, err)
+	}
+
+	modTime, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to parse modification time in stat output %s: %w", out, err)
+	}
+
+	info := FileInfo{
+		Size:    size,
+		Mode:    fields[1],
+		Owner:   owner,
+		Group:   group,
+		ModTime: time.Unix(modTime, 0).UTC(),
+	}
+
+	switch fields[5] {
+	case "regular file", "regular empty file":
+		info.Type = "file"
+	case "directory":
+		info.Type = "directory"
+	case "symbolic link":
+		info.Type = "symlink"
+	default:
+		info.Type = fields[5]
+	}
+
+	if len(lines) == 2 {
+		info.LinkTarget = lines[1]
+	}
+
+	return info, nil
+}
@@ -0,0 +1,110 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/client"
+)
+
+type hostKey struct{}
+
+// WithHost selects the host, among the hosts of a client created with NewMultiHostMachineAccessClient, on which the
+// commands run with the context are run.
+func WithHost(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, hostKey{}, name)
+}
+
+// HostFrom returns the name of the host selected with WithHost, or an empty string for the default host.
+func HostFrom(ctx context.Context) string {
+	name, _ := ctx.Value(hostKey{}).(string)
+	return name
+}
+
+// NewMultiHostMachineAccessClient returns a client that runs the commands on the host selected by the context with
+// WithHost, or on the default host when none is selected.
+func NewMultiHostMachineAccessClient(defaultClient MachineAccessClient, hosts map[string]MachineAccessClient) MachineAccessClient {
+	return &multiHostMachineAccessClient{defaultClient: defaultClient, hosts: hosts}
+}
+
+type multiHostMachineAccessClient struct {
+	defaultClient MachineAccessClient
+	hosts         map[string]MachineAccessClient
+}
+
+func (multiHost *multiHostMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return inner.RunCommand(ctx, command)
+}
+
+func (multiHost *multiHostMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	return inner.Run(ctx, command)
+}
+
+func (multiHost *multiHostMachineAccessClient) WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inner.WriteFile(ctx, path, mode, owner, group, content)
+}
+
+func (multiHost *multiHostMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return inner.ReadFile(ctx, path, followSymlinks)
+}
+
+func (multiHost *multiHostMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return inner.Stat(ctx, path, followSymlinks)
+}
+
+func (multiHost *multiHostMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inner.CopyFile(ctx, localPath, remotePath)
+}
+
+func (multiHost *multiHostMachineAccessClient) GetDockerClient(ctx context.Context) (*client.Client, error) {
+	inner, err := multiHost.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return inner.GetDockerClient(ctx)
+}
+
+func (multiHost *multiHostMachineAccessClient) client(ctx context.Context) (MachineAccessClient, error) {
+	name := HostFrom(ctx)
+	if name == "" {
+		return multiHost.defaultClient, nil
+	}
+
+	inner, ok := multiHost.hosts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown host '%s', it must be one of the hosts of the provider configuration", name)
+	}
+
+	return inner, nil
+}
@@ -0,0 +1,51 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiHostMachineAccessClient(t *testing.T) {
+	t.Run("the commands run on the selected host", func(t *testing.T) {
+		// Arrange
+		defaultHost := NewMockMachineAccessClient()
+		web2 := NewMockMachineAccessClient().On("hostname", MockResponse{Stdout: "web2\n"})
+		client := NewMultiHostMachineAccessClient(defaultHost, map[string]MachineAccessClient{"web2": web2})
+
+		// Act
+		out, err := client.RunCommand(WithHost(context.Background(), "web2"), "hostname")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "web2\n", out)
+		assert.Empty(t, defaultHost.Commands)
+	})
+
+	t.Run("the commands run on the default host when none is selected", func(t *testing.T) {
+		// Arrange
+		defaultHost := NewMockMachineAccessClient()
+		web2 := NewMockMachineAccessClient()
+		client := NewMultiHostMachineAccessClient(defaultHost, map[string]MachineAccessClient{"web2": web2})
+
+		// Act
+		err := client.WriteFile(context.Background(), "/etc/motd", "644", "0", "0", "welcome\n")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Contains(t, defaultHost.Files, "/etc/motd")
+		assert.Empty(t, web2.Files)
+	})
+
+	t.Run("an unknown host is an error", func(t *testing.T) {
+		// Arrange
+		client := NewMultiHostMachineAccessClient(NewMockMachineAccessClient(), map[string]MachineAccessClient{})
+
+		// Act
+		_, err := client.Stat(WithHost(context.Background(), "web3"), "/etc/motd", true)
+
+		// Assert
+		assert.ErrorContains(t, err, "unknown host 'web3'")
+	})
+}
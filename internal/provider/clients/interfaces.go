@@ -2,24 +2,84 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/docker/docker/client"
 )
 
 // MachineAccessClient defines how to interact with a machine.
 type MachineAccessClient interface {
+	// RunCommand runs the command and returns its combined stdout and stderr.
 	RunCommand(ctx context.Context, command string) (string, error)
+	// Run runs the command and returns stdout, stderr and the exit code separately. A non-zero exit code is returned as
+	// an ExitError, together with the result.
+	Run(ctx context.Context, command string) (CommandResult, error)
+	// WriteFile replaces the content of the file. When the path is a symlink, the file it points to is written and the
+	// symlink is preserved.
 	WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error
+	// ReadFile returns the content of the file, or a FileNotFoundError when it does not exist. When followSymlinks is
+	// not set, reading a symlink is an error.
+	ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error)
+	// Stat returns the metadata of the file, or a FileNotFoundError when it does not exist. When followSymlinks is not
+	// set, the metadata of a symlink itself is returned.
+	Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error)
 	CopyFile(ctx context.Context, localPath string, remotePath string) error
 	GetDockerClient(ctx context.Context) (*client.Client, error)
 }
 
-// ExitError describes an error that occurred during command execution.
+// CommandResult is the outcome of a command run with Run.
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExitError is the error of a command that exited with a non-zero exit code. It matches, with errors.Is, the ExitError
+// of the same exit code, e.g. errors.Is(err, ExitError{ExitCode: 9}).
 type ExitError struct {
 	ExitCode int
+	// Command is the command that failed, with the password of the privilege escalation redacted.
+	Command string
+	// Stdout is the standard output of the command, combined with its standard error when they are not captured
+	// separately, as by RunCommand.
+	Stdout string
+	// Stderr is the standard error of the command, if it was captured separately.
+	Stderr string
 }
 
 func (e ExitError) Error() string {
-	return fmt.Sprintf("exit code %d", e.ExitCode)
+	stderr := strings.TrimSpace(e.Stderr)
+	if stderr == "" {
+		return fmt.Sprintf("exit code %d", e.ExitCode)
+	}
+
+	return fmt.Sprintf("exit code %d: %s", e.ExitCode, stderr)
+}
+
+// Is reports whether the target is an ExitError with the same exit code, whatever its command and output.
+func (e ExitError) Is(target error) bool {
+	other, ok := target.(ExitError)
+
+	return ok && other.ExitCode == e.ExitCode
+}
+
+// ExitCode returns the exit code of the command that failed with the error, and false when the error is not an
+// ExitError, e.g. a connection failure.
+func ExitCode(err error) (int, bool) {
+	var exitErr ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+
+	return exitErr.ExitCode, true
+}
+
+// IsExitCode returns true when the error is the ExitError of a command that exited with one of the exit codes.
+func IsExitCode(err error, codes ...int) bool {
+	exitCode, ok := ExitCode(err)
+
+	return ok && slices.Contains(codes, exitCode)
 }
@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitError(t *testing.T) {
+	wrapped := fmt.Errorf("failed to create the user: %w", ExitError{ExitCode: 9, Command: "useradd alice", Stderr: "useradd: user 'alice' already exists\n"})
+
+	t.Run("matches the exit errors of the same exit code with errors.Is", func(t *testing.T) {
+		if !errors.Is(wrapped, ExitError{ExitCode: 9}) || errors.Is(wrapped, ExitError{ExitCode: 1}) {
+			t.Fatalf("unexpected match of %v", wrapped)
+		}
+	})
+
+	t.Run("returns the exit code of a wrapped exit error", func(t *testing.T) {
+		if exitCode, ok := ExitCode(wrapped); !ok || exitCode != 9 {
+			t.Fatalf("unexpected exit code: %d, %t", exitCode, ok)
+		}
+
+		if _, ok := ExitCode(errors.New("connection refused")); ok {
+			t.Fatal("expected no exit code")
+		}
+	})
+
+	t.Run("checks the exit code against several codes", func(t *testing.T) {
+		if !IsExitCode(wrapped, 2, 9) || IsExitCode(wrapped, 2) || IsExitCode(nil, 0) {
+			t.Fatalf("unexpected result for %v", wrapped)
+		}
+	})
+
+	t.Run("the message holds the exit code and the standard error", func(t *testing.T) {
+		if wrapped.Error() != "failed to create the user: exit code 9: useradd: user 'alice' already exists" {
+			t.Fatalf("unexpected message: %s", wrapped.Error())
+		}
+	})
+}
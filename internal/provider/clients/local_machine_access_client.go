@@ -3,10 +3,12 @@ package clients
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	dockerClient "github.com/docker/docker/client"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -20,14 +22,24 @@ func CreateLocalMachineAccessClient() (MachineAccessClient, error) {
 	return &localMachineAccessClient{}, nil
 }
 
-func (localClient *localMachineAccessClient) RunCommand(_ context.Context, command string) (string, error) {
-	cmd := exec.Command("sh", "-c", command)
+func (localClient *localMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
 
 	var out bytes.Buffer
 
-	cmd.Stdout = &out
+	var flush func()
+
+	cmd.Stdout, cmd.Stderr, flush = streamWriters(ctx, &out, io.Discard)
 
 	err := cmd.Run()
+
+	flush()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), ExitError{ExitCode: exitErr.ExitCode(), Command: command, Stdout: out.String()}
+	}
+
 	if err != nil {
 		return "", fmt.Errorf("failed to run command %s: %w", command, err)
 	}
@@ -35,6 +47,43 @@ func (localClient *localMachineAccessClient) RunCommand(_ context.Context, comma
 	return out.String(), nil
 }
 
+func (localClient *localMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+
+	var stdout, stderr bytes.Buffer
+
+	var flush func()
+
+	cmd.Stdout, cmd.Stderr, flush = streamWriters(ctx, &stdout, &stderr)
+
+	err := cmd.Run()
+
+	flush()
+
+	result := CommandResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+
+		return result, ExitError{
+			ExitCode: result.ExitCode,
+			Command:  command,
+			Stdout:   result.Stdout,
+			Stderr:   result.Stderr,
+		}
+	}
+
+	if err != nil {
+		return result, fmt.Errorf("failed to run command %s: %w", command, err)
+	}
+
+	return result, nil
+}
+
 func (localClient *localMachineAccessClient) WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error {
 	tflog.Debug(ctx, "Writing file content to temp file")
 
@@ -51,23 +100,31 @@ func (localClient *localMachineAccessClient) WriteFile(ctx context.Context, path
 		return fmt.Errorf("failed to write to temp file: %w", err)
 	}
 
+	// A symlink is resolved first, so that the file it points to is replaced instead of the symlink itself
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		path, err = filepath.EvalSymlinks(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve symlink: %w", err)
+		}
+	}
+
 	tflog.Debug(ctx, "Moving file to actual path "+path)
 
-	_, err = localClient.RunCommand(ctx, "mv "+tmpFile.Name()+" "+path)
+	_, err = localClient.RunCommand(ctx, ShellCommand("mv", tmpFile.Name(), path))
 	if err != nil {
 		return err
 	}
 
 	tflog.Debug(ctx, "Setting owner and group of the file")
 
-	_, err = localClient.RunCommand(ctx, "chown "+owner+":"+group+" "+path)
+	_, err = localClient.RunCommand(ctx, ShellCommand("chown", owner+":"+group, path))
 	if err != nil {
 		return err
 	}
 
 	tflog.Debug(ctx, "Setting mode of the file")
 
-	_, err = localClient.RunCommand(ctx, "chmod "+mode+" "+path)
+	_, err = localClient.RunCommand(ctx, ShellCommand("chmod", mode, path))
 	if err != nil {
 		return err
 	}
@@ -75,6 +132,14 @@ func (localClient *localMachineAccessClient) WriteFile(ctx context.Context, path
 	return nil
 }
 
+func (localClient *localMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	return readFile(ctx, localClient.Run, path, followSymlinks, false)
+}
+
+func (localClient *localMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	return statFile(ctx, localClient.Run, path, followSymlinks)
+}
+
 func (localClient *localMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
 	tflog.Debug(ctx, fmt.Sprintf("Copying file from %s to %s", localPath, remotePath))
 
@@ -98,9 +163,14 @@ func (localClient *localMachineAccessClient) CopyFile(ctx context.Context, local
 	return nil
 }
 
-func (localClient *localMachineAccessClient) GetDockerClient(_ context.Context) (*dockerClient.Client, error) {
-	// For local machine, create a standard Docker client
-	dockerClient, err := dockerClient.NewClientWithOpts(dockerClient.FromEnv, dockerClient.WithAPIVersionNegotiation())
+func (localClient *localMachineAccessClient) GetDockerClient(ctx context.Context) (*dockerClient.Client, error) {
+	// For local machine, create a standard Docker client, connected to the selected socket when it is not docker's
+	opts := []dockerClient.Opt{dockerClient.FromEnv, dockerClient.WithAPIVersionNegotiation()}
+	if socket := DockerSocketFrom(ctx); socket != DockerSocket {
+		opts = append(opts, dockerClient.WithHost("unix://"+socket))
+	}
+
+	dockerClient, err := dockerClient.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %v", err)
 	}
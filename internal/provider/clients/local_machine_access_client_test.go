@@ -1,8 +1,10 @@
 package clients
 
 import (
+	"errors"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strconv"
 	"syscall"
 	"testing"
@@ -28,6 +30,25 @@ func TesLocalRunCommand(t *testing.T) {
 	})
 }
 
+func TestLocalRunExitError(t *testing.T) {
+	// Arrange
+	client, err := CreateLocalMachineAccessClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	_, err = client.Run(t.Context(), "echo out && echo err >&2 && exit 3")
+
+	// Assert
+	var exitErr ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected an ExitError, got: %v", err)
+	}
+
+	assert.Equal(t, ExitError{ExitCode: 3, Command: "echo out && echo err >&2 && exit 3", Stdout: "out\n", Stderr: "err\n"}, exitErr)
+}
+
 func TestWriteFile(t *testing.T) {
 	// Arrange
 	client, err := CreateLocalMachineAccessClient()
@@ -65,3 +86,104 @@ func TestWriteFile(t *testing.T) {
 		os.Remove(testFilePath)
 	})
 }
+
+func TestReadFileAndStat(t *testing.T) {
+	// Arrange
+	client, err := CreateLocalMachineAccessClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	filePath := filepath.Join(dir, "file")
+	linkPath := filepath.Join(dir, "link")
+
+	if err := os.WriteFile(filePath, []byte("test content"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filePath, linkPath); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("read file through a symlink", func(t *testing.T) {
+		// Act
+		content, err := client.ReadFile(t.Context(), linkPath, true)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "test content", content)
+	})
+
+	t.Run("read symlink without following it", func(t *testing.T) {
+		// Act
+		_, err := client.ReadFile(t.Context(), linkPath, false)
+
+		// Assert
+		assert.Error(t, err)
+		assert.False(t, IsFileNotFound(err))
+	})
+
+	t.Run("read missing file", func(t *testing.T) {
+		// Act
+		_, err := client.ReadFile(t.Context(), filepath.Join(dir, "missing"), true)
+
+		// Assert
+		assert.True(t, IsFileNotFound(err))
+	})
+
+	t.Run("stat file through a symlink", func(t *testing.T) {
+		// Act
+		info, err := client.Stat(t.Context(), linkPath, true)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "file", info.Type)
+		assert.Equal(t, int64(12), info.Size)
+		assert.Equal(t, "640", info.Mode)
+		assert.Equal(t, int64(os.Getuid()), info.Owner)
+		assert.False(t, info.ModTime.IsZero())
+		assert.Empty(t, info.LinkTarget)
+	})
+
+	t.Run("stat symlink without following it", func(t *testing.T) {
+		// Act
+		info, err := client.Stat(t.Context(), linkPath, false)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "symlink", info.Type)
+		assert.Equal(t, filePath, info.LinkTarget)
+	})
+
+	t.Run("stat missing file", func(t *testing.T) {
+		// Act
+		_, err := client.Stat(t.Context(), filepath.Join(dir, "missing"), false)
+
+		// Assert
+		assert.True(t, IsFileNotFound(err))
+	})
+
+	t.Run("write file through a symlink preserves it", func(t *testing.T) {
+		// Arrange
+		user, err := user.Current()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		err = client.WriteFile(t.Context(), linkPath, "0644", user.Uid, user.Gid, "new content")
+
+		// Assert
+		assert.NoError(t, err)
+
+		target, err := os.Readlink(linkPath)
+		assert.NoError(t, err)
+		assert.Equal(t, filePath, target)
+
+		content, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		assert.Equal(t, "new content", string(content))
+	})
+}
@@ -0,0 +1,196 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+
+	dockerClient "github.com/docker/docker/client"
+)
+
+// MockResponse is the result of a command run on a MockMachineAccessClient.
+type MockResponse struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Err is returned instead of running the command, e.g. to simulate a connection failure.
+	Err error
+	// Once removes the response after its first use, so that the following commands get the next matching response,
+	// e.g. to simulate a machine whose state changes.
+	Once bool
+}
+
+type mockCommand struct {
+	pattern  *regexp.Regexp
+	response MockResponse
+}
+
+// MockMachineAccessClient is a MachineAccessClient that answers commands from a command to response mapping and keeps
+// files in memory, so that resources can be tested without a Docker SSH server. Commands without a response succeed
+// without output.
+type MockMachineAccessClient struct {
+	commands []mockCommand
+	// Commands are the commands run so far, in order.
+	Commands []string
+	// Files are the files written so far, or set up to be read, by path.
+	Files map[string]string
+	// FileInfos are the metadata of the files returned by Stat, by path.
+	FileInfos map[string]FileInfo
+
+	mutex sync.Mutex
+}
+
+var _ MachineAccessClient = &MockMachineAccessClient{}
+
+// NewMockMachineAccessClient creates a mock client without any response or file.
+func NewMockMachineAccessClient() *MockMachineAccessClient {
+	return &MockMachineAccessClient{
+		Files:     map[string]string{},
+		FileInfos: map[string]FileInfo{},
+	}
+}
+
+// On sets the response of the command. The command must match exactly.
+func (mock *MockMachineAccessClient) On(command string, response MockResponse) *MockMachineAccessClient {
+	return mock.OnMatch("^"+regexp.QuoteMeta(command)+"$", response)
+}
+
+// OnMatch sets the response of the commands matching the regular expression. The first matching response, in the order
+// they were set, is used.
+func (mock *MockMachineAccessClient) OnMatch(pattern string, response MockResponse) *MockMachineAccessClient {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.commands = append(mock.commands, mockCommand{pattern: regexp.MustCompile(pattern), response: response})
+
+	return mock
+}
+
+// WithFile sets up a file to be returned by ReadFile and Stat.
+func (mock *MockMachineAccessClient) WithFile(path string, content string, info FileInfo) *MockMachineAccessClient {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	if info.Type == "" {
+		info.Type = "file"
+	}
+
+	info.Size = int64(len(content))
+	mock.Files[path] = content
+	mock.FileInfos[path] = info
+
+	return mock
+}
+
+func (mock *MockMachineAccessClient) respond(command string) (CommandResult, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.Commands = append(mock.Commands, command)
+
+	for i, c := range mock.commands {
+		if !c.pattern.MatchString(command) {
+			continue
+		}
+
+		if c.response.Once {
+			mock.commands = slices.Delete(mock.commands, i, i+1)
+		}
+
+		if c.response.Err != nil {
+			return CommandResult{}, c.response.Err
+		}
+
+		result := CommandResult{Stdout: c.response.Stdout, Stderr: c.response.Stderr, ExitCode: c.response.ExitCode}
+		if result.ExitCode != 0 {
+			return result, ExitError{ExitCode: result.ExitCode, Command: command, Stdout: result.Stdout, Stderr: result.Stderr}
+		}
+
+		return result, nil
+	}
+
+	return CommandResult{}, nil
+}
+
+func (mock *MockMachineAccessClient) RunCommand(_ context.Context, command string) (string, error) {
+	result, err := mock.respond(command)
+
+	return result.Stdout + result.Stderr, err
+}
+
+func (mock *MockMachineAccessClient) Run(_ context.Context, command string) (CommandResult, error) {
+	return mock.respond(command)
+}
+
+func (mock *MockMachineAccessClient) WriteFile(_ context.Context, path string, mode string, owner string, group string, content string) error {
+	ownerID, err := strconv.ParseInt(owner, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid owner %s: %w", owner, err)
+	}
+
+	groupID, err := strconv.ParseInt(group, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid group %s: %w", group, err)
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.Files[path] = content
+	mock.FileInfos[path] = FileInfo{
+		Type:  "file",
+		Size:  int64(len(content)),
+		Mode:  mode,
+		Owner: ownerID,
+		Group: groupID,
+	}
+
+	return nil
+}
+
+func (mock *MockMachineAccessClient) ReadFile(_ context.Context, path string, _ bool) (string, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	content, ok := mock.Files[path]
+	if !ok {
+		return "", FileNotFoundError{Path: path}
+	}
+
+	return content, nil
+}
+
+func (mock *MockMachineAccessClient) Stat(_ context.Context, path string, _ bool) (FileInfo, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	info, ok := mock.FileInfos[path]
+	if !ok {
+		return FileInfo{}, FileNotFoundError{Path: path}
+	}
+
+	return info, nil
+}
+
+func (mock *MockMachineAccessClient) CopyFile(_ context.Context, localPath string, remotePath string) error {
+	content, err := os.ReadFile(localPath) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to read local file %s: %w", localPath, err)
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.Files[remotePath] = string(content)
+	mock.FileInfos[remotePath] = FileInfo{Type: "file", Size: int64(len(content)), Mode: "644"}
+
+	return nil
+}
+
+func (mock *MockMachineAccessClient) GetDockerClient(_ context.Context) (*dockerClient.Client, error) {
+	return nil, fmt.Errorf("docker is not supported by the mock client")
+}
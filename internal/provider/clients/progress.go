@@ -0,0 +1,54 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// NewProgressReader wraps the reader of a long transfer so that its progress is logged at INFO level every 10%. total
+// is the size of the whole transfer and done the number of bytes already transferred before reading from reader, e.g.
+// when resuming a transfer.
+func NewProgressReader(ctx context.Context, reader io.Reader, description string, total int64, done int64) io.Reader {
+	return &progressReader{
+		ctx:         ctx,
+		reader:      reader,
+		description: description,
+		total:       total,
+		done:        done,
+		lastPercent: percentOf(done, total),
+	}
+}
+
+type progressReader struct {
+	ctx         context.Context
+	reader      io.Reader
+	description string
+	total       int64
+	done        int64
+	lastPercent int64
+}
+
+func (p *progressReader) Read(buffer []byte) (int, error) {
+	n, err := p.reader.Read(buffer)
+	p.done += int64(n)
+
+	percent := percentOf(p.done, p.total)
+	if percent/10 > p.lastPercent/10 || (err == io.EOF && percent != p.lastPercent) {
+		p.lastPercent = percent
+
+		tflog.Info(p.ctx, fmt.Sprintf("%s: %d%% (%d/%d bytes)", p.description, percent, p.done, p.total))
+	}
+
+	return n, err
+}
+
+func percentOf(done int64, total int64) int64 {
+	if total <= 0 {
+		return 100
+	}
+
+	return done * 100 / total
+}
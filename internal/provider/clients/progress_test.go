@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressReader(t *testing.T) {
+	t.Run("progress is logged every 10 percent", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+		reader := NewProgressReader(ctx, strings.NewReader(strings.Repeat("a", 100)), "Uploading", 100, 0)
+
+		// Act
+		content, err := io.ReadAll(io.LimitReader(reader, 25))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, content, 25)
+
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, entries)
+		assert.Equal(t, "Uploading: 25% (25/100 bytes)", entries[len(entries)-1]["@message"])
+	})
+
+	t.Run("resumed transfer starts from the bytes already done", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+		reader := NewProgressReader(ctx, strings.NewReader(strings.Repeat("a", 50)), "Uploading", 100, 50)
+
+		// Act
+		_, err := io.ReadAll(reader)
+
+		// Assert
+		assert.NoError(t, err)
+
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, entries)
+		assert.Equal(t, "Uploading: 100% (100/100 bytes)", entries[len(entries)-1]["@message"])
+	})
+}
@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+type sensitiveValuesKey struct{}
+
+// WithSensitiveValues marks the context so that the given values are masked in the logs of the commands run with it,
+// and in their streamed output, e.g. the passphrases or passwords that resources pass on the command line. The quoted
+// forms of the values are masked first, as the commands embed them quoted.
+func WithSensitiveValues(ctx context.Context, values ...string) context.Context {
+	masked := []string{}
+
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+
+		masked = append(masked, ShellQuote(value), PowershellQuote(value), value)
+	}
+
+	if len(masked) == 0 {
+		return ctx
+	}
+
+	previous, _ := ctx.Value(sensitiveValuesKey{}).([]string)
+	ctx = context.WithValue(ctx, sensitiveValuesKey{}, append(slices.Clone(previous), masked...))
+
+	return tflog.MaskLogStrings(ctx, masked...)
+}
+
+// hasSensitiveValues returns whether values were marked sensitive in the context, e.g. so that a command embedding them
+// in an encoded form, which cannot be masked value by value, is masked as a whole.
+func hasSensitiveValues(ctx context.Context) bool {
+	values, _ := ctx.Value(sensitiveValuesKey{}).([]string)
+	return len(values) > 0
+}
+
+// redactSensitiveValues masks the values marked sensitive in the context, for the text that is not logged with tflog.
+func redactSensitiveValues(ctx context.Context, text string) string {
+	values, _ := ctx.Value(sensitiveValuesKey{}).([]string)
+
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, "***")
+	}
+
+	return text
+}
@@ -0,0 +1,61 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSensitiveValues(t *testing.T) {
+	t.Run("values are masked in the logged commands", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := WithSensitiveValues(tflogtest.RootLogger(context.Background(), &logs), "s3cr3t", "it's")
+
+		// Act
+		tflog.Debug(ctx, "Running command: "+ShellCommand("ssh-keygen", "-p", "-P", "s3cr3t", "-N", "it's"))
+
+		// Assert
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "Running command: ssh-keygen -p -P *** -N ***", entries[0]["@message"])
+	})
+
+	t.Run("streamed output is masked", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := WithSensitiveValues(WithStreamedOutput(tflogtest.RootLogger(context.Background(), &logs)), "s3cr3t")
+
+		stdout, _, flush := streamWriters(ctx, &bytes.Buffer{}, &bytes.Buffer{})
+
+		// Act
+		_, err := stdout.Write([]byte("password: s3cr3t\n"))
+		flush()
+
+		// Assert
+		assert.NoError(t, err)
+
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "password: ***", entries[0]["@message"])
+	})
+
+	t.Run("empty values are not masked", func(t *testing.T) {
+		// Arrange
+		ctx := context.Background()
+
+		// Act
+		masked := WithSensitiveValues(ctx, "")
+
+		// Assert
+		assert.Equal(t, ctx, masked)
+	})
+}
@@ -0,0 +1,32 @@
+package clients
+
+import (
+	"regexp"
+	"strings"
+)
+
+// shellSafeWord matches the words that a POSIX shell passes as they are, without expansion nor splitting.
+var shellSafeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote quotes the value so that it is passed as a single word by a POSIX shell.
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// ShellCommand returns the command line running the program with the given arguments, in the manner of exec.Command.
+// Each argument is passed as a single word, whatever quotes, spaces, $ or backticks it contains, so that values coming
+// from the configuration can neither break the command nor inject another one. Arguments that need no quoting are
+// left as they are, so that the command stays readable in the logs.
+func ShellCommand(program string, args ...string) string {
+	words := make([]string, 0, len(args)+1)
+
+	for _, word := range append([]string{program}, args...) {
+		if shellSafeWord.MatchString(word) {
+			words = append(words, word)
+		} else {
+			words = append(words, ShellQuote(word))
+		}
+	}
+
+	return strings.Join(words, " ")
+}
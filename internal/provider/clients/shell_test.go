@@ -0,0 +1,38 @@
+package clients
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellCommand(t *testing.T) {
+	t.Run("safe arguments are left as they are", func(t *testing.T) {
+		// Act
+		command := ShellCommand("install", "-d", "-m", "0755", "/srv/data")
+
+		// Assert
+		assert.Equal(t, "install -d -m 0755 /srv/data", command)
+	})
+
+	t.Run("other arguments are quoted", func(t *testing.T) {
+		// Act
+		command := ShellCommand("rm", "-f", "/tmp/my file", "")
+
+		// Assert
+		assert.Equal(t, "rm -f '/tmp/my file' ''", command)
+	})
+
+	t.Run("arguments survive the shell", func(t *testing.T) {
+		// Arrange
+		args := []string{"a b", `it's`, "$HOME", "`id`", "$(id)", `"quoted"`, "semi;colon", "new\nline", "*", ""}
+
+		// Act
+		out, err := exec.Command("sh", "-c", ShellCommand("printf", append([]string{"[%s]"}, args...)...)).CombinedOutput() // #nosec G204 - this is only used for testing
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "[a b][it's][$HOME][`id`][$(id)][\"quoted\"][semi;colon][new\nline][*][]", string(out))
+	})
+}
@@ -1,15 +1,22 @@
 package clients
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"os"
 
+	"github.com/avast/retry-go"
 	scp "github.com/bramvdbogaerde/go-scp"
 	dockerClient "github.com/docker/docker/client"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -25,14 +32,50 @@ type sshMachineAccessClientBuilder struct {
 
 	agent          *string
 	privateKeyPath *string
+	privateKey     *string
+	passphrase     *string
+	password       *string
+
+	timeout     time.Duration
+	maxRetries  uint
+	retryDelay  time.Duration
+	maxSessions uint
+
+	commandTimeout time.Duration
+
+	keepaliveInterval time.Duration
+	keepaliveMaxCount uint
+
+	become Become
+
+	osFamily string
 }
 
+// defaultMaxSessions matches the default MaxSessions of OpenSSH's sshd.
+const defaultMaxSessions = 10
+
+// The keepalive requests are sent often enough for the NAT gateways and firewalls, which commonly drop idle
+// connections after a few minutes, to keep the connection open while a long command prints nothing.
+const (
+	defaultKeepaliveInterval = 30 * time.Second
+	defaultKeepaliveMaxCount = 3
+)
+
+// ErrPrivateKeyPassphraseMissing is returned when the private key is encrypted and no passphrase was given.
+var ErrPrivateKeyPassphraseMissing = fmt.Errorf("private key is protected by a passphrase but no passphrase was provided")
+
 // CreateSSHMachineAccessClientBuilder creates an SSH machine access client builder
 func CreateSSHMachineAccessClientBuilder(user string, host string, port int) *sshMachineAccessClientBuilder {
 	return &sshMachineAccessClientBuilder{
-		user: user,
-		host: host,
-		port: port,
+		user:        user,
+		host:        host,
+		port:        port,
+		timeout:     30 * time.Second,
+		retryDelay:  5 * time.Second,
+		maxSessions: defaultMaxSessions,
+
+		keepaliveInterval: defaultKeepaliveInterval,
+		keepaliveMaxCount: defaultKeepaliveMaxCount,
 	}
 }
 
@@ -46,11 +89,101 @@ func (builder *sshMachineAccessClientBuilder) WithPrivateKeyPath(privateKeyPath
 	return builder
 }
 
+// WithPrivateKey uses the given PEM encoded private key, so that the key does not have to be written to disk.
+func (builder *sshMachineAccessClientBuilder) WithPrivateKey(privateKey string) *sshMachineAccessClientBuilder {
+	builder.privateKey = &privateKey
+	return builder
+}
+
+// WithPrivateKeyPassphrase sets the passphrase used to decrypt the private key, whether given by path or by content.
+func (builder *sshMachineAccessClientBuilder) WithPrivateKeyPassphrase(passphrase string) *sshMachineAccessClientBuilder {
+	builder.passphrase = &passphrase
+	return builder
+}
+
+func (builder *sshMachineAccessClientBuilder) WithPassword(password string) *sshMachineAccessClientBuilder {
+	builder.password = &password
+	return builder
+}
+
+// WithTimeout sets the maximum amount of time to wait for the TCP connection and the SSH handshake.
+func (builder *sshMachineAccessClientBuilder) WithTimeout(timeout time.Duration) *sshMachineAccessClientBuilder {
+	builder.timeout = timeout
+	return builder
+}
+
+// WithRetries retries dialing the host up to maxRetries times, with an exponential backoff starting at delay. This is
+// useful when the host is still booting.
+func (builder *sshMachineAccessClientBuilder) WithRetries(maxRetries uint, delay time.Duration) *sshMachineAccessClientBuilder {
+	builder.maxRetries = maxRetries
+	builder.retryDelay = delay
+
+	return builder
+}
+
+// WithMaxSessions limits the number of SSH sessions that are open at the same time over the connection. Commands run
+// in parallel up to this limit, and wait for a free session beyond it. It must not exceed the MaxSessions setting of
+// the remote sshd.
+func (builder *sshMachineAccessClientBuilder) WithMaxSessions(maxSessions uint) *sshMachineAccessClientBuilder {
+	builder.maxSessions = maxSessions
+	return builder
+}
+
+// WithCommandTimeout kills commands that run for longer than the given duration. A zero duration disables the timeout.
+func (builder *sshMachineAccessClientBuilder) WithCommandTimeout(timeout time.Duration) *sshMachineAccessClientBuilder {
+	builder.commandTimeout = timeout
+	return builder
+}
+
+// WithKeepalive sends a keepalive request over the connection every interval, like ServerAliveInterval of OpenSSH, so
+// that the connection is not dropped while idle. The connection is closed when maxCount requests in a row get no
+// answer, failing the commands still running with an error telling that the server stopped responding. A zero
+// interval disables the keepalive requests.
+func (builder *sshMachineAccessClientBuilder) WithKeepalive(interval time.Duration, maxCount uint) *sshMachineAccessClientBuilder {
+	builder.keepaliveInterval = interval
+	builder.keepaliveMaxCount = maxCount
+
+	return builder
+}
+
+// ForHost returns a copy of the builder connecting to another host with the same options.
+func (builder *sshMachineAccessClientBuilder) ForHost(user string, host string, port int) *sshMachineAccessClientBuilder {
+	copied := *builder
+	copied.user = user
+	copied.host = host
+	copied.port = port
+
+	return &copied
+}
+
+// WithBecome sets how WriteFile elevates its privileges to write files owned by other users.
+func (builder *sshMachineAccessClientBuilder) WithBecome(become Become) *sshMachineAccessClientBuilder {
+	builder.become = become
+	return builder
+}
+
+// WithOSFamily sets the OS family of the host, either OSFamilyLinux (the default) or OSFamilyWindows. Commands are run
+// with PowerShell on Windows hosts.
+func (builder *sshMachineAccessClientBuilder) WithOSFamily(osFamily string) *sshMachineAccessClientBuilder {
+	builder.osFamily = osFamily
+	return builder
+}
+
 func (builder *sshMachineAccessClientBuilder) buildAuthMethod() ([]ssh.AuthMethod, error) {
-	if builder.agent != nil && builder.privateKeyPath != nil {
-		return nil, fmt.Errorf("only one of agent or privateKeyPath can be set")
+	keySources := 0
+
+	for _, source := range []*string{builder.agent, builder.privateKeyPath, builder.privateKey} {
+		if source != nil {
+			keySources++
+		}
+	}
+
+	if keySources > 1 {
+		return nil, fmt.Errorf("only one of agent, privateKeyPath or privateKey can be set")
 	}
 
+	authMethods := []ssh.AuthMethod{}
+
 	if builder.agent != nil {
 		sshAgent, err := net.Dial("unix", *builder.agent)
 		if err != nil {
@@ -64,23 +197,55 @@ func (builder *sshMachineAccessClientBuilder) buildAuthMethod() ([]ssh.AuthMetho
 			return nil, errors.Wrap(err, "couldn't get signers from ssh-agent")
 		}
 
-		return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, nil
+		authMethods = append(authMethods, ssh.PublicKeys(signers...))
 	}
 
 	if builder.privateKeyPath != nil {
-		publicKeyFile, err := publicKeyFile(*builder.privateKeyPath)
+		publicKeyFile, err := publicKeyFile(*builder.privateKeyPath, builder.passphrase)
 		if err != nil {
 			return nil, errors.Wrap(err, "couldn't load public key file")
 		}
 
-		return []ssh.AuthMethod{publicKeyFile}, nil
+		authMethods = append(authMethods, publicKeyFile)
+	}
+
+	if builder.privateKey != nil {
+		signer, err := privateKeySigner([]byte(*builder.privateKey), builder.passphrase)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't load private key")
+		}
+
+		authMethods = append(authMethods, signer)
+	}
+
+	// password authentication is tried after the keys, so that a machine can be bootstrapped with a password and
+	// keep working once the first key is deployed
+	if builder.password != nil {
+		password := *builder.password
+
+		authMethods = append(authMethods,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(_ string, _ string, questions []string, _ []bool) ([]string, error) {
+				// answer every prompt with the password, which is what servers using PAM expect
+				answers := make([]string, len(questions))
+				for i := range questions {
+					answers[i] = password
+				}
+
+				return answers, nil
+			}),
+		)
+	}
+
+	if len(authMethods) == 0 {
+		return nil, fmt.Errorf("one of agent, privateKeyPath, privateKey or password must be set")
 	}
 
-	return nil, fmt.Errorf("either agent or privateKeyPath must be set")
+	return authMethods, nil
 }
 
-// CreateSSHMachineAccessClient creates a new ssh machine access client.
-func (builder *sshMachineAccessClientBuilder) Build(ctx context.Context) (MachineAccessClient, error) {
+// CreateSSHMachineAccessClient creates a new ssh machine access client. The connection is only established on first use.
+func (builder *sshMachineAccessClientBuilder) Build(_ context.Context) (MachineAccessClient, error) {
 	auth, err := builder.buildAuthMethod()
 	if err != nil {
 		return nil, err
@@ -90,23 +255,50 @@ func (builder *sshMachineAccessClientBuilder) Build(ctx context.Context) (Machin
 		User:            builder.user,
 		Auth:            auth,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // #nosec G106 - todo: make this configurable
+		Timeout:         builder.timeout,
 	}
 
-	addr := fmt.Sprintf("%v:%v", builder.host, builder.port)
-	tflog.Debug(ctx, "Dialing "+addr)
+	if err := builder.become.Validate(); err != nil {
+		return nil, err
+	}
 
-	conn, err := ssh.Dial("tcp", addr, sshConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	if builder.maxSessions == 0 {
+		return nil, fmt.Errorf("maxSessions must be at least 1")
+	}
+
+	if builder.keepaliveInterval > 0 && builder.keepaliveMaxCount == 0 {
+		return nil, fmt.Errorf("the keepalive max count must be at least 1")
+	}
+
+	if builder.osFamily != "" && builder.osFamily != OSFamilyLinux && builder.osFamily != OSFamilyWindows {
+		return nil, fmt.Errorf("unsupported OS family '%s', expected '%s' or '%s'", builder.osFamily, OSFamilyLinux, OSFamilyWindows)
 	}
 
-	return &sshMachineAccessClient{
-		Client:             conn,
+	client := &sshMachineAccessClient{
+		addr:               fmt.Sprintf("%v:%v", builder.host, builder.port),
+		sshConfig:          sshConfig,
+		maxRetries:         builder.maxRetries,
+		retryDelay:         builder.retryDelay,
+		clientLocker:       &sync.Mutex{},
+		sessions:           make(chan struct{}, builder.maxSessions),
+		commandTimeout:     builder.commandTimeout,
+		keepaliveInterval:  builder.keepaliveInterval,
+		keepaliveMaxCount:  builder.keepaliveMaxCount,
+		keepaliveFailures:  map[*ssh.Client]error{},
+		become:             builder.become,
 		dockerClientLocker: &sync.Mutex{},
-	}, nil
+		dockerClients:      map[string]*dockerClient.Client{},
+		dockerClientErrs:   map[string]error{},
+	}
+
+	if builder.osFamily == OSFamilyWindows {
+		return &windowsMachineAccessClient{sshClient: client}, nil
+	}
+
+	return client, nil
 }
 
-func publicKeyFile(file string) (ssh.AuthMethod, error) {
+func publicKeyFile(file string, passphrase *string) (ssh.AuthMethod, error) {
 	// validate that the path is absolute
 	if !filepath.IsAbs(file) {
 		return nil, fmt.Errorf("public key file path must be absolute")
@@ -117,8 +309,26 @@ func publicKeyFile(file string) (ssh.AuthMethod, error) {
 		return nil, fmt.Errorf("failed to read publicKeyFile: %w", err)
 	}
 
+	return privateKeySigner(buffer, passphrase)
+}
+
+func privateKeySigner(buffer []byte, passphrase *string) (ssh.AuthMethod, error) {
+	if passphrase != nil {
+		key, err := ssh.ParsePrivateKeyWithPassphrase(buffer, []byte(*passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse privateKey with passphrase: %w", err)
+		}
+
+		return ssh.PublicKeys(key), nil
+	}
+
 	key, err := ssh.ParsePrivateKey(buffer)
 	if err != nil {
+		var passphraseMissingErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseMissingErr) {
+			return nil, ErrPrivateKeyPassphraseMissing
+		}
+
 		return nil, fmt.Errorf("failed to parse privateKey: %w", err)
 	}
 
@@ -126,126 +336,501 @@ func publicKeyFile(file string) (ssh.AuthMethod, error) {
 }
 
 type sshMachineAccessClient struct {
-	*ssh.Client
-	dockerClient       *dockerClient.Client
+	addr       string
+	sshConfig  *ssh.ClientConfig
+	maxRetries uint
+	retryDelay time.Duration
+
+	// client is dialed on first use and re-dialed whenever it is found to be dead, see getClient.
+	client       *ssh.Client
+	clientLocker sync.Locker
+
+	// sessions holds one token per open SSH session, see acquireSession.
+	sessions chan struct{}
+
+	commandTimeout time.Duration
+	become         Become
+
+	keepaliveInterval time.Duration
+	keepaliveMaxCount uint
+
+	// keepaliveFailures holds the error of the connections closed as the server stopped answering the keepalive
+	// requests. It has its own mutex, as clientLocker is held while a connection is health-checked.
+	keepaliveFailures      map[*ssh.Client]error
+	keepaliveFailuresMutex sync.Mutex
+
+	// dockerClients and dockerClientErrs hold the Docker client of each socket, e.g. of docker and of podman
+	dockerClients      map[string]*dockerClient.Client
 	dockerClientLocker sync.Locker
-	dockerClientErr    error
+	dockerClientErrs   map[string]error
+}
+
+// getClient returns a live SSH connection. The connection is established lazily and health-checked with a keepalive
+// request before being handed out, so that a connection dropped mid-apply is transparently re-established.
+func (sshClient *sshMachineAccessClient) getClient(ctx context.Context) (*ssh.Client, error) {
+	sshClient.clientLocker.Lock()
+	defer sshClient.clientLocker.Unlock()
+
+	if sshClient.client != nil {
+		_, _, err := sshClient.client.SendRequest("keepalive@openssh.com", true, nil)
+		if err == nil {
+			return sshClient.client, nil
+		}
+
+		tflog.Warn(ctx, fmt.Sprintf("SSH connection to %s is dead, reconnecting: %v", sshClient.addr, err))
+
+		_ = sshClient.client.Close()
+		sshClient.client = nil
+	}
+
+	var conn *ssh.Client
+
+	err := retry.Do(func() error {
+		tflog.Debug(ctx, "Dialing "+sshClient.addr)
+
+		var dialErr error
+
+		conn, dialErr = ssh.Dial("tcp", sshClient.addr, sshClient.sshConfig)
+
+		return dialErr
+	},
+		retry.Attempts(sshClient.maxRetries+1),
+		retry.Delay(sshClient.retryDelay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
+		retry.OnRetry(func(attempt uint, err error) {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to dial %s (attempt %d/%d): %v", sshClient.addr, attempt+1, sshClient.maxRetries+1, err))
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", sshClient.addr, err)
+	}
+
+	sshClient.client = conn
+
+	if sshClient.keepaliveInterval > 0 {
+		go sshClient.keepalive(ctx, conn)
+	}
+
+	return conn, nil
+}
+
+// keepalive sends a keepalive request over the connection every keepaliveInterval until it is closed, and closes it
+// when keepaliveMaxCount requests in a row got no answer within the interval.
+func (sshClient *sshMachineAccessClient) keepalive(ctx context.Context, conn *ssh.Client) {
+	// The context of the command that dialed the connection may end before the connection does, only its logger is kept
+	ctx = context.WithoutCancel(ctx)
+
+	closed := make(chan struct{})
+
+	go func() {
+		_ = conn.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(sshClient.keepaliveInterval)
+	defer ticker.Stop()
+
+	missed := uint(0)
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		answered := make(chan error, 1)
+
+		go func() {
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			answered <- err
+		}()
+
+		select {
+		case <-closed:
+			return
+		case err := <-answered:
+			if err != nil {
+				// The connection is already broken, getClient reconnects on the next command
+				return
+			}
+
+			missed = 0
+
+			continue
+		case <-time.After(sshClient.keepaliveInterval):
+		}
+
+		missed++
+
+		tflog.Warn(ctx, fmt.Sprintf("SSH server %s did not answer the keepalive request (%d/%d)", sshClient.addr, missed, sshClient.keepaliveMaxCount))
+
+		if missed < sshClient.keepaliveMaxCount {
+			continue
+		}
+
+		sshClient.keepaliveFailuresMutex.Lock()
+		sshClient.keepaliveFailures[conn] = fmt.Errorf("the SSH server %s stopped responding, %d keepalive requests sent every %s got no answer, "+
+			"the connection was closed", sshClient.addr, missed, sshClient.keepaliveInterval)
+		sshClient.keepaliveFailuresMutex.Unlock()
+
+		_ = conn.Close()
+
+		return
+	}
+}
+
+// keepaliveFailure returns the error of the connection closed by keepalive, or nil when it was not.
+func (sshClient *sshMachineAccessClient) keepaliveFailure(conn *ssh.Client) error {
+	sshClient.keepaliveFailuresMutex.Lock()
+	defer sshClient.keepaliveFailuresMutex.Unlock()
+
+	return sshClient.keepaliveFailures[conn]
+}
+
+// acquireSession blocks until one of the maxSessions session slots is free. The returned function releases the slot.
+func (sshClient *sshMachineAccessClient) acquireSession(ctx context.Context) (func(), error) {
+	select {
+	case sshClient.sessions <- struct{}{}:
+		return func() { <-sshClient.sessions }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to acquire ssh session: %w", ctx.Err())
+	}
 }
 
 func (sshClient *sshMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
-	session, err := sshClient.NewSession()
+	var out syncBuffer
+
+	err := sshClient.run(ctx, command, nil, &out, &out)
+
+	var exitErr ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stdout = out.String()
+
+		return out.String(), exitErr
+	}
+
+	return out.String(), err
+}
+
+func (sshClient *sshMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	var stdout, stderr bytes.Buffer
+
+	err := sshClient.run(ctx, command, nil, &stdout, &stderr)
+
+	result := CommandResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	var exitErr ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode
+		exitErr.Stdout = result.Stdout
+		exitErr.Stderr = result.Stderr
+
+		return result, exitErr
+	}
+
+	return result, err
+}
+
+// run executes the command in a new session, feeding it stdin if not nil and writing its output to stdout and stderr.
+// The remote command is killed when the context is done, so that a hung command does not block forever.
+func (sshClient *sshMachineAccessClient) run(ctx context.Context, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if sshClient.commandTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, sshClient.commandTimeout)
+		defer cancel()
+	}
+
+	client, err := sshClient.getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	release, err := sshClient.acquireSession(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to create session: %w", err)
+		return err
+	}
+	defer release()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
-	tflog.Debug(ctx, "Running command: "+command)
+	tflog.Debug(ctx, "Running command: "+sshClient.become.redact(command))
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Signal(ssh.SIGKILL)
+			_ = session.Close()
+		case <-done:
+		}
+	}()
+
+	var flush func()
+
+	session.Stdout, session.Stderr, flush = streamWriters(ctx, stdout, stderr)
+
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+
+	err = session.Run(command)
+
+	flush()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("command was cancelled: %w", ctx.Err())
+	}
 
-	out, err := session.CombinedOutput(command)
 	if err != nil {
+		if keepaliveErr := sshClient.keepaliveFailure(client); keepaliveErr != nil {
+			return fmt.Errorf("failed to run command: %w", keepaliveErr)
+		}
+
 		if exitErr, ok := err.(*ssh.ExitError); ok {
-			return string(out), ExitError{
+			return ExitError{
 				ExitCode: exitErr.ExitStatus(),
+				Command:  sshClient.become.redact(command),
 			}
 		}
 
-		return string(out), fmt.Errorf("failed to run command: %w", err)
+		return fmt.Errorf("failed to run command: %w", err)
 	}
 
-	return string(out), nil
+	return nil
 }
 
+// WriteFile streams the content to a temp file owned by the connecting user, then copies it next to the destination
+// with elevated privileges and renames it, so that the destination is replaced atomically.
 func (sshClient *sshMachineAccessClient) WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error {
-	scpClient, err := scp.NewClientBySSH(sshClient.Client)
+	client, err := sshClient.getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := sshClient.RunCommand(ctx, "mktemp")
 	if err != nil {
-		return fmt.Errorf("error creating new SSH session from existing connection.\n %w", err)
+		return fmt.Errorf("failed to create remote temp file. Err=%w\nout = %s", err, out)
 	}
 
-	// write file content to tmp file from the host
-	tflog.Debug(ctx, "Writing file content to temp file")
+	remoteTmpFile := strings.TrimSpace(out)
+	defer func() {
+		_, _ = sshClient.RunCommand(ctx, "rm -f "+ShellQuote(remoteTmpFile))
+	}()
 
-	tmpFile, err := os.CreateTemp("", "tempfile")
+	scpClient, err := scp.NewClientBySSH(client)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return fmt.Errorf("error creating new SSH session from existing connection: %w", err)
 	}
-	defer os.Remove(tmpFile.Name())
 
-	tflog.Debug(ctx, "Writing content to temp file "+tmpFile.Name())
+	tflog.Debug(ctx, "Copying content to remote temp file "+remoteTmpFile)
 
-	err = os.WriteFile(tmpFile.Name(), []byte(content), 0600)
+	release, err := sshClient.acquireSession(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to write to temp file: %w", err)
+		return err
 	}
 
-	tflog.Debug(ctx, "Copying file to remote host "+path)
+	err = scpClient.Copy(ctx, strings.NewReader(content), remoteTmpFile, "0600", int64(len(content)))
 
-	// copy the file to the remote host
-	f, _ := os.Open(tmpFile.Name())
-	remoteTmpFile, _ := os.CreateTemp("", "tempfile")
+	release()
 
-	err = scpClient.CopyFromFile(ctx, *f, remoteTmpFile.Name(), "0700")
 	if err != nil {
-		return fmt.Errorf("failed to copy file to remote host: %w", err)
+		return fmt.Errorf("failed to copy content to remote host: %w", err)
 	}
 
-	// move the file to the correct location
-	_, err = sshClient.RunCommand(ctx, "sudo mv "+remoteTmpFile.Name()+" "+path)
+	tflog.Debug(ctx, "Moving remote temp file to "+path)
+
+	// A symlink is resolved first, so that the file it points to is replaced instead of the symlink itself
+	install := fmt.Sprintf("dest=%s; if [ -L \"$dest\" ]; then dest=$(readlink -f -- \"$dest\") || exit 1; fi; staging=\"$dest.tmp-%s\"; "+
+		"cp %s \"$staging\" && chown %s \"$staging\" && chmod %s \"$staging\" && mv -f \"$staging\" \"$dest\" || { rm -f \"$staging\"; exit 1; }",
+		ShellQuote(path), filepath.Base(remoteTmpFile),
+		ShellQuote(remoteTmpFile),
+		ShellQuote(owner+":"+group),
+		ShellQuote(mode),
+	)
+
+	_, err = sshClient.Run(ctx, sshClient.become.Wrap(install))
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to install file %s: %w", path, err)
 	}
 
-	// set the owner and group of the remote file
-	out, err := sshClient.RunCommand(ctx, "sudo chown "+owner+":"+group+" "+path)
+	return nil
+}
+
+func (sshClient *sshMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	return readFile(ctx, sshClient.runWithBecome, path, followSymlinks, false)
+}
+
+func (sshClient *sshMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	return statFile(ctx, sshClient.runWithBecome, path, followSymlinks)
+}
+
+func (sshClient *sshMachineAccessClient) runWithBecome(ctx context.Context, command string) (CommandResult, error) {
+	return sshClient.Run(ctx, sshClient.become.Wrap(command))
+}
+
+// CopyFile uploads the local file to a ".part" file next to the destination, verifies its sha256 checksum and renames
+// it to the destination. An upload interrupted by a dropped connection is resumed from the bytes already present in
+// the ".part" file, up to maxRetries times. The copy is skipped when the destination already has the same checksum.
+func (sshClient *sshMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
+	tflog.Debug(ctx, fmt.Sprintf("Copying file from %s to %s", localPath, remotePath))
+
+	f, err := os.Open(localPath) // #nosec G304
 	if err != nil {
-		return fmt.Errorf("failed to set owner and group: %s", out)
+		return fmt.Errorf("failed to open local file %s: %w", localPath, err)
 	}
+	defer f.Close()
 
-	// set the mode of the remote file
-	out, err = sshClient.RunCommand(ctx, "sudo chmod "+mode+" "+path)
+	info, err := f.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to set mode: %s", out)
+		return fmt.Errorf("failed to stat local file %s: %w", localPath, err)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("failed to compute sha256 of %s: %w", localPath, err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	remoteChecksum, err := sshClient.remoteSHA256(ctx, remotePath)
+	if err == nil && remoteChecksum == checksum {
+		tflog.Debug(ctx, fmt.Sprintf("%s is already up to date", remotePath))
+		return nil
+	}
+
+	partPath := remotePath + ".part"
+
+	// A ".part" file left over by the upload of another file fails the verification, in which case the upload is
+	// started again from scratch once.
+	for attempt := 0; ; attempt++ {
+		err = retry.Do(func() error {
+			return sshClient.upload(ctx, f, info.Size(), partPath)
+		},
+			retry.Attempts(sshClient.maxRetries+1),
+			retry.Delay(sshClient.retryDelay),
+			retry.DelayType(retry.BackOffDelay),
+			retry.LastErrorOnly(true),
+			retry.Context(ctx),
+			retry.OnRetry(func(n uint, err error) {
+				tflog.Warn(ctx, fmt.Sprintf("Failed to upload %s (attempt %d/%d), resuming: %v", remotePath, n+1, sshClient.maxRetries+1, err))
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy file to remote host: %w", err)
+		}
+
+		remoteChecksum, err = sshClient.remoteSHA256(ctx, partPath)
+		if err != nil {
+			return err
+		}
+
+		if remoteChecksum == checksum {
+			break
+		}
+
+		out, err := sshClient.RunCommand(ctx, "rm -f "+ShellQuote(partPath))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s. Err=%w\nout = %s", partPath, err, out)
+		}
+
+		if attempt > 0 {
+			return fmt.Errorf("checksum mismatch after copying %s to %s: expected %s, got %s", localPath, remotePath, checksum, remoteChecksum)
+		}
+
+		tflog.Warn(ctx, fmt.Sprintf("Checksum mismatch after copying %s to %s, copying it again", localPath, remotePath))
+	}
+
+	out, err := sshClient.RunCommand(ctx, fmt.Sprintf("mv -f %s %s", ShellQuote(partPath), ShellQuote(remotePath)))
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s. Err=%w\nout = %s", partPath, remotePath, err, out)
 	}
 
 	return nil
 }
 
-func (sshClient *sshMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
-	scpClient, err := scp.NewClientBySSH(sshClient.Client)
+// upload appends the part of the local file that is missing from the remote ".part" file.
+func (sshClient *sshMachineAccessClient) upload(ctx context.Context, f *os.File, size int64, partPath string) error {
+	out, err := sshClient.RunCommand(ctx, fmt.Sprintf("if [ -f %[1]s ]; then wc -c < %[1]s; else echo 0; fi", ShellQuote(partPath)))
 	if err != nil {
-		return fmt.Errorf("error creating new SSH session from existing connection: %w", err)
+		return fmt.Errorf("failed to get the size of %s. Err=%w\nout = %s", partPath, err, out)
 	}
 
-	tflog.Debug(ctx, fmt.Sprintf("Copying file from %s to %s", localPath, remotePath))
-
-	f, err := os.Open(localPath) // #nosec G304
+	offset, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
 	if err != nil {
-		return fmt.Errorf("failed to open local file %s: %w", localPath, err)
+		return fmt.Errorf("unexpected size of %s: %s", partPath, out)
+	}
+
+	redirect := ">>"
+
+	switch {
+	case offset == size:
+		return nil
+	case offset > size:
+		offset = 0
+		redirect = ">"
+	case offset > 0:
+		tflog.Info(ctx, fmt.Sprintf("Resuming upload of %s at %d/%d bytes", partPath, offset, size))
 	}
-	defer f.Close()
 
-	err = scpClient.CopyFromFile(ctx, *f, remotePath, "0644")
+	reader := NewProgressReader(ctx, io.NewSectionReader(f, offset, size-offset), "Uploading "+partPath, size, offset)
+
+	var stderr bytes.Buffer
+
+	err = sshClient.run(ctx, fmt.Sprintf("cat %s %s", redirect, ShellQuote(partPath)), reader, io.Discard, &stderr)
 	if err != nil {
-		return fmt.Errorf("failed to copy file to remote host: %w", err)
+		return fmt.Errorf("failed to upload %s: %w\n%s", partPath, err, stderr.String())
 	}
 
 	return nil
 }
 
+// remoteSHA256 returns the sha256 checksum of the remote file.
+func (sshClient *sshMachineAccessClient) remoteSHA256(ctx context.Context, path string) (string, error) {
+	result, err := sshClient.Run(ctx, "sha256sum "+ShellQuote(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute sha256 of %s: %w", path, err)
+	}
+
+	fields := strings.Fields(result.Stdout)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected sha256sum output: %s", result.Stdout)
+	}
+
+	return fields[0], nil
+}
+
 func (sshClient *sshMachineAccessClient) GetDockerClient(ctx context.Context) (*dockerClient.Client, error) {
+	socket := DockerSocketFrom(ctx)
+
 	sshClient.dockerClientLocker.Lock()
 
-	if sshClient.dockerClient == nil || sshClient.dockerClientErr != nil {
-		sshClient.dockerClient, sshClient.dockerClientErr = sshClient.createDockerClient(ctx)
+	if sshClient.dockerClients[socket] == nil || sshClient.dockerClientErrs[socket] != nil {
+		sshClient.dockerClients[socket], sshClient.dockerClientErrs[socket] = sshClient.createDockerClient(ctx, socket)
 	}
 
+	client, err := sshClient.dockerClients[socket], sshClient.dockerClientErrs[socket]
+
 	sshClient.dockerClientLocker.Unlock()
 
-	return sshClient.dockerClient, sshClient.dockerClientErr
+	return client, err
 }
 
-func (sshClient *sshMachineAccessClient) createDockerClient(ctx context.Context) (*dockerClient.Client, error) {
+func (sshClient *sshMachineAccessClient) createDockerClient(ctx context.Context, socket string) (*dockerClient.Client, error) {
 	// Start SSH port forwarding in the background
-	localPort, cleanup, err := sshClient.startSSHPortForwarding(ctx)
+	localPort, cleanup, err := sshClient.startSSHPortForwarding(ctx, socket)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start SSH port forwarding: %v", err)
 	}
@@ -267,7 +852,7 @@ func (sshClient *sshMachineAccessClient) createDockerClient(ctx context.Context)
 	return dockerClient, nil
 }
 
-func (sshClient *sshMachineAccessClient) startSSHPortForwarding(ctx context.Context) (localPort int, cleanup func(), err error) {
+func (sshClient *sshMachineAccessClient) startSSHPortForwarding(ctx context.Context, socket string) (localPort int, cleanup func(), err error) {
 	// Listen on a random local port
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -284,11 +869,17 @@ func (sshClient *sshMachineAccessClient) startSSHPortForwarding(ctx context.Cont
 		listener.Close()
 	}
 
-	// Connect to Docker daemon on the remote host (default Docker socket)
-	remoteConn, err := sshClient.Dial("unix", "/var/run/docker.sock")
+	// Connect to the Docker API on the remote host, served by dockerd or podman
+	client, err := sshClient.getClient(ctx)
+	if err != nil {
+		listener.Close()
+		return -1, nil, err
+	}
+
+	remoteConn, err := client.Dial("unix", socket)
 	if err != nil {
 		tflog.Error(ctx, fmt.Sprintf("Failed to connect to remote Docker socket: %v", err))
-		return -1, nil, fmt.Errorf("could not dial /var/run/docker.sock. err=%w", err)
+		return -1, nil, fmt.Errorf("could not dial %s. err=%w", socket, err)
 	}
 
 	// Start forwarding in a goroutine
@@ -1,12 +1,22 @@
 package clients
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"log"
+	"net"
 	"os"
+	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/avast/retry-go"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestSshRunCommand(t *testing.T) {
@@ -98,4 +108,443 @@ func TestSshRunCommand(t *testing.T) {
 			t.Fatalf("unexpected output: %s", output)
 		}
 	})
+	t.Run("successful command execution with password", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		// the test server sets the password of the test user to "pass"
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPassword("pass").Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		output, err := client.RunCommand(t.Context(), "echo hello")
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != expectedHelloOutput {
+			t.Fatalf("unexpected output: %s", output)
+		}
+	})
+	t.Run("successful command execution with private key content", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		keyContent, err := os.ReadFile(keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKey(string(keyContent)).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		output, err := client.RunCommand(t.Context(), "echo hello")
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != expectedHelloOutput {
+			t.Fatalf("unexpected output: %s", output)
+		}
+	})
+
+	t.Run("private key path and content are mutually exclusive", func(t *testing.T) {
+		// Act
+		_, err := CreateSSHMachineAccessClientBuilder("test", "localhost", 22).WithPrivateKeyPath("/tmp/key").WithPrivateKey("key").Build(t.Context())
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+	t.Run("encrypted private key without passphrase", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		os.Remove(keyPath.Name())
+
+		output, err := exec.Command("ssh-keygen", "-t", "ed25519", "-f", keyPath.Name(), "-N", "secret").CombinedOutput() // #nosec G204 - this is only used for testing
+		if err != nil {
+			t.Fatalf("failed to create ssh key: %v\n%s", err, output)
+		}
+
+		// Act
+		_, err = CreateSSHMachineAccessClientBuilder("test", "localhost", 22).WithPrivateKeyPath(keyPath.Name()).Build(t.Context())
+
+		// Assert
+		if !errors.Is(err, ErrPrivateKeyPassphraseMissing) {
+			t.Fatalf("expected ErrPrivateKeyPassphraseMissing, got: %v", err)
+		}
+	})
+	t.Run("reconnects after the connection was dropped", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(keyPath.Name()).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// kill the sshd process serving this connection, this fails the command itself
+		_, _ = client.RunCommand(t.Context(), "sudo pkill -KILL -f 'sshd: test'")
+
+		// Act
+		output, err := client.RunCommand(t.Context(), "echo hello")
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != expectedHelloOutput {
+			t.Fatalf("unexpected output: %s", output)
+		}
+	})
+	t.Run("runs commands in parallel up to max sessions", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(keyPath.Name()).WithMaxSessions(2).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		start := time.Now()
+		errs := make(chan error, 4)
+
+		for range 4 {
+			go func() {
+				_, err := client.RunCommand(t.Context(), "sleep 2")
+				errs <- err
+			}()
+		}
+
+		for range 4 {
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		elapsed := time.Since(start)
+
+		// Assert - 4 commands of 2 seconds over 2 sessions take two rounds
+		if elapsed < 4*time.Second || elapsed >= 8*time.Second {
+			t.Fatalf("unexpected duration: %s", elapsed)
+		}
+	})
+	t.Run("command is killed when the context is done", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(keyPath.Name()).WithCommandTimeout(2 * time.Second).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		start := time.Now()
+		_, err = client.RunCommand(t.Context(), "sleep 60")
+
+		// Assert
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a deadline exceeded error, got: %v", err)
+		}
+
+		if time.Since(start) > 30*time.Second {
+			t.Fatalf("command was not killed in time: %s", time.Since(start))
+		}
+	})
+	t.Run("run returns stdout, stderr and exit code separately", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(keyPath.Name()).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		result, err := client.Run(t.Context(), "echo out && echo err >&2 && exit 3")
+
+		// Assert
+		var exitErr ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("expected an ExitError, got: %v", err)
+		}
+
+		if exitErr.Error() != "exit code 3: err" {
+			t.Fatalf("unexpected error message: %s", exitErr.Error())
+		}
+
+		if result.Stdout != "out\n" || result.Stderr != "err\n" || result.ExitCode != 3 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	})
+	t.Run("copy file resumes a partial upload and verifies the checksum", func(t *testing.T) {
+		// Arrange
+		keyPath, err := os.CreateTemp("", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name())
+
+		if err := CreateSSHKey(t, keyPath.Name()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(keyPath.Name() + ".pub")
+
+		port, stopServer, err := StartDockerSSHServer(t, keyPath.Name()+".pub", keyPath.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stopServer()
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(keyPath.Name()).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		localFile, err := os.CreateTemp("", "upload")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(localFile.Name())
+
+		content := strings.Repeat("0123456789\n", 100000)
+		if _, err := localFile.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = localFile.Close()
+
+		// A previous upload stopped half way
+		_, err = client.RunCommand(t.Context(), "yes 0123456789 | head -c 550000 > /tmp/upload.part")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		err = client.CopyFile(t.Context(), localFile.Name(), "/tmp/upload")
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output, err := client.RunCommand(t.Context(), "wc -c < /tmp/upload && ls /tmp/upload.part 2>/dev/null | wc -l")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "1100000\n0\n" {
+			t.Fatalf("unexpected remote file: %q", output)
+		}
+
+		expected := sha256.Sum256([]byte(content))
+
+		output, err = client.RunCommand(t.Context(), "sha256sum /tmp/upload")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(output, hex.EncodeToString(expected[:])) {
+			t.Fatalf("unexpected checksum: %s", output)
+		}
+	})
+}
+
+func TestSshKeepalive(t *testing.T) {
+	t.Run("a server that stops answering fails the running command", func(t *testing.T) {
+		// Arrange
+		port := startUnresponsiveSSHServer(t)
+
+		client, err := CreateSSHMachineAccessClientBuilder("test", "127.0.0.1", port).WithPassword("secret").WithKeepalive(50*time.Millisecond, 2).Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+
+		// Act
+		_, err = client.RunCommand(ctx, "sleep 60")
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), "stopped responding") {
+			t.Fatalf("expected the server to be reported as not responding, got: %v", err)
+		}
+	})
+}
+
+// startUnresponsiveSSHServer starts an SSH server accepting any password, which starts the commands but never finishes
+// them and never answers the keepalive requests, as a server behind a dropped NAT mapping. It returns its port.
+func startUnresponsiveSSHServer(t *testing.T) int {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, _ []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+
+				// The keepalive requests are read but never answered
+				go func() {
+					for range requests {
+					}
+				}()
+
+				for newChannel := range channels {
+					channel, channelRequests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+
+					t.Cleanup(func() { _ = channel.Close() })
+
+					go func() {
+						for request := range channelRequests {
+							_ = request.Reply(true, nil)
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
 }
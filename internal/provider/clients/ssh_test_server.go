@@ -191,12 +191,15 @@ func StartDockerSSHServer(t *testing.T, authorizedKeysPath string, privateKeyPat
 		return -1, nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
-	sshClientBuilder := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(privateKeyPath)
+	sshClient, err := CreateSSHMachineAccessClientBuilder("test", "localhost", port).WithPrivateKeyPath(privateKeyPath).Build(t.Context())
+	if err != nil {
+		return -1, nil, fmt.Errorf("failed to create ssh client: %w", err)
+	}
 
 	err = retry.Do(func() error {
 		t.Log("Trying to connect to the container via ssh")
 
-		_, err := sshClientBuilder.Build(t.Context())
+		_, err := sshClient.RunCommand(t.Context(), "true")
 
 		return err
 	}, retry.Attempts(60), retry.Delay(1*time.Second))
@@ -0,0 +1,95 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+type streamOutputKey struct{}
+
+// WithStreamedOutput marks the context so that the output of commands run with it is logged line by line at INFO level
+// as it arrives, instead of only being available once the command is done. Use it for long running commands, so that
+// TF_LOG=INFO shows their progress.
+func WithStreamedOutput(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamOutputKey{}, true)
+}
+
+func isStreamedOutput(ctx context.Context) bool {
+	streamed, _ := ctx.Value(streamOutputKey{}).(bool)
+	return streamed
+}
+
+// streamWriters returns the writers to give to the command. When the output is streamed, they also log each line as it
+// arrives. The returned function flushes the last incomplete lines and must be called once the command is done.
+func streamWriters(ctx context.Context, stdout io.Writer, stderr io.Writer) (io.Writer, io.Writer, func()) {
+	if !isStreamedOutput(ctx) {
+		return stdout, stderr, func() {}
+	}
+
+	stdoutLogger := &lineLogger{ctx: ctx, stream: "stdout"}
+	stderrLogger := &lineLogger{ctx: ctx, stream: "stderr"}
+
+	return io.MultiWriter(stdout, stdoutLogger), io.MultiWriter(stderr, stderrLogger), func() {
+		stdoutLogger.flush()
+		stderrLogger.flush()
+	}
+}
+
+// lineLogger is a writer that logs every complete line written to it.
+type lineLogger struct {
+	ctx     context.Context
+	stream  string
+	pending []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.pending = append(l.pending, p...)
+
+	for {
+		index := bytes.IndexByte(l.pending, '\n')
+		if index < 0 {
+			break
+		}
+
+		l.log(string(l.pending[:index]))
+		l.pending = l.pending[index+1:]
+	}
+
+	return len(p), nil
+}
+
+func (l *lineLogger) flush() {
+	if len(l.pending) > 0 {
+		l.log(string(l.pending))
+		l.pending = nil
+	}
+}
+
+func (l *lineLogger) log(line string) {
+	tflog.Info(l.ctx, strings.TrimRight(line, "\r"), map[string]interface{}{"stream": l.stream})
+}
+
+// syncBuffer is a buffer that can be written to concurrently, e.g. with both the stdout and the stderr of a command.
+type syncBuffer struct {
+	buffer bytes.Buffer
+	mutex  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
@@ -0,0 +1,63 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWriters(t *testing.T) {
+	t.Run("streamed output is logged line by line", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := WithStreamedOutput(tflogtest.RootLogger(context.Background(), &logs))
+
+		var out bytes.Buffer
+
+		stdout, _, flush := streamWriters(ctx, &out, io.Discard)
+
+		// Act
+		_, _ = stdout.Write([]byte("first\nsec"))
+		_, _ = stdout.Write([]byte("ond\nlast"))
+
+		flush()
+
+		// Assert
+		assert.Equal(t, "first\nsecond\nlast", out.String())
+
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+
+		messages := []interface{}{}
+		for _, entry := range entries {
+			messages = append(messages, entry["@message"])
+		}
+
+		assert.Equal(t, []interface{}{"first", "second", "last"}, messages)
+	})
+
+	t.Run("output is not logged by default", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+		var out bytes.Buffer
+
+		stdout, _, flush := streamWriters(ctx, &out, io.Discard)
+
+		// Act
+		_, _ = stdout.Write([]byte("first\n"))
+
+		flush()
+
+		// Assert
+		assert.Equal(t, "first\n", out.String())
+		assert.Empty(t, logs.String())
+	})
+}
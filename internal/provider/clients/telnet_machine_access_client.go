@@ -0,0 +1,520 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dockerClient "github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// telnetChunkSize is the size of the base64 lines sent to write files, below the 4096 bytes limit of the line
+// discipline of a terminal in canonical mode.
+const telnetChunkSize = 1024
+
+// Telnet protocol bytes, see RFC 854.
+const (
+	telnetIAC  = 255
+	telnetDont = 254
+	telnetDo   = 253
+	telnetWont = 252
+	telnetWill = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetOptionEcho            = 1
+	telnetOptionSuppressGoAhead = 3
+)
+
+var (
+	telnetLoginPrompt    = regexp.MustCompile(`(?i)login:\s*$`)
+	telnetPasswordPrompt = regexp.MustCompile(`(?i)password:\s*$`)
+)
+
+type telnetMachineAccessClientBuilder struct {
+	user string
+	host string
+	port int
+
+	password *string
+
+	timeout        time.Duration
+	commandTimeout time.Duration
+
+	become Become
+}
+
+// CreateTelnetMachineAccessClientBuilder creates a builder of a machine access client running commands in a shell over
+// telnet, e.g. for network gear or for the serial console of a machine exposed by a console server. Raw TCP serial
+// servers not speaking the telnet protocol are supported too.
+func CreateTelnetMachineAccessClientBuilder(user string, host string, port int) *telnetMachineAccessClientBuilder {
+	return &telnetMachineAccessClientBuilder{
+		user:    user,
+		host:    host,
+		port:    port,
+		timeout: 30 * time.Second,
+	}
+}
+
+// WithPassword makes the client log in with the user and password when the console shows a login prompt. Without a
+// password, the console is expected to already run a shell.
+func (builder *telnetMachineAccessClientBuilder) WithPassword(password string) *telnetMachineAccessClientBuilder {
+	builder.password = &password
+	return builder
+}
+
+// WithTimeout sets the maximum time to wait for the connection and the login.
+func (builder *telnetMachineAccessClientBuilder) WithTimeout(timeout time.Duration) *telnetMachineAccessClientBuilder {
+	builder.timeout = timeout
+	return builder
+}
+
+// WithCommandTimeout sets the maximum time a single command may run. The connection is dropped and established again
+// for the next command when it expires, as the command can not be interrupted otherwise.
+func (builder *telnetMachineAccessClientBuilder) WithCommandTimeout(timeout time.Duration) *telnetMachineAccessClientBuilder {
+	builder.commandTimeout = timeout
+	return builder
+}
+
+// ForHost returns a copy of the builder connecting to another host with the same options.
+func (builder *telnetMachineAccessClientBuilder) ForHost(user string, host string, port int) *telnetMachineAccessClientBuilder {
+	copied := *builder
+	copied.user = user
+	copied.host = host
+	copied.port = port
+
+	return &copied
+}
+
+// WithBecome sets how privileges are elevated for file operations.
+func (builder *telnetMachineAccessClientBuilder) WithBecome(become Become) *telnetMachineAccessClientBuilder {
+	builder.become = become
+	return builder
+}
+
+// Build creates the client. The connection is only established on first use.
+func (builder *telnetMachineAccessClientBuilder) Build(_ context.Context) (MachineAccessClient, error) {
+	if err := builder.become.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &telnetMachineAccessClient{
+		addr:           fmt.Sprintf("%v:%v", builder.host, builder.port),
+		user:           builder.user,
+		password:       builder.password,
+		timeout:        builder.timeout,
+		commandTimeout: builder.commandTimeout,
+		become:         builder.become,
+	}, nil
+}
+
+// telnetMachineAccessClient runs commands one at a time in a single shell. The output of a command is delimited with a
+// marker printed after it together with its exit code. The console merges stdout and stderr, so both are returned as
+// stdout.
+type telnetMachineAccessClient struct {
+	addr     string
+	user     string
+	password *string
+
+	timeout        time.Duration
+	commandTimeout time.Duration
+	become         Become
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *telnetReader
+}
+
+// connect establishes the connection, logs in if needed and prepares the shell for running commands. It must be called
+// with the mutex held.
+func (telnetClient *telnetMachineAccessClient) connect(ctx context.Context) error {
+	if telnetClient.conn != nil {
+		return nil
+	}
+
+	tflog.Debug(ctx, "Connecting with telnet to "+telnetClient.addr)
+
+	dialer := net.Dialer{Timeout: telnetClient.timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", telnetClient.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", telnetClient.addr, err)
+	}
+
+	telnetClient.conn = conn
+	telnetClient.reader = &telnetReader{conn: conn}
+
+	loginCtx, cancel := context.WithTimeout(ctx, telnetClient.timeout)
+	defer cancel()
+
+	err = telnetClient.login(loginCtx)
+	if err != nil {
+		telnetClient.close()
+		return err
+	}
+
+	return nil
+}
+
+func (telnetClient *telnetMachineAccessClient) login(ctx context.Context) error {
+	if telnetClient.password != nil {
+		// Wake up the console, which may not show its prompt until it receives a newline
+		if err := telnetClient.write("\n"); err != nil {
+			return err
+		}
+
+		if _, err := telnetClient.readUntil(ctx, telnetLoginPrompt); err != nil {
+			return fmt.Errorf("failed to wait for the login prompt: %w", err)
+		}
+
+		if err := telnetClient.write(telnetClient.user + "\n"); err != nil {
+			return err
+		}
+
+		if _, err := telnetClient.readUntil(ctx, telnetPasswordPrompt); err != nil {
+			return fmt.Errorf("failed to wait for the password prompt: %w", err)
+		}
+
+		if err := telnetClient.write(*telnetClient.password + "\n"); err != nil {
+			return err
+		}
+	}
+
+	// Without echo, prompts and newline translation, the console only prints the output of the commands
+	_, err := telnetClient.runLocked(ctx, "stty -echo -onlcr 2>/dev/null; PS1=''; PS2=''; export PS1 PS2", false)
+	if err != nil {
+		return fmt.Errorf("failed to prepare the shell: %w", err)
+	}
+
+	return nil
+}
+
+func (telnetClient *telnetMachineAccessClient) close() {
+	if telnetClient.conn != nil {
+		_ = telnetClient.conn.Close()
+	}
+
+	telnetClient.conn = nil
+	telnetClient.reader = nil
+}
+
+func (telnetClient *telnetMachineAccessClient) write(text string) error {
+	// IAC bytes in the data must be doubled
+	data := bytes.ReplaceAll([]byte(text), []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+
+	_, err := telnetClient.conn.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write to %s: %w", telnetClient.addr, err)
+	}
+
+	return nil
+}
+
+// readUntil reads until the output matches the pattern, and returns the output read.
+func (telnetClient *telnetMachineAccessClient) readUntil(ctx context.Context, pattern *regexp.Regexp) (string, error) {
+	var out bytes.Buffer
+
+	buffer := make([]byte, 4096)
+
+	for !pattern.Match(out.Bytes()) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Time{}
+		}
+
+		_ = telnetClient.conn.SetReadDeadline(deadline)
+
+		done := make(chan struct{})
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = telnetClient.conn.SetReadDeadline(time.Now())
+			case <-done:
+			}
+		}()
+
+		n, err := telnetClient.reader.Read(buffer)
+
+		close(done)
+
+		out.Write(buffer[:n])
+
+		if ctx.Err() != nil {
+			return out.String(), fmt.Errorf("command was cancelled: %w", ctx.Err())
+		}
+
+		if err != nil {
+			return out.String(), fmt.Errorf("failed to read from %s: %w", telnetClient.addr, err)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// runLocked runs the command in the shell and returns its output. A failure leaves the shell in an unknown state, so
+// the connection is closed to be established again for the next command. It must be called with the mutex held.
+func (telnetClient *telnetMachineAccessClient) runLocked(ctx context.Context, command string, wrap bool) (CommandResult, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return CommandResult{}, fmt.Errorf("failed to generate marker: %w", err)
+	}
+
+	// The marker is printed from two parts, so that it can not be matched in the echo of the command line
+	marker := "SETUP_DONE_" + hex.EncodeToString(nonce)
+	pattern := regexp.MustCompile(`\n` + marker + ` (\d+)\r?\n`)
+
+	line := command
+	if wrap {
+		line = "sh -c " + ShellQuote(command) + " </dev/null"
+	}
+
+	line += fmt.Sprintf("; printf '\\n%%s_%%s %%d\\n' SETUP_DONE %s $?\n", hex.EncodeToString(nonce))
+
+	if err := telnetClient.write(line); err != nil {
+		telnetClient.close()
+		return CommandResult{}, err
+	}
+
+	out, err := telnetClient.readUntil(ctx, pattern)
+	if err != nil {
+		telnetClient.close()
+		return CommandResult{Stdout: out}, err
+	}
+
+	match := pattern.FindStringSubmatchIndex(out)
+	stdout := strings.TrimSuffix(strings.ReplaceAll(out[:match[0]], "\r\n", "\n"), "\r")
+
+	exitCode, err := strconv.Atoi(out[match[2]:match[3]])
+	if err != nil {
+		return CommandResult{Stdout: stdout}, fmt.Errorf("failed to parse exit code: %w", err)
+	}
+
+	result := CommandResult{Stdout: stdout, ExitCode: exitCode}
+	if exitCode != 0 {
+		return result, ExitError{ExitCode: exitCode, Command: telnetClient.become.redact(command), Stdout: stdout}
+	}
+
+	return result, nil
+}
+
+func (telnetClient *telnetMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
+	result, err := telnetClient.Run(ctx, command)
+
+	return result.Stdout, err
+}
+
+func (telnetClient *telnetMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	if telnetClient.commandTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, telnetClient.commandTimeout)
+		defer cancel()
+	}
+
+	telnetClient.mutex.Lock()
+	defer telnetClient.mutex.Unlock()
+
+	if err := telnetClient.connect(ctx); err != nil {
+		return CommandResult{}, err
+	}
+
+	tflog.Debug(ctx, "Running command: "+telnetClient.become.redact(command))
+
+	result, err := telnetClient.runLocked(ctx, command, true)
+
+	// The output is only complete once the marker was read, so it is logged at once when streamed
+	stdout, _, flush := streamWriters(ctx, io.Discard, io.Discard)
+	_, _ = stdout.Write([]byte(result.Stdout))
+
+	flush()
+
+	return result, err
+}
+
+func (telnetClient *telnetMachineAccessClient) runWithBecome(ctx context.Context, command string) (CommandResult, error) {
+	return telnetClient.Run(ctx, telnetClient.become.Wrap(command))
+}
+
+// WriteFile sends the content base64 encoded in lines to a temp file, then decodes it next to the destination and
+// renames it.
+func (telnetClient *telnetMachineAccessClient) WriteFile(ctx context.Context, path string, mode string, owner string, group string, content string) error {
+	out, err := telnetClient.RunCommand(ctx, "mktemp")
+	if err != nil {
+		return fmt.Errorf("failed to create remote temp file. Err=%w\nout = %s", err, out)
+	}
+
+	remoteTmpFile := strings.TrimSpace(out)
+	defer func() {
+		_, _ = telnetClient.RunCommand(ctx, "rm -f "+ShellQuote(remoteTmpFile))
+	}()
+
+	err = telnetClient.upload(ctx, strings.NewReader(content), remoteTmpFile)
+	if err != nil {
+		return err
+	}
+
+	install := fmt.Sprintf("dest=%s; if [ -L \"$dest\" ]; then dest=$(readlink -f -- \"$dest\") || exit 1; fi; staging=\"$dest.tmp-%s\"; "+
+		"base64 -d %s > \"$staging\" && chown %s \"$staging\" && chmod %s \"$staging\" && mv -f \"$staging\" \"$dest\" || { rm -f \"$staging\"; exit 1; }",
+		ShellQuote(path), filepath.Base(remoteTmpFile),
+		ShellQuote(remoteTmpFile),
+		ShellQuote(owner+":"+group),
+		ShellQuote(mode),
+	)
+
+	_, err = telnetClient.Run(ctx, telnetClient.become.Wrap(install))
+	if err != nil {
+		return fmt.Errorf("failed to install file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// upload writes the content base64 encoded to the remote file, one line at a time.
+func (telnetClient *telnetMachineAccessClient) upload(ctx context.Context, content io.Reader, remotePath string) error {
+	_, err := telnetClient.RunCommand(ctx, ": > "+ShellQuote(remotePath))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", remotePath, err)
+	}
+
+	// base64 of telnetChunkSize*3/4 bytes is exactly telnetChunkSize long, without padding in the middle of the file
+	buffer := make([]byte, telnetChunkSize*3/4)
+
+	for {
+		n, readErr := io.ReadFull(content, buffer)
+		if n > 0 {
+			line := base64.StdEncoding.EncodeToString(buffer[:n])
+
+			_, err := telnetClient.RunCommand(ctx, fmt.Sprintf("echo %s >> %s", line, ShellQuote(remotePath)))
+			if err != nil {
+				return fmt.Errorf("failed to write to %s: %w", remotePath, err)
+			}
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return nil
+		}
+
+		if readErr != nil {
+			return fmt.Errorf("failed to read content: %w", readErr)
+		}
+	}
+}
+
+func (telnetClient *telnetMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	return readFile(ctx, telnetClient.runWithBecome, path, followSymlinks, true)
+}
+
+func (telnetClient *telnetMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	return statFile(ctx, telnetClient.runWithBecome, path, followSymlinks)
+}
+
+// CopyFile sends the file base64 encoded in lines, which is slow: only use it for small files.
+func (telnetClient *telnetMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
+	tflog.Debug(ctx, fmt.Sprintf("Copying file from %s to %s", localPath, remotePath))
+
+	f, err := os.Open(localPath) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to open local file %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	encodedPath := remotePath + ".b64"
+
+	err = telnetClient.upload(ctx, f, encodedPath)
+	if err != nil {
+		return err
+	}
+
+	out, err := telnetClient.RunCommand(ctx, fmt.Sprintf("base64 -d %[1]s > %[2]s; status=$?; rm -f %[1]s; exit $status", ShellQuote(encodedPath), ShellQuote(remotePath)))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s. Err=%w\nout = %s", remotePath, err, out)
+	}
+
+	return nil
+}
+
+func (telnetClient *telnetMachineAccessClient) GetDockerClient(_ context.Context) (*dockerClient.Client, error) {
+	return nil, fmt.Errorf("docker is not supported over telnet")
+}
+
+// telnetReader strips the telnet commands from the data read from the connection and answers the option
+// negotiations: only echo and suppress go ahead are accepted.
+type telnetReader struct {
+	conn net.Conn
+
+	// state is the position in the current telnet command: 0 outside of commands, telnetIAC after an IAC, the verb
+	// after IAC WILL/WONT/DO/DONT, and telnetSB inside a sub-negotiation.
+	state byte
+	// subnegotiationIAC tells whether the previous byte of a sub-negotiation was an IAC.
+	subnegotiationIAC bool
+}
+
+func (r *telnetReader) Read(p []byte) (int, error) {
+	raw := make([]byte, len(p))
+
+	n, err := r.conn.Read(raw)
+
+	out := p[:0]
+
+	for _, b := range raw[:n] {
+		switch r.state {
+		case 0:
+			if b == telnetIAC {
+				r.state = telnetIAC
+			} else {
+				out = append(out, b)
+			}
+		case telnetIAC:
+			switch b {
+			case telnetIAC:
+				out = append(out, b)
+				r.state = 0
+			case telnetWill, telnetWont, telnetDo, telnetDont:
+				r.state = b
+			case telnetSB:
+				r.state = telnetSB
+			default:
+				r.state = 0
+			}
+		case telnetWill:
+			r.reply(b == telnetOptionEcho || b == telnetOptionSuppressGoAhead, telnetDo, telnetDont, b)
+			r.state = 0
+		case telnetDo:
+			r.reply(b == telnetOptionSuppressGoAhead, telnetWill, telnetWont, b)
+			r.state = 0
+		case telnetWont, telnetDont:
+			r.state = 0
+		case telnetSB:
+			if r.subnegotiationIAC && b == telnetSE {
+				r.state = 0
+			}
+
+			r.subnegotiationIAC = b == telnetIAC && !r.subnegotiationIAC
+		}
+	}
+
+	return len(out), err
+}
+
+func (r *telnetReader) reply(accept bool, yes byte, no byte, option byte) {
+	verb := no
+	if accept {
+		verb = yes
+	}
+
+	_, _ = r.conn.Write([]byte{telnetIAC, verb, option})
+}
@@ -0,0 +1,186 @@
+package clients
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startFakeTelnetConsole starts a console negotiating telnet options and asking for a login, before running a shell.
+func startFakeTelnetConsole(t *testing.T, password string) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveFakeTelnetConsole(conn, password)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func serveFakeTelnetConsole(conn net.Conn, password string) {
+	defer conn.Close()
+
+	_, _ = conn.Write([]byte{telnetIAC, telnetDo, telnetOptionSuppressGoAhead, telnetIAC, telnetWill, telnetOptionEcho})
+	_, _ = conn.Write([]byte("Welcome\r\nlogin: "))
+
+	// Like getty, an empty line shows the login prompt again
+	login := readFakeTelnetLine(conn)
+	for login == "" {
+		_, _ = conn.Write([]byte("\r\nlogin: "))
+		login = readFakeTelnetLine(conn)
+	}
+
+	if login != "test" {
+		return
+	}
+
+	_, _ = conn.Write([]byte("Password: "))
+
+	if readFakeTelnetLine(conn) != password {
+		return
+	}
+
+	cmd := exec.Command("sh")
+	cmd.Stdin = conn
+	cmd.Stdout = conn
+	cmd.Stderr = conn
+
+	_ = cmd.Run()
+}
+
+// readFakeTelnetLine reads a line one byte at a time, so that nothing after it is consumed, skipping the replies to
+// the option negotiations.
+func readFakeTelnetLine(conn net.Conn) string {
+	var line []byte
+
+	buffer := make([]byte, 1)
+
+	for {
+		if _, err := conn.Read(buffer); err != nil {
+			return ""
+		}
+
+		switch buffer[0] {
+		case telnetIAC:
+			_, _ = conn.Read(make([]byte, 2))
+		case '\n':
+			return strings.TrimSpace(string(line))
+		default:
+			line = append(line, buffer[0])
+		}
+	}
+}
+
+func TestTelnetMachineAccessClient(t *testing.T) {
+	// Arrange
+	port := startFakeTelnetConsole(t, "pass")
+
+	client, err := CreateTelnetMachineAccessClientBuilder("test", "127.0.0.1", port).
+		WithPassword("pass").
+		WithBecome(Become{Disabled: true}).
+		Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	t.Run("runs commands and returns their exit code", func(t *testing.T) {
+		// Act
+		output, err := client.RunCommand(t.Context(), "echo hello")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "hello\n", output)
+
+		// Act
+		result, err := client.Run(t.Context(), "echo 'it''s' && exit 3")
+
+		// Assert
+		var exitErr ExitError
+		assert.True(t, errors.As(err, &exitErr))
+		assert.Equal(t, 3, result.ExitCode)
+		assert.Equal(t, "its\n", result.Stdout)
+	})
+
+	t.Run("writes and reads files", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(dir, "file")
+		content := strings.Repeat("line with 'quotes' and \xff\n", 100)
+
+		// Act
+		err := client.WriteFile(t.Context(), path, "0640", currentUser.Uid, currentUser.Gid, content)
+
+		// Assert
+		assert.NoError(t, err)
+
+		written, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(written))
+
+		// Act
+		read, err := client.ReadFile(t.Context(), path, true)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, content, read)
+
+		info, err := client.Stat(t.Context(), path, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "640", info.Mode)
+	})
+
+	t.Run("copies files", func(t *testing.T) {
+		// Arrange
+		localPath := filepath.Join(dir, "local")
+		if err := os.WriteFile(localPath, []byte("copied content"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		remotePath := filepath.Join(dir, "remote")
+
+		// Act
+		err := client.CopyFile(t.Context(), localPath, remotePath)
+
+		// Assert
+		assert.NoError(t, err)
+
+		copied, err := os.ReadFile(remotePath)
+		assert.NoError(t, err)
+		assert.Equal(t, "copied content", string(copied))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		// Act
+		_, err := client.ReadFile(t.Context(), filepath.Join(dir, "missing"), true)
+
+		// Assert
+		assert.True(t, IsFileNotFound(err))
+	})
+}
@@ -0,0 +1,260 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	scp "github.com/bramvdbogaerde/go-scp"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+)
+
+// OS families supported by the SSH machine access client.
+const (
+	OSFamilyLinux   = "linux"
+	OSFamilyWindows = "windows"
+)
+
+// windowsMachineAccessClient runs commands as PowerShell scripts over SSH, for Windows hosts running OpenSSH server.
+// Privilege escalation is not supported: connect as a user with the required rights. File modes and owners do not
+// apply on Windows and are ignored.
+type windowsMachineAccessClient struct {
+	sshClient *sshMachineAccessClient
+}
+
+// powershellCommand returns the command line running the script with PowerShell. The script is passed encoded, so
+// that it does not need any quoting, and stops on the first error.
+func powershellCommand(script string) string {
+	script = "$ErrorActionPreference = 'Stop'; $ProgressPreference = 'SilentlyContinue'\n" + script
+
+	encoded := utf16.Encode([]rune(script))
+	buffer := make([]byte, 2*len(encoded))
+
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(buffer[2*i:], c)
+	}
+
+	return "powershell -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(buffer)
+}
+
+// PowershellQuote quotes the value as a PowerShell literal string.
+func PowershellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func (windowsClient *windowsMachineAccessClient) RunCommand(ctx context.Context, command string) (string, error) {
+	ctx, encoded := encodedCommand(ctx, command)
+
+	out, err := windowsClient.sshClient.RunCommand(ctx, encoded)
+
+	return out, withScript(ctx, err, command)
+}
+
+func (windowsClient *windowsMachineAccessClient) Run(ctx context.Context, command string) (CommandResult, error) {
+	ctx, encoded := encodedCommand(ctx, command)
+
+	result, err := windowsClient.sshClient.Run(ctx, encoded)
+
+	return result, withScript(ctx, err, command)
+}
+
+// withScript replaces the command of an ExitError, which is unreadable once encoded, with the script it runs, unless
+// the script has sensitive values.
+func withScript(ctx context.Context, err error, script string) error {
+	var exitErr ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	exitErr.Command = ""
+	if !hasSensitiveValues(ctx) {
+		exitErr.Command = script
+	}
+
+	return exitErr
+}
+
+// encodedCommand logs the script, as the command that runs it is unreadable, and returns that command. The command is
+// masked in the logs when the context holds sensitive values, as they would be readable once decoded.
+func encodedCommand(ctx context.Context, script string) (context.Context, string) {
+	tflog.Debug(ctx, "Running script: "+script)
+
+	command := powershellCommand(script)
+	if hasSensitiveValues(ctx) {
+		ctx = tflog.MaskLogStrings(ctx, command)
+	}
+
+	return ctx, command
+}
+
+// WriteFile sends the content base64 encoded on stdin, so that it is written byte for byte. mode, owner and group are
+// ignored.
+func (windowsClient *windowsMachineAccessClient) WriteFile(ctx context.Context, path string, _ string, _ string, _ string, content string) error {
+	script := fmt.Sprintf(`$path = %s
+$item = Get-Item -Force -LiteralPath $path -ErrorAction SilentlyContinue
+if ($item -and $item.LinkType) { $path = [string]$item.Target }
+$tmp = "$path.tmp-" + [guid]::NewGuid()
+try {
+  [IO.File]::WriteAllBytes($tmp, [Convert]::FromBase64String([Console]::In.ReadToEnd()))
+  Move-Item -Force -LiteralPath $tmp -Destination $path
+} finally {
+  Remove-Item -Force -LiteralPath $tmp -ErrorAction SilentlyContinue
+}`, PowershellQuote(path))
+
+	var stderr bytes.Buffer
+
+	err := windowsClient.sshClient.run(ctx, powershellCommand(script), strings.NewReader(base64.StdEncoding.EncodeToString([]byte(content))), io.Discard, &stderr)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w\n%s", path, err, stderr.String())
+	}
+
+	return nil
+}
+
+func (windowsClient *windowsMachineAccessClient) ReadFile(ctx context.Context, path string, followSymlinks bool) (string, error) {
+	script := fmt.Sprintf(`$item = Get-Item -Force -LiteralPath %s -ErrorAction SilentlyContinue
+if (-not $item) { exit %d }
+if ($item.LinkType -and -not $%t) { throw 'is a symbolic link' }
+[Convert]::ToBase64String([IO.File]::ReadAllBytes($item.FullName))`, PowershellQuote(path), fileNotFoundExitCode, followSymlinks)
+
+	result, err := windowsClient.Run(ctx, script)
+	if err != nil {
+		if IsExitCode(err, fileNotFoundExitCode) {
+			return "", FileNotFoundError{Path: path}
+		}
+
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(result.Stdout))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode content of %s: %w", path, err)
+	}
+
+	return string(content), nil
+}
+
+// Stat returns the type, size, modification time and symlink target of the file. Mode, owner and group are not set.
+func (windowsClient *windowsMachineAccessClient) Stat(ctx context.Context, path string, followSymlinks bool) (FileInfo, error) {
+	script := fmt.Sprintf(`$item = Get-Item -Force -LiteralPath %s -ErrorAction SilentlyContinue
+if (-not $item) { exit %d }
+if ($item.LinkType -and $%t) { $item = Get-Item -Force -LiteralPath ([string]$item.Target) }
+$type = if ($item.LinkType) { 'symlink' } elseif ($item.PSIsContainer) { 'directory' } else { 'file' }
+$size = if ($item.PSIsContainer) { 0 } else { $item.Length }
+"$type $size " + [DateTimeOffset]::new($item.LastWriteTimeUtc).ToUnixTimeSeconds()
+if ($item.LinkType) { [string]$item.Target }`, PowershellQuote(path), fileNotFoundExitCode, followSymlinks)
+
+	result, err := windowsClient.Run(ctx, script)
+	if err != nil {
+		if IsExitCode(err, fileNotFoundExitCode) {
+			return FileInfo{}, FileNotFoundError{Path: path}
+		}
+
+		return FileInfo{}, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	return parseWindowsStat(result.Stdout)
+}
+
+func parseWindowsStat(out string) (FileInfo, error) {
+	lines := strings.SplitN(strings.TrimRight(strings.ReplaceAll(out, "\r\n", "\n"), "\n"), "\n", 2)
+
+	fields := strings.Fields(lines[0])
+	if len(fields) != 3 {
+		return FileInfo{}, fmt.Errorf("unexpected stat output: %s", out)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to parse size in stat output %s: %w", out, err)
+	}
+
+	modTime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to parse modification time in stat output %s: %w", out, err)
+	}
+
+	info := FileInfo{
+		Type:    fields[0],
+		Size:    size,
+		ModTime: time.Unix(modTime, 0).UTC(),
+	}
+
+	if len(lines) == 2 {
+		info.LinkTarget = strings.TrimSpace(lines[1])
+	}
+
+	return info, nil
+}
+
+// CopyFile copies the file over scp and verifies its sha256 checksum. Unlike on Linux, interrupted copies are not
+// resumed.
+func (windowsClient *windowsMachineAccessClient) CopyFile(ctx context.Context, localPath string, remotePath string) error {
+	client, err := windowsClient.sshClient.getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to open local file %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("failed to compute sha256 of %s: %w", localPath, err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind %s: %w", localPath, err)
+	}
+
+	scpClient, err := scp.NewClientBySSH(client)
+	if err != nil {
+		return fmt.Errorf("error creating new SSH session from existing connection: %w", err)
+	}
+
+	tflog.Debug(ctx, fmt.Sprintf("Copying file from %s to %s", localPath, remotePath))
+
+	release, err := windowsClient.sshClient.acquireSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = scpClient.CopyFromFile(ctx, *f, remotePath, "0644")
+
+	release()
+
+	if err != nil {
+		return fmt.Errorf("failed to copy file to remote host: %w", err)
+	}
+
+	out, err := windowsClient.RunCommand(ctx, fmt.Sprintf("(Get-FileHash -Algorithm SHA256 -LiteralPath %s).Hash.ToLower()", PowershellQuote(remotePath)))
+	if err != nil {
+		return fmt.Errorf("failed to compute sha256 of %s. Err=%w\nout = %s", remotePath, err, out)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if strings.TrimSpace(out) != checksum {
+		return fmt.Errorf("checksum mismatch after copying %s to %s: expected %s, got %s", localPath, remotePath, checksum, strings.TrimSpace(out))
+	}
+
+	return nil
+}
+
+func (windowsClient *windowsMachineAccessClient) GetDockerClient(_ context.Context) (*dockerClient.Client, error) {
+	return nil, fmt.Errorf("docker is not supported on Windows hosts")
+}
@@ -0,0 +1,107 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPowershellCommand(t *testing.T) {
+	// Act
+	command := powershellCommand("Write-Output 'héllo'")
+
+	// Assert
+	prefix := "powershell -NoProfile -NonInteractive -EncodedCommand "
+	assert.True(t, strings.HasPrefix(command, prefix))
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(command, prefix))
+	assert.NoError(t, err)
+
+	units := make([]uint16, len(decoded)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(decoded[2*i:])
+	}
+
+	script := string(utf16.Decode(units))
+	assert.True(t, strings.HasPrefix(script, "$ErrorActionPreference = 'Stop'"))
+	assert.True(t, strings.HasSuffix(script, "\nWrite-Output 'héllo'"))
+}
+
+func TestEncodedCommand(t *testing.T) {
+	t.Run("the script is logged with its sensitive values masked", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := WithSensitiveValues(tflogtest.RootLogger(context.Background(), &logs), "s3cr3t")
+
+		// Act
+		ctx, command := encodedCommand(ctx, "Set-LocalUser -Name bob -Password "+PowershellQuote("s3cr3t"))
+		tflog.Debug(ctx, "Running command: "+command)
+
+		// Assert
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "Running script: Set-LocalUser -Name bob -Password ***", entries[0]["@message"])
+		assert.Equal(t, "Running command: ***", entries[1]["@message"])
+	})
+
+	t.Run("the command is logged when there is no sensitive value", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+
+		ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+		// Act
+		ctx, command := encodedCommand(ctx, "Get-LocalUser")
+		tflog.Debug(ctx, "Running command: "+command)
+
+		// Assert
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "Running command: "+command, entries[1]["@message"])
+	})
+}
+
+func TestPowershellQuote(t *testing.T) {
+	assert.Equal(t, "'C:\\it''s here'", PowershellQuote("C:\\it's here"))
+}
+
+func TestParseWindowsStat(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		// Act
+		info, err := parseWindowsStat("file 12 1700000000\r\n")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, FileInfo{Type: "file", Size: 12, ModTime: time.Unix(1700000000, 0).UTC()}, info)
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		// Act
+		info, err := parseWindowsStat("symlink 0 1700000000\r\nC:\\target\r\n")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "symlink", info.Type)
+		assert.Equal(t, "C:\\target", info.LinkTarget)
+	})
+
+	t.Run("unexpected output", func(t *testing.T) {
+		// Act
+		_, err := parseWindowsStat("oops")
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
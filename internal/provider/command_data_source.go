@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &commandDataSource{}
+)
+
+func newCommandDataSource(p *internalProvider) datasource.DataSource {
+	return &commandDataSource{
+		provider: p,
+	}
+}
+
+type commandDataSource struct {
+	provider *internalProvider
+}
+
+type commandDataSourceModel struct {
+	Command  types.String `tfsdk:"command"`
+	Stdout   types.String `tfsdk:"stdout"`
+	Stderr   types.String `tfsdk:"stderr"`
+	ExitCode types.Int64  `tfsdk:"exit_code"`
+	ID       types.String `tfsdk:"id"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *commandDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_command"
+}
+
+func (d *commandDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Runs a command on the remote system during plan and refresh, and exposes its output and exit code. The command is run on every refresh, so it must not modify the system",
+
+		Attributes: map[string]schema.Attribute{
+			"command": schema.StringAttribute{
+				Required:    true,
+				Description: "The shell command to run",
+			},
+			"stdout": schema.StringAttribute{
+				Computed:    true,
+				Description: "The standard output of the command",
+			},
+			"stderr": schema.StringAttribute{
+				Computed:    true,
+				Description: "The standard error of the command",
+			},
+			"exit_code": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The exit code of the command. A non-zero exit code is not treated as an error",
+			},
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The command (used as ID)",
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *commandDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	var model commandDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	stdout, stderr, exitCode, err := d.run(ctx, model.Command.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to run command", err.Error())
+		return
+	}
+
+	model.Stdout = types.StringValue(stdout)
+	model.Stderr = types.StringValue(stderr)
+	model.ExitCode = types.Int64Value(exitCode)
+	model.ID = types.StringValue(model.Command.ValueString())
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// run executes the command and returns its stdout, stderr and exit code. A non-zero exit code is not an error.
+func (d *commandDataSource) run(ctx context.Context, command string) (string, string, int64, error) {
+	result, err := d.provider.machineAccessClient.Run(ctx, "("+command+"\n) < /dev/null")
+
+	if _, ok := clients.ExitCode(err); err != nil && !ok {
+		return "", "", 0, fmt.Errorf("failed to run command: %w", err)
+	}
+
+	return result.Stdout, result.Stderr, int64(result.ExitCode), nil
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestCommandDataSource(t *testing.T) {
+	// Arrange - set up a single Docker container for all test cases
+	setup := setupTestEnvironment(t)
+
+	t.Run("Test successful command", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testCommandDataSourceConfig(`echo hello && echo world >&2`),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("data.setup_command.test", "stdout", "hello\n"),
+						resource.TestCheckResourceAttr("data.setup_command.test", "stderr", "world\n"),
+						resource.TestCheckResourceAttr("data.setup_command.test", "exit_code", "0"),
+					),
+				},
+			},
+		})
+	})
+
+	t.Run("Test failing command", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testCommandDataSourceConfig(`test -b /dev/does-not-exist`),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("data.setup_command.test", "stdout", ""),
+						resource.TestCheckResourceAttr("data.setup_command.test", "exit_code", "1"),
+					),
+				},
+			},
+		})
+	})
+}
+
+func testCommandDataSourceConfig(command string) string {
+	return fmt.Sprintf(`
+data "setup_command" "test" {
+	command = %q
+}
+`, command)
+}
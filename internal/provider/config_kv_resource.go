@@ -0,0 +1,324 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &configKVResource{}
+
+func newConfigKVResource(p *internalProvider) resource.Resource {
+	return &configKVResource{
+		provider: p,
+	}
+}
+
+// configKVResource defines the resource implementation.
+type configKVResource struct {
+	provider *internalProvider
+}
+
+type configKVResourceModel struct {
+	Path            types.String `tfsdk:"path"`
+	Key             types.String `tfsdk:"key"`
+	Value           types.String `tfsdk:"value"`
+	Separator       types.String `tfsdk:"separator"`
+	Quote           types.Bool   `tfsdk:"quote"`
+	Create          types.Bool   `tfsdk:"create"`
+	RemoveOnDestroy types.Bool   `tfsdk:"remove_on_destroy"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *configKVResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_config_kv"
+}
+
+func (r *configKVResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Config key value resource that sets a KEY=VALUE line of a simple configuration file, such " +
+			"as /etc/default/* or .env files. The other lines of the file, comments included, are left as they are, " +
+			"except the duplicates of the key that are removed so that its value is unambiguous",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the file",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Required:    true,
+				Description: "The key, e.g. GRUB_TIMEOUT. A line starting with 'export ' also sets the key",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				Required:    true,
+				Description: "The value of the key, without quotes",
+			},
+			"separator": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("="),
+				Description: "The separator between the key and the value, e.g. ': ' for YAML-like files. Defaults to =",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"quote": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the value is written between double quotes, as needed by values with spaces in shell sourced files. Defaults to false",
+			},
+			"create": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether a missing file is created, with mode 644 and owned by the connecting user. Defaults to false",
+			},
+			"remove_on_destroy": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether destroying the resource removes the key from the file. Defaults to false",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *configKVResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *configKVResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan configKVResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set config value", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *configKVResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model configKVResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read config file", err.Error())
+		return
+	}
+
+	found := false
+
+	for _, line := range lines {
+		value, ok := parseConfigKV(line, model.Key.ValueString(), model.Separator.ValueString())
+		if ok {
+			// The last occurrence wins, as when the file is sourced by a shell
+			model.Value = types.StringValue(value)
+			found = true
+		}
+	}
+
+	if !found {
+		// The key, or the file, was removed outside of terraform, it must be set again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *configKVResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan configKVResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set config value", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *configKVResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model configKVResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !model.RemoveOnDestroy.ValueBool() {
+		return
+	}
+
+	// A missing file is edited as an empty one, which has nothing to remove and is not created
+	err := r.provider.editFile(ctx, model.Path.ValueString(), true, func(lines []string) ([]string, bool) {
+		kept := []string{}
+
+		for _, line := range lines {
+			if _, ok := parseConfigKV(line, model.Key.ValueString(), model.Separator.ValueString()); !ok {
+				kept = append(kept, line)
+			}
+		}
+
+		return kept, len(kept) != len(lines)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove config value", err.Error())
+		return
+	}
+}
+
+// apply replaces the first line of the key and removes the others, or appends the key when missing.
+func (r *configKVResource) apply(ctx context.Context, plan configKVResourceModel) error {
+	key := plan.Key.ValueString()
+	separator := plan.Separator.ValueString()
+
+	value := plan.Value.ValueString()
+	if plan.Quote.ValueBool() {
+		value = strconv.Quote(value)
+	}
+
+	return r.provider.editFile(ctx, plan.Path.ValueString(), plan.Create.ValueBool(), func(lines []string) ([]string, bool) {
+		return setConfigKV(lines, key, separator, key+separator+value)
+	})
+}
+
+// setConfigKV returns the lines with the first line of the key replaced by line, and the other lines of the key
+// removed. A missing key is appended.
+func setConfigKV(lines []string, key string, separator string, line string) ([]string, bool) {
+	edited := []string{}
+	found := false
+	changed := false
+
+	for _, existing := range lines {
+		if _, ok := parseConfigKV(existing, key, separator); !ok {
+			edited = append(edited, existing)
+			continue
+		}
+
+		if found {
+			changed = true
+			continue
+		}
+
+		replacement := line
+
+		// An exported key stays exported
+		if strings.HasPrefix(strings.TrimSpace(existing), "export ") {
+			replacement = "export " + line
+		}
+
+		edited = append(edited, replacement)
+		found = true
+		changed = changed || existing != replacement
+	}
+
+	if !found {
+		return append(edited, line), true
+	}
+
+	return edited, changed
+}
+
+// parseConfigKV returns the unquoted value of the line when it sets the key.
+func parseConfigKV(line string, key string, separator string) (string, bool) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "export ")
+
+	if !strings.HasPrefix(line, key) {
+		return "", false
+	}
+
+	rest := strings.TrimLeft(line[len(key):], " \t")
+	trimmedSeparator := strings.TrimSpace(separator)
+
+	// With a blank separator, the key must still be followed by one, e.g. not match KEY2 for KEY
+	if !strings.HasPrefix(rest, trimmedSeparator) || (trimmedSeparator == "" && len(rest) == len(line)-len(key) && rest != "") {
+		return "", false
+	}
+
+	value := strings.TrimSpace(rest[len(trimmedSeparator):])
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		if value[0] == '"' {
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				return unquoted, true
+			}
+		}
+
+		return value[1 : len(value)-1], true
+	}
+
+	return value, true
+}
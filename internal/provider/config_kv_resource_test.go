@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestConfigKVResource(t *testing.T) {
+	t.Run("Test set a quoted value", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sshClient.RunCommand(context.Background(), "printf '# defaults\\nGRUB_TIMEOUT=5\\n' > /tmp/grub")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_config_kv" "test" {
+  path  = "/tmp/grub"
+  key   = "GRUB_CMDLINE_LINUX"
+  value = "quiet splash"
+  quote = true
+}
+`,
+					Check: func(_ *terraform.State) error {
+						content, err := sshClient.RunCommand(context.Background(), "cat /tmp/grub")
+						if err != nil {
+							return fmt.Errorf("file not found")
+						}
+
+						if content != "# defaults\nGRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX=\"quiet splash\"\n" {
+							return fmt.Errorf("unexpected content: %s", content)
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestConfigKVResourceWithMock(t *testing.T) {
+	t.Run("create replaces the key and removes its duplicates", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/srv/app/.env", "# app\nexport DEBUG=1\nPORT=80\nDEBUG=2\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		// Act
+		_, diags := testResourceCreate(t, newConfigKVResource(newTestProvider(mock)), configKVResourceModel{
+			Path:            types.StringValue("/srv/app/.env"),
+			Key:             types.StringValue("DEBUG"),
+			Value:           types.StringValue("0"),
+			Separator:       types.StringValue("="),
+			Quote:           types.BoolValue(false),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/srv/app/.env"] != "# app\nexport DEBUG=0\nPORT=80\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/srv/app/.env"])
+		}
+	})
+
+	t.Run("create does not match a key with the same prefix", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/default/app", "PORT_ADMIN=81\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newConfigKVResource(newTestProvider(mock)), configKVResourceModel{
+			Path:            types.StringValue("/etc/default/app"),
+			Key:             types.StringValue("PORT"),
+			Value:           types.StringValue("80"),
+			Separator:       types.StringValue("="),
+			Quote:           types.BoolValue(false),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/default/app"] != "PORT_ADMIN=81\nPORT=80\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/default/app"])
+		}
+	})
+
+	t.Run("read unquotes the value", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/default/grub", "GRUB_CMDLINE_LINUX=\"quiet splash\"\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newConfigKVResource(newTestProvider(mock)), configKVResourceModel{
+			Path:            types.StringValue("/etc/default/grub"),
+			Key:             types.StringValue("GRUB_CMDLINE_LINUX"),
+			Value:           types.StringValue("quiet"),
+			Separator:       types.StringValue("="),
+			Quote:           types.BoolValue(true),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Value.ValueString() != "quiet splash" {
+			t.Fatalf("unexpected value: %s", state.Value)
+		}
+	})
+
+	t.Run("read removes the resource when the key is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/default/grub", "#GRUB_CMDLINE_LINUX=\"quiet\"\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newConfigKVResource(newTestProvider(mock)), configKVResourceModel{
+			Path:            types.StringValue("/etc/default/grub"),
+			Key:             types.StringValue("GRUB_CMDLINE_LINUX"),
+			Value:           types.StringValue("quiet"),
+			Separator:       types.StringValue("="),
+			Quote:           types.BoolValue(true),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed: %v", diags)
+		}
+	})
+}
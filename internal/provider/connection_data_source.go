@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &connectionDataSource{}
+)
+
+// connectionCommand prints the name of the distribution, or of the kernel when it is unknown, followed by the uptime in
+// seconds.
+const connectionCommand = `. /etc/os-release 2>/dev/null; echo "${PRETTY_NAME:-$(uname -s)}"; cut -d ' ' -f 1 /proc/uptime`
+
+func newConnectionDataSource(p *internalProvider) datasource.DataSource {
+	return &connectionDataSource{
+		provider: p,
+	}
+}
+
+type connectionDataSource struct {
+	provider *internalProvider
+}
+
+type connectionDataSourceModel struct {
+	FailOnError types.Bool   `tfsdk:"fail_on_error"`
+	Reachable   types.Bool   `tfsdk:"reachable"`
+	SudoOK      types.Bool   `tfsdk:"sudo_ok"`
+	OS          types.String `tfsdk:"os"`
+	Uptime      types.Int64  `tfsdk:"uptime"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *connectionDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_connection"
+}
+
+func (d *connectionDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Checks that the provider can connect to the host and run commands with elevated privileges, " +
+			"so that a plan fails early with a clear message rather than on the first resource",
+
+		Attributes: map[string]schema.Attribute{
+			"fail_on_error": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether an unreachable host, or a failure to elevate privileges, is an error. When false, they are only reported by reachable and sudo_ok. Defaults to true",
+			},
+			"reachable": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the host could be reached and a command run on it",
+			},
+			"sudo_ok": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether commands run as the become_user of the provider, root by default, through the privilege escalation configured in the provider or because the provider connects as that user",
+			},
+			"os": schema.StringAttribute{
+				Computed:    true,
+				Description: "The name of the distribution of the host, e.g. 'Ubuntu 24.04.1 LTS', null when it is not reachable",
+			},
+			"uptime": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The time since the host booted in seconds, null when it is not reachable",
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *connectionDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	var model connectionDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !d.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	failOnError := model.FailOnError.IsNull() || model.FailOnError.ValueBool()
+
+	model.Reachable = types.BoolValue(false)
+	model.SudoOK = types.BoolValue(false)
+	model.OS = types.StringNull()
+	model.Uptime = types.Int64Null()
+
+	out, err := d.provider.machineAccessClient.RunCommand(ctx, connectionCommand)
+	if err != nil {
+		if failOnError {
+			resp.Diagnostics.AddError("Host unreachable", fmt.Sprintf("Failed to run a command on the host, check the host, port, user and credentials of the provider. Err=%s\nout = %s", err, out))
+			return
+		}
+
+		diags = resp.State.Set(ctx, &model)
+		resp.Diagnostics.Append(diags...)
+
+		return
+	}
+
+	model.Reachable = types.BoolValue(true)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	model.OS = types.StringValue(strings.TrimSpace(lines[0]))
+
+	if uptime, err := strconv.ParseFloat(strings.TrimSpace(lines[len(lines)-1]), 64); len(lines) == 2 && err == nil {
+		model.Uptime = types.Int64Value(int64(uptime))
+	}
+
+	// The elevated commands run as the become user, root when none is configured
+	becomeUser := d.provider.become.User
+	if becomeUser == "" {
+		becomeUser = "root"
+	}
+
+	user, err := d.provider.machineAccessClient.RunCommand(ctx, d.provider.sudo("id -un"))
+	model.SudoOK = types.BoolValue(err == nil && strings.TrimSpace(user) == becomeUser)
+
+	if !model.SudoOK.ValueBool() && failOnError {
+		resp.Diagnostics.AddError("Privilege escalation failed", fmt.Sprintf("Commands do not run as %s, check the privilege escalation settings of the provider and the sudo configuration of the user. Err=%v\nout = %s", becomeUser, err, user))
+		return
+	}
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
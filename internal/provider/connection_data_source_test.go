@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestConnectionDataSourceWithMock(t *testing.T) {
+	t.Run("a reachable host with sudo", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(connectionCommand, clients.MockResponse{Stdout: "Ubuntu 24.04.1 LTS\n86461.27\n"}).
+			On("id -un", clients.MockResponse{Stdout: "root\n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newConnectionDataSource(newTestProvider(mock)), connectionDataSourceModel{})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.Reachable.ValueBool() || !state.SudoOK.ValueBool() || state.OS.ValueString() != "Ubuntu 24.04.1 LTS" || state.Uptime.ValueInt64() != 86461 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("an unreachable host fails with a clear message", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(connectionCommand, clients.MockResponse{Err: errors.New("dial tcp 10.0.0.1:22: connect: connection refused")})
+
+		// Act
+		_, diags := testDataSourceRead(t, newConnectionDataSource(newTestProvider(mock)), connectionDataSourceModel{})
+
+		// Assert
+		if !diags.HasError() || diags[0].Summary() != "Host unreachable" || !strings.Contains(diags[0].Detail(), "connection refused") {
+			t.Fatalf("expected an unreachable error: %v", diags)
+		}
+	})
+
+	t.Run("an unreachable host is reported when fail_on_error is false", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(connectionCommand, clients.MockResponse{Err: errors.New("i/o timeout")})
+
+		// Act
+		state, diags := testDataSourceRead(t, newConnectionDataSource(newTestProvider(mock)), connectionDataSourceModel{FailOnError: types.BoolValue(false)})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Reachable.ValueBool() || state.SudoOK.ValueBool() || !state.OS.IsNull() || !state.Uptime.IsNull() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("a user that is not root without privilege escalation", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(connectionCommand, clients.MockResponse{Stdout: "Debian GNU/Linux 12 (bookworm)\n12.5\n"}).
+			On("id -un", clients.MockResponse{Stdout: "debian\n"})
+
+		// Act
+		_, failed := testDataSourceRead(t, newConnectionDataSource(newTestProvider(mock)), connectionDataSourceModel{})
+		state, diags := testDataSourceRead(t, newConnectionDataSource(newTestProvider(mock)), connectionDataSourceModel{FailOnError: types.BoolValue(false)})
+
+		// Assert
+		if !failed.HasError() || failed[0].Summary() != "Privilege escalation failed" {
+			t.Fatalf("expected a privilege escalation error: %v", failed)
+		}
+
+		if diags.HasError() || !state.Reachable.ValueBool() || state.SudoOK.ValueBool() {
+			t.Fatalf("unexpected result: %v, %+v", diags, state)
+		}
+	})
+
+	t.Run("a become user that is not root", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(connectionCommand, clients.MockResponse{Stdout: "Debian GNU/Linux 12 (bookworm)\n12.5\n"}).
+			OnMatch(`id -un`, clients.MockResponse{Stdout: "postgres\n"})
+
+		p := newTestProvider(mock)
+		p.become = clients.Become{User: "postgres"}
+
+		// Act
+		state, diags := testDataSourceRead(t, newConnectionDataSource(p), connectionDataSourceModel{})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.SudoOK.ValueBool() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+}
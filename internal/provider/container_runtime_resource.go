@@ -0,0 +1,717 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &containerRuntimeResource{}
+var _ resource.ResourceWithModifyPlan = &containerRuntimeResource{}
+
+const (
+	containerdConfig      = "/etc/containerd/config.toml"
+	containerdCertsDir    = "/etc/containerd/certs.d"
+	containerdDockerHub   = "docker.io"
+	containerdDefaultHost = "_default"
+)
+
+// containerdSections are the tables of the SystemdCgroup option of runc and of the registry configuration, by version
+// of the configuration file. containerd 1.x writes version 2 files, and containerd 2.x version 3 files.
+var containerdSections = map[string]struct {
+	runc     string
+	registry string
+}{
+	"2": {
+		runc:     `plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options`,
+		registry: `plugins."io.containerd.grpc.v1.cri".registry`,
+	},
+	"3": {
+		runc:     `plugins."io.containerd.cri.v1.runtime".containerd.runtimes.runc.options`,
+		registry: `plugins."io.containerd.cri.v1.images".registry`,
+	},
+}
+
+var (
+	containerdRegistryPattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?(:[0-9]+)?|_default)$`)
+	containerdKeyPattern      = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+func newContainerRuntimeResource(p *internalProvider) resource.Resource {
+	return &containerRuntimeResource{
+		provider: p,
+	}
+}
+
+// containerRuntimeResource defines the resource implementation.
+type containerRuntimeResource struct {
+	provider *internalProvider
+}
+
+type containerRuntimeResourceModel struct {
+	Runtime         types.String   `tfsdk:"runtime"`
+	Package         types.String   `tfsdk:"package"`
+	SystemdCgroup   types.Bool     `tfsdk:"systemd_cgroup"`
+	RegistryMirrors types.Map      `tfsdk:"registry_mirrors"`
+	Settings        types.Map      `tfsdk:"settings"`
+	AptOptions      types.List     `tfsdk:"apt_options"`
+	Version         types.String   `tfsdk:"version"`
+	Timeouts        *timeoutsModel `tfsdk:"timeouts"`
+	Retry           *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *containerRuntimeResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_container_runtime"
+}
+
+func (r *containerRuntimeResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Container runtime resource that installs containerd with apt, without Docker, and configures " +
+			containerdConfig + " for Kubernetes nodes. The file is replaced by the output of containerd config default " +
+			"when it is missing or has no version, e.g. the stub of the containerd.io package disabling the CRI plugin. " +
+			"The keys set by the resource are then changed in place, the other lines of the file are left as they are, " +
+			"and containerd is restarted when the file changed. Destroying the resource leaves containerd and its " +
+			"configuration as they are",
+
+		Attributes: map[string]schema.Attribute{
+			"runtime": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("containerd"),
+				Description: "The container runtime. Only containerd is supported. Defaults to containerd",
+				Validators:  []validator.String{oneOf("containerd")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"package": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("containerd"),
+				Description: "The apt package of containerd, e.g. containerd.io from the Docker repository. Defaults to containerd, from the repositories of the distribution",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"systemd_cgroup": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether runc uses the systemd cgroup driver, which the kubelet expects on hosts booted with systemd. Defaults to true",
+			},
+			"registry_mirrors": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.ListType{ElemType: types.StringType},
+				Description: "The mirrors of the registries, by registry host, e.g. { \"docker.io\" = [\"https://mirror.gcr.io\"] }. " +
+					"_default applies to all the registries. Each registry gets a hosts.toml file in " + containerdCertsDir +
+					", which becomes the config_path of the registries. The mirrors are tried in order, before the registry itself",
+			},
+			"settings": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.MapType{ElemType: types.StringType},
+				Description: "The other keys of the configuration file, by table, whose values are TOML values written as they are, e.g. " +
+					"{ \"plugins.\\\"io.containerd.grpc.v1.cri\\\"\" = { sandbox_image = \"\\\"registry.k8s.io/pause:3.9\\\"\" } }. " +
+					"The table \"\" is the top of the file. The keys removed from the settings are removed from the file, so that containerd uses their defaults",
+			},
+			"apt_options": aptOptionsAttribute(),
+			"version": schema.StringAttribute{
+				Computed:    true,
+				Description: "The installed version of the package",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *containerRuntimeResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan checks the registries and the keys of the settings, which are written in TOML files.
+func (r *containerRuntimeResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan containerRuntimeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	mirrors, settings, diags := plan.configuration(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	for _, registry := range sortedKeys(mirrors) {
+		if !containerdRegistryPattern.MatchString(registry) {
+			resp.Diagnostics.AddAttributeError(path.Root("registry_mirrors").AtMapKey(registry), "Invalid registry",
+				fmt.Sprintf("%s must be the host of a registry with an optional port, e.g. docker.io or registry.internal:5000, or _default", registry))
+		}
+	}
+
+	for _, table := range sortedKeys(settings) {
+		for _, key := range sortedKeys(settings[table]) {
+			if !containerdKeyPattern.MatchString(key) {
+				resp.Diagnostics.AddAttributeError(path.Root("settings").AtMapKey(table), "Invalid key",
+					fmt.Sprintf("%s must be a bare TOML key, made of letters, digits, underscores and dashes", key))
+			}
+
+			if strings.TrimSpace(settings[table][key]) == "" || strings.Contains(settings[table][key], "\n") {
+				resp.Diagnostics.AddAttributeError(path.Root("settings").AtMapKey(table), "Invalid value",
+					fmt.Sprintf("the value of %s must be a TOML value on a single line", key))
+			}
+		}
+	}
+}
+
+func (r *containerRuntimeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan containerRuntimeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	// docker_setup may be installing containerd.io, or restarting containerd, at the same time
+	defer r.provider.lock(ctx, dockerLock)()
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install containerd", err.Error())
+		return
+	}
+
+	// The package starts containerd with the configuration it ships, so it is always restarted
+	r.apply(ctx, plan, nil, true, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.Version, err = r.installedVersion(ctx, plan.Package.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the containerd version", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *containerRuntimeResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model containerRuntimeResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// dpkg-query exits with 1 when the package is not installed, the other errors are failures to read it
+	version, err := r.installedVersion(ctx, model.Package.ValueString())
+	if clients.IsExitCode(err, 1) {
+		// containerd was removed outside of terraform, it must be installed again
+		tflog.Debug(ctx, "containerd is not installed: "+err.Error())
+		resp.State.RemoveResource(ctx)
+
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the containerd version", err.Error())
+		return
+	}
+
+	model.Version = version
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, containerdConfig)
+	if clients.IsFileNotFound(err) {
+		// The configuration was removed outside of terraform, it must be written again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the containerd configuration", err.Error())
+		return
+	}
+
+	mirrors, settings, diags := model.configuration(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	systemdCgroup := false
+	if sections, ok := containerdSections[containerdConfigVersion(lines)]; ok {
+		if index, found := findINIKey(lines, containerdSection(lines, sections.runc), "SystemdCgroup"); found {
+			_, value := parseINIKey(lines[index])
+			systemdCgroup = value == "true"
+		}
+	}
+
+	model.SystemdCgroup = types.BoolValue(systemdCgroup)
+
+	// Only the keys of the settings are compared, the other keys of the file may change freely
+	if !model.Settings.IsNull() {
+		actual := map[string]map[string]string{}
+
+		for table, values := range settings {
+			actual[table] = map[string]string{}
+
+			for key := range values {
+				if index, found := findINIKey(lines, containerdSection(lines, table), key); found {
+					_, actual[table][key] = parseINIKey(lines[index])
+				}
+			}
+		}
+
+		model.Settings, diags = types.MapValueFrom(ctx, types.MapType{ElemType: types.StringType}, actual)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if !model.RegistryMirrors.IsNull() {
+		actual := map[string][]string{}
+
+		for registry := range mirrors {
+			hosts, err := readLines(ctx, r.provider.machineAccessClient, containerdHostsFile(registry))
+			if clients.IsFileNotFound(err) {
+				continue
+			}
+
+			if err != nil {
+				resp.Diagnostics.AddError("Failed to read the mirrors of "+registry, err.Error())
+				return
+			}
+
+			actual[registry] = parseContainerdMirrors(hosts)
+		}
+
+		model.RegistryMirrors, diags = types.MapValueFrom(ctx, types.ListType{ElemType: types.StringType}, actual)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *containerRuntimeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan containerRuntimeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state containerRuntimeResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	defer r.provider.lock(ctx, dockerLock)()
+
+	r.apply(ctx, plan, &state, false, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *containerRuntimeResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+
+}
+
+// install installs the package of containerd from the freshly updated package lists.
+func (r *containerRuntimeResource) install(ctx context.Context, plan containerRuntimeResourceModel) error {
+	var options []string
+
+	if diags := plan.AptOptions.ElementsAs(ctx, &options, false); diags.HasError() {
+		return fmt.Errorf("invalid apt options: %v", diags)
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		return fmt.Errorf("failed to configure the apt proxy: %w", err)
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	command := r.provider.sudo(r.provider.withProxy(aptGet(options, "update") + " && " + aptGet(options, "install", "-y", plan.Package.ValueString())))
+
+	out, err := retry(ctx, plan.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), command)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install %s. Err=%w\nout = %s", plan.Package.ValueString(), err, out)
+	}
+
+	return nil
+}
+
+// apply writes the configuration file and the mirrors of the registries, removing the settings and the registries of
+// the previous state that are not planned anymore. containerd is restarted when the configuration file changed, or
+// when restart is set.
+func (r *containerRuntimeResource) apply(ctx context.Context, plan containerRuntimeResourceModel, previous *containerRuntimeResourceModel, restart bool, diags *diag.Diagnostics) {
+	mirrors, settings, d := plan.configuration(ctx)
+	diags.Append(d...)
+
+	previousMirrors := map[string][]string{}
+	previousSettings := map[string]map[string]string{}
+
+	if previous != nil {
+		previousMirrors, previousSettings, d = previous.configuration(ctx)
+		diags.Append(d...)
+	}
+
+	if diags.HasError() {
+		return
+	}
+
+	generated, err := r.generateDefaultConfig(ctx)
+	if err != nil {
+		diags.AddError("Failed to generate the containerd configuration", err.Error())
+		return
+	}
+
+	changed := generated
+
+	var editErr error
+
+	err = r.provider.editFile(ctx, containerdConfig, false, func(lines []string) ([]string, bool) {
+		version := containerdConfigVersion(lines)
+
+		sections, ok := containerdSections[version]
+		if !ok {
+			editErr = fmt.Errorf("the version %s of %s is not supported, only versions 2 and 3 are", version, containerdConfig)
+			return lines, false
+		}
+
+		edited := lines
+
+		set := func(section string, key string, value string) {
+			var keyChanged bool
+
+			edited, keyChanged = setContainerdKey(edited, section, key, value)
+			changed = changed || keyChanged
+		}
+
+		set(sections.runc, "SystemdCgroup", fmt.Sprint(plan.SystemdCgroup.ValueBool()))
+
+		if len(mirrors) > 0 {
+			set(sections.registry, "config_path", `"`+containerdCertsDir+`"`)
+		}
+
+		for _, table := range sortedKeys(previousSettings) {
+			for _, key := range sortedKeys(previousSettings[table]) {
+				if _, ok := settings[table][key]; ok {
+					continue
+				}
+
+				if index, found := findINIKey(edited, containerdSection(edited, table), key); found {
+					edited = append(append([]string{}, edited[:index]...), edited[index+1:]...)
+					changed = true
+				}
+			}
+		}
+
+		for _, table := range sortedKeys(settings) {
+			for _, key := range sortedKeys(settings[table]) {
+				set(table, key, settings[table][key])
+			}
+		}
+
+		return edited, !slices.Equal(edited, lines)
+	})
+	if err == nil {
+		err = editErr
+	}
+
+	if err != nil {
+		diags.AddError("Failed to write the containerd configuration", err.Error())
+		return
+	}
+
+	for _, registry := range sortedKeys(previousMirrors) {
+		if _, ok := mirrors[registry]; ok {
+			continue
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-rf", containerdCertsDir+"/"+registry)))
+		if err != nil {
+			diags.AddError("Failed to remove the mirrors of "+registry, fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	for _, registry := range sortedKeys(mirrors) {
+		err := r.writeMirrors(ctx, registry, mirrors[registry])
+		if err != nil {
+			diags.AddError("Failed to write the mirrors of "+registry, err.Error())
+			return
+		}
+	}
+
+	if !changed && !restart {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl enable containerd && systemctl restart containerd"))
+	if err != nil {
+		diags.AddError("Failed to restart containerd", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// generateDefaultConfig replaces the configuration file by the default configuration of the installed containerd when
+// it is missing or has no version. It returns whether the file was replaced.
+func (r *containerRuntimeResource) generateDefaultConfig(ctx context.Context) (bool, error) {
+	lines, err := readLines(ctx, r.provider.machineAccessClient, containerdConfig)
+	if err != nil && !clients.IsFileNotFound(err) {
+		return false, fmt.Errorf("failed to read %s: %w", containerdConfig, err)
+	}
+
+	if containerdConfigVersion(lines) != "" {
+		return false, nil
+	}
+
+	config, err := r.provider.machineAccessClient.RunCommand(ctx, "containerd config default")
+	if err != nil {
+		return false, fmt.Errorf("failed to get the default configuration. Err=%w\nout = %s", err, config)
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("install -d -m 0755 /etc/containerd"))
+	if err != nil {
+		return false, fmt.Errorf("failed to create /etc/containerd. Err=%w\nout = %s", err, out)
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, containerdConfig, "0644", "0", "0", config)
+	if err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", containerdConfig, err)
+	}
+
+	return true, nil
+}
+
+// writeMirrors writes the hosts.toml file of the registry, when its content changed.
+func (r *containerRuntimeResource) writeMirrors(ctx context.Context, registry string, mirrors []string) error {
+	filePath := containerdHostsFile(registry)
+	content := renderContainerdHosts(registry, mirrors)
+
+	existing, err := r.provider.machineAccessClient.ReadFile(ctx, filePath, true)
+	if err == nil && existing == content {
+		return nil
+	}
+
+	if err != nil && !clients.IsFileNotFound(err) {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("install", "-d", "-m", "0755", containerdCertsDir+"/"+registry)))
+	if err != nil {
+		return fmt.Errorf("failed to create the directory of %s. Err=%w\nout = %s", filePath, err, out)
+	}
+
+	return r.provider.machineAccessClient.WriteFile(ctx, filePath, "0644", "0", "0", content)
+}
+
+// installedVersion returns the version of the installed package.
+func (r *containerRuntimeResource) installedVersion(ctx context.Context, pkg string) (types.String, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "dpkg-query -W -f='${Version}' "+clients.ShellQuote(pkg))
+	if err != nil {
+		return types.StringNull(), fmt.Errorf("%s is not installed. Err=%w\nout = %s", pkg, err, out)
+	}
+
+	return types.StringValue(strings.TrimSpace(out)), nil
+}
+
+// configuration returns the mirrors by registry and the settings by table, empty when the attributes are null.
+func (model containerRuntimeResourceModel) configuration(ctx context.Context) (map[string][]string, map[string]map[string]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	mirrors := map[string][]string{}
+	settings := map[string]map[string]string{}
+
+	if !model.RegistryMirrors.IsNull() && !model.RegistryMirrors.IsUnknown() {
+		diags.Append(model.RegistryMirrors.ElementsAs(ctx, &mirrors, false)...)
+	}
+
+	if !model.Settings.IsNull() && !model.Settings.IsUnknown() {
+		diags.Append(model.Settings.ElementsAs(ctx, &settings, false)...)
+	}
+
+	return mirrors, settings, diags
+}
+
+// containerdConfigVersion returns the version of the configuration file, or an empty string when it has none.
+func containerdConfigVersion(lines []string) string {
+	index, found := findINIKey(lines, "", "version")
+	if !found {
+		return ""
+	}
+
+	_, value := parseINIKey(lines[index])
+
+	return value
+}
+
+// containerdSection returns the name of the table as written in the file, whose headers quote the names of the plugins
+// with either double or single quotes, e.g. plugins.'io.containerd.cri.v1.images' for containerd 2.x. The name is
+// returned as it is when the table is missing.
+func containerdSection(lines []string, section string) string {
+	normalize := func(name string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(name, "'", `"`), " ", "")
+	}
+
+	for _, line := range lines {
+		if name, ok := parseINISectionHeader(line); ok && normalize(name) == normalize(section) {
+			return name
+		}
+	}
+
+	return section
+}
+
+// setContainerdKey sets the value of the key in the table, keeping the indentation of the line of the key.
+func setContainerdKey(lines []string, section string, key string, value string) ([]string, bool) {
+	section = containerdSection(lines, section)
+	line := key + " = " + value
+
+	if index, found := findINIKey(lines, section, key); found {
+		existing := lines[index]
+		line = existing[:len(existing)-len(strings.TrimLeft(existing, " \t"))] + line
+	}
+
+	return setINIKey(lines, section, key, line)
+}
+
+// containerdHostsFile returns the path of the hosts.toml file of the registry.
+func containerdHostsFile(registry string) string {
+	return containerdCertsDir + "/" + registry + "/hosts.toml"
+}
+
+// renderContainerdHosts returns the hosts.toml file of the registry, which lists its mirrors before the registry.
+func renderContainerdHosts(registry string, mirrors []string) string {
+	lines := []string{}
+
+	switch registry {
+	case containerdDefaultHost:
+	case containerdDockerHub:
+		lines = append(lines, `server = "https://registry-1.docker.io"`)
+	default:
+		lines = append(lines, `server = "https://`+registry+`"`)
+	}
+
+	for _, mirror := range mirrors {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, `[host."`+mirror+`"]`, `  capabilities = ["pull", "resolve"]`)
+	}
+
+	return joinLines(lines)
+}
+
+// parseContainerdMirrors returns the mirrors of a hosts.toml file, in order.
+func parseContainerdMirrors(lines []string) []string {
+	mirrors := []string{}
+
+	for _, line := range lines {
+		name, ok := parseINISectionHeader(line)
+		if !ok || !strings.HasPrefix(name, "host.") {
+			continue
+		}
+
+		mirrors = append(mirrors, strings.Trim(strings.TrimPrefix(name, "host."), `"'`))
+	}
+
+	return mirrors
+}
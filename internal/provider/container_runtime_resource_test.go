@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testContainerdDefaultConfig = `version = 2
+
+[plugins]
+  [plugins."io.containerd.grpc.v1.cri"]
+    sandbox_image = "registry.k8s.io/pause:3.8"
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+      BinaryName = ""
+      SystemdCgroup = false
+    [plugins."io.containerd.grpc.v1.cri".registry]
+      config_path = ""
+`
+
+func TestContainerRuntimeResourceWithMock(t *testing.T) {
+	t.Run("create replaces the configuration without version and configures the mirrors", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(containerdConfig, "disabled_plugins = [\"cri\"]\n", clients.FileInfo{Mode: "644"}).
+			On("containerd config default", clients.MockResponse{Stdout: testContainerdDefaultConfig}).
+			OnMatch(`^dpkg-query `, clients.MockResponse{Stdout: "1.6.20~ds1-1+b1"})
+
+		model := testContainerRuntimeModel()
+		model.RegistryMirrors = types.MapValueMust(types.ListType{ElemType: types.StringType}, map[string]attr.Value{
+			"docker.io": types.ListValueMust(types.StringType, []attr.Value{types.StringValue("https://mirror.gcr.io")}),
+		})
+
+		// Act
+		state, diags := testResourceCreate(t, newContainerRuntimeResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		config := mock.Files[containerdConfig]
+		if !strings.Contains(config, "\n      SystemdCgroup = true\n") || !strings.Contains(config, "\n      config_path = \"/etc/containerd/certs.d\"\n") {
+			t.Fatalf("unexpected configuration:\n%s", config)
+		}
+
+		if mock.Files["/etc/containerd/certs.d/docker.io/hosts.toml"] != "server = \"https://registry-1.docker.io\"\n\n[host.\"https://mirror.gcr.io\"]\n  capabilities = [\"pull\", \"resolve\"]\n" {
+			t.Fatalf("unexpected hosts.toml:\n%s", mock.Files["/etc/containerd/certs.d/docker.io/hosts.toml"])
+		}
+
+		if !testContainerRuntimeCommandRan(mock, "apt-get -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold install -y containerd") ||
+			!testContainerRuntimeCommandRan(mock, "systemctl restart containerd") {
+			t.Fatalf("expected containerd to be installed and restarted, got: %v", mock.Commands)
+		}
+
+		if state.Version.ValueString() != "1.6.20~ds1-1+b1" {
+			t.Fatalf("unexpected version: %s", state.Version.ValueString())
+		}
+	})
+
+	t.Run("update removes the settings and the mirrors that are not configured anymore", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(containerdConfig, strings.Replace(testContainerdDefaultConfig, "SystemdCgroup = false", "SystemdCgroup = true", 1)+"oom_score = -999\n", clients.FileInfo{Mode: "644"})
+
+		state := testContainerRuntimeModel()
+		state.Version = types.StringValue("1.6.20~ds1-1+b1")
+		state.Settings = types.MapValueMust(types.MapType{ElemType: types.StringType}, map[string]attr.Value{
+			`plugins."io.containerd.grpc.v1.cri"`: types.MapValueMust(types.StringType, map[string]attr.Value{"enable_cdi": types.StringValue("true")}),
+		})
+		state.RegistryMirrors = types.MapValueMust(types.ListType{ElemType: types.StringType}, map[string]attr.Value{
+			"ghcr.io": types.ListValueMust(types.StringType, []attr.Value{types.StringValue("https://mirror.internal")}),
+		})
+
+		plan := state
+		plan.Settings = types.MapNull(types.MapType{ElemType: types.StringType})
+		plan.RegistryMirrors = types.MapNull(types.ListType{ElemType: types.StringType})
+
+		mock.Files[containerdConfig] = strings.Replace(mock.Files[containerdConfig], "    sandbox_image", "    enable_cdi = true\n    sandbox_image", 1)
+
+		// Act
+		_, diags := testResourceUpdate(t, newContainerRuntimeResource(newTestProvider(mock)), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if strings.Contains(mock.Files[containerdConfig], "enable_cdi") || !strings.Contains(mock.Files[containerdConfig], "oom_score = -999") {
+			t.Fatalf("unexpected configuration:\n%s", mock.Files[containerdConfig])
+		}
+
+		if !testContainerRuntimeCommandRan(mock, "rm -rf /etc/containerd/certs.d/ghcr.io") || !testContainerRuntimeCommandRan(mock, "systemctl restart containerd") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("update does not restart containerd when the configuration is unchanged", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(containerdConfig, strings.Replace(testContainerdDefaultConfig, "SystemdCgroup = false", "SystemdCgroup = true", 1), clients.FileInfo{Mode: "644"})
+
+		state := testContainerRuntimeModel()
+		state.Version = types.StringValue("1.6.20~ds1-1+b1")
+
+		// Act
+		_, diags := testResourceUpdate(t, newContainerRuntimeResource(newTestProvider(mock)), state, state)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if testContainerRuntimeCommandRan(mock, "systemctl") {
+			t.Fatalf("unexpected restart: %v", mock.Commands)
+		}
+	})
+
+	t.Run("plan rejects registries that are not hosts", func(t *testing.T) {
+		// Arrange
+		plan := testContainerRuntimeModel()
+		plan.Version = types.StringNull()
+		plan.RegistryMirrors = types.MapValueMust(types.ListType{ElemType: types.StringType}, map[string]attr.Value{
+			"https://docker.io": types.ListValueMust(types.StringType, []attr.Value{types.StringValue("https://mirror.gcr.io")}),
+		})
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newContainerRuntimeResource(newTestProvider(clients.NewMockMachineAccessClient())).(*containerRuntimeResource), nil, plan)
+
+		// Assert
+		if diags.ErrorsCount() != 1 {
+			t.Fatalf("expected an invalid registry, got: %v", diags)
+		}
+	})
+
+	t.Run("read reports the cgroup driver changed outside of terraform", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(containerdConfig, strings.ReplaceAll(testContainerdDefaultConfig, `"io.containerd.grpc.v1.cri"`, `'io.containerd.grpc.v1.cri'`), clients.FileInfo{Mode: "644"}).
+			OnMatch(`^dpkg-query `, clients.MockResponse{Stdout: "1.6.20~ds1-1+b1"})
+
+		state := testContainerRuntimeModel()
+		state.Version = types.StringValue("1.6.20~ds1-1+b1")
+
+		// Act
+		read, removed, diags := testResourceRead(t, newContainerRuntimeResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected read: %v", diags)
+		}
+
+		if read.SystemdCgroup.ValueBool() {
+			t.Fatal("expected the cgroupfs driver")
+		}
+	})
+
+	t.Run("read keeps the resource when the version cannot be read", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query `, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		state := testContainerRuntimeModel()
+		state.Version = types.StringValue("1.6.20~ds1-1+b1")
+
+		// Act
+		_, removed, diags := testResourceRead(t, newContainerRuntimeResource(newTestProvider(mock)), state)
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func testContainerRuntimeModel() containerRuntimeResourceModel {
+	return containerRuntimeResourceModel{
+		Runtime:         types.StringValue("containerd"),
+		Package:         types.StringValue("containerd"),
+		SystemdCgroup:   types.BoolValue(true),
+		RegistryMirrors: types.MapNull(types.ListType{ElemType: types.StringType}),
+		Settings:        types.MapNull(types.MapType{ElemType: types.StringType}),
+		AptOptions:      defaultAptOptions(),
+		Version:         types.StringUnknown(),
+	}
+}
+
+func testContainerRuntimeCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
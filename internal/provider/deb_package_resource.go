@@ -0,0 +1,508 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	tfpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &debPackageResource{}
+var _ resource.ResourceWithModifyPlan = &debPackageResource{}
+
+func newDebPackageResource(p *internalProvider) resource.Resource {
+	return &debPackageResource{
+		provider: p,
+	}
+}
+
+// debPackageResource defines the resource implementation.
+type debPackageResource struct {
+	provider *internalProvider
+}
+
+type debPackageResourceModel struct {
+	Source      types.String `tfsdk:"source"`
+	ContentHash types.String `tfsdk:"content_hash"`
+	Packages    types.Map    `tfsdk:"packages"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *debPackageResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_deb_package"
+}
+
+func (r *debPackageResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Deb package resource that uploads local .deb files to the remote machine and installs them with " +
+			"dpkg -i, followed by apt-get -f install when dependencies are missing. It installs packages on hosts without " +
+			"access to the repositories, in which case the dependencies must be given together with the packages. The " +
+			"packages are removed on destroy",
+
+		Attributes: map[string]schema.Attribute{
+			"source": schema.StringAttribute{
+				Required:    true,
+				Description: "Local path of a .deb file, or of a directory whose .deb files are installed together",
+				Validators:  []validator.String{notBlank()},
+			},
+			"content_hash": schema.StringAttribute{
+				Computed:    true,
+				Description: "sha256 of the .deb files, computed while planning so that changed files are installed again",
+			},
+			"packages": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The versions of the installed packages by name, as read from the .deb files",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *debPackageResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan hashes the .deb files, so that their changes are planned as a change of content_hash, and of packages
+// which are only known once the files are installed again.
+func (r *debPackageResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan debPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	plan.ContentHash = types.StringUnknown()
+	plan.Packages = types.MapUnknown(types.StringType)
+
+	// The files may be produced by another resource and only be known while applying
+	if !plan.Source.IsUnknown() {
+		files, err := debPackageFiles(plan.Source.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(tfpath.Root("source"), "Invalid source", err.Error())
+			return
+		}
+
+		contentHash, err := debPackageContentHash(files)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(tfpath.Root("source"), "Failed to hash the .deb files", err.Error())
+			return
+		}
+
+		plan.ContentHash = types.StringValue(contentHash)
+	}
+
+	if !req.State.Raw.IsNull() {
+		var state debPackageResourceModel
+
+		diags = req.State.Get(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+
+		if state.ContentHash.Equal(plan.ContentHash) {
+			plan.Packages = state.Packages
+		}
+	}
+
+	diags = resp.Plan.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *debPackageResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan debPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	r.install(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *debPackageResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state debPackageResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var packages map[string]string
+
+	diags = state.Packages.ElementsAs(ctx, &packages, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	installed, err := r.installedVersions(ctx, sortedKeys(packages))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the installed packages", err.Error())
+		return
+	}
+
+	if len(installed) == 0 {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// A package removed or upgraded since it was installed is installed again from the same files
+	for name, version := range packages {
+		if installed[name] != version {
+			tflog.Info(ctx, fmt.Sprintf("Package %s is at version '%s' instead of '%s'", name, installed[name], version))
+			state.ContentHash = types.StringNull()
+		}
+	}
+
+	state.Packages, diags = types.MapValueFrom(ctx, types.StringType, installed)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *debPackageResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state debPackageResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.install(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The packages that are not part of the files anymore are removed
+	var previous, current map[string]string
+
+	resp.Diagnostics.Append(state.Packages.ElementsAs(ctx, &previous, false)...)
+	resp.Diagnostics.Append(plan.Packages.ElementsAs(ctx, &current, false)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var removed []string
+
+	for _, name := range sortedKeys(previous) {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	if err := r.remove(ctx, removed); err != nil {
+		resp.Diagnostics.AddError("Failed to remove the packages", err.Error())
+		return
+	}
+
+	diags := resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *debPackageResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state debPackageResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var packages map[string]string
+
+	diags = state.Packages.ElementsAs(ctx, &packages, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.remove(ctx, sortedKeys(packages)); err != nil {
+		resp.Diagnostics.AddError("Failed to remove the packages", err.Error())
+		return
+	}
+}
+
+// install uploads the .deb files of the plan to a temporary directory and installs them, then sets the installed
+// packages in the plan.
+func (r *debPackageResource) install(ctx context.Context, plan *debPackageResourceModel, diags *diag.Diagnostics) {
+	files, err := debPackageFiles(plan.Source.ValueString())
+	if err != nil {
+		diags.AddError("Invalid source", err.Error())
+		return
+	}
+
+	contentHash, err := debPackageContentHash(files)
+	if err != nil {
+		diags.AddError("Failed to hash the .deb files", err.Error())
+		return
+	}
+
+	if !plan.ContentHash.IsUnknown() && plan.ContentHash.ValueString() != contentHash {
+		diags.AddError("The .deb files changed", fmt.Sprintf("The .deb files of %s changed since the plan was made, the plan must be made again", plan.Source.ValueString()))
+		return
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		diags.AddError("Failed to configure the apt proxy", err.Error())
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "mktemp -d")
+	if err != nil {
+		diags.AddError("Failed to create the remote temporary directory", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	directory := strings.TrimSpace(out)
+
+	defer func() {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-rf", directory))
+	}()
+
+	expected := map[string]string{}
+
+	var remoteFiles []string
+
+	for _, file := range files {
+		remoteFile := path.Join(directory, filepath.Base(file))
+
+		tflog.Debug(ctx, "Uploading "+file+" to "+remoteFile)
+
+		if err := r.provider.machineAccessClient.CopyFile(ctx, file, remoteFile); err != nil {
+			diags.AddError("Failed to upload "+file, err.Error())
+			return
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("dpkg-deb", "-f", remoteFile, "Package", "Version"))
+		if err != nil {
+			diags.AddError("Failed to read the control fields of "+file, fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+
+		name, version := parseDebControlFields(out)
+		if name == "" || version == "" {
+			diags.AddError("Invalid .deb file", fmt.Sprintf("%s has no package name or version: %s", file, out))
+			return
+		}
+
+		if _, ok := expected[name]; ok {
+			diags.AddError("Duplicate package", fmt.Sprintf("Several .deb files of %s contain the package %s", plan.Source.ValueString(), name))
+			return
+		}
+
+		expected[name] = version
+		remoteFiles = append(remoteFiles, remoteFile)
+	}
+
+	// dpkg leaves the packages with missing dependencies unconfigured, apt-get -f installs the dependencies from the
+	// repositories or, when they are not available, removes the packages which is detected below
+	defer r.provider.lock(ctx, aptLock)()
+
+	command := "export DEBIAN_FRONTEND=noninteractive; " + clients.ShellCommand("dpkg", append([]string{"-i"}, remoteFiles...)...) + " || apt-get -f install -y"
+
+	out, err = r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(command)))
+	if err != nil {
+		diags.AddError("Failed to install the packages", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	installed, err := r.installedVersions(ctx, sortedKeys(expected))
+	if err != nil {
+		diags.AddError("Failed to read the installed packages", err.Error())
+		return
+	}
+
+	for _, name := range sortedKeys(expected) {
+		if installed[name] != expected[name] {
+			diags.AddError("Failed to install the packages", fmt.Sprintf("%s %s is not installed, its dependencies may be missing:\n%s", name, expected[name], out))
+			return
+		}
+	}
+
+	packages, mapDiags := types.MapValueFrom(ctx, types.StringType, expected)
+	diags.Append(mapDiags...)
+
+	plan.ContentHash = types.StringValue(contentHash)
+	plan.Packages = packages
+}
+
+// installedVersions returns the versions of the given packages that are installed, by name.
+func (r *debPackageResource) installedVersions(ctx context.Context, names []string) (map[string]string, error) {
+	if len(names) == 0 {
+		return map[string]string{}, nil
+	}
+
+	result, err := r.provider.machineAccessClient.Run(ctx, clients.ShellCommand("dpkg-query", append([]string{"-W", "-f", `${Package}\t${Version}\t${db:Status-Abbrev}\n`}, names...)...))
+
+	// dpkg-query exits with 1 when some of the packages are unknown, after printing the others
+	if err != nil && !clients.IsExitCode(err, 1) {
+		return nil, fmt.Errorf("Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+	}
+
+	return parseInstalledPackages(result.Stdout), nil
+}
+
+// remove removes the given packages that are still installed.
+func (r *debPackageResource) remove(ctx context.Context, names []string) error {
+	installed, err := r.installedVersions(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	names = slices.DeleteFunc(names, func(name string) bool {
+		_, ok := installed[name]
+		return !ok
+	})
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo("export DEBIAN_FRONTEND=noninteractive; "+clients.ShellCommand("apt-get", append([]string{"remove", "-y"}, names...)...)))
+	if err != nil {
+		return fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// debPackageFiles returns the .deb file of the source, or the .deb files of the source directory sorted by name.
+func debPackageFiles(source string) ([]string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{source}, nil
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".deb") {
+			files = append(files, filepath.Join(source, entry.Name()))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("the directory %s contains no .deb file", source)
+	}
+
+	return files, nil
+}
+
+// debPackageContentHash returns the sha256 of the names and contents of the files.
+func debPackageContentHash(files []string) (string, error) {
+	hash := sha256.New()
+
+	for _, file := range files {
+		content, err := os.Open(file) // #nosec G304
+		if err != nil {
+			return "", err
+		}
+
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, content)
+		content.Close()
+
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		fmt.Fprintf(hash, "%s %x\n", filepath.Base(file), fileHash.Sum(nil))
+	}
+
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// parseDebControlFields returns the package name and version printed by dpkg-deb -f, e.g.
+//
+//	Package: hello
+//	Version: 2.10-3
+func parseDebControlFields(out string) (string, string) {
+	var name, version string
+
+	for line := range strings.SplitSeq(out, "\n") {
+		if value, ok := strings.CutPrefix(line, "Package:"); ok {
+			name = strings.TrimSpace(value)
+		}
+
+		if value, ok := strings.CutPrefix(line, "Version:"); ok {
+			version = strings.TrimSpace(value)
+		}
+	}
+
+	return name, version
+}
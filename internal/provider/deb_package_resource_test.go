@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// testDebPackageDirectory writes fake .deb files to a temporary directory, the mock client does not read them.
+func testDebPackageDirectory(t *testing.T, names ...string) string {
+	t.Helper()
+
+	directory := t.TempDir()
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return directory
+}
+
+func TestDebPackageResourceWithMock(t *testing.T) {
+	packages := types.MapValueMust(types.StringType, map[string]attr.Value{
+		"hello":  types.StringValue("2.10-3"),
+		"libfoo": types.StringValue("1.0-1"),
+	})
+
+	debMock := func() *clients.MockMachineAccessClient {
+		return clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.deb\n"}).
+			On("dpkg-deb -f /tmp/tmp.deb/hello_2.10-3_amd64.deb Package Version", clients.MockResponse{Stdout: "Package: hello\nVersion: 2.10-3\n"}).
+			On("dpkg-deb -f /tmp/tmp.deb/libfoo_1.0-1_amd64.deb Package Version", clients.MockResponse{Stdout: "Package: libfoo\nVersion: 1.0-1\n"})
+	}
+
+	t.Run("modify plan keeps the packages of unchanged files", func(t *testing.T) {
+		// Arrange
+		directory := testDebPackageDirectory(t, "hello_2.10-3_amd64.deb", "README")
+		r := newDebPackageResource(newTestProvider(clients.NewMockMachineAccessClient())).(*debPackageResource)
+
+		planned, diags := testResourceModifyPlan(t, r, nil, debPackageResourceModel{
+			Source:      types.StringValue(directory),
+			ContentHash: types.StringUnknown(),
+			Packages:    types.MapUnknown(types.StringType),
+		})
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		state := planned
+		state.Packages = packages
+
+		// Act
+		replanned, diags := testResourceModifyPlan(t, r, &state, planned)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !strings.HasPrefix(planned.ContentHash.ValueString(), "sha256:") || !replanned.Packages.Equal(packages) {
+			t.Fatalf("unexpected plan: %+v", replanned)
+		}
+	})
+
+	t.Run("modify plan fails on a directory without .deb file", func(t *testing.T) {
+		// Arrange
+		directory := testDebPackageDirectory(t, "README")
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newDebPackageResource(newTestProvider(clients.NewMockMachineAccessClient())).(*debPackageResource), nil, debPackageResourceModel{
+			Source:      types.StringValue(directory),
+			ContentHash: types.StringUnknown(),
+			Packages:    types.MapUnknown(types.StringType),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("create uploads and installs the .deb files of the directory", func(t *testing.T) {
+		// Arrange
+		directory := testDebPackageDirectory(t, "libfoo_1.0-1_amd64.deb", "hello_2.10-3_amd64.deb")
+		mock := debMock().
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "hello\t2.10-3\tii \nlibfoo\t1.0-1\tii \n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newDebPackageResource(newTestProvider(mock)), debPackageResourceModel{
+			Source:      types.StringValue(directory),
+			ContentHash: types.StringUnknown(),
+			Packages:    types.MapUnknown(types.StringType),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/tmp/tmp.deb/hello_2.10-3_amd64.deb"] != "hello_2.10-3_amd64.deb" {
+			t.Fatalf("unexpected files: %v", mock.Files)
+		}
+
+		commands := strings.Join(mock.Commands, "\n")
+		for _, expected := range []string{
+			"dpkg -i /tmp/tmp.deb/hello_2.10-3_amd64.deb /tmp/tmp.deb/libfoo_1.0-1_amd64.deb || apt-get -f install -y",
+			"rm -rf /tmp/tmp.deb",
+		} {
+			if !strings.Contains(commands, expected) {
+				t.Fatalf("expected %s in commands: %v", expected, mock.Commands)
+			}
+		}
+
+		if !state.Packages.Equal(packages) || !strings.HasPrefix(state.ContentHash.ValueString(), "sha256:") {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("create fails when a package is not installed afterwards", func(t *testing.T) {
+		// Arrange
+		directory := testDebPackageDirectory(t, "hello_2.10-3_amd64.deb")
+		mock := debMock().
+			OnMatch(`^dpkg-query`, clients.MockResponse{ExitCode: 1, Stderr: "dpkg-query: no packages found matching hello"})
+
+		// Act
+		_, diags := testResourceCreate(t, newDebPackageResource(newTestProvider(mock)), debPackageResourceModel{
+			Source:      types.StringValue(directory),
+			ContentHash: types.StringUnknown(),
+			Packages:    types.MapUnknown(types.StringType),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("read plans a reinstall when a package was upgraded", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "hello\t2.12-1\tii \nlibfoo\t1.0-1\tii \n"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newDebPackageResource(newTestProvider(mock)), debPackageResourceModel{
+			Source:      types.StringValue("/srv/debs"),
+			ContentHash: types.StringValue("sha256:abc"),
+			Packages:    packages,
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: %v, %v", diags, removed)
+		}
+
+		if !state.ContentHash.IsNull() || state.Packages.Elements()["hello"].String() != `"2.12-1"` {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read removes the resource when no package is installed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "hello\t2.10-3\trc \n", ExitCode: 1})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDebPackageResource(newTestProvider(mock)), debPackageResourceModel{
+			Source:      types.StringValue("/srv/debs"),
+			ContentHash: types.StringValue("sha256:abc"),
+			Packages:    packages,
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed: %v", diags)
+		}
+	})
+
+	t.Run("delete removes the installed packages", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "libfoo\t1.0-1\tii \n", ExitCode: 1})
+
+		// Act
+		diags := testResourceDelete(t, newDebPackageResource(newTestProvider(mock)), debPackageResourceModel{
+			Source:      types.StringValue("/srv/debs"),
+			ContentHash: types.StringValue("sha256:abc"),
+			Packages:    packages,
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 2 || !strings.HasSuffix(mock.Commands[1], "apt-get remove -y libfoo") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &directoryDataSource{}
+)
+
+func newDirectoryDataSource(p *internalProvider) datasource.DataSource {
+	return &directoryDataSource{
+		provider: p,
+	}
+}
+
+type directoryDataSource struct {
+	provider *internalProvider
+}
+
+type directoryDataSourceModel struct {
+	Path    types.String          `tfsdk:"path"`
+	Mode    types.String          `tfsdk:"mode"`
+	Owner   types.Int64           `tfsdk:"owner"`
+	Group   types.Int64           `tfsdk:"group"`
+	Entries []directoryEntryModel `tfsdk:"entries"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+type directoryEntryModel struct {
+	Name         types.String `tfsdk:"name"`
+	Path         types.String `tfsdk:"path"`
+	Type         types.String `tfsdk:"type"`
+	Size         types.Int64  `tfsdk:"size"`
+	Mode         types.String `tfsdk:"mode"`
+	ModifiedTime types.String `tfsdk:"modified_time"`
+}
+
+func (d *directoryDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_directory"
+}
+
+func (d *directoryDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Lists the entries of a directory of the remote system, e.g. to iterate over the existing files " +
+			"with for_each. The entries are not listed recursively, and symlinks are listed as such rather than followed",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the directory to list",
+				Validators:  []validator.String{absolutePath()},
+			},
+			"mode": schema.StringAttribute{
+				Computed:    true,
+				Description: "The mode of the directory",
+			},
+			"owner": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The owner UID of the directory",
+			},
+			"group": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The group GID of the directory",
+			},
+			"entries": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The entries of the directory, hidden ones included, sorted by name",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The name of the entry",
+						},
+						"path": schema.StringAttribute{
+							Computed:    true,
+							Description: "The path of the entry",
+						},
+						"type": schema.StringAttribute{
+							Computed:    true,
+							Description: "The type of the entry, 'file', 'directory', 'symlink', or the type reported by stat for the others, e.g. 'socket'",
+						},
+						"size": schema.Int64Attribute{
+							Computed:    true,
+							Description: "The size of the entry in bytes",
+						},
+						"mode": schema.StringAttribute{
+							Computed:    true,
+							Description: "The mode of the entry",
+						},
+						"modified_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The last modification time of the entry, in RFC 3339 format",
+						},
+					},
+				},
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *directoryDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	var model directoryDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	directory := model.Path.ValueString()
+
+	info, err := d.provider.machineAccessClient.Stat(ctx, directory, true)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read directory stat", err.Error())
+		return
+	}
+
+	if info.Type != "directory" {
+		resp.Diagnostics.AddError("Not a directory", fmt.Sprintf("%s is a %s", directory, info.Type))
+		return
+	}
+
+	model.Mode = types.StringValue(info.Mode)
+	model.Owner = types.Int64Value(info.Owner)
+	model.Group = types.Int64Value(info.Group)
+
+	// Each entry is printed as its stat line followed by its name, the three patterns match the hidden entries too
+	command := "cd -- " + clients.ShellQuote(directory) + ` || exit 1; for f in * .[!.]* ..?*; do if [ -e "$f" ] || [ -L "$f" ]; then ` +
+		`stat -c '%s %a %u %g %Y %F' -- "$f" && printf '%s\n' "$f" || exit 1; fi; done`
+
+	out, err := d.provider.machineAccessClient.RunCommand(ctx, d.provider.sudo(command))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list the directory", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	model.Entries, err = parseDirectoryListing(directory, out)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse the directory listing", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// parseDirectoryListing returns the entries of the directory from their stat lines, each followed by the name of the
+// entry, sorted by name.
+func parseDirectoryListing(directory string, out string) ([]directoryEntryModel, error) {
+	entries := []directoryEntryModel{}
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return entries, nil
+	}
+
+	if len(lines)%2 != 0 {
+		return nil, fmt.Errorf("unexpected listing, the names of the entries may contain line breaks: %s", out)
+	}
+
+	for i := 0; i < len(lines); i += 2 {
+		info, err := clients.ParseStat(lines[i])
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, directoryEntryModel{
+			Name:         types.StringValue(lines[i+1]),
+			Path:         types.StringValue(path.Join(directory, lines[i+1])),
+			Type:         types.StringValue(info.Type),
+			Size:         types.Int64Value(info.Size),
+			Mode:         types.StringValue(info.Mode),
+			ModifiedTime: types.StringValue(info.ModTime.Format(time.RFC3339)),
+		})
+	}
+
+	slices.SortFunc(entries, func(a, b directoryEntryModel) int {
+		return strings.Compare(a.Name.ValueString(), b.Name.ValueString())
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestDirectoryDataSourceWithMock(t *testing.T) {
+	t.Run("the entries are listed sorted by name", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/nginx/sites-enabled", "", clients.FileInfo{Type: "directory", Mode: "755"}).
+			OnMatch(`^cd -- '/etc/nginx/sites-enabled' `, clients.MockResponse{Stdout: "" +
+				"1024 644 0 0 1700000000 regular file\nmy site.conf\n" +
+				"22 777 0 0 1700000000 symbolic link\ndefault\n" +
+				"0 644 0 0 1700000000 regular empty file\n.keep\n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newDirectoryDataSource(newTestProvider(mock)), directoryDataSourceModel{
+			Path: types.StringValue("/etc/nginx/sites-enabled"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(state.Entries) != 3 || state.Mode.ValueString() != "755" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+
+		expected := []struct {
+			name, path, entryType string
+			size                  int64
+		}{
+			{".keep", "/etc/nginx/sites-enabled/.keep", "file", 0},
+			{"default", "/etc/nginx/sites-enabled/default", "symlink", 22},
+			{"my site.conf", "/etc/nginx/sites-enabled/my site.conf", "file", 1024},
+		}
+
+		for i, entry := range expected {
+			actual := state.Entries[i]
+			if actual.Name.ValueString() != entry.name || actual.Path.ValueString() != entry.path || actual.Type.ValueString() != entry.entryType || actual.Size.ValueInt64() != entry.size {
+				t.Fatalf("unexpected entry %d: %+v", i, actual)
+			}
+		}
+
+		if state.Entries[2].ModifiedTime.ValueString() != "2023-11-14T22:13:20Z" {
+			t.Fatalf("unexpected modification time: %s", state.Entries[2].ModifiedTime)
+		}
+	})
+
+	t.Run("an empty directory has no entries", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/srv/empty", "", clients.FileInfo{Type: "directory", Mode: "755"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newDirectoryDataSource(newTestProvider(mock)), directoryDataSourceModel{
+			Path: types.StringValue("/srv/empty"),
+		})
+
+		// Assert
+		if diags.HasError() || state.Entries == nil || len(state.Entries) != 0 {
+			t.Fatalf("unexpected result: %v, %+v", diags, state)
+		}
+	})
+
+	t.Run("reading a file fails", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/hostname", "host\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testDataSourceRead(t, newDirectoryDataSource(newTestProvider(mock)), directoryDataSourceModel{
+			Path: types.StringValue("/etc/hostname"),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
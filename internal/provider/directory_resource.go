@@ -5,19 +5,20 @@ package provider
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &directoryResource{}
 var _ resource.ResourceWithImportState = &directoryResource{}
+var _ resource.ResourceWithUpgradeState = &directoryResource{}
 
 func newDirectoryResource(p *internalProvider) resource.Resource {
 	return &directoryResource{
@@ -33,9 +34,12 @@ type directoryResource struct {
 type directoryResourceModel struct {
 	Path             types.String `tfsdk:"path"`
 	Mode             types.String `tfsdk:"mode"`
-	Owner            types.Int64  `tfsdk:"owner"`
-	Group            types.Int64  `tfsdk:"group"`
+	Owner            types.String `tfsdk:"owner"`
+	Group            types.String `tfsdk:"group"`
 	RemoveOnDeletion types.Bool   `tfsdk:"remove_on_deletion"`
+	IgnoreExternal   types.Bool   `tfsdk:"ignore_external_changes"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (directory *directoryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -44,24 +48,30 @@ func (directory *directoryResource) Metadata(_ context.Context, req resource.Met
 
 func (directory *directoryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Directory resource",
+		MarkdownDescription: "Directory resource. It can be imported with its path, e.g. `terraform import setup_directory.data /srv/data`, " +
+			"an imported directory being kept on deletion unless remove_on_deletion is set",
+		Version: 1,
 
 		Attributes: map[string]schema.Attribute{
 			"path": schema.StringAttribute{
 				Required:    true,
 				Description: "The path of the directory",
+				Validators:  []validator.String{absolutePath()},
 			},
 			"mode": schema.StringAttribute{
 				Required:    true,
 				Description: "The mode of the directory",
+				Validators:  []validator.String{octalMode()},
 			},
-			"owner": schema.Int64Attribute{
+			"owner": schema.StringAttribute{
 				Required:    true,
-				Description: "The owner of the directory",
+				Description: "The owner of the directory, a user name or a numeric UID",
+				Validators:  []validator.String{accountName()},
 			},
-			"group": schema.Int64Attribute{
+			"group": schema.StringAttribute{
 				Required:    true,
-				Description: "The group of the directory",
+				Description: "The group of the directory, a group name or a numeric GID",
+				Validators:  []validator.String{accountName()},
 			},
 			"remove_on_deletion": schema.BoolAttribute{
 				Optional:    true,
@@ -69,6 +79,13 @@ func (directory *directoryResource) Schema(_ context.Context, _ resource.SchemaR
 				Default:     booldefault.StaticBool(false),
 				Description: "Whether to remove the directory when the resource is deleted. Defaults to false.",
 			},
+			"ignore_external_changes": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the mode, owner and group changed outside of terraform are left as they are. Defaults to false, which shows them as drift in the plan and repairs them on apply",
+			},
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -78,6 +95,8 @@ func (directory *directoryResource) Configure(_ context.Context, _ resource.Conf
 }
 
 func (directory *directoryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = directory.provider.mutating(ctx, directory, "create", req.Plan)
+
 	var plan directoryResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -87,8 +106,13 @@ func (directory *directoryResource) Create(ctx context.Context, req resource.Cre
 		return
 	}
 
-	// todo: consider adding a configation for elevated actions
-	out, err := directory.provider.machineAccessClient.RunCommand(ctx, "sudo install -d -m "+plan.Mode.String()+" -o "+plan.Owner.String()+" -g "+plan.Group.String()+" "+plan.Path.String())
+	owner, group, err := directory.provider.lookupOwnership(ctx, plan.Owner, plan.Group)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to look up the owner of the directory", err.Error())
+		return
+	}
+
+	out, err := directory.provider.machineAccessClient.RunCommand(ctx, directory.provider.sudo(clients.ShellCommand("install", "-d", "-m", plan.Mode.ValueString(), "-o", owner, "-g", group, plan.Path.ValueString())))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to create directory. Err="+err.Error()+"\nout = "+string(out), err.Error())
 		return
@@ -103,6 +127,8 @@ func (directory *directoryResource) Create(ctx context.Context, req resource.Cre
 }
 
 func (directory *directoryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = directory.provider.onHost(ctx, req.State)
+
 	var model directoryResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -113,33 +139,28 @@ func (directory *directoryResource) Read(ctx context.Context, req resource.ReadR
 	}
 
 	// get the directory stat
-	stat, err := directory.provider.machineAccessClient.RunCommand(ctx, "sudo stat -c '%u %g %a' "+model.Path.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to read directory stat", err.Error())
-		return
-	}
-
-	statParts := strings.Split(strings.Trim(stat, "\n"), " ")
-	if len(statParts) != 3 {
-		resp.Diagnostics.AddError("Failed to parse stat output", stat)
+	info, err := directory.provider.machineAccessClient.Stat(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The directory was removed outside of terraform, it must be created again
+		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	owner, err := strconv.ParseInt(statParts[0], 10, 64)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to parse owner", err.Error())
+		resp.Diagnostics.AddError("Failed to read directory stat", err.Error())
 		return
 	}
 
-	group, err := strconv.ParseInt(statParts[1], 10, 64)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to parse group", err.Error())
-		return
+	// The refreshed values make the changes made outside of terraform show in the plan, to be repaired by Update
+	if !model.IgnoreExternal.ValueBool() {
+		model.Owner = ownershipValue(ctx, directory.provider.machineAccessClient, "passwd", model.Owner, info.Owner)
+		model.Group = ownershipValue(ctx, directory.provider.machineAccessClient, "group", model.Group, info.Group)
+		model.Mode = modeValue(model.Mode, info.Mode)
 	}
 
-	model.Owner = types.Int64Value(owner)
-	model.Group = types.Int64Value(group)
-	model.Mode = types.StringValue(statParts[2])
+	if model.IgnoreExternal.IsNull() {
+		model.IgnoreExternal = types.BoolValue(false)
+	}
 
 	diags = resp.State.Set(ctx, model)
 	resp.Diagnostics.Append(diags...)
@@ -150,7 +171,9 @@ func (directory *directoryResource) Read(ctx context.Context, req resource.ReadR
 }
 
 func (directory *directoryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var plan directoryResourceModel
+	ctx = directory.provider.mutating(ctx, directory, "update", req.Plan)
+
+	var plan, state directoryResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
@@ -159,18 +182,35 @@ func (directory *directoryResource) Update(ctx context.Context, req resource.Upd
 		return
 	}
 
-	// Update mode
-	_, err := directory.provider.machineAccessClient.RunCommand(ctx, "sudo chmod "+plan.Mode.String()+" "+plan.Path.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to update directory mode", err.Error())
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
 		return
 	}
 
-	// Update owner and group
-	_, err = directory.provider.machineAccessClient.RunCommand(ctx, "sudo chown "+plan.Owner.String()+":"+plan.Group.String()+" "+plan.Path.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to update directory owner/group", err.Error())
-		return
+	// The state holds the refreshed values, unless ignore_external_changes is set, in which case the changes made
+	// outside of terraform are kept as long as the configuration does not change
+	if !plan.Mode.Equal(state.Mode) {
+		_, err := directory.provider.machineAccessClient.Run(ctx, directory.provider.sudo(clients.ShellCommand("chmod", plan.Mode.ValueString(), plan.Path.ValueString())))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update directory mode", err.Error())
+			return
+		}
+	}
+
+	if !plan.Owner.Equal(state.Owner) || !plan.Group.Equal(state.Group) {
+		owner, group, err := directory.provider.lookupOwnership(ctx, plan.Owner, plan.Group)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to look up the owner of the directory", err.Error())
+			return
+		}
+
+		_, err = directory.provider.machineAccessClient.Run(ctx, directory.provider.sudo(clients.ShellCommand("chown", owner+":"+group, plan.Path.ValueString())))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update directory owner/group", err.Error())
+			return
+		}
 	}
 
 	diags = resp.State.Set(ctx, plan)
@@ -182,6 +222,8 @@ func (directory *directoryResource) Update(ctx context.Context, req resource.Upd
 }
 
 func (directory *directoryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = directory.provider.mutating(ctx, directory, "delete", req.State)
+
 	var model directoryResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -193,7 +235,11 @@ func (directory *directoryResource) Delete(ctx context.Context, req resource.Del
 
 	// Only remove the directory if remove_on_deletion is explicitly set to true
 	if model.RemoveOnDeletion.ValueBool() {
-		_, err := directory.provider.machineAccessClient.RunCommand(ctx, "sudo rm -rf "+model.Path.String())
+		if !directory.provider.allowDestructiveOperation("remove the directory "+model.Path.ValueString(), &resp.Diagnostics) {
+			return
+		}
+
+		_, err := directory.provider.machineAccessClient.Run(ctx, directory.provider.sudo(clients.ShellCommand("rm", "-rf", model.Path.ValueString())))
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to delete directory", err.Error())
 			return
@@ -201,6 +247,16 @@ func (directory *directoryResource) Delete(ctx context.Context, req resource.Del
 	}
 }
 
+func (directory *directoryResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: ownershipStateUpgrader(),
+	}
+}
+
 func (directory *directoryResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resource.ImportStatePassthroughID(ctx, path.Root("path"), req, resp)
+
+	// An imported directory is kept on deletion, as it was not created by terraform
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("remove_on_deletion"), false)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("ignore_external_changes"), false)...)
 }
@@ -6,7 +6,9 @@ import (
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
 
@@ -245,6 +247,11 @@ resource "setup_directory" "dir" {
 						}
 					},
 					Config: testProviderConfig(setup, "test", "localhost") + testDirectoryResourceConfig("/tmp/testdir_external_change", "755", 0, 0, false),
+					ConfigPlanChecks: resource.ConfigPlanChecks{
+						PreApply: []plancheck.PlanCheck{
+							plancheck.ExpectResourceAction("setup_directory.dir", plancheck.ResourceActionUpdate),
+						},
+					},
 					Check: resource.ComposeTestCheckFunc(
 						resource.TestCheckResourceAttr("setup_directory.dir", "path", "/tmp/testdir_external_change"),
 						resource.TestCheckResourceAttr("setup_directory.dir", "mode", "755"),
@@ -274,6 +281,160 @@ resource "setup_directory" "dir" {
 	})
 }
 
+func TestDirectoryResourceWithMock(t *testing.T) {
+	t.Run("import reads the directory and keeps it on deletion", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/srv/data", "", clients.FileInfo{Mode: "750", Owner: 1000, Group: 1000})
+
+		// Act
+		state, diags := testResourceImportState[directoryResourceModel](t, newDirectoryResource(newTestProvider(mock)), "/srv/data")
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Path.ValueString() != "/srv/data" || state.Mode.ValueString() != "750" || state.Owner.ValueString() != "1000" || state.Group.ValueString() != "1000" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+
+		if !state.RemoveOnDeletion.Equal(types.BoolValue(false)) {
+			t.Fatalf("unexpected remove_on_deletion: %s", state.RemoveOnDeletion)
+		}
+	})
+
+	t.Run("read shows the mode and owner changed outside of terraform", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/srv/data", "", clients.FileInfo{Type: "directory", Mode: "777", Owner: 1000, Group: 0})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newDirectoryResource(newTestProvider(mock)), directoryResourceModel{
+			Path:             types.StringValue("/srv/data"),
+			Mode:             types.StringValue("0755"),
+			Owner:            types.StringValue("0"),
+			Group:            types.StringValue("0"),
+			RemoveOnDeletion: types.BoolValue(false),
+			IgnoreExternal:   types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Mode.ValueString() != "777" || state.Owner.ValueString() != "1000" || state.Group.ValueString() != "0" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read keeps the configured mode when it only differs in format", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/srv/data", "", clients.FileInfo{Type: "directory", Mode: "755", Owner: 0, Group: 0})
+
+		// Act
+		state, _, diags := testResourceRead(t, newDirectoryResource(newTestProvider(mock)), directoryResourceModel{
+			Path:             types.StringValue("/srv/data"),
+			Mode:             types.StringValue("0755"),
+			Owner:            types.StringValue("0"),
+			Group:            types.StringValue("0"),
+			RemoveOnDeletion: types.BoolValue(false),
+			IgnoreExternal:   types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Mode.ValueString() != "0755" {
+			t.Fatalf("unexpected mode: %s", state.Mode)
+		}
+	})
+
+	t.Run("read ignores external changes when requested", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/srv/data", "", clients.FileInfo{Type: "directory", Mode: "777", Owner: 1000, Group: 1000})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newDirectoryResource(newTestProvider(mock)), directoryResourceModel{
+			Path:             types.StringValue("/srv/data"),
+			Mode:             types.StringValue("755"),
+			Owner:            types.StringValue("0"),
+			Group:            types.StringValue("0"),
+			RemoveOnDeletion: types.BoolValue(false),
+			IgnoreExternal:   types.BoolValue(true),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Mode.ValueString() != "755" || state.Owner.ValueString() != "0" || state.Group.ValueString() != "0" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("update only repairs the attributes that differ", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		state := directoryResourceModel{
+			Path:             types.StringValue("/srv/data"),
+			Mode:             types.StringValue("777"),
+			Owner:            types.StringValue("0"),
+			Group:            types.StringValue("0"),
+			RemoveOnDeletion: types.BoolValue(false),
+			IgnoreExternal:   types.BoolValue(false),
+		}
+
+		plan := state
+		plan.Mode = types.StringValue("755")
+
+		// Act
+		_, diags := testResourceUpdate(t, newDirectoryResource(newTestProvider(mock)), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 || mock.Commands[0] != "chmod 755 /srv/data" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("delete is refused when destructive operations are disabled", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		provider := newTestProvider(mock)
+		provider.denyDestructiveOperations = true
+
+		// Act
+		diags := testResourceDelete(t, newDirectoryResource(provider), directoryResourceModel{
+			Path:             types.StringValue("/etc"),
+			Mode:             types.StringValue("755"),
+			Owner:            types.StringValue("0"),
+			Group:            types.StringValue("0"),
+			RemoveOnDeletion: types.BoolValue(true),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
 func testDirectoryResourceConfig(path string, mode string, owner int, group int, removeOnDeletion bool) string {
 	return fmt.Sprintf(`
 resource "setup_directory" "dir" {
@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &dockerContainerDataSource{}
+)
+
+func newDockerContainerDataSource(p *internalProvider) datasource.DataSource {
+	return &dockerContainerDataSource{
+		provider: p,
+	}
+}
+
+type dockerContainerDataSource struct {
+	provider *internalProvider
+}
+
+type dockerContainerDataSourceModel struct {
+	Name         types.String `tfsdk:"name"`
+	ID           types.String `tfsdk:"id"`
+	Image        types.String `tfsdk:"image"`
+	ImageID      types.String `tfsdk:"image_id"`
+	Running      types.Bool   `tfsdk:"running"`
+	Status       types.String `tfsdk:"status"`
+	Health       types.String `tfsdk:"health"`
+	ExitCode     types.Int64  `tfsdk:"exit_code"`
+	StartedAt    types.String `tfsdk:"started_at"`
+	RestartCount types.Int64  `tfsdk:"restart_count"`
+	Labels       types.Map    `tfsdk:"labels"`
+	IPAddresses  types.Map    `tfsdk:"ip_addresses"`
+	Runtime      types.String `tfsdk:"runtime"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *dockerContainerDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_container"
+}
+
+func (d *dockerContainerDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Inspects a container of the remote Docker daemon, reached through the connection of the provider",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name or ID of the container",
+				Validators:  []validator.String{notBlank()},
+			},
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the container",
+			},
+			"image": schema.StringAttribute{
+				Computed:    true,
+				Description: "The image the container was created from, as given when creating it, e.g. 'nginx:1.27'",
+			},
+			"image_id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the image the container was created from, e.g. 'sha256:...'",
+			},
+			"running": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the container is running",
+			},
+			"status": schema.StringAttribute{
+				Computed:    true,
+				Description: "The status of the container, e.g. 'running', 'exited' or 'restarting'",
+			},
+			"health": schema.StringAttribute{
+				Computed:    true,
+				Description: "The health status of the container, e.g. 'healthy' or 'unhealthy', null when it has no health check",
+			},
+			"exit_code": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The exit code of the last run of the container",
+			},
+			"started_at": schema.StringAttribute{
+				Computed:    true,
+				Description: "When the container was last started, in RFC 3339 format",
+			},
+			"restart_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "How many times the container was restarted by its restart policy",
+			},
+			"labels": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The labels of the container",
+			},
+			"ip_addresses": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The IP addresses of the container by network name",
+			},
+			"runtime":     dockerRuntimeDataSourceAttribute(),
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *dockerContainerDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+	ctx = d.provider.onRuntime(ctx, req.Config)
+
+	var model dockerContainerDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create Docker client", err.Error())
+		return
+	}
+
+	container, err := dockerClient.ContainerInspect(ctx, model.Name.ValueString())
+	if errdefs.IsNotFound(err) {
+		resp.Diagnostics.AddError("Container not found", "The container "+model.Name.ValueString()+" does not exist on the remote Docker daemon")
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to inspect the container", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(model.fromInspect(ctx, container)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// fromInspect sets the attributes of the model from the inspection of the container.
+func (model *dockerContainerDataSourceModel) fromInspect(ctx context.Context, container dockertypes.ContainerJSON) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	model.ID = types.StringValue(container.ID)
+	model.ImageID = types.StringValue(container.ContainerJSONBase.Image)
+	model.RestartCount = types.Int64Value(int64(container.RestartCount))
+	model.Image = types.StringValue("")
+	model.Running = types.BoolValue(false)
+	model.Status = types.StringValue("")
+	model.ExitCode = types.Int64Value(0)
+	model.StartedAt = types.StringValue("")
+	model.Health = types.StringNull()
+
+	labels := map[string]string{}
+
+	if container.Config != nil {
+		model.Image = types.StringValue(container.Config.Image)
+		labels = nonNilMap(container.Config.Labels)
+	}
+
+	if state := container.State; state != nil {
+		model.Running = types.BoolValue(state.Running)
+		model.Status = types.StringValue(state.Status)
+		model.ExitCode = types.Int64Value(int64(state.ExitCode))
+		model.StartedAt = types.StringValue(state.StartedAt)
+
+		if state.Health != nil {
+			model.Health = types.StringValue(state.Health.Status)
+		}
+	}
+
+	addresses := map[string]string{}
+
+	if container.NetworkSettings != nil {
+		for name, network := range container.NetworkSettings.Networks {
+			if network != nil && network.IPAddress != "" {
+				addresses[name] = network.IPAddress
+			}
+		}
+	}
+
+	var mapDiags diag.Diagnostics
+
+	model.Labels, mapDiags = types.MapValueFrom(ctx, types.StringType, labels)
+	diags.Append(mapDiags...)
+
+	model.IPAddresses, mapDiags = types.MapValueFrom(ctx, types.StringType, addresses)
+	diags.Append(mapDiags...)
+
+	return diags
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestDockerContainerDataSourceWithMock(t *testing.T) {
+	t.Run("the inspection of the container is converted", func(t *testing.T) {
+		// Arrange
+		var model dockerContainerDataSourceModel
+
+		// Act
+		diags := model.fromInspect(context.Background(), dockertypes.ContainerJSON{
+			ContainerJSONBase: &dockertypes.ContainerJSONBase{
+				ID:           "0f3c",
+				Name:         "/web",
+				Image:        "sha256:4f8a",
+				RestartCount: 2,
+				State: &dockertypes.ContainerState{
+					Status:    "running",
+					Running:   true,
+					StartedAt: "2024-08-14T21:31:12Z",
+					Health:    &dockertypes.Health{Status: "healthy"},
+				},
+			},
+			Config: &container.Config{Image: "nginx:1.27", Labels: map[string]string{"app": "web"}},
+			NetworkSettings: &dockertypes.NetworkSettings{
+				Networks: map[string]*network.EndpointSettings{"bridge": {IPAddress: "172.17.0.2"}, "none": {}},
+			},
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !model.Running.ValueBool() || model.Status.ValueString() != "running" || model.Health.ValueString() != "healthy" || model.RestartCount.ValueInt64() != 2 {
+			t.Fatalf("unexpected model: %+v", model)
+		}
+
+		if model.Image.ValueString() != "nginx:1.27" || model.ImageID.ValueString() != "sha256:4f8a" {
+			t.Fatalf("unexpected image: %+v", model)
+		}
+
+		addresses := model.IPAddresses.Elements()
+		if len(addresses) != 1 || addresses["bridge"].String() != `"172.17.0.2"` || model.Labels.Elements()["app"].String() != `"web"` {
+			t.Fatalf("unexpected addresses or labels: %v, %v", model.IPAddresses, model.Labels)
+		}
+	})
+
+	t.Run("a stopped container without health check", func(t *testing.T) {
+		// Arrange
+		var model dockerContainerDataSourceModel
+
+		// Act
+		diags := model.fromInspect(context.Background(), dockertypes.ContainerJSON{
+			ContainerJSONBase: &dockertypes.ContainerJSONBase{
+				ID:    "0f3c",
+				Image: "sha256:4f8a",
+				State: &dockertypes.ContainerState{Status: "exited", ExitCode: 137},
+			},
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if model.Running.ValueBool() || model.ExitCode.ValueInt64() != 137 || !model.Health.IsNull() || len(model.Labels.Elements()) != 0 {
+			t.Fatalf("unexpected model: %+v", model)
+		}
+	})
+
+	t.Run("read fails without Docker client", func(t *testing.T) {
+		// Act
+		_, diags := testDataSourceRead(t, newDockerContainerDataSource(newTestProvider(clients.NewMockMachineAccessClient())), dockerContainerDataSourceModel{
+			Name:        types.StringValue("web"),
+			Labels:      types.MapNull(types.StringType),
+			IPAddresses: types.MapNull(types.StringType),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,405 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &dockerDaemonConfigResource{}
+
+func newDockerDaemonConfigResource(p *internalProvider) resource.Resource {
+	return &dockerDaemonConfigResource{
+		provider: p,
+	}
+}
+
+// dockerDaemonConfigResource defines the resource implementation.
+type dockerDaemonConfigResource struct {
+	provider *internalProvider
+}
+
+type dockerDaemonConfigResourceModel struct {
+	Path               types.String `tfsdk:"path"`
+	LogDriver          types.String `tfsdk:"log_driver"`
+	LogOpts            types.Map    `tfsdk:"log_opts"`
+	RegistryMirrors    types.List   `tfsdk:"registry_mirrors"`
+	InsecureRegistries types.List   `tfsdk:"insecure_registries"`
+	DataRoot           types.String `tfsdk:"data_root"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+// dockerDaemonConfigKeys are the keys of daemon.json managed by the resource, by attribute.
+var dockerDaemonConfigKeys = map[string]string{
+	"log_driver":          "log-driver",
+	"log_opts":            "log-opts",
+	"registry_mirrors":    "registry-mirrors",
+	"insecure_registries": "insecure-registries",
+	"data_root":           "data-root",
+}
+
+func (r *dockerDaemonConfigResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_daemon_config"
+}
+
+func (r *dockerDaemonConfigResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Docker daemon configuration resource that sets keys of /etc/docker/daemon.json. The keys " +
+			"of the attributes that are not set, and the other keys of the file, are left as they are. dockerd is " +
+			"restarted, when running, only if the file changed. Destroying the resource removes the keys it set",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("/etc/docker/daemon.json"),
+				Description: "The path of the daemon configuration file. Defaults to /etc/docker/daemon.json",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"log_driver": schema.StringAttribute{
+				Optional:    true,
+				Description: "The default logging driver of the containers, e.g. json-file or journald",
+			},
+			"log_opts": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The options of the logging driver, e.g. { max-size = \"10m\", max-file = \"3\" }",
+			},
+			"registry_mirrors": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The URLs of the mirrors of Docker Hub",
+			},
+			"insecure_registries": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The registries reached without TLS verification, e.g. registry.internal:5000",
+			},
+			"data_root": schema.StringAttribute{
+				Optional:    true,
+				Description: "The directory of the images, containers and volumes, e.g. /data/docker",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *dockerDaemonConfigResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *dockerDaemonConfigResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan dockerDaemonConfigResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerDaemonConfigResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model dockerDaemonConfigResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be written again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read docker daemon configuration", err.Error())
+		return
+	}
+
+	document, err := decodeStructured(structuredFormatJSON, content)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse docker daemon configuration", err.Error())
+		return
+	}
+
+	config, _ := document.(map[string]interface{})
+
+	// Only the keys of the configured attributes are managed, the others may be set by other means
+	if !model.LogDriver.IsNull() {
+		model.LogDriver = dockerDaemonConfigString(config["log-driver"])
+	}
+
+	if !model.DataRoot.IsNull() {
+		model.DataRoot = dockerDaemonConfigString(config["data-root"])
+	}
+
+	if !model.LogOpts.IsNull() {
+		model.LogOpts, diags = dockerDaemonConfigMap(ctx, config["log-opts"])
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if !model.RegistryMirrors.IsNull() {
+		model.RegistryMirrors, diags = dockerDaemonConfigList(ctx, config["registry-mirrors"])
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if !model.InsecureRegistries.IsNull() {
+		model.InsecureRegistries, diags = dockerDaemonConfigList(ctx, config["insecure-registries"])
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerDaemonConfigResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan dockerDaemonConfigResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state dockerDaemonConfigResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &state, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerDaemonConfigResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model dockerDaemonConfigResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	_, err := r.provider.machineAccessClient.Stat(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		return
+	}
+
+	// Applying an empty configuration removes the keys that were set
+	empty := dockerDaemonConfigResourceModel{
+		Path:               model.Path,
+		LogDriver:          types.StringNull(),
+		LogOpts:            types.MapNull(types.StringType),
+		RegistryMirrors:    types.ListNull(types.StringType),
+		InsecureRegistries: types.ListNull(types.StringType),
+		DataRoot:           types.StringNull(),
+	}
+
+	r.apply(ctx, empty, &model, &resp.Diagnostics)
+}
+
+// apply merges the configured keys into the file, and removes the keys that were configured in the previous state
+// and are not anymore. dockerd is restarted when the file changed.
+func (r *dockerDaemonConfigResource) apply(ctx context.Context, plan dockerDaemonConfigResourceModel, previous *dockerDaemonConfigResourceModel, diags *diag.Diagnostics) {
+	patch, d := plan.patch(ctx)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if previous != nil {
+		previousPatch, d := previous.patch(ctx)
+		diags.Append(d...)
+
+		if diags.HasError() {
+			return
+		}
+
+		for key := range previousPatch {
+			if _, ok := patch[key]; !ok {
+				patch[key] = nil
+			}
+		}
+	}
+
+	// docker_setup may be installing docker at the same time
+	defer r.provider.lock(ctx, dockerLock)()
+
+	filePath := plan.Path.ValueString()
+
+	if strings.Contains(filePath, "/") {
+		dir := filePath[:strings.LastIndex(filePath, "/")]
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("mkdir -p "+clients.ShellQuote(dir)))
+		if err != nil {
+			diags.AddError("Failed to create docker configuration directory", "Err="+err.Error()+"\nout = "+out)
+			return
+		}
+	}
+
+	changed, err := r.provider.patchStructuredFile(ctx, structuredFormatJSON, filePath, true, patch)
+	if err != nil {
+		diags.AddError("Failed to write docker daemon configuration", err.Error())
+		return
+	}
+
+	if !changed {
+		return
+	}
+
+	// try-restart leaves a stopped, or not yet installed, docker as it is
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl try-restart docker"))
+	if err != nil {
+		diags.AddError("Failed to restart docker", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+// patch returns the daemon.json keys of the attributes that are set.
+func (model dockerDaemonConfigResourceModel) patch(ctx context.Context) (map[string]interface{}, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	patch := map[string]interface{}{}
+
+	if !model.LogDriver.IsNull() {
+		patch[dockerDaemonConfigKeys["log_driver"]] = model.LogDriver.ValueString()
+	}
+
+	if !model.DataRoot.IsNull() {
+		patch[dockerDaemonConfigKeys["data_root"]] = model.DataRoot.ValueString()
+	}
+
+	if !model.LogOpts.IsNull() {
+		var logOpts map[string]string
+
+		diags.Append(model.LogOpts.ElementsAs(ctx, &logOpts, false)...)
+
+		// The options replace the existing ones as a whole, rather than being merged key by key
+		patch[dockerDaemonConfigKeys["log_opts"]] = nil
+		if len(logOpts) > 0 {
+			patch[dockerDaemonConfigKeys["log_opts"]] = logOpts
+		}
+	}
+
+	for attribute, list := range map[string]types.List{"registry_mirrors": model.RegistryMirrors, "insecure_registries": model.InsecureRegistries} {
+		if list.IsNull() {
+			continue
+		}
+
+		values := []string{}
+
+		diags.Append(list.ElementsAs(ctx, &values, false)...)
+
+		patch[dockerDaemonConfigKeys[attribute]] = values
+	}
+
+	return patch, diags
+}
+
+// dockerDaemonConfigString returns the value of a string key of daemon.json.
+func dockerDaemonConfigString(value interface{}) types.String {
+	text, ok := value.(string)
+	if !ok {
+		return types.StringNull()
+	}
+
+	return types.StringValue(text)
+}
+
+// dockerDaemonConfigList returns the value of a list of strings key of daemon.json.
+func dockerDaemonConfigList(ctx context.Context, value interface{}) (types.List, diag.Diagnostics) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return types.ListNull(types.StringType), nil
+	}
+
+	values := []string{}
+	for _, item := range items {
+		values = append(values, fmt.Sprint(item))
+	}
+
+	return types.ListValueFrom(ctx, types.StringType, values)
+}
+
+// dockerDaemonConfigMap returns the value of an object of strings key of daemon.json.
+func dockerDaemonConfigMap(ctx context.Context, value interface{}) (types.Map, diag.Diagnostics) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return types.MapNull(types.StringType), nil
+	}
+
+	values := map[string]string{}
+	for key, item := range object {
+		values[key] = fmt.Sprint(item)
+	}
+
+	return types.MapValueFrom(ctx, types.StringType, values)
+}
@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestDockerDaemonConfigResource(t *testing.T) {
+	t.Run("Test set the docker daemon configuration", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sshClient.RunCommand(context.Background(), `mkdir -p /tmp/docker && echo '{"debug": true}' > /tmp/docker/daemon.json`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_docker_daemon_config" "test" {
+  path             = "/tmp/docker/daemon.json"
+  log_driver       = "json-file"
+  log_opts         = { "max-size" = "10m" }
+  registry_mirrors = ["https://mirror.gcr.io"]
+}
+`,
+					Check: func(_ *terraform.State) error {
+						content, err := sshClient.RunCommand(context.Background(), "cat /tmp/docker/daemon.json")
+						if err != nil {
+							return fmt.Errorf("file not found")
+						}
+
+						expected := "{\n  \"debug\": true,\n  \"log-driver\": \"json-file\",\n  \"log-opts\": {\n    \"max-size\": \"10m\"\n  },\n  \"registry-mirrors\": [\n    \"https://mirror.gcr.io\"\n  ]\n}\n"
+						if content != expected {
+							return fmt.Errorf("unexpected content: %s", content)
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestDockerDaemonConfigResourceWithMock(t *testing.T) {
+	model := dockerDaemonConfigResourceModel{
+		Path:               types.StringValue("/etc/docker/daemon.json"),
+		LogDriver:          types.StringValue("json-file"),
+		LogOpts:            types.MapNull(types.StringType),
+		RegistryMirrors:    types.ListNull(types.StringType),
+		InsecureRegistries: types.ListNull(types.StringType),
+		DataRoot:           types.StringValue("/data/docker"),
+	}
+
+	t.Run("create merges the keys and restarts docker", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", "{\"debug\": true, \"log-driver\": \"syslog\"}\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newDockerDaemonConfigResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "{\n  \"data-root\": \"/data/docker\",\n  \"debug\": true,\n  \"log-driver\": \"json-file\"\n}\n"
+		if mock.Files["/etc/docker/daemon.json"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/docker/daemon.json"])
+		}
+
+		if !strings.Contains(strings.Join(mock.Commands, "\n"), "systemctl try-restart docker") {
+			t.Fatalf("expected docker to be restarted: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does not restart docker when the configuration is unchanged", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", "{\"log-driver\": \"json-file\", \"data-root\": \"/data/docker\"}\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newDockerDaemonConfigResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if strings.Contains(strings.Join(mock.Commands, "\n"), "systemctl") {
+			t.Fatalf("unexpected restart: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create writes a missing file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("id -u && id -g", clients.MockResponse{Stdout: "0\n0\n"})
+
+		mirrors := model
+		mirrors.LogDriver = types.StringNull()
+		mirrors.DataRoot = types.StringNull()
+		mirrors.InsecureRegistries = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("registry.internal:5000")})
+
+		// Act
+		_, diags := testResourceCreate(t, newDockerDaemonConfigResource(newTestProvider(mock)), mirrors)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/docker/daemon.json"] != "{\n  \"insecure-registries\": [\n    \"registry.internal:5000\"\n  ]\n}\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/docker/daemon.json"])
+		}
+	})
+
+	t.Run("update removes the keys that are not set anymore", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", "{\"debug\": true, \"log-driver\": \"json-file\", \"data-root\": \"/data/docker\"}\n", clients.FileInfo{Mode: "644"})
+
+		plan := model
+		plan.DataRoot = types.StringNull()
+
+		// Act
+		_, diags := testResourceUpdate(t, newDockerDaemonConfigResource(newTestProvider(mock)), model, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/docker/daemon.json"] != "{\n  \"debug\": true,\n  \"log-driver\": \"json-file\"\n}\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/docker/daemon.json"])
+		}
+	})
+
+	t.Run("read reports the managed keys changed outside of terraform", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", "{\"log-driver\": \"syslog\", \"data-root\": \"/data/docker\", \"debug\": true}\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newDockerDaemonConfigResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: %v, %v", diags, removed)
+		}
+
+		if state.LogDriver.ValueString() != "syslog" || state.DataRoot.ValueString() != "/data/docker" || !state.LogOpts.IsNull() {
+			t.Fatalf("unexpected state: %v", state)
+		}
+	})
+
+	t.Run("delete removes the managed keys", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", "{\"debug\": true, \"log-driver\": \"json-file\", \"data-root\": \"/data/docker\"}\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		diags := testResourceDelete(t, newDockerDaemonConfigResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/docker/daemon.json"] != "{\n  \"debug\": true\n}\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/docker/daemon.json"])
+		}
+	})
+}
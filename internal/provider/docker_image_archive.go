@@ -0,0 +1,285 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// maxImageMetadataSize is the size of the largest file of an image archive kept to find its architectures. The
+// manifests and configs are a few kilobytes.
+const maxImageMetadataSize = 1 << 20
+
+// imageConfig is the part of the config of an image giving its platform.
+type imageConfig struct {
+	Architecture string `json:"architecture"`
+}
+
+// ociDescriptor references a manifest or a config blob of an OCI image layout.
+type ociDescriptor struct {
+	Digest   string       `json:"digest"`
+	Platform *imageConfig `json:"platform"`
+}
+
+// ociManifest is an OCI image index, which lists manifests, or an image manifest, which references a config.
+type ociManifest struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    *ociDescriptor  `json:"config"`
+}
+
+// imageArchive is the tar stream of an image archive, as it is sent to Docker.
+type imageArchive struct {
+	io.Reader
+
+	// plain is set when the archive is a tar file sent as it is, which can be uploaded without being written again.
+	plain bool
+
+	// inspectable is unset for zstd archives, which are sent compressed as the standard library has no zstd decoder.
+	// Docker decompresses them itself.
+	inspectable bool
+
+	closers []io.Closer
+}
+
+func (archive *imageArchive) Close() error {
+	var err error
+
+	for i := len(archive.closers) - 1; i >= 0; i-- {
+		if closeErr := archive.closers[i].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// openImageArchive opens a docker save tar file, a gzip or zstd compressed one, an OCI image layout tar file, or an
+// OCI image layout directory which is turned into a tar stream. Gzip archives are decompressed, so that the stream
+// does not depend on the compression. When description is set, the progress of the reading is logged.
+func openImageArchive(ctx context.Context, archivePath string, description string) (*imageArchive, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat image archive: %v", err)
+	}
+
+	if info.IsDir() {
+		return openOCILayoutDirectory(ctx, archivePath, description)
+	}
+
+	// #nosec G304 - archivePath is user-provided and we need to read their specified archive
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image archive: %v", err)
+	}
+
+	archive := &imageArchive{closers: []io.Closer{file}}
+
+	var reader io.Reader = file
+	if description != "" {
+		reader = clients.NewProgressReader(ctx, file, description, info.Size(), 0)
+	}
+
+	buffered := bufio.NewReader(reader)
+
+	// A short file is not an error here, it is reported as an invalid tar file when read
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			_ = archive.Close()
+			return nil, fmt.Errorf("failed to decompress gzip image archive: %v", err)
+		}
+
+		archive.Reader = gzipReader
+		archive.inspectable = true
+		archive.closers = append(archive.closers, gzipReader)
+	case bytes.HasPrefix(magic, zstdMagic):
+		archive.Reader = buffered
+	default:
+		archive.Reader = buffered
+		archive.plain = true
+		archive.inspectable = true
+	}
+
+	return archive, nil
+}
+
+// openOCILayoutDirectory returns the tar stream of an OCI image layout directory. The entries are written in lexical
+// order without timestamps nor owners, so that the stream, and its hash, only depend on the content of the files.
+func openOCILayoutDirectory(ctx context.Context, directory string, description string) (*imageArchive, error) {
+	if _, err := os.Stat(filepath.Join(directory, "oci-layout")); err != nil {
+		return nil, fmt.Errorf("%s is not an OCI image layout directory, it has no oci-layout file", directory)
+	}
+
+	var total int64
+
+	err := filepath.WalkDir(directory, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		// A header, the content padded to 512 bytes
+		total += 512 + (info.Size()+511)/512*512
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OCI image layout directory: %v", err)
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+
+	go func() {
+		pipeWriter.CloseWithError(writeOCILayoutTar(directory, pipeWriter))
+	}()
+
+	var reader io.Reader = pipeReader
+	if description != "" {
+		// The end of archive marker is made of two empty blocks
+		reader = clients.NewProgressReader(ctx, pipeReader, description, total+1024, 0)
+	}
+
+	return &imageArchive{Reader: reader, inspectable: true, closers: []io.Closer{pipeReader}}, nil
+}
+
+func writeOCILayoutTar(directory string, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+
+	err := filepath.WalkDir(directory, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(directory, filePath)
+		if err != nil || name == "." {
+			return err
+		}
+
+		name = filepath.ToSlash(name)
+
+		if entry.IsDir() {
+			return tarWriter.WriteHeader(&tar.Header{Name: name + "/", Mode: 0755, Typeflag: tar.TypeDir})
+		}
+
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", filePath)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		err = tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size(), Typeflag: tar.TypeReg})
+		if err != nil {
+			return err
+		}
+
+		// #nosec G304 - the file is part of the layout directory provided by the user
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tarWriter, file)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
+
+// imageArchitectures returns the architectures of the images of an archive, from the JSON files of the archive by
+// name. The configs referenced by the manifest.json of docker save are read first, then the OCI index. The
+// architecture 'unknown' of the attestation manifests of buildx is ignored.
+func imageArchitectures(metadata map[string][]byte) []string {
+	var architectures []string
+
+	add := func(architecture string) {
+		if architecture != "" && architecture != "unknown" && !slices.Contains(architectures, architecture) {
+			architectures = append(architectures, architecture)
+		}
+	}
+
+	var manifests []dockerManifest
+	if json.Unmarshal(metadata["manifest.json"], &manifests) == nil {
+		for _, manifest := range manifests {
+			var config imageConfig
+			if json.Unmarshal(metadata[path.Clean(manifest.Config)], &config) == nil {
+				add(config.Architecture)
+			}
+		}
+	}
+
+	if len(architectures) == 0 {
+		addOCIArchitectures(metadata, metadata["index.json"], 0, add)
+	}
+
+	return architectures
+}
+
+// addOCIArchitectures adds the architectures of an OCI index or manifest, from the platforms of the index when they
+// are given, and otherwise from the configs. The nested indexes are followed a few levels deep.
+func addOCIArchitectures(metadata map[string][]byte, content []byte, depth int, add func(string)) {
+	var manifest ociManifest
+	if depth > 3 || json.Unmarshal(content, &manifest) != nil {
+		return
+	}
+
+	if manifest.Config != nil {
+		var config imageConfig
+		if json.Unmarshal(ociBlob(metadata, manifest.Config.Digest), &config) == nil {
+			add(config.Architecture)
+		}
+	}
+
+	for _, descriptor := range manifest.Manifests {
+		if descriptor.Platform != nil {
+			add(descriptor.Platform.Architecture)
+			continue
+		}
+
+		addOCIArchitectures(metadata, ociBlob(metadata, descriptor.Digest), depth+1, add)
+	}
+}
+
+// ociBlob returns the content of a blob of an OCI image layout, nil when it is not kept.
+func ociBlob(metadata map[string][]byte, digest string) []byte {
+	algorithm, encoded, found := strings.Cut(digest, ":")
+	if !found {
+		return nil
+	}
+
+	return metadata["blobs/"+algorithm+"/"+encoded]
+}
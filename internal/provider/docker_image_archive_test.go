@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+)
+
+func TestOpenImageArchive(t *testing.T) {
+	t.Run("should hash a gzip archive as the tar file it contains", func(t *testing.T) {
+		// Arrange
+		tempDir := t.TempDir()
+
+		tarFile := filepath.Join(tempDir, "image.tar")
+		if err := createTestDockerImageTar(tarFile); err != nil {
+			t.Fatal(err)
+		}
+
+		gzipFile := filepath.Join(tempDir, "image.tar.gz")
+		if err := gzipTestFile(tarFile, gzipFile); err != nil {
+			t.Fatal(err)
+		}
+
+		resource := &dockerImageLoadResource{}
+
+		// Act
+		tarHash, err1 := resource.getImageContentHashFromLocalTar(t.Context(), tarFile)
+		gzipHash, err2 := resource.getImageContentHashFromLocalTar(t.Context(), gzipFile)
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("Expected no error, got: %v, %v", err1, err2)
+		}
+
+		if tarHash != gzipHash {
+			t.Fatalf("Expected the same hash, got %s and %s", tarHash, gzipHash)
+		}
+	})
+
+	t.Run("should stream an OCI image layout directory as a tar file", func(t *testing.T) {
+		// Arrange
+		layout := createTestOCILayout(t, "layer content")
+
+		resource := &dockerImageLoadResource{}
+
+		// Act
+		hash1, err1 := resource.getImageContentHashFromLocalTar(t.Context(), layout)
+		hash2, err2 := resource.getImageContentHashFromLocalTar(t.Context(), layout)
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("Expected no error, got: %v, %v", err1, err2)
+		}
+
+		if hash1 != hash2 {
+			t.Fatalf("Expected a stable hash, got %s and %s", hash1, hash2)
+		}
+
+		if other, _ := resource.getImageContentHashFromLocalTar(t.Context(), createTestOCILayout(t, "other layer content")); other == hash1 {
+			t.Fatalf("Expected a different hash for a different layer")
+		}
+	})
+
+	t.Run("should reject a directory that is not an OCI image layout", func(t *testing.T) {
+		// Arrange
+		resource := &dockerImageLoadResource{}
+
+		// Act
+		_, err := resource.getImageContentHashFromLocalTar(t.Context(), t.TempDir())
+
+		// Assert
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+	})
+
+	t.Run("should pass a zstd archive as it is", func(t *testing.T) {
+		// Arrange
+		zstdFile := filepath.Join(t.TempDir(), "image.tar.zst")
+
+		content := append([]byte{0x28, 0xb5, 0x2f, 0xfd}, []byte("compressed frames")...)
+		if err := os.WriteFile(zstdFile, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		archive, err := openImageArchive(t.Context(), zstdFile, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer archive.Close()
+
+		streamed, err := io.ReadAll(archive)
+
+		// Assert
+		if err != nil || string(streamed) != string(content) || archive.inspectable || archive.plain {
+			t.Fatalf("unexpected archive: %v, %q, %+v", err, streamed, archive)
+		}
+	})
+}
+
+func TestImageArchitecture(t *testing.T) {
+	t.Run("reads the architecture of a docker save archive", func(t *testing.T) {
+		// Arrange
+		tarFile := filepath.Join(t.TempDir(), "image.tar")
+		if err := createTestDockerImageTar(tarFile); err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		_, architectures, err := (&dockerImageLoadResource{}).inspectLocalImageArchive(t.Context(), tarFile)
+
+		// Assert
+		if err != nil || !slices.Equal(architectures, []string{"amd64"}) {
+			t.Fatalf("unexpected architectures: %v, %v", architectures, err)
+		}
+	})
+
+	t.Run("reads the architectures of an OCI index, ignoring the attestations", func(t *testing.T) {
+		// Arrange
+		layout := createTestOCILayoutWithFiles(t, map[string]string{
+			"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+			"index.json": `{"schemaVersion":2,"manifests":[{"digest":"sha256:index"}]}`,
+			"blobs/sha256/index": `{"schemaVersion":2,"manifests":[
+				{"digest":"sha256:arm","platform":{"architecture":"arm64","os":"linux"}},
+				{"digest":"sha256:attestation","platform":{"architecture":"unknown","os":"unknown"}},
+				{"digest":"sha256:manifest"}]}`,
+			"blobs/sha256/manifest": `{"schemaVersion":2,"config":{"digest":"sha256:config"}}`,
+			"blobs/sha256/config":   `{"architecture":"riscv64","os":"linux"}`,
+		})
+
+		// Act
+		_, architectures, err := (&dockerImageLoadResource{}).inspectLocalImageArchive(t.Context(), layout)
+
+		// Assert
+		if err != nil || !slices.Equal(architectures, []string{"arm64", "riscv64"}) {
+			t.Fatalf("unexpected architectures: %v, %v", architectures, err)
+		}
+	})
+
+	t.Run("refuses an image of another architecture than the host", func(t *testing.T) {
+		// Arrange
+		p := withTestCapabilities(&internalProvider{}, clients.Capabilities{Architecture: "arm64"})
+		resource := &dockerImageLoadResource{provider: p}
+
+		// Act
+		err := resource.checkImageArchitecture(t.Context(), "image.tar", []string{"amd64"})
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), "built for amd64, but the host architecture is arm64") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("accepts a multi-platform image or an unknown architecture", func(t *testing.T) {
+		// Arrange
+		p := withTestCapabilities(&internalProvider{}, clients.Capabilities{Architecture: "arm64"})
+		resource := &dockerImageLoadResource{provider: p}
+
+		// Act
+		multiPlatformErr := resource.checkImageArchitecture(t.Context(), "image.tar", []string{"amd64", "arm64"})
+		unknownErr := resource.checkImageArchitecture(t.Context(), "image.tar", nil)
+
+		// Assert
+		if multiPlatformErr != nil || unknownErr != nil {
+			t.Fatalf("unexpected errors: %v, %v", multiPlatformErr, unknownErr)
+		}
+	})
+}
+
+func gzipTestFile(source string, target string) error {
+	content, err := os.ReadFile(filepath.Clean(source))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Clean(target))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write(content); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func createTestOCILayout(t *testing.T, layer string) string {
+	t.Helper()
+
+	return createTestOCILayoutWithFiles(t, map[string]string{
+		"oci-layout":             `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json":             `{"schemaVersion":2,"manifests":[]}`,
+		"blobs/sha256/layerblob": layer,
+	})
+}
+
+func createTestOCILayoutWithFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	layout := t.TempDir()
+
+	for name, content := range files {
+		filePath := filepath.Join(layout, name)
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return layout
+}
@@ -0,0 +1,758 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var _ resource.Resource = &dockerImageBuildResource{}
+var _ resource.ResourceWithModifyPlan = &dockerImageBuildResource{}
+
+func newDockerImageBuildResource(p *internalProvider) resource.Resource {
+	return &dockerImageBuildResource{
+		provider: p,
+	}
+}
+
+type dockerImageBuildResource struct {
+	provider *internalProvider
+}
+
+type dockerImageBuildResourceModel struct {
+	Context     types.String `tfsdk:"context"`
+	Dockerfile  types.String `tfsdk:"dockerfile"`
+	BuildArgs   types.Map    `tfsdk:"build_args"`
+	Target      types.String `tfsdk:"target"`
+	Tags        types.List   `tfsdk:"tags"`
+	Runtime     types.String `tfsdk:"runtime"`
+	ContextHash types.String `tfsdk:"context_hash"`
+	ImageSHA    types.String `tfsdk:"image_sha"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *dockerImageBuildResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_image_build"
+}
+
+func (r *dockerImageBuildResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Builds a Docker image on the remote daemon from a local build context and returns the SHA of the built image. The context is streamed over the connection, which avoids saving, transferring and loading the image when the host can build it",
+
+		Attributes: map[string]schema.Attribute{
+			"context": schema.StringAttribute{
+				Required:    true,
+				Description: "Local directory sent as the build context. The files matched by its .dockerignore are left out",
+			},
+			"dockerfile": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("Dockerfile"),
+				Description: "Path of the Dockerfile, relative to the context. Defaults to Dockerfile",
+				Validators:  []validator.String{notBlank()},
+			},
+			"build_args": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Values of the ARG instructions of the Dockerfile, by name",
+			},
+			"target": schema.StringAttribute{
+				Optional:    true,
+				Description: "Stage of a multi-stage Dockerfile to build, the last stage when not set",
+				Validators:  []validator.String{notBlank()},
+			},
+			"tags": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Tags given to the built image, e.g. myapp:deploy, so that it can be referenced by a stable name. The tags are removed on destroy",
+			},
+			"runtime": dockerRuntimeAttribute(),
+			"context_hash": schema.StringAttribute{
+				Computed:    true,
+				Description: "sha256 of the build context as sent to the daemon. It is computed while planning, so that a changed file shows up as an in-place update rebuilding the image",
+			},
+			"image_sha": schema.StringAttribute{
+				Computed:    true,
+				Description: "SHA of the built Docker image",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *dockerImageBuildResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	// Docker client will be created on-demand for each operation
+}
+
+// ModifyPlan hashes the build context, so that its changes are planned as a change of context_hash, and of image_sha
+// which is only known once the image is built again.
+func (r *dockerImageBuildResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan dockerImageBuildResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The context may be produced by another resource and only be known while applying
+	if plan.Context.IsUnknown() || plan.Dockerfile.IsUnknown() {
+		plan.ContextHash = types.StringUnknown()
+		plan.ImageSHA = types.StringUnknown()
+
+		resp.Diagnostics.Append(resp.Plan.Set(ctx, plan)...)
+
+		return
+	}
+
+	dockerfile := filepath.Join(plan.Context.ValueString(), plan.Dockerfile.ValueString())
+	if _, err := os.Stat(dockerfile); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("dockerfile"), "Dockerfile not found", fmt.Sprintf("The Dockerfile %s does not exist: %v", dockerfile, err))
+		return
+	}
+
+	contextHash, err := hashBuildContext(plan.Context.ValueString(), plan.Dockerfile.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("context"), "Failed to read build context", err.Error())
+		return
+	}
+
+	plan.ContextHash = types.StringValue(contextHash)
+	plan.ImageSHA = types.StringUnknown()
+
+	if !req.State.Raw.IsNull() {
+		var state dockerImageBuildResourceModel
+
+		diags = req.State.Get(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+
+		if !plan.rebuildNeeded(state) {
+			plan.ImageSHA = state.ImageSHA
+		}
+	}
+
+	diags = resp.Plan.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *dockerImageBuildResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+	ctx = r.provider.onRuntime(ctx, req.Plan)
+
+	var plan dockerImageBuildResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	imageSHA, contextHash, err := r.buildImage(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to build Docker image", err.Error())
+		return
+	}
+
+	plan.ImageSHA = types.StringValue(imageSHA)
+	plan.ContextHash = types.StringValue(contextHash)
+
+	var tags []string
+
+	diags = plan.Tags.ElementsAs(ctx, &tags, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.images().tagImage(ctx, imageSHA, tags); err != nil {
+		resp.Diagnostics.AddError("Failed to tag Docker image", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerImageBuildResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+	ctx = r.provider.onRuntime(ctx, req.State)
+
+	var state dockerImageBuildResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	imageSHA := state.ImageSHA.ValueString()
+
+	// A changed context is not checked here, but planned as a change of context_hash by ModifyPlan
+	exists, err := r.images().imageExistsRemotely(ctx, imageSHA)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read Docker image", err.Error())
+		return
+	}
+
+	if !exists {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if !state.Tags.IsNull() {
+		state.Tags, diags = r.images().readTags(ctx, imageSHA, state.Tags)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerImageBuildResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+	ctx = r.provider.onRuntime(ctx, req.Plan)
+
+	var plan dockerImageBuildResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state dockerImageBuildResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var tags, oldTags []string
+
+	resp.Diagnostics.Append(plan.Tags.ElementsAs(ctx, &tags, false)...)
+	resp.Diagnostics.Append(state.Tags.ElementsAs(ctx, &oldTags, false)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	images := r.images()
+	rebuild := plan.ContextHash.IsUnknown() || plan.rebuildNeeded(state)
+
+	if !rebuild {
+		plan.ImageSHA = state.ImageSHA
+
+		if err := images.tagImage(ctx, state.ImageSHA.ValueString(), tags); err != nil {
+			resp.Diagnostics.AddError("Failed to tag Docker image", err.Error())
+			return
+		}
+
+		if err := images.untagImage(ctx, removedTags(oldTags, tags)); err != nil {
+			resp.Diagnostics.AddError("Failed to untag Docker image", err.Error())
+			return
+		}
+
+		// Removing the last tag of an image removes the image, which is then built again
+		exists, err := images.imageExistsRemotely(ctx, state.ImageSHA.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read Docker image", err.Error())
+			return
+		}
+
+		rebuild = !exists
+	}
+
+	if rebuild {
+		// The old image is kept when the new one fails to build
+		imageSHA, contextHash, err := r.buildImage(ctx, plan)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to build Docker image", err.Error())
+			return
+		}
+
+		oldImageSHA := state.ImageSHA.ValueString()
+
+		if err := images.untagImage(ctx, removedTags(oldTags, tags)); err != nil {
+			resp.Diagnostics.AddWarning("Failed to untag old Docker image", err.Error())
+		}
+
+		if err := images.tagImage(ctx, imageSHA, tags); err != nil {
+			resp.Diagnostics.AddError("Failed to tag Docker image", err.Error())
+			return
+		}
+
+		// A build from an unchanged context may give the same image from the cache
+		if oldImageSHA != "" && oldImageSHA != imageSHA {
+			if err := images.removeImageIfExists(ctx, oldImageSHA); err != nil {
+				resp.Diagnostics.AddWarning("Failed to remove old Docker image", fmt.Sprintf("Could not remove old image %s: %v", oldImageSHA, err))
+			}
+		}
+
+		plan.ImageSHA = types.StringValue(imageSHA)
+		plan.ContextHash = types.StringValue(contextHash)
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerImageBuildResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+	ctx = r.provider.onRuntime(ctx, req.State)
+
+	var state dockerImageBuildResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var tags []string
+
+	diags = state.Tags.ElementsAs(ctx, &tags, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	images := r.images()
+
+	if err := images.untagImage(ctx, tags); err != nil {
+		resp.Diagnostics.AddError("Failed to untag Docker image", err.Error())
+		return
+	}
+
+	// The image is already gone when one of the tags was its last reference
+	imageSHA := state.ImageSHA.ValueString()
+	if imageSHA != "" {
+		if err := images.removeImageIfExists(ctx, imageSHA); err != nil {
+			resp.Diagnostics.AddError("Failed to remove Docker image", err.Error())
+			return
+		}
+	}
+}
+
+// images returns the operations on the images of the daemon, which are shared with setup_docker_image_load.
+func (r *dockerImageBuildResource) images() *dockerImageLoadResource {
+	return &dockerImageLoadResource{provider: r.provider}
+}
+
+// rebuildNeeded tells whether the image of the state was built from another context, Dockerfile, build arguments or
+// target than the ones of the plan.
+func (m dockerImageBuildResourceModel) rebuildNeeded(state dockerImageBuildResourceModel) bool {
+	return !m.Context.Equal(state.Context) ||
+		!m.ContextHash.Equal(state.ContextHash) ||
+		!m.Dockerfile.Equal(state.Dockerfile) ||
+		!m.BuildArgs.Equal(state.BuildArgs) ||
+		!m.Target.Equal(state.Target)
+}
+
+// buildImage streams the build context to the Docker API forwarded over the connection and returns the SHA of the
+// built image and the hash of the streamed context. The context is hashed on the way, so that a context changed since
+// it was planned is reported instead of being recorded with a stale hash.
+func (r *dockerImageBuildResource) buildImage(ctx context.Context, plan dockerImageBuildResourceModel) (string, string, error) {
+	buildArgs := map[string]*string{}
+
+	var args map[string]string
+
+	if diags := plan.BuildArgs.ElementsAs(ctx, &args, false); diags.HasError() {
+		return "", "", fmt.Errorf("failed to read the build arguments: %v", diags)
+	}
+
+	for name, value := range args {
+		buildArgs[name] = &value
+	}
+
+	dockerClient, err := r.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Docker client: %v", err)
+	}
+
+	contextDir := plan.Context.ValueString()
+	dockerfile := plan.Dockerfile.ValueString()
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(writeBuildContext(writer, contextDir, dockerfile))
+	}()
+	defer reader.Close()
+
+	hash := sha256.New()
+	input := io.TeeReader(reader, hash)
+
+	tflog.Info(ctx, fmt.Sprintf("Building %s from %s", dockerfile, contextDir))
+
+	response, err := dockerClient.ImageBuild(ctx, input, dockertypes.ImageBuildOptions{
+		Dockerfile:  filepath.ToSlash(dockerfile),
+		BuildArgs:   buildArgs,
+		Target:      plan.Target.ValueString(),
+		Remove:      true,
+		ForceRemove: true,
+		Version:     dockertypes.BuilderV1,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to build image via Docker API: %v", err)
+	}
+	defer response.Body.Close()
+
+	imageSHA, err := readBuildResponse(ctx, response.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	contextHash := fmt.Sprintf("sha256:%x", hash.Sum(nil))
+	if !plan.ContextHash.IsUnknown() && plan.ContextHash.ValueString() != contextHash {
+		return "", "", fmt.Errorf("the build context %s changed since the plan was made, the plan must be made again", contextDir)
+	}
+
+	return imageSHA, contextHash, nil
+}
+
+// dockerBuildMessage is a message of the JSON stream returned by the image build endpoint of the Docker API, whose aux
+// message holds the ID of the built image.
+type dockerBuildMessage struct {
+	dockerLoadMessage
+
+	Aux *struct {
+		ID string `json:"ID"`
+	} `json:"aux"`
+}
+
+var successfullyBuiltPattern = regexp.MustCompile(`^Successfully built ([0-9a-f]+)$`)
+
+// readBuildResponse reads the JSON stream of the image build endpoint until its end, logging the output of the steps,
+// and returns the ID of the built image. The daemons that do not send it in an aux message print it at the end of the
+// build. An error message of the daemon is returned as an error.
+func readBuildResponse(ctx context.Context, body io.Reader) (string, error) {
+	imageID := ""
+
+	decoder := json.NewDecoder(body)
+
+	for {
+		var message dockerBuildMessage
+
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("failed to read build response: %v", err)
+		}
+
+		if message.ErrorDetail != nil && message.ErrorDetail.Message != "" {
+			return "", fmt.Errorf("docker failed to build the image: %s", message.ErrorDetail.Message)
+		}
+
+		if message.Error != "" {
+			return "", fmt.Errorf("docker failed to build the image: %s", message.Error)
+		}
+
+		if message.Aux != nil && message.Aux.ID != "" {
+			imageID = message.Aux.ID
+		}
+
+		if message.Stream != "" {
+			line := strings.TrimSpace(message.Stream)
+			tflog.Info(ctx, line)
+
+			if match := successfullyBuiltPattern.FindStringSubmatch(line); match != nil && imageID == "" {
+				imageID = match[1]
+			}
+		}
+
+		if message.Status != "" {
+			tflog.Debug(ctx, strings.TrimSpace(message.ID+" "+message.Status))
+		}
+	}
+
+	if imageID == "" {
+		return "", fmt.Errorf("the build response has no image ID")
+	}
+
+	return imageID, nil
+}
+
+// hashBuildContext returns the sha256 of the build context as written by writeBuildContext.
+func hashBuildContext(contextDir string, dockerfile string) (string, error) {
+	hash := sha256.New()
+
+	if err := writeBuildContext(hash, contextDir, dockerfile); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// writeBuildContext writes the tar of the build context, leaving out the files matched by its .dockerignore. The
+// entries are written in lexical order without their times and owners, so that the same files always give the same
+// tar, whose hash only changes with the content, the names or the modes of the files. The Dockerfile and the
+// .dockerignore are always sent, as the daemon needs them.
+func writeBuildContext(writer io.Writer, contextDir string, dockerfile string) error {
+	ignore, err := readDockerignore(contextDir)
+	if err != nil {
+		return err
+	}
+
+	ignore.keep(filepath.ToSlash(filepath.Clean(dockerfile)), ".dockerignore")
+
+	tarWriter := tar.NewWriter(writer)
+
+	err = filepath.WalkDir(contextDir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(contextDir, file)
+		if err != nil {
+			return err
+		}
+
+		name = filepath.ToSlash(name)
+		if name == "." {
+			return nil
+		}
+
+		if ignore.excludes(name) {
+			// The files of an excluded directory are not sent unless an exception may match them
+			if entry.IsDir() && !ignore.mayKeepIn(name) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the build context: %v", file, err)
+		}
+
+		header.Name = name
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+
+		header.Format = tar.FormatPAX
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		header.ModTime, header.AccessTime, header.ChangeTime = time.Unix(0, 0), time.Time{}, time.Time{}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		content, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		_, err = io.Copy(tarWriter, content)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write the build context %s: %v", contextDir, err)
+	}
+
+	return tarWriter.Close()
+}
+
+// dockerignore holds the patterns of a .dockerignore file. As in docker, a path is excluded when it or one of its
+// parent directories is matched by the last matching pattern, unless that pattern is an exception starting with !.
+type dockerignore struct {
+	patterns []dockerignorePattern
+	kept     []string
+}
+
+type dockerignorePattern struct {
+	pattern   *regexp.Regexp
+	exception bool
+}
+
+// readDockerignore reads the .dockerignore of the context directory, which is empty when the file does not exist.
+func readDockerignore(contextDir string) (*dockerignore, error) {
+	content, err := os.ReadFile(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return &dockerignore{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %v", err)
+	}
+
+	return parseDockerignore(string(content)), nil
+}
+
+func parseDockerignore(content string) *dockerignore {
+	ignore := &dockerignore{}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		exception := strings.HasPrefix(line, "!")
+		if exception {
+			line = strings.TrimSpace(line[1:])
+		}
+
+		line = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(line)), "/")
+
+		ignore.patterns = append(ignore.patterns, dockerignorePattern{
+			pattern:   regexp.MustCompile("^" + dockerignoreExpression(line) + "(/.*)?$"),
+			exception: exception,
+		})
+	}
+
+	return ignore
+}
+
+// dockerignoreExpression translates a pattern of .dockerignore to a regular expression, where ** matches any number
+// of directories, * and ? do not match a /, and character classes are kept.
+func dockerignoreExpression(pattern string) string {
+	var expression strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expression.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case c == '*':
+			expression.WriteString("[^/]*")
+		case c == '?':
+			expression.WriteString("[^/]")
+		case c == '[' && strings.Contains(pattern[i:], "]"):
+			end := i + strings.Index(pattern[i:], "]")
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expression.WriteString("[" + class + "]")
+			i = end
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expression.String()
+}
+
+// keep adds the files the daemon needs whatever the .dockerignore says.
+func (d *dockerignore) keep(names ...string) {
+	d.kept = append(d.kept, names...)
+}
+
+func (d *dockerignore) excludes(name string) bool {
+	if slices.Contains(d.kept, name) {
+		return false
+	}
+
+	excluded := false
+
+	for _, pattern := range d.patterns {
+		if pattern.pattern.MatchString(name) {
+			excluded = !pattern.exception
+		}
+	}
+
+	return excluded
+}
+
+// mayKeepIn tells whether a file of the excluded directory may still be sent, because of an exception or of a kept
+// file.
+func (d *dockerignore) mayKeepIn(directory string) bool {
+	for _, name := range d.kept {
+		if strings.HasPrefix(name, directory+"/") {
+			return true
+		}
+	}
+
+	for _, pattern := range d.patterns {
+		if pattern.exception {
+			return true
+		}
+	}
+
+	return false
+}
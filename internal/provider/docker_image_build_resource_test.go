@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestDockerImageBuildResourceModifyPlan(t *testing.T) {
+	contextDir := testBuildContext(t, map[string]string{"Dockerfile": "FROM alpine\nCOPY app /app\n", "app": "v1"})
+
+	contextHash, err := hashBuildContext(contextDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := testDockerImageBuildModel(contextDir)
+
+	state := plan
+	state.ImageSHA = types.StringValue("sha256:built")
+	state.ContextHash = types.StringValue(contextHash)
+
+	t.Run("should keep the image of an unchanged context", func(t *testing.T) {
+		// Act
+		modified, diags := testResourceModifyPlan(t, newDockerImageBuildResource(nil).(*dockerImageBuildResource), &state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if modified.ContextHash.ValueString() != contextHash || modified.ImageSHA.ValueString() != "sha256:built" {
+			t.Fatalf("unexpected plan: %v", modified)
+		}
+	})
+
+	t.Run("should plan a new image for another target", func(t *testing.T) {
+		// Arrange
+		changed := plan
+		changed.Target = types.StringValue("runtime")
+
+		// Act
+		modified, diags := testResourceModifyPlan(t, newDockerImageBuildResource(nil).(*dockerImageBuildResource), &state, changed)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !modified.ImageSHA.IsUnknown() {
+			t.Fatalf("unexpected plan: %v", modified)
+		}
+	})
+
+	t.Run("should fail on a missing Dockerfile", func(t *testing.T) {
+		// Arrange
+		missing := plan
+		missing.Dockerfile = types.StringValue("build/Dockerfile")
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newDockerImageBuildResource(nil).(*dockerImageBuildResource), nil, missing)
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestDockerImageBuildResourceRead(t *testing.T) {
+	t.Run("should keep the image when the daemon cannot be reached", func(t *testing.T) {
+		// Arrange
+		state := testDockerImageBuildModel(t.TempDir())
+		state.ImageSHA = types.StringValue("sha256:built")
+		state.ContextHash = types.StringValue("0123abcd")
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDockerImageBuildResource(newTestProvider(clients.NewMockMachineAccessClient())), state)
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the image, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func TestWriteBuildContext(t *testing.T) {
+	t.Run("should leave out the files of .dockerignore but the Dockerfile", func(t *testing.T) {
+		// Arrange
+		contextDir := testBuildContext(t, map[string]string{
+			".dockerignore":                  "# local files\n*.log\nnode_modules\n**/secret.txt\nbuild\n!build/keep.txt\nDockerfile\n",
+			"Dockerfile":                     "FROM alpine\n",
+			"app.log":                        "log",
+			"src/main.go":                    "package main",
+			"src/secret.txt":                 "secret",
+			"node_modules/left-pad/index.js": "module.exports = {}",
+			"build/out.bin":                  "binary",
+			"build/keep.txt":                 "kept",
+		})
+
+		// Act
+		var archive bytes.Buffer
+		err := writeBuildContext(&archive, contextDir, "Dockerfile")
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := testTarNames(t, archive.Bytes())
+		expected := []string{".dockerignore", "Dockerfile", "build/keep.txt", "src/", "src/main.go"}
+		if !slices.Equal(names, expected) {
+			t.Fatalf("unexpected entries: %v", names)
+		}
+	})
+
+	t.Run("should give the same hash for the same files", func(t *testing.T) {
+		// Arrange
+		contextDir := testBuildContext(t, map[string]string{"Dockerfile": "FROM alpine\n", "app": "v1"})
+
+		first, err := hashBuildContext(contextDir, "Dockerfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(filepath.Join(contextDir, "app"), later, later); err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		touched, _ := hashBuildContext(contextDir, "Dockerfile")
+
+		if err := os.WriteFile(filepath.Join(contextDir, "app"), []byte("v2"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		changed, _ := hashBuildContext(contextDir, "Dockerfile")
+
+		// Assert
+		if touched != first || changed == first {
+			t.Fatalf("unexpected hashes: %s, %s, %s", first, touched, changed)
+		}
+	})
+}
+
+func TestReadBuildResponse(t *testing.T) {
+	t.Run("should return the ID of the aux message", func(t *testing.T) {
+		// Arrange
+		body := `{"stream":"Step 1/2 : FROM alpine\n"}
+{"aux":{"ID":"sha256:0123abcd"}}
+{"stream":"Successfully built 0123abcd\n"}
+`
+
+		// Act
+		imageID, err := readBuildResponse(t.Context(), strings.NewReader(body))
+
+		// Assert
+		if err != nil || imageID != "sha256:0123abcd" {
+			t.Fatalf("unexpected image ID: %s, %v", imageID, err)
+		}
+	})
+
+	t.Run("should fall back to the last step of the build", func(t *testing.T) {
+		// Act
+		imageID, err := readBuildResponse(t.Context(), strings.NewReader(`{"stream":"Successfully built 0123abcd\n"}`))
+
+		// Assert
+		if err != nil || imageID != "0123abcd" {
+			t.Fatalf("unexpected image ID: %s, %v", imageID, err)
+		}
+	})
+
+	t.Run("should return the error of the daemon", func(t *testing.T) {
+		// Arrange
+		body := `{"errorDetail":{"message":"The command '/bin/sh -c make' returned a non-zero code: 2"},"error":"The command '/bin/sh -c make' returned a non-zero code: 2"}`
+
+		// Act
+		_, err := readBuildResponse(t.Context(), strings.NewReader(body))
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), "non-zero code: 2") {
+			t.Fatalf("expected the daemon error, got: %v", err)
+		}
+	})
+}
+
+func testDockerImageBuildModel(contextDir string) dockerImageBuildResourceModel {
+	return dockerImageBuildResourceModel{
+		Context:     types.StringValue(contextDir),
+		Dockerfile:  types.StringValue("Dockerfile"),
+		BuildArgs:   types.MapNull(types.StringType),
+		Target:      types.StringNull(),
+		Tags:        types.ListNull(types.StringType),
+		Runtime:     types.StringValue(dockerRuntimeDocker),
+		ContextHash: types.StringNull(),
+		ImageSHA:    types.StringNull(),
+	}
+}
+
+func testBuildContext(t *testing.T, files map[string]string) string {
+	contextDir := t.TempDir()
+
+	for name, content := range files {
+		file := filepath.Join(contextDir, name)
+
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return contextDir
+}
+
+func testTarNames(t *testing.T, archive []byte) []string {
+	names := []string{}
+
+	reader := tar.NewReader(bytes.NewReader(archive))
+
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			break
+		}
+
+		names = append(names, header.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &dockerImageDataSource{}
+)
+
+func newDockerImageDataSource(p *internalProvider) datasource.DataSource {
+	return &dockerImageDataSource{
+		provider: p,
+	}
+}
+
+type dockerImageDataSource struct {
+	provider *internalProvider
+}
+
+type dockerImageDataSourceModel struct {
+	Name         types.String   `tfsdk:"name"`
+	ID           types.String   `tfsdk:"id"`
+	RepoTags     []types.String `tfsdk:"repo_tags"`
+	RepoDigests  []types.String `tfsdk:"repo_digests"`
+	Labels       types.Map      `tfsdk:"labels"`
+	Created      types.String   `tfsdk:"created"`
+	Size         types.Int64    `tfsdk:"size"`
+	Architecture types.String   `tfsdk:"architecture"`
+	OS           types.String   `tfsdk:"os"`
+	Runtime      types.String   `tfsdk:"runtime"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *dockerImageDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_image"
+}
+
+func (d *dockerImageDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Inspects an image of the remote Docker daemon, reached through the connection of the provider",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name, tag or ID of the image, e.g. 'nginx:1.27' or 'sha256:...'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the image, e.g. 'sha256:...'",
+			},
+			"repo_tags": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The tags of the image",
+			},
+			"repo_digests": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The digests of the image in the registries it was pulled from, e.g. 'nginx@sha256:...'. Empty for images that were built or loaded",
+			},
+			"labels": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The labels of the image",
+			},
+			"created": schema.StringAttribute{
+				Computed:    true,
+				Description: "When the image was created, in RFC 3339 format",
+			},
+			"size": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The size of the image in bytes",
+			},
+			"architecture": schema.StringAttribute{
+				Computed:    true,
+				Description: "The architecture of the image, e.g. 'amd64'",
+			},
+			"os": schema.StringAttribute{
+				Computed:    true,
+				Description: "The operating system of the image, e.g. 'linux'",
+			},
+			"runtime":     dockerRuntimeDataSourceAttribute(),
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *dockerImageDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+	ctx = d.provider.onRuntime(ctx, req.Config)
+
+	var model dockerImageDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create Docker client", err.Error())
+		return
+	}
+
+	image, _, err := dockerClient.ImageInspectWithRaw(ctx, model.Name.ValueString())
+	if errdefs.IsNotFound(err) {
+		resp.Diagnostics.AddError("Image not found", "The image "+model.Name.ValueString()+" does not exist on the remote Docker daemon")
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to inspect the image", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(model.fromInspect(ctx, image)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// fromInspect sets the attributes of the model from the inspection of the image.
+func (model *dockerImageDataSourceModel) fromInspect(ctx context.Context, image dockertypes.ImageInspect) diag.Diagnostics {
+	var labels map[string]string
+	if image.Config != nil {
+		labels = image.Config.Labels
+	}
+
+	var diags diag.Diagnostics
+
+	model.Labels, diags = types.MapValueFrom(ctx, types.StringType, nonNilMap(labels))
+
+	model.ID = types.StringValue(image.ID)
+	model.RepoTags = stringValues(image.RepoTags)
+	model.RepoDigests = stringValues(image.RepoDigests)
+	model.Created = types.StringValue(image.Created)
+	model.Size = types.Int64Value(image.Size)
+	model.Architecture = types.StringValue(image.Architecture)
+	model.OS = types.StringValue(image.Os)
+
+	return diags
+}
+
+// stringValues returns the values as a list attribute, empty rather than null when there is none.
+func stringValues(values []string) []types.String {
+	list := []types.String{}
+	for _, value := range values {
+		list = append(list, types.StringValue(value))
+	}
+
+	return list
+}
+
+// nonNilMap returns the map, or an empty map when it is nil, so that the attribute is empty rather than null.
+func nonNilMap(values map[string]string) map[string]string {
+	if values == nil {
+		return map[string]string{}
+	}
+
+	return values
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestDockerImageDataSourceWithMock(t *testing.T) {
+	t.Run("the inspection of the image is converted", func(t *testing.T) {
+		// Arrange
+		var model dockerImageDataSourceModel
+
+		// Act
+		diags := model.fromInspect(context.Background(), dockertypes.ImageInspect{
+			ID:           "sha256:4f8a",
+			RepoTags:     []string{"nginx:1.27"},
+			RepoDigests:  []string{"nginx@sha256:9c1f"},
+			Created:      "2024-08-14T21:31:12.000000000Z",
+			Size:         187654321,
+			Architecture: "amd64",
+			Os:           "linux",
+			Config:       &container.Config{Labels: map[string]string{"maintainer": "NGINX"}},
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if model.ID.ValueString() != "sha256:4f8a" || len(model.RepoDigests) != 1 || model.RepoDigests[0].ValueString() != "nginx@sha256:9c1f" || model.Size.ValueInt64() != 187654321 {
+			t.Fatalf("unexpected model: %+v", model)
+		}
+
+		if model.Labels.Elements()["maintainer"].String() != `"NGINX"` {
+			t.Fatalf("unexpected labels: %v", model.Labels)
+		}
+	})
+
+	t.Run("an image without config has no labels", func(t *testing.T) {
+		// Arrange
+		var model dockerImageDataSourceModel
+
+		// Act
+		diags := model.fromInspect(context.Background(), dockertypes.ImageInspect{ID: "sha256:4f8a"})
+
+		// Assert
+		if diags.HasError() || model.Labels.IsNull() || len(model.Labels.Elements()) != 0 || model.RepoTags == nil {
+			t.Fatalf("unexpected model: %v, %+v", diags, model)
+		}
+	})
+
+	t.Run("read fails without Docker client", func(t *testing.T) {
+		// Act
+		_, diags := testDataSourceRead(t, newDockerImageDataSource(newTestProvider(clients.NewMockMachineAccessClient())), dockerImageDataSourceModel{
+			Name:   types.StringValue("nginx:1.27"),
+			Labels: types.MapNull(types.StringType),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
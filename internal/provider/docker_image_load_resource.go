@@ -3,23 +3,30 @@ package provider
 import (
 	"archive/tar"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
 	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var _ resource.Resource = &dockerImageLoadResource{}
 var _ resource.ResourceWithImportState = &dockerImageLoadResource{}
+var _ resource.ResourceWithModifyPlan = &dockerImageLoadResource{}
 
 func newDockerImageLoadResource(p *internalProvider) resource.Resource {
 	return &dockerImageLoadResource{
@@ -35,6 +42,11 @@ type dockerImageLoadResourceModel struct {
 	TarFile     types.String `tfsdk:"tar_file"`
 	ImageSHA    types.String `tfsdk:"image_sha"`
 	ContentHash types.String `tfsdk:"content_hash"`
+	UploadFirst types.Bool   `tfsdk:"upload_first"`
+	Tags        types.List   `tfsdk:"tags"`
+	Runtime     types.String `tfsdk:"runtime"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (d *dockerImageLoadResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -48,7 +60,7 @@ func (d *dockerImageLoadResource) Schema(_ context.Context, _ resource.SchemaReq
 		Attributes: map[string]schema.Attribute{
 			"tar_file": schema.StringAttribute{
 				Required:    true,
-				Description: "Path to the tar file containing the Docker image",
+				Description: "Path to the Docker image archive: a docker save or OCI image layout tar file, optionally compressed with gzip or zstd, or an OCI image layout directory",
 			},
 			"image_sha": schema.StringAttribute{
 				Computed:    true,
@@ -56,8 +68,21 @@ func (d *dockerImageLoadResource) Schema(_ context.Context, _ resource.SchemaReq
 			},
 			"content_hash": schema.StringAttribute{
 				Computed:    true,
-				Description: "Hash of the tar file content for change detection",
+				Description: "sha256 of the whole tar file, so that a change of any layer is detected. It is computed while planning, so that a changed archive shows up as an in-place update",
+			},
+			"upload_first": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Upload the tar file to the remote machine before loading it, instead of streaming it to the Docker API. The upload is resumed after a dropped connection and verified with its sha256 checksum, which is more reliable for large images on slow or flaky links",
 			},
+			"tags": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Tags given to the loaded image, e.g. myapp:deploy, so that it can be referenced by a stable name. The tags are removed on destroy",
+			},
+			"runtime":     dockerRuntimeAttribute(),
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -66,7 +91,67 @@ func (d *dockerImageLoadResource) Configure(_ context.Context, _ resource.Config
 	// Docker client will be created on-demand for each operation
 }
 
+// ModifyPlan hashes the archive, so that its changes are planned as a change of content_hash, and of image_sha which is
+// only known once the image is loaded again.
+func (d *dockerImageLoadResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan dockerImageLoadResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The archive may be produced by another resource and only be known while applying
+	if plan.TarFile.IsUnknown() {
+		plan.ContentHash = types.StringUnknown()
+		plan.ImageSHA = types.StringUnknown()
+
+		resp.Diagnostics.Append(resp.Plan.Set(ctx, plan)...)
+
+		return
+	}
+
+	tarFilePath := strings.Trim(plan.TarFile.ValueString(), `"`)
+
+	contentHash, err := d.getImageContentHashFromLocalTar(ctx, tarFilePath)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("tar_file"), "Failed to inspect tar file", fmt.Sprintf("Error reading tar file %s: %v", tarFilePath, err))
+		return
+	}
+
+	plan.ContentHash = types.StringValue(contentHash)
+	plan.ImageSHA = types.StringUnknown()
+
+	if !req.State.Raw.IsNull() {
+		var state dockerImageLoadResourceModel
+
+		diags = req.State.Get(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+
+		// The same archive gives the same image, even when it is loaded again to get back a removed tag
+		if state.ContentHash.ValueString() == contentHash {
+			plan.ImageSHA = state.ImageSHA
+		}
+	}
+
+	diags = resp.Plan.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
 func (d *dockerImageLoadResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = d.provider.mutating(ctx, d, "create", req.Plan)
+	ctx = d.provider.onRuntime(ctx, req.Plan)
+
 	var plan dockerImageLoadResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -85,14 +170,24 @@ func (d *dockerImageLoadResource) Create(ctx context.Context, req resource.Creat
 	}
 
 	// Get the content hash for change detection
-	contentHash, err := d.getImageContentHashFromLocalTar(ctx, tarFilePath)
+	contentHash, architectures, err := d.inspectLocalImageArchive(ctx, tarFilePath)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to inspect tar file", fmt.Sprintf("Error reading tar file %s: %v", tarFilePath, err))
 		return
 	}
 
+	if !plan.ContentHash.IsUnknown() && plan.ContentHash.ValueString() != contentHash {
+		resp.Diagnostics.AddError("Tar file changed", fmt.Sprintf("The tar file %s changed since the plan was made, the plan must be made again", tarFilePath))
+		return
+	}
+
+	if err := d.checkImageArchitecture(ctx, tarFilePath, architectures); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("tar_file"), "Image architecture mismatch", err.Error())
+		return
+	}
+
 	// Load the Docker image using remote Docker socket via SSH
-	imageSHA, err := d.loadImageUsingRemoteDocker(ctx, tarFilePath)
+	imageSHA, err := d.loadImage(ctx, tarFilePath, plan.UploadFirst.ValueBool(), plan.Runtime, contentHash)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to load Docker image", fmt.Sprintf("Error: %v", err))
 		return
@@ -101,6 +196,20 @@ func (d *dockerImageLoadResource) Create(ctx context.Context, req resource.Creat
 	plan.ImageSHA = types.StringValue(imageSHA)
 	plan.ContentHash = types.StringValue(contentHash)
 
+	var tags []string
+
+	diags = plan.Tags.ElementsAs(ctx, &tags, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := d.tagImage(ctx, imageSHA, tags); err != nil {
+		resp.Diagnostics.AddError("Failed to tag Docker image", err.Error())
+		return
+	}
+
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 
@@ -110,6 +219,9 @@ func (d *dockerImageLoadResource) Create(ctx context.Context, req resource.Creat
 }
 
 func (d *dockerImageLoadResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.State)
+	ctx = d.provider.onRuntime(ctx, req.State)
+
 	var state dockerImageLoadResourceModel
 
 	diags := req.State.Get(ctx, &state)
@@ -119,35 +231,34 @@ func (d *dockerImageLoadResource) Read(ctx context.Context, req resource.ReadReq
 		return
 	}
 
+	// The imported images have no runtime yet, they are loaded in docker like the ones of the previous versions
+	if state.Runtime.IsNull() {
+		state.Runtime = types.StringValue(dockerRuntimeDocker)
+	}
+
 	imageSHA := state.ImageSHA.ValueString()
-	tarFilePath := strings.Trim(state.TarFile.ValueString(), `"`)
 
-	// Check if the tar file still exists and get its expected SHA
-	if _, err := os.Stat(tarFilePath); err != nil {
-		// Tar file no longer exists, remove the resource
-		resp.State.RemoveResource(ctx)
-		return
-	}
+	// A changed tar file is not checked here, but planned as a change of content_hash by ModifyPlan
 
-	currentContentHash, err := d.getImageContentHashFromLocalTar(ctx, tarFilePath)
+	// Check if the image still exists on the remote machine
+	exists, err := d.imageExistsRemotely(ctx, imageSHA)
 	if err != nil {
-		// Can't read the tar file, treat as if resource should be removed
-		resp.State.RemoveResource(ctx)
+		resp.Diagnostics.AddError("Failed to read Docker image", err.Error())
 		return
 	}
 
-	// If the current content hash differs from stored state, the tar content has changed
-	// and we need to trigger an update by removing the resource so it gets recreated
-	storedContentHash := state.ContentHash.ValueString()
-	if currentContentHash != storedContentHash {
+	if !exists {
 		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	// Check if the image still exists on the remote machine
-	if !d.imageExistsRemotely(ctx, imageSHA) {
-		resp.State.RemoveResource(ctx)
-		return
+	if !state.Tags.IsNull() {
+		state.Tags, diags = d.readTags(ctx, imageSHA, state.Tags)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
 	}
 
 	diags = resp.State.Set(ctx, state)
@@ -159,6 +270,9 @@ func (d *dockerImageLoadResource) Read(ctx context.Context, req resource.ReadReq
 }
 
 func (d *dockerImageLoadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = d.provider.mutating(ctx, d, "update", req.Plan)
+	ctx = d.provider.onRuntime(ctx, req.Plan)
+
 	var plan dockerImageLoadResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -180,26 +294,68 @@ func (d *dockerImageLoadResource) Update(ctx context.Context, req resource.Updat
 	// Get the expected image content hash from the tar file
 	tarFilePath := strings.Trim(plan.TarFile.ValueString(), `"`)
 
-	expectedContentHash, err := d.getImageContentHashFromLocalTar(ctx, tarFilePath)
+	expectedContentHash, architectures, err := d.inspectLocalImageArchive(ctx, tarFilePath)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to inspect tar file", fmt.Sprintf("Error reading tar file %s: %v", tarFilePath, err))
 		return
 	}
 
-	// Get the current tar's content hash
-	oldTarFilePath := strings.Trim(state.TarFile.ValueString(), `"`)
-	oldContentHash := ""
+	if !plan.ContentHash.IsUnknown() && plan.ContentHash.ValueString() != expectedContentHash {
+		resp.Diagnostics.AddError("Tar file changed", fmt.Sprintf("The tar file %s changed since the plan was made, the plan must be made again", tarFilePath))
+		return
+	}
+
+	plan.ImageSHA = state.ImageSHA
+	plan.ContentHash = state.ContentHash
+
+	var tags, oldTags []string
 
-	if _, err := os.Stat(oldTarFilePath); err == nil {
-		oldContentHash, _ = d.getImageContentHashFromLocalTar(ctx, oldTarFilePath)
+	resp.Diagnostics.Append(plan.Tags.ElementsAs(ctx, &tags, false)...)
+	resp.Diagnostics.Append(state.Tags.ElementsAs(ctx, &oldTags, false)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	// Check if tar file path changed OR if the expected content hash differs from current state
-	if !plan.TarFile.Equal(state.TarFile) || expectedContentHash != oldContentHash {
+	// Check if tar file path changed OR if the expected content hash differs from the one of the loaded image
+	reload := !plan.TarFile.Equal(state.TarFile) || expectedContentHash != state.ContentHash.ValueString()
+
+	if !reload {
+		if err := d.tagImage(ctx, state.ImageSHA.ValueString(), tags); err != nil {
+			resp.Diagnostics.AddError("Failed to tag Docker image", err.Error())
+			return
+		}
+
+		if err := d.untagImage(ctx, removedTags(oldTags, tags)); err != nil {
+			resp.Diagnostics.AddError("Failed to untag Docker image", err.Error())
+			return
+		}
+
+		// Removing the last tag of an image removes the image, which is then loaded again
+		exists, err := d.imageExistsRemotely(ctx, state.ImageSHA.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read Docker image", err.Error())
+			return
+		}
+
+		reload = !exists
+	}
+
+	if reload {
+		// The old image is kept when the new one can not run on the host
+		if err := d.checkImageArchitecture(ctx, tarFilePath, architectures); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("tar_file"), "Image architecture mismatch", err.Error())
+			return
+		}
+
 		oldImageSHA := state.ImageSHA.ValueString()
 
+		if err := d.untagImage(ctx, oldTags); err != nil {
+			resp.Diagnostics.AddWarning("Failed to untag old Docker image", err.Error())
+		}
+
 		if oldImageSHA != "" {
-			if err := d.removeImageRemotely(ctx, oldImageSHA); err != nil {
+			if err := d.removeImageIfExists(ctx, oldImageSHA); err != nil {
 				resp.Diagnostics.AddWarning("Failed to remove old Docker image", fmt.Sprintf("Could not remove old image %s: %v", oldImageSHA, err))
 			}
 		}
@@ -213,7 +369,7 @@ func (d *dockerImageLoadResource) Update(ctx context.Context, req resource.Updat
 		}
 
 		// Load the Docker image using remote Docker socket via SSH
-		imageSHA, err := d.loadImageUsingRemoteDocker(ctx, tarFilePath)
+		imageSHA, err := d.loadImage(ctx, tarFilePath, plan.UploadFirst.ValueBool(), plan.Runtime, expectedContentHash)
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to load Docker image", fmt.Sprintf("Error: %v", err))
 			return
@@ -221,6 +377,11 @@ func (d *dockerImageLoadResource) Update(ctx context.Context, req resource.Updat
 
 		plan.ImageSHA = types.StringValue(imageSHA)
 		plan.ContentHash = types.StringValue(expectedContentHash)
+
+		if err := d.tagImage(ctx, imageSHA, tags); err != nil {
+			resp.Diagnostics.AddError("Failed to tag Docker image", err.Error())
+			return
+		}
 	}
 
 	diags = resp.State.Set(ctx, plan)
@@ -232,6 +393,9 @@ func (d *dockerImageLoadResource) Update(ctx context.Context, req resource.Updat
 }
 
 func (d *dockerImageLoadResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = d.provider.mutating(ctx, d, "delete", req.State)
+	ctx = d.provider.onRuntime(ctx, req.State)
+
 	var state dockerImageLoadResourceModel
 
 	diags := req.State.Get(ctx, &state)
@@ -241,9 +405,24 @@ func (d *dockerImageLoadResource) Delete(ctx context.Context, req resource.Delet
 		return
 	}
 
+	var tags []string
+
+	diags = state.Tags.ElementsAs(ctx, &tags, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := d.untagImage(ctx, tags); err != nil {
+		resp.Diagnostics.AddError("Failed to untag Docker image", err.Error())
+		return
+	}
+
+	// The image is already gone when one of the tags was its last reference
 	imageSHA := state.ImageSHA.ValueString()
 	if imageSHA != "" {
-		if err := d.removeImageRemotely(ctx, imageSHA); err != nil {
+		if err := d.removeImageIfExists(ctx, imageSHA); err != nil {
 			resp.Diagnostics.AddError("Failed to remove Docker image", err.Error())
 			return
 		}
@@ -258,17 +437,54 @@ type dockerManifest struct {
 	Config string `json:"Config"`
 }
 
+// getImageContentHashFromLocalTar returns the sha256 of the whole tar stream of the image archive, after checking that
+// it is a docker or OCI image archive. Hashing every byte, rather than only the manifest, detects the changes of the
+// layers.
 func (d *dockerImageLoadResource) getImageContentHashFromLocalTar(ctx context.Context, tarFilePath string) (string, error) {
-	tflog.Debug(ctx, "Getting the sha of the local tar file")
+	contentHash, _, err := d.inspectLocalImageArchive(ctx, tarFilePath)
+
+	return contentHash, err
+}
+
+// inspectLocalImageArchive returns the content hash of the image archive, as getImageContentHashFromLocalTar, and the
+// architectures of the image, which are read in the same pass. They are empty when the archive is compressed with
+// zstd or its configs are not found.
+func (d *dockerImageLoadResource) inspectLocalImageArchive(ctx context.Context, tarFilePath string) (string, []string, error) {
+	tflog.Debug(ctx, "Getting the sha of the local image archive")
 
-	// #nosec G304 - tarFilePath is user-provided and we need to read their specified tar file
-	file, err := os.Open(tarFilePath)
+	archive, err := openImageArchive(ctx, tarFilePath, "")
 	if err != nil {
-		return "", fmt.Errorf("failed to open tar file: %v", err)
+		return "", nil, err
 	}
-	defer file.Close()
+	defer archive.Close()
+
+	hash := sha256.New()
+	reader := io.TeeReader(archive, hash)
 
-	tarReader := tar.NewReader(file)
+	var architectures []string
+
+	if archive.inspectable {
+		architectures, err = checkImageArchive(tar.NewReader(reader))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	// The padding after the end of the archive is not read by the tar reader
+	_, err = io.Copy(io.Discard, reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read tar file: %v", err)
+	}
+
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), architectures, nil
+}
+
+// checkImageArchive reads the tar stream until its end, checks that it has the manifest.json of a docker save
+// archive or the index.json of an OCI image layout, and returns the architectures of the image. The small JSON files
+// are kept, as the configs may come before or after the manifests which reference them.
+func checkImageArchive(tarReader *tar.Reader) ([]string, error) {
+	foundManifest := false
+	metadata := map[string][]byte{}
 
 	for {
 		header, err := tarReader.Next()
@@ -277,66 +493,183 @@ func (d *dockerImageLoadResource) getImageContentHashFromLocalTar(ctx context.Co
 		}
 
 		if err != nil {
-			return "", fmt.Errorf("failed to read tar file: %v", err)
+			return nil, fmt.Errorf("failed to read tar file: %v", err)
 		}
 
-		if header.Name == "manifest.json" {
-			manifestBytes, err := io.ReadAll(tarReader)
-			if err != nil {
-				return "", fmt.Errorf("failed to read manifest.json: %v", err)
-			}
+		name := strings.TrimPrefix(header.Name, "./")
 
-			var manifests []dockerManifest
-			if err := json.Unmarshal(manifestBytes, &manifests); err != nil {
-				return "", fmt.Errorf("failed to parse manifest.json: %v", err)
-			}
+		if name == "index.json" {
+			foundManifest = true
+		}
+
+		if name != "manifest.json" {
+			if header.Size > 0 && header.Size <= maxImageMetadataSize {
+				content, err := io.ReadAll(tarReader)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %s: %v", header.Name, err)
+				}
 
-			if len(manifests) == 0 {
-				return "", fmt.Errorf("no manifests found in manifest.json")
+				if len(content) > 0 && content[0] == '{' {
+					metadata[name] = content
+				}
 			}
 
-			// Use the config file name as a content hash for change detection
-			configFile := manifests[0].Config
+			continue
+		}
+
+		manifestBytes, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest.json: %v", err)
+		}
+
+		var manifests []dockerManifest
+		if err := json.Unmarshal(manifestBytes, &manifests); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest.json: %v", err)
+		}
 
-			return configFile, nil
+		if len(manifests) == 0 {
+			return nil, fmt.Errorf("no manifests found in manifest.json")
 		}
+
+		metadata[name] = manifestBytes
+		foundManifest = true
 	}
 
-	return "", fmt.Errorf("manifest.json not found in tar file")
+	if !foundManifest {
+		return nil, fmt.Errorf("neither manifest.json nor index.json found in tar file")
+	}
+
+	return imageArchitectures(metadata), nil
+}
+
+// checkImageArchitecture checks that the image is built for the architecture of the host, so that loading an amd64
+// image on an arm64 host fails before the upload, rather than when a container starts with an exec format error. The
+// check is skipped when the architectures of the image or of the host are unknown.
+func (d *dockerImageLoadResource) checkImageArchitecture(ctx context.Context, tarFilePath string, architectures []string) error {
+	hostArchitecture := d.provider.capabilities(ctx).Architecture
+
+	if hostArchitecture == "" || len(architectures) == 0 || slices.Contains(architectures, hostArchitecture) {
+		return nil
+	}
+
+	return fmt.Errorf("the image archive %s is built for %s, but the host architecture is %s. Build the image for the "+
+		"host, e.g. with docker buildx build --platform linux/%s, or pull it with docker pull --platform linux/%s before saving it",
+		tarFilePath, strings.Join(architectures, ", "), hostArchitecture, hostArchitecture, hostArchitecture)
+}
+
+// loadImage loads the image either by streaming the tar file to the remote Docker API or, when uploadFirst is set, by
+// uploading it to the remote machine and loading it from there with the command line interface of the runtime.
+func (d *dockerImageLoadResource) loadImage(ctx context.Context, tarFilePath string, uploadFirst bool, runtime types.String, contentHash string) (string, error) {
+	if !uploadFirst {
+		return d.loadImageUsingRemoteDocker(ctx, tarFilePath, contentHash)
+	}
+
+	// The remote path only depends on the image, so that an upload interrupted in a previous apply is resumed
+	remotePath := fmt.Sprintf("/var/tmp/setup-docker-image-%x.tar", sha256.Sum256([]byte(contentHash)))
+
+	err := d.uploadImageArchive(ctx, tarFilePath, remotePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload tar file: %v", err)
+	}
+
+	defer func() {
+		_, _ = d.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-f", remotePath))
+	}()
+
+	result, err := d.provider.machineAccessClient.Run(clients.WithStreamedOutput(ctx), d.provider.sudo(clients.ShellCommand(dockerRuntimeCommand(runtime), "load", "-i", remotePath)))
+	if err != nil {
+		return "", fmt.Errorf("failed to load image: %v\nout = %s", err, result.Stdout)
+	}
+
+	loadedImage := d.parseLoadedImageFromOutput(result.Stdout)
+	if loadedImage == "" {
+		return "", fmt.Errorf("could not extract loaded image from docker load output: %s", result.Stdout)
+	}
+
+	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %v", err)
+	}
+
+	imageInspect, _, err := dockerClient.ImageInspectWithRaw(ctx, loadedImage)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect loaded image %s: %v", loadedImage, err)
+	}
+
+	return imageInspect.ID, nil
+}
+
+// uploadImageArchive uploads a tar file as it is. The other archives are first written to a local tar file, which can
+// be loaded by docker load and resumed by the upload.
+func (d *dockerImageLoadResource) uploadImageArchive(ctx context.Context, archivePath string, remotePath string) error {
+	archive, err := openImageArchive(ctx, archivePath, "")
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	if archive.plain {
+		return d.provider.machineAccessClient.CopyFile(ctx, archivePath, remotePath)
+	}
+
+	tarFile, err := os.CreateTemp("", "setup-docker-image-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create local tar file: %v", err)
+	}
+
+	defer os.Remove(tarFile.Name())
+
+	_, err = io.Copy(tarFile, archive)
+	if closeErr := tarFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write local tar file: %v", err)
+	}
+
+	return d.provider.machineAccessClient.CopyFile(ctx, tarFile.Name(), remotePath)
 }
 
-func (d *dockerImageLoadResource) loadImageUsingRemoteDocker(ctx context.Context, tarFilePath string) (string, error) {
+// loadImageUsingRemoteDocker streams the image archive to the Docker API forwarded over the connection, logging the
+// progress of the transfer and the messages of the daemon. The streamed bytes are hashed on the way, so that an archive
+// changed since contentHash was computed is reported instead of being recorded with a stale hash.
+func (d *dockerImageLoadResource) loadImageUsingRemoteDocker(ctx context.Context, tarFilePath string, contentHash string) (string, error) {
 	// Create Docker client on-demand using the machine access client
 	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Docker client: %v", err)
 	}
 
-	// Open the tar file for loading
-	// #nosec G304 - tarFilePath is user-provided and we need to read their specified tar file
-	tarFile, err := os.Open(tarFilePath)
+	archive, err := openImageArchive(ctx, tarFilePath, "Loading "+tarFilePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open tar file: %v", err)
+		return "", err
 	}
-	defer tarFile.Close()
+	defer archive.Close()
 
-	// Load the image using Docker SDK
-	response, err := dockerClient.ImageLoad(ctx, tarFile, true)
+	hash := sha256.New()
+	input := io.TeeReader(archive, hash)
+
+	response, err := dockerClient.ImageLoad(ctx, input, false)
 	if err != nil {
 		return "", fmt.Errorf("failed to load image via Docker API: %v", err)
 	}
 	defer response.Body.Close()
 
-	// Read the response to get load details
-	responseBytes, err := io.ReadAll(response.Body)
+	output, err := d.readLoadResponse(ctx, response.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read load response: %v", err)
+		return "", err
+	}
+
+	streamedHash := fmt.Sprintf("sha256:%x", hash.Sum(nil))
+	if streamedHash != contentHash {
+		return "", fmt.Errorf("the tar file %s changed while it was loaded (%s instead of %s)", tarFilePath, streamedHash, contentHash)
 	}
 
 	// Parse the output to get the loaded image reference
-	loadedImage := d.parseLoadedImageFromOutput(string(responseBytes))
+	loadedImage := d.parseLoadedImageFromOutput(output)
 	if loadedImage == "" {
-		return "", fmt.Errorf("could not extract loaded image from docker load output: %s", string(responseBytes))
+		return "", fmt.Errorf("could not extract loaded image from docker load output: %s", output)
 	}
 
 	// Get the actual SHA of the loaded image using Docker API
@@ -348,16 +681,86 @@ func (d *dockerImageLoadResource) loadImageUsingRemoteDocker(ctx context.Context
 	return imageInspect.ID, nil
 }
 
-func (d *dockerImageLoadResource) imageExistsRemotely(ctx context.Context, imageSHA string) bool {
+// dockerLoadMessage is a message of the JSON stream returned by the image load endpoint of the Docker API.
+type dockerLoadMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	ID          string `json:"id"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readLoadResponse reads the JSON stream of the image load endpoint until its end, logging its messages, and returns
+// the text of its stream messages. An error message of the daemon is returned as an error.
+func (d *dockerImageLoadResource) readLoadResponse(ctx context.Context, body io.Reader) (string, error) {
+	var output strings.Builder
+
+	decoder := json.NewDecoder(body)
+
+	for {
+		var message dockerLoadMessage
+
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("failed to read load response: %v", err)
+		}
+
+		if message.ErrorDetail != nil && message.ErrorDetail.Message != "" {
+			return "", fmt.Errorf("docker failed to load the image: %s", message.ErrorDetail.Message)
+		}
+
+		if message.Error != "" {
+			return "", fmt.Errorf("docker failed to load the image: %s", message.Error)
+		}
+
+		if message.Stream != "" {
+			tflog.Info(ctx, strings.TrimSpace(message.Stream))
+			output.WriteString(message.Stream)
+		}
+
+		if message.Status != "" {
+			tflog.Debug(ctx, strings.TrimSpace(message.ID+" "+message.Status))
+		}
+	}
+
+	return output.String(), nil
+}
+
+// imageExistsRemotely returns whether the image is in the daemon. Only a missing image is false, the failures to reach
+// the daemon are errors, so that a dropped connection does not remove the image from the state.
+func (d *dockerImageLoadResource) imageExistsRemotely(ctx context.Context, imageSHA string) (bool, error) {
 	// Create Docker client on-demand using the machine access client
 	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
 	if err != nil {
-		return false
+		return false, fmt.Errorf("failed to create Docker client: %v", err)
 	}
 
 	_, _, err = dockerClient.ImageInspectWithRaw(ctx, imageSHA)
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect image %s: %w", imageSHA, err)
+	}
+
+	return true, nil
+}
+
+// removeImageIfExists removes the image unless it is already gone.
+func (d *dockerImageLoadResource) removeImageIfExists(ctx context.Context, imageSHA string) error {
+	exists, err := d.imageExistsRemotely(ctx, imageSHA)
+	if err != nil || !exists {
+		return err
+	}
 
-	return err == nil
+	return d.removeImageRemotely(ctx, imageSHA)
 }
 
 func (d *dockerImageLoadResource) removeImageRemotely(ctx context.Context, imageSHA string) error {
@@ -372,6 +775,108 @@ func (d *dockerImageLoadResource) removeImageRemotely(ctx context.Context, image
 	return err
 }
 
+// tagImage adds the tags to the image, moving them from the images they were given to.
+func (d *dockerImageLoadResource) tagImage(ctx context.Context, imageSHA string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+
+	for _, tag := range tags {
+		if err := dockerClient.ImageTag(ctx, imageSHA, tag); err != nil {
+			return fmt.Errorf("failed to tag image %s as %s: %v", imageSHA, tag, err)
+		}
+	}
+
+	return nil
+}
+
+// untagImage removes the tags, ignoring the ones that do not exist anymore.
+func (d *dockerImageLoadResource) untagImage(ctx context.Context, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+
+	for _, tag := range tags {
+		_, err := dockerClient.ImageRemove(ctx, tag, dockertypes.ImageRemoveOptions{})
+		if err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to remove tag %s: %v", tag, err)
+		}
+	}
+
+	return nil
+}
+
+// readTags returns the tags of the state that still point to the image, so that the others are given again.
+func (d *dockerImageLoadResource) readTags(ctx context.Context, imageSHA string, stateTags types.List) (types.List, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	dockerClient, err := d.provider.machineAccessClient.GetDockerClient(ctx)
+	if err != nil {
+		diags.AddError("Failed to create Docker client", err.Error())
+		return stateTags, diags
+	}
+
+	imageInspect, _, err := dockerClient.ImageInspectWithRaw(ctx, imageSHA)
+	if err != nil {
+		diags.AddError("Failed to inspect Docker image", err.Error())
+		return stateTags, diags
+	}
+
+	var tags []string
+
+	diags.Append(stateTags.ElementsAs(ctx, &tags, false)...)
+
+	if diags.HasError() {
+		return stateTags, diags
+	}
+
+	present := []string{}
+
+	for _, tag := range tags {
+		if slices.Contains(imageInspect.RepoTags, normalizeImageTag(tag)) {
+			present = append(present, tag)
+		}
+	}
+
+	list, listDiags := types.ListValueFrom(ctx, types.StringType, present)
+	diags.Append(listDiags...)
+
+	return list, diags
+}
+
+// removedTags returns the old tags that are not in tags.
+func removedTags(oldTags []string, tags []string) []string {
+	removed := []string{}
+
+	for _, tag := range oldTags {
+		if !slices.Contains(tags, tag) {
+			removed = append(removed, tag)
+		}
+	}
+
+	return removed
+}
+
+// normalizeImageTag returns the tag as listed in the RepoTags of an image, where a missing tag is latest.
+func normalizeImageTag(tag string) string {
+	name := tag[strings.LastIndex(tag, "/")+1:]
+	if !strings.Contains(name, ":") {
+		return tag + ":latest"
+	}
+
+	return tag
+}
+
 func (d *dockerImageLoadResource) parseLoadedImageFromOutput(output string) string {
 	// Parse docker load output to extract the loaded image reference
 	// For Docker SDK, the output may be in JSON streaming format: {"stream":"Loaded image: <image_reference>\n"}
@@ -403,6 +908,12 @@ func (d *dockerImageLoadResource) parseLoadedImageFromOutput(output string) stri
 		if strings.HasPrefix(line, "Loaded image ID: ") {
 			return strings.TrimPrefix(line, "Loaded image ID: ")
 		}
+
+		// podman before 4.0 lists the loaded images on a single line: "Loaded image(s): <image_reference>,..."
+		if strings.HasPrefix(line, "Loaded image(s): ") {
+			images, _, _ := strings.Cut(strings.TrimPrefix(line, "Loaded image(s): "), ",")
+			return images
+		}
 	}
 
 	// Fallback: try to extract any sha256 hash from the output
@@ -6,12 +6,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -127,6 +129,57 @@ func TestDockerImageLoadResource(t *testing.T) {
 		})
 	})
 
+	t.Run("Test docker image load with upload first", func(t *testing.T) {
+		setup := setupTestEnvironment(t)
+
+		tempDir, err := os.MkdirTemp("", "docker-test")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		tarFile := filepath.Join(tempDir, "test-image.tar")
+		if err := createTestDockerImageTar(tarFile); err != nil {
+			t.Fatalf("Failed to create test tar file: %v", err)
+		}
+
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testDockerSetupConfig(t) + fmt.Sprintf(`
+resource "setup_docker_image_load" "test" {
+  tar_file     = "%s"
+  upload_first = true
+  depends_on   = [setup_docker_setup.docker]
+}
+`, tarFile),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_docker_image_load.test", "upload_first", "true"),
+						resource.TestCheckResourceAttrSet("setup_docker_image_load.test", "image_sha"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							out, err := sshClient.RunCommand(context.Background(), "ls /var/tmp | grep -c setup-docker-image || true")
+							if err != nil {
+								return err
+							}
+
+							if strings.TrimSpace(out) != "0" {
+								return fmt.Errorf("uploaded tar file was not removed")
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+
 	t.Run("Test tar file content change detection", func(t *testing.T) {
 		setup := setupTestEnvironment(t)
 
@@ -233,8 +286,13 @@ func TestGetImageContentHashFromLocalTar(t *testing.T) {
 			t.Error("Expected non-empty content hash")
 		}
 
-		if !strings.HasSuffix(contentHash, ".json") {
-			t.Errorf("Expected content hash to end with '.json', got: %s", contentHash)
+		content, err := os.ReadFile(tarFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if contentHash != fmt.Sprintf("sha256:%x", sha256.Sum256(content)) {
+			t.Errorf("Expected content hash to be the sha256 of the tar file, got: %s", contentHash)
 		}
 	})
 
@@ -317,6 +375,42 @@ func TestGetImageContentHashFromLocalTar(t *testing.T) {
 		}
 	})
 
+	t.Run("should produce different content hashes when only a layer changed", func(t *testing.T) {
+		// Arrange
+		tempDir, err := os.MkdirTemp("", "tar-inspect-test")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		tarFile1 := filepath.Join(tempDir, "test-image1.tar")
+		tarFile2 := filepath.Join(tempDir, "test-image2.tar")
+
+		if err := createTestDockerImageTar(tarFile1); err != nil {
+			t.Fatalf("Failed to create test tar file: %v", err)
+		}
+
+		// Same manifest and config, with an extra entry next to the layers
+		if err := appendToTestDockerImageTar(tarFile1, tarFile2, "extra/layer.tar", "extra layer"); err != nil {
+			t.Fatalf("Failed to create second test tar file: %v", err)
+		}
+
+		resource := &dockerImageLoadResource{}
+
+		// Act
+		contentHash1, err1 := resource.getImageContentHashFromLocalTar(t.Context(), tarFile1)
+		contentHash2, err2 := resource.getImageContentHashFromLocalTar(t.Context(), tarFile2)
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("Expected no error, got: %v, %v", err1, err2)
+		}
+
+		if contentHash1 == contentHash2 {
+			t.Errorf("Expected different content hashes, but got same: %s", contentHash1)
+		}
+	})
+
 	t.Run("should return error for non-existent file", func(t *testing.T) {
 		// Arrange
 		resource := &dockerImageLoadResource{}
@@ -355,16 +449,229 @@ func TestGetImageContentHashFromLocalTar(t *testing.T) {
 	})
 }
 
+func TestDockerImageLoadResourceTags(t *testing.T) {
+	t.Run("Test tag the loaded image", func(t *testing.T) {
+		setup := setupTestEnvironment(t)
+
+		tarFile := filepath.Join(t.TempDir(), "test-image.tar")
+		if err := createTestDockerImageTar(tarFile); err != nil {
+			t.Fatalf("Failed to create test tar file: %v", err)
+		}
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := func(tags string) string {
+			return testProviderConfig(setup, "test", "localhost") + testDockerSetupConfig(t) + fmt.Sprintf(`
+resource "setup_docker_image_load" "test" {
+  tar_file   = "%s"
+  tags       = %s
+  depends_on = [setup_docker_setup.docker]
+}
+`, tarFile, tags)
+		}
+
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: config(`["myapp:deploy"]`),
+					Check: func(_ *terraform.State) error {
+						_, err := sshClient.RunCommand(context.Background(), "sudo docker inspect myapp:deploy")
+						if err != nil {
+							return fmt.Errorf("tag not found: %v", err)
+						}
+
+						return nil
+					},
+				},
+				{
+					Config: config(`["myapp:stable"]`),
+					Check: func(_ *terraform.State) error {
+						_, err := sshClient.RunCommand(context.Background(), "sudo docker inspect myapp:stable")
+						if err != nil {
+							return fmt.Errorf("tag not found: %v", err)
+						}
+
+						_, err = sshClient.RunCommand(context.Background(), "sudo docker inspect myapp:deploy")
+						if err == nil {
+							return fmt.Errorf("the removed tag still exists")
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestDockerImageLoadResourceModifyPlan(t *testing.T) {
+	tarFile := filepath.Join(t.TempDir(), "test-image.tar")
+	if err := createTestDockerImageTar(tarFile); err != nil {
+		t.Fatalf("Failed to create test tar file: %v", err)
+	}
+
+	contentHash, err := (&dockerImageLoadResource{}).getImageContentHashFromLocalTar(t.Context(), tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := dockerImageLoadResourceModel{
+		TarFile:     types.StringValue(tarFile),
+		ImageSHA:    types.StringUnknown(),
+		ContentHash: types.StringUnknown(),
+		UploadFirst: types.BoolValue(false),
+		Tags:        types.ListNull(types.StringType),
+		Runtime:     types.StringValue(dockerRuntimeDocker),
+	}
+
+	state := plan
+	state.ImageSHA = types.StringValue("sha256:loaded")
+	state.ContentHash = types.StringValue(contentHash)
+
+	t.Run("should keep the image of an unchanged archive", func(t *testing.T) {
+		// Act
+		modified, diags := testResourceModifyPlan(t, newDockerImageLoadResource(nil).(*dockerImageLoadResource), &state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if modified.ContentHash.ValueString() != contentHash || modified.ImageSHA.ValueString() != "sha256:loaded" {
+			t.Fatalf("unexpected plan: %v", modified)
+		}
+	})
+
+	t.Run("should plan a new image for a changed archive", func(t *testing.T) {
+		// Arrange
+		changed := state
+		changed.ContentHash = types.StringValue("sha256:previous")
+
+		// Act
+		modified, diags := testResourceModifyPlan(t, newDockerImageLoadResource(nil).(*dockerImageLoadResource), &changed, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if modified.ContentHash.ValueString() != contentHash || !modified.ImageSHA.IsUnknown() {
+			t.Fatalf("unexpected plan: %v", modified)
+		}
+	})
+
+	t.Run("should fail on a missing archive", func(t *testing.T) {
+		// Arrange
+		missing := plan
+		missing.TarFile = types.StringValue(filepath.Join(t.TempDir(), "missing.tar"))
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newDockerImageLoadResource(nil).(*dockerImageLoadResource), nil, missing)
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestImageTagHelpers(t *testing.T) {
+	t.Run("should default the tag to latest", func(t *testing.T) {
+		for tag, expected := range map[string]string{
+			"myapp":                       "myapp:latest",
+			"myapp:deploy":                "myapp:deploy",
+			"registry:5000/team/myapp":    "registry:5000/team/myapp:latest",
+			"registry:5000/team/myapp:v1": "registry:5000/team/myapp:v1",
+		} {
+			if normalized := normalizeImageTag(tag); normalized != expected {
+				t.Errorf("unexpected tag for %s: %s", tag, normalized)
+			}
+		}
+	})
+
+	t.Run("should return the removed tags", func(t *testing.T) {
+		// Act
+		removed := removedTags([]string{"a:1", "b:1", "c:1"}, []string{"b:1", "d:1"})
+
+		// Assert
+		if strings.Join(removed, ",") != "a:1,c:1" {
+			t.Fatalf("unexpected removed tags: %v", removed)
+		}
+	})
+}
+
+func TestReadLoadResponse(t *testing.T) {
+	t.Run("should return the stream messages", func(t *testing.T) {
+		// Arrange
+		body := `{"status":"Loading layer","id":"abc","progressDetail":{"current":512,"total":1024}}
+{"stream":"Loaded image: test:latest\n"}
+`
+
+		// Act
+		output, err := (&dockerImageLoadResource{}).readLoadResponse(t.Context(), strings.NewReader(body))
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "Loaded image: test:latest\n" {
+			t.Fatalf("unexpected output: %q", output)
+		}
+	})
+
+	t.Run("should return the error of the daemon", func(t *testing.T) {
+		// Arrange
+		body := `{"errorDetail":{"message":"invalid tar header"},"error":"invalid tar header"}`
+
+		// Act
+		_, err := (&dockerImageLoadResource{}).readLoadResponse(t.Context(), strings.NewReader(body))
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), "invalid tar header") {
+			t.Fatalf("expected the daemon error, got: %v", err)
+		}
+	})
+}
+
+func TestParseLoadedImageFromOutput(t *testing.T) {
+	for name, output := range map[string]string{
+		"docker":          "Loaded image: test:latest\n",
+		"podman":          "Getting image source signatures\nCopying blob 5f70bf18a086 done\nLoaded image: localhost/test:latest\n",
+		"podman 3":        "Loaded image(s): localhost/test:latest,localhost/test:v1\n",
+		"docker API JSON": `{"stream":"Loaded image: test:latest\n"}`,
+	} {
+		t.Run("should parse the output of "+name, func(t *testing.T) {
+			// Act
+			image := (&dockerImageLoadResource{}).parseLoadedImageFromOutput(output)
+
+			// Assert
+			if image != "test:latest" && image != "localhost/test:latest" {
+				t.Fatalf("unexpected image: %q", image)
+			}
+		})
+	}
+}
+
 func testDockerSetupConfig(t *testing.T) string {
 	t.Helper()
 
-	return ""
+	return `
+resource "setup_docker_setup" "docker" {
+  users = ["test"]
+}
+`
 }
 
 func testDockerImageLoadResourceConfig(tarFile string) string {
 	return fmt.Sprintf(`
 resource "setup_docker_image_load" "test" {
-  tar_file = "%s"
+  tar_file   = "%s"
+  depends_on = [setup_docker_setup.docker]
 }
 `, tarFile)
 }
@@ -476,3 +783,72 @@ func addFileToTar(tarWriter *tar.Writer, filename string, data []byte) error {
 
 	return nil
 }
+
+// appendToTestDockerImageTar copies the entries of the source tar file to the target one, followed by a new entry.
+func appendToTestDockerImageTar(source string, target string, name string, content string) error {
+	input, err := os.Open(filepath.Clean(source))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.Create(filepath.Clean(target))
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	tarReader := tar.NewReader(input)
+	tarWriter := tar.NewWriter(output)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0644}); err != nil {
+		return err
+	}
+
+	if _, err := tarWriter.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
+
+func TestDockerImageLoadResourceWithMock(t *testing.T) {
+	t.Run("read keeps the image when the daemon cannot be reached", func(t *testing.T) {
+		// Arrange
+		state := dockerImageLoadResourceModel{
+			TarFile:     types.StringValue("/tmp/image.tar"),
+			ImageSHA:    types.StringValue("sha256:loaded"),
+			ContentHash: types.StringValue("0123abcd"),
+			UploadFirst: types.BoolValue(false),
+			Tags:        types.ListNull(types.StringType),
+			Runtime:     types.StringValue(dockerRuntimeDocker),
+		}
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDockerImageLoadResource(newTestProvider(clients.NewMockMachineAccessClient())), state)
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the image, got: %v, %v", diags, removed)
+		}
+	})
+}
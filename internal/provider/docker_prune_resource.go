@@ -0,0 +1,418 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &dockerPruneResource{}
+var _ resource.ResourceWithModifyPlan = &dockerPruneResource{}
+
+const systemdUnitDirectory = "/etc/systemd/system"
+
+func newDockerPruneResource(p *internalProvider) resource.Resource {
+	return &dockerPruneResource{
+		provider: p,
+	}
+}
+
+// dockerPruneResource defines the resource implementation.
+type dockerPruneResource struct {
+	provider *internalProvider
+}
+
+type dockerPruneResourceModel struct {
+	Name            types.String `tfsdk:"name"`
+	Schedule        types.String `tfsdk:"schedule"`
+	RandomizedDelay types.String `tfsdk:"randomized_delay"`
+	All             types.Bool   `tfsdk:"all"`
+	Volumes         types.Bool   `tfsdk:"volumes"`
+	Until           types.String `tfsdk:"until"`
+	Filters         types.List   `tfsdk:"filters"`
+	Runtime         types.String `tfsdk:"runtime"`
+	ServiceContent  types.String `tfsdk:"service_content"`
+	TimerContent    types.String `tfsdk:"timer_content"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *dockerPruneResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_prune"
+}
+
+func (r *dockerPruneResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Docker prune resource that installs a systemd timer running docker system prune on a " +
+			"schedule, so that the images, containers, networks and build cache left by repeated deploys do not fill " +
+			"the disk. The schedule is checked with systemd-analyze calendar before the units are written in " +
+			systemdUnitDirectory + ". The runs missed while the machine was off happen at its next boot. Destroying " +
+			"the resource stops the timer and removes the units",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("docker-prune"),
+				Description: "The name of the service and of the timer units, without their extensions. Defaults to docker-prune",
+				Validators:  []validator.String{fileName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"schedule": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("daily"),
+				Description: "When the prune runs, as the OnCalendar expression of the timer, e.g. 'weekly' or 'Sun *-*-* 03:00:00'. Defaults to daily",
+				Validators:  []validator.String{notBlank(), singleLine()},
+			},
+			"randomized_delay": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("1h"),
+				Description: "The maximum random delay added to the schedule, so that the hosts do not all prune at the same time, e.g. '30m'. Defaults to 1h",
+				Validators:  []validator.String{duration()},
+			},
+			"all": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether all the images without containers are removed, instead of only the dangling ones, with --all. Defaults to false",
+			},
+			"volumes": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the anonymous volumes without containers are removed too, with --volumes. Defaults to false",
+			},
+			"until": schema.StringAttribute{
+				Optional:    true,
+				Description: "The retention: only the objects created longer ago than this duration are removed, e.g. '168h' to keep those of the last week",
+				Validators:  []validator.String{duration()},
+			},
+			"filters": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The other filters of the objects removed, passed with --filter, e.g. 'label!=keep'",
+				Validators:  []validator.List{listOf(notBlank()), listOf(singleLine())},
+			},
+			"runtime": dockerRuntimeAttribute(),
+			"service_content": schema.StringAttribute{
+				Computed:    true,
+				Description: "The content of the service unit. A change made outside of terraform is planned as an update",
+			},
+			"timer_content": schema.StringAttribute{
+				Computed:    true,
+				Description: "The content of the timer unit. A change made outside of terraform is planned as an update",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *dockerPruneResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan renders the units, so that a unit changed outside of terraform is planned as a change of its content.
+func (r *dockerPruneResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan dockerPruneResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	service, timer, diags := plan.renderUnits(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("service_content"), service)...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("timer_content"), timer)...)
+}
+
+func (r *dockerPruneResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan dockerPruneResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerPruneResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model dockerPruneResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	service, err := r.provider.machineAccessClient.ReadFile(ctx, model.servicePath(), true)
+	if err == nil {
+		model.ServiceContent = types.StringValue(service)
+
+		var timer string
+
+		timer, err = r.provider.machineAccessClient.ReadFile(ctx, model.timerPath(), true)
+		model.TimerContent = types.StringValue(timer)
+	}
+
+	if clients.IsFileNotFound(err) {
+		// A unit was removed outside of terraform, the timer must be installed again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read docker prune units", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerPruneResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan dockerPruneResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerPruneResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model dockerPruneResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The units may already be gone, the timer is stopped anyway
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("systemctl", "disable", "--now", model.Name.ValueString()+".timer")+" || true"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stop docker prune timer", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", model.servicePath(), model.timerPath())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove docker prune units", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl daemon-reload"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to reload systemd", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// apply checks the schedule, writes the units that changed, and restarts the timer when one of them changed. The
+// contents of the units are set in the plan.
+func (r *dockerPruneResource) apply(ctx context.Context, plan *dockerPruneResourceModel, diags *diag.Diagnostics) {
+	service, timer, d := plan.renderUnits(ctx)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	plan.ServiceContent = types.StringValue(service)
+	plan.TimerContent = types.StringValue(timer)
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("systemd-analyze", "calendar", plan.Schedule.ValueString()))
+	if err != nil {
+		diags.AddAttributeError(path.Root("schedule"), "Invalid schedule", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	changed := false
+
+	for _, unit := range [][2]string{{plan.servicePath(), service}, {plan.timerPath(), timer}} {
+		filePath, content := unit[0], unit[1]
+
+		previous, err := r.provider.machineAccessClient.ReadFile(ctx, filePath, true)
+		if err != nil && !clients.IsFileNotFound(err) {
+			diags.AddError("Failed to read docker prune unit", err.Error())
+			return
+		}
+
+		if err == nil && previous == content {
+			continue
+		}
+
+		if err := r.provider.machineAccessClient.WriteFile(ctx, filePath, "644", "0", "0", content); err != nil {
+			diags.AddError("Failed to write docker prune unit", err.Error())
+			return
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return
+	}
+
+	timerUnit := plan.Name.ValueString() + ".timer"
+	command := "systemctl daemon-reload && " + clients.ShellCommand("systemctl", "enable", timerUnit) + " && " + clients.ShellCommand("systemctl", "restart", timerUnit)
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+	if err != nil {
+		diags.AddError("Failed to start docker prune timer", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// servicePath returns the path of the service unit running the prune.
+func (model dockerPruneResourceModel) servicePath() string {
+	return systemdUnitDirectory + "/" + model.Name.ValueString() + ".service"
+}
+
+// timerPath returns the path of the timer unit starting the service.
+func (model dockerPruneResourceModel) timerPath() string {
+	return systemdUnitDirectory + "/" + model.Name.ValueString() + ".timer"
+}
+
+// renderUnits returns the contents of the service unit running the prune with the flags of the model, and of the
+// timer unit starting it on schedule.
+func (model dockerPruneResourceModel) renderUnits(ctx context.Context) (string, string, diag.Diagnostics) {
+	var filters []string
+
+	diags := model.Filters.ElementsAs(ctx, &filters, false)
+	if diags.HasError() {
+		return "", "", diags
+	}
+
+	command := dockerRuntimeCommand(model.Runtime)
+	args := []string{"/usr/bin/" + command, "system", "prune", "--force"}
+
+	if model.All.ValueBool() {
+		args = append(args, "--all")
+	}
+
+	if model.Volumes.ValueBool() {
+		args = append(args, "--volumes")
+	}
+
+	if !model.Until.IsNull() {
+		args = append(args, "--filter", "until="+model.Until.ValueString())
+	}
+
+	for _, filter := range filters {
+		args = append(args, "--filter", filter)
+	}
+
+	for i, arg := range args {
+		args[i] = systemdQuote(arg)
+	}
+
+	lines := []string{"[Unit]", "Description=Remove the unused " + command + " objects"}
+
+	// podman has no daemon, the objects of docker are removed by dockerd
+	if command == dockerRuntimeDocker {
+		lines = append(lines, "Requires=docker.service", "After=docker.service")
+	}
+
+	service := joinLines(append(lines, "", "[Service]", "Type=oneshot", "ExecStart="+strings.Join(args, " ")))
+
+	delay, err := time.ParseDuration(model.RandomizedDelay.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root("randomized_delay"), "Invalid randomized delay", err.Error())
+		return "", "", diags
+	}
+
+	timer := joinLines([]string{
+		"[Unit]",
+		"Description=Remove the unused " + command + " objects on schedule",
+		"",
+		"[Timer]",
+		"OnCalendar=" + model.Schedule.ValueString(),
+		fmt.Sprintf("RandomizedDelaySec=%d", int64(delay.Seconds())),
+		"Persistent=true",
+		"",
+		"[Install]",
+		"WantedBy=timers.target",
+	})
+
+	return service, timer, diags
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestDockerPruneResourceWithMock(t *testing.T) {
+	t.Run("create installs the timer running the prune with its filters", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		model := testDockerPruneModel()
+		model.All = types.BoolValue(true)
+		model.Until = types.StringValue("168h")
+		model.Filters = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("label!=keep")})
+
+		// Act
+		state, diags := testResourceCreate(t, newDockerPruneResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		service := mock.Files["/etc/systemd/system/docker-prune.service"]
+		if !strings.Contains(service, "ExecStart=/usr/bin/docker system prune --force --all --filter until=168h --filter label!=keep\n") {
+			t.Fatalf("unexpected service:\n%s", service)
+		}
+
+		timer := mock.Files["/etc/systemd/system/docker-prune.timer"]
+		if !strings.Contains(timer, "OnCalendar=daily\nRandomizedDelaySec=3600\nPersistent=true\n") {
+			t.Fatalf("unexpected timer:\n%s", timer)
+		}
+
+		if !testDockerPruneCommandRan(mock, "systemd-analyze calendar daily") || !testDockerPruneCommandRan(mock, "systemctl enable docker-prune.timer && systemctl restart docker-prune.timer") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if state.ServiceContent.ValueString() != service || state.TimerContent.ValueString() != timer {
+			t.Fatal("expected the contents of the units in the state")
+		}
+	})
+
+	t.Run("create refuses a schedule that systemd does not parse", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^systemd-analyze calendar `, clients.MockResponse{Stderr: "Failed to parse calendar specification 'every day'", ExitCode: 1})
+
+		model := testDockerPruneModel()
+		model.Schedule = types.StringValue("every day")
+
+		// Act
+		_, diags := testResourceCreate(t, newDockerPruneResource(newTestProvider(mock)), model)
+
+		// Assert
+		if !diags.HasError() || len(mock.Files) != 0 {
+			t.Fatalf("expected the schedule to be refused before writing the units, got: %v", diags)
+		}
+	})
+
+	t.Run("update does not restart the timer of unchanged units", func(t *testing.T) {
+		// Arrange
+		model := testDockerPruneModel()
+		service, timer, _ := model.renderUnits(t.Context())
+
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/systemd/system/docker-prune.service", service, clients.FileInfo{Mode: "644"}).
+			WithFile("/etc/systemd/system/docker-prune.timer", timer, clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceUpdate(t, newDockerPruneResource(newTestProvider(mock)), model, model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if testDockerPruneCommandRan(mock, "systemctl") {
+			t.Fatalf("unexpected restart: %v", mock.Commands)
+		}
+	})
+
+	t.Run("the units of podman do not depend on dockerd", func(t *testing.T) {
+		// Arrange
+		model := testDockerPruneModel()
+		model.Runtime = types.StringValue("podman")
+
+		// Act
+		service, _, diags := model.renderUnits(t.Context())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if strings.Contains(service, "docker.service") || !strings.Contains(service, "ExecStart=/usr/bin/podman system prune --force\n") {
+			t.Fatalf("unexpected service:\n%s", service)
+		}
+	})
+}
+
+func testDockerPruneModel() dockerPruneResourceModel {
+	return dockerPruneResourceModel{
+		Name:            types.StringValue("docker-prune"),
+		Schedule:        types.StringValue("daily"),
+		RandomizedDelay: types.StringValue("1h"),
+		All:             types.BoolValue(false),
+		Volumes:         types.BoolValue(false),
+		Until:           types.StringNull(),
+		Filters:         types.ListNull(types.StringType),
+		Runtime:         types.StringValue("docker"),
+		ServiceContent:  types.StringUnknown(),
+		TimerContent:    types.StringUnknown(),
+	}
+}
+
+func testDockerPruneCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"terraform-provider-setup/internal/provider/clients"
+
+	datasourceschema "github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// The runtimes serving the Docker API of the machine. podman serves a Docker compatible API on its own socket, which
+// lets the Docker resources manage its images and containers on the hosts where Docker is not allowed, e.g. RHEL.
+const (
+	dockerRuntimeDocker = "docker"
+	dockerRuntimePodman = "podman"
+)
+
+const dockerRuntimeAttributeDescription = "The container runtime whose Docker API is used, docker or podman. podman is reached on " +
+	clients.PodmanSocket + ", served once podman.socket is enabled, e.g. with systemctl enable --now podman.socket. Defaults to docker"
+
+// dockerRuntimeAttribute is the runtime attribute of the resources using the Docker API.
+func dockerRuntimeAttribute() schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional:    true,
+		Computed:    true,
+		Default:     stringdefault.StaticString(dockerRuntimeDocker),
+		Description: dockerRuntimeAttributeDescription,
+		Validators:  []validator.String{oneOf(dockerRuntimeDocker, dockerRuntimePodman)},
+		PlanModifiers: []planmodifier.String{
+			stringplanmodifier.RequiresReplace(),
+		},
+	}
+}
+
+// dockerRuntimeDataSourceAttribute is the runtime attribute of the data sources using the Docker API.
+func dockerRuntimeDataSourceAttribute() datasourceschema.StringAttribute {
+	return datasourceschema.StringAttribute{
+		Optional:    true,
+		Description: dockerRuntimeAttributeDescription,
+		Validators:  []validator.String{oneOf(dockerRuntimeDocker, dockerRuntimePodman)},
+	}
+}
+
+// onRuntime selects the socket of the runtime attribute of the resource or data source for the Docker clients created
+// with the returned context.
+func (p *internalProvider) onRuntime(ctx context.Context, source hostAttributeSource) context.Context {
+	var runtime types.String
+
+	if diags := source.GetAttribute(ctx, path.Root("runtime"), &runtime); diags.HasError() || runtime.ValueString() != dockerRuntimePodman {
+		return ctx
+	}
+
+	return clients.WithDockerSocket(ctx, clients.PodmanSocket)
+}
+
+// dockerRuntimeCommand returns the command line interface of the runtime, docker when the runtime is null.
+func dockerRuntimeCommand(runtime types.String) string {
+	if runtime.ValueString() == dockerRuntimePodman {
+		return dockerRuntimePodman
+	}
+
+	return dockerRuntimeDocker
+}
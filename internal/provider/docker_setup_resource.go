@@ -0,0 +1,515 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &dockerSetupResource{}
+
+// dockerSetupKeyring is the keyring of the key signing the packages of the Docker repository.
+const dockerSetupKeyring = "/etc/apt/keyrings/docker.asc"
+
+// dockerSetupSourcesList is the sources list of the Docker repository.
+const dockerSetupSourcesList = "/etc/apt/sources.list.d/docker.list"
+
+// dockerSetupProxyDropIn is the systemd drop-in passing the proxy settings of the provider to the Docker daemon, which
+// pulls the images.
+const dockerSetupProxyDropIn = "/etc/systemd/system/docker.service.d/http-proxy.conf"
+
+func newDockerSetupResource(p *internalProvider) resource.Resource {
+	return &dockerSetupResource{
+		provider: p,
+	}
+}
+
+// dockerSetupResource defines the resource implementation.
+type dockerSetupResource struct {
+	provider *internalProvider
+}
+
+type dockerSetupResourceModel struct {
+	Users    types.List     `tfsdk:"users"`
+	Packages types.List     `tfsdk:"packages"`
+	Channel  types.String   `tfsdk:"channel"`
+	Version  types.String   `tfsdk:"version"`
+	Timeouts *timeoutsModel `tfsdk:"timeouts"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *dockerSetupResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_setup"
+}
+
+func (r *dockerSetupResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Docker setup resource that installs Docker CE from the official Docker apt repository on " +
+			"Debian and Ubuntu, enables and starts the docker service, and adds users to the docker group. Destroying " +
+			"the resource removes the users from the docker group, while Docker, its images and its containers are kept",
+
+		Attributes: map[string]schema.Attribute{
+			"users": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The users added to the docker group, which lets them use docker without sudo",
+			},
+			"packages": schema.ListAttribute{
+				Optional:    true,
+				Computed:    true,
+				ElementType: types.StringType,
+				Default: listdefault.StaticValue(types.ListValueMust(types.StringType, []attr.Value{
+					types.StringValue("docker-ce"),
+					types.StringValue("docker-ce-cli"),
+					types.StringValue("containerd.io"),
+					types.StringValue("docker-buildx-plugin"),
+					types.StringValue("docker-compose-plugin"),
+				})),
+				Description: "The packages installed from the Docker repository. Defaults to docker-ce, docker-ce-cli, containerd.io, docker-buildx-plugin and docker-compose-plugin",
+			},
+			"channel": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("stable"),
+				Description: "The channel of the Docker repository, stable or test. Defaults to stable",
+			},
+			"version": schema.StringAttribute{
+				Computed:    true,
+				Description: "The installed version of the docker-ce package",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+		},
+	}
+}
+
+func (r *dockerSetupResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *dockerSetupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan dockerSetupResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	// The daemon configuration restarts docker, which must not happen while it is installed
+	defer r.provider.lock(ctx, dockerLock)()
+
+	var packages []string
+
+	diags = plan.Packages.ElementsAs(ctx, &packages, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err = r.addRepository(ctx, plan.Channel.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to add the Docker repository", err.Error())
+		return
+	}
+
+	err = r.install(ctx, packages)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install Docker", err.Error())
+		return
+	}
+
+	start := "systemctl enable --now docker"
+
+	if r.provider.proxy.enabled() {
+		err = r.configureProxy(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to configure the proxy of docker", err.Error())
+			return
+		}
+
+		// The daemon may already run without the proxy, as the packages start it when they are installed
+		start = "systemctl daemon-reload && systemctl enable docker && systemctl restart docker"
+	}
+
+	// Without systemd, as in containers, the service is started by its init script
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("if [ -d /run/systemd/system ]; then "+start+"; else service docker start; fi"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to start docker", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+
+	var users []string
+
+	diags = plan.Users.ElementsAs(ctx, &users, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err = r.updateUsers(ctx, users, nil)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to add users to the docker group", err.Error())
+		return
+	}
+
+	plan.Version, err = r.installedVersion(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the installed Docker version", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerSetupResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model dockerSetupResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// dpkg-query exits with 1 when the package is not installed, the other errors are failures to read it
+	version, err := r.installedVersion(ctx)
+	if clients.IsExitCode(err, 1) {
+		// Docker was removed outside of terraform, it must be installed again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the Docker version", err.Error())
+		return
+	}
+
+	model.Version = version
+
+	if !model.Users.IsNull() {
+		members, err := r.groupMembers(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the docker group", err.Error())
+			return
+		}
+
+		var users []string
+
+		diags = model.Users.ElementsAs(ctx, &users, false)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+
+		// The users removed from the group outside of terraform are dropped, so that they are added again
+		users = slices.DeleteFunc(users, func(user string) bool {
+			return !slices.Contains(members, user)
+		})
+
+		model.Users, diags = types.ListValueFrom(ctx, types.StringType, users)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerSetupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan dockerSetupResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state dockerSetupResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	defer r.provider.lock(ctx, dockerLock)()
+
+	if !plan.Channel.Equal(state.Channel) {
+		err = r.addRepository(ctx, plan.Channel.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update the Docker repository", err.Error())
+			return
+		}
+	}
+
+	var packages, previousPackages []string
+
+	resp.Diagnostics.Append(plan.Packages.ElementsAs(ctx, &packages, false)...)
+	resp.Diagnostics.Append(state.Packages.ElementsAs(ctx, &previousPackages, false)...)
+
+	var users, previousUsers []string
+
+	resp.Diagnostics.Append(plan.Users.ElementsAs(ctx, &users, false)...)
+	resp.Diagnostics.Append(state.Users.ElementsAs(ctx, &previousUsers, false)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Packages that are not listed anymore are kept installed, as other software may need them
+	added := slices.DeleteFunc(slices.Clone(packages), func(pkg string) bool {
+		return slices.Contains(previousPackages, pkg)
+	})
+
+	err = r.install(ctx, added)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install Docker packages", err.Error())
+		return
+	}
+
+	err = r.updateUsers(ctx, users, previousUsers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update the users of the docker group", err.Error())
+		return
+	}
+
+	plan.Version, err = r.installedVersion(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the installed Docker version", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *dockerSetupResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model dockerSetupResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var users []string
+
+	diags = model.Users.ElementsAs(ctx, &users, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.updateUsers(ctx, nil, users)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove users from the docker group", err.Error())
+		return
+	}
+}
+
+// addRepository adds the Docker apt repository of the distribution of the machine, signed by the Docker key.
+func (r *dockerSetupResource) addRepository(ctx context.Context, channel string) error {
+	distribution, err := r.provider.machineAccessClient.RunCommand(ctx, `. /etc/os-release && echo "$ID"`)
+	if err != nil {
+		return fmt.Errorf("failed to get the distribution. Err=%w\nout = %s", err, distribution)
+	}
+
+	distribution = strings.TrimSpace(distribution)
+	if distribution != "ubuntu" && distribution != "debian" {
+		return fmt.Errorf("the distribution %s is not supported, only debian and ubuntu are", distribution)
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		return fmt.Errorf("failed to configure the apt proxy: %w", err)
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(
+		"apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y ca-certificates curl && "+
+			"install -m 0755 -d /etc/apt/keyrings && "+
+			"curl -fsSL https://download.docker.com/linux/"+distribution+"/gpg -o "+dockerSetupKeyring+" && "+
+			"chmod a+r "+dockerSetupKeyring)))
+	if err != nil {
+		return fmt.Errorf("failed to download the Docker key. Err=%w\nout = %s", err, out)
+	}
+
+	arch, err := r.provider.machineAccessClient.RunCommand(ctx, "dpkg --print-architecture")
+	if err != nil {
+		return fmt.Errorf("failed to get the architecture. Err=%w\nout = %s", err, arch)
+	}
+
+	codename, err := r.provider.machineAccessClient.RunCommand(ctx, `. /etc/os-release && echo "${UBUNTU_CODENAME:-$VERSION_CODENAME}"`)
+	if err != nil {
+		return fmt.Errorf("failed to get the distribution codename. Err=%w\nout = %s", err, codename)
+	}
+
+	sources := "deb [arch=" + strings.TrimSpace(arch) + " signed-by=" + dockerSetupKeyring + "] https://download.docker.com/linux/" +
+		distribution + " " + strings.TrimSpace(codename) + " " + channel + "\n"
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, dockerSetupSourcesList, "0644", "0", "0", sources)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", dockerSetupSourcesList, err)
+	}
+
+	return nil
+}
+
+// configureProxy writes the systemd drop-in passing the proxy settings of the provider to the Docker daemon.
+func (r *dockerSetupResource) configureProxy(ctx context.Context) error {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("install", "-d", "-m", "0755", path.Dir(dockerSetupProxyDropIn))))
+	if err != nil {
+		return fmt.Errorf("failed to create the directory of %s. Err=%w\nout = %s", dockerSetupProxyDropIn, err, out)
+	}
+
+	lines := []string{"[Service]"}
+	for _, variable := range r.provider.proxy.variables() {
+		lines = append(lines, "Environment="+strconv.Quote(variable[0]+"="+variable[1]))
+	}
+
+	return r.provider.machineAccessClient.WriteFile(ctx, dockerSetupProxyDropIn, "0644", "0", "0", joinLines(lines))
+}
+
+// install installs the packages from the freshly updated package lists.
+func (r *dockerSetupResource) install(ctx context.Context, packages []string) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	quoted := []string{}
+	for _, pkg := range packages {
+		quoted = append(quoted, clients.ShellQuote(pkg))
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		return fmt.Errorf("failed to configure the apt proxy: %w", err)
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy("apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y "+strings.Join(quoted, " "))))
+	if err != nil {
+		return fmt.Errorf("failed to install %s. Err=%w\nout = %s", strings.Join(packages, ", "), err, out)
+	}
+
+	return nil
+}
+
+// updateUsers adds the users to the docker group, and removes the previous users that are not listed anymore.
+func (r *dockerSetupResource) updateUsers(ctx context.Context, users []string, previousUsers []string) error {
+	for _, user := range previousUsers {
+		if slices.Contains(users, user) {
+			continue
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("gpasswd -d "+clients.ShellQuote(user)+" docker"))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s from the docker group. Err=%w\nout = %s", user, err, out)
+		}
+	}
+
+	for _, user := range users {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("usermod -aG docker "+clients.ShellQuote(user)))
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the docker group. Err=%w\nout = %s", user, err, out)
+		}
+	}
+
+	return nil
+}
+
+// groupMembers returns the members of the docker group.
+func (r *dockerSetupResource) groupMembers(ctx context.Context) ([]string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "getent group docker")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the docker group. Err=%w\nout = %s", err, out)
+	}
+
+	// docker:x:999:alice,bob
+	parts := strings.Split(strings.TrimSpace(out), ":")
+	if len(parts) < 4 || parts[3] == "" {
+		return []string{}, nil
+	}
+
+	return strings.Split(parts[3], ","), nil
+}
+
+// installedVersion returns the version of the installed docker-ce package.
+func (r *dockerSetupResource) installedVersion(ctx context.Context) (types.String, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "dpkg-query -W -f='${Version}' docker-ce")
+	if err != nil {
+		return types.StringNull(), fmt.Errorf("docker-ce is not installed. Err=%w\nout = %s", err, out)
+	}
+
+	return types.StringValue(strings.TrimSpace(out)), nil
+}
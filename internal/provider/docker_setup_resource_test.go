@@ -0,0 +1,237 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestDockerSetupResource(t *testing.T) {
+	t.Run("Test install docker", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testDockerSetupConfig(t),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttrSet("setup_docker_setup.docker", "version"),
+						func(_ *terraform.State) error {
+							groups, err := sshClient.RunCommand(context.Background(), "id -nG test")
+							if err != nil {
+								return err
+							}
+
+							if !slices.Contains(strings.Fields(groups), "docker") {
+								return fmt.Errorf("test is not in the docker group: %s", groups)
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func TestDockerSetupResourceWithMock(t *testing.T) {
+	packages := types.ListValueMust(types.StringType, []attr.Value{types.StringValue("docker-ce")})
+
+	t.Run("create adds the repository, installs docker and adds the users", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(`. /etc/os-release && echo "$ID"`, clients.MockResponse{Stdout: "ubuntu\n"}).
+			On("dpkg --print-architecture", clients.MockResponse{Stdout: "amd64\n"}).
+			On(`. /etc/os-release && echo "${UBUNTU_CODENAME:-$VERSION_CODENAME}"`, clients.MockResponse{Stdout: "noble\n"}).
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "5:28.0.1-1~ubuntu.24.04~noble"})
+
+		// Act
+		state, diags := testResourceCreate(t, newDockerSetupResource(newTestProvider(mock)), dockerSetupResourceModel{
+			Users:    types.ListValueMust(types.StringType, []attr.Value{types.StringValue("alice")}),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[dockerSetupSourcesList] != "deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable\n" {
+			t.Fatalf("unexpected sources: %q", mock.Files[dockerSetupSourcesList])
+		}
+
+		commands := strings.Join(mock.Commands, "\n")
+		for _, expected := range []string{"https://download.docker.com/linux/ubuntu/gpg", "apt-get install -y 'docker-ce'", "systemctl enable --now docker", "usermod -aG docker 'alice'"} {
+			if !strings.Contains(commands, expected) {
+				t.Fatalf("expected %s in commands: %v", expected, mock.Commands)
+			}
+		}
+
+		if state.Version.ValueString() != "5:28.0.1-1~ubuntu.24.04~noble" {
+			t.Fatalf("unexpected version: %s", state.Version)
+		}
+	})
+
+	t.Run("create passes the proxy to apt and the docker daemon", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(`. /etc/os-release && echo "$ID"`, clients.MockResponse{Stdout: "debian\n"}).
+			On("dpkg --print-architecture", clients.MockResponse{Stdout: "amd64\n"}).
+			On(`. /etc/os-release && echo "${UBUNTU_CODENAME:-$VERSION_CODENAME}"`, clients.MockResponse{Stdout: "bookworm\n"}).
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "5:28.0.1"})
+
+		p := newTestProvider(mock)
+		p.proxy = proxySettings{HTTP: "http://proxy:3128", HTTPS: "http://proxy:3128"}
+
+		// Act
+		_, diags := testResourceCreate(t, newDockerSetupResource(p), dockerSetupResourceModel{
+			Users:    types.ListNull(types.StringType),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !strings.Contains(mock.Files[aptProxyConfigPath], `Acquire::https::Proxy "http://proxy:3128";`) {
+			t.Fatalf("unexpected apt proxy configuration: %q", mock.Files[aptProxyConfigPath])
+		}
+
+		if !strings.Contains(mock.Files[dockerSetupProxyDropIn], `Environment="HTTPS_PROXY=http://proxy:3128"`) {
+			t.Fatalf("unexpected drop-in: %q", mock.Files[dockerSetupProxyDropIn])
+		}
+
+		commands := strings.Join(mock.Commands, "\n")
+		for _, expected := range []string{"export http_proxy='http://proxy:3128'", "systemctl restart docker"} {
+			if !strings.Contains(commands, expected) {
+				t.Fatalf("expected %s in commands: %v", expected, mock.Commands)
+			}
+		}
+	})
+
+	t.Run("create fails on an unsupported distribution", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(`. /etc/os-release && echo "$ID"`, clients.MockResponse{Stdout: "fedora\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newDockerSetupResource(newTestProvider(mock)), dockerSetupResourceModel{
+			Users:    types.ListNull(types.StringType),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringUnknown(),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("read drops the users removed from the docker group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query`, clients.MockResponse{Stdout: "5:28.0.1"}).
+			On("getent group docker", clients.MockResponse{Stdout: "docker:x:999:bob\n"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newDockerSetupResource(newTestProvider(mock)), dockerSetupResourceModel{
+			Users:    types.ListValueMust(types.StringType, []attr.Value{types.StringValue("alice"), types.StringValue("bob")}),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringValue("5:27.0.0"),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: %v, %v", diags, removed)
+		}
+
+		if len(state.Users.Elements()) != 1 || state.Users.Elements()[0].String() != `"bob"` || state.Version.ValueString() != "5:28.0.1" {
+			t.Fatalf("unexpected state: %v", state)
+		}
+	})
+
+	t.Run("read removes the resource when docker is not installed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query`, clients.MockResponse{ExitCode: 1, Stderr: "dpkg-query: no packages found matching docker-ce"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDockerSetupResource(newTestProvider(mock)), dockerSetupResourceModel{
+			Users:    types.ListNull(types.StringType),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringValue("5:28.0.1"),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed: %v", diags)
+		}
+	})
+
+	t.Run("read keeps the resource when the version cannot be read", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query`, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDockerSetupResource(newTestProvider(mock)), dockerSetupResourceModel{
+			Users:    types.ListNull(types.StringType),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringValue("5:28.0.1"),
+		})
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+
+	t.Run("delete removes the users from the docker group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newDockerSetupResource(newTestProvider(mock)), dockerSetupResourceModel{
+			Users:    types.ListValueMust(types.StringType, []attr.Value{types.StringValue("alice")}),
+			Packages: packages,
+			Channel:  types.StringValue("stable"),
+			Version:  types.StringValue("5:28.0.1"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 || !strings.Contains(mock.Commands[0], "gpasswd -d 'alice' docker") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
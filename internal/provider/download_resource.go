@@ -0,0 +1,357 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &downloadResource{}
+var _ resource.ResourceWithImportState = &downloadResource{}
+
+func newDownloadResource(p *internalProvider) resource.Resource {
+	return &downloadResource{
+		provider: p,
+	}
+}
+
+// downloadResource defines the resource implementation.
+type downloadResource struct {
+	provider *internalProvider
+}
+
+type downloadResourceModel struct {
+	URL         types.String   `tfsdk:"url"`
+	Destination types.String   `tfsdk:"destination"`
+	SHA256      types.String   `tfsdk:"sha256"`
+	Owner       types.String   `tfsdk:"owner"`
+	Group       types.String   `tfsdk:"group"`
+	Mode        types.String   `tfsdk:"mode"`
+	Timeouts    *timeoutsModel `tfsdk:"timeouts"`
+	Retry       *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *downloadResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_download"
+}
+
+func (r *downloadResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Download resource that fetches a URL directly on the remote machine (using curl or wget) and verifies its sha256 checksum",
+
+		Attributes: map[string]schema.Attribute{
+			"url": schema.StringAttribute{
+				Required:    true,
+				Description: "The URL to download",
+			},
+			"destination": schema.StringAttribute{
+				Required:    true,
+				Description: "The path where the downloaded file is stored on the remote machine",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"sha256": schema.StringAttribute{
+				Required:    true,
+				Description: "The expected sha256 checksum of the downloaded file. The file is downloaded again when the checksum of the remote file does not match",
+				Validators:  []validator.String{sha256Checksum()},
+			},
+			"owner": schema.StringAttribute{
+				Optional:    true,
+				Description: "The owner (user name or uid) of the downloaded file. If not specified, the owner is left unchanged",
+			},
+			"group": schema.StringAttribute{
+				Optional:    true,
+				Description: "The group (group name or gid) of the downloaded file. If not specified, the group is left unchanged",
+			},
+			"mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("0644"),
+				Description: "The mode of the downloaded file in octal format. Defaults to '0644'",
+				Validators:  []validator.String{octalMode()},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *downloadResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *downloadResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan downloadResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	err = r.download(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to download file", err.Error())
+		return
+	}
+
+	err = r.setAttributes(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set file attributes", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *downloadResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model downloadResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// test exits with 1 when the file does not exist, the other errors are failures to check it
+	_, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("test", "-f", model.Destination.ValueString())))
+	if clients.IsExitCode(err, 1) {
+		// File doesn't exist anymore, remove from state
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to check the destination", err.Error())
+		return
+	}
+
+	checksum, err := r.checksum(ctx, model.Destination.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to compute checksum", err.Error())
+		return
+	}
+
+	// Storing the actual checksum makes a mismatch show up as a diff on sha256, which triggers a new download on update.
+	// The checksums are compared ignoring case, a matching checksum is kept as configured
+	if !strings.EqualFold(checksum, model.SHA256.ValueString()) {
+		model.SHA256 = types.StringValue(checksum)
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *downloadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan downloadResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state downloadResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	if !plan.URL.Equal(state.URL) || !strings.EqualFold(plan.SHA256.ValueString(), state.SHA256.ValueString()) {
+		err = r.download(ctx, plan)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to download file", err.Error())
+			return
+		}
+	}
+
+	err = r.setAttributes(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set file attributes", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *downloadResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model downloadResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	_, err = r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", model.Destination.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to delete downloaded file", err.Error())
+		return
+	}
+}
+
+func (r *downloadResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("destination"), req, resp)
+}
+
+// download fetches the url into a remote temporary file, verifies its checksum and moves it to its destination.
+func (r *downloadResource) download(ctx context.Context, model downloadResourceModel) error {
+	tmpFile, err := r.provider.machineAccessClient.RunCommand(ctx, "mktemp")
+	if err != nil {
+		return fmt.Errorf("failed to create remote temp file: %w", err)
+	}
+
+	tmpFile = strings.TrimSpace(tmpFile)
+
+	// A dry run creates no temporary file, the commands are logged with the template of mktemp
+	if r.provider.dryRun {
+		tmpFile = "/tmp/tmp.XXXXXXXXXX"
+	}
+
+	tflog.Debug(ctx, "Downloading "+model.URL.ValueString()+" to "+tmpFile)
+
+	out, err := retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(ctx, r.provider.withProxy(fetchCommand(model.URL.ValueString(), tmpFile)))
+	})
+	if err != nil {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-f", tmpFile))
+		return fmt.Errorf("failed to download %s. Err=%w\nout = %s", model.URL.ValueString(), err, out)
+	}
+
+	// Nothing was downloaded in a dry run, there is no checksum to verify
+	checksum := model.SHA256.ValueString()
+
+	if !r.provider.dryRun {
+		checksum, err = r.checksum(ctx, tmpFile)
+		if err != nil {
+			_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-f", tmpFile))
+			return err
+		}
+	}
+
+	if !strings.EqualFold(checksum, model.SHA256.ValueString()) {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-f", tmpFile))
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", model.URL.ValueString(), model.SHA256.ValueString(), checksum)
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("mv", tmpFile, model.Destination.ValueString())))
+	if err != nil {
+		return fmt.Errorf("failed to move downloaded file to %s. Err=%w\nout = %s", model.Destination.ValueString(), err, out)
+	}
+
+	return nil
+}
+
+// fetchCommand returns the command downloading the url to the destination with curl, or with wget when curl is not
+// installed.
+func fetchCommand(url string, destination string) string {
+	return "if command -v curl > /dev/null 2>&1; then " + clients.ShellCommand("curl", "-fsSL", "-o", destination, url) +
+		"; else " + clients.ShellCommand("wget", "-q", "-O", destination, url) + "; fi"
+}
+
+func (r *downloadResource) setAttributes(ctx context.Context, model downloadResourceModel) error {
+	owner := model.Owner.ValueString()
+	if !model.Group.IsNull() && model.Group.ValueString() != "" {
+		owner += ":" + model.Group.ValueString()
+	}
+
+	if owner != "" {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("chown", owner, model.Destination.ValueString())))
+		if err != nil {
+			return fmt.Errorf("failed to set owner and group. Err=%w\nout = %s", err, out)
+		}
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("chmod", model.Mode.ValueString(), model.Destination.ValueString())))
+	if err != nil {
+		return fmt.Errorf("failed to set mode. Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+func (r *downloadResource) checksum(ctx context.Context, filePath string) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sha256sum", filePath)))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute sha256 of %s. Err=%w\nout = %s", filePath, err, out)
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected sha256sum output: %s", out)
+	}
+
+	return fields[0], nil
+}
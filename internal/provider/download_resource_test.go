@@ -0,0 +1,212 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestDownloadResource(t *testing.T) {
+	const helloSHA256 = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+	const worldSHA256 = "e258d248fda94c63753607f7c4494ee0fcbe92f1a76bfdac795c9d84101eb317"
+
+	// Arrange - set up a single Docker container for all test cases
+	setup := setupTestEnvironment(t)
+
+	sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sshClient.RunCommand(context.Background(), "echo hello > /tmp/download_hello.txt && echo world > /tmp/download_world.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Test create, external change, update and removed", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testDownloadResourceConfig("file:///tmp/download_hello.txt", "/tmp/downloaded.txt", helloSHA256),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_download.test", "destination", "/tmp/downloaded.txt"),
+						resource.TestCheckResourceAttr("setup_download.test", "sha256", helloSHA256),
+						resource.TestCheckResourceAttr("setup_download.test", "mode", "0755"),
+						func(_ *terraform.State) error {
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/downloaded.txt")
+							if err != nil {
+								return err
+							}
+
+							if content != "hello\n" {
+								return fmt.Errorf("unexpected content: %s", content)
+							}
+
+							stat, err := sshClient.RunCommand(context.Background(), "stat -c '%U %G %a' /tmp/downloaded.txt")
+							if err != nil {
+								return err
+							}
+
+							if stat != "root root 755\n" {
+								return fmt.Errorf("unexpected stat: %s", stat)
+							}
+
+							return nil
+						},
+					),
+				},
+				{
+					PreConfig: func() {
+						// Corrupt the downloaded file so that it gets downloaded again
+						_, err := sshClient.RunCommand(context.Background(), "echo corrupted | sudo tee /tmp/downloaded.txt")
+						if err != nil {
+							t.Fatal(err)
+						}
+					},
+					Config: testProviderConfig(setup, "test", "localhost") + testDownloadResourceConfig("file:///tmp/download_hello.txt", "/tmp/downloaded.txt", helloSHA256),
+					Check: resource.ComposeTestCheckFunc(
+						func(_ *terraform.State) error {
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/downloaded.txt")
+							if err != nil {
+								return err
+							}
+
+							if content != "hello\n" {
+								return fmt.Errorf("file was not downloaded again: %s", content)
+							}
+
+							return nil
+						},
+					),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testDownloadResourceConfig("file:///tmp/download_world.txt", "/tmp/downloaded.txt", worldSHA256),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_download.test", "sha256", worldSHA256),
+						func(_ *terraform.State) error {
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/downloaded.txt")
+							if err != nil {
+								return err
+							}
+
+							if content != "world\n" {
+								return fmt.Errorf("unexpected content: %s", content)
+							}
+
+							return nil
+						},
+					),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost"),
+					Check: resource.ComposeTestCheckFunc(
+						func(_ *terraform.State) error {
+							_, err := sshClient.RunCommand(context.Background(), "ls /tmp/downloaded.txt")
+							if err == nil {
+								return fmt.Errorf("file was not deleted")
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+
+	t.Run("Test checksum mismatch", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config:      testProviderConfig(setup, "test", "localhost") + testDownloadResourceConfig("file:///tmp/download_hello.txt", "/tmp/mismatch.txt", worldSHA256),
+					ExpectError: regexp.MustCompile("checksum mismatch"),
+				},
+			},
+		})
+	})
+}
+
+func testDownloadResourceConfig(url string, destination string, sha256 string) string {
+	return fmt.Sprintf(`
+resource "setup_download" "test" {
+	url         = "%s"
+	destination = "%s"
+	sha256      = "%s"
+	owner       = "root"
+	group       = "root"
+	mode        = "0755"
+}
+`, url, destination, sha256)
+}
+
+func TestDownloadResourceWithMock(t *testing.T) {
+	t.Run("read removes the resource when the file is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^test -f `, clients.MockResponse{ExitCode: 1})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDownloadResource(newTestProvider(mock)), testDownloadModel())
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed, got: %v", diags)
+		}
+	})
+
+	t.Run("read keeps an uppercase checksum of the same file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^sha256sum `, clients.MockResponse{Stdout: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  /tmp/hello.txt\n"})
+
+		state := testDownloadModel()
+		state.SHA256 = types.StringValue(strings.ToUpper(state.SHA256.ValueString()))
+
+		// Act
+		read, removed, diags := testResourceRead(t, newDownloadResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected read: %v", diags)
+		}
+
+		if !read.SHA256.Equal(state.SHA256) {
+			t.Fatalf("unexpected sha256: %s", read.SHA256)
+		}
+	})
+
+	t.Run("read keeps the resource when the file cannot be checked", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^test -f `, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newDownloadResource(newTestProvider(mock)), testDownloadModel())
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func testDownloadModel() downloadResourceModel {
+	return downloadResourceModel{
+		URL:         types.StringValue("https://example.com/hello.txt"),
+		Destination: types.StringValue("/tmp/hello.txt"),
+		SHA256:      types.StringValue("5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"),
+	}
+}
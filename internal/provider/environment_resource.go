@@ -0,0 +1,288 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &environmentResource{}
+
+const (
+	// environmentPath is the file read by pam_env for every session, with KEY="value" lines.
+	environmentPath = "/etc/environment"
+
+	// environmentProfileDirectory is the directory of the scripts sourced by the login shells.
+	environmentProfileDirectory = "/etc/profile.d"
+)
+
+// environmentNamePattern matches the names of environment variables.
+var environmentNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func newEnvironmentResource(p *internalProvider) resource.Resource {
+	return &environmentResource{
+		provider: p,
+	}
+}
+
+// environmentResource defines the resource implementation.
+type environmentResource struct {
+	provider *internalProvider
+}
+
+type environmentResourceModel struct {
+	Name  types.String `tfsdk:"name"`
+	Value types.String `tfsdk:"value"`
+	Scope types.String `tfsdk:"scope"`
+	Path  types.String `tfsdk:"path"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *environmentResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_environment"
+}
+
+func (r *environmentResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Environment resource that sets a system-wide environment variable, either in " +
+			environmentPath + ", read by pam_env for every session including non interactive ones, or in a script of " +
+			environmentProfileDirectory + ", sourced by the login shells, which can reference other variables, e.g. " +
+			"to extend PATH. The variables apply to the sessions started afterwards. Destroying the resource removes " +
+			"the variable",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the variable, e.g. 'JAVA_HOME'",
+				Validators:  []validator.String{environmentName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				Required:    true,
+				Description: "The value of the variable. In the profile scope, it is expanded by the shell, e.g. '$PATH:/opt/tools/bin'",
+				Validators:  []validator.String{environmentValue()},
+			},
+			"scope": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("environment"),
+				Description: "Where the variable is set, either 'environment' for " + environmentPath + " or 'profile' for a script of " + environmentProfileDirectory + ". Defaults to environment",
+				Validators:  []validator.String{oneOf("environment", "profile")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The file setting the variable",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *environmentResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *environmentResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan environmentResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	plan.Path = types.StringValue(environmentFilePath(plan))
+
+	if err := r.apply(ctx, plan); err != nil {
+		resp.Diagnostics.AddError("Failed to set the environment variable", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *environmentResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state environmentResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, state.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read environment file", err.Error())
+		return
+	}
+
+	found := false
+
+	for _, line := range lines {
+		if value, ok := parseConfigKV(line, state.Name.ValueString(), "="); ok {
+			// The last occurrence wins, as with pam_env and the shells
+			state.Value = types.StringValue(value)
+			found = true
+		}
+	}
+
+	if !found {
+		// The variable, or its file, was removed outside of terraform, it must be set again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *environmentResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan environmentResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.apply(ctx, plan); err != nil {
+		resp.Diagnostics.AddError("Failed to set the environment variable", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *environmentResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state environmentResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if state.Scope.ValueString() == "profile" {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", state.Path.ValueString())))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to remove the environment variable", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		}
+
+		return
+	}
+
+	err := r.provider.editSystemFile(ctx, environmentPath, "644", func(lines []string) ([]string, bool) {
+		kept := []string{}
+
+		for _, line := range lines {
+			if _, ok := parseConfigKV(line, state.Name.ValueString(), "="); !ok {
+				kept = append(kept, line)
+			}
+		}
+
+		return kept, len(kept) != len(lines)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the environment variable", err.Error())
+		return
+	}
+}
+
+// apply sets the variable in the file of its scope.
+func (r *environmentResource) apply(ctx context.Context, model environmentResourceModel) error {
+	name := model.Name.ValueString()
+	line := name + `="` + model.Value.ValueString() + `"`
+
+	// Each variable of the profile scope has its own script, which is replaced as a whole
+	if model.Scope.ValueString() == "profile" {
+		return r.provider.machineAccessClient.WriteFile(ctx, model.Path.ValueString(), "644", "0", "0", "export "+line+"\n")
+	}
+
+	return r.provider.editSystemFile(ctx, environmentPath, "644", func(lines []string) ([]string, bool) {
+		return setConfigKV(lines, name, "=", line)
+	})
+}
+
+// environmentFilePath returns the file setting the variable of the model.
+func environmentFilePath(model environmentResourceModel) string {
+	if model.Scope.ValueString() == "profile" {
+		return path.Join(environmentProfileDirectory, "setup-"+model.Name.ValueString()+".sh")
+	}
+
+	return environmentPath
+}
+
+// environmentName validates the names of environment variables.
+func environmentName() validator.String {
+	return stringValidator{
+		description: "value must be the name of an environment variable",
+		check: func(value string) string {
+			if !environmentNamePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must be made of letters, digits and underscores, and not start with a digit", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// environmentValue validates the values of environment variables, which are written between double quotes on a
+// single line, where backslashes would escape the following character.
+func environmentValue() validator.String {
+	return stringValidator{
+		description: "value must not contain double quotes, backslashes nor line breaks",
+		check: func(value string) string {
+			if strings.ContainsAny(value, "\"\\\n\r") {
+				return fmt.Sprintf("%q must not contain double quotes, backslashes nor line breaks", value)
+			}
+
+			return ""
+		},
+	}
+}
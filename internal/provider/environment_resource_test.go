@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestEnvironmentResourceWithMock(t *testing.T) {
+	t.Run("create sets the variable in /etc/environment", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(environmentPath, "PATH=\"/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin\"\nJAVA_HOME=/usr/lib/jvm/default\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, diags := testResourceCreate(t, newEnvironmentResource(newTestProvider(mock)), testEnvironmentModel("environment"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "PATH=\"/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin\"\nJAVA_HOME=\"/usr/lib/jvm/java-17-openjdk-amd64\"\n"
+		if mock.Files[environmentPath] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files[environmentPath])
+		}
+
+		if state.Path.ValueString() != environmentPath {
+			t.Fatalf("unexpected path: %s", state.Path)
+		}
+	})
+
+	t.Run("create writes a profile script in the profile scope", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		plan := testEnvironmentModel("profile")
+		plan.Name = types.StringValue("PATH")
+		plan.Value = types.StringValue("$PATH:/opt/tools/bin")
+
+		// Act
+		state, diags := testResourceCreate(t, newEnvironmentResource(newTestProvider(mock)), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Path.ValueString() != "/etc/profile.d/setup-PATH.sh" || mock.Files["/etc/profile.d/setup-PATH.sh"] != "export PATH=\"$PATH:/opt/tools/bin\"\n" {
+			t.Fatalf("unexpected files: %v", mock.Files)
+		}
+	})
+
+	t.Run("read detects a changed value", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(environmentPath, "JAVA_HOME=\"/usr/lib/jvm/java-21-openjdk-amd64\"\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newEnvironmentResource(newTestProvider(mock)), testEnvironmentState("environment"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if removed || state.Value.ValueString() != "/usr/lib/jvm/java-21-openjdk-amd64" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read removes a missing variable", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(environmentPath, "PATH=\"/usr/bin\"\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newEnvironmentResource(newTestProvider(mock)), testEnvironmentState("environment"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete removes the variable", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(environmentPath, "PATH=\"/usr/bin\"\nJAVA_HOME=\"/usr/lib/jvm/java-17-openjdk-amd64\"\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		diags := testResourceDelete(t, newEnvironmentResource(newTestProvider(mock)), testEnvironmentState("environment"))
+		profileDiags := testResourceDelete(t, newEnvironmentResource(newTestProvider(mock)), testEnvironmentState("profile"))
+
+		// Assert
+		if diags.HasError() || profileDiags.HasError() {
+			t.Fatal(diags, profileDiags)
+		}
+
+		if mock.Files[environmentPath] != "PATH=\"/usr/bin\"\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[environmentPath])
+		}
+
+		if !slices.Equal(mock.Commands, []string{"rm -f /etc/profile.d/setup-JAVA_HOME.sh"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func TestEnvironmentValueValidator(t *testing.T) {
+	for value, valid := range map[string]bool{
+		"/usr/lib/jvm/default":  true,
+		"$PATH:/opt/tools/bin":  true,
+		"say \"hello\"":         false,
+		"C:\\tools":             false,
+		"first line\nsecond":    false,
+		"http://proxy:3128/":    true,
+		"value with spaces too": true,
+	} {
+		if message := environmentValue().(stringValidator).check(value); (message == "") != valid {
+			t.Errorf("unexpected validation of %q: %q", value, message)
+		}
+	}
+}
+
+func testEnvironmentModel(scope string) environmentResourceModel {
+	return environmentResourceModel{
+		Name:  types.StringValue("JAVA_HOME"),
+		Value: types.StringValue("/usr/lib/jvm/java-17-openjdk-amd64"),
+		Scope: types.StringValue(scope),
+		Path:  types.StringUnknown(),
+	}
+}
+
+func testEnvironmentState(scope string) environmentResourceModel {
+	model := testEnvironmentModel(scope)
+	model.Path = types.StringValue(environmentFilePath(model))
+
+	return model
+}
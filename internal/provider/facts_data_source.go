@@ -0,0 +1,199 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &factsDataSource{}
+)
+
+// factsScript prints one key=value pair per line, so that all facts are gathered in a single round trip.
+const factsScript = `. /etc/os-release 2>/dev/null
+echo "hostname=$(hostname)"
+echo "os_id=$ID"
+echo "os_name=$NAME"
+echo "os_pretty_name=$PRETTY_NAME"
+echo "os_version_id=$VERSION_ID"
+echo "os_codename=${UBUNTU_CODENAME:-$VERSION_CODENAME}"
+echo "kernel=$(uname -r)"
+echo "architecture=$(uname -m)"
+echo "platform_architecture=$(dpkg --print-architecture 2>/dev/null || uname -m)"
+echo "cpu_count=$(nproc 2>/dev/null || getconf _NPROCESSORS_ONLN)"
+echo "memory_total_kb=$(awk '/^MemTotal:/ {print $2}' /proc/meminfo)"
+__virt=$(systemd-detect-virt 2>/dev/null)
+echo "virtualization=${__virt:-none}"`
+
+func newFactsDataSource(p *internalProvider) datasource.DataSource {
+	return &factsDataSource{
+		provider: p,
+	}
+}
+
+type factsDataSource struct {
+	provider *internalProvider
+}
+
+type factsDataSourceModel struct {
+	ID               types.String `tfsdk:"id"`
+	Hostname         types.String `tfsdk:"hostname"`
+	OSID             types.String `tfsdk:"os_id"`
+	OSName           types.String `tfsdk:"os_name"`
+	OSPrettyName     types.String `tfsdk:"os_pretty_name"`
+	OSVersionID      types.String `tfsdk:"os_version_id"`
+	OSCodename       types.String `tfsdk:"os_codename"`
+	Kernel           types.String `tfsdk:"kernel"`
+	Architecture     types.String `tfsdk:"architecture"`
+	PlatformArch     types.String `tfsdk:"platform_architecture"`
+	CPUCount         types.Int64  `tfsdk:"cpu_count"`
+	MemoryTotalBytes types.Int64  `tfsdk:"memory_total_bytes"`
+	Virtualization   types.String `tfsdk:"virtualization"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *factsDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_facts"
+}
+
+func (d *factsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Gathers system facts (OS, kernel, architecture, CPU, memory and virtualization) from the remote system",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The hostname of the remote system (used as ID)",
+			},
+			"hostname": schema.StringAttribute{
+				Computed:    true,
+				Description: "The hostname of the remote system",
+			},
+			"os_id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID field of /etc/os-release (e.g. 'ubuntu', 'debian')",
+			},
+			"os_name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The NAME field of /etc/os-release",
+			},
+			"os_pretty_name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The PRETTY_NAME field of /etc/os-release",
+			},
+			"os_version_id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The VERSION_ID field of /etc/os-release (e.g. '24.04')",
+			},
+			"os_codename": schema.StringAttribute{
+				Computed:    true,
+				Description: "The codename of the distribution (e.g. 'noble')",
+			},
+			"kernel": schema.StringAttribute{
+				Computed:    true,
+				Description: "The kernel release, as reported by uname -r",
+			},
+			"architecture": schema.StringAttribute{
+				Computed:    true,
+				Description: "The machine architecture, as reported by uname -m (e.g. 'x86_64', 'aarch64')",
+			},
+			"platform_architecture": schema.StringAttribute{
+				Computed: true,
+				Description: "The architecture of the userland in the naming of Docker and Go (e.g. 'amd64', 'arm64'), as used by " +
+					"image platforms and release downloads. It is the one of dpkg when installed, as a 64 bits kernel may run a 32 bits userland",
+			},
+			"cpu_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of online CPUs",
+			},
+			"memory_total_bytes": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The total memory of the system in bytes",
+			},
+			"virtualization": schema.StringAttribute{
+				Computed:    true,
+				Description: "The virtualization technology, as reported by systemd-detect-virt, or 'none'",
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *factsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	out, err := d.provider.machineAccessClient.RunCommand(ctx, factsScript)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to gather facts", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+
+	facts := parseFacts(out)
+
+	cpuCount, err := strconv.ParseInt(facts["cpu_count"], 10, 64)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse cpu count", fmt.Sprintf("('%s'): %s", facts["cpu_count"], err.Error()))
+		return
+	}
+
+	memoryTotalKB, err := strconv.ParseInt(facts["memory_total_kb"], 10, 64)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse total memory", fmt.Sprintf("('%s'): %s", facts["memory_total_kb"], err.Error()))
+		return
+	}
+
+	model := factsDataSourceModel{
+		ID:               types.StringValue(facts["hostname"]),
+		Hostname:         types.StringValue(facts["hostname"]),
+		OSID:             types.StringValue(facts["os_id"]),
+		OSName:           types.StringValue(facts["os_name"]),
+		OSPrettyName:     types.StringValue(facts["os_pretty_name"]),
+		OSVersionID:      types.StringValue(facts["os_version_id"]),
+		OSCodename:       types.StringValue(facts["os_codename"]),
+		Kernel:           types.StringValue(facts["kernel"]),
+		Architecture:     types.StringValue(facts["architecture"]),
+		PlatformArch:     types.StringValue(clients.NormalizeArchitecture(facts["platform_architecture"])),
+		CPUCount:         types.Int64Value(cpuCount),
+		MemoryTotalBytes: types.Int64Value(memoryTotalKB * 1024),
+		Virtualization:   types.StringValue(facts["virtualization"]),
+	}
+
+	// The data source is built from the output, the target host is the one of the configuration
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("target_host"), &model.TargetHost)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags := resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// parseFacts parses the key=value lines printed by factsScript.
+func parseFacts(out string) map[string]string {
+	facts := map[string]string{}
+
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		facts[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return facts
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestFactsDataSource(t *testing.T) {
+	// Arrange - set up a single Docker container for all test cases
+	setup := setupTestEnvironment(t)
+
+	t.Run("Test read facts", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testFactsDataSourceConfig(),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("data.setup_facts.test", "os_id", "ubuntu"),
+						resource.TestCheckResourceAttrSet("data.setup_facts.test", "os_codename"),
+						resource.TestCheckResourceAttrSet("data.setup_facts.test", "kernel"),
+						// The test container runs on the architecture of the tests
+						resource.TestCheckResourceAttr("data.setup_facts.test", "platform_architecture", runtime.GOARCH),
+						resource.TestMatchResourceAttr("data.setup_facts.test", "cpu_count", regexp.MustCompile(`^[1-9][0-9]*$`)),
+						resource.TestMatchResourceAttr("data.setup_facts.test", "memory_total_bytes", regexp.MustCompile(`^[1-9][0-9]*$`)),
+						resource.TestCheckResourceAttrSet("data.setup_facts.test", "virtualization"),
+						func(state *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							arch, err := sshClient.RunCommand(context.Background(), "uname -m")
+							if err != nil {
+								return err
+							}
+
+							facts := state.RootModule().Resources["data.setup_facts.test"]
+							if facts == nil {
+								return fmt.Errorf("facts data source not found")
+							}
+
+							if facts.Primary.Attributes["architecture"] != strings.TrimSpace(arch) {
+								return fmt.Errorf("unexpected architecture: %s", facts.Primary.Attributes["architecture"])
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func testFactsDataSourceConfig() string {
+	return `
+data "setup_facts" "test" {
+}
+`
+}
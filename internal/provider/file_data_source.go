@@ -5,11 +5,15 @@ package provider
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
@@ -35,6 +39,15 @@ type fileDataSourceModel struct {
 	Group   types.Int64  `tfsdk:"group"`
 	Content types.String `tfsdk:"content"`
 	ID      types.String `tfsdk:"id"`
+
+	ContentBase64 types.String `tfsdk:"content_base64"`
+	MaxSize       types.Int64  `tfsdk:"max_size"`
+
+	Size           types.Int64  `tfsdk:"size"`
+	ModifiedTime   types.String `tfsdk:"modified_time"`
+	FollowSymlinks types.Bool   `tfsdk:"follow_symlinks"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (d *fileDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -43,12 +56,14 @@ func (d *fileDataSource) Metadata(_ context.Context, req datasource.MetadataRequ
 
 func (d *fileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Reads a file from the remote system via SSH",
+		MarkdownDescription: "Reads a file from the remote system via SSH. Binary files are read as content_base64, as " +
+			"Terraform strings can only hold UTF-8 text",
 
 		Attributes: map[string]schema.Attribute{
 			"path": schema.StringAttribute{
 				Required:    true,
 				Description: "The path of the file to read",
+				Validators:  []validator.String{absolutePath()},
 			},
 			"mode": schema.StringAttribute{
 				Computed:    true,
@@ -64,17 +79,41 @@ func (d *fileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, r
 			},
 			"content": schema.StringAttribute{
 				Computed:    true,
-				Description: "The content of the file",
+				Description: "The content of the file, null when it is not valid UTF-8 text, e.g. for binary files",
+			},
+			"content_base64": schema.StringAttribute{
+				Computed:    true,
+				Description: "The content of the file encoded in base64, which preserves binary content",
+			},
+			"max_size": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The maximum size of the file in bytes. Reading a larger file fails instead of loading it into the state",
+				Validators:  []validator.Int64{int64Between(0, math.MaxInt64)},
 			},
 			"id": schema.StringAttribute{
 				Computed:    true,
 				Description: "The path of the file (used as ID)",
 			},
+			"size": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The size of the file in bytes",
+			},
+			"modified_time": schema.StringAttribute{
+				Computed:    true,
+				Description: "The last modification time of the file, in RFC 3339 format",
+			},
+			"follow_symlinks": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether to read the file a symlink points to. Reading a symlink fails when false. Defaults to true",
+			},
+			"target_host": targetHostDataSourceAttribute(),
 		},
 	}
 }
 
 func (d *fileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
 	var model fileDataSourceModel
 
 	diags := req.Config.Get(ctx, &model)
@@ -84,40 +123,41 @@ func (d *fileDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 		return
 	}
 
-	// read the file content
-	content, err := d.provider.machineAccessClient.RunCommand(ctx, "sudo cat "+model.Path.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to read file", err.Error())
-		return
-	}
+	path := model.Path.ValueString()
+	followSymlinks := model.FollowSymlinks.IsNull() || model.FollowSymlinks.ValueBool()
 
-	model.Content = types.StringValue(content)
-	model.ID = types.StringValue(model.Path.String())
-
-	// get the file stat
-	stat, err := d.provider.machineAccessClient.RunCommand(ctx, "sudo stat -c '%u %g %a' "+model.Path.String())
+	// get the file stat first, so that a file too large is not read
+	info, err := d.provider.machineAccessClient.Stat(ctx, path, followSymlinks)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to read file stat", err.Error())
 		return
 	}
 
-	statParts := strings.Split(strings.Trim(stat, "\n"), " ")
-
-	owner, err := strconv.ParseInt(statParts[0], 10, 64)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to parse owner", err.Error())
+	if !model.MaxSize.IsNull() && info.Size > model.MaxSize.ValueInt64() {
+		resp.Diagnostics.AddError("File too large", fmt.Sprintf("%s is %d bytes, more than the max_size of %d bytes", path, info.Size, model.MaxSize.ValueInt64()))
 		return
 	}
 
-	group, err := strconv.ParseInt(statParts[1], 10, 64)
+	// read the file content
+	content, err := d.provider.machineAccessClient.ReadFile(ctx, path, followSymlinks)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to parse group", err.Error())
+		resp.Diagnostics.AddError("Failed to read file", err.Error())
 		return
 	}
 
-	model.Owner = types.Int64Value(owner)
-	model.Group = types.Int64Value(group)
-	model.Mode = types.StringValue(statParts[2])
+	model.Content = types.StringNull()
+	if utf8.ValidString(content) {
+		model.Content = types.StringValue(content)
+	}
+
+	model.ContentBase64 = types.StringValue(base64.StdEncoding.EncodeToString([]byte(content)))
+	model.ID = types.StringValue(model.Path.String())
+
+	model.Owner = types.Int64Value(info.Owner)
+	model.Group = types.Int64Value(info.Group)
+	model.Mode = types.StringValue(info.Mode)
+	model.Size = types.Int64Value(info.Size)
+	model.ModifiedTime = types.StringValue(info.ModTime.Format(time.RFC3339))
 
 	diags = resp.State.Set(ctx, &model)
 	resp.Diagnostics.Append(diags...)
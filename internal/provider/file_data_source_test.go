@@ -3,9 +3,11 @@ package provider
 import (
 	"context"
 	"fmt"
+	"strings"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
@@ -75,6 +77,8 @@ func TestFileDataSource(t *testing.T) {
 						resource.TestCheckResourceAttrSet("data.setup_file.test", "group"),
 						resource.TestCheckResourceAttrSet("data.setup_file.test", "content"),
 						resource.TestCheckResourceAttrSet("data.setup_file.test", "id"),
+						resource.TestCheckResourceAttr("data.setup_file.test", "size", "13"),
+						resource.TestCheckResourceAttrSet("data.setup_file.test", "modified_time"),
 					),
 				},
 			},
@@ -89,3 +93,63 @@ data "setup_file" "test" {
 }
 `, path)
 }
+
+func TestFileDataSourceWithMock(t *testing.T) {
+	t.Run("binary content is only read as base64", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/usr/share/app/logo.png", "\x89PNG\r\n\x1a\n\xff", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newFileDataSource(newTestProvider(mock)), fileDataSourceModel{
+			Path: types.StringValue("/usr/share/app/logo.png"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.Content.IsNull() || state.ContentBase64.ValueString() != "iVBORw0KGgr/" || state.Size.ValueInt64() != 9 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("text content is read as is and as base64", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/hostname", "host\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newFileDataSource(newTestProvider(mock)), fileDataSourceModel{
+			Path:    types.StringValue("/etc/hostname"),
+			MaxSize: types.Int64Value(5),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Content.ValueString() != "host\n" || state.ContentBase64.ValueString() != "aG9zdAo=" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("a file larger than max_size is not read", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/var/log/syslog", "a long log\n", clients.FileInfo{Mode: "640"})
+
+		// Act
+		_, diags := testDataSourceRead(t, newFileDataSource(newTestProvider(mock)), fileDataSourceModel{
+			Path:    types.StringValue("/var/log/syslog"),
+			MaxSize: types.Int64Value(4),
+		})
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags[0].Detail(), "max_size") {
+			t.Fatalf("expected a max_size error: %v", diags)
+		}
+	})
+}
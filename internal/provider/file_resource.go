@@ -5,18 +5,26 @@ package provider
 
 import (
 	"context"
-	"strconv"
+	"crypto/sha256"
+	"fmt"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &fileResource{}
 var _ resource.ResourceWithImportState = &fileResource{}
+var _ resource.ResourceWithUpgradeState = &fileResource{}
+var _ resource.ResourceWithModifyPlan = &fileResource{}
 
 func newFileResource(p *internalProvider) resource.Resource {
 	return &fileResource{
@@ -30,11 +38,17 @@ type fileResource struct {
 }
 
 type fileResourceModel struct {
-	Path    types.String `tfsdk:"path"`
-	Mode    types.String `tfsdk:"mode"`
-	Owner   types.Int64  `tfsdk:"owner"`
-	Group   types.Int64  `tfsdk:"group"`
-	Content types.String `tfsdk:"content"`
+	Path            types.String `tfsdk:"path"`
+	Mode            types.String `tfsdk:"mode"`
+	Owner           types.String `tfsdk:"owner"`
+	Group           types.String `tfsdk:"group"`
+	Content         types.String `tfsdk:"content"`
+	ContentWO       types.String `tfsdk:"content_wo"`
+	ContentSHA256   types.String `tfsdk:"content_sha256"`
+	StoreContent    types.Bool   `tfsdk:"store_content_in_state"`
+	TrailingNewline types.Bool   `tfsdk:"ensure_trailing_newline"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (file *fileResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -43,29 +57,57 @@ func (file *fileResource) Metadata(_ context.Context, req resource.MetadataReque
 
 func (file *fileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "file resource",
+		MarkdownDescription: "file resource. It can be imported with its path, e.g. `terraform import setup_file.motd /etc/motd`",
+		Version:             1,
 
 		Attributes: map[string]schema.Attribute{
 			"path": schema.StringAttribute{
 				Required:    true,
 				Description: "The path of the file",
+				Validators:  []validator.String{absolutePath()},
 			},
 			"mode": schema.StringAttribute{
 				Required:    true,
 				Description: "The mode of the file",
+				Validators:  []validator.String{octalMode()},
 			},
-			"owner": schema.Int64Attribute{
+			"owner": schema.StringAttribute{
 				Required:    true,
-				Description: "The owner of the file",
+				Description: "The owner of the file, a user name or a numeric UID",
+				Validators:  []validator.String{accountName()},
 			},
-			"group": schema.Int64Attribute{
+			"group": schema.StringAttribute{
 				Required:    true,
-				Description: "The group of the file",
+				Description: "The group of the file, a group name or a numeric GID",
+				Validators:  []validator.String{accountName()},
 			},
 			"content": schema.StringAttribute{
-				Required:    true,
-				Description: "The content of the file",
+				Optional:    true,
+				Description: "The content of the file, written as is. Required unless store_content_in_state is false",
+			},
+			"content_wo": schema.StringAttribute{
+				Optional:    true,
+				WriteOnly:   true,
+				Sensitive:   true,
+				Description: "The content of the file when store_content_in_state is false. It is not stored in the state, only its sha256 is. Requires Terraform 1.11 or later",
+			},
+			"content_sha256": schema.StringAttribute{
+				Computed:    true,
+				Description: "The hex encoded sha256 of the content of the file",
 			},
+			"store_content_in_state": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether the content is stored in the state. When false, the content is given with content_wo, e.g. for large or secret files, and the changes made outside of terraform are detected by hashing the file on the remote host. Defaults to true",
+			},
+			"ensure_trailing_newline": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether a line break is appended to the content when it does not end with one, e.g. for content rendered by templatefile. The content is kept as configured in the state. Defaults to false",
+			},
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -75,6 +117,8 @@ func (file *fileResource) Configure(_ context.Context, _ resource.ConfigureReque
 }
 
 func (file *fileResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = file.provider.mutating(ctx, file, "create", req.Plan)
+
 	var plan fileResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -84,15 +128,9 @@ func (file *fileResource) Create(ctx context.Context, req resource.CreateRequest
 		return
 	}
 
-	content, err := strconv.Unquote(plan.Content.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to unquote package name", err.Error())
-		return
-	}
+	file.write(ctx, &plan, req.Config, &resp.Diagnostics)
 
-	err = file.provider.machineAccessClient.WriteFile(ctx, plan.Path.String(), plan.Mode.String(), plan.Owner.String(), plan.Group.String(), content)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to create file", err.Error())
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
@@ -105,6 +143,8 @@ func (file *fileResource) Create(ctx context.Context, req resource.CreateRequest
 }
 
 func (file *fileResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = file.provider.onHost(ctx, req.State)
+
 	var model fileResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -114,39 +154,56 @@ func (file *fileResource) Read(ctx context.Context, req resource.ReadRequest, re
 		return
 	}
 
-	// read the file content
-	content, err := file.provider.machineAccessClient.RunCommand(ctx, "sudo cat "+model.Path.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to read file", err.Error())
-		return
+	if model.StoreContent.IsNull() {
+		model.StoreContent = types.BoolValue(true)
 	}
 
-	model.Content = types.StringValue(content)
+	if model.TrailingNewline.IsNull() {
+		model.TrailingNewline = types.BoolValue(false)
+	}
 
 	// get the file stat
-	stat, err := file.provider.machineAccessClient.RunCommand(ctx, "sudo stat -c '%u %g %a' "+model.Path.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to read file stat", err.Error())
+	info, err := file.provider.machineAccessClient.Stat(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be created again
+		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	statParts := strings.Split(strings.Trim(stat, "\n"), " ")
-
-	owner, err := strconv.ParseInt(statParts[0], 10, 64)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to parse owner", err.Error())
+		resp.Diagnostics.AddError("Failed to read file stat", err.Error())
 		return
 	}
 
-	group, err := strconv.ParseInt(statParts[1], 10, 64)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to parse group", err.Error())
-		return
+	if model.StoreContent.ValueBool() {
+		content, err := file.provider.machineAccessClient.ReadFile(ctx, model.Path.ValueString(), true)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read file", err.Error())
+			return
+		}
+
+		// The configured content is kept when the line break appended on write is the only difference
+		if model.Content.IsNull() || content != model.writtenContent() {
+			model.Content = types.StringValue(content)
+		}
+
+		model.ContentSHA256 = types.StringValue(fmt.Sprintf("%x", sha256.Sum256([]byte(content))))
+	} else {
+		hash, err := file.remoteSHA256(ctx, model.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to hash file", err.Error())
+			return
+		}
+
+		model.ContentSHA256 = types.StringValue(hash)
 	}
 
-	model.Owner = types.Int64Value(owner)
-	model.Group = types.Int64Value(group)
-	model.Mode = types.StringValue(statParts[2])
+	// Windows has no file modes nor numeric owners, only the content is tracked there
+	if file.provider.osFamily != clients.OSFamilyWindows {
+		model.Owner = ownershipValue(ctx, file.provider.machineAccessClient, "passwd", model.Owner, info.Owner)
+		model.Group = ownershipValue(ctx, file.provider.machineAccessClient, "group", model.Group, info.Group)
+		model.Mode = modeValue(model.Mode, info.Mode)
+	}
 
 	diags = resp.State.Set(ctx, model)
 	resp.Diagnostics.Append(diags...)
@@ -157,6 +214,8 @@ func (file *fileResource) Read(ctx context.Context, req resource.ReadRequest, re
 }
 
 func (file *fileResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = file.provider.mutating(ctx, file, "update", req.Plan)
+
 	var plan fileResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -166,15 +225,9 @@ func (file *fileResource) Update(ctx context.Context, req resource.UpdateRequest
 		return
 	}
 
-	content, err := strconv.Unquote(plan.Content.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to unquote package name", err.Error())
-		return
-	}
+	file.write(ctx, &plan, req.Config, &resp.Diagnostics)
 
-	err = file.provider.machineAccessClient.WriteFile(ctx, plan.Path.String(), plan.Mode.String(), plan.Owner.String(), plan.Group.String(), content)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to create file", err.Error())
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
@@ -187,6 +240,8 @@ func (file *fileResource) Update(ctx context.Context, req resource.UpdateRequest
 }
 
 func (file *fileResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = file.provider.mutating(ctx, file, "delete", req.State)
+
 	var model fileResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -196,13 +251,136 @@ func (file *fileResource) Delete(ctx context.Context, req resource.DeleteRequest
 		return
 	}
 
-	_, err := file.provider.machineAccessClient.RunCommand(ctx, "sudo rm -rf "+model.Path.String())
+	if !file.provider.allowDestructiveOperation("remove the file "+model.Path.ValueString(), &resp.Diagnostics) {
+		return
+	}
+
+	_, err := file.provider.machineAccessClient.Run(ctx, file.provider.sudo(clients.ShellCommand("rm", "-rf", model.Path.ValueString())))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to delete file", err.Error())
 		return
 	}
 }
 
+func (file *fileResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var config fileResourceModel
+
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !config.StoreContent.IsUnknown() {
+		store := config.StoreContent.IsNull() || config.StoreContent.ValueBool()
+
+		if store && config.Content.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("content"), "Missing content", "content must be set, or content_wo with store_content_in_state = false")
+			return
+		}
+
+		if store && !config.ContentWO.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("content_wo"), "Write-only content stored in the state", "content_wo requires store_content_in_state = false")
+			return
+		}
+
+		if !store && !config.Content.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("content"), "Content stored in the state", "content is stored in the state, use content_wo with store_content_in_state = false")
+			return
+		}
+	}
+
+	// The hash of the configured content is compared to the hash read from the file, so that a change of either shows
+	// in the plan whether the content is stored in the state or not
+	hash := types.StringUnknown()
+	if !config.Content.IsUnknown() && !config.ContentWO.IsUnknown() && !config.TrailingNewline.IsUnknown() {
+		hash = types.StringValue(fmt.Sprintf("%x", sha256.Sum256([]byte(config.writtenContent()))))
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content_sha256"), hash)...)
+}
+
+// write writes the content of the configuration, which holds the write-only content, to the file, and sets the hash of
+// the written content in the plan.
+func (file *fileResource) write(ctx context.Context, plan *fileResourceModel, config tfsdk.Config, diags *diag.Diagnostics) {
+	var configured fileResourceModel
+
+	diags.Append(config.Get(ctx, &configured)...)
+
+	if diags.HasError() {
+		return
+	}
+
+	owner, group, err := file.provider.lookupOwnership(ctx, plan.Owner, plan.Group)
+	if err != nil {
+		diags.AddError("Failed to look up the owner of the file", err.Error())
+		return
+	}
+
+	// The plan holds the content with the default of ensure_trailing_newline, which the configuration may not set
+	configured.TrailingNewline = plan.TrailingNewline
+	content := configured.writtenContent()
+
+	err = file.provider.machineAccessClient.WriteFile(ctx, plan.Path.ValueString(), plan.Mode.ValueString(), owner, group, content)
+	if err != nil {
+		diags.AddError("Failed to create file", err.Error())
+		return
+	}
+
+	plan.ContentSHA256 = types.StringValue(fmt.Sprintf("%x", sha256.Sum256([]byte(content))))
+}
+
+// remoteSHA256 returns the hex encoded sha256 of the file, computed on the remote host so that the content is not
+// transferred.
+func (file *fileResource) remoteSHA256(ctx context.Context, filePath string) (string, error) {
+	if file.provider.osFamily == clients.OSFamilyWindows {
+		out, err := file.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("(Get-FileHash -Algorithm SHA256 -LiteralPath %s).Hash.ToLower()", clients.PowershellQuote(filePath)))
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s. Err=%w\nout = %s", filePath, err, out)
+		}
+
+		return strings.TrimSpace(out), nil
+	}
+
+	out, err := file.provider.machineAccessClient.RunCommand(ctx, file.provider.sudo(clients.ShellCommand("sha256sum", "--", filePath)))
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s. Err=%w\nout = %s", filePath, err, out)
+	}
+
+	hash, _, _ := strings.Cut(out, " ")
+	if len(hash) != sha256.Size*2 {
+		return "", fmt.Errorf("unexpected sha256sum output: %s", out)
+	}
+
+	return hash, nil
+}
+
+// writtenContent returns the content written to the file, with a line break appended when ensure_trailing_newline is
+// set and the content does not end with one. The write-only content is used when content is not set.
+func (model fileResourceModel) writtenContent() string {
+	content := model.Content.ValueString()
+	if model.Content.IsNull() {
+		content = model.ContentWO.ValueString()
+	}
+
+	if model.TrailingNewline.ValueBool() && content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return content
+}
+
+func (file *fileResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: ownershipStateUpgrader(),
+	}
+}
+
 func (file *fileResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resource.ImportStatePassthroughID(ctx, path.Root("path"), req, resp)
 }
@@ -3,9 +3,13 @@ package provider
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -215,6 +219,474 @@ func TestFileResource(t *testing.T) {
 	})
 }
 
+func TestFileResourceWithMock(t *testing.T) {
+	t.Run("create writes the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:    types.StringValue("/tmp/test.txt"),
+			Mode:    types.StringValue("644"),
+			Owner:   types.StringValue("1000"),
+			Group:   types.StringValue("1000"),
+			Content: types.StringValue("hello\n"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/tmp/test.txt"] != "hello\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/tmp/test.txt"])
+		}
+	})
+
+	t.Run("read detects a changed content and mode", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/tmp/test.txt", "changed\n", clients.FileInfo{Mode: "600", Owner: 1000, Group: 1000})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:    types.StringValue("/tmp/test.txt"),
+			Mode:    types.StringValue("644"),
+			Owner:   types.StringValue("1000"),
+			Group:   types.StringValue("1000"),
+			Content: types.StringValue("hello\n"),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Content.ValueString() != "changed\n" || state.Mode.ValueString() != "600" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("create writes the file on the target host", func(t *testing.T) {
+		// Arrange
+		defaultHost := clients.NewMockMachineAccessClient()
+		web2 := clients.NewMockMachineAccessClient()
+		client := clients.NewMultiHostMachineAccessClient(defaultHost, map[string]clients.MachineAccessClient{"web2": web2})
+
+		// Act
+		_, diags := testResourceCreate(t, newFileResource(newTestProvider(client)), fileResourceModel{
+			Path:       types.StringValue("/etc/motd"),
+			Mode:       types.StringValue("644"),
+			Owner:      types.StringValue("0"),
+			Group:      types.StringValue("0"),
+			Content:    types.StringValue("welcome\n"),
+			TargetHost: types.StringValue("web2"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if web2.Files["/etc/motd"] != "welcome\n" || len(defaultHost.Files) != 0 {
+			t.Fatalf("unexpected files: %v, %v", web2.Files, defaultHost.Files)
+		}
+	})
+
+	t.Run("create is recorded in the audit log", func(t *testing.T) {
+		// Arrange
+		auditLogPath := filepath.Join(t.TempDir(), "audit.jsonl")
+		client := clients.NewAuditedMachineAccessClient(clients.NewMockMachineAccessClient(), clients.AuditOptions{Path: auditLogPath})
+
+		// Act
+		_, diags := testResourceCreate(t, newFileResource(newTestProvider(client)), fileResourceModel{
+			Path:    types.StringValue("/etc/motd"),
+			Mode:    types.StringValue("644"),
+			Owner:   types.StringValue("0"),
+			Group:   types.StringValue("0"),
+			Content: types.StringValue("welcome\n"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		auditLog, err := os.ReadFile(auditLogPath) // #nosec G304 - the path is a test file
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(auditLog), `"resource":"setup_file","operation":"create","command":"write /etc/motd`) {
+			t.Fatalf("unexpected audit log: %s", auditLog)
+		}
+	})
+
+	t.Run("create writes the content as is by default", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:            types.StringValue("/tmp/test.txt"),
+			Mode:            types.StringValue("644"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringValue("hello"),
+			TrailingNewline: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/tmp/test.txt"] != "hello" {
+			t.Fatalf("unexpected content: %q", mock.Files["/tmp/test.txt"])
+		}
+	})
+
+	t.Run("create appends a trailing newline when requested", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		state, diags := testResourceCreate(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:            types.StringValue("/tmp/test.txt"),
+			Mode:            types.StringValue("644"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringValue("hello"),
+			TrailingNewline: types.BoolValue(true),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/tmp/test.txt"] != "hello\n" || state.Content.ValueString() != "hello" {
+			t.Fatalf("unexpected content: %q, state: %q", mock.Files["/tmp/test.txt"], state.Content.ValueString())
+		}
+	})
+
+	t.Run("read ignores the appended trailing newline", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/tmp/test.txt", "hello\n", clients.FileInfo{Mode: "644", Owner: 0, Group: 0})
+
+		model := fileResourceModel{
+			Path:            types.StringValue("/tmp/test.txt"),
+			Mode:            types.StringValue("0644"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringValue("hello"),
+			TrailingNewline: types.BoolValue(true),
+		}
+
+		// Act
+		state, _, diags := testResourceRead(t, newFileResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.Content.Equal(model.Content) || !state.Mode.Equal(model.Mode) {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read shows the trailing newline when it was not requested", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/tmp/test.txt", "hello\n", clients.FileInfo{Mode: "644", Owner: 0, Group: 0})
+
+		// Act
+		state, _, diags := testResourceRead(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:            types.StringValue("/tmp/test.txt"),
+			Mode:            types.StringValue("644"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringValue("hello"),
+			TrailingNewline: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Content.ValueString() != "hello\n" {
+			t.Fatalf("unexpected content: %q", state.Content.ValueString())
+		}
+	})
+
+	t.Run("modify plan hashes the write-only content", func(t *testing.T) {
+		// Arrange
+		r := newFileResource(newTestProvider(clients.NewMockMachineAccessClient())).(*fileResource)
+
+		// Act
+		plan, diags := testResourceModifyPlan(t, r, nil, fileResourceModel{
+			Path:            types.StringValue("/etc/app/secret.key"),
+			Mode:            types.StringValue("600"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringNull(),
+			ContentWO:       types.StringValue("secret"),
+			ContentSHA256:   types.StringUnknown(),
+			StoreContent:    types.BoolValue(false),
+			TrailingNewline: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if plan.ContentSHA256.ValueString() != "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b" {
+			t.Fatalf("unexpected hash: %s", plan.ContentSHA256)
+		}
+	})
+
+	t.Run("modify plan refuses content when it is not stored in the state", func(t *testing.T) {
+		// Arrange
+		r := newFileResource(newTestProvider(clients.NewMockMachineAccessClient())).(*fileResource)
+
+		// Act
+		_, diags := testResourceModifyPlan(t, r, nil, fileResourceModel{
+			Path:            types.StringValue("/etc/app/secret.key"),
+			Mode:            types.StringValue("600"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringValue("secret"),
+			ContentSHA256:   types.StringUnknown(),
+			StoreContent:    types.BoolValue(false),
+			TrailingNewline: types.BoolValue(false),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("create writes the write-only content", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		state, diags := testResourceCreate(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:            types.StringValue("/etc/app/secret.key"),
+			Mode:            types.StringValue("600"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringNull(),
+			ContentWO:       types.StringValue("secret"),
+			ContentSHA256:   types.StringValue("2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"),
+			StoreContent:    types.BoolValue(false),
+			TrailingNewline: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/app/secret.key"] != "secret" || !state.Content.IsNull() {
+			t.Fatalf("unexpected content: %q, state: %+v", mock.Files["/etc/app/secret.key"], state)
+		}
+	})
+
+	t.Run("read hashes the file remotely when the content is not stored in the state", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/app/secret.key", "changed", clients.FileInfo{Mode: "600", Owner: 0, Group: 0}).
+			On("sha256sum -- /etc/app/secret.key", clients.MockResponse{Stdout: "0a3f0a2a7e0e2b5d6a0d4b1b9b8c1f3e2d4c5b6a7980a1b2c3d4e5f60718293a  /etc/app/secret.key\n"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:            types.StringValue("/etc/app/secret.key"),
+			Mode:            types.StringValue("600"),
+			Owner:           types.StringValue("0"),
+			Group:           types.StringValue("0"),
+			Content:         types.StringNull(),
+			ContentWO:       types.StringNull(),
+			ContentSHA256:   types.StringValue("2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"),
+			StoreContent:    types.BoolValue(false),
+			TrailingNewline: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.ContentSHA256.ValueString() != "0a3f0a2a7e0e2b5d6a0d4b1b9b8c1f3e2d4c5b6a7980a1b2c3d4e5f60718293a" || !state.Content.IsNull() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("create resolves the names of the owner and group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent passwd www-data", clients.MockResponse{Stdout: "www-data:x:33:33:www-data:/var/www:/usr/sbin/nologin\n"}).
+			On("getent group adm", clients.MockResponse{Stdout: "adm:x:4:syslog\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:    types.StringValue("/var/www/index.html"),
+			Mode:    types.StringValue("640"),
+			Owner:   types.StringValue("www-data"),
+			Group:   types.StringValue("adm"),
+			Content: types.StringValue("hello\n"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if info := mock.FileInfos["/var/www/index.html"]; info.Owner != 33 || info.Group != 4 {
+			t.Fatalf("unexpected file info: %+v", info)
+		}
+
+		if state.Owner.ValueString() != "www-data" || state.Group.ValueString() != "adm" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("create fails on an unknown owner", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent passwd nobody-here", clients.MockResponse{ExitCode: 2})
+
+		// Act
+		_, diags := testResourceCreate(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:    types.StringValue("/tmp/test.txt"),
+			Mode:    types.StringValue("644"),
+			Owner:   types.StringValue("nobody-here"),
+			Group:   types.StringValue("0"),
+			Content: types.StringValue("hello\n"),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if _, ok := mock.Files["/tmp/test.txt"]; ok {
+			t.Fatal("the file should not be written")
+		}
+	})
+
+	t.Run("read keeps the names that match the actual ids", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent passwd www-data", clients.MockResponse{Stdout: "www-data:x:33:33:www-data:/var/www:/usr/sbin/nologin\n"}).
+			On("getent group adm", clients.MockResponse{Stdout: "adm:x:4:syslog\n"}).
+			WithFile("/var/www/index.html", "hello\n", clients.FileInfo{Mode: "640", Owner: 33, Group: 0})
+
+		// Act
+		state, _, diags := testResourceRead(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:    types.StringValue("/var/www/index.html"),
+			Mode:    types.StringValue("640"),
+			Owner:   types.StringValue("www-data"),
+			Group:   types.StringValue("adm"),
+			Content: types.StringValue("hello\n"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Owner.ValueString() != "www-data" || state.Group.ValueString() != "0" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("upgrade state converts the numeric owner and group", func(t *testing.T) {
+		// Arrange
+		r := newFileResource(newTestProvider(clients.NewMockMachineAccessClient())).(*fileResource)
+
+		// Act
+		state, diags := testResourceUpgradeState[fileResourceModel](t, r, 0, `{"path":"/etc/motd","mode":"644","owner":1000,"group":0,"content":"welcome\n"}`)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Owner.ValueString() != "1000" || state.Group.ValueString() != "0" || state.Content.ValueString() != "welcome\n" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read removes a deleted file from the state", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newFileResource(newTestProvider(mock)), fileResourceModel{
+			Path:    types.StringValue("/tmp/test.txt"),
+			Mode:    types.StringValue("644"),
+			Owner:   types.StringValue("1000"),
+			Group:   types.StringValue("1000"),
+			Content: types.StringValue("hello\n"),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+	})
+
+	t.Run("import reads the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/motd", "welcome\n", clients.FileInfo{Mode: "644", Owner: 0, Group: 0})
+
+		// Act
+		state, diags := testResourceImportState[fileResourceModel](t, newFileResource(newTestProvider(mock)), "/etc/motd")
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Path.ValueString() != "/etc/motd" || state.Content.ValueString() != "welcome\n" || state.Mode.ValueString() != "644" || state.Owner.ValueString() != "0" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("delete is refused when destructive operations are disabled", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		provider := newTestProvider(mock)
+		provider.denyDestructiveOperations = true
+
+		// Act
+		diags := testResourceDelete(t, newFileResource(provider), fileResourceModel{
+			Path:    types.StringValue("/etc/motd"),
+			Mode:    types.StringValue("644"),
+			Owner:   types.StringValue("0"),
+			Group:   types.StringValue("0"),
+			Content: types.StringValue("welcome\n"),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
 func testFileResourceConfig(path string, mode string, owner int, group int, content string) string {
 	return fmt.Sprintf(`
 resource "setup_file" "file" {
@@ -0,0 +1,304 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &filesystemResource{}
+
+// filesystemLabelCommands are the commands setting the label of each filesystem type, given the label and the device.
+var filesystemLabelCommands = map[string]func(label string, device string) string{
+	"ext2": ext2Label,
+	"ext3": ext2Label,
+	"ext4": ext2Label,
+	"xfs": func(label, device string) string {
+		return clients.ShellCommand("xfs_admin", "-L", xfsLabel(label), device)
+	},
+	"btrfs": func(label, device string) string {
+		return clients.ShellCommand("btrfs", "filesystem", "label", device, label)
+	},
+	"vfat": func(label, device string) string { return clients.ShellCommand("fatlabel", device, label) },
+	"swap": func(label, device string) string { return clients.ShellCommand("swaplabel", "-L", label, device) },
+}
+
+func newFilesystemResource(p *internalProvider) resource.Resource {
+	return &filesystemResource{
+		provider: p,
+	}
+}
+
+// filesystemResource defines the resource implementation.
+type filesystemResource struct {
+	provider *internalProvider
+}
+
+type filesystemResourceModel struct {
+	Device  types.String `tfsdk:"device"`
+	Type    types.String `tfsdk:"type"`
+	Label   types.String `tfsdk:"label"`
+	Options types.List   `tfsdk:"options"`
+	UUID    types.String `tfsdk:"uuid"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *filesystemResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_filesystem"
+}
+
+func (r *filesystemResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Filesystem resource that creates a filesystem on a device with mkfs, or adopts the existing " +
+			"filesystem when it already has the planned type. A device holding another filesystem, a partition table " +
+			"or any other signature is never formatted: it has to be wiped by hand. The label is changed in place. " +
+			"Destroying the resource leaves the filesystem and its data on the device",
+
+		Attributes: map[string]schema.Attribute{
+			"device": schema.StringAttribute{
+				Required:    true,
+				Description: "The device to create the filesystem on, e.g. setup_lvm_logical_volume.postgres.path",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"type": schema.StringAttribute{
+				Required:    true,
+				Description: "The type of the filesystem, one of 'ext2', 'ext3', 'ext4', 'xfs', 'btrfs', 'vfat' or 'swap'",
+				Validators:  []validator.String{oneOf("ext2", "ext3", "ext4", "xfs", "btrfs", "vfat", "swap")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"label": schema.StringAttribute{
+				Optional:    true,
+				Description: "The label of the filesystem, e.g. 'data', to mount it with LABEL=data",
+				Validators:  []validator.String{notBlank()},
+			},
+			"options": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Additional arguments of mkfs, e.g. ['-m', '0'] for ext4. They only apply when the filesystem is created",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.RequiresReplace(),
+				},
+			},
+			"uuid": schema.StringAttribute{
+				Computed:    true,
+				Description: "The UUID of the filesystem, to mount it with UUID=",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *filesystemResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *filesystemResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan filesystemResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	device := plan.Device.ValueString()
+
+	signature, err := blockDeviceSignature(ctx, r.provider, device)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to probe the device", err.Error())
+		return
+	}
+
+	if signature["TYPE"] == plan.Type.ValueString() {
+		// The existing filesystem is adopted, only its label is changed
+		if !r.relabel(ctx, plan, signature["LABEL"], &resp.Diagnostics) {
+			return
+		}
+	} else {
+		if err := requireBlankSignature(device, signature); err != nil {
+			resp.Diagnostics.AddError("Refusing to create the filesystem", err.Error())
+			return
+		}
+
+		command, err := r.mkfsCommand(ctx, plan)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the options", err.Error())
+			return
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create the filesystem", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	signature, err = blockDeviceSignature(ctx, r.provider, device)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to probe the device", err.Error())
+		return
+	}
+
+	plan.UUID = types.StringValue(signature["UUID"])
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *filesystemResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state filesystemResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	signature, err := blockDeviceSignature(ctx, r.provider, state.Device.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to probe the device", err.Error())
+		return
+	}
+
+	if signature["TYPE"] != state.Type.ValueString() {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if label := signature["LABEL"]; label != "" || !state.Label.IsNull() {
+		state.Label = types.StringValue(label)
+	}
+
+	state.UUID = types.StringValue(signature["UUID"])
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *filesystemResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state filesystemResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.relabel(ctx, plan, state.Label.ValueString(), &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *filesystemResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// the filesystem is left on the device, so that its data is never lost
+}
+
+// relabel sets the label of the filesystem when it differs from the current one.
+func (r *filesystemResource) relabel(ctx context.Context, plan filesystemResourceModel, current string, diags *diag.Diagnostics) bool {
+	label := plan.Label.ValueString()
+	if label == current {
+		return true
+	}
+
+	command := filesystemLabelCommands[plan.Type.ValueString()](label, plan.Device.ValueString())
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+	if err != nil {
+		diags.AddError("Failed to change the label", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return false
+	}
+
+	return true
+}
+
+// mkfsCommand returns the command creating the filesystem planned by the model.
+func (r *filesystemResource) mkfsCommand(ctx context.Context, plan filesystemResourceModel) (string, error) {
+	var options []string
+
+	if !plan.Options.IsNull() {
+		if diags := plan.Options.ElementsAs(ctx, &options, false); diags.HasError() {
+			return "", fmt.Errorf("%v", diags)
+		}
+	}
+
+	program, args := "mkfs", []string{"-t", plan.Type.ValueString()}
+	if plan.Type.ValueString() == "swap" {
+		program, args = "mkswap", nil
+	}
+
+	if !plan.Label.IsNull() {
+		// mkfs.vfat takes the label with -n, as -L is unrelated
+		flag := "-L"
+		if plan.Type.ValueString() == "vfat" {
+			flag = "-n"
+		}
+
+		args = append(args, flag, plan.Label.ValueString())
+	}
+
+	args = append(args, options...)
+
+	return clients.ShellCommand(program, append(args, plan.Device.ValueString())...), nil
+}
+
+func ext2Label(label string, device string) string {
+	return clients.ShellCommand("e2label", device, label)
+}
+
+// xfsLabel returns the argument of xfs_admin -L for the label, which clears the label with "--".
+func xfsLabel(label string) string {
+	if label == "" {
+		return "--"
+	}
+
+	return label
+}
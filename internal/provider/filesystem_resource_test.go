@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestFilesystemResourceWithMock(t *testing.T) {
+	t.Run("create formats a blank device", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("blkid -p -o export /dev/data/postgres", clients.MockResponse{ExitCode: 2, Once: true}).
+			On("blkid -p -o export /dev/data/postgres", clients.MockResponse{Stdout: "UUID=0e0f1a52-3c5d-4bd6-8b41-2f0c7a1d9e33\nLABEL=pgdata\nTYPE=ext4\n"})
+
+		model := testFilesystemModel("ext4", "pgdata")
+		model.Options, _ = types.ListValueFrom(t.Context(), types.StringType, []string{"-m", "0"})
+
+		// Act
+		state, diags := testResourceCreate(t, newFilesystemResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "mkfs -t ext4 -L pgdata -m 0 /dev/data/postgres") {
+			t.Fatalf("expected the filesystem to be created, got: %v", mock.Commands)
+		}
+
+		if state.UUID.ValueString() != "0e0f1a52-3c5d-4bd6-8b41-2f0c7a1d9e33" {
+			t.Fatalf("unexpected uuid: %s", state.UUID)
+		}
+	})
+
+	t.Run("create adopts an existing filesystem of the same type and relabels it", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("blkid -p -o export /dev/sdb1", clients.MockResponse{Stdout: "UUID=0e0f\nLABEL=old\nTYPE=xfs\n"})
+
+		model := testFilesystemModel("xfs", "new")
+		model.Device = types.StringValue("/dev/sdb1")
+
+		// Act
+		_, diags := testResourceCreate(t, newFilesystemResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "xfs_admin -L new /dev/sdb1") {
+			t.Fatalf("expected the filesystem to be relabeled, got: %v", mock.Commands)
+		}
+
+		if slices.ContainsFunc(mock.Commands, func(command string) bool { return strings.HasPrefix(command, "mkfs") }) {
+			t.Fatalf("expected no filesystem to be created, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create refuses a device holding another filesystem or a partition table", func(t *testing.T) {
+		for _, signature := range []string{"UUID=0e0f\nTYPE=ext4\n", "PTUUID=4f6e\nPTTYPE=gpt\n"} {
+			// Arrange
+			mock := clients.NewMockMachineAccessClient().
+				On("blkid -p -o export /dev/data/postgres", clients.MockResponse{Stdout: signature})
+
+			// Act
+			_, diags := testResourceCreate(t, newFilesystemResource(newTestProvider(mock)), testFilesystemModel("xfs", ""))
+
+			// Assert
+			if !diags.HasError() {
+				t.Fatalf("expected %q to be refused", signature)
+			}
+
+			if len(mock.Commands) != 1 {
+				t.Fatalf("unexpected commands: %v", mock.Commands)
+			}
+		}
+	})
+
+	t.Run("read detects a changed label and a reformatted device", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("blkid -p -o export /dev/data/postgres", clients.MockResponse{Stdout: "UUID=0e0f\nLABEL=other\nTYPE=ext4\n", Once: true}).
+			On("blkid -p -o export /dev/data/postgres", clients.MockResponse{Stdout: "UUID=0e0f\nTYPE=xfs\n"})
+
+		// Act
+		state, _, diags := testResourceRead(t, newFilesystemResource(newTestProvider(mock)), testFilesystemModel("ext4", "pgdata"))
+		_, removed, removedDiags := testResourceRead(t, newFilesystemResource(newTestProvider(mock)), testFilesystemModel("ext4", "pgdata"))
+
+		// Assert
+		if diags.HasError() || removedDiags.HasError() {
+			t.Fatal(diags, removedDiags)
+		}
+
+		if state.Label.ValueString() != "other" {
+			t.Fatalf("unexpected label: %s", state.Label)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+}
+
+func testFilesystemModel(fsType string, label string) filesystemResourceModel {
+	model := filesystemResourceModel{
+		Device:  types.StringValue("/dev/data/postgres"),
+		Type:    types.StringValue(fsType),
+		Label:   types.StringNull(),
+		Options: types.ListNull(types.StringType),
+		UUID:    types.StringUnknown(),
+	}
+
+	if label != "" {
+		model.Label = types.StringValue(label)
+	}
+
+	return model
+}
@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
@@ -34,6 +36,8 @@ type groupResource struct {
 type groupResourceModel struct {
 	Name types.String `tfsdk:"name"`
 	Gid  types.Int64  `tfsdk:"gid"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (group *groupResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -42,17 +46,19 @@ func (group *groupResource) Metadata(_ context.Context, req resource.MetadataReq
 
 func (group *groupResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Group resource",
+		MarkdownDescription: "Group resource. It can be imported with its name, e.g. `terraform import setup_group.group developers`",
 
 		Attributes: map[string]schema.Attribute{
 			"name": schema.StringAttribute{
 				Required:    true,
 				Description: "The name of the group",
+				Validators:  []validator.String{notBlank()},
 			},
 			"gid": schema.Int64Attribute{
 				Computed:    true,
 				Description: "The group id",
 			},
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -62,6 +68,8 @@ func (group *groupResource) Configure(_ context.Context, _ resource.ConfigureReq
 }
 
 func (group *groupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = group.provider.mutating(ctx, group, "create", req.Plan)
+
 	var plan groupResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -71,7 +79,13 @@ func (group *groupResource) Create(ctx context.Context, req resource.CreateReque
 		return
 	}
 
-	out, err := group.provider.machineAccessClient.RunCommand(ctx, "sudo groupadd -f "+plan.Name.String())
+	command := clients.ShellCommand("groupadd", "-f", plan.Name.ValueString())
+	if !group.provider.capabilities(ctx).ShadowUtils {
+		// addgroup of BusyBox has no equivalent of -f, which succeeds when the group exists
+		command = clients.ShellCommand("getent", "group", plan.Name.ValueString()) + " >/dev/null || " + clients.ShellCommand("addgroup", plan.Name.ValueString())
+	}
+
+	out, err := group.provider.machineAccessClient.RunCommand(ctx, group.provider.sudo(command))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to create group. Err="+err.Error()+"\nout = "+string(out), err.Error())
 		return
@@ -93,6 +107,8 @@ func (group *groupResource) Create(ctx context.Context, req resource.CreateReque
 }
 
 func (group *groupResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = group.provider.onHost(ctx, req.State)
+
 	var model groupResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -120,6 +136,8 @@ func (group *groupResource) Read(ctx context.Context, req resource.ReadRequest,
 }
 
 func (group *groupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = group.provider.mutating(ctx, group, "update", req.Plan)
+
 	var oldModel groupResourceModel
 
 	diags := req.State.Get(ctx, &oldModel)
@@ -139,7 +157,12 @@ func (group *groupResource) Update(ctx context.Context, req resource.UpdateReque
 	}
 
 	if oldModel.Name.String() != newModel.Name.String() {
-		_, err := group.provider.machineAccessClient.RunCommand(ctx, "sudo groupmod -n "+newModel.Name.String()+" "+oldModel.Name.String())
+		if !group.provider.capabilities(ctx).ShadowUtils {
+			resp.Diagnostics.AddError("Failed to rename group", shadowUtilsRequired("groupmod").Error())
+			return
+		}
+
+		_, err := group.provider.machineAccessClient.Run(ctx, group.provider.sudo(clients.ShellCommand("groupmod", "-n", newModel.Name.ValueString(), oldModel.Name.ValueString())))
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to update group", err.Error())
 			return
@@ -158,6 +181,8 @@ func (group *groupResource) Update(ctx context.Context, req resource.UpdateReque
 }
 
 func (group *groupResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = group.provider.mutating(ctx, group, "delete", req.State)
+
 	var model groupResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -167,7 +192,16 @@ func (group *groupResource) Delete(ctx context.Context, req resource.DeleteReque
 		return
 	}
 
-	_, err := group.provider.machineAccessClient.RunCommand(ctx, "sudo groupdel "+model.Name.String())
+	if !group.provider.allowDestructiveOperation("delete the group "+model.Name.ValueString(), &resp.Diagnostics) {
+		return
+	}
+
+	command := clients.ShellCommand("groupdel", model.Name.ValueString())
+	if !group.provider.capabilities(ctx).ShadowUtils {
+		command = clients.ShellCommand("delgroup", model.Name.ValueString())
+	}
+
+	_, err := group.provider.machineAccessClient.Run(ctx, group.provider.sudo(command))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to delete group", err.Error())
 		return
@@ -175,7 +209,7 @@ func (group *groupResource) Delete(ctx context.Context, req resource.DeleteReque
 }
 
 func (group *groupResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
 }
 
 func (group *groupResource) getGid(ctx context.Context, inputName types.String) (int64, error) {
@@ -7,6 +7,7 @@ import (
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -144,6 +145,174 @@ func TestGroupResource(t *testing.T) {
 	})
 }
 
+func TestGroupResourceWithMock(t *testing.T) {
+	t.Run("create adds the group and reads its gid", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent group", clients.MockResponse{Stdout: "root:x:0:\ndevelopers:x:1001:\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newGroupResource(newTestProvider(mock)), groupResourceModel{
+			Name: types.StringValue("developers"),
+			Gid:  types.Int64Unknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Gid.ValueInt64() != 1001 {
+			t.Fatalf("unexpected gid: %d", state.Gid.ValueInt64())
+		}
+
+		if mock.Commands[0] != "groupadd -f developers" {
+			t.Fatalf("unexpected command: %s", mock.Commands[0])
+		}
+	})
+
+	t.Run("create uses addgroup of BusyBox without the shadow utilities", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent group", clients.MockResponse{Stdout: "root:x:0:\ndevelopers:x:1001:\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newGroupResource(withTestCapabilities(newTestProvider(mock), clients.Capabilities{BusyBox: true})), groupResourceModel{
+			Name: types.StringValue("developers"),
+			Gid:  types.Int64Unknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Commands[0] != "getent group developers >/dev/null || addgroup developers" {
+			t.Fatalf("unexpected command: %s", mock.Commands[0])
+		}
+	})
+
+	t.Run("create quotes a name with shell characters", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent group", clients.MockResponse{Stdout: "dev$(id)`id`:x:1001:\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newGroupResource(newTestProvider(mock)), groupResourceModel{
+			Name: types.StringValue("dev$(id)`id`"),
+			Gid:  types.Int64Unknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Commands[0] != "groupadd -f 'dev$(id)`id`'" {
+			t.Fatalf("unexpected command: %s", mock.Commands[0])
+		}
+	})
+
+	t.Run("create fails when groupadd fails", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^groupadd ", clients.MockResponse{Stderr: "groupadd: Permission denied.", ExitCode: 10})
+
+		// Act
+		_, diags := testResourceCreate(t, newGroupResource(newTestProvider(mock)), groupResourceModel{
+			Name: types.StringValue("developers"),
+			Gid:  types.Int64Unknown(),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("read detects a changed gid", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent group", clients.MockResponse{Stdout: "developers:x:2000:\n"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newGroupResource(newTestProvider(mock)), groupResourceModel{
+			Name: types.StringValue("developers"),
+			Gid:  types.Int64Value(1001),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Gid.ValueInt64() != 2000 {
+			t.Fatalf("unexpected gid: %d", state.Gid.ValueInt64())
+		}
+	})
+
+	t.Run("delete removes the group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newGroupResource(newTestProvider(mock)), groupResourceModel{
+			Name: types.StringValue("developers"),
+			Gid:  types.Int64Value(1001),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 || mock.Commands[0] != "groupdel developers" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("import reads the gid of the group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("getent group", clients.MockResponse{Stdout: "developers:x:1001:\n"})
+
+		// Act
+		state, diags := testResourceImportState[groupResourceModel](t, newGroupResource(newTestProvider(mock)), "developers")
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Name.ValueString() != "developers" || state.Gid.ValueInt64() != 1001 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("delete is refused when destructive operations are disabled", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		provider := newTestProvider(mock)
+		provider.denyDestructiveOperations = true
+
+		// Act
+		diags := testResourceDelete(t, newGroupResource(provider), groupResourceModel{
+			Name: types.StringValue("developers"),
+			Gid:  types.Int64Value(1001),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
 func testGroupResourceConfig(name string) string {
 	return fmt.Sprintf(`
 resource "setup_group" "group" {
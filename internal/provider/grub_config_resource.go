@@ -0,0 +1,471 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &grubConfigResource{}
+
+const (
+	defaultGrubPath     = "/etc/default/grub"
+	defaultGrubVariable = "GRUB_CMDLINE_LINUX"
+)
+
+var (
+	kernelParameterNamePattern  = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	kernelParameterValuePattern = regexp.MustCompile("^[^\\s\"'`$\\\\]*$")
+)
+
+func newGrubConfigResource(p *internalProvider) resource.Resource {
+	return &grubConfigResource{
+		provider: p,
+	}
+}
+
+// grubConfigResource defines the resource implementation.
+type grubConfigResource struct {
+	provider *internalProvider
+}
+
+type grubConfigResourceModel struct {
+	Path           types.String `tfsdk:"path"`
+	Variable       types.String `tfsdk:"variable"`
+	Parameters     types.Map    `tfsdk:"parameters"`
+	RebootRequired types.Bool   `tfsdk:"reboot_required"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *grubConfigResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_grub_config"
+}
+
+func (r *grubConfigResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "GRUB config resource that sets kernel parameters of the GRUB_CMDLINE_LINUX variable of " +
+			defaultGrubPath + ", e.g. cgroup flags, hugepages or iommu, and regenerates the GRUB configuration with " +
+			"update-grub, or grub2-mkconfig on RHEL based systems. The other parameters of the variable are left as " +
+			"they are, and destroying the resource removes the parameters it set. The parameters apply after a " +
+			"reboot, see reboot_required",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(defaultGrubPath),
+				Description: "The path of the GRUB defaults file. Defaults to " + defaultGrubPath,
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"variable": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(defaultGrubVariable),
+				Description: "The variable holding the parameters, either GRUB_CMDLINE_LINUX, used for all the boot entries, or GRUB_CMDLINE_LINUX_DEFAULT, not used for the recovery entries. Defaults to " + defaultGrubVariable,
+				Validators:  []validator.String{oneOf("GRUB_CMDLINE_LINUX", "GRUB_CMDLINE_LINUX_DEFAULT")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"parameters": schema.MapAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The kernel parameters by name, e.g. { hugepages = \"1024\", intel_iommu = \"on\" }. An empty value sets a parameter without value, e.g. { quiet = \"\" }",
+			},
+			"reboot_required": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the parameters differ from the ones of the running kernel, read from /proc/cmdline, so that the host must be rebooted for them to apply",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *grubConfigResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *grubConfigResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan grubConfigResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *grubConfigResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model grubConfigResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read GRUB defaults file", err.Error())
+		return
+	}
+
+	current := parseKernelParameters(grubVariable(lines, model.Variable.ValueString()))
+	parameters := map[string]string{}
+
+	// Only the managed parameters are read, the others may be set by other means
+	for name := range model.Parameters.Elements() {
+		if value, ok := current.get(name); ok {
+			parameters[name] = value
+		}
+	}
+
+	model.Parameters, diags = types.MapValueFrom(ctx, types.StringType, parameters)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	rebootRequired, err := r.rebootRequired(ctx, parameters, nil)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the kernel command line", err.Error())
+		return
+	}
+
+	model.RebootRequired = types.BoolValue(rebootRequired)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *grubConfigResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan grubConfigResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state grubConfigResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &state, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *grubConfigResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model grubConfigResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	parameters := map[string]string{}
+
+	diags = model.Parameters.ElementsAs(ctx, &parameters, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.edit(ctx, model, nil, sortedKeys(parameters))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove kernel parameters", err.Error())
+		return
+	}
+}
+
+// apply sets the parameters of the plan, removes the parameters of the previous state that are not configured anymore,
+// regenerates the GRUB configuration and sets reboot_required.
+func (r *grubConfigResource) apply(ctx context.Context, plan *grubConfigResourceModel, previous *grubConfigResourceModel, diags *diag.Diagnostics) {
+	parameters, d := kernelParametersOf(ctx, plan.Parameters)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var removed []string
+
+	if previous != nil {
+		previousParameters := map[string]string{}
+
+		diags.Append(previous.Parameters.ElementsAs(ctx, &previousParameters, false)...)
+
+		if diags.HasError() {
+			return
+		}
+
+		for _, name := range sortedKeys(previousParameters) {
+			if _, ok := parameters[name]; !ok {
+				removed = append(removed, name)
+			}
+		}
+	}
+
+	err := r.edit(ctx, *plan, parameters, removed)
+	if err != nil {
+		diags.AddError("Failed to set kernel parameters", err.Error())
+		return
+	}
+
+	rebootRequired, err := r.rebootRequired(ctx, parameters, removed)
+	if err != nil {
+		diags.AddError("Failed to read the kernel command line", err.Error())
+		return
+	}
+
+	plan.RebootRequired = types.BoolValue(rebootRequired)
+}
+
+// edit sets the parameters, removes the removed ones from the variable of the model, and regenerates the GRUB
+// configuration.
+func (r *grubConfigResource) edit(ctx context.Context, model grubConfigResourceModel, parameters map[string]string, removed []string) error {
+	variable := model.Variable.ValueString()
+
+	err := r.provider.editFile(ctx, model.Path.ValueString(), false, func(lines []string) ([]string, bool) {
+		current := parseKernelParameters(grubVariable(lines, variable))
+
+		edited := current.without(removed)
+		for _, name := range sortedKeys(parameters) {
+			edited = edited.with(name, parameters[name])
+		}
+
+		if edited.String() == current.String() {
+			return lines, false
+		}
+
+		return setConfigKV(lines, variable, "=", variable+"="+strconv.Quote(edited.String()))
+	})
+	if err != nil {
+		return err
+	}
+
+	command, err := r.updateCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+	if err != nil {
+		return fmt.Errorf("failed to regenerate the GRUB configuration: %w, out = %s", err, out)
+	}
+
+	return nil
+}
+
+// updateCommand returns the command regenerating the GRUB configuration, depending on the tool that is installed.
+func (r *grubConfigResource) updateCommand(ctx context.Context) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "if command -v update-grub >/dev/null 2>&1; then echo debian; elif command -v grub2-mkconfig >/dev/null 2>&1; then echo rhel; fi")
+	if err != nil {
+		return "", fmt.Errorf("%w, out = %s", err, out)
+	}
+
+	switch strings.TrimSpace(out) {
+	case "debian":
+		return "update-grub", nil
+	case "rhel":
+		return "grub2-mkconfig -o /boot/grub2/grub.cfg", nil
+	default:
+		return "", errors.New("neither update-grub nor grub2-mkconfig is installed")
+	}
+}
+
+// rebootRequired returns whether the running kernel was booted without the parameters, or with the removed ones.
+func (r *grubConfigResource) rebootRequired(ctx context.Context, parameters map[string]string, removed []string) (bool, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "cat /proc/cmdline")
+	if err != nil {
+		return false, fmt.Errorf("%w, out = %s", err, out)
+	}
+
+	running := parseKernelParameters(strings.TrimSpace(out))
+
+	for name, value := range parameters {
+		if runningValue, ok := running.get(name); !ok || runningValue != value {
+			return true, nil
+		}
+	}
+
+	for _, name := range removed {
+		if _, ok := running.get(name); ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// kernelParameters are the parameters of a kernel command line, in order, e.g. "quiet" or "hugepages=1024".
+type kernelParameters []string
+
+func parseKernelParameters(cmdline string) kernelParameters {
+	return strings.Fields(cmdline)
+}
+
+// get returns the value of the last occurrence of the parameter, which is the one the kernel uses.
+func (p kernelParameters) get(name string) (string, bool) {
+	value := ""
+	found := false
+
+	for _, parameter := range p {
+		parameterName, parameterValue, _ := strings.Cut(parameter, "=")
+		if parameterName == name {
+			value = parameterValue
+			found = true
+		}
+	}
+
+	return value, found
+}
+
+// with returns the parameters with the first occurrence of the parameter replaced, and the others removed. A missing
+// parameter is appended.
+func (p kernelParameters) with(name string, value string) kernelParameters {
+	parameter := name
+	if value != "" {
+		parameter += "=" + value
+	}
+
+	edited := kernelParameters{}
+	found := false
+
+	for _, existing := range p {
+		existingName, _, _ := strings.Cut(existing, "=")
+		if existingName != name {
+			edited = append(edited, existing)
+			continue
+		}
+
+		if !found {
+			edited = append(edited, parameter)
+			found = true
+		}
+	}
+
+	if !found {
+		edited = append(edited, parameter)
+	}
+
+	return edited
+}
+
+// without returns the parameters without all the occurrences of the names.
+func (p kernelParameters) without(names []string) kernelParameters {
+	edited := kernelParameters{}
+
+	for _, existing := range p {
+		existingName, _, _ := strings.Cut(existing, "=")
+		if !slices.Contains(names, existingName) {
+			edited = append(edited, existing)
+		}
+	}
+
+	return edited
+}
+
+func (p kernelParameters) String() string {
+	return strings.Join(p, " ")
+}
+
+// grubVariable returns the unquoted value of the last line setting the variable, as when the file is sourced.
+func grubVariable(lines []string, variable string) string {
+	value := ""
+
+	for _, line := range lines {
+		if v, ok := parseConfigKV(line, variable, "="); ok {
+			value = v
+		}
+	}
+
+	return value
+}
+
+// kernelParametersOf returns the parameters of the map, checking that they can be written to the variable as they are.
+func kernelParametersOf(ctx context.Context, value types.Map) (map[string]string, diag.Diagnostics) {
+	parameters := map[string]string{}
+
+	diags := value.ElementsAs(ctx, &parameters, false)
+
+	for _, name := range sortedKeys(parameters) {
+		if !kernelParameterNamePattern.MatchString(name) {
+			diags.AddError("Invalid kernel parameter", fmt.Sprintf("'%s' is not a kernel parameter name, e.g. hugepages", name))
+		}
+
+		if !kernelParameterValuePattern.MatchString(parameters[name]) {
+			diags.AddError("Invalid kernel parameter", fmt.Sprintf("the value of %s must not contain spaces, quotes, $ or backslashes, got '%s'", name, parameters[name]))
+		}
+	}
+
+	return parameters, diags
+}
@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testGrubDefaults = `GRUB_DEFAULT=0
+GRUB_TIMEOUT=5
+GRUB_CMDLINE_LINUX_DEFAULT="quiet splash"
+GRUB_CMDLINE_LINUX="console=tty0 hugepages=512"
+`
+
+func TestGrubConfigResourceWithMock(t *testing.T) {
+	t.Run("create sets the parameters, keeping the others, and regenerates the configuration", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^if command -v update-grub", clients.MockResponse{Stdout: "debian\n"}).
+			On("cat /proc/cmdline", clients.MockResponse{Stdout: "BOOT_IMAGE=/vmlinuz root=/dev/sda1 console=tty0 hugepages=512\n"}).
+			WithFile(defaultGrubPath, testGrubDefaults, clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, diags := testResourceCreate(t, newGrubConfigResource(newTestProvider(mock)), testGrubConfigModel(map[string]string{"hugepages": "1024", "intel_iommu": "on", "nosmt": ""}))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[defaultGrubPath] != "GRUB_DEFAULT=0\nGRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet splash\"\nGRUB_CMDLINE_LINUX=\"console=tty0 hugepages=1024 intel_iommu=on nosmt\"\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultGrubPath])
+		}
+
+		if !slices.Contains(mock.Commands, "update-grub") {
+			t.Fatalf("expected update-grub to run, got: %v", mock.Commands)
+		}
+
+		if !state.RebootRequired.ValueBool() {
+			t.Fatal("expected a reboot to be required")
+		}
+	})
+
+	t.Run("create uses grub2-mkconfig on RHEL", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^if command -v update-grub", clients.MockResponse{Stdout: "rhel\n"}).
+			On("cat /proc/cmdline", clients.MockResponse{Stdout: "BOOT_IMAGE=/vmlinuz hugepages=512\n"}).
+			WithFile(defaultGrubPath, testGrubDefaults, clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, diags := testResourceCreate(t, newGrubConfigResource(newTestProvider(mock)), testGrubConfigModel(map[string]string{"hugepages": "512"}))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "grub2-mkconfig -o /boot/grub2/grub.cfg") {
+			t.Fatalf("expected grub2-mkconfig to run, got: %v", mock.Commands)
+		}
+
+		if state.RebootRequired.ValueBool() {
+			t.Fatal("expected no reboot to be required")
+		}
+	})
+
+	t.Run("update removes the parameters that are not configured anymore", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^if command -v update-grub", clients.MockResponse{Stdout: "debian\n"}).
+			On("cat /proc/cmdline", clients.MockResponse{Stdout: "BOOT_IMAGE=/vmlinuz console=tty0 hugepages=512\n"}).
+			WithFile(defaultGrubPath, testGrubDefaults, clients.FileInfo{Mode: "644"})
+
+		state := testGrubConfigModel(map[string]string{"hugepages": "512", "console": "tty0"})
+		state.RebootRequired = types.BoolValue(false)
+
+		// Act
+		newState, diags := testResourceUpdate(t, newGrubConfigResource(newTestProvider(mock)), state, testGrubConfigModel(map[string]string{"hugepages": "512"}))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[defaultGrubPath] != "GRUB_DEFAULT=0\nGRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet splash\"\nGRUB_CMDLINE_LINUX=\"hugepages=512\"\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultGrubPath])
+		}
+
+		if !newState.RebootRequired.ValueBool() {
+			t.Fatal("expected a reboot to be required, as the running kernel still has console")
+		}
+	})
+
+	t.Run("read detects drift of the managed parameters", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /proc/cmdline", clients.MockResponse{Stdout: "BOOT_IMAGE=/vmlinuz hugepages=512\n"}).
+			WithFile(defaultGrubPath, testGrubDefaults, clients.FileInfo{Mode: "644"})
+
+		model := testGrubConfigModel(map[string]string{"hugepages": "1024", "intel_iommu": "on"})
+		model.RebootRequired = types.BoolValue(true)
+
+		// Act
+		state, _, diags := testResourceRead(t, newGrubConfigResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Parameters.String() != `{"hugepages":"512"}` {
+			t.Fatalf("unexpected parameters: %s", state.Parameters)
+		}
+
+		if state.RebootRequired.ValueBool() {
+			t.Fatal("expected no reboot to be required")
+		}
+	})
+
+	t.Run("delete removes the parameters", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^if command -v update-grub", clients.MockResponse{Stdout: "debian\n"}).
+			WithFile(defaultGrubPath, testGrubDefaults, clients.FileInfo{Mode: "644"})
+
+		model := testGrubConfigModel(map[string]string{"hugepages": "512"})
+		model.RebootRequired = types.BoolValue(false)
+
+		// Act
+		diags := testResourceDelete(t, newGrubConfigResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[defaultGrubPath] != "GRUB_DEFAULT=0\nGRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet splash\"\nGRUB_CMDLINE_LINUX=\"console=tty0\"\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultGrubPath])
+		}
+	})
+
+	t.Run("values that would break the variable are rejected", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(defaultGrubPath, testGrubDefaults, clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newGrubConfigResource(newTestProvider(mock)), testGrubConfigModel(map[string]string{"init": "/bin/sh\" rd.break"}))
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if mock.Files[defaultGrubPath] != testGrubDefaults {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultGrubPath])
+		}
+	})
+}
+
+func testGrubConfigModel(parameters map[string]string) grubConfigResourceModel {
+	value, _ := types.MapValueFrom(context.Background(), types.StringType, parameters)
+
+	return grubConfigResourceModel{
+		Path:           types.StringValue(defaultGrubPath),
+		Variable:       types.StringValue(defaultGrubVariable),
+		Parameters:     value,
+		RebootRequired: types.BoolUnknown(),
+	}
+}
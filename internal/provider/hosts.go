@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"strconv"
+	"terraform-provider-setup/internal/provider/clients"
+
+	datasourceschema "github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// providerHostModel is a host of the hosts of the provider.
+type providerHostModel struct {
+	Host types.String `tfsdk:"host"`
+	Port types.String `tfsdk:"port"`
+	User types.String `tfsdk:"user"`
+}
+
+// connection returns the user and the port of the host, which default to the ones of the provider.
+func (host providerHostModel) connection(defaultUser string, defaultPort int) (string, int) {
+	user := defaultUser
+	if host.User.ValueString() != "" {
+		user = host.User.ValueString()
+	}
+
+	port := defaultPort
+	if parsed, err := strconv.Atoi(host.Port.ValueString()); err == nil {
+		port = parsed
+	}
+
+	return user, port
+}
+
+const targetHostAttributeDescription = "The name of the host, among the hosts of the provider, on which the resource is managed, " +
+	"e.g. each.key when iterating over the hosts with for_each. Defaults to the host of the provider. Imported resources are on the host of the provider"
+
+// targetHostAttribute is the target_host attribute of the resources, selecting the host they are managed on.
+func targetHostAttribute() schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional:    true,
+		Description: targetHostAttributeDescription,
+		Validators:  []validator.String{notBlank()},
+		PlanModifiers: []planmodifier.String{
+			stringplanmodifier.RequiresReplace(),
+		},
+	}
+}
+
+// targetHostDataSourceAttribute is the target_host attribute of the data sources, selecting the host they are read
+// from.
+func targetHostDataSourceAttribute() datasourceschema.StringAttribute {
+	return datasourceschema.StringAttribute{
+		Optional:    true,
+		Description: "The name of the host, among the hosts of the provider, from which the data source is read. Defaults to the host of the provider",
+		Validators:  []validator.String{notBlank()},
+	}
+}
+
+// hostAttributeSource is the plan, the state or the configuration the target_host attribute is read from.
+type hostAttributeSource interface {
+	GetAttribute(ctx context.Context, path path.Path, target interface{}) diag.Diagnostics
+}
+
+// onHost selects the host named by the target_host attribute of the resource or data source for the commands run with the
+// returned context.
+func (p *internalProvider) onHost(ctx context.Context, source hostAttributeSource) context.Context {
+	var host types.String
+
+	if diags := source.GetAttribute(ctx, path.Root("target_host"), &host); diags.HasError() || host.ValueString() == "" {
+		return ctx
+	}
+
+	return clients.WithHost(ctx, host.ValueString())
+}
@@ -0,0 +1,346 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &iniValueResource{}
+
+func newINIValueResource(p *internalProvider) resource.Resource {
+	return &iniValueResource{
+		provider: p,
+	}
+}
+
+// iniValueResource defines the resource implementation.
+type iniValueResource struct {
+	provider *internalProvider
+}
+
+type iniValueResourceModel struct {
+	Path            types.String `tfsdk:"path"`
+	Section         types.String `tfsdk:"section"`
+	Key             types.String `tfsdk:"key"`
+	Value           types.String `tfsdk:"value"`
+	NoExtraSpaces   types.Bool   `tfsdk:"no_extra_spaces"`
+	Create          types.Bool   `tfsdk:"create"`
+	RemoveOnDestroy types.Bool   `tfsdk:"remove_on_destroy"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *iniValueResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_ini_value"
+}
+
+func (r *iniValueResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "INI value resource that sets the value of a key in a section of an INI file. The other " +
+			"lines of the file, comments included, are left as they are",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the INI file",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"section": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(""),
+				Description: "The section of the key, without brackets. The section is added at the end of the file when missing. Defaults to the lines before the first section",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Required:    true,
+				Description: "The key",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				Required:    true,
+				Description: "The value of the key",
+			},
+			"no_extra_spaces": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the key is written as key=value instead of key = value. Defaults to false",
+			},
+			"create": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether a missing file is created, with mode 644 and owned by the connecting user. Defaults to false",
+			},
+			"remove_on_destroy": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether destroying the resource removes the key from the file. Defaults to false",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *iniValueResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *iniValueResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan iniValueResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set INI value", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *iniValueResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model iniValueResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read INI file", err.Error())
+		return
+	}
+
+	index, found := findINIKey(lines, model.Section.ValueString(), model.Key.ValueString())
+	if !found {
+		// The key, or the file, was removed outside of terraform, it must be set again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	_, value := parseINIKey(lines[index])
+	model.Value = types.StringValue(value)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *iniValueResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan iniValueResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set INI value", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *iniValueResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model iniValueResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !model.RemoveOnDestroy.ValueBool() {
+		return
+	}
+
+	// A missing file is edited as an empty one, which has nothing to remove and is not created
+	err := r.provider.editFile(ctx, model.Path.ValueString(), true, func(lines []string) ([]string, bool) {
+		index, found := findINIKey(lines, model.Section.ValueString(), model.Key.ValueString())
+		if !found {
+			return lines, false
+		}
+
+		return append(append([]string{}, lines[:index]...), lines[index+1:]...), true
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove INI value", err.Error())
+		return
+	}
+}
+
+// apply sets the value of the key, adding the key and its section when missing.
+func (r *iniValueResource) apply(ctx context.Context, plan iniValueResourceModel) error {
+	separator := " = "
+	if plan.NoExtraSpaces.ValueBool() {
+		separator = "="
+	}
+
+	section := plan.Section.ValueString()
+	line := plan.Key.ValueString() + separator + plan.Value.ValueString()
+
+	return r.provider.editFile(ctx, plan.Path.ValueString(), plan.Create.ValueBool(), func(lines []string) ([]string, bool) {
+		return setINIKey(lines, section, plan.Key.ValueString(), line)
+	})
+}
+
+// setINIKey returns the lines with the line of the key in the section replaced by line. A missing key is added after
+// the last line of its section, and a missing section is added at the end of the file.
+func setINIKey(lines []string, section string, key string, line string) ([]string, bool) {
+	index, found := findINIKey(lines, section, key)
+	if found {
+		if lines[index] == line {
+			return lines, false
+		}
+
+		edited := append([]string{}, lines...)
+		edited[index] = line
+
+		return edited, true
+	}
+
+	start, end, found := findINISection(lines, section)
+	if !found {
+		edited := append([]string{}, lines...)
+		if len(edited) > 0 && strings.TrimSpace(edited[len(edited)-1]) != "" {
+			edited = append(edited, "")
+		}
+
+		return append(edited, "["+section+"]", line), true
+	}
+
+	// The key goes after the last line of the section that is not blank, before the blank lines separating sections
+	index = end
+	for index > start && strings.TrimSpace(lines[index-1]) == "" {
+		index--
+	}
+
+	return append(append(append([]string{}, lines[:index]...), line), lines[index:]...), true
+}
+
+// findINIKey returns the index of the line of the key in the section.
+func findINIKey(lines []string, section string, key string) (int, bool) {
+	start, end, found := findINISection(lines, section)
+	if !found {
+		return 0, false
+	}
+
+	for i := start; i < end; i++ {
+		if name, _ := parseINIKey(lines[i]); name == key {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// findINISection returns the indexes of the first line of the section after its header, and of the line ending it.
+// The section named "" is made of the lines before the first header, and is always found.
+func findINISection(lines []string, section string) (int, int, bool) {
+	start := -1
+	if section == "" {
+		start = 0
+	}
+
+	for i, line := range lines {
+		name, ok := parseINISectionHeader(line)
+		if !ok {
+			continue
+		}
+
+		if start >= 0 {
+			return start, i, true
+		}
+
+		if name == section {
+			start = i + 1
+		}
+	}
+
+	if start >= 0 {
+		return start, len(lines), true
+	}
+
+	return 0, 0, false
+}
+
+// parseINISectionHeader returns the name of the section of a [section] line.
+func parseINISectionHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// parseINIKey returns the key and the value of a key = value line. Comments, blank lines and section headers have no
+// key, while a line without equal sign is a key without value.
+func parseINIKey(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "[") {
+		return "", ""
+	}
+
+	key, value, _ := strings.Cut(line, "=")
+
+	return strings.TrimSpace(key), strings.TrimSpace(value)
+}
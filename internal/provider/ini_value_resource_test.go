@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestINIValueResource(t *testing.T) {
+	t.Run("Test set a value in a new section", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_ini_value" "test" {
+  path    = "/tmp/test.ini"
+  section = "server"
+  key     = "port"
+  value   = "8080"
+  create  = true
+}
+`,
+					Check: func(_ *terraform.State) error {
+						content, err := sshClient.RunCommand(context.Background(), "cat /tmp/test.ini")
+						if err != nil {
+							return fmt.Errorf("file not found")
+						}
+
+						if content != "[server]\nport = 8080\n" {
+							return fmt.Errorf("unexpected content: %s", content)
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestINIValueResourceWithMock(t *testing.T) {
+	content := "; global comment\nuser = admin\n\n[database]\n# the host\nhost = localhost\n\n[cache]\nsize=10\n"
+
+	t.Run("create replaces the value of the key in its section only", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/app.ini", content+"host = other\n", clients.FileInfo{Mode: "640", Owner: 0, Group: 50})
+
+		// Act
+		_, diags := testResourceCreate(t, newINIValueResource(newTestProvider(mock)), iniValueResourceModel{
+			Path:            types.StringValue("/etc/app.ini"),
+			Section:         types.StringValue("database"),
+			Key:             types.StringValue("host"),
+			Value:           types.StringValue("db.internal"),
+			NoExtraSpaces:   types.BoolValue(false),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := strings.Replace(content, "host = localhost", "host = db.internal", 1) + "host = other\n"
+		if mock.Files["/etc/app.ini"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/app.ini"])
+		}
+
+		if info := mock.FileInfos["/etc/app.ini"]; info.Mode != "640" || info.Group != 50 {
+			t.Fatalf("unexpected file info: %+v", info)
+		}
+	})
+
+	t.Run("read returns the actual value", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/app.ini", content, clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newINIValueResource(newTestProvider(mock)), iniValueResourceModel{
+			Path:            types.StringValue("/etc/app.ini"),
+			Section:         types.StringValue("cache"),
+			Key:             types.StringValue("size"),
+			Value:           types.StringValue("20"),
+			NoExtraSpaces:   types.BoolValue(true),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Value.ValueString() != "10" {
+			t.Fatalf("unexpected value: %s", state.Value)
+		}
+	})
+
+	t.Run("delete removes the key with remove_on_destroy", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/app.ini", content, clients.FileInfo{Mode: "644"})
+
+		// Act
+		diags := testResourceDelete(t, newINIValueResource(newTestProvider(mock)), iniValueResourceModel{
+			Path:            types.StringValue("/etc/app.ini"),
+			Section:         types.StringValue(""),
+			Key:             types.StringValue("user"),
+			Value:           types.StringValue("admin"),
+			NoExtraSpaces:   types.BoolValue(false),
+			Create:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(true),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/app.ini"] != strings.Replace(content, "user = admin\n", "", 1) {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/app.ini"])
+		}
+	})
+}
+
+func TestSetINIKey(t *testing.T) {
+	lines := []string{"a = 1", "", "[first]", "b = 2", "", "[second]", "c = 3"}
+
+	tests := []struct {
+		name     string
+		section  string
+		key      string
+		expected []string
+	}{
+		{
+			name:     "adds a key to the global section",
+			section:  "",
+			key:      "x",
+			expected: []string{"a = 1", "x = 0", "", "[first]", "b = 2", "", "[second]", "c = 3"},
+		},
+		{
+			name:     "adds a key before the blank lines of its section",
+			section:  "first",
+			key:      "x",
+			expected: []string{"a = 1", "", "[first]", "b = 2", "x = 0", "", "[second]", "c = 3"},
+		},
+		{
+			name:     "adds a missing section at the end",
+			section:  "third",
+			key:      "x",
+			expected: []string{"a = 1", "", "[first]", "b = 2", "", "[second]", "c = 3", "", "[third]", "x = 0"},
+		},
+		{
+			name:     "replaces the key of the section",
+			section:  "second",
+			key:      "c",
+			expected: []string{"a = 1", "", "[first]", "b = 2", "", "[second]", "c = 0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			edited, changed := setINIKey(lines, test.section, test.key, test.key+" = 0")
+
+			// Assert
+			if !changed || strings.Join(edited, "\n") != strings.Join(test.expected, "\n") {
+				t.Fatalf("unexpected result: %q", edited)
+			}
+		})
+	}
+}
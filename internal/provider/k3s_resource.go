@@ -0,0 +1,547 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &k3sResource{}
+var _ resource.ResourceWithModifyPlan = &k3sResource{}
+
+const (
+	k3sInstallScriptURL = "https://get.k3s.io"
+	k3sKubeconfig       = "/etc/rancher/k3s/k3s.yaml"
+	k3sNodeToken        = "/var/lib/rancher/k3s/server/node-token"
+	k3sLocalServer      = "https://127.0.0.1:6443"
+)
+
+var (
+	k3sVersionPattern       = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+\+k3s[0-9]+$`)
+	k3sVersionOutputPattern = regexp.MustCompile(`k3s version (\S+)`)
+	k3sArgumentPattern      = regexp.MustCompile(`^\S+$`)
+)
+
+func newK3sResource(p *internalProvider) resource.Resource {
+	return &k3sResource{
+		provider: p,
+	}
+}
+
+// k3sResource defines the resource implementation.
+type k3sResource struct {
+	provider *internalProvider
+}
+
+type k3sResourceModel struct {
+	Role             types.String   `tfsdk:"role"`
+	Version          types.String   `tfsdk:"version"`
+	Channel          types.String   `tfsdk:"channel"`
+	ServerURL        types.String   `tfsdk:"server_url"`
+	Token            types.String   `tfsdk:"token"`
+	ClusterInit      types.Bool     `tfsdk:"cluster_init"`
+	ExtraArgs        types.List     `tfsdk:"extra_args"`
+	InstallScriptURL types.String   `tfsdk:"install_script_url"`
+	KubeconfigServer types.String   `tfsdk:"kubeconfig_server"`
+	Kubeconfig       types.String   `tfsdk:"kubeconfig"`
+	NodeToken        types.String   `tfsdk:"node_token"`
+	Timeouts         *timeoutsModel `tfsdk:"timeouts"`
+	Retry            *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *k3sResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_k3s"
+}
+
+func (r *k3sResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "K3s resource that installs k3s with its install script, either as a server, which starts a " +
+			"new cluster or joins an existing one, or as an agent joining a cluster. The install script runs again to " +
+			"upgrade k3s when the version, the channel or the arguments change. The kubeconfig and the node token of " +
+			"the servers are exposed, so that the agents and the other providers can use them. Destroying the resource " +
+			"runs the uninstall script of k3s, which removes the node and its data",
+
+		Attributes: map[string]schema.Attribute{
+			"role": schema.StringAttribute{
+				Required:    true,
+				Description: "The role of the node, 'server' or 'agent'",
+				Validators:  []validator.String{oneOf("server", "agent")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"version": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The version of k3s, e.g. 'v1.30.4+k3s1'. If not specified, the latest version of the channel is installed, and kept until the version is set",
+				Validators:  []validator.String{k3sVersion()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"channel": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("stable"),
+				Description: "The release channel the version is chosen from when it is not specified, e.g. 'stable', 'latest' or 'v1.30'. Defaults to 'stable'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"server_url": schema.StringAttribute{
+				Optional:    true,
+				Description: "The URL of a server of the cluster to join, e.g. 'https://10.0.0.1:6443'. Required for the agents. A server without it starts a new cluster",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"token": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "The secret joining the nodes to the cluster, e.g. the node_token of the first server. Required for the agents. If not specified for the first server, k3s generates it",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"cluster_init": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the first server starts the cluster with embedded etcd, which the other servers can join for high availability, rather than with SQLite. Defaults to false",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"extra_args": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The other flags of k3s server or k3s agent, e.g. '--disable=traefik' or '--node-label=zone=a'",
+				Validators:  []validator.List{listOf(k3sArgument())},
+			},
+			"install_script_url": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(k3sInstallScriptURL),
+				Description: "The URL of the install script, e.g. a mirror of it. Defaults to '" + k3sInstallScriptURL + "'",
+			},
+			"kubeconfig_server": schema.StringAttribute{
+				Optional:    true,
+				Description: "The URL of the API server written in kubeconfig, e.g. 'https://10.0.0.1:6443', as the kubeconfig of k3s points to " + k3sLocalServer,
+			},
+			"kubeconfig": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The kubeconfig of the cluster admin, only set for the servers",
+			},
+			"node_token": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The token joining the other nodes to the cluster, only set for the servers",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *k3sResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan checks the attributes needed by the role of the node, and plans the version of a new channel.
+func (r *k3sResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan k3sResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Role.ValueString() == "agent" {
+		if plan.ServerURL.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("server_url"), "Missing server_url", "the agents need the URL of a server of the cluster")
+		}
+
+		if plan.Token.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("token"), "Missing token", "the agents need the token of the cluster")
+		}
+
+		if plan.ClusterInit.ValueBool() {
+			resp.Diagnostics.AddAttributeError(path.Root("cluster_init"), "Invalid cluster_init", "only the first server starts the cluster")
+		}
+	}
+
+	if plan.ClusterInit.ValueBool() && !plan.ServerURL.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster_init"), "Invalid cluster_init", "a server joining the cluster of server_url does not start it")
+	}
+
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	var state k3sResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var configVersion types.String
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("version"), &configVersion)...)
+
+	// Without a version, the installed one is kept until the channel changes, which installs its latest version
+	if configVersion.IsNull() && !plan.Channel.Equal(state.Channel) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("version"), types.StringUnknown())...)
+	}
+}
+
+func (r *k3sResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan k3sResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install k3s", err.Error())
+		return
+	}
+
+	r.refresh(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *k3sResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model k3sResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The install script writes the uninstall script, test exits with 1 when it does not exist. The other errors, e.g. a
+	// lost connection, must not remove the node from the state, as k3s would be installed again on the next apply
+	_, err := r.provider.machineAccessClient.Run(ctx, clients.ShellCommand("test", "-f", k3sUninstallScript(model.Role.ValueString())))
+	if clients.IsExitCode(err, 1) {
+		// k3s was removed outside of terraform, it must be installed again
+		tflog.Debug(ctx, "k3s is not installed: "+err.Error())
+		resp.State.RemoveResource(ctx)
+
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to check the k3s installation", err.Error())
+		return
+	}
+
+	r.refresh(ctx, &model, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *k3sResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan k3sResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state k3sResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	if !plan.Version.Equal(state.Version) || !plan.Channel.Equal(state.Channel) || !plan.ExtraArgs.Equal(state.ExtraArgs) ||
+		!plan.InstallScriptURL.Equal(state.InstallScriptURL) {
+		err = r.install(ctx, plan)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to install k3s", err.Error())
+			return
+		}
+	}
+
+	r.refresh(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *k3sResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model k3sResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	script := k3sUninstallScript(model.Role.ValueString())
+
+	// The script is missing when k3s was already uninstalled
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo("if [ -x "+script+" ]; then "+script+"; fi"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to uninstall k3s", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// install downloads the install script of k3s and runs it with the settings of the model. The script installs or
+// upgrades k3s, and restarts it when its configuration changed.
+func (r *k3sResource) install(ctx context.Context, model k3sResourceModel) error {
+	var extraArgs []string
+
+	if diags := model.ExtraArgs.ElementsAs(ctx, &extraArgs, false); diags.HasError() {
+		return fmt.Errorf("failed to read extra_args: %v", diags)
+	}
+
+	ctx = clients.WithSensitiveValues(ctx, model.Token.ValueString())
+
+	tmpFile, err := r.provider.machineAccessClient.RunCommand(ctx, "mktemp")
+	if err != nil {
+		return fmt.Errorf("failed to create remote temp file. Err=%w\nout = %s", err, tmpFile)
+	}
+
+	tmpFile = strings.TrimSpace(tmpFile)
+
+	defer func() {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-f", tmpFile))
+	}()
+
+	out, err := retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(ctx, r.provider.withProxy(fetchCommand(model.InstallScriptURL.ValueString(), tmpFile)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download %s. Err=%w\nout = %s", model.InstallScriptURL.ValueString(), err, out)
+	}
+
+	exec := []string{model.Role.ValueString()}
+	if model.ClusterInit.ValueBool() {
+		exec = append(exec, "--cluster-init")
+	}
+
+	environment := []string{"INSTALL_K3S_EXEC=" + clients.ShellQuote(strings.Join(append(exec, extraArgs...), " "))}
+
+	if !model.Version.IsNull() && !model.Version.IsUnknown() {
+		environment = append(environment, "INSTALL_K3S_VERSION="+clients.ShellQuote(model.Version.ValueString()))
+	} else {
+		environment = append(environment, "INSTALL_K3S_CHANNEL="+clients.ShellQuote(model.Channel.ValueString()))
+	}
+
+	if !model.ServerURL.IsNull() {
+		environment = append(environment, "K3S_URL="+clients.ShellQuote(model.ServerURL.ValueString()))
+	}
+
+	if !model.Token.IsNull() {
+		environment = append(environment, "K3S_TOKEN="+clients.ShellQuote(model.Token.ValueString()))
+	}
+
+	// The install script downloads k3s, and copies the proxy variables to the environment of the service
+	command := r.provider.sudo(r.provider.withProxy(strings.Join(environment, " ") + " " + clients.ShellCommand("sh", tmpFile)))
+
+	out, err = retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), command)
+	})
+	if err != nil {
+		return fmt.Errorf("the install script failed. Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// refresh sets the version, and for the servers the kubeconfig and the node token, from the machine.
+func (r *k3sResource) refresh(ctx context.Context, model *k3sResourceModel, diags *diag.Diagnostics) {
+	version, err := r.installedVersion(ctx)
+	if err != nil {
+		diags.AddError("Failed to get the installed k3s version", err.Error())
+		return
+	}
+
+	model.Version = types.StringValue(version)
+
+	if model.Role.ValueString() != "server" {
+		model.Kubeconfig = types.StringNull()
+		model.NodeToken = types.StringNull()
+
+		return
+	}
+
+	kubeconfig, err := r.provider.machineAccessClient.ReadFile(ctx, k3sKubeconfig, true)
+	if err != nil {
+		diags.AddError("Failed to read the k3s kubeconfig", err.Error())
+		return
+	}
+
+	if !model.KubeconfigServer.IsNull() {
+		kubeconfig = strings.ReplaceAll(kubeconfig, "server: "+k3sLocalServer, "server: "+model.KubeconfigServer.ValueString())
+	}
+
+	nodeToken, err := r.provider.machineAccessClient.ReadFile(ctx, k3sNodeToken, true)
+	if err != nil {
+		diags.AddError("Failed to read the k3s node token", err.Error())
+		return
+	}
+
+	model.Kubeconfig = types.StringValue(kubeconfig)
+	model.NodeToken = types.StringValue(strings.TrimSpace(nodeToken))
+}
+
+// installedVersion returns the version of the k3s binary, e.g. v1.30.4+k3s1.
+// k3sUninstallScript returns the uninstall script written by the install script for the role.
+func k3sUninstallScript(role string) string {
+	if role == "agent" {
+		return "/usr/local/bin/k3s-agent-uninstall.sh"
+	}
+
+	return "/usr/local/bin/k3s-uninstall.sh"
+}
+
+func (r *k3sResource) installedVersion(ctx context.Context) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "k3s --version")
+	if err != nil {
+		return "", fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	version := k3sVersionOutputPattern.FindStringSubmatch(out)
+	if version == nil {
+		return "", fmt.Errorf("unexpected output of k3s --version: %s", out)
+	}
+
+	return version[1], nil
+}
+
+// k3sVersion validates the versions of k3s, e.g. 'v1.30.4+k3s1'.
+func k3sVersion() validator.String {
+	return stringValidator{
+		description: "value must be a version of k3s, e.g. 'v1.30.4+k3s1'",
+		check: func(value string) string {
+			if !k3sVersionPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a version of k3s, e.g. 'v1.30.4+k3s1'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// k3sArgument validates the flags of k3s, which the install script splits on the spaces.
+func k3sArgument() validator.String {
+	return stringValidator{
+		description: "value must be a flag of k3s without spaces, e.g. '--disable=traefik'",
+		check: func(value string) string {
+			if !k3sArgumentPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a flag of k3s without spaces, e.g. '--disable=traefik'", value)
+			}
+
+			return ""
+		},
+	}
+}
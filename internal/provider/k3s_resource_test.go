@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestK3sResourceWithMock(t *testing.T) {
+	t.Run("create installs the first server and exposes its kubeconfig and node token", func(t *testing.T) {
+		// Arrange
+		mock := testK3sMock().
+			WithFile("/etc/rancher/k3s/k3s.yaml", "clusters:\n- cluster:\n    server: https://127.0.0.1:6443\n", clients.FileInfo{Mode: "600"}).
+			WithFile("/var/lib/rancher/k3s/server/node-token", "K10abc::server:secret\n", clients.FileInfo{Mode: "600"})
+
+		model := testK3sModel()
+		model.ClusterInit = types.BoolValue(true)
+		model.ExtraArgs = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("--disable=traefik")})
+		model.KubeconfigServer = types.StringValue("https://10.0.0.1:6443")
+
+		// Act
+		state, diags := testResourceCreate(t, newK3sResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !testK3sCommandRan(mock, "INSTALL_K3S_EXEC='server --cluster-init --disable=traefik' INSTALL_K3S_CHANNEL='stable' sh /tmp/tmp.k3s") {
+			t.Fatalf("expected the install script to be run, got: %v", mock.Commands)
+		}
+
+		if state.Version.ValueString() != "v1.30.4+k3s1" || state.NodeToken.ValueString() != "K10abc::server:secret" {
+			t.Fatalf("unexpected state: %s, %s", state.Version.ValueString(), state.NodeToken.ValueString())
+		}
+
+		if !strings.Contains(state.Kubeconfig.ValueString(), "server: https://10.0.0.1:6443") {
+			t.Fatalf("unexpected kubeconfig:\n%s", state.Kubeconfig.ValueString())
+		}
+	})
+
+	t.Run("create joins an agent to the server", func(t *testing.T) {
+		// Arrange
+		mock := testK3sMock()
+
+		model := testK3sModel()
+		model.Role = types.StringValue("agent")
+		model.Version = types.StringValue("v1.30.4+k3s1")
+		model.ServerURL = types.StringValue("https://10.0.0.1:6443")
+		model.Token = types.StringValue("K10abc::server:secret")
+
+		// Act
+		state, diags := testResourceCreate(t, newK3sResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !testK3sCommandRan(mock, "INSTALL_K3S_EXEC='agent' INSTALL_K3S_VERSION='v1.30.4+k3s1' K3S_URL='https://10.0.0.1:6443' K3S_TOKEN='K10abc::server:secret' sh") {
+			t.Fatalf("expected the agent to join the server, got: %v", mock.Commands)
+		}
+
+		if !state.Kubeconfig.IsNull() || !state.NodeToken.IsNull() {
+			t.Fatal("the agents have no kubeconfig nor node token")
+		}
+	})
+
+	t.Run("plan requires the server and the token of the agents", func(t *testing.T) {
+		// Arrange
+		plan := testK3sModel()
+		plan.Role = types.StringValue("agent")
+		plan.Version = types.StringNull()
+		plan.Kubeconfig = types.StringNull()
+		plan.NodeToken = types.StringNull()
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newK3sResource(newTestProvider(clients.NewMockMachineAccessClient())).(*k3sResource), nil, plan)
+
+		// Assert
+		if diags.ErrorsCount() != 2 {
+			t.Fatalf("expected the missing server_url and token, got: %v", diags)
+		}
+	})
+
+	t.Run("read removes the resource when k3s is not installed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("test -f /usr/local/bin/k3s-uninstall.sh", clients.MockResponse{ExitCode: 1})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newK3sResource(newTestProvider(mock)), testK3sModel())
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed, got: %v", diags)
+		}
+	})
+
+	t.Run("read keeps the resource when the installation cannot be checked", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("test -f /usr/local/bin/k3s-uninstall.sh", clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newK3sResource(newTestProvider(mock)), testK3sModel())
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func testK3sMock() *clients.MockMachineAccessClient {
+	return clients.NewMockMachineAccessClient().
+		On("mktemp", clients.MockResponse{Stdout: "/tmp/tmp.k3s\n"}).
+		On("k3s --version", clients.MockResponse{Stdout: "k3s version v1.30.4+k3s1 (98262b5d)\ngo version go1.22.5\n"})
+}
+
+func testK3sModel() k3sResourceModel {
+	return k3sResourceModel{
+		Role:             types.StringValue("server"),
+		Version:          types.StringUnknown(),
+		Channel:          types.StringValue("stable"),
+		ServerURL:        types.StringNull(),
+		Token:            types.StringNull(),
+		ClusterInit:      types.BoolValue(false),
+		ExtraArgs:        types.ListNull(types.StringType),
+		InstallScriptURL: types.StringValue(k3sInstallScriptURL),
+		KubeconfigServer: types.StringNull(),
+		Kubeconfig:       types.StringUnknown(),
+		NodeToken:        types.StringUnknown(),
+	}
+}
+
+func testK3sCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,526 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &kubeadmResource{}
+var _ resource.ResourceWithModifyPlan = &kubeadmResource{}
+
+const (
+	kubeadmAdminKubeconfig   = "/etc/kubernetes/admin.conf"
+	kubeadmKubeletKubeconfig = "/etc/kubernetes/kubelet.conf"
+	kubeadmCACertificate     = "/etc/kubernetes/pki/ca.crt"
+)
+
+var (
+	kubeadmTokenPattern          = regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`)
+	kubeadmCACertHashPattern     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	kubeadmCertificateKeyPattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+func newKubeadmResource(p *internalProvider) resource.Resource {
+	return &kubeadmResource{
+		provider: p,
+	}
+}
+
+// kubeadmResource defines the resource implementation.
+type kubeadmResource struct {
+	provider *internalProvider
+}
+
+type kubeadmResourceModel struct {
+	Role                     types.String   `tfsdk:"role"`
+	ControlPlaneEndpoint     types.String   `tfsdk:"control_plane_endpoint"`
+	KubernetesVersion        types.String   `tfsdk:"kubernetes_version"`
+	PodNetworkCIDR           types.String   `tfsdk:"pod_network_cidr"`
+	Token                    types.String   `tfsdk:"token"`
+	DiscoveryTokenCACertHash types.String   `tfsdk:"discovery_token_ca_cert_hash"`
+	CertificateKey           types.String   `tfsdk:"certificate_key"`
+	ExtraArgs                types.List     `tfsdk:"extra_args"`
+	Kubeconfig               types.String   `tfsdk:"kubeconfig"`
+	Timeouts                 *timeoutsModel `tfsdk:"timeouts"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *kubeadmResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_kubeadm"
+}
+
+func (r *kubeadmResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Kubeadm resource that bootstraps a Kubernetes cluster with kubeadm init, or joins the " +
+			"machine to a cluster with kubeadm join, as a worker or as another control plane node. kubeadm, the kubelet " +
+			"and a container runtime must already be installed, e.g. with the apt_packages resource. The token and the " +
+			"CA certificate hash of the first node are exposed, so that the other nodes can join it. Any change " +
+			"replaces the node. Destroying the resource runs kubeadm reset, the node is not deleted from the cluster",
+
+		Attributes: map[string]schema.Attribute{
+			"role": schema.StringAttribute{
+				Required:    true,
+				Description: "'init' to bootstrap the cluster on its first control plane node, 'worker' to join it as a worker, or 'control-plane' to join it as another control plane node",
+				Validators:  []validator.String{oneOf("init", "worker", "control-plane")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"control_plane_endpoint": schema.StringAttribute{
+				Optional:    true,
+				Description: "The address and port of the API server, e.g. '10.0.0.1:6443' or the one of a load balancer. Required to join a cluster. For init, it is needed to add other control plane nodes later",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"kubernetes_version": schema.StringAttribute{
+				Optional:    true,
+				Description: "The version of the control plane for init, e.g. 'v1.30.4'. Defaults to the version of kubeadm",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"pod_network_cidr": schema.StringAttribute{
+				Optional:    true,
+				Description: "The range of the pod IP addresses for init, as expected by the network add-on, e.g. '10.244.0.0/16' for flannel",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"token": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The bootstrap token the nodes join with, e.g. 'abcdef.0123456789abcdef'. Required to join a cluster. If not specified for init, it is generated with kubeadm token generate. The token expires after 24 hours unless init gets '--token-ttl=0' in extra_args",
+				Validators:  []validator.String{kubeadmToken()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"discovery_token_ca_cert_hash": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The hash of the public key of the cluster CA, e.g. 'sha256:...', which the joining nodes check the API server with. Required to join a cluster. Set from the CA of the cluster for init",
+				Validators:  []validator.String{kubeadmCACertHash()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"certificate_key": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "The key encrypting the control plane certificates shared in the cluster, 64 hexadecimal characters, e.g. generated with kubeadm certs certificate-key. Required to join as a control plane node. For init, the certificates are uploaded with it",
+				Validators:  []validator.String{kubeadmCertificateKey()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"extra_args": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The other flags of kubeadm init or kubeadm join, e.g. '--cri-socket=unix:///run/containerd/containerd.sock'",
+				Validators:  []validator.List{listOf(notBlank())},
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.RequiresReplace(),
+				},
+			},
+			"kubeconfig": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The kubeconfig of the cluster admin, only set for the control plane nodes",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+		},
+	}
+}
+
+func (r *kubeadmResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan checks the attributes needed by the role of the node.
+func (r *kubeadmResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan kubeadmResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var config kubeadmResourceModel
+
+	diags = req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Role.ValueString() == "init" {
+		if !config.DiscoveryTokenCACertHash.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("discovery_token_ca_cert_hash"), "Invalid discovery_token_ca_cert_hash", "the hash is set from the CA created by init")
+		}
+
+		return
+	}
+
+	required := []string{"control_plane_endpoint", "token", "discovery_token_ca_cert_hash"}
+	if plan.Role.ValueString() == "control-plane" {
+		required = append(required, "certificate_key")
+	}
+
+	values := map[string]types.String{
+		"control_plane_endpoint":       config.ControlPlaneEndpoint,
+		"token":                        config.Token,
+		"discovery_token_ca_cert_hash": config.DiscoveryTokenCACertHash,
+		"certificate_key":              config.CertificateKey,
+		"kubernetes_version":           config.KubernetesVersion,
+		"pod_network_cidr":             config.PodNetworkCIDR,
+	}
+
+	for _, name := range required {
+		if values[name].IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root(name), "Missing "+name, "joining a cluster as "+plan.Role.ValueString()+" needs "+name)
+		}
+	}
+
+	for _, name := range []string{"kubernetes_version", "pod_network_cidr"} {
+		if !values[name].IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root(name), "Invalid "+name, name+" is only used by init, the joining nodes get the settings of the cluster")
+		}
+	}
+}
+
+func (r *kubeadmResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan kubeadmResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	if plan.Token.IsUnknown() {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("kubeadm token generate"))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to generate the bootstrap token", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+
+		plan.Token = types.StringValue(strings.TrimSpace(out))
+	}
+
+	command, diags := plan.command(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx = clients.WithSensitiveValues(ctx, plan.Token.ValueString(), plan.CertificateKey.ValueString())
+
+	// kubeadm pulls the images of the control plane
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(command)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to run kubeadm "+plan.subcommand(), fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	r.refresh(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *kubeadmResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model kubeadmResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// test exits with 1 when the kubeconfig does not exist. The other errors, e.g. a lost connection, must not remove
+	// the node from the state, as it would join the cluster again on the next apply
+	_, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("test", "-f", kubeadmKubeletKubeconfig)))
+	if clients.IsExitCode(err, 1) {
+		// The node was reset outside of terraform, it must join the cluster again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to check the kubelet kubeconfig", err.Error())
+		return
+	}
+
+	r.refresh(ctx, &model, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *kubeadmResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan kubeadmResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Every setting of the node replaces it, only the timeouts are updated
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *kubeadmResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model kubeadmResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	// kubeadm reset leaves the CNI configuration and the iptables rules behind, as it does not know the network add-on
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo("kubeadm reset -f"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to run kubeadm reset", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// refresh sets the kubeconfig of the control plane nodes, and the CA certificate hash of the first one, from the
+// machine.
+func (r *kubeadmResource) refresh(ctx context.Context, model *kubeadmResourceModel, diags *diag.Diagnostics) {
+	if model.Role.ValueString() == "worker" {
+		model.Kubeconfig = types.StringNull()
+		return
+	}
+
+	kubeconfig, err := r.provider.machineAccessClient.ReadFile(ctx, kubeadmAdminKubeconfig, true)
+	if err != nil {
+		diags.AddError("Failed to read the kubeconfig", err.Error())
+		return
+	}
+
+	model.Kubeconfig = types.StringValue(kubeconfig)
+
+	if model.Role.ValueString() != "init" {
+		return
+	}
+
+	certificate, err := r.provider.machineAccessClient.ReadFile(ctx, kubeadmCACertificate, true)
+	if err != nil {
+		diags.AddError("Failed to read the cluster CA", err.Error())
+		return
+	}
+
+	hash, err := kubeadmCACertHashOf(certificate)
+	if err != nil {
+		diags.AddError("Failed to compute the CA certificate hash", err.Error())
+		return
+	}
+
+	model.DiscoveryTokenCACertHash = types.StringValue(hash)
+}
+
+// subcommand returns the kubeadm subcommand of the role.
+func (model kubeadmResourceModel) subcommand() string {
+	if model.Role.ValueString() == "init" {
+		return "init"
+	}
+
+	return "join"
+}
+
+// command returns the kubeadm init or kubeadm join command of the model.
+func (model kubeadmResourceModel) command(ctx context.Context) (string, diag.Diagnostics) {
+	var extraArgs []string
+
+	diags := model.ExtraArgs.ElementsAs(ctx, &extraArgs, false)
+	if diags.HasError() {
+		return "", diags
+	}
+
+	args := []string{model.subcommand()}
+
+	if model.Role.ValueString() == "init" {
+		args = append(args, "--token", model.Token.ValueString())
+
+		if !model.ControlPlaneEndpoint.IsNull() {
+			args = append(args, "--control-plane-endpoint", model.ControlPlaneEndpoint.ValueString())
+		}
+
+		if !model.KubernetesVersion.IsNull() {
+			args = append(args, "--kubernetes-version", model.KubernetesVersion.ValueString())
+		}
+
+		if !model.PodNetworkCIDR.IsNull() {
+			args = append(args, "--pod-network-cidr", model.PodNetworkCIDR.ValueString())
+		}
+
+		if !model.CertificateKey.IsNull() {
+			args = append(args, "--upload-certs", "--certificate-key", model.CertificateKey.ValueString())
+		}
+	} else {
+		args = append(args, model.ControlPlaneEndpoint.ValueString(), "--token", model.Token.ValueString(),
+			"--discovery-token-ca-cert-hash", model.DiscoveryTokenCACertHash.ValueString())
+
+		if model.Role.ValueString() == "control-plane" {
+			args = append(args, "--control-plane", "--certificate-key", model.CertificateKey.ValueString())
+		}
+	}
+
+	return clients.ShellCommand("kubeadm", append(args, extraArgs...)...), nil
+}
+
+// kubeadmCACertHashOf returns the hash kubeadm join checks the CA with, the sha256 of the public key of the certificate.
+func kubeadmCACertHashOf(certificate string) (string, error) {
+	block, _ := pem.Decode([]byte(certificate))
+	if block == nil {
+		return "", fmt.Errorf("the CA certificate is not PEM encoded")
+	}
+
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the CA certificate: %w", err)
+	}
+
+	sum := sha256.Sum256(parsed.RawSubjectPublicKeyInfo)
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// kubeadmToken validates the bootstrap tokens of kubeadm, e.g. 'abcdef.0123456789abcdef'.
+func kubeadmToken() validator.String {
+	return stringValidator{
+		description: "value must be a bootstrap token, e.g. 'abcdef.0123456789abcdef'",
+		check: func(value string) string {
+			if !kubeadmTokenPattern.MatchString(value) {
+				return "the value is not a bootstrap token, e.g. 'abcdef.0123456789abcdef'"
+			}
+
+			return ""
+		},
+	}
+}
+
+// kubeadmCACertHash validates the CA certificate hashes of kubeadm join, e.g. 'sha256:...'.
+func kubeadmCACertHash() validator.String {
+	return stringValidator{
+		description: "value must be the sha256 hash of a CA public key, e.g. 'sha256:...'",
+		check: func(value string) string {
+			if !kubeadmCACertHashPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not the sha256 hash of a CA public key, e.g. 'sha256:...'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// kubeadmCertificateKey validates the keys of the certificates shared between the control plane nodes.
+func kubeadmCertificateKey() validator.String {
+	return stringValidator{
+		description: "value must be 64 hexadecimal characters",
+		check: func(value string) string {
+			if !kubeadmCertificateKeyPattern.MatchString(value) {
+				return "the value is not 64 hexadecimal characters"
+			}
+
+			return ""
+		},
+	}
+}
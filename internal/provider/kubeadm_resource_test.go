@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestKubeadmResourceWithMock(t *testing.T) {
+	t.Run("create bootstraps the cluster and exposes how to join it", func(t *testing.T) {
+		// Arrange
+		certificate, _ := testSelfSignedCertificate(t, time.Now().Add(time.Hour))
+
+		mock := clients.NewMockMachineAccessClient().
+			On("kubeadm token generate", clients.MockResponse{Stdout: "abcdef.0123456789abcdef\n"}).
+			WithFile("/etc/kubernetes/admin.conf", "apiVersion: v1\nkind: Config\n", clients.FileInfo{Mode: "600"}).
+			WithFile("/etc/kubernetes/pki/ca.crt", certificate, clients.FileInfo{Mode: "644"})
+
+		model := testKubeadmModel()
+		model.ControlPlaneEndpoint = types.StringValue("10.0.0.1:6443")
+		model.PodNetworkCIDR = types.StringValue("10.244.0.0/16")
+
+		// Act
+		state, diags := testResourceCreate(t, newKubeadmResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !testKubeadmCommandRan(mock, "kubeadm init --token abcdef.0123456789abcdef --control-plane-endpoint 10.0.0.1:6443 --pod-network-cidr 10.244.0.0/16") {
+			t.Fatalf("expected kubeadm init to be run, got: %v", mock.Commands)
+		}
+
+		block, _ := pem.Decode([]byte(certificate))
+		parsed, _ := x509.ParseCertificate(block.Bytes)
+		publicKey, _ := x509.MarshalPKIXPublicKey(parsed.PublicKey)
+		sum := sha256.Sum256(publicKey)
+
+		if state.DiscoveryTokenCACertHash.ValueString() != "sha256:"+hex.EncodeToString(sum[:]) {
+			t.Fatalf("unexpected CA certificate hash: %s", state.DiscoveryTokenCACertHash.ValueString())
+		}
+
+		if state.Token.ValueString() != "abcdef.0123456789abcdef" || state.Kubeconfig.ValueString() != "apiVersion: v1\nkind: Config\n" {
+			t.Fatalf("unexpected state: %s, %q", state.Token.ValueString(), state.Kubeconfig.ValueString())
+		}
+	})
+
+	t.Run("create joins a worker without kubeconfig", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		model := testKubeadmJoinModel()
+
+		// Act
+		state, diags := testResourceCreate(t, newKubeadmResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 || mock.Commands[0] != "kubeadm join 10.0.0.1:6443 --token abcdef.0123456789abcdef --discovery-token-ca-cert-hash sha256:"+strings.Repeat("a", 64) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if !state.Kubeconfig.IsNull() {
+			t.Fatal("the workers have no kubeconfig")
+		}
+	})
+
+	t.Run("plan requires the certificate key of the control plane nodes joining", func(t *testing.T) {
+		// Arrange
+		plan := testKubeadmJoinModel()
+		plan.Role = types.StringValue("control-plane")
+		plan.Kubeconfig = types.StringNull()
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newKubeadmResource(newTestProvider(clients.NewMockMachineAccessClient())).(*kubeadmResource), nil, plan)
+
+		// Assert
+		if diags.ErrorsCount() != 1 || !strings.Contains(diags.Errors()[0].Detail(), "certificate_key") {
+			t.Fatalf("expected the missing certificate_key, got: %v", diags)
+		}
+	})
+
+	t.Run("read removes the resource when the node was reset", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`test -f /etc/kubernetes/kubelet.conf`, clients.MockResponse{ExitCode: 1})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newKubeadmResource(newTestProvider(mock)), testKubeadmJoinModel())
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed, got: %v", diags)
+		}
+	})
+
+	t.Run("read keeps the resource when the kubeconfig cannot be checked", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`test -f /etc/kubernetes/kubelet.conf`, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newKubeadmResource(newTestProvider(mock)), testKubeadmJoinModel())
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func testKubeadmModel() kubeadmResourceModel {
+	return kubeadmResourceModel{
+		Role:                     types.StringValue("init"),
+		ControlPlaneEndpoint:     types.StringNull(),
+		KubernetesVersion:        types.StringNull(),
+		PodNetworkCIDR:           types.StringNull(),
+		Token:                    types.StringUnknown(),
+		DiscoveryTokenCACertHash: types.StringUnknown(),
+		CertificateKey:           types.StringNull(),
+		ExtraArgs:                types.ListNull(types.StringType),
+		Kubeconfig:               types.StringUnknown(),
+	}
+}
+
+func testKubeadmJoinModel() kubeadmResourceModel {
+	model := testKubeadmModel()
+	model.Role = types.StringValue("worker")
+	model.ControlPlaneEndpoint = types.StringValue("10.0.0.1:6443")
+	model.Token = types.StringValue("abcdef.0123456789abcdef")
+	model.DiscoveryTokenCACertHash = types.StringValue("sha256:" + strings.Repeat("a", 64))
+
+	return model
+}
+
+func testKubeadmCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,332 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &limitsResource{}
+
+const defaultLimitsPath = "/etc/security/limits.d/90-terraform.conf"
+
+var (
+	// limitItems are the items of limits.conf(5)
+	limitItems = []string{
+		"core", "data", "fsize", "memlock", "nofile", "rss", "stack", "cpu", "nproc", "as", "maxlogins",
+		"maxsyslogins", "nonewprivs", "priority", "locks", "sigpending", "msgqueue", "nice", "rtprio",
+	}
+	limitDomainPattern = regexp.MustCompile(`^\S+$`)
+	limitValuePattern  = regexp.MustCompile(`^(-?[0-9]+|unlimited|infinity)$`)
+)
+
+func newLimitsResource(p *internalProvider) resource.Resource {
+	return &limitsResource{
+		provider: p,
+	}
+}
+
+// limitsResource defines the resource implementation.
+type limitsResource struct {
+	provider *internalProvider
+}
+
+type limitsResourceModel struct {
+	Path   types.String `tfsdk:"path"`
+	Domain types.String `tfsdk:"domain"`
+	Type   types.String `tfsdk:"type"`
+	Item   types.String `tfsdk:"item"`
+	Value  types.String `tfsdk:"value"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *limitsResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_limits"
+}
+
+func (r *limitsResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Limits resource that sets a PAM limit, i.e. a `domain type item value` line of a file of " +
+			"/etc/security/limits.d, see limits.conf(5). The file is created, with mode 644 and owned by root, when " +
+			"missing, and the other lines of the file are left as they are, so several limits can share a file. The " +
+			"limits apply to the sessions opened after the change",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(defaultLimitsPath),
+				Description: "The path of the limits file. Defaults to " + defaultLimitsPath,
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"domain": schema.StringAttribute{
+				Required:    true,
+				Description: "The user, '@group', '%group', uid or gid range, e.g. '1000:', or '*' the limit applies to",
+				Validators:  []validator.String{limitDomain()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"type": schema.StringAttribute{
+				Required:    true,
+				Description: "The type of the limit: 'soft', 'hard', or '-' for both",
+				Validators:  []validator.String{oneOf("soft", "hard", "-")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"item": schema.StringAttribute{
+				Required:    true,
+				Description: "The limited resource, e.g. 'nofile' or 'memlock'",
+				Validators:  []validator.String{oneOf(limitItems...)},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				Required:    true,
+				Description: "The value of the limit, a number, 'unlimited' or 'infinity'",
+				Validators:  []validator.String{limitValue()},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *limitsResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *limitsResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan limitsResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set limit", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *limitsResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model limitsResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read limits file", err.Error())
+		return
+	}
+
+	found := false
+
+	for _, line := range lines {
+		value, ok := parseLimit(line, model)
+		if ok {
+			// The last occurrence wins, as with pam_limits
+			model.Value = types.StringValue(value)
+			found = true
+		}
+	}
+
+	if !found {
+		// The limit, or the file, was removed outside of terraform, it must be set again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *limitsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan limitsResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to set limit", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *limitsResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model limitsResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.edit(ctx, model.Path.ValueString(), func(lines []string) ([]string, bool) {
+		kept := []string{}
+
+		for _, line := range lines {
+			if _, ok := parseLimit(line, model); !ok {
+				kept = append(kept, line)
+			}
+		}
+
+		return kept, len(kept) != len(lines)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove limit", err.Error())
+		return
+	}
+}
+
+// apply replaces the first line of the limit and removes the others, or appends the limit when missing.
+func (r *limitsResource) apply(ctx context.Context, plan limitsResourceModel) error {
+	limit := strings.Join([]string{plan.Domain.ValueString(), plan.Type.ValueString(), plan.Item.ValueString(), plan.Value.ValueString()}, " ")
+
+	return r.edit(ctx, plan.Path.ValueString(), func(lines []string) ([]string, bool) {
+		edited := []string{}
+		found := false
+		changed := false
+
+		for _, line := range lines {
+			if _, ok := parseLimit(line, plan); !ok {
+				edited = append(edited, line)
+				continue
+			}
+
+			if found {
+				changed = true
+				continue
+			}
+
+			edited = append(edited, limit)
+			found = true
+			changed = changed || line != limit
+		}
+
+		if !found {
+			return append(edited, limit), true
+		}
+
+		return edited, changed
+	})
+}
+
+// edit rewrites the lines of the limits file with edit while holding the lock of the file. Unlike editFile, the file
+// is always written with mode 644 and owned by root, as pam_limits expects, and a missing file is created.
+func (r *limitsResource) edit(ctx context.Context, filePath string, edit func(lines []string) ([]string, bool)) error {
+	defer r.provider.lockFile(ctx, filePath)()
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	newLines, changed := edit(lines)
+	if !changed {
+		return nil
+	}
+
+	return r.provider.machineAccessClient.WriteFile(ctx, filePath, "644", "0", "0", joinLines(newLines))
+}
+
+// parseLimit returns the value of the line when it sets the limit of the domain, type and item of the model.
+func parseLimit(line string, model limitsResourceModel) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 || strings.HasPrefix(fields[0], "#") {
+		return "", false
+	}
+
+	if fields[0] != model.Domain.ValueString() || fields[1] != model.Type.ValueString() || fields[2] != model.Item.ValueString() {
+		return "", false
+	}
+
+	return fields[3], true
+}
+
+// limitDomain validates the domains of limits, which are a single word.
+func limitDomain() validator.String {
+	return stringValidator{
+		description: "value must be a user, '@group', '%group', a uid or gid range, or '*'",
+		check: func(value string) string {
+			if !limitDomainPattern.MatchString(value) || strings.HasPrefix(value, "#") {
+				return fmt.Sprintf("'%s' is not a user, '@group', '%%group', a uid or gid range, or '*'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// limitValue validates the values of limits.
+func limitValue() validator.String {
+	return stringValidator{
+		description: "value must be a number, 'unlimited' or 'infinity'",
+		check: func(value string) string {
+			if !limitValuePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a number, 'unlimited' or 'infinity'", value)
+			}
+
+			return ""
+		},
+	}
+}
@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestLimitsResource(t *testing.T) {
+	t.Run("Test set limits", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_limits" "nofile" {
+  path   = "/tmp/limits.conf"
+  domain = "elasticsearch"
+  type   = "-"
+  item   = "nofile"
+  value  = "65535"
+}
+
+resource "setup_limits" "memlock" {
+  path   = "/tmp/limits.conf"
+  domain = "elasticsearch"
+  type   = "-"
+  item   = "memlock"
+  value  = "unlimited"
+}
+`,
+					Check: func(_ *terraform.State) error {
+						content, err := sshClient.RunCommand(context.Background(), "sort /tmp/limits.conf")
+						if err != nil {
+							return fmt.Errorf("file not found")
+						}
+
+						if content != "elasticsearch - memlock unlimited\nelasticsearch - nofile 65535\n" {
+							return fmt.Errorf("unexpected content: %s", content)
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestLimitsResourceWithMock(t *testing.T) {
+	t.Run("create appends the limit to a new file owned by root", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newLimitsResource(newTestProvider(mock)), testLimitsModel("nofile", "65535"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[defaultLimitsPath] != "elasticsearch - nofile 65535\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultLimitsPath])
+		}
+
+		if info := mock.FileInfos[defaultLimitsPath]; info.Mode != "644" || info.Owner != 0 || info.Group != 0 {
+			t.Fatalf("unexpected file info: %+v", info)
+		}
+	})
+
+	t.Run("update replaces the limit and leaves the other lines", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(defaultLimitsPath, "# elasticsearch\nelasticsearch - nofile 1024\nelasticsearch soft nofile 512\nelasticsearch\t-\tnofile 2048\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceUpdate(t, newLimitsResource(newTestProvider(mock)), testLimitsModel("nofile", "1024"), testLimitsModel("nofile", "65535"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[defaultLimitsPath] != "# elasticsearch\nelasticsearch - nofile 65535\nelasticsearch soft nofile 512\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultLimitsPath])
+		}
+	})
+
+	t.Run("read detects a changed value", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(defaultLimitsPath, "elasticsearch - nofile 4096\n# elasticsearch - nofile 1\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newLimitsResource(newTestProvider(mock)), testLimitsModel("nofile", "65535"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if removed || state.Value.ValueString() != "4096" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read removes the resource when the limit is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(defaultLimitsPath, "elasticsearch soft nofile 4096\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newLimitsResource(newTestProvider(mock)), testLimitsModel("nofile", "65535"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete only removes the limit", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(defaultLimitsPath, "elasticsearch - nofile 65535\nelasticsearch - memlock unlimited\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		diags := testResourceDelete(t, newLimitsResource(newTestProvider(mock)), testLimitsModel("nofile", "65535"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[defaultLimitsPath] != "elasticsearch - memlock unlimited\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultLimitsPath])
+		}
+	})
+
+	t.Run("domains and values are validated", func(t *testing.T) {
+		for _, value := range []string{"*", "@admin", "%group", "1000:", "elasticsearch"} {
+			if resp := validateString(limitDomain(), types.StringValue(value)); resp.Diagnostics.HasError() {
+				t.Fatalf("expected %q to be valid: %v", value, resp.Diagnostics)
+			}
+		}
+
+		for _, value := range []string{"", "two words", "#comment"} {
+			if resp := validateString(limitDomain(), types.StringValue(value)); !resp.Diagnostics.HasError() {
+				t.Fatalf("expected %q to be invalid", value)
+			}
+		}
+
+		for _, value := range []string{"65535", "-20", "unlimited", "infinity"} {
+			if resp := validateString(limitValue(), types.StringValue(value)); resp.Diagnostics.HasError() {
+				t.Fatalf("expected %q to be valid: %v", value, resp.Diagnostics)
+			}
+		}
+
+		for _, value := range []string{"", "64k", "none"} {
+			if resp := validateString(limitValue(), types.StringValue(value)); !resp.Diagnostics.HasError() {
+				t.Fatalf("expected %q to be invalid", value)
+			}
+		}
+	})
+}
+
+func testLimitsModel(item string, value string) limitsResourceModel {
+	return limitsResourceModel{
+		Path:   types.StringValue(defaultLimitsPath),
+		Domain: types.StringValue("elasticsearch"),
+		Type:   types.StringValue("-"),
+		Item:   types.StringValue(item),
+		Value:  types.StringValue(value),
+	}
+}
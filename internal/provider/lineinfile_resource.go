@@ -0,0 +1,454 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	lineStatePresent = "present"
+	lineStateAbsent  = "absent"
+
+	lineAnchorBOF = "BOF"
+	lineAnchorEOF = "EOF"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &lineInFileResource{}
+var _ resource.ResourceWithModifyPlan = &lineInFileResource{}
+
+func newLineInFileResource(p *internalProvider) resource.Resource {
+	return &lineInFileResource{
+		provider: p,
+	}
+}
+
+// lineInFileResource defines the resource implementation.
+type lineInFileResource struct {
+	provider *internalProvider
+}
+
+type lineInFileResourceModel struct {
+	Path            types.String `tfsdk:"path"`
+	Line            types.String `tfsdk:"line"`
+	Regexp          types.String `tfsdk:"regexp"`
+	State           types.String `tfsdk:"state"`
+	InsertAfter     types.String `tfsdk:"insert_after"`
+	InsertBefore    types.String `tfsdk:"insert_before"`
+	Backup          types.Bool   `tfsdk:"backup"`
+	RemoveOnDestroy types.Bool   `tfsdk:"remove_on_destroy"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+// lineInFileSpec is the compiled form of the model, see editLines.
+type lineInFileSpec struct {
+	line         string
+	regexp       *regexp.Regexp
+	absent       bool
+	insertAfter  string
+	insertBefore string
+}
+
+func (r *lineInFileResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_lineinfile"
+}
+
+func (r *lineInFileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Line in file resource that ensures a line is present in, or absent from, an existing file, " +
+			"like the lineinfile module of Ansible. Destroying the resource leaves the file untouched, unless remove_on_destroy is set",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the file, which must exist",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"line": schema.StringAttribute{
+				Optional:    true,
+				Description: "The line to ensure present, or absent. Required when state is present",
+			},
+			"regexp": schema.StringAttribute{
+				Optional: true,
+				Description: "A regular expression matching the line to manage. When present, the last matching line is " +
+					"replaced by line. When absent, all the matching lines are removed",
+			},
+			"state": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(lineStatePresent),
+				Description: "Whether the line must be present or absent. Defaults to present",
+			},
+			"insert_after": schema.StringAttribute{
+				Optional: true,
+				Description: "Where a missing line is inserted: after the last line matching this regular expression, or at " +
+					"the end of the file with EOF. Falls back to the end of the file when nothing matches. Defaults to EOF",
+			},
+			"insert_before": schema.StringAttribute{
+				Optional: true,
+				Description: "Where a missing line is inserted: before the first line matching this regular expression, or " +
+					"at the beginning of the file with BOF. Falls back to the end of the file when nothing matches. Conflicts with insert_after",
+			},
+			"backup": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether a copy of the file is kept next to it, with a timestamp suffix, before it is modified. Defaults to false",
+			},
+			"remove_on_destroy": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether destroying the resource removes the line, or the lines matching regexp, from the file. Has no effect when state is absent. Defaults to false",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *lineInFileResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *lineInFileResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan lineInFileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Line.IsUnknown() || plan.Regexp.IsUnknown() || plan.State.IsUnknown() || plan.InsertAfter.IsUnknown() || plan.InsertBefore.IsUnknown() {
+		return
+	}
+
+	_, err := plan.spec()
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid lineinfile configuration", err.Error())
+	}
+}
+
+func (r *lineInFileResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan lineInFileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *lineInFileResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model lineInFileResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	spec, err := model.spec()
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid lineinfile configuration", err.Error())
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read file", err.Error())
+		return
+	}
+
+	if _, changed := editLines(lines, spec); changed {
+		// The line was changed outside of terraform, it must be applied again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *lineInFileResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan lineInFileResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *lineInFileResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model lineInFileResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !model.RemoveOnDestroy.ValueBool() || model.State.ValueString() == lineStateAbsent {
+		return
+	}
+
+	model.State = types.StringValue(lineStateAbsent)
+
+	r.apply(ctx, model, &resp.Diagnostics)
+}
+
+// apply edits the file as planned. The file is only written, and backed up, when its lines change.
+func (r *lineInFileResource) apply(ctx context.Context, plan lineInFileResourceModel, diags *diag.Diagnostics) {
+	spec, err := plan.spec()
+	if err != nil {
+		diags.AddError("Invalid lineinfile configuration", err.Error())
+		return
+	}
+
+	filePath := plan.Path.ValueString()
+
+	defer r.provider.lockFile(ctx, filePath)()
+
+	info, err := r.provider.machineAccessClient.Stat(ctx, filePath, true)
+	if clients.IsFileNotFound(err) && spec.absent {
+		// A missing file does not have the line either
+		return
+	}
+
+	if err != nil {
+		diags.AddError("Failed to stat file", err.Error())
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		diags.AddError("Failed to read file", err.Error())
+		return
+	}
+
+	newLines, changed := editLines(lines, spec)
+	if !changed {
+		return
+	}
+
+	if plan.Backup.ValueBool() {
+		backupPath := filePath + "." + time.Now().UTC().Format("2006-01-02@15:04:05") + "~"
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("cp -p "+clients.ShellQuote(filePath)+" "+clients.ShellQuote(backupPath)))
+		if err != nil {
+			diags.AddError("Failed to back up file", "Err="+err.Error()+"\nout = "+out)
+			return
+		}
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, filePath, info.Mode, strconv.FormatInt(info.Owner, 10), strconv.FormatInt(info.Group, 10), joinLines(newLines))
+	if err != nil {
+		diags.AddError("Failed to write file", err.Error())
+		return
+	}
+}
+
+// spec validates the model and compiles its regular expressions.
+func (model lineInFileResourceModel) spec() (lineInFileSpec, error) {
+	spec := lineInFileSpec{
+		line:         model.Line.ValueString(),
+		absent:       model.State.ValueString() == lineStateAbsent,
+		insertAfter:  model.InsertAfter.ValueString(),
+		insertBefore: model.InsertBefore.ValueString(),
+	}
+
+	switch model.State.ValueString() {
+	case lineStatePresent:
+		if model.Line.IsNull() {
+			return spec, errors.New("line is required when state is present")
+		}
+	case lineStateAbsent:
+		if model.Line.IsNull() && model.Regexp.IsNull() {
+			return spec, errors.New("line or regexp is required when state is absent")
+		}
+	default:
+		return spec, fmt.Errorf("state must be '%s' or '%s', got '%s'", lineStatePresent, lineStateAbsent, model.State.ValueString())
+	}
+
+	if !model.Regexp.IsNull() {
+		compiled, err := regexp.Compile(model.Regexp.ValueString())
+		if err != nil {
+			return spec, fmt.Errorf("invalid regexp: %w", err)
+		}
+
+		spec.regexp = compiled
+	}
+
+	return spec, validateInsertionAnchors(spec.insertAfter, spec.insertBefore)
+}
+
+// validateInsertionAnchors checks that at most one anchor is set, and that it is BOF, EOF or a regular expression.
+func validateInsertionAnchors(insertAfter string, insertBefore string) error {
+	if insertAfter != "" && insertBefore != "" {
+		return errors.New("insert_after and insert_before cannot be set together")
+	}
+
+	for _, anchor := range []string{insertAfter, insertBefore} {
+		if anchor == "" || anchor == lineAnchorBOF || anchor == lineAnchorEOF {
+			continue
+		}
+
+		_, err := regexp.Compile(anchor)
+		if err != nil {
+			return fmt.Errorf("invalid insert anchor: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// matches returns whether the line is the one managed by the spec.
+func (spec lineInFileSpec) matches(line string) bool {
+	if spec.regexp != nil {
+		return spec.regexp.MatchString(line)
+	}
+
+	return line == spec.line
+}
+
+// editLines returns the lines once edited as specified, and whether they changed.
+func editLines(lines []string, spec lineInFileSpec) ([]string, bool) {
+	if spec.absent {
+		kept := []string{}
+
+		for _, line := range lines {
+			if !spec.matches(line) {
+				kept = append(kept, line)
+			}
+		}
+
+		return kept, len(kept) != len(lines)
+	}
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		if !spec.matches(lines[i]) {
+			continue
+		}
+
+		if lines[i] == spec.line {
+			return lines, false
+		}
+
+		edited := append([]string{}, lines...)
+		edited[i] = spec.line
+
+		return edited, true
+	}
+
+	// With a regexp that matches nothing, the line may still be there already
+	for _, line := range lines {
+		if line == spec.line {
+			return lines, false
+		}
+	}
+
+	index := insertionIndex(lines, spec.insertAfter, spec.insertBefore)
+
+	edited := append([]string{}, lines[:index]...)
+	edited = append(edited, spec.line)
+	edited = append(edited, lines[index:]...)
+
+	return edited, true
+}
+
+// insertionIndex returns where missing lines are inserted, the end of the file unless an anchor matches. The anchors
+// must be valid regular expressions, or BOF and EOF.
+func insertionIndex(lines []string, insertAfter string, insertBefore string) int {
+	switch {
+	case insertBefore == lineAnchorBOF:
+		return 0
+	case insertBefore != "":
+		anchor := regexp.MustCompile(insertBefore)
+
+		for i, line := range lines {
+			if anchor.MatchString(line) {
+				return i
+			}
+		}
+	case insertAfter != "" && insertAfter != lineAnchorEOF:
+		anchor := regexp.MustCompile(insertAfter)
+
+		for i := len(lines) - 1; i >= 0; i-- {
+			if anchor.MatchString(lines[i]) {
+				return i + 1
+			}
+		}
+	}
+
+	return len(lines)
+}
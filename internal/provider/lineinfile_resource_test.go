@@ -0,0 +1,324 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestLineInFileResource(t *testing.T) {
+	t.Run("Test replace a line matching a regexp", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sshClient.RunCommand(context.Background(), "printf 'Port 22\\n#PermitRootLogin yes\\n' > /tmp/sshd_config")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_lineinfile" "test" {
+  path   = "/tmp/sshd_config"
+  regexp = "^#?PermitRootLogin"
+  line   = "PermitRootLogin no"
+  backup = true
+}
+`,
+					Check: func(_ *terraform.State) error {
+						content, err := sshClient.RunCommand(context.Background(), "cat /tmp/sshd_config")
+						if err != nil {
+							return fmt.Errorf("file not found")
+						}
+
+						if content != "Port 22\nPermitRootLogin no\n" {
+							return fmt.Errorf("unexpected content: %s", content)
+						}
+
+						backups, err := sshClient.RunCommand(context.Background(), "ls /tmp/sshd_config.*~ | wc -l")
+						if err != nil || strings.TrimSpace(backups) != "1" {
+							return fmt.Errorf("expected one backup, got: %s", backups)
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestLineInFileResourceWithMock(t *testing.T) {
+	t.Run("create inserts the line after the anchor and keeps the file metadata", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/hosts", "127.0.0.1 localhost\n::1 localhost\n# end\n", clients.FileInfo{Mode: "644", Owner: 0, Group: 0})
+
+		// Act
+		_, diags := testResourceCreate(t, newLineInFileResource(newTestProvider(mock)), lineInFileResourceModel{
+			Path:            types.StringValue("/etc/hosts"),
+			Line:            types.StringValue("10.0.0.1 db"),
+			Regexp:          types.StringNull(),
+			State:           types.StringValue("present"),
+			InsertAfter:     types.StringValue("^127\\."),
+			InsertBefore:    types.StringNull(),
+			Backup:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/hosts"] != "127.0.0.1 localhost\n10.0.0.1 db\n::1 localhost\n# end\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/hosts"])
+		}
+
+		if mock.FileInfos["/etc/hosts"].Mode != "644" {
+			t.Fatalf("unexpected mode: %s", mock.FileInfos["/etc/hosts"].Mode)
+		}
+	})
+
+	t.Run("create removes the matching lines when absent and backs up the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/fstab", "/dev/sda1 / ext4\n/dev/sdb1 /old ext4\n/dev/sdb2 /old2 ext4\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newLineInFileResource(newTestProvider(mock)), lineInFileResourceModel{
+			Path:            types.StringValue("/etc/fstab"),
+			Line:            types.StringNull(),
+			Regexp:          types.StringValue("^/dev/sdb"),
+			State:           types.StringValue("absent"),
+			InsertAfter:     types.StringNull(),
+			InsertBefore:    types.StringNull(),
+			Backup:          types.BoolValue(true),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/fstab"] != "/dev/sda1 / ext4\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/fstab"])
+		}
+
+		if len(mock.Commands) != 1 || !regexp.MustCompile(`^cp -p '/etc/fstab' '/etc/fstab\.\d{4}-\d{2}-\d{2}@\d{2}:\d{2}:\d{2}~'$`).MatchString(mock.Commands[0]) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does not back up an unchanged file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/environment", "LANG=C\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newLineInFileResource(newTestProvider(mock)), lineInFileResourceModel{
+			Path:            types.StringValue("/etc/environment"),
+			Line:            types.StringValue("LANG=C"),
+			Regexp:          types.StringValue("^LANG="),
+			State:           types.StringValue("present"),
+			InsertAfter:     types.StringNull(),
+			InsertBefore:    types.StringNull(),
+			Backup:          types.BoolValue(true),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || len(mock.Commands) != 0 {
+			t.Fatalf("unexpected result: %v, %v", diags, mock.Commands)
+		}
+	})
+
+	t.Run("create fails when the file is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newLineInFileResource(newTestProvider(mock)), lineInFileResourceModel{
+			Path:            types.StringValue("/etc/missing"),
+			Line:            types.StringValue("key=value"),
+			Regexp:          types.StringNull(),
+			State:           types.StringValue("present"),
+			InsertAfter:     types.StringNull(),
+			InsertBefore:    types.StringNull(),
+			Backup:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("read removes the resource when the line was changed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/environment", "LANG=en_US.UTF-8\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newLineInFileResource(newTestProvider(mock)), lineInFileResourceModel{
+			Path:            types.StringValue("/etc/environment"),
+			Line:            types.StringValue("LANG=C"),
+			Regexp:          types.StringValue("^LANG="),
+			State:           types.StringValue("present"),
+			InsertAfter:     types.StringNull(),
+			InsertBefore:    types.StringNull(),
+			Backup:          types.BoolValue(false),
+			RemoveOnDestroy: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed: %v", diags)
+		}
+	})
+}
+
+func TestLineInFileResourceDeleteWithMock(t *testing.T) {
+	model := lineInFileResourceModel{
+		Path:            types.StringValue("/etc/environment"),
+		Line:            types.StringValue("HTTP_PROXY=http://proxy:3128"),
+		Regexp:          types.StringValue("^HTTP_PROXY="),
+		State:           types.StringValue("present"),
+		InsertAfter:     types.StringNull(),
+		InsertBefore:    types.StringNull(),
+		Backup:          types.BoolValue(false),
+		RemoveOnDestroy: types.BoolValue(true),
+	}
+
+	t.Run("delete removes the matching lines with remove_on_destroy", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/environment", "LANG=C\nHTTP_PROXY=http://proxy:3128\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		diags := testResourceDelete(t, newLineInFileResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/environment"] != "LANG=C\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/environment"])
+		}
+	})
+
+	t.Run("delete leaves the file untouched by default", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/environment", "HTTP_PROXY=http://proxy:3128\n", clients.FileInfo{Mode: "644"})
+
+		kept := model
+		kept.RemoveOnDestroy = types.BoolValue(false)
+
+		// Act
+		diags := testResourceDelete(t, newLineInFileResource(newTestProvider(mock)), kept)
+
+		// Assert
+		if diags.HasError() || mock.Files["/etc/environment"] != "HTTP_PROXY=http://proxy:3128\n" {
+			t.Fatalf("unexpected result: %v, %q", diags, mock.Files["/etc/environment"])
+		}
+	})
+
+	t.Run("delete succeeds when the file is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newLineInFileResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+	})
+}
+
+func TestEditLines(t *testing.T) {
+	lines := []string{"[main]", "a=1", "[other]", "b=2"}
+
+	tests := []struct {
+		name     string
+		spec     lineInFileSpec
+		expected []string
+		changed  bool
+	}{
+		{
+			name:     "appends a missing line",
+			spec:     lineInFileSpec{line: "c=3"},
+			expected: []string{"[main]", "a=1", "[other]", "b=2", "c=3"},
+			changed:  true,
+		},
+		{
+			name:     "inserts at the beginning",
+			spec:     lineInFileSpec{line: "# managed", insertBefore: "BOF"},
+			expected: []string{"# managed", "[main]", "a=1", "[other]", "b=2"},
+			changed:  true,
+		},
+		{
+			name:     "inserts before the first match",
+			spec:     lineInFileSpec{line: "c=3", insertBefore: `^\[`},
+			expected: []string{"c=3", "[main]", "a=1", "[other]", "b=2"},
+			changed:  true,
+		},
+		{
+			name:     "appends when the anchor matches nothing",
+			spec:     lineInFileSpec{line: "c=3", insertAfter: "^nothing"},
+			expected: []string{"[main]", "a=1", "[other]", "b=2", "c=3"},
+			changed:  true,
+		},
+		{
+			name:     "replaces the last match",
+			spec:     lineInFileSpec{line: "x=0", regexp: regexp.MustCompile(`^\w=`)},
+			expected: []string{"[main]", "a=1", "[other]", "x=0"},
+			changed:  true,
+		},
+		{
+			name:     "keeps a present line",
+			spec:     lineInFileSpec{line: "a=1"},
+			expected: lines,
+			changed:  false,
+		},
+		{
+			name:     "removes an absent line",
+			spec:     lineInFileSpec{line: "a=1", absent: true},
+			expected: []string{"[main]", "[other]", "b=2"},
+			changed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			edited, changed := editLines(lines, test.spec)
+
+			// Assert
+			if changed != test.changed || strings.Join(edited, "\n") != strings.Join(test.expected, "\n") {
+				t.Fatalf("unexpected result: %v, %v", edited, changed)
+			}
+		})
+	}
+}
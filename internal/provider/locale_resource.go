@@ -0,0 +1,561 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &localeResource{}
+
+const localeGenPath = "/etc/locale.gen"
+
+var (
+	localeNamePattern     = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+	localeVariablePattern = regexp.MustCompile(`^LC_[A-Z_]+$`)
+)
+
+func newLocaleResource(p *internalProvider) resource.Resource {
+	return &localeResource{
+		provider: p,
+	}
+}
+
+// localeResource defines the resource implementation.
+type localeResource struct {
+	provider *internalProvider
+}
+
+type localeResourceModel struct {
+	Locales         types.List   `tfsdk:"locales"`
+	Lang            types.String `tfsdk:"lang"`
+	LC              types.Map    `tfsdk:"lc"`
+	KeyboardLayout  types.String `tfsdk:"keyboard_layout"`
+	KeyboardVariant types.String `tfsdk:"keyboard_variant"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *localeResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_locale"
+}
+
+func (r *localeResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Locale resource that generates locales, sets the default locale and the keyboard layout " +
+			"with localectl, so it needs systemd. The settings of the attributes that are not set are left as they " +
+			"are, and destroying the resource leaves the machine as it is",
+
+		Attributes: map[string]schema.Attribute{
+			"locales": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The locales to generate, e.g. 'en_US.UTF-8'. They are enabled in " + localeGenPath + " when " +
+					"it exists, and generated with locale-gen",
+				Validators: []validator.List{listOf(localeName())},
+			},
+			"lang": schema.StringAttribute{
+				Optional:    true,
+				Description: "The default locale, i.e. LANG, e.g. 'en_US.UTF-8'",
+				Validators:  []validator.String{localeName()},
+			},
+			"lc": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The locale categories overriding the default locale, e.g. { LC_TIME = \"en_GB.UTF-8\" }",
+			},
+			"keyboard_layout": schema.StringAttribute{
+				Optional:    true,
+				Description: "The keyboard layout, e.g. 'us' or 'fr', set for X11 and converted for the console",
+			},
+			"keyboard_variant": schema.StringAttribute{
+				Optional:    true,
+				Description: "The variant of the keyboard layout, e.g. 'dvorak'. Requires keyboard_layout",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *localeResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *localeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan localeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *localeResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model localeResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Only the configured settings are managed, the others may be set by other means
+	if !model.Locales.IsNull() {
+		var locales []string
+
+		resp.Diagnostics.Append(model.Locales.ElementsAs(ctx, &locales, false)...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		missing, err := r.missingLocales(ctx, locales)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list the locales", err.Error())
+			return
+		}
+
+		generated := []string{}
+
+		for _, locale := range locales {
+			if !slices.Contains(missing, locale) {
+				generated = append(generated, locale)
+			}
+		}
+
+		model.Locales, diags = types.ListValueFrom(ctx, types.StringType, generated)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if !model.Lang.IsNull() || !model.LC.IsNull() || !model.KeyboardLayout.IsNull() || !model.KeyboardVariant.IsNull() {
+		status, err := r.status(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the locale status", err.Error())
+			return
+		}
+
+		if !model.Lang.IsNull() {
+			model.Lang = types.StringValue(status.variables["LANG"])
+		}
+
+		if !model.LC.IsNull() {
+			lc := map[string]string{}
+
+			for key := range model.LC.Elements() {
+				if value, ok := status.variables[key]; ok {
+					lc[key] = value
+				}
+			}
+
+			model.LC, diags = types.MapValueFrom(ctx, types.StringType, lc)
+			resp.Diagnostics.Append(diags...)
+		}
+
+		if !model.KeyboardLayout.IsNull() {
+			model.KeyboardLayout = types.StringValue(status.layout)
+		}
+
+		if !model.KeyboardVariant.IsNull() {
+			model.KeyboardVariant = types.StringValue(status.variant)
+		}
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *localeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan localeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state localeResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &state, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *localeResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// The machine needs a locale and a keyboard layout, they are left as they are
+}
+
+// apply generates the missing locales, and sets the locale variables and the keyboard layout that are configured. The
+// locale variables that were configured in the previous state, and are not anymore, are unset.
+func (r *localeResource) apply(ctx context.Context, plan localeResourceModel, previous *localeResourceModel, diags *diag.Diagnostics) {
+	if !plan.KeyboardVariant.IsNull() && plan.KeyboardLayout.IsNull() {
+		diags.AddError("Invalid configuration", "keyboard_variant requires keyboard_layout")
+		return
+	}
+
+	if !plan.Locales.IsNull() {
+		var locales []string
+
+		diags.Append(plan.Locales.ElementsAs(ctx, &locales, false)...)
+
+		if diags.HasError() {
+			return
+		}
+
+		err := r.generateLocales(ctx, locales)
+		if err != nil {
+			diags.AddError("Failed to generate locales", err.Error())
+			return
+		}
+	}
+
+	variables, d := plan.variables(ctx)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var previousVariables map[string]string
+
+	if previous != nil {
+		previousVariables, d = previous.variables(ctx)
+		diags.Append(d...)
+
+		if diags.HasError() {
+			return
+		}
+	}
+
+	if len(variables) > 0 || len(previousVariables) > 0 {
+		status, err := r.status(ctx)
+		if err != nil {
+			diags.AddError("Failed to read the locale status", err.Error())
+			return
+		}
+
+		// set-locale replaces all the variables, the ones set by other means are passed again
+		merged := map[string]string{}
+
+		for key, value := range status.variables {
+			if _, ok := previousVariables[key]; !ok {
+				merged[key] = value
+			}
+		}
+
+		for key, value := range variables {
+			merged[key] = value
+		}
+
+		args := []string{"set-locale"}
+
+		for _, key := range sortedKeys(merged) {
+			args = append(args, key+"="+merged[key])
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("localectl", args...)))
+		if err != nil {
+			diags.AddError("Failed to set the locale", "Err="+err.Error()+"\nout = "+out)
+			return
+		}
+	}
+
+	if !plan.KeyboardLayout.IsNull() {
+		// The X11 layout is converted to the closest console keymap
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("localectl", "set-x11-keymap", plan.KeyboardLayout.ValueString(), "", plan.KeyboardVariant.ValueString())))
+		if err != nil {
+			diags.AddError("Failed to set the keyboard layout", "Err="+err.Error()+"\nout = "+out)
+			return
+		}
+	}
+}
+
+// generateLocales enables the missing locales in /etc/locale.gen, when it exists, and generates them.
+func (r *localeResource) generateLocales(ctx context.Context, locales []string) error {
+	missing, err := r.missingLocales(ctx, locales)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	_, err = r.provider.machineAccessClient.Stat(ctx, localeGenPath, true)
+	if clients.IsFileNotFound(err) {
+		// Without locale.gen, as on some Ubuntu images, locale-gen takes the locales as arguments
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("locale-gen", missing...)))
+		if err != nil {
+			return fmt.Errorf("failed to run locale-gen: %w.\n out= %s", err, out)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", localeGenPath, err)
+	}
+
+	err = r.provider.editFile(ctx, localeGenPath, false, func(lines []string) ([]string, bool) {
+		return enableLocales(lines, missing)
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("locale-gen"))
+	if err != nil {
+		return fmt.Errorf("failed to run locale-gen: %w.\n out= %s", err, out)
+	}
+
+	return nil
+}
+
+// missingLocales returns the locales that are not listed by locale -a.
+func (r *localeResource) missingLocales(ctx context.Context, locales []string) ([]string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "locale -a")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the locales: %w.\n out= %s", err, out)
+	}
+
+	available := map[string]bool{}
+
+	for _, line := range strings.Split(out, "\n") {
+		available[normalizeLocale(strings.TrimSpace(line))] = true
+	}
+
+	missing := []string{}
+
+	for _, locale := range locales {
+		if !available[normalizeLocale(locale)] {
+			missing = append(missing, locale)
+		}
+	}
+
+	return missing, nil
+}
+
+// localeStatus is the output of localectl status.
+type localeStatus struct {
+	variables map[string]string
+	layout    string
+	variant   string
+}
+
+func (r *localeResource) status(ctx context.Context) (localeStatus, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "localectl status")
+	if err != nil {
+		return localeStatus{}, fmt.Errorf("failed to run localectl: %w.\n out= %s", err, out)
+	}
+
+	return parseLocaleStatus(out), nil
+}
+
+// parseLocaleStatus parses the output of localectl status, e.g.
+//
+//	System Locale: LANG=en_US.UTF-8
+//	               LC_TIME=en_GB.UTF-8
+//	    VC Keymap: us
+//	   X11 Layout: us
+//	  X11 Variant: dvorak
+func parseLocaleStatus(out string) localeStatus {
+	status := localeStatus{variables: map[string]string{}}
+	field := ""
+
+	for _, line := range strings.Split(out, "\n") {
+		value := strings.TrimSpace(line)
+
+		// The lines without a field name continue the previous field
+		if name, rest, ok := strings.Cut(value, ": "); ok && !strings.Contains(name, "=") {
+			field = name
+			value = strings.TrimSpace(rest)
+		}
+
+		if value == "n/a" {
+			value = ""
+		}
+
+		switch field {
+		case "System Locale":
+			if key, v, ok := strings.Cut(value, "="); ok {
+				status.variables[key] = v
+			}
+		case "X11 Layout":
+			status.layout = value
+		case "X11 Variant":
+			status.variant = value
+		}
+	}
+
+	return status
+}
+
+// variables returns the locale variables of the attributes that are set.
+func (model localeResourceModel) variables(ctx context.Context) (map[string]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	variables := map[string]string{}
+
+	if !model.LC.IsNull() {
+		diags.Append(model.LC.ElementsAs(ctx, &variables, false)...)
+
+		for key := range variables {
+			if !localeVariablePattern.MatchString(key) {
+				diags.AddError("Invalid locale category", fmt.Sprintf("'%s' is not a locale category, e.g. LC_TIME", key))
+			}
+		}
+	}
+
+	if !model.Lang.IsNull() {
+		variables["LANG"] = model.Lang.ValueString()
+	}
+
+	return variables, diags
+}
+
+// enableLocales uncomments the lines of locale.gen of the locales, and appends the locales that have no line.
+func enableLocales(lines []string, locales []string) ([]string, bool) {
+	edited := slices.Clone(lines)
+	changed := false
+
+	for _, locale := range locales {
+		found := false
+
+		for i, line := range edited {
+			fields := strings.Fields(strings.TrimLeft(strings.TrimSpace(line), "# "))
+			if len(fields) != 2 || fields[0] != locale {
+				continue
+			}
+
+			found = true
+
+			if line != fields[0]+" "+fields[1] {
+				edited[i] = fields[0] + " " + fields[1]
+				changed = true
+			}
+
+			break
+		}
+
+		if !found {
+			edited = append(edited, locale+" "+localeCharset(locale))
+			changed = true
+		}
+	}
+
+	return edited, changed
+}
+
+// localeCharset returns the charset of the locale, e.g. UTF-8 for en_US.UTF-8, defaulting to ISO-8859-1 as glibc.
+func localeCharset(locale string) string {
+	_, charset, ok := strings.Cut(locale, ".")
+	if !ok {
+		return "ISO-8859-1"
+	}
+
+	charset, _, _ = strings.Cut(charset, "@")
+
+	return charset
+}
+
+// normalizeLocale returns the locale as listed by locale -a, e.g. en_US.utf8 for en_US.UTF-8.
+func normalizeLocale(locale string) string {
+	name, charset, ok := strings.Cut(locale, ".")
+	if !ok {
+		return locale
+	}
+
+	charset, modifier, hasModifier := strings.Cut(charset, "@")
+	charset = strings.ToLower(strings.ReplaceAll(charset, "-", ""))
+
+	if hasModifier {
+		return name + "." + charset + "@" + modifier
+	}
+
+	return name + "." + charset
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// localeName validates locale names, e.g. en_US.UTF-8.
+func localeName() validator.String {
+	return stringValidator{
+		description: "value must be a locale name, e.g. 'en_US.UTF-8'",
+		check: func(value string) string {
+			if !localeNamePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a locale name, e.g. 'en_US.UTF-8'", value)
+			}
+
+			return ""
+		},
+	}
+}
@@ -0,0 +1,208 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testLocaleStatus = `   System Locale: LANG=en_US.UTF-8
+                  LC_TIME=en_GB.UTF-8
+       VC Keymap: us
+      X11 Layout: us
+       X11 Model: pc105
+     X11 Variant: n/a
+`
+
+func TestLocaleResourceWithMock(t *testing.T) {
+	t.Run("create enables and generates the missing locales", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("locale -a", clients.MockResponse{Stdout: "C\nC.utf8\nPOSIX\nen_US.utf8\n"}).
+			WithFile(localeGenPath, "# en_US.UTF-8 UTF-8\n# fr_FR.UTF-8 UTF-8\n", clients.FileInfo{Mode: "644"})
+
+		locales, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"en_US.UTF-8", "fr_FR.UTF-8", "de_DE.UTF-8"})
+
+		// Act
+		_, diags := testResourceCreate(t, newLocaleResource(newTestProvider(mock)), testLocaleModel(locales))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[localeGenPath] != "# en_US.UTF-8 UTF-8\nfr_FR.UTF-8 UTF-8\nde_DE.UTF-8 UTF-8\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[localeGenPath])
+		}
+
+		if !slices.Contains(mock.Commands, "locale-gen") {
+			t.Fatalf("expected locale-gen to run, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does not generate the locales that exist", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("locale -a", clients.MockResponse{Stdout: "C\nen_US.utf8\n"})
+
+		locales, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"en_US.UTF-8"})
+
+		// Act
+		_, diags := testResourceCreate(t, newLocaleResource(newTestProvider(mock)), testLocaleModel(locales))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if slices.Contains(mock.Commands, "locale-gen") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create sets the locale, keeping the other variables, and the keyboard layout", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("localectl status", clients.MockResponse{Stdout: testLocaleStatus})
+
+		lc, _ := types.MapValueFrom(context.Background(), types.StringType, map[string]string{"LC_PAPER": "fr_FR.UTF-8"})
+
+		model := testLocaleModel(types.ListNull(types.StringType))
+		model.Lang = types.StringValue("fr_FR.UTF-8")
+		model.LC = lc
+		model.KeyboardLayout = types.StringValue("fr")
+		model.KeyboardVariant = types.StringValue("azerty")
+
+		// Act
+		_, diags := testResourceCreate(t, newLocaleResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		for _, command := range []string{"localectl set-locale LANG=fr_FR.UTF-8 LC_PAPER=fr_FR.UTF-8 LC_TIME=en_GB.UTF-8", "localectl set-x11-keymap fr '' azerty"} {
+			if !slices.Contains(mock.Commands, command) {
+				t.Fatalf("expected %s, got: %v", command, mock.Commands)
+			}
+		}
+	})
+
+	t.Run("update unsets the variables that are not configured anymore", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("localectl status", clients.MockResponse{Stdout: testLocaleStatus})
+
+		lc, _ := types.MapValueFrom(context.Background(), types.StringType, map[string]string{"LC_TIME": "en_GB.UTF-8"})
+
+		state := testLocaleModel(types.ListNull(types.StringType))
+		state.Lang = types.StringValue("en_US.UTF-8")
+		state.LC = lc
+
+		plan := state
+		plan.LC = types.MapNull(types.StringType)
+
+		// Act
+		_, diags := testResourceUpdate(t, newLocaleResource(newTestProvider(mock)), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "localectl set-locale LANG=en_US.UTF-8") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects drift of the managed settings", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("locale -a", clients.MockResponse{Stdout: "C\nen_US.utf8\n"}).
+			On("localectl status", clients.MockResponse{Stdout: testLocaleStatus})
+
+		locales, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"en_US.UTF-8", "fr_FR.UTF-8"})
+		lc, _ := types.MapValueFrom(context.Background(), types.StringType, map[string]string{"LC_TIME": "fr_FR.UTF-8", "LC_PAPER": "fr_FR.UTF-8"})
+
+		model := testLocaleModel(locales)
+		model.Lang = types.StringValue("fr_FR.UTF-8")
+		model.LC = lc
+		model.KeyboardLayout = types.StringValue("fr")
+		model.KeyboardVariant = types.StringValue("azerty")
+
+		// Act
+		state, _, diags := testResourceRead(t, newLocaleResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Locales.String() != `["en_US.UTF-8"]` || state.Lang.ValueString() != "en_US.UTF-8" || state.LC.String() != `{"LC_TIME":"en_GB.UTF-8"}` {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+
+		if state.KeyboardLayout.ValueString() != "us" || state.KeyboardVariant.ValueString() != "" {
+			t.Fatalf("unexpected keyboard: %+v", state)
+		}
+	})
+
+	t.Run("a variant requires a layout", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		model := testLocaleModel(types.ListNull(types.StringType))
+		model.KeyboardVariant = types.StringValue("dvorak")
+
+		// Act
+		_, diags := testResourceCreate(t, newLocaleResource(newTestProvider(mock)), model)
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("invalid categories are rejected", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		lc, _ := types.MapValueFrom(context.Background(), types.StringType, map[string]string{"TIME": "en_GB.UTF-8"})
+
+		model := testLocaleModel(types.ListNull(types.StringType))
+		model.LC = lc
+
+		// Act
+		_, diags := testResourceCreate(t, newLocaleResource(newTestProvider(mock)), model)
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	for locale, expected := range map[string]string{
+		"en_US.UTF-8":            "en_US.utf8",
+		"de_DE.ISO-8859-15@euro": "de_DE.iso885915@euro",
+		"C":                      "C",
+	} {
+		if normalized := normalizeLocale(locale); normalized != expected {
+			t.Fatalf("unexpected normalized locale for %s: %s", locale, normalized)
+		}
+	}
+}
+
+func testLocaleModel(locales types.List) localeResourceModel {
+	return localeResourceModel{
+		Locales:         locales,
+		Lang:            types.StringNull(),
+		LC:              types.MapNull(types.StringType),
+		KeyboardLayout:  types.StringNull(),
+		KeyboardVariant: types.StringNull(),
+	}
+}
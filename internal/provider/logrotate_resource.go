@@ -0,0 +1,441 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &logrotateResource{}
+var _ resource.ResourceWithModifyPlan = &logrotateResource{}
+
+const logrotateDirectory = "/etc/logrotate.d"
+
+var logrotateSizePattern = regexp.MustCompile(`^[0-9]+[kMG]?$`)
+
+func newLogrotateResource(p *internalProvider) resource.Resource {
+	return &logrotateResource{
+		provider: p,
+	}
+}
+
+// logrotateResource defines the resource implementation.
+type logrotateResource struct {
+	provider *internalProvider
+}
+
+type logrotateResourceModel struct {
+	Name          types.String `tfsdk:"name"`
+	Paths         types.List   `tfsdk:"paths"`
+	Frequency     types.String `tfsdk:"frequency"`
+	Rotate        types.Int64  `tfsdk:"rotate"`
+	MaxSize       types.String `tfsdk:"max_size"`
+	Compress      types.Bool   `tfsdk:"compress"`
+	DelayCompress types.Bool   `tfsdk:"delay_compress"`
+	MissingOK     types.Bool   `tfsdk:"missing_ok"`
+	NotIfEmpty    types.Bool   `tfsdk:"not_if_empty"`
+	CopyTruncate  types.Bool   `tfsdk:"copy_truncate"`
+	Create        types.String `tfsdk:"create"`
+	SharedScripts types.Bool   `tfsdk:"shared_scripts"`
+	Postrotate    types.String `tfsdk:"postrotate"`
+	Path          types.String `tfsdk:"path"`
+	Content       types.String `tfsdk:"content"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *logrotateResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_logrotate"
+}
+
+func (r *logrotateResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Logrotate resource that writes the rotation of a set of logs to a file of " + logrotateDirectory +
+			", see logrotate(8). The configuration is checked with logrotate -d before it is installed, as a wrong " +
+			"file of " + logrotateDirectory + " fails the rotation of all the logs. The directives that are not set " +
+			"are inherited from /etc/logrotate.conf. Destroying the resource removes the file",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the file in " + logrotateDirectory + ", e.g. 'myapp'",
+				Validators:  []validator.String{fileName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"paths": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The absolute paths of the rotated logs, which may be globs, e.g. '/var/log/myapp/*.log'",
+				Validators:  []validator.List{listOf(absolutePath())},
+			},
+			"frequency": schema.StringAttribute{
+				Optional:    true,
+				Description: "How often the logs are rotated: 'hourly', 'daily', 'weekly', 'monthly' or 'yearly'. Hourly rotations need logrotate to run every hour",
+				Validators:  []validator.String{oneOf("hourly", "daily", "weekly", "monthly", "yearly")},
+			},
+			"rotate": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The number of rotated logs kept, the older ones are removed",
+				Validators:  []validator.Int64{int64Between(0, 100000)},
+			},
+			"max_size": schema.StringAttribute{
+				Optional:    true,
+				Description: "The size above which the logs are rotated before their time, in bytes or with a k, M or G suffix, e.g. '100M'",
+				Validators:  []validator.String{logrotateSize()},
+			},
+			"compress": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Compress the rotated logs with gzip. Defaults to false",
+			},
+			"delay_compress": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Compress a rotated log at the next rotation only, for the programs which keep writing to the log until they are signaled. Defaults to false",
+			},
+			"missing_ok": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Skip the missing logs without an error, e.g. before the program writing them is installed. Defaults to true",
+			},
+			"not_if_empty": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Do not rotate the empty logs. Defaults to false",
+			},
+			"copy_truncate": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Copy the log then truncate it, instead of moving it, for the programs which can not reopen their log. The lines written during the copy are lost. Defaults to false",
+			},
+			"create": schema.StringAttribute{
+				Optional:    true,
+				Description: "The mode, owner and group of the log created after a rotation, e.g. '0640 www-data adm'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"shared_scripts": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Run the postrotate script once for all the logs, instead of once per rotated log. Defaults to false",
+			},
+			"postrotate": schema.StringAttribute{
+				Optional:    true,
+				Description: "The shell script run after the rotation, e.g. 'systemctl kill -s HUP myapp.service'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the logrotate file",
+			},
+			"content": schema.StringAttribute{
+				Computed:    true,
+				Description: "The content of the logrotate file. A change made outside of terraform is planned as an update",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *logrotateResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan renders the configuration, so that a file changed outside of terraform is planned as a change of content.
+func (r *logrotateResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan logrotateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, diags := plan.render(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("path"), plan.path())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), content)...)
+}
+
+func (r *logrotateResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan logrotateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *logrotateResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model logrotateResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, model.path(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be written again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read logrotate file", err.Error())
+		return
+	}
+
+	model.Content = types.StringValue(content)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *logrotateResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan logrotateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *logrotateResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model logrotateResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", model.path())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove logrotate file", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// apply renders the configuration of the plan, sets its computed attributes, checks it and installs it.
+func (r *logrotateResource) apply(ctx context.Context, plan *logrotateResourceModel, diags *diag.Diagnostics) {
+	content, d := plan.render(ctx)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.check(ctx, plan.Name.ValueString(), content); err != nil {
+		diags.AddError("Invalid logrotate configuration", err.Error())
+		return
+	}
+
+	defer r.provider.lockFile(ctx, plan.path())()
+
+	if err := r.provider.machineAccessClient.WriteFile(ctx, plan.path(), "644", "0", "0", content); err != nil {
+		diags.AddError("Failed to write logrotate file", err.Error())
+		return
+	}
+
+	plan.Path = types.StringValue(plan.path())
+	plan.Content = types.StringValue(content)
+}
+
+// check runs logrotate -d on the configuration, written to a directory only root can write to, as logrotate refuses
+// the configuration files which other users can change. logrotate -d only prints what it would do.
+func (r *logrotateResource) check(ctx context.Context, name string, content string) error {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("mktemp -d"))
+	if err != nil {
+		return fmt.Errorf("failed to create a temp directory. Err=%w\nout = %s", err, out)
+	}
+
+	directory := strings.TrimSpace(out)
+	defer func() {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-rf", directory)))
+	}()
+
+	filePath := directory + "/" + name
+
+	if err := r.provider.machineAccessClient.WriteFile(ctx, filePath, "644", "0", "0", content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("logrotate", "-d", filePath)))
+	if err != nil {
+		return fmt.Errorf("logrotate -d refused the configuration. Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+func (model logrotateResourceModel) path() string {
+	return logrotateDirectory + "/" + model.Name.ValueString()
+}
+
+// render returns the content of the logrotate file. The paths with spaces are quoted.
+func (model logrotateResourceModel) render(ctx context.Context) (string, diag.Diagnostics) {
+	var paths []string
+
+	diags := model.Paths.ElementsAs(ctx, &paths, false)
+	if diags.HasError() {
+		return "", diags
+	}
+
+	for i, logPath := range paths {
+		if strings.ContainsAny(logPath, " \t") {
+			paths[i] = `"` + logPath + `"`
+		}
+	}
+
+	directives := []string{}
+
+	if !model.Frequency.IsNull() {
+		directives = append(directives, model.Frequency.ValueString())
+	}
+
+	if !model.Rotate.IsNull() {
+		directives = append(directives, "rotate "+strconv.FormatInt(model.Rotate.ValueInt64(), 10))
+	}
+
+	if !model.MaxSize.IsNull() {
+		directives = append(directives, "maxsize "+model.MaxSize.ValueString())
+	}
+
+	for _, flag := range []struct {
+		value     types.Bool
+		directive string
+	}{
+		{model.Compress, "compress"},
+		{model.DelayCompress, "delaycompress"},
+		{model.MissingOK, "missingok"},
+		{model.NotIfEmpty, "notifempty"},
+		{model.CopyTruncate, "copytruncate"},
+		{model.SharedScripts, "sharedscripts"},
+	} {
+		if flag.value.ValueBool() {
+			directives = append(directives, flag.directive)
+		}
+	}
+
+	if !model.Create.IsNull() {
+		directives = append(directives, "create "+model.Create.ValueString())
+	}
+
+	lines := []string{strings.Join(paths, " ") + " {"}
+
+	for _, directive := range directives {
+		lines = append(lines, "    "+directive)
+	}
+
+	if !model.Postrotate.IsNull() {
+		lines = append(lines, "    postrotate")
+
+		for _, line := range strings.Split(strings.TrimRight(model.Postrotate.ValueString(), "\n"), "\n") {
+			lines = append(lines, "        "+line)
+		}
+
+		lines = append(lines, "    endscript")
+	}
+
+	lines = append(lines, "}")
+
+	return joinLines(lines), diags
+}
+
+// logrotateSize validates the sizes of logrotate, in bytes or with a k, M or G suffix.
+func logrotateSize() validator.String {
+	return stringValidator{
+		description: "value must be a size in bytes, or with a k, M or G suffix, e.g. '100M'",
+		check: func(value string) string {
+			if !logrotateSizePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a size in bytes, or with a k, M or G suffix, e.g. '100M'", value)
+			}
+
+			return ""
+		},
+	}
+}
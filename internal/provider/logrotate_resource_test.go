@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestLogrotateResourceWithMock(t *testing.T) {
+	t.Run("create checks the configuration before installing it", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.check\n"})
+
+		model := testLogrotateModel()
+		model.Frequency = types.StringValue("daily")
+		model.Rotate = types.Int64Value(7)
+		model.Compress = types.BoolValue(true)
+		model.Postrotate = types.StringValue("systemctl kill -s HUP myapp.service")
+
+		// Act
+		state, diags := testResourceCreate(t, newLogrotateResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := `"/var/log/my app/*.log" /var/log/myapp.log {
+    daily
+    rotate 7
+    compress
+    missingok
+    postrotate
+        systemctl kill -s HUP myapp.service
+    endscript
+}
+`
+		if mock.Files["/etc/logrotate.d/myapp"] != expected || state.Content.ValueString() != expected {
+			t.Fatalf("unexpected content:\n%s", mock.Files["/etc/logrotate.d/myapp"])
+		}
+
+		if mock.Files["/tmp/tmp.check/myapp"] != expected || !testLogrotateCommandRan(mock, "logrotate -d /tmp/tmp.check/myapp") {
+			t.Fatalf("expected the configuration to be checked, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does not install a configuration refused by logrotate", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.check\n"}).
+			OnMatch(`logrotate -d`, clients.MockResponse{Stderr: "error: myapp:3 unknown option 'rotat'", ExitCode: 1})
+
+		// Act
+		_, diags := testResourceCreate(t, newLogrotateResource(newTestProvider(mock)), testLogrotateModel())
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "unknown option") {
+			t.Fatalf("expected the error of logrotate, got: %v", diags)
+		}
+
+		if _, ok := mock.Files["/etc/logrotate.d/myapp"]; ok {
+			t.Fatal("the refused configuration must not be installed")
+		}
+
+		if !testLogrotateCommandRan(mock, "rm -rf /tmp/tmp.check") {
+			t.Fatalf("expected the temp directory to be removed, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read removes the resource when the file is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newLogrotateResource(newTestProvider(mock)), testLogrotateModel())
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed, got: %v", diags)
+		}
+	})
+}
+
+func testLogrotateModel() logrotateResourceModel {
+	return logrotateResourceModel{
+		Name:          types.StringValue("myapp"),
+		Paths:         types.ListValueMust(types.StringType, []attr.Value{types.StringValue("/var/log/my app/*.log"), types.StringValue("/var/log/myapp.log")}),
+		Frequency:     types.StringNull(),
+		Rotate:        types.Int64Null(),
+		MaxSize:       types.StringNull(),
+		Compress:      types.BoolValue(false),
+		DelayCompress: types.BoolValue(false),
+		MissingOK:     types.BoolValue(true),
+		NotIfEmpty:    types.BoolValue(false),
+		CopyTruncate:  types.BoolValue(false),
+		Create:        types.StringNull(),
+		SharedScripts: types.BoolValue(false),
+		Postrotate:    types.StringNull(),
+		Path:          types.StringUnknown(),
+		Content:       types.StringUnknown(),
+	}
+}
+
+func testLogrotateCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
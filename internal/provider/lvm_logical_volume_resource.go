@@ -0,0 +1,285 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &lvmLogicalVolumeResource{}
+
+// lvmSizePattern matches absolute sizes, e.g. 10G, and sizes relative to the volume group, e.g. 100%FREE.
+var lvmSizePattern = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?[bBsSkKmMgGtTpPeE]?|[0-9]+%(FREE|VG|PVS))$`)
+
+func newLvmLogicalVolumeResource(p *internalProvider) resource.Resource {
+	return &lvmLogicalVolumeResource{
+		provider: p,
+	}
+}
+
+// lvmLogicalVolumeResource defines the resource implementation.
+type lvmLogicalVolumeResource struct {
+	provider *internalProvider
+}
+
+type lvmLogicalVolumeResourceModel struct {
+	VolumeGroup      types.String `tfsdk:"volume_group"`
+	Name             types.String `tfsdk:"name"`
+	Size             types.String `tfsdk:"size"`
+	ResizeFilesystem types.Bool   `tfsdk:"resize_filesystem"`
+	AllowDataLoss    types.Bool   `tfsdk:"allow_data_loss"`
+	Path             types.String `tfsdk:"path"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *lvmLogicalVolumeResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_lvm_logical_volume"
+}
+
+func (r *lvmLogicalVolumeResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "LVM logical volume resource that creates a logical volume in a volume group, or adopts it " +
+			"when it already exists. Increasing the size extends the volume in place, and its filesystem with it; " +
+			"volumes are never shrunk, as shrinking loses data. Destroying the resource requires allow_data_loss " +
+			"and allow_destructive_operations, and fails while the volume is mounted",
+
+		Attributes: map[string]schema.Attribute{
+			"volume_group": schema.StringAttribute{
+				Required:    true,
+				Description: "The volume group of the volume, e.g. setup_lvm_volume_group.data.name",
+				Validators:  []validator.String{lvmName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the volume, e.g. 'postgres'",
+				Validators:  []validator.String{lvmName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"size": schema.StringAttribute{
+				Required:    true,
+				Description: "The size of the volume, either absolute with a unit, e.g. '20G', or relative to the volume group, e.g. '100%FREE' or '50%VG'",
+				Validators:  []validator.String{lvmSize()},
+			},
+			"resize_filesystem": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether the filesystem of the volume is grown with it when the size increases. Defaults to true",
+			},
+			"allow_data_loss": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether destroying or replacing the resource may remove the volume, losing its data. Apply it set to true before destroying the resource. Defaults to false",
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The device path of the volume, e.g. '/dev/data/postgres'",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *lvmLogicalVolumeResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *lvmLogicalVolumeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan lvmLogicalVolumeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	exists, err := r.exists(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the logical volume", err.Error())
+		return
+	}
+
+	if !exists {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("lvcreate", "--yes", "-n", plan.Name.ValueString(), lvmSizeFlag(plan.Size.ValueString()), plan.Size.ValueString(), plan.VolumeGroup.ValueString())))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create the logical volume", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	plan.Path = types.StringValue("/dev/" + lvmVolumeID(plan))
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *lvmLogicalVolumeResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state lvmLogicalVolumeResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	exists, err := r.exists(ctx, state)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the logical volume", err.Error())
+		return
+	}
+
+	// The size is not read back, as relative sizes cannot be derived from the current one
+	if !exists {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+}
+
+func (r *lvmLogicalVolumeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state lvmLogicalVolumeResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Size != state.Size {
+		args := []string{lvmSizeFlag(plan.Size.ValueString()), plan.Size.ValueString()}
+		if plan.ResizeFilesystem.ValueBool() {
+			args = append(args, "--resizefs")
+		}
+
+		// lvextend refuses sizes smaller than the current one, so that the volume is never shrunk
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("lvextend", append(args, lvmVolumeID(plan))...)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to extend the logical volume", fmt.Sprintf("Logical volumes are only grown, never shrunk. Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *lvmLogicalVolumeResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state lvmLogicalVolumeResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !state.AllowDataLoss.ValueBool() {
+		resp.Diagnostics.AddError("Data loss refused", fmt.Sprintf("Refusing to remove the logical volume %s as allow_data_loss is false. "+
+			"Apply it set to true first to allow it, or remove the resource from the state with terraform state rm to leave it on the machine", lvmVolumeID(state)))
+		return
+	}
+
+	if !r.provider.allowDestructiveOperation("remove the logical volume "+lvmVolumeID(state), &resp.Diagnostics) {
+		return
+	}
+
+	// lvremove refuses to remove an open volume, e.g. a mounted one
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("lvremove", "--yes", lvmVolumeID(state))))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the logical volume", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// exists returns whether the logical volume exists.
+func (r *lvmLogicalVolumeResource) exists(ctx context.Context, model lvmLogicalVolumeResourceModel) (bool, error) {
+	result, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("lvs", "--noheadings", "-o", "lv_name", lvmVolumeID(model))))
+
+	// lvs exits with 5 when the volume or its group does not exist
+	if clients.IsExitCode(err, 5) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+	}
+
+	return strings.TrimSpace(result.Stdout) == model.Name.ValueString(), nil
+}
+
+// lvmVolumeID returns the group/name identifier of the volume used by the LVM commands.
+func lvmVolumeID(model lvmLogicalVolumeResourceModel) string {
+	return model.VolumeGroup.ValueString() + "/" + model.Name.ValueString()
+}
+
+// lvmSizeFlag returns the flag of lvcreate and lvextend for the size, -l for relative sizes counted in extents.
+func lvmSizeFlag(size string) string {
+	if strings.Contains(size, "%") {
+		return "-l"
+	}
+
+	return "-L"
+}
+
+// lvmSize validates the sizes of logical volumes.
+func lvmSize() validator.String {
+	return stringValidator{
+		description: "value must be a size with a unit, e.g. '20G', or a percentage, e.g. '100%FREE'",
+		check: func(value string) string {
+			if !lvmSizePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must be a size with a unit, e.g. '20G', or a percentage of FREE, VG or PVS, e.g. '100%%FREE'", value)
+			}
+
+			return ""
+		},
+	}
+}
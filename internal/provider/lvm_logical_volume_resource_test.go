@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestLvmLogicalVolumeResourceWithMock(t *testing.T) {
+	t.Run("create creates the volume", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("lvs --noheadings -o lv_name data/postgres", clients.MockResponse{ExitCode: 5})
+
+		// Act
+		state, diags := testResourceCreate(t, newLvmLogicalVolumeResource(newTestProvider(mock)), testLvmLogicalVolumeModel("100%FREE"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "lvcreate --yes -n postgres -l 100%FREE data") {
+			t.Fatalf("expected the volume to be created, got: %v", mock.Commands)
+		}
+
+		if state.Path.ValueString() != "/dev/data/postgres" {
+			t.Fatalf("unexpected path: %s", state.Path)
+		}
+	})
+
+	t.Run("create adopts an existing volume", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("lvs --noheadings -o lv_name data/postgres", clients.MockResponse{Stdout: "  postgres\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newLvmLogicalVolumeResource(newTestProvider(mock)), testLvmLogicalVolumeModel("20G"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 1 {
+			t.Fatalf("expected the volume to be left as is, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("update extends the volume and its filesystem", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceUpdate(t, newLvmLogicalVolumeResource(newTestProvider(mock)), testLvmLogicalVolumeState("20G"), testLvmLogicalVolumeState("30G"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"lvextend -L 30G --resizefs data/postgres"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read removes the volume when it is gone", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("lvs --noheadings -o lv_name data/postgres", clients.MockResponse{ExitCode: 5})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newLvmLogicalVolumeResource(newTestProvider(mock)), testLvmLogicalVolumeState("20G"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete requires allow_data_loss", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		state := testLvmLogicalVolumeState("20G")
+
+		allowed := testLvmLogicalVolumeState("20G")
+		allowed.AllowDataLoss = types.BoolValue(true)
+
+		// Act
+		refusedDiags := testResourceDelete(t, newLvmLogicalVolumeResource(newTestProvider(mock)), state)
+		diags := testResourceDelete(t, newLvmLogicalVolumeResource(newTestProvider(mock)), allowed)
+
+		// Assert
+		if !refusedDiags.HasError() {
+			t.Fatal("expected an error without allow_data_loss")
+		}
+
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"lvremove --yes data/postgres"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func testLvmLogicalVolumeModel(size string) lvmLogicalVolumeResourceModel {
+	return lvmLogicalVolumeResourceModel{
+		VolumeGroup:      types.StringValue("data"),
+		Name:             types.StringValue("postgres"),
+		Size:             types.StringValue(size),
+		ResizeFilesystem: types.BoolValue(true),
+		AllowDataLoss:    types.BoolValue(false),
+		Path:             types.StringUnknown(),
+	}
+}
+
+func testLvmLogicalVolumeState(size string) lvmLogicalVolumeResourceModel {
+	model := testLvmLogicalVolumeModel(size)
+	model.Path = types.StringValue("/dev/data/postgres")
+
+	return model
+}
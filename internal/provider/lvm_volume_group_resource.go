@@ -0,0 +1,366 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &lvmVolumeGroupResource{}
+
+// lvmNamePattern matches the names LVM accepts for volume groups and logical volumes.
+var lvmNamePattern = regexp.MustCompile(`^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]{0,126}$`)
+
+func newLvmVolumeGroupResource(p *internalProvider) resource.Resource {
+	return &lvmVolumeGroupResource{
+		provider: p,
+	}
+}
+
+// lvmVolumeGroupResource defines the resource implementation.
+type lvmVolumeGroupResource struct {
+	provider *internalProvider
+}
+
+type lvmVolumeGroupResourceModel struct {
+	Name            types.String `tfsdk:"name"`
+	PhysicalVolumes types.List   `tfsdk:"physical_volumes"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *lvmVolumeGroupResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_lvm_volume_group"
+}
+
+func (r *lvmVolumeGroupResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "LVM volume group resource that initializes the physical volumes and creates the volume " +
+			"group, or adopts it when it already exists. Only devices holding no filesystem, partition table or other " +
+			"signature are initialized: devices with data are refused and have to be wiped by hand. Physical volumes " +
+			"are added to and removed from the group in place, a physical volume still holding extents is never " +
+			"removed. Destroying the resource requires allow_destructive_operations and fails while the group has " +
+			"logical volumes",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the volume group, e.g. 'data'",
+				Validators:  []validator.String{lvmName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"physical_volumes": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The devices making the volume group, e.g. ['/dev/sdb', setup_raid.data.device]",
+				Validators:  []validator.List{listOf(absolutePath())},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *lvmVolumeGroupResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *lvmVolumeGroupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan lvmVolumeGroupResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	name := plan.Name.ValueString()
+
+	devices := stringList(ctx, plan.PhysicalVolumes, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	physicalVolumes, err := r.physicalVolumes(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list the physical volumes", err.Error())
+		return
+	}
+
+	// An existing group is adopted, and its physical volumes reconciled as on update
+	if len(lvmGroupMembers(physicalVolumes, name)) > 0 {
+		if !r.reconcile(ctx, name, devices, &resp.Diagnostics) {
+			return
+		}
+	} else {
+		if !r.prepare(ctx, name, devices, physicalVolumes, &resp.Diagnostics) {
+			return
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("vgcreate", append([]string{name}, devices...)...)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create the volume group", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *lvmVolumeGroupResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state lvmVolumeGroupResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	physicalVolumes, err := r.physicalVolumes(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list the physical volumes", err.Error())
+		return
+	}
+
+	members := lvmGroupMembers(physicalVolumes, state.Name.ValueString())
+	if len(members) == 0 {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if !sameElements(members, stringList(ctx, state.PhysicalVolumes, &resp.Diagnostics)) {
+		value, diags := types.ListValueFrom(ctx, types.StringType, members)
+		resp.Diagnostics.Append(diags...)
+
+		state.PhysicalVolumes = value
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *lvmVolumeGroupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan lvmVolumeGroupResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	devices := stringList(ctx, plan.PhysicalVolumes, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !r.reconcile(ctx, plan.Name.ValueString(), devices, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *lvmVolumeGroupResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state lvmVolumeGroupResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	name := state.Name.ValueString()
+
+	if !r.provider.allowDestructiveOperation("remove the volume group "+name, &resp.Diagnostics) {
+		return
+	}
+
+	result, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("vgs", "--noheadings", "-o", "lv_count", name)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the volume group", fmt.Sprintf("Err=%s\nout = %s", err, result.Stdout+result.Stderr))
+		return
+	}
+
+	if count := strings.TrimSpace(result.Stdout); count != "0" {
+		resp.Diagnostics.AddError("Volume group in use", fmt.Sprintf("Refusing to remove the volume group %s which still has %s logical volumes", name, count))
+		return
+	}
+
+	physicalVolumes, err := r.physicalVolumes(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list the physical volumes", err.Error())
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("vgremove", name)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the volume group", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("pvremove", lvmGroupMembers(physicalVolumes, name)...)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the physical volumes", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// reconcile adds the missing devices to the volume group and removes the devices that are not planned anymore.
+func (r *lvmVolumeGroupResource) reconcile(ctx context.Context, name string, devices []string, diags *diag.Diagnostics) bool {
+	physicalVolumes, err := r.physicalVolumes(ctx)
+	if err != nil {
+		diags.AddError("Failed to list the physical volumes", err.Error())
+		return false
+	}
+
+	members := lvmGroupMembers(physicalVolumes, name)
+
+	added := slices.DeleteFunc(slices.Clone(devices), func(device string) bool { return slices.Contains(members, device) })
+	if len(added) > 0 {
+		if !r.prepare(ctx, name, added, physicalVolumes, diags) {
+			return false
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("vgextend", append([]string{name}, added...)...)))
+		if err != nil {
+			diags.AddError("Failed to extend the volume group", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return false
+		}
+	}
+
+	for _, device := range members {
+		if slices.Contains(devices, device) {
+			continue
+		}
+
+		// vgreduce refuses to remove a physical volume holding extents, they have to be moved with pvmove first
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("vgreduce", name, device)))
+		if err != nil {
+			diags.AddError("Failed to remove "+device+" from the volume group", fmt.Sprintf("Move its extents first with `pvmove %s`. Err=%s\nout = %s", device, err, out))
+			return false
+		}
+
+		out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("pvremove", device)))
+		if err != nil {
+			diags.AddError("Failed to remove the physical volume "+device, fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return false
+		}
+	}
+
+	return true
+}
+
+// prepare initializes the devices as physical volumes, refusing devices holding data.
+func (r *lvmVolumeGroupResource) prepare(ctx context.Context, name string, devices []string, physicalVolumes map[string]string, diags *diag.Diagnostics) bool {
+	for _, device := range devices {
+		group, isPhysicalVolume := physicalVolumes[device]
+
+		switch {
+		case isPhysicalVolume && group != "":
+			diags.AddError("Physical volume in use", fmt.Sprintf("%s already belongs to the volume group %s, refusing to add it to %s", device, group, name))
+			return false
+		case isPhysicalVolume:
+			// an unused physical volume holds no data
+			continue
+		}
+
+		if err := requireBlankDevices(ctx, r.provider, []string{device}); err != nil {
+			diags.AddError("Refusing to initialize the physical volume", err.Error())
+			return false
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("pvcreate", device)))
+		if err != nil {
+			diags.AddError("Failed to initialize the physical volume "+device, fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return false
+		}
+	}
+
+	return true
+}
+
+// physicalVolumes returns the volume group of each physical volume of the machine, empty for unused ones.
+func (r *lvmVolumeGroupResource) physicalVolumes(ctx context.Context) (map[string]string, error) {
+	result, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo("pvs --noheadings --separator '|' -o pv_name,vg_name"))
+	if err != nil {
+		return nil, fmt.Errorf("Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+	}
+
+	physicalVolumes := map[string]string{}
+
+	for line := range strings.SplitSeq(result.Stdout, "\n") {
+		device, group, ok := strings.Cut(strings.TrimSpace(line), "|")
+		if ok {
+			physicalVolumes[device] = group
+		}
+	}
+
+	return physicalVolumes, nil
+}
+
+// lvmGroupMembers returns the sorted physical volumes of the volume group.
+func lvmGroupMembers(physicalVolumes map[string]string, name string) []string {
+	members := []string{}
+
+	for device, group := range physicalVolumes {
+		if group == name {
+			members = append(members, device)
+		}
+	}
+
+	slices.Sort(members)
+
+	return members
+}
+
+// lvmName validates the names of volume groups and logical volumes.
+func lvmName() validator.String {
+	return stringValidator{
+		description: "value must only contain letters, digits and '+_.-', and not start with '-'",
+		check: func(value string) string {
+			if !lvmNamePattern.MatchString(value) || value == "." || value == ".." {
+				return fmt.Sprintf("'%s' must only contain letters, digits and '+_.-', and not start with '-'", value)
+			}
+
+			return ""
+		},
+	}
+}
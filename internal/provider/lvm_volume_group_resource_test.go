@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testPvsCommand = "pvs --noheadings --separator '|' -o pv_name,vg_name"
+
+func TestLvmVolumeGroupResourceWithMock(t *testing.T) {
+	t.Run("create initializes the blank devices and creates the group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(testPvsCommand, clients.MockResponse{Stdout: "  /dev/sda3|system\n  /dev/sdc|\n"}).
+			On("blkid -p -o export /dev/sdb", clients.MockResponse{ExitCode: 2})
+
+		// Act
+		_, diags := testResourceCreate(t, newLvmVolumeGroupResource(newTestProvider(mock)), testLvmVolumeGroupModel("/dev/sdb", "/dev/sdc"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{testPvsCommand, "blkid -p -o export /dev/sdb", "pvcreate /dev/sdb", "vgcreate data /dev/sdb /dev/sdc"}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create refuses the physical volumes of another group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(testPvsCommand, clients.MockResponse{Stdout: "  /dev/sdb|system\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newLvmVolumeGroupResource(newTestProvider(mock)), testLvmVolumeGroupModel("/dev/sdb"))
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if len(mock.Commands) != 1 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("update extends and reduces the group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(testPvsCommand, clients.MockResponse{Stdout: "  /dev/sdb|data\n  /dev/sdc|data\n"}).
+			On("blkid -p -o export /dev/sdd", clients.MockResponse{ExitCode: 2})
+
+		// Act
+		_, diags := testResourceUpdate(t, newLvmVolumeGroupResource(newTestProvider(mock)), testLvmVolumeGroupModel("/dev/sdb", "/dev/sdc"), testLvmVolumeGroupModel("/dev/sdb", "/dev/sdd"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{testPvsCommand, "blkid -p -o export /dev/sdd", "pvcreate /dev/sdd", "vgextend data /dev/sdd", "vgreduce data /dev/sdc", "pvremove /dev/sdc"}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read removes the group when it is gone", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(testPvsCommand, clients.MockResponse{Stdout: "  /dev/sda3|system\n"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newLvmVolumeGroupResource(newTestProvider(mock)), testLvmVolumeGroupModel("/dev/sdb"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete refuses a group with logical volumes", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("vgs --noheadings -o lv_count data", clients.MockResponse{Stdout: "  2\n"})
+
+		// Act
+		diags := testResourceDelete(t, newLvmVolumeGroupResource(newTestProvider(mock)), testLvmVolumeGroupModel("/dev/sdb"))
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if slices.Contains(mock.Commands, "vgremove data") {
+			t.Fatalf("expected the group to be kept, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("delete removes the group and its physical volumes", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("vgs --noheadings -o lv_count data", clients.MockResponse{Stdout: "  0\n"}).
+			On(testPvsCommand, clients.MockResponse{Stdout: "  /dev/sdb|data\n"})
+
+		// Act
+		diags := testResourceDelete(t, newLvmVolumeGroupResource(newTestProvider(mock)), testLvmVolumeGroupModel("/dev/sdb"))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "vgremove data") || !slices.Contains(mock.Commands, "pvremove /dev/sdb") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func testLvmVolumeGroupModel(devices ...string) lvmVolumeGroupResourceModel {
+	value, _ := types.ListValueFrom(context.Background(), types.StringType, devices)
+
+	return lvmVolumeGroupResourceModel{
+		Name:            types.StringValue("data"),
+		PhysicalVolumes: value,
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &machineIDResource{}
+
+const (
+	machineIDFile = "/etc/machine-id"
+
+	// dbusMachineIDFile is the copy of the machine id of older D-Bus versions, turned into a link to machineIDFile.
+	dbusMachineIDFile = "/var/lib/dbus/machine-id"
+
+	// cleanCloudInitCommand removes the state of cloud-init, so that it runs again as on a first boot. The state is
+	// removed by hand on the images where cloud-init is not installed anymore.
+	cleanCloudInitCommand = "if command -v cloud-init >/dev/null 2>&1; then cloud-init clean --logs; else rm -rf /var/lib/cloud; fi"
+
+	// regenerateSSHHostKeysCommand replaces the host keys of the SSH server. The running server reads them for each
+	// new connection.
+	regenerateSSHHostKeysCommand = "rm -f /etc/ssh/ssh_host_*_key /etc/ssh/ssh_host_*_key.pub && ssh-keygen -A"
+)
+
+func newMachineIDResource(p *internalProvider) resource.Resource {
+	return &machineIDResource{
+		provider: p,
+	}
+}
+
+// machineIDResource defines the resource implementation.
+type machineIDResource struct {
+	provider *internalProvider
+}
+
+type machineIDResourceModel struct {
+	Triggers              types.Map    `tfsdk:"triggers"`
+	MachineID             types.String `tfsdk:"machine_id"`
+	CleanCloudInit        types.Bool   `tfsdk:"clean_cloud_init"`
+	RegenerateSSHHostKeys types.Bool   `tfsdk:"regenerate_ssh_host_keys"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *machineIDResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_id"
+}
+
+func (r *machineIDResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Gives a new id to a machine cloned from a golden image, by writing /etc/machine-id when the " +
+			"resource is created, and again whenever triggers change. The state of cloud-init and the SSH host keys, " +
+			"which are also copied with the image, can be reset as well. The services read the machine id when they " +
+			"start, e.g. the DHCP client id of systemd-networkd, so reboot the machine afterwards, e.g. with a " +
+			"setup_reboot triggered by machine_id. Destroying the resource leaves the machine as it is",
+
+		Attributes: map[string]schema.Attribute{
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Arbitrary values that cause a new machine id when changed, e.g. the id of the image the machine is cloned from",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"machine_id": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The machine id, 32 lowercase hexadecimal characters. When it is not set, a random id is generated",
+				Validators:  []validator.String{machineID()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"clean_cloud_init": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Remove the state and the logs of cloud-init, so that it runs again as on a first boot at the next boot. Defaults to false",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"regenerate_ssh_host_keys": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Replace the host keys of the SSH server with new ones, so that the clones of an image do not share them. The known_hosts entries of the machine must be updated afterwards. Defaults to false",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *machineIDResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *machineIDResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan machineIDResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	if plan.CleanCloudInit.ValueBool() {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", cleanCloudInitCommand)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to clean cloud-init", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	if plan.RegenerateSSHHostKeys.ValueBool() {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", regenerateSSHHostKeysCommand)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to regenerate the SSH host keys", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	// The id is written after the cleanups, so that a failed cleanup is run again by the next apply
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", writeMachineIDCommand(plan.MachineID))))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to write the machine id", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	machineID, err := r.machineID(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the machine id", err.Error())
+		return
+	}
+
+	plan.MachineID = types.StringValue(machineID)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *machineIDResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model machineIDResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// A configured id which was changed by other means is written again
+	machineID, err := r.machineID(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the machine id", err.Error())
+		return
+	}
+
+	model.MachineID = types.StringValue(machineID)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *machineIDResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan machineIDResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Every attribute requires a replacement, there is nothing to change in place
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *machineIDResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// nothing to do, the machine keeps its id
+}
+
+// machineID returns the machine id of the machine.
+func (r *machineIDResource) machineID(ctx context.Context) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("cat", machineIDFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s. Err=%w\nout = %s", machineIDFile, err, out)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// writeMachineIDCommand returns the POSIX sh script writing the given machine id, or a random one generated by the
+// kernel when it is not set. The copy of D-Bus is replaced by a link, so that both ids stay the same.
+func writeMachineIDCommand(machineID types.String) string {
+	write := "sed 's/-//g' /proc/sys/kernel/random/uuid > " + machineIDFile
+	if !machineID.IsNull() && !machineID.IsUnknown() {
+		write = clients.ShellCommand("printf", `%s\n`, machineID.ValueString()) + " > " + machineIDFile
+	}
+
+	return write + " && chmod 444 " + machineIDFile +
+		" && if [ -d /var/lib/dbus ]; then ln -sf " + machineIDFile + " " + dbusMachineIDFile + "; fi"
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestMachineIDResourceWithMock(t *testing.T) {
+	t.Run("create writes a random machine id", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/machine-id", clients.MockResponse{Stdout: "0123456789abcdef0123456789abcdef\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newMachineIDResource(newTestProvider(mock)), testMachineIDModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.MachineID.ValueString() != "0123456789abcdef0123456789abcdef" {
+			t.Fatalf("unexpected machine id: %s", state.MachineID)
+		}
+
+		if !testMachineIDCommandRan(mock, "/proc/sys/kernel/random/uuid > /etc/machine-id") || testMachineIDCommandRan(mock, "cloud-init") || testMachineIDCommandRan(mock, "ssh-keygen") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create writes the given machine id and cleans the image", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/machine-id", clients.MockResponse{Stdout: "fedcba9876543210fedcba9876543210\n"})
+
+		model := testMachineIDModel()
+		model.MachineID = types.StringValue("fedcba9876543210fedcba9876543210")
+		model.CleanCloudInit = types.BoolValue(true)
+		model.RegenerateSSHHostKeys = types.BoolValue(true)
+
+		// Act
+		state, diags := testResourceCreate(t, newMachineIDResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.MachineID.ValueString() != "fedcba9876543210fedcba9876543210" {
+			t.Fatalf("unexpected machine id: %s", state.MachineID)
+		}
+
+		if !testMachineIDCommandRan(mock, "fedcba9876543210fedcba9876543210") || !testMachineIDCommandRan(mock, "cloud-init clean --logs") || !testMachineIDCommandRan(mock, "ssh-keygen -A") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects a machine id changed by other means", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/machine-id", clients.MockResponse{Stdout: "fedcba9876543210fedcba9876543210\n"})
+
+		model := testMachineIDModel()
+		model.MachineID = types.StringValue("0123456789abcdef0123456789abcdef")
+
+		// Act
+		state, _, diags := testResourceRead(t, newMachineIDResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.MachineID.ValueString() != "fedcba9876543210fedcba9876543210" {
+			t.Fatalf("unexpected machine id: %s", state.MachineID)
+		}
+	})
+}
+
+func testMachineIDModel() machineIDResourceModel {
+	return machineIDResourceModel{
+		Triggers:              types.MapNull(types.StringType),
+		MachineID:             types.StringUnknown(),
+		CleanCloudInit:        types.BoolValue(false),
+		RegenerateSSHHostKeys: types.BoolValue(false),
+	}
+}
+
+func testMachineIDCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,333 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &motdResource{}
+
+// updateMotdDirectory has the scripts of update-motd(5), printing the dynamic parts of the message of the day of Ubuntu.
+const updateMotdDirectory = "/etc/update-motd.d"
+
+func newMotdResource(p *internalProvider) resource.Resource {
+	return &motdResource{
+		provider: p,
+	}
+}
+
+// motdResource defines the resource implementation.
+type motdResource struct {
+	provider *internalProvider
+}
+
+type motdResourceModel struct {
+	Motd            types.String `tfsdk:"motd"`
+	Issue           types.String `tfsdk:"issue"`
+	IssueNet        types.String `tfsdk:"issue_net"`
+	DisabledScripts types.List   `tfsdk:"disabled_scripts"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+// banners returns the configured banner files of the model by path, the null attributes are left out.
+func (m motdResourceModel) banners() map[string]types.String {
+	banners := map[string]types.String{}
+
+	for filePath, value := range map[string]types.String{"/etc/motd": m.Motd, "/etc/issue": m.Issue, "/etc/issue.net": m.IssueNet} {
+		if !value.IsNull() {
+			banners[filePath] = value
+		}
+	}
+
+	return banners
+}
+
+func (r *motdResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_motd"
+}
+
+func (r *motdResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Motd resource that sets the banners of the machine: the message of the day shown after " +
+			"login, the banners shown before login on the consoles and, when sshd has 'Banner /etc/issue.net', over " +
+			"SSH. The dynamic parts of the message of the day of Ubuntu, printed by the scripts of " + updateMotdDirectory +
+			", can be disabled. Only the configured files and scripts are managed. Destroying the resource leaves the " +
+			"banners as they are and enables the disabled scripts again",
+
+		Attributes: map[string]schema.Attribute{
+			"motd": schema.StringAttribute{
+				Optional:    true,
+				Description: "The content of /etc/motd, the static message of the day shown after login",
+			},
+			"issue": schema.StringAttribute{
+				Optional:    true,
+				Description: "The content of /etc/issue, shown before the login prompt of the consoles. Backslashes are escapes of agetty(8), e.g. '\\n' for the hostname",
+			},
+			"issue_net": schema.StringAttribute{
+				Optional:    true,
+				Description: "The content of /etc/issue.net, shown before login over SSH when sshd_config has 'Banner /etc/issue.net', e.g. a legal notice",
+			},
+			"disabled_scripts": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The names of the scripts of " + updateMotdDirectory + " that are disabled by removing their executable bit, e.g. '10-help-text' or '50-motd-news'. The missing scripts are ignored",
+				Validators:  []validator.List{listOf(fileName())},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *motdResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *motdResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan motdResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	r.apply(ctx, plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *motdResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model motdResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	for filePath := range model.banners() {
+		content, err := r.provider.machineAccessClient.ReadFile(ctx, filePath, true)
+		if err != nil && !clients.IsFileNotFound(err) {
+			resp.Diagnostics.AddError("Failed to read banner", fmt.Sprintf("Failed to read %s: %s", filePath, err))
+			return
+		}
+
+		switch filePath {
+		case "/etc/motd":
+			model.Motd = types.StringValue(content)
+		case "/etc/issue":
+			model.Issue = types.StringValue(content)
+		case "/etc/issue.net":
+			model.IssueNet = types.StringValue(content)
+		}
+	}
+
+	if !model.DisabledScripts.IsNull() {
+		var scripts []string
+
+		resp.Diagnostics.Append(model.DisabledScripts.ElementsAs(ctx, &scripts, false)...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		enabled, err := r.enabledScripts(ctx, scripts)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the update-motd scripts", err.Error())
+			return
+		}
+
+		// The scripts enabled again, e.g. by hand, are disabled by the next apply
+		model.DisabledScripts, diags = types.ListValueFrom(ctx, types.StringType, difference(scripts, enabled))
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *motdResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan motdResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state motdResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &state, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *motdResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state motdResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var scripts []string
+
+	resp.Diagnostics.Append(state.DisabledScripts.ElementsAs(ctx, &scripts, false)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.setScriptsExecutable(ctx, scripts, true); err != nil {
+		resp.Diagnostics.AddError("Failed to enable the update-motd scripts", err.Error())
+		return
+	}
+}
+
+// apply writes the configured banners and disables the configured scripts. The scripts that were disabled in the
+// previous state, and are not anymore, are enabled again.
+func (r *motdResource) apply(ctx context.Context, plan motdResourceModel, previous *motdResourceModel, diags *diag.Diagnostics) {
+	for filePath, content := range plan.banners() {
+		unlock := r.provider.lockFile(ctx, filePath)
+		err := r.provider.machineAccessClient.WriteFile(ctx, filePath, "644", "0", "0", content.ValueString())
+		unlock()
+
+		if err != nil {
+			diags.AddError("Failed to write banner", fmt.Sprintf("Failed to write %s: %s", filePath, err))
+			return
+		}
+	}
+
+	var scripts, previousScripts []string
+
+	diags.Append(plan.DisabledScripts.ElementsAs(ctx, &scripts, false)...)
+
+	if previous != nil {
+		diags.Append(previous.DisabledScripts.ElementsAs(ctx, &previousScripts, false)...)
+	}
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.setScriptsExecutable(ctx, difference(previousScripts, scripts), true); err != nil {
+		diags.AddError("Failed to enable the update-motd scripts", err.Error())
+		return
+	}
+
+	if err := r.setScriptsExecutable(ctx, scripts, false); err != nil {
+		diags.AddError("Failed to disable the update-motd scripts", err.Error())
+		return
+	}
+}
+
+// setScriptsExecutable adds or removes the executable bit of the existing scripts among the given ones.
+func (r *motdResource) setScriptsExecutable(ctx context.Context, scripts []string, executable bool) error {
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	mode := "a-x"
+	if executable {
+		mode = "a+x"
+	}
+
+	script := "for script in " + motdScriptPaths(scripts) + `; do [ ! -e "$script" ] || chmod ` + mode + ` "$script"; done`
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", script)))
+	if err != nil {
+		return fmt.Errorf("Err=%s\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// enabledScripts returns the names of the given scripts that are executable.
+func (r *motdResource) enabledScripts(ctx context.Context, scripts []string) ([]string, error) {
+	if len(scripts) == 0 {
+		return nil, nil
+	}
+
+	script := "for script in " + motdScriptPaths(scripts) + `; do [ ! -x "$script" ] || basename "$script"; done`
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("sh", "-c", script))
+	if err != nil {
+		return nil, fmt.Errorf("Err=%s\nout = %s", err, out)
+	}
+
+	return strings.Fields(out), nil
+}
+
+// motdScriptPaths returns the quoted paths of the scripts of updateMotdDirectory.
+func motdScriptPaths(scripts []string) string {
+	paths := make([]string, 0, len(scripts))
+
+	for _, script := range scripts {
+		paths = append(paths, clients.ShellQuote(updateMotdDirectory+"/"+script))
+	}
+
+	return strings.Join(paths, " ")
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestMotdResourceWithMock(t *testing.T) {
+	t.Run("create writes the configured banners and disables the scripts", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		model := testMotdModel()
+		model.Motd = types.StringValue("Authorized use only\n")
+		model.DisabledScripts = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("50-motd-news")})
+
+		// Act
+		_, diags := testResourceCreate(t, newMotdResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/motd"] != "Authorized use only\n" {
+			t.Fatalf("unexpected motd: %q", mock.Files["/etc/motd"])
+		}
+
+		if _, ok := mock.Files["/etc/issue"]; ok {
+			t.Fatal("the issue is not configured and must be left as it is")
+		}
+
+		if !testMotdCommandRan(mock, "chmod a-x") || !testMotdCommandRan(mock, "/etc/update-motd.d/50-motd-news") {
+			t.Fatalf("expected the script to be disabled, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects the changed banners and the scripts enabled again", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/issue.net", "changed\n", clients.FileInfo{Mode: "644"}).
+			OnMatch(`\[ ! -x`, clients.MockResponse{Stdout: "10-help-text\n"})
+
+		model := testMotdModel()
+		model.IssueNet = types.StringValue("Authorized use only\n")
+		model.DisabledScripts = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("10-help-text"), types.StringValue("50-motd-news")})
+
+		// Act
+		state, _, diags := testResourceRead(t, newMotdResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.IssueNet.ValueString() != "changed\n" {
+			t.Fatalf("unexpected issue.net: %q", state.IssueNet.ValueString())
+		}
+
+		var scripts []string
+
+		state.DisabledScripts.ElementsAs(t.Context(), &scripts, false)
+
+		if strings.Join(scripts, ",") != "50-motd-news" {
+			t.Fatalf("unexpected disabled scripts: %v", scripts)
+		}
+	})
+
+	t.Run("update enables the scripts that are not disabled anymore", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		state := testMotdModel()
+		state.DisabledScripts = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("10-help-text"), types.StringValue("50-motd-news")})
+
+		plan := testMotdModel()
+		plan.DisabledScripts = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("50-motd-news")})
+
+		// Act
+		_, diags := testResourceUpdate(t, newMotdResource(newTestProvider(mock)), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 2 || !strings.Contains(mock.Commands[0], "a+x") || !strings.Contains(mock.Commands[0], "10-help-text") || strings.Contains(mock.Commands[0], "50-motd-news") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func testMotdModel() motdResourceModel {
+	return motdResourceModel{
+		Motd:            types.StringNull(),
+		Issue:           types.StringNull(),
+		IssueNet:        types.StringNull(),
+		DisabledScripts: types.ListNull(types.StringType),
+	}
+}
+
+func testMotdCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,600 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &netplanResource{}
+var _ resource.ResourceWithModifyPlan = &netplanResource{}
+
+const netplanDirectory = "/etc/netplan"
+
+var netplanNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func newNetplanResource(p *internalProvider) resource.Resource {
+	return &netplanResource{
+		provider:     p,
+		pollInterval: waitForPollInterval,
+	}
+}
+
+// netplanResource defines the resource implementation.
+type netplanResource struct {
+	provider *internalProvider
+
+	// pollInterval is the delay between two attempts to reach the machine after applying the configuration.
+	pollInterval time.Duration
+}
+
+type netplanResourceModel struct {
+	Name            types.String                     `tfsdk:"name"`
+	Renderer        types.String                     `tfsdk:"renderer"`
+	Ethernets       map[string]netplanInterfaceModel `tfsdk:"ethernets"`
+	VLANs           map[string]netplanVLANModel      `tfsdk:"vlans"`
+	Bonds           map[string]netplanBondModel      `tfsdk:"bonds"`
+	RollbackTimeout types.String                     `tfsdk:"rollback_timeout"`
+	Path            types.String                     `tfsdk:"path"`
+	Content         types.String                     `tfsdk:"content"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+// netplanInterfaceModel holds the settings common to all the kinds of interfaces.
+type netplanInterfaceModel struct {
+	DHCP4       types.Bool   `tfsdk:"dhcp4"`
+	DHCP6       types.Bool   `tfsdk:"dhcp6"`
+	Addresses   types.List   `tfsdk:"addresses"`
+	Gateway4    types.String `tfsdk:"gateway4"`
+	Gateway6    types.String `tfsdk:"gateway6"`
+	Nameservers types.List   `tfsdk:"nameservers"`
+	MTU         types.Int64  `tfsdk:"mtu"`
+}
+
+type netplanVLANModel struct {
+	netplanInterfaceModel
+
+	ID   types.Int64  `tfsdk:"id"`
+	Link types.String `tfsdk:"link"`
+}
+
+type netplanBondModel struct {
+	netplanInterfaceModel
+
+	Interfaces types.List   `tfsdk:"interfaces"`
+	Mode       types.String `tfsdk:"mode"`
+}
+
+func (r *netplanResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_netplan"
+}
+
+func (r *netplanResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Netplan resource that writes a netplan configuration file of " + netplanDirectory + " from " +
+			"ethernets, VLANs and bonds, and applies it with netplan apply. The configuration is validated with netplan " +
+			"generate first. As a wrong network configuration can lock the provider out, the machine restores the " +
+			"previous configuration by itself when the provider does not reconnect within rollback_timeout after " +
+			"applying it. Destroying the resource removes the file and applies the remaining configuration",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the file in " + netplanDirectory + ", without the .yaml extension, e.g. '60-terraform'. The files are applied in the order of their names",
+				Validators:  []validator.String{netplanName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"renderer": schema.StringAttribute{
+				Optional:    true,
+				Description: "The backend applying the configuration, either 'networkd' or 'NetworkManager'. Defaults to the renderer of the other files, or networkd",
+				Validators:  []validator.String{oneOf("networkd", "NetworkManager")},
+			},
+			"ethernets": schema.MapNestedAttribute{
+				Optional:    true,
+				Description: "The physical interfaces by name, e.g. 'eth0'",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: netplanInterfaceAttributes(nil),
+				},
+			},
+			"vlans": schema.MapNestedAttribute{
+				Optional:    true,
+				Description: "The VLAN interfaces by name, e.g. 'vlan10'",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: netplanInterfaceAttributes(map[string]schema.Attribute{
+						"id": schema.Int64Attribute{
+							Required:    true,
+							Description: "The VLAN id",
+						},
+						"link": schema.StringAttribute{
+							Required:    true,
+							Description: "The interface the VLAN is on, e.g. 'eth0' or 'bond0'",
+						},
+					}),
+				},
+			},
+			"bonds": schema.MapNestedAttribute{
+				Optional:    true,
+				Description: "The bonds by name, e.g. 'bond0'",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: netplanInterfaceAttributes(map[string]schema.Attribute{
+						"interfaces": schema.ListAttribute{
+							Required:    true,
+							ElementType: types.StringType,
+							Description: "The interfaces of the bond, declared in ethernets",
+						},
+						"mode": schema.StringAttribute{
+							Optional:    true,
+							Description: "The bonding mode, e.g. 'active-backup' or '802.3ad'. Defaults to 'balance-rr'",
+							Validators:  []validator.String{oneOf("balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb")},
+						},
+					}),
+				},
+			},
+			"rollback_timeout": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("2m"),
+				Description: "How long the machine waits for the provider to reconnect after applying the configuration before restoring the previous one, as a duration (e.g. '2m'). Defaults to '2m'",
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the netplan file",
+			},
+			"content": schema.StringAttribute{
+				Computed:    true,
+				Description: "The YAML content of the netplan file. A change made outside of terraform is planned as an update",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+// netplanInterfaceAttributes returns the attributes common to all the kinds of interfaces, with the extra ones.
+func netplanInterfaceAttributes(extra map[string]schema.Attribute) map[string]schema.Attribute {
+	attributes := map[string]schema.Attribute{
+		"dhcp4": schema.BoolAttribute{
+			Optional:    true,
+			Description: "Whether the IPv4 address is obtained with DHCP",
+		},
+		"dhcp6": schema.BoolAttribute{
+			Optional:    true,
+			Description: "Whether the IPv6 address is obtained with DHCPv6",
+		},
+		"addresses": schema.ListAttribute{
+			Optional:    true,
+			ElementType: types.StringType,
+			Description: "The static addresses with their prefix length, e.g. '192.168.1.10/24'",
+			Validators:  []validator.List{listOf(cidrAddress())},
+		},
+		"gateway4": schema.StringAttribute{
+			Optional:    true,
+			Description: "The IPv4 default gateway, written as a default route",
+			Validators:  []validator.String{ipAddress()},
+		},
+		"gateway6": schema.StringAttribute{
+			Optional:    true,
+			Description: "The IPv6 default gateway, written as a default route",
+			Validators:  []validator.String{ipAddress()},
+		},
+		"nameservers": schema.ListAttribute{
+			Optional:    true,
+			ElementType: types.StringType,
+			Description: "The addresses of the DNS servers",
+			Validators:  []validator.List{listOf(ipAddress())},
+		},
+		"mtu": schema.Int64Attribute{
+			Optional:    true,
+			Description: "The MTU of the interface",
+		},
+	}
+
+	for name, attribute := range extra {
+		attributes[name] = attribute
+	}
+
+	return attributes
+}
+
+func (r *netplanResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan renders the configuration, so that a file changed outside of terraform is planned as a change of content.
+func (r *netplanResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan netplanResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, diags := plan.render(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("path"), plan.path())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), content)...)
+}
+
+func (r *netplanResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan netplanResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *netplanResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model netplanResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, model.path(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be written again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read netplan file", err.Error())
+		return
+	}
+
+	model.Content = types.StringValue(content)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *netplanResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan netplanResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *netplanResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model netplanResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	timeout, err := time.ParseDuration(model.RollbackTimeout.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse rollback_timeout", err.Error())
+		return
+	}
+
+	err = r.applySafely(ctx, model.Name.ValueString(), nil, timeout)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the netplan configuration", err.Error())
+		return
+	}
+}
+
+// apply renders the configuration of the plan, sets its computed attributes, and applies it.
+func (r *netplanResource) apply(ctx context.Context, plan *netplanResourceModel, diags *diag.Diagnostics) {
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, diags) {
+		return
+	}
+
+	timeout, err := time.ParseDuration(plan.RollbackTimeout.ValueString())
+	if err != nil {
+		diags.AddError("Failed to parse rollback_timeout", err.Error())
+		return
+	}
+
+	content, d := plan.render(ctx)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err = r.applySafely(ctx, plan.Name.ValueString(), &content, timeout)
+	if err != nil {
+		diags.AddError("Failed to apply the netplan configuration", err.Error())
+		return
+	}
+
+	plan.Path = types.StringValue(plan.path())
+	plan.Content = types.StringValue(content)
+}
+
+// applySafely writes the netplan file with the content, or removes it when the content is nil, and applies the
+// configuration. The previous file is kept aside, and a background job on the machine restores it unless the provider
+// reconnects within the timeout and confirms the change.
+func (r *netplanResource) applySafely(ctx context.Context, name string, content *string, timeout time.Duration) error {
+	filePath := netplanDirectory + "/" + name + ".yaml"
+	backup := filePath + ".terraform-rollback"
+	pending := "/run/terraform-netplan-" + name + ".pending"
+	applied := "/run/terraform-netplan-" + name + ".applied"
+
+	restore := fmt.Sprintf("if [ -e %[1]s ]; then mv %[1]s %[2]s; else rm -f %[2]s; fi", clients.ShellQuote(backup), clients.ShellQuote(filePath))
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c",
+		fmt.Sprintf("rm -f %[3]s; if [ -e %[1]s ]; then cp -p %[1]s %[2]s; else rm -f %[2]s; fi", clients.ShellQuote(filePath), clients.ShellQuote(backup), clients.ShellQuote(applied)))))
+	if err != nil {
+		return fmt.Errorf("failed to back up %s. Err=%w\nout = %s", filePath, err, out)
+	}
+
+	if content != nil {
+		// netplan warns about files readable by others, as they may hold secrets such as wifi passwords
+		err = r.provider.machineAccessClient.WriteFile(ctx, filePath, "600", "0", "0", *content)
+	} else {
+		out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", filePath)))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w, out = %s", filePath, err, out)
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("netplan generate"))
+	if err != nil {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", restore)))
+
+		return fmt.Errorf("invalid netplan configuration, the previous one is kept. Err=%w\nout = %s", err, out)
+	}
+
+	// The job is detached from the SSH session, which netplan apply may drop
+	job := fmt.Sprintf("sleep 1; netplan apply; touch %[1]s; sleep %[2]d; if [ -e %[3]s ]; then %[4]s; netplan apply; rm -f %[3]s; fi",
+		clients.ShellQuote(applied), int(timeout.Seconds()), clients.ShellQuote(pending), restore)
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c",
+		"touch "+clients.ShellQuote(pending)+" && nohup "+clients.ShellCommand("sh", "-c", job)+" >/dev/null 2>&1 &")))
+	if err != nil {
+		return fmt.Errorf("failed to start netplan apply. Err=%w\nout = %s", err, out)
+	}
+
+	err = r.waitForApplied(ctx, applied, timeout)
+	if err != nil {
+		return fmt.Errorf("%w. The previous configuration is restored on the machine %s after applying the new one", err, timeout)
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", pending, applied, backup)))
+	if err != nil {
+		return fmt.Errorf("failed to confirm the netplan configuration. Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// waitForApplied reconnects to the machine until netplan apply is done, which proves that the machine is still
+// reachable with the new configuration.
+func (r *netplanResource) waitForApplied(ctx context.Context, applied string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	lastErr := errors.New("netplan apply did not finish")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the machine was not reachable within %s after netplan apply, last error: %w", timeout, lastErr)
+		case <-time.After(r.pollInterval):
+		}
+
+		_, err := r.provider.machineAccessClient.Run(ctx, clients.ShellCommand("test", "-e", applied))
+		if err == nil {
+			return nil
+		}
+
+		tflog.Info(ctx, "netplan apply is not confirmed yet: "+err.Error())
+		lastErr = err
+	}
+}
+
+func (model netplanResourceModel) path() string {
+	return netplanDirectory + "/" + model.Name.ValueString() + ".yaml"
+}
+
+// render returns the YAML content of the netplan file.
+func (model netplanResourceModel) render(ctx context.Context) (string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	network := map[string]interface{}{"version": 2}
+
+	if !model.Renderer.IsNull() {
+		network["renderer"] = model.Renderer.ValueString()
+	}
+
+	if len(model.Ethernets) > 0 {
+		ethernets := map[string]interface{}{}
+
+		for name, ethernet := range model.Ethernets {
+			ethernets[name] = ethernet.render(ctx, &diags)
+		}
+
+		network["ethernets"] = ethernets
+	}
+
+	if len(model.VLANs) > 0 {
+		vlans := map[string]interface{}{}
+
+		for name, vlan := range model.VLANs {
+			settings := vlan.render(ctx, &diags)
+			settings["id"] = vlan.ID.ValueInt64()
+			settings["link"] = vlan.Link.ValueString()
+
+			vlans[name] = settings
+		}
+
+		network["vlans"] = vlans
+	}
+
+	if len(model.Bonds) > 0 {
+		bonds := map[string]interface{}{}
+
+		for name, bond := range model.Bonds {
+			settings := bond.render(ctx, &diags)
+			settings["interfaces"] = stringList(ctx, bond.Interfaces, &diags)
+
+			if !bond.Mode.IsNull() {
+				settings["parameters"] = map[string]interface{}{"mode": bond.Mode.ValueString()}
+			}
+
+			bonds[name] = settings
+		}
+
+		network["bonds"] = bonds
+	}
+
+	if diags.HasError() {
+		return "", diags
+	}
+
+	content, err := encodeStructured(structuredFormatYAML, map[string]interface{}{"network": network})
+	if err != nil {
+		diags.AddError("Failed to render the netplan configuration", err.Error())
+	}
+
+	return content, diags
+}
+
+// render returns the netplan settings of the interface.
+func (model netplanInterfaceModel) render(ctx context.Context, diags *diag.Diagnostics) map[string]interface{} {
+	settings := map[string]interface{}{}
+
+	if !model.DHCP4.IsNull() {
+		settings["dhcp4"] = model.DHCP4.ValueBool()
+	}
+
+	if !model.DHCP6.IsNull() {
+		settings["dhcp6"] = model.DHCP6.ValueBool()
+	}
+
+	if !model.Addresses.IsNull() {
+		settings["addresses"] = stringList(ctx, model.Addresses, diags)
+	}
+
+	// gateway4 and gateway6 are deprecated by netplan in favor of default routes
+	routes := []interface{}{}
+
+	for _, gateway := range []types.String{model.Gateway4, model.Gateway6} {
+		if !gateway.IsNull() {
+			routes = append(routes, map[string]interface{}{"to": "default", "via": gateway.ValueString()})
+		}
+	}
+
+	if len(routes) > 0 {
+		settings["routes"] = routes
+	}
+
+	if !model.Nameservers.IsNull() {
+		settings["nameservers"] = map[string]interface{}{"addresses": stringList(ctx, model.Nameservers, diags)}
+	}
+
+	if !model.MTU.IsNull() {
+		settings["mtu"] = model.MTU.ValueInt64()
+	}
+
+	return settings
+}
+
+// stringList returns the elements of the list of strings.
+func stringList(ctx context.Context, list types.List, diags *diag.Diagnostics) []string {
+	elements := []string{}
+
+	diags.Append(list.ElementsAs(ctx, &elements, false)...)
+
+	return elements
+}
+
+// netplanName validates the names of netplan files.
+func netplanName() validator.String {
+	return stringValidator{
+		description: "value must only contain letters, digits, dots, dashes and underscores",
+		check: func(value string) string {
+			if !netplanNamePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must only contain letters, digits, dots, dashes and underscores", value)
+			}
+
+			return ""
+		},
+	}
+}
@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testNetplanContent = `network:
+  ethernets:
+    eth0:
+      addresses:
+        - 192.168.1.10/24
+      nameservers:
+        addresses:
+          - 1.1.1.1
+      routes:
+        - to: default
+          via: 192.168.1.1
+  version: 2
+  vlans:
+    vlan10:
+      dhcp4: false
+      id: 10
+      link: eth0
+`
+
+func TestNetplanResourceWithMock(t *testing.T) {
+	t.Run("create writes, validates and applies the configuration, then confirms it", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		state, diags := testResourceCreate(t, testNetplanResource(mock), testNetplanModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/netplan/60-terraform.yaml"] != testNetplanContent || state.Content.ValueString() != testNetplanContent {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/netplan/60-terraform.yaml"])
+		}
+
+		if mock.FileInfos["/etc/netplan/60-terraform.yaml"].Mode != "600" {
+			t.Fatalf("unexpected mode: %s", mock.FileInfos["/etc/netplan/60-terraform.yaml"].Mode)
+		}
+
+		if !slices.Contains(mock.Commands, "netplan generate") {
+			t.Fatalf("expected the configuration to be validated, got: %v", mock.Commands)
+		}
+
+		if !strings.Contains(mock.Commands[len(mock.Commands)-1], "rm -f /run/terraform-netplan-60-terraform.pending") {
+			t.Fatalf("expected the configuration to be confirmed, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("an invalid configuration is restored without being applied", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("netplan generate", clients.MockResponse{Stderr: "Invalid YAML", ExitCode: 1})
+
+		// Act
+		_, diags := testResourceCreate(t, testNetplanResource(mock), testNetplanModel())
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		for _, command := range mock.Commands {
+			if strings.Contains(command, "netplan apply") {
+				t.Fatalf("unexpected netplan apply: %v", mock.Commands)
+			}
+		}
+
+		if !strings.Contains(mock.Commands[len(mock.Commands)-1], "then mv ") {
+			t.Fatalf("expected the previous configuration to be restored, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("the configuration is not confirmed when the machine is not reachable anymore", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^test -e ", clients.MockResponse{Err: errors.New("connection timed out")})
+
+		model := testNetplanModel()
+		model.RollbackTimeout = types.StringValue("50ms")
+
+		// Act
+		_, diags := testResourceCreate(t, testNetplanResource(mock), model)
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "The previous configuration is restored") {
+			t.Fatalf("expected a rollback error, got: %v", diags)
+		}
+
+		for _, command := range mock.Commands {
+			if strings.Contains(command, "rm -f /run/terraform-netplan-60-terraform.pending") {
+				t.Fatalf("unexpected confirmation: %v", mock.Commands)
+			}
+		}
+	})
+
+	t.Run("read detects changes of the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/netplan/60-terraform.yaml", "network:\n  version: 2\n", clients.FileInfo{Mode: "600"})
+
+		model := testNetplanModel()
+		model.Path = types.StringValue("/etc/netplan/60-terraform.yaml")
+		model.Content = types.StringValue(testNetplanContent)
+
+		// Act
+		state, _, diags := testResourceRead(t, testNetplanResource(mock), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Content.ValueString() != "network:\n  version: 2\n" {
+			t.Fatalf("unexpected content: %q", state.Content.ValueString())
+		}
+	})
+
+	t.Run("delete removes the file and applies the remaining configuration", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		model := testNetplanModel()
+		model.Path = types.StringValue("/etc/netplan/60-terraform.yaml")
+		model.Content = types.StringValue(testNetplanContent)
+
+		// Act
+		diags := testResourceDelete(t, testNetplanResource(mock), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "rm -f /etc/netplan/60-terraform.yaml") {
+			t.Fatalf("expected the file to be removed, got: %v", mock.Commands)
+		}
+	})
+}
+
+func testNetplanResource(mock *clients.MockMachineAccessClient) *netplanResource {
+	r := newNetplanResource(newTestProvider(mock)).(*netplanResource)
+	r.pollInterval = time.Millisecond
+
+	return r
+}
+
+func testNetplanModel() netplanResourceModel {
+	addresses, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"192.168.1.10/24"})
+	nameservers, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"1.1.1.1"})
+
+	return netplanResourceModel{
+		Name:     types.StringValue("60-terraform"),
+		Renderer: types.StringNull(),
+		Ethernets: map[string]netplanInterfaceModel{
+			"eth0": {
+				DHCP4:       types.BoolNull(),
+				DHCP6:       types.BoolNull(),
+				Addresses:   addresses,
+				Gateway4:    types.StringValue("192.168.1.1"),
+				Gateway6:    types.StringNull(),
+				Nameservers: nameservers,
+				MTU:         types.Int64Null(),
+			},
+		},
+		VLANs: map[string]netplanVLANModel{
+			"vlan10": {
+				netplanInterfaceModel: netplanInterfaceModel{
+					DHCP4:       types.BoolValue(false),
+					DHCP6:       types.BoolNull(),
+					Addresses:   types.ListNull(types.StringType),
+					Gateway4:    types.StringNull(),
+					Gateway6:    types.StringNull(),
+					Nameservers: types.ListNull(types.StringType),
+					MTU:         types.Int64Null(),
+				},
+				ID:   types.Int64Value(10),
+				Link: types.StringValue("eth0"),
+			},
+		},
+		RollbackTimeout: types.StringValue("1m"),
+		Path:            types.StringUnknown(),
+		Content:         types.StringUnknown(),
+	}
+}
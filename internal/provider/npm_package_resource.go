@@ -0,0 +1,274 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &npmPackageResource{}
+var _ resource.ResourceWithImportState = &npmPackageResource{}
+
+func newNpmPackageResource(p *internalProvider) resource.Resource {
+	return &npmPackageResource{
+		provider: p,
+	}
+}
+
+// npmPackageResource defines the resource implementation.
+type npmPackageResource struct {
+	provider *internalProvider
+}
+
+type npmPackageResourceModel struct {
+	Name     types.String   `tfsdk:"name"`
+	Version  types.String   `tfsdk:"version"`
+	Timeouts *timeoutsModel `tfsdk:"timeouts"`
+	Retry    *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *npmPackageResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_npm_package"
+}
+
+func (r *npmPackageResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Npm package resource that installs a global npm package",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the npm package",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"version": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The version of the npm package. If not specified, the latest version is installed and the installed version is tracked",
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *npmPackageResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *npmPackageResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan npmPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install npm package", err.Error())
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, plan.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed npm package version", err.Error())
+		return
+	}
+
+	if !found {
+		resp.Diagnostics.AddError("Npm package not found after installation", "The package "+plan.Name.ValueString()+" is not listed by npm ls -g")
+		return
+	}
+
+	plan.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *npmPackageResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model npmPackageResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, model.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed npm package version", err.Error())
+		return
+	}
+
+	if !found {
+		// Package is not installed anymore, remove from state
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	model.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *npmPackageResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan npmPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install npm package", err.Error())
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, plan.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed npm package version", err.Error())
+		return
+	}
+
+	if !found {
+		resp.Diagnostics.AddError("Npm package not found after installation", "The package "+plan.Name.ValueString()+" is not listed by npm ls -g")
+		return
+	}
+
+	plan.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *npmPackageResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model npmPackageResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("npm", "uninstall", "-g", model.Name.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to uninstall npm package", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+func (r *npmPackageResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+}
+
+func (r *npmPackageResource) install(ctx context.Context, model npmPackageResourceModel) error {
+	spec := model.Name.ValueString()
+	if !model.Version.IsNull() && !model.Version.IsUnknown() && model.Version.ValueString() != "" {
+		spec += "@" + model.Version.ValueString()
+	}
+
+	out, err := retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(clients.ShellCommand("npm", "install", "-g", spec))))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install %s. Err=%w\nout = %s", spec, err, out)
+	}
+
+	return nil
+}
+
+type npmListOutput struct {
+	Dependencies map[string]struct {
+		Version string `json:"version"`
+	} `json:"dependencies"`
+}
+
+// installedVersion returns the globally installed version of the package, and whether the package is installed at all.
+func (r *npmPackageResource) installedVersion(ctx context.Context, name string) (string, bool, error) {
+	// npm ls exits with a non-zero code when the package is missing, but still prints valid json
+	out, _ := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("npm", "ls", "-g", "--depth=0", "--json", name)+" 2>/dev/null")
+
+	var list npmListOutput
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return "", false, fmt.Errorf("failed to parse npm ls output: %w\nout = %s", err, out)
+	}
+
+	dependency, ok := list.Dependencies[name]
+	if !ok {
+		return "", false, nil
+	}
+
+	return dependency.Version, true, nil
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestNpmPackageResource(t *testing.T) {
+	// Arrange - set up a single Docker container for all test cases
+	setup := setupTestEnvironment(t)
+
+	t.Run("Test create, update and removed", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testNpmPackageResourceConfig("left-pad", "1.2.0"),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_npm_package.test", "name", "left-pad"),
+						resource.TestCheckResourceAttr("setup_npm_package.test", "version", "1.2.0"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							out, err := sshClient.RunCommand(context.Background(), "npm ls -g --depth=0 left-pad")
+							if err != nil {
+								return fmt.Errorf("package left-pad not installed: %w", err)
+							}
+
+							if !strings.Contains(out, "left-pad@1.2.0") {
+								return fmt.Errorf("unexpected npm ls output: %s", out)
+							}
+
+							return nil
+						},
+					),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testNpmPackageResourceConfig("left-pad", "1.3.0"),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_npm_package.test", "version", "1.3.0"),
+					),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testNpmPackageResourcePrerequisitesConfig(),
+					Check: resource.ComposeTestCheckFunc(
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							_, err = sshClient.RunCommand(context.Background(), "npm ls -g --depth=0 left-pad")
+							if err == nil {
+								return fmt.Errorf("package left-pad was not uninstalled")
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func testNpmPackageResourcePrerequisitesConfig() string {
+	return `
+resource "setup_apt_packages" "npm" {
+	package {
+		name = "npm"
+	}
+}
+`
+}
+
+func testNpmPackageResourceConfig(name string, version string) string {
+	return testNpmPackageResourcePrerequisitesConfig() + fmt.Sprintf(`
+resource "setup_npm_package" "test" {
+	name    = "%s"
+	version = "%s"
+
+	depends_on = [setup_apt_packages.npm]
+}
+`, name, version)
+}
@@ -0,0 +1,523 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &nvidiaGPUResource{}
+var _ resource.ResourceWithModifyPlan = &nvidiaGPUResource{}
+
+const (
+	nvidiaContainerToolkitKeyring     = "/etc/apt/keyrings/nvidia-container-toolkit.asc"
+	nvidiaContainerToolkitSourcesList = "/etc/apt/sources.list.d/nvidia-container-toolkit.list"
+	nvidiaContainerToolkitRepository  = "https://nvidia.github.io/libnvidia-container"
+	nvidiaContainerToolkitPackage     = "nvidia-container-toolkit"
+	nvidiaDockerDaemonConfig          = "/etc/docker/daemon.json"
+	nvidiaLoadedDriverVersionFile     = "/proc/driver/nvidia/version"
+	nvidiaContainerRuntime            = "nvidia"
+	nvidiaContainerRuntimeExecutable  = "nvidia-container-runtime"
+)
+
+// nvidiaLoadedDriverVersionPattern matches the version in /proc/driver/nvidia/version, e.g.
+// "NVRM version: NVIDIA UNIX x86_64 Kernel Module  550.54.14  Thu Feb 22 01:44:30 UTC 2024", or
+// "NVRM version: NVIDIA UNIX Open Kernel Module for x86_64  550.54.14  Release Build ..." for the open modules.
+var nvidiaLoadedDriverVersionPattern = regexp.MustCompile(`Kernel Module(?: for \S+)?\s+([0-9][0-9.]*)`)
+
+func newNvidiaGPUResource(p *internalProvider) resource.Resource {
+	return &nvidiaGPUResource{
+		provider: p,
+	}
+}
+
+// nvidiaGPUResource defines the resource implementation.
+type nvidiaGPUResource struct {
+	provider *internalProvider
+}
+
+type nvidiaGPUResourceModel struct {
+	DriverPackage    types.String   `tfsdk:"driver_package"`
+	ContainerToolkit types.Bool     `tfsdk:"container_toolkit"`
+	DefaultRuntime   types.Bool     `tfsdk:"default_runtime"`
+	AptOptions       types.List     `tfsdk:"apt_options"`
+	DriverVersion    types.String   `tfsdk:"driver_version"`
+	RebootRequired   types.Bool     `tfsdk:"reboot_required"`
+	Timeouts         *timeoutsModel `tfsdk:"timeouts"`
+	Retry            *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *nvidiaGPUResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_nvidia_gpu"
+}
+
+func (r *nvidiaGPUResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "NVIDIA GPU resource that installs the NVIDIA driver with apt and, by default, the NVIDIA " +
+			"Container Toolkit from the repository of NVIDIA, registering its nvidia runtime in " + nvidiaDockerDaemonConfig +
+			". dockerd is restarted, when running, only if the file changed. The new driver is only loaded once the host " +
+			"is rebooted, see reboot_required, e.g. with setup_reboot. Destroying the resource removes the nvidia runtime " +
+			"from the Docker configuration and leaves the packages installed",
+
+		Attributes: map[string]schema.Attribute{
+			"driver_package": schema.StringAttribute{
+				Required:    true,
+				Description: "The apt package of the driver, e.g. nvidia-driver-550-server on Ubuntu, or nvidia-driver from the non-free component on Debian",
+				Validators:  []validator.String{notBlank()},
+			},
+			"container_toolkit": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether the NVIDIA Container Toolkit is installed from " + nvidiaContainerToolkitRepository + " and its runtime registered in the Docker configuration, so that the containers get the GPUs with --gpus. Defaults to true",
+			},
+			"default_runtime": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the nvidia runtime is the default runtime of Docker, which gives the GPUs to the containers started without --gpus, e.g. by docker compose. Requires container_toolkit. Defaults to false",
+			},
+			"apt_options": aptOptionsAttribute(),
+			"driver_version": schema.StringAttribute{
+				Computed:    true,
+				Description: "The installed version of the driver package",
+			},
+			"reboot_required": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the kernel module of the installed driver is not the loaded one, read from " + nvidiaLoadedDriverVersionFile + ", so that the host must be rebooted for the driver to apply. It stays true on a host without NVIDIA GPU, where no module is loaded",
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *nvidiaGPUResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan checks that the nvidia runtime is only made the default runtime of Docker along with the toolkit.
+func (r *nvidiaGPUResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan nvidiaGPUResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.DefaultRuntime.ValueBool() && !plan.ContainerToolkit.ValueBool() {
+		resp.Diagnostics.AddAttributeError(path.Root("default_runtime"), "Invalid default runtime", "the nvidia runtime can only be the default runtime of Docker when container_toolkit is set")
+	}
+}
+
+func (r *nvidiaGPUResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan nvidiaGPUResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	// The Docker configuration restarts docker, which must not happen while docker_setup installs it
+	defer r.provider.lock(ctx, dockerLock)()
+
+	r.apply(ctx, &plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *nvidiaGPUResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model nvidiaGPUResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// dpkg-query exits with 1 when the package is not installed, the other errors are failures to read it
+	version, err := r.installedVersion(ctx, model.DriverPackage.ValueString())
+	if clients.IsExitCode(err, 1) {
+		// The driver was removed outside of terraform, it must be installed again
+		tflog.Debug(ctx, "The NVIDIA driver is not installed: "+err.Error())
+		resp.State.RemoveResource(ctx)
+
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the NVIDIA driver version", err.Error())
+		return
+	}
+
+	model.DriverVersion = version
+
+	if model.ContainerToolkit.ValueBool() {
+		installed, registered, defaultRuntime, err := r.readContainerToolkit(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the NVIDIA Container Toolkit", err.Error())
+			return
+		}
+
+		// A toolkit removed, or a runtime unregistered, outside of terraform is planned to be set up again
+		model.ContainerToolkit = types.BoolValue(installed && registered)
+		model.DefaultRuntime = types.BoolValue(defaultRuntime)
+	}
+
+	rebootRequired, err := r.rebootRequired(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the loaded NVIDIA driver", err.Error())
+		return
+	}
+
+	model.RebootRequired = types.BoolValue(rebootRequired)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *nvidiaGPUResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan nvidiaGPUResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state nvidiaGPUResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	defer r.provider.lock(ctx, dockerLock)()
+
+	r.apply(ctx, &plan, &state, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *nvidiaGPUResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model nvidiaGPUResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !model.ContainerToolkit.ValueBool() {
+		return
+	}
+
+	_, err := r.provider.machineAccessClient.Stat(ctx, nvidiaDockerDaemonConfig, true)
+	if clients.IsFileNotFound(err) {
+		return
+	}
+
+	defer r.provider.lock(ctx, dockerLock)()
+
+	err = r.configureDocker(ctx, false, false, model.DefaultRuntime.ValueBool())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the nvidia runtime from the Docker configuration", err.Error())
+		return
+	}
+}
+
+// apply installs the packages, registers the runtime of the toolkit in the Docker configuration, or removes it when
+// the toolkit is not wanted anymore, and reads the computed attributes.
+func (r *nvidiaGPUResource) apply(ctx context.Context, plan *nvidiaGPUResourceModel, previous *nvidiaGPUResourceModel, diags *diag.Diagnostics) {
+	packages := []string{plan.DriverPackage.ValueString()}
+
+	if plan.ContainerToolkit.ValueBool() {
+		if err := r.addRepository(ctx); err != nil {
+			diags.AddError("Failed to add the NVIDIA Container Toolkit repository", err.Error())
+			return
+		}
+
+		packages = append(packages, nvidiaContainerToolkitPackage)
+	}
+
+	if err := r.install(ctx, *plan, packages); err != nil {
+		diags.AddError("Failed to install the NVIDIA packages", err.Error())
+		return
+	}
+
+	wasDefaultRuntime := previous != nil && previous.DefaultRuntime.ValueBool()
+
+	if plan.ContainerToolkit.ValueBool() || (previous != nil && previous.ContainerToolkit.ValueBool()) {
+		err := r.configureDocker(ctx, plan.ContainerToolkit.ValueBool(), plan.DefaultRuntime.ValueBool(), wasDefaultRuntime)
+		if err != nil {
+			diags.AddError("Failed to register the nvidia runtime in the Docker configuration", err.Error())
+			return
+		}
+	}
+
+	var err error
+
+	plan.DriverVersion, err = r.installedVersion(ctx, plan.DriverPackage.ValueString())
+	if err != nil {
+		diags.AddError("Failed to get the NVIDIA driver version", err.Error())
+		return
+	}
+
+	rebootRequired, err := r.rebootRequired(ctx)
+	if err != nil {
+		diags.AddError("Failed to read the loaded NVIDIA driver", err.Error())
+		return
+	}
+
+	plan.RebootRequired = types.BoolValue(rebootRequired)
+}
+
+// addRepository adds the repository of the NVIDIA Container Toolkit, signed by its own key in /etc/apt/keyrings.
+func (r *nvidiaGPUResource) addRepository(ctx context.Context) error {
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		return fmt.Errorf("failed to configure the apt proxy: %w", err)
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	out, err := r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.provider.sudo(r.provider.withProxy(
+		"apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y ca-certificates curl && "+
+			"install -m 0755 -d /etc/apt/keyrings && "+
+			"curl -fsSL "+nvidiaContainerToolkitRepository+"/gpgkey -o "+nvidiaContainerToolkitKeyring+" && "+
+			"chmod a+r "+nvidiaContainerToolkitKeyring)))
+	if err != nil {
+		return fmt.Errorf("failed to download the NVIDIA key. Err=%w\nout = %s", err, out)
+	}
+
+	arch, err := r.provider.machineAccessClient.RunCommand(ctx, "dpkg --print-architecture")
+	if err != nil {
+		return fmt.Errorf("failed to get the architecture. Err=%w\nout = %s", err, arch)
+	}
+
+	// The repository is flat, with a directory by architecture rather than by distribution
+	sources := "deb [signed-by=" + nvidiaContainerToolkitKeyring + "] " + nvidiaContainerToolkitRepository + "/stable/deb/" + strings.TrimSpace(arch) + " /\n"
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, nvidiaContainerToolkitSourcesList, "0644", "0", "0", sources)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", nvidiaContainerToolkitSourcesList, err)
+	}
+
+	return nil
+}
+
+// install installs the packages from the freshly updated package lists.
+func (r *nvidiaGPUResource) install(ctx context.Context, plan nvidiaGPUResourceModel, packages []string) error {
+	var options []string
+
+	if diags := plan.AptOptions.ElementsAs(ctx, &options, false); diags.HasError() {
+		return fmt.Errorf("invalid apt options: %v", diags)
+	}
+
+	if err := r.provider.configureAptProxy(ctx); err != nil {
+		return fmt.Errorf("failed to configure the apt proxy: %w", err)
+	}
+
+	defer r.provider.lock(ctx, aptLock)()
+
+	command := r.provider.sudo(r.provider.withProxy(aptGet(options, "update") + " && " + aptGet(options, append([]string{"install", "-y"}, packages...)...)))
+
+	out, err := retry(ctx, plan.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), command)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install %s. Err=%w\nout = %s", strings.Join(packages, ", "), err, out)
+	}
+
+	return nil
+}
+
+// configureDocker registers the nvidia runtime in the Docker configuration, or removes it, and makes it the default
+// runtime. The default runtime is only removed when the resource set it. dockerd is restarted when the file changed.
+func (r *nvidiaGPUResource) configureDocker(ctx context.Context, register bool, defaultRuntime bool, wasDefaultRuntime bool) error {
+	patch := map[string]interface{}{
+		"runtimes": map[string]interface{}{
+			nvidiaContainerRuntime: nil,
+		},
+	}
+
+	if register {
+		patch["runtimes"] = map[string]interface{}{
+			nvidiaContainerRuntime: map[string]interface{}{
+				"path": nvidiaContainerRuntimeExecutable,
+				"args": []interface{}{},
+			},
+		}
+	}
+
+	if register && defaultRuntime {
+		patch["default-runtime"] = nvidiaContainerRuntime
+	} else if wasDefaultRuntime {
+		patch["default-runtime"] = nil
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("mkdir -p /etc/docker"))
+	if err != nil {
+		return fmt.Errorf("failed to create the docker configuration directory. Err=%w\nout = %s", err, out)
+	}
+
+	changed, err := r.provider.patchStructuredFile(ctx, structuredFormatJSON, nvidiaDockerDaemonConfig, true, patch)
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+
+	// try-restart leaves a stopped, or not yet installed, docker as it is
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl try-restart docker"))
+	if err != nil {
+		return fmt.Errorf("failed to restart docker. Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// readContainerToolkit returns whether the toolkit is installed, whether its runtime is registered in the Docker
+// configuration, and whether it is the default runtime.
+func (r *nvidiaGPUResource) readContainerToolkit(ctx context.Context) (bool, bool, bool, error) {
+	_, err := r.installedVersion(ctx, nvidiaContainerToolkitPackage)
+	installed := err == nil
+
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, nvidiaDockerDaemonConfig, true)
+	if clients.IsFileNotFound(err) {
+		return installed, false, false, nil
+	}
+
+	if err != nil {
+		return false, false, false, err
+	}
+
+	document, err := decodeStructured(structuredFormatJSON, content)
+	if err != nil {
+		return false, false, false, fmt.Errorf("failed to parse %s: %w", nvidiaDockerDaemonConfig, err)
+	}
+
+	config, _ := document.(map[string]interface{})
+	runtimes, _ := config["runtimes"].(map[string]interface{})
+	_, registered := runtimes[nvidiaContainerRuntime]
+
+	return installed, registered, config["default-runtime"] == nvidiaContainerRuntime, nil
+}
+
+func (r *nvidiaGPUResource) installedVersion(ctx context.Context, pkg string) (types.String, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "dpkg-query -W -f='${Version}' "+clients.ShellQuote(pkg))
+	if err != nil {
+		return types.StringNull(), fmt.Errorf("%s is not installed. Err=%w\nout = %s", pkg, err, out)
+	}
+
+	return types.StringValue(strings.TrimSpace(out)), nil
+}
+
+// rebootRequired returns whether the loaded kernel module is not the one of the installed driver, or no module is
+// loaded.
+func (r *nvidiaGPUResource) rebootRequired(ctx context.Context) (bool, error) {
+	loaded, err := r.provider.machineAccessClient.ReadFile(ctx, nvidiaLoadedDriverVersionFile, false)
+	if clients.IsFileNotFound(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	match := nvidiaLoadedDriverVersionPattern.FindStringSubmatch(loaded)
+	if match == nil {
+		return false, fmt.Errorf("unexpected content of %s: %s", nvidiaLoadedDriverVersionFile, loaded)
+	}
+
+	// The module of the running kernel may not be built yet, e.g. after a kernel upgrade, which also needs a reboot
+	installed, err := r.provider.machineAccessClient.RunCommand(ctx, "/sbin/modinfo -F version nvidia")
+	if err != nil {
+		tflog.Debug(ctx, fmt.Sprintf("The NVIDIA kernel module is not installed. Err=%s\nout = %s", err, installed))
+		return true, nil
+	}
+
+	return strings.TrimSpace(installed) != match[1], nil
+}
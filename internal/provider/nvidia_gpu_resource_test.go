@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testNvidiaLoadedDriverVersion = "NVRM version: NVIDIA UNIX x86_64 Kernel Module  550.54.14  Thu Feb 22 01:44:30 UTC 2024\nGCC version:  gcc version 12.2.0 (Debian 12.2.0-14)\n"
+
+func TestNvidiaGPUResourceWithMock(t *testing.T) {
+	t.Run("create installs the packages and registers the runtime", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(nvidiaDockerDaemonConfig, "{\n  \"log-driver\": \"journald\"\n}\n", clients.FileInfo{Mode: "644"}).
+			On("dpkg --print-architecture", clients.MockResponse{Stdout: "amd64\n"}).
+			OnMatch(`^dpkg-query `, clients.MockResponse{Stdout: "550.54.14-0ubuntu1"})
+
+		model := testNvidiaGPUModel()
+		model.DefaultRuntime = types.BoolValue(true)
+
+		// Act
+		state, diags := testResourceCreate(t, newNvidiaGPUResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[nvidiaContainerToolkitSourcesList] != "deb [signed-by=/etc/apt/keyrings/nvidia-container-toolkit.asc] https://nvidia.github.io/libnvidia-container/stable/deb/amd64 /\n" {
+			t.Fatalf("unexpected sources list: %s", mock.Files[nvidiaContainerToolkitSourcesList])
+		}
+
+		expected := "{\n  \"default-runtime\": \"nvidia\",\n  \"log-driver\": \"journald\",\n  \"runtimes\": {\n    \"nvidia\": {\n      \"args\": [],\n      \"path\": \"nvidia-container-runtime\"\n    }\n  }\n}\n"
+		if mock.Files[nvidiaDockerDaemonConfig] != expected {
+			t.Fatalf("unexpected docker configuration:\n%s", mock.Files[nvidiaDockerDaemonConfig])
+		}
+
+		if !testNvidiaGPUCommandRan(mock, "install -y nvidia-driver-550-server nvidia-container-toolkit") || !testNvidiaGPUCommandRan(mock, "systemctl try-restart docker") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		// No module is loaded before the first reboot
+		if state.DriverVersion.ValueString() != "550.54.14-0ubuntu1" || !state.RebootRequired.ValueBool() {
+			t.Fatalf("unexpected state: %v", state)
+		}
+	})
+
+	t.Run("create without the toolkit leaves docker as it is", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(nvidiaLoadedDriverVersionFile, testNvidiaLoadedDriverVersion, clients.FileInfo{Mode: "444"}).
+			On("/sbin/modinfo -F version nvidia", clients.MockResponse{Stdout: "550.54.14\n"})
+
+		model := testNvidiaGPUModel()
+		model.ContainerToolkit = types.BoolValue(false)
+
+		// Act
+		state, diags := testResourceCreate(t, newNvidiaGPUResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if _, ok := mock.Files[nvidiaDockerDaemonConfig]; ok || testNvidiaGPUCommandRan(mock, "nvidia-container-toolkit") {
+			t.Fatalf("unexpected toolkit setup: %v", mock.Commands)
+		}
+
+		if state.RebootRequired.ValueBool() {
+			t.Fatal("expected the loaded driver to be the installed one")
+		}
+	})
+
+	t.Run("delete unregisters the runtime and the default runtime", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(nvidiaDockerDaemonConfig, "{\n  \"default-runtime\": \"nvidia\",\n  \"runtimes\": {\n    \"nvidia\": {\n      \"args\": [],\n      \"path\": \"nvidia-container-runtime\"\n    }\n  }\n}\n", clients.FileInfo{Mode: "644"})
+
+		state := testNvidiaGPUModel()
+		state.DefaultRuntime = types.BoolValue(true)
+		state.DriverVersion = types.StringValue("550.54.14-0ubuntu1")
+		state.RebootRequired = types.BoolValue(false)
+
+		// Act
+		diags := testResourceDelete(t, newNvidiaGPUResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if strings.Contains(mock.Files[nvidiaDockerDaemonConfig], "nvidia") {
+			t.Fatalf("unexpected docker configuration:\n%s", mock.Files[nvidiaDockerDaemonConfig])
+		}
+	})
+
+	t.Run("read reports a newer installed driver", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(nvidiaLoadedDriverVersionFile, strings.Replace(testNvidiaLoadedDriverVersion, "x86_64 Kernel Module", "Open Kernel Module for x86_64", 1), clients.FileInfo{Mode: "444"}).
+			WithFile(nvidiaDockerDaemonConfig, "{\n  \"runtimes\": {\n    \"nvidia\": {\n      \"path\": \"nvidia-container-runtime\"\n    }\n  }\n}\n", clients.FileInfo{Mode: "644"}).
+			OnMatch(`^dpkg-query `, clients.MockResponse{Stdout: "560.35.03-0ubuntu1"}).
+			On("/sbin/modinfo -F version nvidia", clients.MockResponse{Stdout: "560.35.03\n"})
+
+		state := testNvidiaGPUModel()
+		state.DriverVersion = types.StringValue("550.54.14-0ubuntu1")
+		state.RebootRequired = types.BoolValue(false)
+
+		// Act
+		read, removed, diags := testResourceRead(t, newNvidiaGPUResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected read: %v", diags)
+		}
+
+		if !read.RebootRequired.ValueBool() || read.DriverVersion.ValueString() != "560.35.03-0ubuntu1" || !read.ContainerToolkit.ValueBool() {
+			t.Fatalf("unexpected state: %v", read)
+		}
+	})
+
+	t.Run("read keeps the resource when the driver version cannot be read", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^dpkg-query `, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		state := testNvidiaGPUModel()
+		state.DriverVersion = types.StringValue("550.54.14-0ubuntu1")
+		state.RebootRequired = types.BoolValue(false)
+
+		// Act
+		_, removed, diags := testResourceRead(t, newNvidiaGPUResource(newTestProvider(mock)), state)
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+
+	t.Run("plan rejects the default runtime without the toolkit", func(t *testing.T) {
+		// Arrange
+		plan := testNvidiaGPUModel()
+		plan.ContainerToolkit = types.BoolValue(false)
+		plan.DefaultRuntime = types.BoolValue(true)
+		plan.DriverVersion = types.StringNull()
+		plan.RebootRequired = types.BoolNull()
+
+		// Act
+		_, diags := testResourceModifyPlan(t, newNvidiaGPUResource(newTestProvider(clients.NewMockMachineAccessClient())).(*nvidiaGPUResource), nil, plan)
+
+		// Assert
+		if diags.ErrorsCount() != 1 {
+			t.Fatalf("expected an invalid default runtime, got: %v", diags)
+		}
+	})
+}
+
+func testNvidiaGPUModel() nvidiaGPUResourceModel {
+	return nvidiaGPUResourceModel{
+		DriverPackage:    types.StringValue("nvidia-driver-550-server"),
+		ContainerToolkit: types.BoolValue(true),
+		DefaultRuntime:   types.BoolValue(false),
+		AptOptions:       defaultAptOptions(),
+		DriverVersion:    types.StringUnknown(),
+		RebootRequired:   types.BoolUnknown(),
+	}
+}
+
+func testNvidiaGPUCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = &octalFunction{}
+
+var (
+	lsModePattern       = regexp.MustCompile(`^[r-][w-][xsS-][r-][w-][xsS-][r-][w-][xtT-]$`)
+	symbolicModePattern = regexp.MustCompile(`^([ugoa]*)[=+]([rwxst]*)$`)
+)
+
+// symbolicModeBits are the permission bits of the classes of users of a symbolic mode.
+var symbolicModeBits = map[byte]map[byte]uint32{
+	'u': {'r': 0400, 'w': 0200, 'x': 0100, 's': 04000},
+	'g': {'r': 040, 'w': 020, 'x': 010, 's': 02000},
+	'o': {'r': 04, 'w': 02, 'x': 01, 't': 01000},
+}
+
+func newOctalFunction() function.Function {
+	return &octalFunction{}
+}
+
+type octalFunction struct{}
+
+func (f *octalFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "octal"
+}
+
+func (f *octalFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Returns a file mode as the 4 octal digits expected by the mode attributes",
+		Description: "Returns the file mode as 4 octal digits, e.g. '0644', failing on an invalid mode. The mode is given as 3 or 4 " +
+			"octal digits, e.g. '755', as listed by ls, e.g. 'rwxr-x---', or as a symbolic mode of chmod starting from no " +
+			"permission, e.g. 'u=rw,go=r'",
+
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "mode",
+				Description: "The file mode",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *octalFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var mode string
+
+	resp.Error = req.Arguments.Get(ctx, &mode)
+	if resp.Error != nil {
+		return
+	}
+
+	octal, err := parseFileMode(mode)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, octal)
+}
+
+// parseFileMode returns the mode, given as octal digits, as listed by ls or as a symbolic mode, as 4 octal digits.
+func parseFileMode(mode string) (string, error) {
+	if octalModePattern.MatchString(mode) {
+		bits, _ := strconv.ParseUint(mode, 8, 32)
+
+		return fmt.Sprintf("%04o", bits), nil
+	}
+
+	if lsModePattern.MatchString(mode) {
+		return fmt.Sprintf("%04o", lsModeBits(mode)), nil
+	}
+
+	var bits uint32
+
+	for _, clause := range strings.Split(mode, ",") {
+		match := symbolicModePattern.FindStringSubmatch(clause)
+		if match == nil {
+			return "", fmt.Errorf("'%s' is not a file mode of 3 or 4 octal digits, e.g. '644', as listed by ls, e.g. 'rw-r--r--', or symbolic, e.g. 'u=rw,go=r'", mode)
+		}
+
+		classes := match[1]
+		if classes == "" || strings.Contains(classes, "a") {
+			classes = "ugo"
+		}
+
+		for _, class := range []byte(classes) {
+			for _, permission := range []byte(match[2]) {
+				bits |= symbolicModeBits[class][permission]
+			}
+		}
+	}
+
+	return fmt.Sprintf("%04o", bits), nil
+}
+
+// lsModeBits returns the bits of a mode as listed by ls, where s and t are the setuid, setgid and sticky bits along
+// with the execute permission, and S and T without it.
+func lsModeBits(mode string) uint32 {
+	var bits uint32
+
+	for i := 0; i < 9; i++ {
+		if mode[i] != '-' && mode[i] != 'S' && mode[i] != 'T' {
+			bits |= 1 << (8 - i)
+		}
+	}
+
+	for i, special := range []uint32{04000, 02000, 01000} {
+		if c := mode[i*3+2]; c == 's' || c == 'S' || c == 't' || c == 'T' {
+			bits |= special
+		}
+	}
+
+	return bits
+}
@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestOctalFunction(t *testing.T) {
+	for mode, expected := range map[string]string{
+		"644":            "0644",
+		"1777":           "1777",
+		"rwxr-x---":      "0750",
+		"rwsr-xr-x":      "4755",
+		"rwxrwxrwT":      "1776",
+		"u=rw,go=r":      "0644",
+		"a=rx,u+w":       "0755",
+		"u=rwx,g=rxs,o=": "2750",
+	} {
+		t.Run(mode, func(t *testing.T) {
+			// Act
+			octal, err := testFunctionRun(t, newOctalFunction(), types.StringValue(mode))
+
+			// Assert
+			if err != nil || octal != expected {
+				t.Fatalf("unexpected mode: %s, %v", octal, err)
+			}
+		})
+	}
+
+	for _, mode := range []string{"", "999", "0o644", "rw-r--r", "u=rw;g=r", "g-w"} {
+		t.Run("rejects "+mode, func(t *testing.T) {
+			// Act
+			_, err := testFunctionRun(t, newOctalFunction(), types.StringValue(mode))
+
+			// Assert
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &packagesDataSource{}
+)
+
+func newPackagesDataSource(p *internalProvider) datasource.DataSource {
+	return &packagesDataSource{
+		provider: p,
+	}
+}
+
+type packagesDataSource struct {
+	provider *internalProvider
+}
+
+type packagesDataSourceModel struct {
+	Names          []types.String `tfsdk:"names"`
+	PackageManager types.String   `tfsdk:"package_manager"`
+	Versions       types.Map      `tfsdk:"versions"`
+	Missing        []types.String `tfsdk:"missing"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *packagesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_packages"
+}
+
+func (d *packagesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Reads the installed versions of packages with dpkg on Debian based systems, or rpm on Red Hat " +
+			"based systems, e.g. to skip adding a repository when a recent enough version is already installed",
+
+		Attributes: map[string]schema.Attribute{
+			"names": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The names of the packages",
+				Validators:  []validator.List{listOf(notBlank())},
+			},
+			"package_manager": schema.StringAttribute{
+				Computed:    true,
+				Description: "The package manager the versions were read from, 'dpkg' or 'rpm'",
+			},
+			"versions": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The versions of the installed packages by name, e.g. '5:28.0.1-1~ubuntu.24.04~noble'. The packages that are not installed are left out",
+			},
+			"missing": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The names of the packages that are not installed",
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *packagesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	var model packagesDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	names, quoted := []string{}, ""
+
+	for _, name := range model.Names {
+		names = append(names, name.ValueString())
+		quoted += " " + clients.ShellQuote(name.ValueString())
+	}
+
+	// The query commands fail when some of the packages are not installed, which is reported by their absence. The
+	// package manager is printed first, rpm prints the epoch only when the package has one, as dpkg does.
+	command := "if command -v dpkg-query >/dev/null 2>&1; then echo dpkg; dpkg-query -W -f '${Package}\\t${Version}\\t${db:Status-Abbrev}\\n'" + quoted + " 2>/dev/null; " +
+		"elif command -v rpm >/dev/null 2>&1; then echo rpm; rpm -q --qf '%{NAME}\\t%|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\\tii\\n'" + quoted + " 2>/dev/null; " +
+		"else echo none; fi; true"
+
+	out, err := d.provider.machineAccessClient.RunCommand(ctx, command)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the installed packages", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	manager, listing, _ := strings.Cut(out, "\n")
+	if manager != "dpkg" && manager != "rpm" {
+		resp.Diagnostics.AddError("Unsupported package manager", "Neither dpkg nor rpm is available on the remote system")
+		return
+	}
+
+	installed := parseInstalledPackages(listing)
+
+	model.PackageManager = types.StringValue(manager)
+	model.Missing = []types.String{}
+
+	for _, name := range names {
+		if _, ok := installed[name]; !ok {
+			model.Missing = append(model.Missing, types.StringValue(name))
+		}
+	}
+
+	model.Versions, diags = types.MapValueFrom(ctx, types.StringType, installed)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// parseInstalledPackages returns the versions of the installed packages by name, from lines made of the name, the
+// version and the dpkg status separated by tabs. The packages whose status is not installed, e.g. removed but not
+// purged, are left out.
+func parseInstalledPackages(out string) map[string]string {
+	installed := map[string]string{}
+
+	for line := range strings.SplitSeq(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || !strings.HasPrefix(fields[2], "ii") {
+			continue
+		}
+
+		installed[fields[0]] = fields[1]
+	}
+
+	return installed
+}
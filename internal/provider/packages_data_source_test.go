@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestPackagesDataSourceWithMock(t *testing.T) {
+	names := []types.String{types.StringValue("docker-ce"), types.StringValue("nginx"), types.StringValue("curl")}
+
+	t.Run("the versions are read with dpkg", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^if command -v dpkg-query .* 'docker-ce' 'nginx' 'curl' 2>/dev/null; `, clients.MockResponse{Stdout: "dpkg\n" +
+				"docker-ce\t5:28.0.1-1~ubuntu.24.04~noble\tii \n" +
+				"nginx\t1.24.0-2ubuntu7\trc \n" +
+				"curl\t8.5.0-2ubuntu10\tii \n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newPackagesDataSource(newTestProvider(mock)), packagesDataSourceModel{Names: names, Versions: types.MapNull(types.StringType)})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		versions := state.Versions.Elements()
+		if len(versions) != 2 || versions["docker-ce"].String() != `"5:28.0.1-1~ubuntu.24.04~noble"` || versions["curl"].String() != `"8.5.0-2ubuntu10"` {
+			t.Fatalf("unexpected versions: %v", versions)
+		}
+
+		if state.PackageManager.ValueString() != "dpkg" || len(state.Missing) != 1 || state.Missing[0].ValueString() != "nginx" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("the versions are read with rpm", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^if command -v dpkg-query`, clients.MockResponse{Stdout: "rpm\n" +
+				"docker-ce\t3:28.0.1-1.el9\tii\n" +
+				"package nginx is not installed\n" +
+				"curl\t7.76.1-29.el9\tii\n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newPackagesDataSource(newTestProvider(mock)), packagesDataSourceModel{Names: names, Versions: types.MapNull(types.StringType)})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.PackageManager.ValueString() != "rpm" || len(state.Versions.Elements()) != 2 || len(state.Missing) != 1 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("an unknown package manager fails", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^if command -v dpkg-query`, clients.MockResponse{Stdout: "none\n"})
+
+		// Act
+		_, diags := testDataSourceRead(t, newPackagesDataSource(newTestProvider(mock)), packagesDataSourceModel{Names: names, Versions: types.MapNull(types.StringType)})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
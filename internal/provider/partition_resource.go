@@ -0,0 +1,475 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &partitionResource{}
+
+var (
+	// partitionNumberPattern matches the number at the end of partition nodes, e.g. /dev/sda1 or /dev/nvme0n1p1.
+	partitionNumberPattern = regexp.MustCompile(`[0-9]+$`)
+	// partitionSizePattern matches the sizes and offsets of sfdisk, in sectors or with a binary unit.
+	partitionSizePattern = regexp.MustCompile(`^[0-9]+([KMGTP]i?B?)?$`)
+)
+
+func newPartitionResource(p *internalProvider) resource.Resource {
+	return &partitionResource{
+		provider: p,
+	}
+}
+
+// partitionResource defines the resource implementation.
+type partitionResource struct {
+	provider *internalProvider
+}
+
+type partitionResourceModel struct {
+	Device types.String `tfsdk:"device"`
+	Number types.Int64  `tfsdk:"number"`
+	Start  types.String `tfsdk:"start"`
+	Size   types.String `tfsdk:"size"`
+	Type   types.String `tfsdk:"type"`
+	Label  types.String `tfsdk:"label"`
+	Wipe   types.Bool   `tfsdk:"wipe"`
+	Path   types.String `tfsdk:"path"`
+	UUID   types.String `tfsdk:"uuid"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+// sfdiskTable is the partition table printed by `sfdisk --json`.
+type sfdiskTable struct {
+	PartitionTable struct {
+		Label      string            `json:"label"`
+		Partitions []sfdiskPartition `json:"partitions"`
+	} `json:"partitiontable"`
+}
+
+type sfdiskPartition struct {
+	Node string `json:"node"`
+	Type string `json:"type"`
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+func (r *partitionResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_partition"
+}
+
+func (r *partitionResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Partition resource that adds a partition to a disk with sfdisk, creating a GPT partition " +
+			"table on disks without one, or adopts the partition when its number already exists. With wipe false, " +
+			"the default, a disk holding a filesystem or other data outside of a partition table is refused, " +
+			"signatures left in the new partition by former partitions are kept, so that setup_filesystem refuses " +
+			"to format it, and a partition holding data is not deleted. Use the setup_block_devices data source to " +
+			"find the disk by serial number or WWN",
+
+		Attributes: map[string]schema.Attribute{
+			"device": schema.StringAttribute{
+				Required:    true,
+				Description: "The disk to partition, e.g. data.setup_block_devices.all.by_serial[\"S4EVNX0R\"]",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"number": schema.Int64Attribute{
+				Required:    true,
+				Description: "The number of the partition, e.g. 1",
+				Validators:  []validator.Int64{int64Between(1, 128)},
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"start": schema.StringAttribute{
+				Optional:    true,
+				Description: "The offset of the partition, in sectors or with a unit, e.g. '1MiB'. Defaults to the start of the first free space large enough",
+				Validators:  []validator.String{partitionSize()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"size": schema.StringAttribute{
+				Optional:    true,
+				Description: "The size of the partition, in sectors or with a unit, e.g. '20GiB'. Defaults to all the free space after the start",
+				Validators:  []validator.String{partitionSize()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"type": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("linux"),
+				Description: "The type of the partition, a GUID or a sfdisk alias: 'linux', 'swap', 'uefi', 'lvm', 'raid' or 'home'. Defaults to 'linux'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"label": schema.StringAttribute{
+				Optional:    true,
+				Description: "The GPT name of the partition, e.g. 'data'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"wipe": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether data may be erased: the signatures of a disk without partition table, the signatures left in the new partition, and the partition on deletion. Defaults to false",
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the partition, e.g. '/dev/sdb1' or '/dev/nvme0n1p1'",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"uuid": schema.StringAttribute{
+				Computed:    true,
+				Description: "The unique id of the partition, to reference it with PARTUUID=",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *partitionResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *partitionResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan partitionResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	disk := plan.Device.ValueString()
+
+	table, err := r.table(ctx, disk)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the partition table", err.Error())
+		return
+	}
+
+	if table == nil {
+		if !r.createTable(ctx, disk, plan.Wipe.ValueBool(), &resp.Diagnostics) {
+			return
+		}
+	}
+
+	if partition, found := findPartition(table, plan.Number.ValueInt64()); found {
+		// The partition is adopted as is, only its type and label are managed
+		if !r.setAttributes(ctx, plan, partition, &resp.Diagnostics) {
+			return
+		}
+	} else {
+		wipe := "never"
+		if plan.Wipe.ValueBool() {
+			wipe = "always"
+		}
+
+		command := "echo " + clients.ShellQuote(partitionScript(plan)) + " | " + clients.ShellCommand("sfdisk", "--no-tell-kernel", "--wipe-partitions", wipe, "-N", strconv.FormatInt(plan.Number.ValueInt64(), 10), disk) +
+			" && " + clients.ShellCommand("partx", "-u", disk)
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", command)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create the partition", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	table, err = r.table(ctx, disk)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the partition table", err.Error())
+		return
+	}
+
+	partition, found := findPartition(table, plan.Number.ValueInt64())
+	if !found {
+		resp.Diagnostics.AddError("Partition not found", fmt.Sprintf("Partition %d of %s was not found after its creation", plan.Number.ValueInt64(), disk))
+		return
+	}
+
+	plan.Path = types.StringValue(partition.Node)
+	plan.UUID = types.StringValue(partition.UUID)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *partitionResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state partitionResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	table, err := r.table(ctx, state.Device.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the partition table", err.Error())
+		return
+	}
+
+	partition, found := findPartition(table, state.Number.ValueInt64())
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// The type is not read back, as sfdisk prints the GUID of the aliases
+	if partition.Name != "" || !state.Label.IsNull() {
+		state.Label = types.StringValue(partition.Name)
+	}
+
+	state.Path = types.StringValue(partition.Node)
+	state.UUID = types.StringValue(partition.UUID)
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *partitionResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state partitionResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	current := sfdiskPartition{Name: state.Label.ValueString()}
+	if plan.Type.Equal(state.Type) {
+		current.Type = plan.Type.ValueString()
+	}
+
+	if !r.setAttributes(ctx, plan, current, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *partitionResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state partitionResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	partition := state.Path.ValueString()
+
+	if !state.Wipe.ValueBool() {
+		signature, err := blockDeviceSignature(ctx, r.provider, partition)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to probe the partition", err.Error())
+			return
+		}
+
+		if err := requireBlankSignature(partition, signature); err != nil {
+			resp.Diagnostics.AddError("Refusing to delete the partition", err.Error()+", or set wipe to true")
+			return
+		}
+	}
+
+	if !r.provider.allowDestructiveOperation("delete the partition "+partition, &resp.Diagnostics) {
+		return
+	}
+
+	disk := state.Device.ValueString()
+	command := clients.ShellCommand("sfdisk", "--no-tell-kernel", "--delete", disk, strconv.FormatInt(state.Number.ValueInt64(), 10)) + " && " + clients.ShellCommand("partx", "-u", disk)
+
+	// The signatures are erased first, so that they do not reappear in a partition created later at the same offset
+	if state.Wipe.ValueBool() {
+		command = clients.ShellCommand("wipefs", "-a", partition) + " && " + command
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", command)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to delete the partition", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// table returns the partition table of the disk, or nil when the disk has none.
+func (r *partitionResource) table(ctx context.Context, disk string) (*sfdiskTable, error) {
+	result, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("sfdisk", "--json", disk)))
+
+	// sfdisk fails without output when the disk has no partition table
+	if _, ok := clients.ExitCode(err); ok && strings.TrimSpace(result.Stdout) == "" && strings.Contains(result.Stderr, "does not contain a recognized partition table") {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+	}
+
+	table := &sfdiskTable{}
+	if err := json.Unmarshal([]byte(result.Stdout), table); err != nil {
+		return nil, fmt.Errorf("failed to parse the output of sfdisk: %w", err)
+	}
+
+	return table, nil
+}
+
+// createTable creates a GPT partition table on the disk, refusing disks holding data unless wipe is set.
+func (r *partitionResource) createTable(ctx context.Context, disk string, wipe bool, diags *diag.Diagnostics) bool {
+	signature, err := blockDeviceSignature(ctx, r.provider, disk)
+	if err != nil {
+		diags.AddError("Failed to probe the disk", err.Error())
+		return false
+	}
+
+	command := "echo 'label: gpt' | " + clients.ShellCommand("sfdisk", "--no-tell-kernel", disk)
+
+	if err := requireBlankSignature(disk, signature); err != nil {
+		if !wipe {
+			diags.AddError("Refusing to partition the disk", err.Error()+", or set wipe to true")
+			return false
+		}
+
+		command = clients.ShellCommand("wipefs", "-a", disk) + " && " + command
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", command)))
+	if err != nil {
+		diags.AddError("Failed to create the partition table", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return false
+	}
+
+	return true
+}
+
+// setAttributes sets the type and the label of the partition when they differ from the current ones.
+func (r *partitionResource) setAttributes(ctx context.Context, plan partitionResourceModel, current sfdiskPartition, diags *diag.Diagnostics) bool {
+	disk := plan.Device.ValueString()
+	number := strconv.FormatInt(plan.Number.ValueInt64(), 10)
+
+	var commands []string
+
+	if !strings.EqualFold(plan.Type.ValueString(), current.Type) {
+		commands = append(commands, clients.ShellCommand("sfdisk", "--no-tell-kernel", "--part-type", disk, number, plan.Type.ValueString()))
+	}
+
+	if label := plan.Label.ValueString(); label != current.Name {
+		commands = append(commands, clients.ShellCommand("sfdisk", "--no-tell-kernel", "--part-label", disk, number, label))
+	}
+
+	for _, command := range commands {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(command))
+		if err != nil {
+			diags.AddError("Failed to update the partition", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return false
+		}
+	}
+
+	return true
+}
+
+// findPartition returns the partition of the table with the number.
+func findPartition(table *sfdiskTable, number int64) (sfdiskPartition, bool) {
+	if table == nil {
+		return sfdiskPartition{}, false
+	}
+
+	for _, partition := range table.PartitionTable.Partitions {
+		if partitionNumberPattern.FindString(partition.Node) == strconv.FormatInt(number, 10) {
+			return partition, true
+		}
+	}
+
+	return sfdiskPartition{}, false
+}
+
+// partitionScript returns the sfdisk script line describing the partition.
+func partitionScript(plan partitionResourceModel) string {
+	fields := []string{}
+
+	if !plan.Start.IsNull() {
+		fields = append(fields, "start="+plan.Start.ValueString())
+	}
+
+	if !plan.Size.IsNull() {
+		fields = append(fields, "size="+plan.Size.ValueString())
+	}
+
+	fields = append(fields, "type="+plan.Type.ValueString())
+
+	if !plan.Label.IsNull() {
+		fields = append(fields, "name="+strconv.Quote(plan.Label.ValueString()))
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// partitionSize validates the sizes and offsets of partitions.
+func partitionSize() validator.String {
+	return stringValidator{
+		description: "value must be a number of sectors, or a size with a binary unit, e.g. '20GiB'",
+		check: func(value string) string {
+			if !partitionSizePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must be a number of sectors, or a size with a binary unit, e.g. '20GiB'", value)
+			}
+
+			return ""
+		},
+	}
+}
@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	testSfdiskEmpty   = `{"partitiontable": {"label": "gpt", "device": "/dev/sdb", "unit": "sectors", "partitions": []}}`
+	testSfdiskCreated = `{"partitiontable": {"label": "gpt", "device": "/dev/sdb", "unit": "sectors", "partitions": [
+		{"node": "/dev/sdb1", "start": 2048, "size": 41943040, "type": "0FC63DAF-8483-4772-8E79-3D69D8477DE4", "uuid": "8C1E3F52-6A4B-4C1D-9E2F-3A5B7C9D1E0F", "name": "data"}
+	]}}`
+)
+
+func TestPartitionResourceWithMock(t *testing.T) {
+	t.Run("create adds the partition", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("sfdisk --json /dev/sdb", clients.MockResponse{Stdout: testSfdiskEmpty, Once: true}).
+			On("sfdisk --json /dev/sdb", clients.MockResponse{Stdout: testSfdiskCreated})
+
+		// Act
+		state, diags := testResourceCreate(t, newPartitionResource(newTestProvider(mock)), testPartitionModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := `sh -c 'echo '\''start=1MiB, size=20GiB, type=linux, name="data"'\'' | sfdisk --no-tell-kernel --wipe-partitions never -N 1 /dev/sdb && partx -u /dev/sdb'`
+		if !slices.Contains(mock.Commands, expected) {
+			t.Fatalf("expected the partition to be created, got: %v", mock.Commands)
+		}
+
+		if state.Path.ValueString() != "/dev/sdb1" || state.UUID.ValueString() != "8C1E3F52-6A4B-4C1D-9E2F-3A5B7C9D1E0F" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("create writes a partition table on a blank disk", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("sfdisk --json /dev/sdb", clients.MockResponse{ExitCode: 1, Stderr: "sfdisk: /dev/sdb: does not contain a recognized partition table", Once: true}).
+			On("sfdisk --json /dev/sdb", clients.MockResponse{Stdout: testSfdiskCreated}).
+			On("blkid -p -o export /dev/sdb", clients.MockResponse{ExitCode: 2})
+
+		// Act
+		_, diags := testResourceCreate(t, newPartitionResource(newTestProvider(mock)), testPartitionModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, `sh -c 'echo '\''label: gpt'\'' | sfdisk --no-tell-kernel /dev/sdb'`) {
+			t.Fatalf("expected a partition table to be created, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create refuses a disk holding a filesystem unless wipe is set", func(t *testing.T) {
+		// Arrange
+		newMock := func() *clients.MockMachineAccessClient {
+			return clients.NewMockMachineAccessClient().
+				On("sfdisk --json /dev/sdb", clients.MockResponse{ExitCode: 1, Stderr: "sfdisk: /dev/sdb: does not contain a recognized partition table", Once: true}).
+				On("sfdisk --json /dev/sdb", clients.MockResponse{Stdout: testSfdiskCreated}).
+				On("blkid -p -o export /dev/sdb", clients.MockResponse{Stdout: "UUID=1b5e\nTYPE=ext4\n"})
+		}
+
+		refusedMock, wipedMock := newMock(), newMock()
+
+		wiped := testPartitionModel()
+		wiped.Wipe = types.BoolValue(true)
+
+		// Act
+		_, refusedDiags := testResourceCreate(t, newPartitionResource(newTestProvider(refusedMock)), testPartitionModel())
+		_, diags := testResourceCreate(t, newPartitionResource(newTestProvider(wipedMock)), wiped)
+
+		// Assert
+		if !refusedDiags.HasError() {
+			t.Fatal("expected the disk to be refused")
+		}
+
+		if slices.ContainsFunc(refusedMock.Commands, func(command string) bool { return strings.Contains(command, "label: gpt") }) {
+			t.Fatalf("expected no partition table to be created, got: %v", refusedMock.Commands)
+		}
+
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.ContainsFunc(wipedMock.Commands, func(command string) bool { return strings.HasPrefix(command, "sh -c 'wipefs -a /dev/sdb && ") }) {
+			t.Fatalf("expected the disk to be wiped, got: %v", wipedMock.Commands)
+		}
+	})
+
+	t.Run("update changes the label in place", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		plan := testPartitionState()
+		plan.Label = types.StringValue("postgres")
+
+		// Act
+		_, diags := testResourceUpdate(t, newPartitionResource(newTestProvider(mock)), testPartitionState(), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"sfdisk --no-tell-kernel --part-label /dev/sdb 1 postgres"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read removes a deleted partition", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("sfdisk --json /dev/sdb", clients.MockResponse{Stdout: testSfdiskEmpty})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newPartitionResource(newTestProvider(mock)), testPartitionState())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete refuses a partition holding data unless wipe is set", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("blkid -p -o export /dev/sdb1", clients.MockResponse{Stdout: "UUID=1b5e\nTYPE=xfs\n"})
+
+		wiped := testPartitionState()
+		wiped.Wipe = types.BoolValue(true)
+
+		// Act
+		refusedDiags := testResourceDelete(t, newPartitionResource(newTestProvider(mock)), testPartitionState())
+		diags := testResourceDelete(t, newPartitionResource(newTestProvider(mock)), wiped)
+
+		// Assert
+		if !refusedDiags.HasError() {
+			t.Fatal("expected the partition to be kept")
+		}
+
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{"blkid -p -o export /dev/sdb1", "sh -c 'wipefs -a /dev/sdb1 && sfdisk --no-tell-kernel --delete /dev/sdb 1 && partx -u /dev/sdb'"}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func testPartitionModel() partitionResourceModel {
+	return partitionResourceModel{
+		Device: types.StringValue("/dev/sdb"),
+		Number: types.Int64Value(1),
+		Start:  types.StringValue("1MiB"),
+		Size:   types.StringValue("20GiB"),
+		Type:   types.StringValue("linux"),
+		Label:  types.StringValue("data"),
+		Wipe:   types.BoolValue(false),
+		Path:   types.StringUnknown(),
+		UUID:   types.StringUnknown(),
+	}
+}
+
+func testPartitionState() partitionResourceModel {
+	model := testPartitionModel()
+	model.Path = types.StringValue("/dev/sdb1")
+	model.UUID = types.StringValue("8C1E3F52-6A4B-4C1D-9E2F-3A5B7C9D1E0F")
+
+	return model
+}
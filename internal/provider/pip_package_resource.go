@@ -0,0 +1,313 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &pipPackageResource{}
+var _ resource.ResourceWithImportState = &pipPackageResource{}
+
+func newPipPackageResource(p *internalProvider) resource.Resource {
+	return &pipPackageResource{
+		provider: p,
+	}
+}
+
+// pipPackageResource defines the resource implementation.
+type pipPackageResource struct {
+	provider *internalProvider
+}
+
+type pipPackageResourceModel struct {
+	Name       types.String   `tfsdk:"name"`
+	Version    types.String   `tfsdk:"version"`
+	Virtualenv types.String   `tfsdk:"virtualenv"`
+	Timeouts   *timeoutsModel `tfsdk:"timeouts"`
+	Retry      *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *pipPackageResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_pip_package"
+}
+
+func (r *pipPackageResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Pip package resource that installs a python package system-wide or inside a virtualenv",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the pip package",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"version": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The version of the pip package. If not specified, the latest version is installed and the installed version is tracked",
+			},
+			"virtualenv": schema.StringAttribute{
+				Optional:    true,
+				Description: "The path of the virtualenv to install the package into. The virtualenv is created if it does not exist. If not specified, the package is installed system-wide",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *pipPackageResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *pipPackageResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan pipPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	if !plan.Virtualenv.IsNull() && plan.Virtualenv.ValueString() != "" {
+		err = r.ensureVirtualenv(ctx, plan.Virtualenv.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create virtualenv", err.Error())
+			return
+		}
+	}
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install pip package", err.Error())
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed pip package version", err.Error())
+		return
+	}
+
+	if !found {
+		resp.Diagnostics.AddError("Pip package not found after installation", "The package "+plan.Name.ValueString()+" could not be found with pip show")
+		return
+	}
+
+	plan.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *pipPackageResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model pipPackageResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, model)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed pip package version", err.Error())
+		return
+	}
+
+	if !found {
+		// Package is not installed anymore, remove from state
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	model.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *pipPackageResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan pipPackageResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	err = r.install(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to install pip package", err.Error())
+		return
+	}
+
+	version, found, err := r.installedVersion(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get installed pip package version", err.Error())
+		return
+	}
+
+	if !found {
+		resp.Diagnostics.AddError("Pip package not found after installation", "The package "+plan.Name.ValueString()+" could not be found with pip show")
+		return
+	}
+
+	plan.Version = types.StringValue(version)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *pipPackageResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model pipPackageResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.pipCommand(model, "uninstall", "-y", model.Name.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to uninstall pip package", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+func (r *pipPackageResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+}
+
+// pipCommand returns the pip command with the given arguments, run either with the pip of the virtualenv or with the
+// system-wide one.
+func (r *pipPackageResource) pipCommand(model pipPackageResourceModel, args ...string) string {
+	if !model.Virtualenv.IsNull() && model.Virtualenv.ValueString() != "" {
+		return r.provider.withProxy(clients.ShellCommand(strings.TrimSuffix(model.Virtualenv.ValueString(), "/")+"/bin/pip", args...))
+	}
+
+	return r.provider.sudo(r.provider.withProxy(clients.ShellCommand("python3", append([]string{"-m", "pip"}, args...)...)))
+}
+
+func (r *pipPackageResource) ensureVirtualenv(ctx context.Context, virtualenv string) error {
+	_, err := r.provider.machineAccessClient.Run(ctx, clients.ShellCommand("test", "-x", strings.TrimSuffix(virtualenv, "/")+"/bin/pip"))
+	if err == nil {
+		tflog.Debug(ctx, "Virtualenv "+virtualenv+" already exists")
+		return nil
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("python3", "-m", "venv", virtualenv))
+	if err != nil {
+		return fmt.Errorf("failed to create virtualenv %s. Err=%w\nout = %s", virtualenv, err, out)
+	}
+
+	return nil
+}
+
+func (r *pipPackageResource) install(ctx context.Context, model pipPackageResourceModel) error {
+	requirement := model.Name.ValueString()
+	if !model.Version.IsNull() && !model.Version.IsUnknown() && model.Version.ValueString() != "" {
+		requirement += "==" + model.Version.ValueString()
+	}
+
+	out, err := retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(clients.WithStreamedOutput(ctx), r.pipCommand(model, "install", requirement))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install %s. Err=%w\nout = %s", requirement, err, out)
+	}
+
+	return nil
+}
+
+// installedVersion returns the installed version of the package, and whether the package is installed at all.
+func (r *pipPackageResource) installedVersion(ctx context.Context, model pipPackageResourceModel) (string, bool, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.pipCommand(model, "show", model.Name.ValueString()))
+	if err != nil {
+		// pip show exits with a non-zero code when the package is not installed
+		if strings.Contains(out, "not found") {
+			return "", false, nil
+		}
+
+		return "", false, fmt.Errorf("failed to run pip show. Err=%w\nout = %s", err, out)
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "Version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Version:")), true, nil
+		}
+	}
+
+	return "", false, fmt.Errorf("could not find the version in pip show output: %s", out)
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestPipPackageResource(t *testing.T) {
+	// Arrange - set up a single Docker container for all test cases
+	setup := setupTestEnvironment(t)
+
+	t.Run("Test create, update and removed in a virtualenv", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testPipPackageResourceConfig("six", "1.16.0", "/tmp/venv"),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_pip_package.test", "name", "six"),
+						resource.TestCheckResourceAttr("setup_pip_package.test", "version", "1.16.0"),
+						resource.TestCheckResourceAttr("setup_pip_package.test", "virtualenv", "/tmp/venv"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							out, err := sshClient.RunCommand(context.Background(), "/tmp/venv/bin/pip show six")
+							if err != nil {
+								return fmt.Errorf("package six not installed: %w", err)
+							}
+
+							if !strings.Contains(out, "Version: 1.16.0") {
+								return fmt.Errorf("unexpected pip show output: %s", out)
+							}
+
+							return nil
+						},
+					),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testPipPackageResourceConfig("six", "1.17.0", "/tmp/venv"),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_pip_package.test", "version", "1.17.0"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							out, err := sshClient.RunCommand(context.Background(), "/tmp/venv/bin/pip show six")
+							if err != nil {
+								return fmt.Errorf("package six not installed: %w", err)
+							}
+
+							if !strings.Contains(out, "Version: 1.17.0") {
+								return fmt.Errorf("unexpected pip show output: %s", out)
+							}
+
+							return nil
+						},
+					),
+				},
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testPipPackageResourcePrerequisitesConfig(),
+					Check: resource.ComposeTestCheckFunc(
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							_, err = sshClient.RunCommand(context.Background(), "/tmp/venv/bin/pip show six")
+							if err == nil {
+								return fmt.Errorf("package six was not uninstalled")
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func testPipPackageResourcePrerequisitesConfig() string {
+	return `
+resource "setup_apt_packages" "python" {
+	package {
+		name = "python3-pip"
+	}
+
+	package {
+		name = "python3-venv"
+	}
+}
+`
+}
+
+func testPipPackageResourceConfig(name string, version string, virtualenv string) string {
+	return testPipPackageResourcePrerequisitesConfig() + fmt.Sprintf(`
+resource "setup_pip_package" "test" {
+	name       = "%s"
+	version    = "%s"
+	virtualenv = "%s"
+
+	depends_on = [setup_apt_packages.python]
+}
+`, name, version, virtualenv)
+}
@@ -0,0 +1,642 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &prometheusNodeExporterResource{}
+var _ resource.ResourceWithModifyPlan = &prometheusNodeExporterResource{}
+
+const (
+	nodeExporterBinary      = "/usr/local/bin/node_exporter"
+	nodeExporterUnit        = "/etc/systemd/system/node_exporter.service"
+	nodeExporterUser        = "node_exporter"
+	nodeExporterReleasesURL = "https://github.com/prometheus/node_exporter/releases/download"
+)
+
+var (
+	nodeExporterVersionPattern       = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+	nodeExporterVersionOutputPattern = regexp.MustCompile(`version ([0-9]+\.[0-9]+\.[0-9]+)`)
+	nodeExporterCollectorPattern     = regexp.MustCompile(`^[a-z0-9_.]+$`)
+	nodeExporterListenAddressPattern = regexp.MustCompile(`^\S*:[0-9]+$`)
+)
+
+// nodeExporterArchitectures maps the architectures of the hosts to those of the releases of node_exporter. The 32 bits
+// ARM hosts get the ARMv7 build, the one of the armhf userland of Debian and Raspberry Pi OS.
+var nodeExporterArchitectures = map[string]string{
+	"amd64":   "amd64",
+	"arm64":   "arm64",
+	"arm":     "armv7",
+	"386":     "386",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+func newPrometheusNodeExporterResource(p *internalProvider) resource.Resource {
+	return &prometheusNodeExporterResource{
+		provider: p,
+	}
+}
+
+// prometheusNodeExporterResource defines the resource implementation.
+type prometheusNodeExporterResource struct {
+	provider *internalProvider
+}
+
+type prometheusNodeExporterResourceModel struct {
+	Version            types.String   `tfsdk:"version"`
+	SHA256             types.String   `tfsdk:"sha256"`
+	ReleasesURL        types.String   `tfsdk:"releases_url"`
+	ListenAddress      types.String   `tfsdk:"listen_address"`
+	EnabledCollectors  types.List     `tfsdk:"enabled_collectors"`
+	DisabledCollectors types.List     `tfsdk:"disabled_collectors"`
+	ExtraArgs          types.List     `tfsdk:"extra_args"`
+	Architecture       types.String   `tfsdk:"architecture"`
+	UnitContent        types.String   `tfsdk:"unit_content"`
+	Timeouts           *timeoutsModel `tfsdk:"timeouts"`
+	Retry              *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *prometheusNodeExporterResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_prometheus_node_exporter"
+}
+
+func (r *prometheusNodeExporterResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Prometheus node exporter resource that downloads the release of node_exporter built for the " +
+			"architecture of the machine, installs its binary in " + nodeExporterBinary + " and runs it as the " +
+			nodeExporterUser + " system user with the systemd unit " + nodeExporterUnit + ". The archive is verified " +
+			"with its sha256 checksum, and the service is restarted when the binary or the unit changed. Destroying the " +
+			"resource stops the service and removes the binary and the unit, the user is kept as it may own files of " +
+			"the textfile collector",
+
+		Attributes: map[string]schema.Attribute{
+			"version": schema.StringAttribute{
+				Required:    true,
+				Description: "The version of node_exporter, without the leading v, e.g. '1.8.2'. A binary of another version installed outside of terraform is planned as an update",
+				Validators:  []validator.String{nodeExporterVersion()},
+			},
+			"sha256": schema.StringAttribute{
+				Optional:    true,
+				Description: "The expected sha256 checksum of the release archive for the architecture of the machine. If not specified, the archive is verified with the sha256sums.txt file of the release",
+			},
+			"releases_url": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(nodeExporterReleasesURL),
+				Description: "The URL the releases are downloaded from, e.g. a mirror of the GitHub releases, with the archives at <releases_url>/v<version>/node_exporter-<version>.linux-<architecture>.tar.gz. Defaults to '" + nodeExporterReleasesURL + "'",
+			},
+			"listen_address": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(":9100"),
+				Description: "The address the metrics are served on, e.g. '127.0.0.1:9100'. Defaults to ':9100'",
+				Validators:  []validator.String{nodeExporterListenAddress()},
+			},
+			"enabled_collectors": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The collectors to enable in addition to those enabled by default, e.g. 'systemd' or 'processes'",
+				Validators:  []validator.List{listOf(nodeExporterCollector())},
+			},
+			"disabled_collectors": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The collectors enabled by default to disable, e.g. 'wifi' or 'infiniband'",
+				Validators:  []validator.List{listOf(nodeExporterCollector())},
+			},
+			"extra_args": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The other flags of node_exporter, e.g. '--collector.textfile.directory=/var/lib/node_exporter'",
+				Validators:  []validator.List{listOf(notBlank())},
+			},
+			"architecture": schema.StringAttribute{
+				Computed:    true,
+				Description: "The architecture of the installed release, e.g. 'amd64' or 'armv7'",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"unit_content": schema.StringAttribute{
+				Computed:    true,
+				Description: "The content of the systemd unit. A change made outside of terraform is planned as an update",
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *prometheusNodeExporterResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan renders the unit, so that a unit changed outside of terraform is planned as a change of its content.
+func (r *prometheusNodeExporterResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan prometheusNodeExporterResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, diags := plan.renderUnit(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("unit_content"), content)...)
+}
+
+func (r *prometheusNodeExporterResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan prometheusNodeExporterResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	r.apply(ctx, &plan, true, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *prometheusNodeExporterResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model prometheusNodeExporterResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	unit, err := r.provider.machineAccessClient.ReadFile(ctx, nodeExporterUnit, true)
+	if clients.IsFileNotFound(err) {
+		// The unit was removed outside of terraform, node_exporter must be installed again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read node_exporter unit", err.Error())
+		return
+	}
+
+	_, err = r.provider.machineAccessClient.Stat(ctx, nodeExporterBinary, true)
+	if clients.IsFileNotFound(err) {
+		// The binary was removed outside of terraform, node_exporter must be installed again
+		tflog.Debug(ctx, "node_exporter is not installed: "+err.Error())
+		resp.State.RemoveResource(ctx)
+
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stat the node_exporter binary", err.Error())
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand(nodeExporterBinary, "--version")+" 2>&1")
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to run node_exporter", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	version := nodeExporterVersionOutputPattern.FindStringSubmatch(out)
+	if version == nil {
+		resp.Diagnostics.AddError("Failed to read node_exporter version", "unexpected output of node_exporter --version: "+out)
+		return
+	}
+
+	model.Version = types.StringValue(version[1])
+	model.UnitContent = types.StringValue(unit)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *prometheusNodeExporterResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan prometheusNodeExporterResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state prometheusNodeExporterResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	install := !plan.Version.Equal(state.Version) || !plan.ReleasesURL.Equal(state.ReleasesURL) ||
+		!strings.EqualFold(plan.SHA256.ValueString(), state.SHA256.ValueString())
+
+	r.apply(ctx, &plan, install, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *prometheusNodeExporterResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model prometheusNodeExporterResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	// The unit may already be gone, the service is stopped anyway
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl disable --now node_exporter || true"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stop node_exporter", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", nodeExporterUnit, nodeExporterBinary)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove node_exporter", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl daemon-reload"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to reload systemd", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// apply installs the release when install is set, creates the user, writes the unit and restarts the service when the
+// binary or the unit changed. The computed attributes of the plan are set.
+func (r *prometheusNodeExporterResource) apply(ctx context.Context, plan *prometheusNodeExporterResourceModel, install bool, diags *diag.Diagnostics) {
+	unit, unitDiags := plan.renderUnit(ctx)
+	diags.Append(unitDiags...)
+
+	if unitDiags.HasError() {
+		return
+	}
+
+	plan.UnitContent = types.StringValue(unit)
+
+	if install {
+		architecture, err := r.architecture(ctx)
+		if err != nil {
+			diags.AddError("Unsupported architecture", err.Error())
+			return
+		}
+
+		if err := r.install(ctx, *plan, architecture); err != nil {
+			diags.AddError("Failed to install node_exporter", err.Error())
+			return
+		}
+
+		plan.Architecture = types.StringValue(architecture)
+	}
+
+	if err := r.createUser(ctx); err != nil {
+		diags.AddError("Failed to create node_exporter user", err.Error())
+		return
+	}
+
+	previous, err := r.provider.machineAccessClient.ReadFile(ctx, nodeExporterUnit, true)
+	if err != nil && !clients.IsFileNotFound(err) {
+		diags.AddError("Failed to read node_exporter unit", err.Error())
+		return
+	}
+
+	changed := err != nil || previous != unit
+
+	if changed {
+		if err := r.provider.machineAccessClient.WriteFile(ctx, nodeExporterUnit, "644", "0", "0", unit); err != nil {
+			diags.AddError("Failed to write node_exporter unit", err.Error())
+			return
+		}
+	}
+
+	if !changed && !install {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl daemon-reload && systemctl enable node_exporter && systemctl restart node_exporter"))
+	if err != nil {
+		diags.AddError("Failed to start node_exporter", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// architecture returns the architecture of the release built for the machine.
+func (r *prometheusNodeExporterResource) architecture(ctx context.Context) (string, error) {
+	hostArchitecture := r.provider.capabilities(ctx).Architecture
+	if hostArchitecture == "" {
+		return "", fmt.Errorf("the architecture of the machine is unknown")
+	}
+
+	architecture, ok := nodeExporterArchitectures[hostArchitecture]
+	if !ok {
+		return "", fmt.Errorf("node_exporter has no release for the architecture %s of the machine", hostArchitecture)
+	}
+
+	return architecture, nil
+}
+
+// install downloads the release archive into a remote temporary directory, verifies its checksum, and installs the
+// binary it contains.
+func (r *prometheusNodeExporterResource) install(ctx context.Context, model prometheusNodeExporterResourceModel, architecture string) error {
+	tmpDir, err := r.provider.machineAccessClient.RunCommand(ctx, "mktemp -d")
+	if err != nil {
+		return fmt.Errorf("failed to create remote temp directory. Err=%w\nout = %s", err, tmpDir)
+	}
+
+	tmpDir = strings.TrimSpace(tmpDir)
+
+	defer func() {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-rf", tmpDir))
+	}()
+
+	release := fmt.Sprintf("node_exporter-%s.linux-%s", model.Version.ValueString(), architecture)
+	archive := release + ".tar.gz"
+	releaseURL := strings.TrimSuffix(model.ReleasesURL.ValueString(), "/") + "/v" + model.Version.ValueString()
+
+	if err := r.fetch(ctx, model, releaseURL+"/"+archive, tmpDir+"/"+archive); err != nil {
+		return err
+	}
+
+	expected := model.SHA256.ValueString()
+	if model.SHA256.IsNull() {
+		expected, err = r.releaseChecksum(ctx, model, releaseURL, tmpDir, archive)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("sha256sum", tmpDir+"/"+archive))
+	if err != nil {
+		return fmt.Errorf("failed to compute sha256 of %s. Err=%w\nout = %s", archive, err, out)
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", archive, expected, strings.TrimSpace(out))
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("tar", "-xzf", tmpDir+"/"+archive, "-C", tmpDir))
+	if err != nil {
+		return fmt.Errorf("failed to extract %s. Err=%w\nout = %s", archive, err, out)
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("install", "-m", "0755", tmpDir+"/"+release+"/node_exporter", nodeExporterBinary)))
+	if err != nil {
+		return fmt.Errorf("failed to install %s. Err=%w\nout = %s", nodeExporterBinary, err, out)
+	}
+
+	return nil
+}
+
+// releaseChecksum returns the checksum of the archive listed in the sha256sums.txt file of the release.
+func (r *prometheusNodeExporterResource) releaseChecksum(ctx context.Context, model prometheusNodeExporterResourceModel, releaseURL string, tmpDir string, archive string) (string, error) {
+	if err := r.fetch(ctx, model, releaseURL+"/sha256sums.txt", tmpDir+"/sha256sums.txt"); err != nil {
+		return "", err
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, tmpDir+"/sha256sums.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to read sha256sums.txt: %w", err)
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == archive {
+			return fields[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("the sha256sums.txt file of the release has no checksum for %s", archive)
+}
+
+// fetch downloads the url to the destination, with the retries of the model.
+func (r *prometheusNodeExporterResource) fetch(ctx context.Context, model prometheusNodeExporterResourceModel, url string, destination string) error {
+	tflog.Debug(ctx, "Downloading "+url+" to "+destination)
+
+	out, err := retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(ctx, r.provider.withProxy(fetchCommand(url, destination)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download %s. Err=%w\nout = %s", url, err, out)
+	}
+
+	return nil
+}
+
+// createUser creates the system user node_exporter runs as, without a home nor a login shell, unless it exists.
+func (r *prometheusNodeExporterResource) createUser(ctx context.Context) error {
+	command := clients.ShellCommand("useradd", "--system", "--user-group", "--no-create-home", "--shell", "/usr/sbin/nologin", nodeExporterUser)
+	if !r.provider.capabilities(ctx).ShadowUtils {
+		command = clients.ShellCommand("adduser", "-S", "-D", "-H", "-s", "/sbin/nologin", nodeExporterUser)
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("id", nodeExporterUser)+" > /dev/null 2>&1 || "+command))
+	if err != nil {
+		return fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// renderUnit returns the content of the systemd unit running node_exporter with the flags of the model.
+func (model prometheusNodeExporterResourceModel) renderUnit(ctx context.Context) (string, diag.Diagnostics) {
+	var enabled, disabled, extra []string
+
+	var diags diag.Diagnostics
+
+	diags.Append(model.EnabledCollectors.ElementsAs(ctx, &enabled, false)...)
+	diags.Append(model.DisabledCollectors.ElementsAs(ctx, &disabled, false)...)
+	diags.Append(model.ExtraArgs.ElementsAs(ctx, &extra, false)...)
+
+	if diags.HasError() {
+		return "", diags
+	}
+
+	args := []string{nodeExporterBinary, "--web.listen-address=" + model.ListenAddress.ValueString()}
+
+	for _, collector := range enabled {
+		args = append(args, "--collector."+collector)
+	}
+
+	for _, collector := range disabled {
+		args = append(args, "--no-collector."+collector)
+	}
+
+	args = append(args, extra...)
+
+	for i, arg := range args {
+		args[i] = systemdQuote(arg)
+	}
+
+	return joinLines([]string{
+		"[Unit]",
+		"Description=Prometheus node exporter",
+		"Documentation=https://github.com/prometheus/node_exporter",
+		"Wants=network-online.target",
+		"After=network-online.target",
+		"",
+		"[Service]",
+		"User=" + nodeExporterUser,
+		"ExecStart=" + strings.Join(args, " \\\n    "),
+		"Restart=on-failure",
+		"",
+		"[Install]",
+		"WantedBy=multi-user.target",
+	}), nil
+}
+
+// systemdQuote returns the argument escaped for the command lines of systemd units, which expand the specifiers
+// starting with % and the variables starting with $.
+func systemdQuote(arg string) string {
+	arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+
+	if !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// nodeExporterVersion validates the versions of node_exporter, e.g. '1.8.2'.
+func nodeExporterVersion() validator.String {
+	return stringValidator{
+		description: "value must be a version of node_exporter, e.g. '1.8.2'",
+		check: func(value string) string {
+			if !nodeExporterVersionPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a version of node_exporter, e.g. '1.8.2'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// nodeExporterCollector validates the names of the collectors of node_exporter, e.g. 'systemd'.
+func nodeExporterCollector() validator.String {
+	return stringValidator{
+		description: "value must be the name of a collector of node_exporter, e.g. 'systemd'",
+		check: func(value string) string {
+			if !nodeExporterCollectorPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not the name of a collector of node_exporter, e.g. 'systemd'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// nodeExporterListenAddress validates the listening addresses, a host and a port, e.g. '127.0.0.1:9100' or ':9100'.
+func nodeExporterListenAddress() validator.String {
+	return stringValidator{
+		description: "value must be an address and a port, e.g. '127.0.0.1:9100' or ':9100'",
+		check: func(value string) string {
+			if !nodeExporterListenAddressPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not an address and a port, e.g. '127.0.0.1:9100' or ':9100'", value)
+			}
+
+			return ""
+		},
+	}
+}
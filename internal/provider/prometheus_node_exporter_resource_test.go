@@ -0,0 +1,209 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestPrometheusNodeExporterResourceWithMock(t *testing.T) {
+	t.Run("create installs the release of the architecture and starts the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.exporter\n"}).
+			WithFile("/tmp/tmp.exporter/sha256sums.txt", "aaaa  node_exporter-1.8.2.linux-amd64.tar.gz\nbbbb  node_exporter-1.8.2.linux-arm64.tar.gz\n", clients.FileInfo{Mode: "644"}).
+			OnMatch(`^sha256sum `, clients.MockResponse{Stdout: "bbbb  /tmp/tmp.exporter/node_exporter-1.8.2.linux-arm64.tar.gz\n"})
+
+		model := testPrometheusNodeExporterModel()
+		model.EnabledCollectors = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("systemd")})
+		model.DisabledCollectors = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("wifi")})
+		model.ExtraArgs = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("--collector.filesystem.mount-points-exclude=^/(dev|proc)($|/)")})
+
+		// Act
+		state, diags := testResourceCreate(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "arm64")), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !testNodeExporterCommandRan(mock, "https://github.com/prometheus/node_exporter/releases/download/v1.8.2/node_exporter-1.8.2.linux-arm64.tar.gz") {
+			t.Fatalf("expected the arm64 release to be downloaded, got: %v", mock.Commands)
+		}
+
+		if !testNodeExporterCommandRan(mock, "install -m 0755 /tmp/tmp.exporter/node_exporter-1.8.2.linux-arm64/node_exporter /usr/local/bin/node_exporter") {
+			t.Fatalf("expected the binary to be installed, got: %v", mock.Commands)
+		}
+
+		expected := `[Unit]
+Description=Prometheus node exporter
+Documentation=https://github.com/prometheus/node_exporter
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+User=node_exporter
+ExecStart=/usr/local/bin/node_exporter \
+    --web.listen-address=:9100 \
+    --collector.systemd \
+    --no-collector.wifi \
+    --collector.filesystem.mount-points-exclude=^/(dev|proc)($$|/)
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`
+		if mock.Files["/etc/systemd/system/node_exporter.service"] != expected || state.UnitContent.ValueString() != expected {
+			t.Fatalf("unexpected unit:\n%s", mock.Files["/etc/systemd/system/node_exporter.service"])
+		}
+
+		if state.Architecture.ValueString() != "arm64" || !testNodeExporterCommandRan(mock, "systemctl restart node_exporter") {
+			t.Fatalf("expected the service to be started, got: %s, %v", state.Architecture.ValueString(), mock.Commands)
+		}
+	})
+
+	t.Run("create does not install an archive with another checksum", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.exporter\n"}).
+			OnMatch(`^sha256sum `, clients.MockResponse{Stdout: "bbbb  /tmp/tmp.exporter/node_exporter-1.8.2.linux-amd64.tar.gz\n"})
+
+		model := testPrometheusNodeExporterModel()
+		model.SHA256 = types.StringValue("cccc")
+
+		// Act
+		_, diags := testResourceCreate(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "amd64")), model)
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "checksum mismatch") {
+			t.Fatalf("expected a checksum mismatch, got: %v", diags)
+		}
+
+		if testNodeExporterCommandRan(mock, "install -m") || testNodeExporterCommandRan(mock, "sha256sums.txt") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if !testNodeExporterCommandRan(mock, "rm -rf /tmp/tmp.exporter") {
+			t.Fatalf("expected the temp directory to be removed, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create fails on an architecture without release", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "loong64")), testPrometheusNodeExporterModel())
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "loong64") {
+			t.Fatalf("expected an unsupported architecture, got: %v", diags)
+		}
+	})
+
+	t.Run("update of the flags rewrites the unit without downloading the release", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		state := testPrometheusNodeExporterModel()
+		state.Architecture = types.StringValue("amd64")
+
+		plan := testPrometheusNodeExporterModel()
+		plan.Architecture = types.StringValue("amd64")
+		plan.ListenAddress = types.StringValue("127.0.0.1:9100")
+
+		// Act
+		_, diags := testResourceUpdate(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "amd64")), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if testNodeExporterCommandRan(mock, "mktemp") {
+			t.Fatalf("the release must not be downloaded again, got: %v", mock.Commands)
+		}
+
+		if !strings.Contains(mock.Files["/etc/systemd/system/node_exporter.service"], "--web.listen-address=127.0.0.1:9100") || !testNodeExporterCommandRan(mock, "systemctl restart node_exporter") {
+			t.Fatalf("expected the unit to be rewritten and the service restarted, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects the version of the installed binary", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/systemd/system/node_exporter.service", "[Unit]\n", clients.FileInfo{Mode: "644"}).
+			WithFile(nodeExporterBinary, "", clients.FileInfo{Mode: "755"}).
+			OnMatch(`node_exporter --version`, clients.MockResponse{Stdout: "node_exporter, version 1.7.0 (branch: HEAD, revision: 7333465abf9efba81876303bb57e6fadb946041b)\n"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "amd64")), testPrometheusNodeExporterModel())
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected read: %v", diags)
+		}
+
+		if state.Version.ValueString() != "1.7.0" || state.UnitContent.ValueString() != "[Unit]\n" {
+			t.Fatalf("unexpected state: %s, %q", state.Version.ValueString(), state.UnitContent.ValueString())
+		}
+	})
+
+	t.Run("read removes the resource when the binary is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/systemd/system/node_exporter.service", "[Unit]\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "amd64")), testPrometheusNodeExporterModel())
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed, got: %v", diags)
+		}
+	})
+
+	t.Run("read keeps the resource when node_exporter cannot be run", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/systemd/system/node_exporter.service", "[Unit]\n", clients.FileInfo{Mode: "644"}).
+			WithFile(nodeExporterBinary, "", clients.FileInfo{Mode: "755"}).
+			OnMatch(`node_exporter --version`, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		// Act
+		_, removed, diags := testResourceRead(t, newPrometheusNodeExporterResource(newTestProviderWithArchitecture(mock, "amd64")), testPrometheusNodeExporterModel())
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func testPrometheusNodeExporterModel() prometheusNodeExporterResourceModel {
+	return prometheusNodeExporterResourceModel{
+		Version:            types.StringValue("1.8.2"),
+		SHA256:             types.StringNull(),
+		ReleasesURL:        types.StringValue(nodeExporterReleasesURL),
+		ListenAddress:      types.StringValue(":9100"),
+		EnabledCollectors:  types.ListNull(types.StringType),
+		DisabledCollectors: types.ListNull(types.StringType),
+		ExtraArgs:          types.ListNull(types.StringType),
+		Architecture:       types.StringUnknown(),
+		UnitContent:        types.StringUnknown(),
+	}
+}
+
+func testNodeExporterCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
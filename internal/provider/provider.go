@@ -2,19 +2,32 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"terraform-provider-setup/internal/provider/clients"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
 var (
-	_ provider.Provider = &internalProvider{}
+	_ provider.Provider                   = &internalProvider{}
+	_ provider.ProviderWithValidateConfig = &internalProvider{}
+	_ provider.ProviderWithFunctions      = &internalProvider{}
 )
 
 // NewProvider is a helper function to simplify provider server and testing implementation.
@@ -27,15 +40,65 @@ func NewProvider() func() provider.Provider {
 // internalProvider is the provider implementation.
 type internalProvider struct {
 	machineAccessClient clients.MachineAccessClient
+	become              clients.Become
+	osFamily            string
+
+	// denyDestructiveOperations is set when allow_destructive_operations is false
+	denyDestructiveOperations bool
+
+	// dryRun is set when dry_run is true, the changes are only logged so that what they would produce cannot be read
+	dryRun bool
+
+	proxy proxySettings
+
+	// aptProxy is the apt proxy configuration of each host, by host name
+	aptProxyMutex sync.Mutex
+	aptProxy      map[string]*aptProxyConfiguration
+
+	// hostCapabilities are the capabilities of each host, by host name, probed on first use
+	hostCapabilitiesMutex sync.Mutex
+	hostCapabilities      map[string]*capabilitiesProbe
+
+	// locks are the named locks of each host, by host name and lock name
+	locksMutex sync.Mutex
+	locks      map[string]*sync.Mutex
 }
 
 // todo: add more validation of the attributes
 type providerData struct {
-	User       types.String `tfsdk:"user"`
-	Host       types.String `tfsdk:"host"`
-	Port       types.String `tfsdk:"port"`
-	PrivateKey types.String `tfsdk:"private_key"`
-	SSHAgent   types.String `tfsdk:"ssh_agent"`
+	User                 types.String `tfsdk:"user"`
+	Host                 types.String `tfsdk:"host"`
+	Port                 types.String `tfsdk:"port"`
+	PrivateKey           types.String `tfsdk:"private_key"`
+	PrivateKeyContent    types.String `tfsdk:"private_key_content"`
+	PrivateKeyPassphrase types.String `tfsdk:"private_key_passphrase"`
+	SSHAgent             types.String `tfsdk:"ssh_agent"`
+	Password             types.String `tfsdk:"password"`
+	ConnectionTimeout    types.String `tfsdk:"connection_timeout"`
+	MaxRetries           types.Int64  `tfsdk:"max_retries"`
+	RetryDelay           types.String `tfsdk:"retry_delay"`
+	MaxSessions          types.Int64  `tfsdk:"max_sessions"`
+	CommandTimeout       types.String `tfsdk:"command_timeout"`
+	KeepaliveInterval    types.String `tfsdk:"keepalive_interval"`
+	KeepaliveMaxCount    types.Int64  `tfsdk:"keepalive_max_count"`
+	UseSudo              types.Bool   `tfsdk:"use_sudo"`
+	BecomeMethod         types.String `tfsdk:"become_method"`
+	BecomeUser           types.String `tfsdk:"become_user"`
+	SudoPassword         types.String `tfsdk:"sudo_password"`
+	OSFamily             types.String `tfsdk:"os_family"`
+	Transport            types.String `tfsdk:"transport"`
+	HTTPProxy            types.String `tfsdk:"http_proxy"`
+	HTTPSProxy           types.String `tfsdk:"https_proxy"`
+	NoProxy              types.String `tfsdk:"no_proxy"`
+
+	AllowDestructiveOperations types.Bool `tfsdk:"allow_destructive_operations"`
+
+	AuditLogPath types.String `tfsdk:"audit_log_path"`
+	AuditSyslog  types.Bool   `tfsdk:"audit_syslog"`
+
+	DryRun types.Bool `tfsdk:"dry_run"`
+
+	Hosts map[string]providerHostModel `tfsdk:"hosts"`
 }
 
 // Metadata returns the provider type name.
@@ -52,10 +115,25 @@ func (p *internalProvider) Schema(_ context.Context, _ provider.SchemaRequest, r
 				Description: "Private key to use for SSH authentication",
 				Optional:    true,
 			},
+			"private_key_content": schema.StringAttribute{
+				Description: "PEM encoded content of the private key to use for SSH authentication, as an alternative to the private_key path",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"private_key_passphrase": schema.StringAttribute{
+				Description: "Passphrase used to decrypt the private key given by private_key or private_key_content",
+				Optional:    true,
+				Sensitive:   true,
+			},
 			"ssh_agent": schema.StringAttribute{
 				Description: "Path to the SSH agent socket",
 				Optional:    true,
 			},
+			"password": schema.StringAttribute{
+				Description: "Password to use for SSH authentication, tried after the private key or SSH agent. Also used to answer keyboard-interactive prompts",
+				Optional:    true,
+				Sensitive:   true,
+			},
 			"user": schema.StringAttribute{
 				Description: "User to use for SSH authentication",
 				Required:    true,
@@ -67,11 +145,163 @@ func (p *internalProvider) Schema(_ context.Context, _ provider.SchemaRequest, r
 			"port": schema.StringAttribute{
 				Description: "Port to connect to",
 				Required:    true,
+				Validators:  []validator.String{portString()},
+			},
+			"connection_timeout": schema.StringAttribute{
+				Description: "Maximum time to wait for the SSH connection to be established, as a duration (e.g. '30s'). Defaults to '30s'",
+				Optional:    true,
+				Validators:  []validator.String{duration()},
+			},
+			"max_retries": schema.Int64Attribute{
+				Description: "Number of times to retry establishing the SSH connection, e.g. while the host is still booting. Defaults to 0",
+				Optional:    true,
+				Validators:  []validator.Int64{int64AtLeast(0)},
+			},
+			"retry_delay": schema.StringAttribute{
+				Description: "Initial delay between connection retries as a duration (e.g. '5s'). The delay doubles after each attempt. Defaults to '5s'",
+				Optional:    true,
+				Validators:  []validator.String{duration()},
+			},
+			"max_sessions": schema.Int64Attribute{
+				Description: "Maximum number of SSH sessions opened in parallel over the connection. Must not exceed MaxSessions of the remote sshd. Defaults to 10",
+				Optional:    true,
+				Validators:  []validator.Int64{int64AtLeast(1)},
+			},
+			"command_timeout": schema.StringAttribute{
+				Description: "Maximum time a single remote command may run before it is killed, as a duration (e.g. '30m'). Defaults to no timeout",
+				Optional:    true,
+				Validators:  []validator.String{duration()},
+			},
+			"keepalive_interval": schema.StringAttribute{
+				Description: "Interval between the keepalive requests sent over the SSH connection, as a duration (e.g. '15s'), so that NAT gateways and firewalls do not drop it during long commands. '0s' disables them. Defaults to '30s'",
+				Optional:    true,
+				Validators:  []validator.String{duration()},
+			},
+			"keepalive_max_count": schema.Int64Attribute{
+				Description: "Number of keepalive requests in a row without answer after which the server is considered gone and the connection is closed, failing the running commands. Defaults to 3",
+				Optional:    true,
+				Validators:  []validator.Int64{int64Between(1, 100)},
+			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "Whether to elevate privileges for commands that need them. Disable when connecting as root to a host without sudo. Defaults to true",
+				Optional:    true,
+			},
+			"become_method": schema.StringAttribute{
+				Description: "How to elevate privileges, either 'sudo' or 'doas'. Defaults to 'sudo'",
+				Optional:    true,
+				Validators:  []validator.String{oneOf("sudo", "doas")},
+			},
+			"become_user": schema.StringAttribute{
+				Description: "User to run privileged commands as. Defaults to root",
+				Optional:    true,
+			},
+			"sudo_password": schema.StringAttribute{
+				Description: "Password fed to sudo on stdin, for hosts without passwordless sudo. Not supported with doas",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"os_family": schema.StringAttribute{
+				Description: "OS family of the host, either 'linux' or 'windows'. Commands are run with PowerShell over SSH on Windows hosts, which needs OpenSSH server, and privileges are not elevated. Defaults to 'linux'",
+				Optional:    true,
+				Validators:  []validator.String{oneOf(clients.OSFamilyLinux, clients.OSFamilyWindows)},
+			},
+			"allow_destructive_operations": schema.BoolAttribute{
+				Description: "Whether destroying or replacing resources may run destructive operations: deleting the users of " +
+					"setup_user and setup_windows_user, the groups of setup_group, and removing the files of setup_file and the " +
+					"directories of setup_directory with remove_on_deletion, the volume groups of setup_lvm_volume_group, and the " +
+					"arrays of setup_raid and logical volumes of setup_lvm_logical_volume with allow_data_loss. When false, these " +
+					"operations fail with an error instead, as a guardrail against a mis-scoped destroy. Defaults to true",
+				Optional: true,
+			},
+			"transport": schema.StringAttribute{
+				Description: "How to connect to the host, either 'ssh' or 'telnet'. Telnet is meant for appliances and freshly installed hosts without SSH server, and for serial consoles exposed over the network by a console server or ser2net. Only user, host, port, password, connection_timeout, command_timeout and the privilege escalation attributes are used with telnet, and stdout and stderr are merged. Defaults to 'ssh'",
+				Optional:    true,
+				Validators:  []validator.String{oneOf("ssh", "telnet")},
+			},
+			"http_proxy": schema.StringAttribute{
+				Description: "URL of the proxy the host reaches HTTP URLs through, e.g. 'http://proxy.example.com:3128'. It is exported to the commands reaching the network, i.e. apt, curl, wget, pip, npm and certbot, written to the apt configuration in " + aptProxyConfigPath + " and to the environment of the Docker daemon installed by setup_docker, which pulls the images",
+				Optional:    true,
+				Validators:  []validator.String{proxyURL()},
+			},
+			"https_proxy": schema.StringAttribute{
+				Description: "URL of the proxy the host reaches HTTPS URLs through, usually the same as http_proxy. It is used like http_proxy",
+				Optional:    true,
+				Validators:  []validator.String{proxyURL()},
+			},
+			"no_proxy": schema.StringAttribute{
+				Description: "Comma separated hosts and domains reached without proxy, e.g. 'localhost,127.0.0.1,.internal.example.com'. apt only honors the plain host names",
+				Optional:    true,
+			},
+			"audit_log_path": schema.StringAttribute{
+				Description: "The local file to which the commands run, and the files written, to create, update or delete resources are appended, " +
+					"one JSON object per line with the timestamp, the resource type, the operation, the command and its exit code. The commands reading the state are not recorded",
+				Optional: true,
+			},
+			"audit_syslog": schema.BoolAttribute{
+				Description: "Whether the entries of the audit log are also sent to the syslog of the machine with logger, tagged terraform-provider-setup. Only supported with os_family = 'linux'. Defaults to false",
+				Optional:    true,
+			},
+			"hosts": schema.MapNestedAttribute{
+				Description: "Other hosts managed with the same credentials and settings, by name, e.g. to manage many similar nodes with for_each " +
+					"instead of a provider block per node. The resources and data sources run on the host named by their target_host attribute, " +
+					"or on the host of the provider when it is not set",
+				Optional: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"host": schema.StringAttribute{
+							Description: "Host to connect to",
+							Required:    true,
+						},
+						"port": schema.StringAttribute{
+							Description: "Port to connect to. Defaults to the port of the provider",
+							Optional:    true,
+							Validators:  []validator.String{portString()},
+						},
+						"user": schema.StringAttribute{
+							Description: "User to use for authentication. Defaults to the user of the provider",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"dry_run": schema.BoolAttribute{
+				Description: "Whether the commands, and the file writes, to create, update or delete resources are only logged at the INFO level instead of being run, " +
+					"e.g. with TF_LOG=INFO terraform apply, to review what would be run on the machine. The commands reading the state are still run. " +
+					"The state is saved as if the changes were applied, with a null uid for the users it did not create, so it should be used on a throwaway state, e.g. a copy of the workspace. Defaults to false",
+				Optional: true,
 			},
 		},
 	}
 }
 
+// ValidateConfig checks the attributes depending on each other, so that an invalid configuration fails while planning
+// instead of when connecting.
+func (p *internalProvider) ValidateConfig(ctx context.Context, req provider.ValidateConfigRequest, resp *provider.ValidateConfigResponse) {
+	var data providerData
+
+	diags := req.Config.Get(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The SSH client authenticates with a single key source
+	var keySources []string
+
+	for name, value := range map[string]types.String{"private_key": data.PrivateKey, "private_key_content": data.PrivateKeyContent, "ssh_agent": data.SSHAgent} {
+		if !value.IsNull() {
+			keySources = append(keySources, name)
+		}
+	}
+
+	if len(keySources) > 1 {
+		slices.Sort(keySources)
+		resp.Diagnostics.AddAttributeError(path.Root(keySources[1]), "Conflicting attributes",
+			"Only one of private_key, private_key_content and ssh_agent can be set, got: "+strings.Join(keySources, ", "))
+	}
+}
+
 func (p *internalProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
 	var data providerData
 
@@ -88,26 +318,323 @@ func (p *internalProvider) Configure(ctx context.Context, req provider.Configure
 		return
 	}
 
+	transport := "ssh"
+	if data.Transport.ValueString() != "" {
+		transport = data.Transport.ValueString()
+	}
+
+	if transport != "ssh" && transport != "telnet" {
+		resp.Diagnostics.AddError("Invalid transport", fmt.Sprintf("transport must be 'ssh' or 'telnet', got '%s'", transport))
+		return
+	}
+
 	sshClientBuild := clients.CreateSSHMachineAccessClientBuilder(data.User.ValueString(), data.Host.ValueString(), port)
+	telnetClientBuild := clients.CreateTelnetMachineAccessClientBuilder(data.User.ValueString(), data.Host.ValueString(), port)
 	if data.PrivateKey.ValueString() != "" {
 		sshClientBuild.WithPrivateKeyPath(data.PrivateKey.ValueString())
 	}
 
+	if data.PrivateKeyContent.ValueString() != "" {
+		sshClientBuild.WithPrivateKey(data.PrivateKeyContent.ValueString())
+	}
+
+	if data.PrivateKeyPassphrase.ValueString() != "" {
+		sshClientBuild.WithPrivateKeyPassphrase(data.PrivateKeyPassphrase.ValueString())
+	}
+
 	if data.SSHAgent.ValueString() != "" {
 		sshClientBuild.WithAgent(data.SSHAgent.ValueString())
 	}
 
-	p.machineAccessClient, err = sshClientBuild.Build(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to create SSH client", err.Error())
+	if data.Password.ValueString() != "" {
+		sshClientBuild.WithPassword(data.Password.ValueString())
+		telnetClientBuild.WithPassword(data.Password.ValueString())
+	}
+
+	if data.ConnectionTimeout.ValueString() != "" {
+		timeout, err := time.ParseDuration(data.ConnectionTimeout.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to parse connection_timeout", err.Error())
+			return
+		}
+
+		sshClientBuild.WithTimeout(timeout)
+		telnetClientBuild.WithTimeout(timeout)
+	}
+
+	if !data.MaxRetries.IsNull() {
+		if data.MaxRetries.ValueInt64() < 0 {
+			resp.Diagnostics.AddError("Invalid max_retries", "max_retries must not be negative")
+			return
+		}
+
+		retryDelay := 5 * time.Second
+
+		if data.RetryDelay.ValueString() != "" {
+			retryDelay, err = time.ParseDuration(data.RetryDelay.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("Failed to parse retry_delay", err.Error())
+				return
+			}
+		}
+
+		sshClientBuild.WithRetries(uint(data.MaxRetries.ValueInt64()), retryDelay) // #nosec G115 - checked to be positive above
+	}
+
+	if !data.MaxSessions.IsNull() {
+		if data.MaxSessions.ValueInt64() < 1 {
+			resp.Diagnostics.AddError("Invalid max_sessions", "max_sessions must be at least 1")
+			return
+		}
+
+		sshClientBuild.WithMaxSessions(uint(data.MaxSessions.ValueInt64())) // #nosec G115 - checked to be positive above
+	}
+
+	if data.CommandTimeout.ValueString() != "" {
+		commandTimeout, err := time.ParseDuration(data.CommandTimeout.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to parse command_timeout", err.Error())
+			return
+		}
+
+		sshClientBuild.WithCommandTimeout(commandTimeout)
+		telnetClientBuild.WithCommandTimeout(commandTimeout)
+	}
+
+	if data.KeepaliveInterval.ValueString() != "" || !data.KeepaliveMaxCount.IsNull() {
+		keepaliveInterval := 30 * time.Second
+
+		if data.KeepaliveInterval.ValueString() != "" {
+			keepaliveInterval, err = time.ParseDuration(data.KeepaliveInterval.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("Failed to parse keepalive_interval", err.Error())
+				return
+			}
+		}
+
+		keepaliveMaxCount := int64(3)
+		if !data.KeepaliveMaxCount.IsNull() {
+			keepaliveMaxCount = data.KeepaliveMaxCount.ValueInt64()
+		}
+
+		sshClientBuild.WithKeepalive(keepaliveInterval, uint(keepaliveMaxCount)) // #nosec G115 - validated to be positive
+	}
+
+	p.osFamily = clients.OSFamilyLinux
+	if data.OSFamily.ValueString() != "" {
+		p.osFamily = data.OSFamily.ValueString()
+	}
+
+	if p.osFamily != clients.OSFamilyLinux && p.osFamily != clients.OSFamilyWindows {
+		resp.Diagnostics.AddError("Invalid os_family", fmt.Sprintf("os_family must be '%s' or '%s', got '%s'", clients.OSFamilyLinux, clients.OSFamilyWindows, p.osFamily))
 		return
 	}
+
+	sshClientBuild.WithOSFamily(p.osFamily)
+
+	p.denyDestructiveOperations = !data.AllowDestructiveOperations.IsNull() && !data.AllowDestructiveOperations.ValueBool()
+
+	p.proxy = proxySettings{
+		HTTP:    data.HTTPProxy.ValueString(),
+		HTTPS:   data.HTTPSProxy.ValueString(),
+		NoProxy: data.NoProxy.ValueString(),
+	}
+
+	p.become = clients.Become{
+		Disabled: !data.UseSudo.IsNull() && !data.UseSudo.ValueBool(),
+		Method:   data.BecomeMethod.ValueString(),
+		User:     data.BecomeUser.ValueString(),
+		Password: data.SudoPassword.ValueString(),
+	}
+
+	// There is no sudo on Windows, the user connecting must have the required rights
+	if p.osFamily == clients.OSFamilyWindows {
+		p.become = clients.Become{Disabled: true}
+	}
+
+	if err := p.become.Validate(); err != nil {
+		resp.Diagnostics.AddError("Invalid privilege escalation configuration", err.Error())
+		return
+	}
+
+	sshClientBuild.WithBecome(p.become)
+	telnetClientBuild.WithBecome(p.become)
+
+	if data.AuditSyslog.ValueBool() && p.osFamily != clients.OSFamilyLinux {
+		resp.Diagnostics.AddError("Invalid audit_syslog", "audit_syslog is only supported with os_family = 'linux'")
+		return
+	}
+
+	if transport == "telnet" {
+		if p.osFamily != clients.OSFamilyLinux {
+			resp.Diagnostics.AddError("Invalid transport", "transport 'telnet' is only supported with os_family = 'linux'")
+			return
+		}
+
+		p.machineAccessClient, err = telnetClientBuild.Build(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create telnet client", err.Error())
+			return
+		}
+	} else {
+		p.machineAccessClient, err = sshClientBuild.Build(ctx)
+		if errors.Is(err, clients.ErrPrivateKeyPassphraseMissing) {
+			resp.Diagnostics.AddError("Private key is protected by a passphrase", "The private key could not be decrypted. Set private_key_passphrase in the provider configuration.")
+			return
+		}
+
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create SSH client", err.Error())
+			return
+		}
+	}
+
+	// The multi host client is installed without hosts too, so that a target_host is an unknown host rather than
+	// silently the default host
+	hosts := map[string]clients.MachineAccessClient{}
+
+	for name, host := range data.Hosts {
+		user, hostPort := host.connection(data.User.ValueString(), port)
+
+		if transport == "telnet" {
+			hosts[name], err = telnetClientBuild.ForHost(user, host.Host.ValueString(), hostPort).Build(ctx)
+		} else {
+			hosts[name], err = sshClientBuild.ForHost(user, host.Host.ValueString(), hostPort).Build(ctx)
+		}
+
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create the client of the host "+name, err.Error())
+			return
+		}
+	}
+
+	p.machineAccessClient = clients.NewMultiHostMachineAccessClient(p.machineAccessClient, hosts)
+
+	if data.AuditLogPath.ValueString() != "" || data.AuditSyslog.ValueBool() {
+		p.machineAccessClient = clients.NewAuditedMachineAccessClient(p.machineAccessClient, clients.AuditOptions{
+			Path:   data.AuditLogPath.ValueString(),
+			Syslog: data.AuditSyslog.ValueBool(),
+			Become: p.become,
+		})
+	}
+
+	// The dry run wraps the audited client, so that the commands which are not run are not recorded
+	p.dryRun = data.DryRun.ValueBool()
+
+	if p.dryRun {
+		p.machineAccessClient = clients.NewDryRunMachineAccessClient(p.machineAccessClient, p.become)
+	}
+}
+
+// requireOSFamily adds an error to the diagnostics and returns false when the host is not of the given OS family.
+func (p *internalProvider) requireOSFamily(osFamily string, diags *diag.Diagnostics) bool {
+	actual := p.osFamily
+	if actual == "" {
+		actual = clients.OSFamilyLinux
+	}
+
+	if actual != osFamily {
+		diags.AddError("Unsupported OS family", fmt.Sprintf("This resource is only supported on hosts with os_family = '%s', the provider is configured for '%s'", osFamily, actual))
+		return false
+	}
+
+	return true
+}
+
+// allowDestructiveOperation adds an error to the diagnostics and returns false when destructive operations are disabled
+// by allow_destructive_operations. The operation describes what would have been done, e.g. "delete the user alice".
+func (p *internalProvider) allowDestructiveOperation(operation string, diags *diag.Diagnostics) bool {
+	if p.denyDestructiveOperations {
+		diags.AddError("Destructive operation refused", fmt.Sprintf("Refusing to %s as allow_destructive_operations is false in the provider configuration. "+
+			"Set it to true to allow it, or remove the resource from the state with terraform state rm to leave it on the machine", operation))
+		return false
+	}
+
+	return true
+}
+
+// The names of the locks serializing the operations on a subsystem of the machine that fail when run concurrently.
+// When both are needed, dockerLock is taken before aptLock.
+const (
+	// aptLock is held while apt-get or dpkg run, as they fail when the dpkg lock is held by another of them
+	aptLock = "apt"
+	// dockerLock is held while docker is installed or its daemon is configured and restarted
+	dockerLock = "docker"
+	// rsyslogLock is held while the configuration of rsyslog is changed, checked and rsyslog restarted
+	rsyslogLock = "rsyslog"
+)
+
+// lock takes the named lock of the host of the context, so that the conflicting operations of the resources, which
+// terraform applies in parallel, run one after the other while the others still run in parallel. The locks of
+// different hosts are independent. It returns the function releasing the lock.
+func (p *internalProvider) lock(ctx context.Context, name string) func() {
+	key := clients.HostFrom(ctx) + "\x00" + name
+
+	p.locksMutex.Lock()
+
+	if p.locks == nil {
+		p.locks = map[string]*sync.Mutex{}
+	}
+
+	lock, ok := p.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.locks[key] = lock
+	}
+
+	p.locksMutex.Unlock()
+
+	if !lock.TryLock() {
+		tflog.Debug(ctx, "Waiting for the "+name+" lock held by another resource")
+		lock.Lock()
+	}
+
+	return lock.Unlock
+}
+
+// lockFile serializes the read-modify-write of a remote file shared by several resources. It returns the function
+// releasing the lock.
+func (p *internalProvider) lockFile(ctx context.Context, path string) func() {
+	return p.lock(ctx, "file:"+path)
+}
+
+// mutating marks the context of the Create, Update or Delete of the resource, so that the commands run with it are
+// recorded in the audit log, or skipped in a dry run, and selects the host of the resource from its plan or state. The
+// operation is one of create, update or delete.
+func (p *internalProvider) mutating(ctx context.Context, r resource.Resource, operation string, source hostAttributeSource) context.Context {
+	metadata := resource.MetadataResponse{}
+	r.Metadata(ctx, resource.MetadataRequest{ProviderTypeName: "setup"}, &metadata)
+
+	return clients.WithMutation(p.onHost(ctx, source), metadata.TypeName, operation)
+}
+
+// sudo returns the command so that it runs with elevated privileges, as configured in the provider.
+func (p *internalProvider) sudo(command string) string {
+	return p.become.Wrap(command)
 }
 
 // DataSources defines the data sources implemented in the provider.
 func (p *internalProvider) DataSources(_ context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
 		p.newFileDataSource,
+		p.newCommandDataSource,
+		p.newFactsDataSource,
+		p.newBlockDevicesDataSource,
+		p.newDirectoryDataSource,
+		p.newServiceStatusDataSource,
+		p.newPackagesDataSource,
+		p.newDockerImageDataSource,
+		p.newDockerContainerDataSource,
+		p.newConnectionDataSource,
+	}
+}
+
+// Functions defines the functions implemented in the provider, which do not reach the hosts.
+func (p *internalProvider) Functions(_ context.Context) []func() function.Function {
+	return []func() function.Function{
+		newOctalFunction,
+		newUnixPathJoinFunction,
+		newSSHPublicKeyFingerprintFunction,
 	}
 }
 
@@ -123,6 +650,57 @@ func (p *internalProvider) Resources(_ context.Context) []func() resource.Resour
 		p.newDockerImageLoadResource,
 		p.newSSHKeyResource,
 		p.newSSHAddResource,
+		p.newAuthorizedKeysResource,
+		p.newSSHConfigResource,
+		p.newTLSCertificateResource,
+		p.newCATrustResource,
+		p.newLineInFileResource,
+		p.newBlockInFileResource,
+		p.newINIValueResource,
+		p.newConfigKVResource,
+		p.newJSONPatchResource,
+		p.newYAMLPatchResource,
+		p.newDockerDaemonConfigResource,
+		p.newDockerSetupResource,
+		p.newPipPackageResource,
+		p.newNpmPackageResource,
+		p.newDownloadResource,
+		p.newWaitForResource,
+		p.newChocolateyPackageResource,
+		p.newWindowsUserResource,
+		p.newLimitsResource,
+		p.newLocaleResource,
+		p.newGrubConfigResource,
+		p.newRebootResource,
+		p.newNetplanResource,
+		p.newWireguardInterfaceResource,
+		p.newWireguardPeerResource,
+		p.newRaidResource,
+		p.newLvmVolumeGroupResource,
+		p.newLvmLogicalVolumeResource,
+		p.newFilesystemResource,
+		p.newPartitionResource,
+		p.newCertbotResource,
+		p.newServiceReloadResource,
+		p.newSELinuxResource,
+		p.newApparmorProfileResource,
+		p.newAlternativesResource,
+		p.newEnvironmentResource,
+		p.newDebPackageResource,
+		p.newAptUpdateResource,
+		p.newAptUpgradeResource,
+		p.newMachineIDResource,
+		p.newMotdResource,
+		p.newLogrotateResource,
+		p.newRsyslogForwardingResource,
+		p.newPrometheusNodeExporterResource,
+		p.newK3sResource,
+		p.newKubeadmResource,
+		p.newReleaseBinaryResource,
+		p.newContainerRuntimeResource,
+		p.newDockerPruneResource,
+		p.newDockerImageBuildResource,
+		p.newNvidiaGPUResource,
 	}
 }
 
@@ -162,6 +740,246 @@ func (p *internalProvider) newSSHAddResource() resource.Resource {
 	return newSSHAddResource(p)
 }
 
+func (p *internalProvider) newPipPackageResource() resource.Resource {
+	return newPipPackageResource(p)
+}
+
+func (p *internalProvider) newNpmPackageResource() resource.Resource {
+	return newNpmPackageResource(p)
+}
+
+func (p *internalProvider) newDownloadResource() resource.Resource {
+	return newDownloadResource(p)
+}
+
+func (p *internalProvider) newWaitForResource() resource.Resource {
+	return newWaitForResource(p)
+}
+
+func (p *internalProvider) newChocolateyPackageResource() resource.Resource {
+	return newChocolateyPackageResource(p)
+}
+
+func (p *internalProvider) newWindowsUserResource() resource.Resource {
+	return newWindowsUserResource(p)
+}
+
+func (p *internalProvider) newLimitsResource() resource.Resource {
+	return newLimitsResource(p)
+}
+
+func (p *internalProvider) newLocaleResource() resource.Resource {
+	return newLocaleResource(p)
+}
+
+func (p *internalProvider) newGrubConfigResource() resource.Resource {
+	return newGrubConfigResource(p)
+}
+
+func (p *internalProvider) newRebootResource() resource.Resource {
+	return newRebootResource(p)
+}
+
+func (p *internalProvider) newNetplanResource() resource.Resource {
+	return newNetplanResource(p)
+}
+
+func (p *internalProvider) newWireguardInterfaceResource() resource.Resource {
+	return newWireguardInterfaceResource(p)
+}
+
+func (p *internalProvider) newWireguardPeerResource() resource.Resource {
+	return newWireguardPeerResource(p)
+}
+
+func (p *internalProvider) newRaidResource() resource.Resource {
+	return newRaidResource(p)
+}
+
+func (p *internalProvider) newLvmVolumeGroupResource() resource.Resource {
+	return newLvmVolumeGroupResource(p)
+}
+
+func (p *internalProvider) newLvmLogicalVolumeResource() resource.Resource {
+	return newLvmLogicalVolumeResource(p)
+}
+
+func (p *internalProvider) newFilesystemResource() resource.Resource {
+	return newFilesystemResource(p)
+}
+
+func (p *internalProvider) newPartitionResource() resource.Resource {
+	return newPartitionResource(p)
+}
+
+func (p *internalProvider) newCertbotResource() resource.Resource {
+	return newCertbotResource(p)
+}
+
+func (p *internalProvider) newServiceReloadResource() resource.Resource {
+	return newServiceReloadResource(p)
+}
+
+func (p *internalProvider) newSELinuxResource() resource.Resource {
+	return newSELinuxResource(p)
+}
+
+func (p *internalProvider) newApparmorProfileResource() resource.Resource {
+	return newApparmorProfileResource(p)
+}
+
+func (p *internalProvider) newAlternativesResource() resource.Resource {
+	return newAlternativesResource(p)
+}
+
+func (p *internalProvider) newEnvironmentResource() resource.Resource {
+	return newEnvironmentResource(p)
+}
+
+func (p *internalProvider) newAuthorizedKeysResource() resource.Resource {
+	return newAuthorizedKeysResource(p)
+}
+
+func (p *internalProvider) newSSHConfigResource() resource.Resource {
+	return newSSHConfigResource(p)
+}
+
+func (p *internalProvider) newTLSCertificateResource() resource.Resource {
+	return newTLSCertificateResource(p)
+}
+
+func (p *internalProvider) newCATrustResource() resource.Resource {
+	return newCATrustResource(p)
+}
+
+func (p *internalProvider) newLineInFileResource() resource.Resource {
+	return newLineInFileResource(p)
+}
+
+func (p *internalProvider) newBlockInFileResource() resource.Resource {
+	return newBlockInFileResource(p)
+}
+
+func (p *internalProvider) newINIValueResource() resource.Resource {
+	return newINIValueResource(p)
+}
+
+func (p *internalProvider) newConfigKVResource() resource.Resource {
+	return newConfigKVResource(p)
+}
+
+func (p *internalProvider) newJSONPatchResource() resource.Resource {
+	return newJSONPatchResource(p)
+}
+
+func (p *internalProvider) newYAMLPatchResource() resource.Resource {
+	return newYAMLPatchResource(p)
+}
+
+func (p *internalProvider) newDockerDaemonConfigResource() resource.Resource {
+	return newDockerDaemonConfigResource(p)
+}
+
+func (p *internalProvider) newDockerSetupResource() resource.Resource {
+	return newDockerSetupResource(p)
+}
+
 func (p *internalProvider) newFileDataSource() datasource.DataSource {
 	return newFileDataSource(p)
 }
+
+func (p *internalProvider) newCommandDataSource() datasource.DataSource {
+	return newCommandDataSource(p)
+}
+
+func (p *internalProvider) newFactsDataSource() datasource.DataSource {
+	return newFactsDataSource(p)
+}
+
+func (p *internalProvider) newBlockDevicesDataSource() datasource.DataSource {
+	return newBlockDevicesDataSource(p)
+}
+
+func (p *internalProvider) newDebPackageResource() resource.Resource {
+	return newDebPackageResource(p)
+}
+
+func (p *internalProvider) newAptUpdateResource() resource.Resource {
+	return newAptUpdateResource(p)
+}
+
+func (p *internalProvider) newAptUpgradeResource() resource.Resource {
+	return newAptUpgradeResource(p)
+}
+
+func (p *internalProvider) newMachineIDResource() resource.Resource {
+	return newMachineIDResource(p)
+}
+
+func (p *internalProvider) newMotdResource() resource.Resource {
+	return newMotdResource(p)
+}
+
+func (p *internalProvider) newLogrotateResource() resource.Resource {
+	return newLogrotateResource(p)
+}
+
+func (p *internalProvider) newRsyslogForwardingResource() resource.Resource {
+	return newRsyslogForwardingResource(p)
+}
+
+func (p *internalProvider) newPrometheusNodeExporterResource() resource.Resource {
+	return newPrometheusNodeExporterResource(p)
+}
+
+func (p *internalProvider) newK3sResource() resource.Resource {
+	return newK3sResource(p)
+}
+
+func (p *internalProvider) newKubeadmResource() resource.Resource {
+	return newKubeadmResource(p)
+}
+
+func (p *internalProvider) newReleaseBinaryResource() resource.Resource {
+	return newReleaseBinaryResource(p)
+}
+
+func (p *internalProvider) newContainerRuntimeResource() resource.Resource {
+	return newContainerRuntimeResource(p)
+}
+
+func (p *internalProvider) newDockerPruneResource() resource.Resource {
+	return newDockerPruneResource(p)
+}
+
+func (p *internalProvider) newDockerImageBuildResource() resource.Resource {
+	return newDockerImageBuildResource(p)
+}
+
+func (p *internalProvider) newNvidiaGPUResource() resource.Resource {
+	return newNvidiaGPUResource(p)
+}
+
+func (p *internalProvider) newDirectoryDataSource() datasource.DataSource {
+	return newDirectoryDataSource(p)
+}
+
+func (p *internalProvider) newServiceStatusDataSource() datasource.DataSource {
+	return newServiceStatusDataSource(p)
+}
+
+func (p *internalProvider) newPackagesDataSource() datasource.DataSource {
+	return newPackagesDataSource(p)
+}
+
+func (p *internalProvider) newDockerImageDataSource() datasource.DataSource {
+	return newDockerImageDataSource(p)
+}
+
+func (p *internalProvider) newDockerContainerDataSource() datasource.DataSource {
+	return newDockerContainerDataSource(p)
+}
+
+func (p *internalProvider) newConnectionDataSource() datasource.DataSource {
+	return newConnectionDataSource(p)
+}
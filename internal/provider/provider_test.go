@@ -1,13 +1,29 @@
 package provider
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 )
 
 // TestSetup represents the common test setup for all provider tests
@@ -101,3 +117,583 @@ func testProviderConfig(args ...interface{}) string {
 
 	panic("testProviderConfig: invalid number of arguments")
 }
+
+func TestProviderValidateConfig(t *testing.T) {
+	t.Run("accepts a single key source", func(t *testing.T) {
+		// Act
+		diags := testProviderValidateConfig(t, map[string]string{"private_key": "/home/test/.ssh/id_ed25519"})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+	})
+
+	t.Run("rejects both a private key and an SSH agent", func(t *testing.T) {
+		// Act
+		diags := testProviderValidateConfig(t, map[string]string{"private_key": "/home/test/.ssh/id_ed25519", "ssh_agent": "/run/ssh-agent.sock"})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestProviderConfigure(t *testing.T) {
+	t.Run("rejects a target host without hosts", func(t *testing.T) {
+		// Arrange
+		p, diags := testProviderConfigure(t, map[string]any{"user": "test", "host": "localhost", "port": "22", "password": "secret"})
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		// Act
+		_, err := p.machineAccessClient.RunCommand(clients.WithHost(context.Background(), "web3"), "true")
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), "unknown host 'web3'") {
+			t.Fatalf("expected an unknown host, got: %v", err)
+		}
+	})
+
+	t.Run("rejects invalid connection settings", func(t *testing.T) {
+		valid := testProviderValidateSchema(t, map[string]any{"user": "test", "host": "localhost", "port": "22", "connection_timeout": "10s", "retry_delay": "1s", "max_retries": int64(0)})
+		if valid.HasError() {
+			t.Fatal(valid)
+		}
+
+		for name, value := range map[string]any{"connection_timeout": "forever", "retry_delay": "-5s", "max_retries": int64(-1), "max_sessions": int64(0), "command_timeout": "1d"} {
+			// Act
+			diags := testProviderValidateSchema(t, map[string]any{"user": "test", "host": "localhost", "port": "22", name: value})
+
+			// Assert
+			if !diags.HasError() {
+				t.Fatalf("expected %s = %v to be invalid", name, value)
+			}
+		}
+	})
+
+	t.Run("dials with the retries of the configuration", func(t *testing.T) {
+		// Arrange
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		var attempts atomic.Int32
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				attempts.Add(1)
+				_ = conn.Close()
+			}
+		}()
+
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+		p, diags := testProviderConfigure(t, map[string]any{"user": "test", "host": "127.0.0.1", "port": port, "password": "secret", "max_retries": int64(2), "retry_delay": "10ms"})
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		// Act
+		start := time.Now()
+		_, err = p.machineAccessClient.RunCommand(context.Background(), "true")
+		elapsed := time.Since(start)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected the connection to fail")
+		}
+
+		if attempts.Load() != 3 {
+			t.Fatalf("expected 3 attempts, got %d", attempts.Load())
+		}
+
+		// The delay doubles after each attempt, 10ms then 20ms, far from the default of 5s
+		if elapsed < 30*time.Millisecond || elapsed > 5*time.Second {
+			t.Fatalf("unexpected retry delay, the attempts took %s", elapsed)
+		}
+	})
+}
+
+func TestProviderDryRun(t *testing.T) {
+	t.Run("creates a user without running useradd", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "root:x:0:0:root:/root:/bin/bash\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newUserResource(newTestDryRunProvider(mock)), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Unknown(),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: types.ListNull(types.StringType),
+			ExpireDate:     types.StringNull(),
+			Locked:         types.BoolNull(),
+			PasswordMaxAge: types.Int64Null(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "cat /etc/passwd") || slices.ContainsFunc(mock.Commands, func(command string) bool { return strings.Contains(command, "useradd") }) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if !state.UID.IsNull() {
+			t.Fatalf("expected a null uid, got: %s", state.UID)
+		}
+	})
+
+	t.Run("reboots without waiting for a new boot id", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(bootIDCommand, clients.MockResponse{Stdout: "first-boot\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newRebootResource(newTestDryRunProvider(mock)), testRebootModel(true))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if testRebootCommandRan(mock) || state.BootID.ValueString() != "first-boot" {
+			t.Fatalf("unexpected reboot: %v, %s", mock.Commands, state.BootID)
+		}
+	})
+
+	t.Run("downloads without fetching the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newDownloadResource(newTestDryRunProvider(mock)), testDownloadModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+func TestProviderLock(t *testing.T) {
+	t.Run("serializes the holders of the same lock", func(t *testing.T) {
+		// Arrange
+		p := newTestProvider(clients.NewMockMachineAccessClient())
+		unlock := p.lock(context.Background(), aptLock)
+
+		// Act
+		acquired := make(chan struct{})
+		go func() {
+			defer p.lock(context.Background(), aptLock)()
+			close(acquired)
+		}()
+
+		// Assert
+		select {
+		case <-acquired:
+			t.Fatal("the lock was acquired twice")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		unlock()
+		<-acquired
+	})
+
+	t.Run("does not block different locks or hosts", func(t *testing.T) {
+		// Arrange
+		p := newTestProvider(clients.NewMockMachineAccessClient())
+		defer p.lock(context.Background(), aptLock)()
+
+		// Act
+		p.lock(context.Background(), dockerLock)()
+		p.lock(clients.WithHost(context.Background(), "worker"), aptLock)()
+		p.lockFile(context.Background(), "/etc/hosts")()
+	})
+}
+
+// testProviderValidateConfig runs the ValidateConfig of the provider with the given attributes, the others being null.
+func testProviderValidateConfig(t *testing.T, values map[string]string) diag.Diagnostics {
+	t.Helper()
+
+	p := &internalProvider{}
+
+	config := map[string]any{}
+	for name, value := range values {
+		config[name] = value
+	}
+
+	req := provider.ValidateConfigRequest{Config: testProviderConfigValue(t, p, config)}
+	resp := provider.ValidateConfigResponse{}
+	p.ValidateConfig(context.Background(), req, &resp)
+
+	return resp.Diagnostics
+}
+
+// testProviderConfigure runs the Configure of a new provider with the given attributes, the others being null, and
+// returns the configured provider. The SSH client connects lazily, so that no server is needed.
+func testProviderConfigure(t *testing.T, values map[string]any) (*internalProvider, diag.Diagnostics) {
+	t.Helper()
+
+	p := &internalProvider{}
+
+	req := provider.ConfigureRequest{Config: testProviderConfigValue(t, p, values)}
+	resp := provider.ConfigureResponse{}
+	p.Configure(context.Background(), req, &resp)
+
+	return p, resp.Diagnostics
+}
+
+// testProviderValidateSchema validates the provider configuration with the given attributes, the others being null,
+// through the provider server, so that the validators of the schema run too.
+func testProviderValidateSchema(t *testing.T, values map[string]any) diag.Diagnostics {
+	t.Helper()
+
+	server, err := providerserver.NewProtocol6WithError(NewProvider()())()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := testProviderConfigValue(t, &internalProvider{}, values)
+
+	dynamicValue, err := tfprotov6.NewDynamicValue(config.Raw.Type(), config.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := server.ValidateProviderConfig(context.Background(), &tfprotov6.ValidateProviderConfigRequest{Config: &dynamicValue})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var diags diag.Diagnostics
+	for _, diagnostic := range resp.Diagnostics {
+		if diagnostic.Severity == tfprotov6.DiagnosticSeverityError {
+			diags.AddError(diagnostic.Summary, diagnostic.Detail)
+		}
+	}
+
+	return diags
+}
+
+// testProviderConfigValue returns the provider configuration with the given attributes, the others being null. The
+// values are of the Go types of tftypes.NewValue, e.g. a string or an int64.
+func testProviderConfigValue(t *testing.T, p *internalProvider, values map[string]any) tfsdk.Config {
+	t.Helper()
+
+	schemaResp := provider.SchemaResponse{}
+	p.Schema(context.Background(), provider.SchemaRequest{}, &schemaResp)
+
+	objectType := schemaResp.Schema.Type().TerraformType(context.Background()).(tftypes.Object)
+
+	attributes := map[string]tftypes.Value{}
+	for name, attributeType := range objectType.AttributeTypes {
+		attributes[name] = tftypes.NewValue(attributeType, nil)
+	}
+
+	for name, value := range values {
+		attributes[name] = tftypes.NewValue(objectType.AttributeTypes[name], value)
+	}
+
+	return tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objectType, attributes)}
+}
+
+// newTestProvider returns a provider running its commands with the given client, without privilege escalation, so that
+// resources can be unit tested with a clients.MockMachineAccessClient instead of a Docker SSH server. The host has the
+// default capabilities, without probing them.
+func newTestProvider(client clients.MachineAccessClient) *internalProvider {
+	return withTestCapabilities(&internalProvider{
+		machineAccessClient: client,
+		become:              clients.Become{Disabled: true},
+	}, clients.DefaultCapabilities)
+}
+
+// newTestDryRunProvider returns a test provider with dry_run set, logging the changes instead of running them with the
+// given client.
+func newTestDryRunProvider(client clients.MachineAccessClient) *internalProvider {
+	p := newTestProvider(clients.NewDryRunMachineAccessClient(client, clients.Become{Disabled: true}))
+	p.dryRun = true
+
+	return p
+}
+
+// newTestProviderWithArchitecture returns a test provider whose default host has the given architecture, e.g. for the
+// resources downloading the release built for it.
+func newTestProviderWithArchitecture(client clients.MachineAccessClient, architecture string) *internalProvider {
+	capabilities := clients.DefaultCapabilities
+	capabilities.Architecture = architecture
+
+	return withTestCapabilities(newTestProvider(client), capabilities)
+}
+
+// withTestCapabilities sets the capabilities of the default host of the provider, as if they had been probed.
+func withTestCapabilities(p *internalProvider, capabilities clients.Capabilities) *internalProvider {
+	probe := &capabilitiesProbe{probed: true, capabilities: capabilities}
+
+	p.hostCapabilities = map[string]*capabilitiesProbe{"": probe}
+
+	return p
+}
+
+// testResourceCreate runs the Create of the resource with the given plan, which is also used as the configuration, and
+// returns the resulting state.
+func testResourceCreate[T any](t *testing.T, r resource.Resource, plan T) (T, diag.Diagnostics) {
+	t.Helper()
+
+	s := testResourceSchema(t, r)
+
+	req := resource.CreateRequest{Plan: tfsdk.Plan{Schema: s}}
+	testSetModel(t, req.Plan.Set(context.Background(), &plan))
+	req.Config = tfsdk.Config{Schema: s, Raw: req.Plan.Raw}
+
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(context.Background()), nil)}}
+	r.Create(context.Background(), req, &resp)
+
+	return testGetState[T](t, resp.State, resp.Diagnostics), resp.Diagnostics
+}
+
+// testResourceRead runs the Read of the resource with the given state and returns the refreshed state, or whether the
+// resource was removed from the state.
+func testResourceRead[T any](t *testing.T, r resource.Resource, state T) (T, bool, diag.Diagnostics) {
+	t.Helper()
+
+	s := testResourceSchema(t, r)
+
+	req := resource.ReadRequest{State: tfsdk.State{Schema: s}}
+	testSetModel(t, req.State.Set(context.Background(), &state))
+
+	resp := resource.ReadResponse{State: req.State}
+	r.Read(context.Background(), req, &resp)
+
+	if resp.State.Raw.IsNull() {
+		var removed T
+		return removed, true, resp.Diagnostics
+	}
+
+	return testGetState[T](t, resp.State, resp.Diagnostics), false, resp.Diagnostics
+}
+
+// testResourceUpdate runs the Update of the resource from the given state to the given plan, which is also used as the
+// configuration, and returns the resulting state.
+func testResourceUpdate[T any](t *testing.T, r resource.Resource, state T, plan T) (T, diag.Diagnostics) {
+	t.Helper()
+
+	s := testResourceSchema(t, r)
+
+	req := resource.UpdateRequest{State: tfsdk.State{Schema: s}, Plan: tfsdk.Plan{Schema: s}}
+	testSetModel(t, req.State.Set(context.Background(), &state))
+	testSetModel(t, req.Plan.Set(context.Background(), &plan))
+	req.Config = tfsdk.Config{Schema: s, Raw: req.Plan.Raw}
+
+	resp := resource.UpdateResponse{State: req.State}
+	r.Update(context.Background(), req, &resp)
+
+	return testGetState[T](t, resp.State, resp.Diagnostics), resp.Diagnostics
+}
+
+// testResourceModifyPlan runs the ModifyPlan of the resource with the given plan, which is also used as the
+// configuration, from the given state or a creation when it is nil, and returns the modified plan.
+func testResourceModifyPlan[T any](t *testing.T, r resource.ResourceWithModifyPlan, state *T, plan T) (T, diag.Diagnostics) {
+	t.Helper()
+
+	s := testResourceSchema(t, r)
+
+	req := resource.ModifyPlanRequest{
+		State: tfsdk.State{Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(context.Background()), nil)},
+		Plan:  tfsdk.Plan{Schema: s},
+	}
+	testSetModel(t, req.Plan.Set(context.Background(), &plan))
+	req.Config = tfsdk.Config{Schema: s, Raw: req.Plan.Raw}
+
+	if state != nil {
+		testSetModel(t, req.State.Set(context.Background(), state))
+	}
+
+	resp := resource.ModifyPlanResponse{Plan: req.Plan}
+	r.ModifyPlan(context.Background(), req, &resp)
+
+	var model T
+
+	if resp.Diagnostics.HasError() {
+		return model, resp.Diagnostics
+	}
+
+	if getDiags := resp.Plan.Get(context.Background(), &model); getDiags.HasError() {
+		t.Fatalf("failed to get plan: %v", getDiags)
+	}
+
+	return model, resp.Diagnostics
+}
+
+// testResourceImportState runs the ImportState of the resource with the given id, then the Read of the imported state,
+// as terraform does, and returns the resulting state.
+func testResourceImportState[T any](t *testing.T, r resource.Resource, id string) (T, diag.Diagnostics) {
+	t.Helper()
+
+	importer, ok := r.(resource.ResourceWithImportState)
+	if !ok {
+		t.Fatal("the resource does not support import")
+	}
+
+	s := testResourceSchema(t, r)
+
+	objectType := s.Type().TerraformType(context.Background()).(tftypes.Object)
+
+	attributes := map[string]tftypes.Value{}
+	for name, attributeType := range objectType.AttributeTypes {
+		attributes[name] = tftypes.NewValue(attributeType, nil)
+	}
+
+	resp := resource.ImportStateResponse{State: tfsdk.State{Schema: s, Raw: tftypes.NewValue(objectType, attributes)}}
+	importer.ImportState(context.Background(), resource.ImportStateRequest{ID: id}, &resp)
+
+	if resp.Diagnostics.HasError() {
+		var model T
+		return model, resp.Diagnostics
+	}
+
+	readResp := resource.ReadResponse{State: resp.State}
+	r.Read(context.Background(), resource.ReadRequest{State: resp.State}, &readResp)
+
+	return testGetState[T](t, readResp.State, readResp.Diagnostics), readResp.Diagnostics
+}
+
+// testResourceUpgradeState runs the state upgrader of the resource for the given version with the raw JSON state, and
+// returns the upgraded state.
+func testResourceUpgradeState[T any](t *testing.T, r resource.ResourceWithUpgradeState, version int64, rawState string) (T, diag.Diagnostics) {
+	t.Helper()
+
+	upgrader, ok := r.UpgradeState(context.Background())[version]
+	if !ok {
+		t.Fatalf("no state upgrader for version %d", version)
+	}
+
+	req := resource.UpgradeStateRequest{RawState: &tfprotov6.RawState{JSON: []byte(rawState)}}
+	resp := resource.UpgradeStateResponse{}
+	upgrader.StateUpgrader(context.Background(), req, &resp)
+
+	if resp.Diagnostics.HasError() {
+		var model T
+		return model, resp.Diagnostics
+	}
+
+	s := testResourceSchema(t, r)
+
+	raw, err := resp.DynamicValue.Unmarshal(s.Type().TerraformType(context.Background()))
+	if err != nil {
+		t.Fatalf("failed to decode the upgraded state: %v", err)
+	}
+
+	return testGetState[T](t, tfsdk.State{Schema: s, Raw: raw}, resp.Diagnostics), resp.Diagnostics
+}
+
+// testResourceDelete runs the Delete of the resource with the given state.
+func testResourceDelete[T any](t *testing.T, r resource.Resource, state T) diag.Diagnostics {
+	t.Helper()
+
+	s := testResourceSchema(t, r)
+
+	req := resource.DeleteRequest{State: tfsdk.State{Schema: s}}
+	testSetModel(t, req.State.Set(context.Background(), &state))
+
+	resp := resource.DeleteResponse{State: req.State}
+	r.Delete(context.Background(), req, &resp)
+
+	return resp.Diagnostics
+}
+
+// testDataSourceRead runs the Read of the data source with the given configuration and returns the resulting state.
+func testDataSourceRead[T any](t *testing.T, d datasource.DataSource, config T) (T, diag.Diagnostics) {
+	t.Helper()
+
+	schemaResp := datasource.SchemaResponse{}
+	d.Schema(context.Background(), datasource.SchemaRequest{}, &schemaResp)
+
+	if schemaResp.Diagnostics.HasError() {
+		t.Fatalf("invalid schema: %v", schemaResp.Diagnostics)
+	}
+
+	// The configuration is built through a state, as tfsdk.Config cannot be set from a model
+	configState := tfsdk.State{Schema: schemaResp.Schema}
+	testSetModel(t, configState.Set(context.Background(), &config))
+
+	req := datasource.ReadRequest{Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: configState.Raw}}
+	resp := datasource.ReadResponse{State: configState}
+	d.Read(context.Background(), req, &resp)
+
+	return testGetState[T](t, resp.State, resp.Diagnostics), resp.Diagnostics
+}
+
+// testFunctionRun runs the function with the arguments, the last one being a tuple for a variadic parameter, and
+// returns its string result.
+func testFunctionRun(t *testing.T, f function.Function, arguments ...attr.Value) (string, *function.FuncError) {
+	t.Helper()
+
+	definition := function.DefinitionResponse{}
+	f.Definition(context.Background(), function.DefinitionRequest{}, &definition)
+
+	validation := function.DefinitionValidateResponse{}
+	definition.Definition.ValidateImplementation(context.Background(), function.DefinitionValidateRequest{}, &validation)
+
+	if validation.Diagnostics.HasError() {
+		t.Fatalf("invalid definition: %v", validation.Diagnostics)
+	}
+
+	resp := function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+	f.Run(context.Background(), function.RunRequest{Arguments: function.NewArgumentsData(arguments)}, &resp)
+
+	result, _ := resp.Result.Value().(types.String)
+
+	return result.ValueString(), resp.Error
+}
+
+func testResourceSchema(t *testing.T, r resource.Resource) schema.Schema {
+	t.Helper()
+
+	resp := resource.SchemaResponse{}
+	r.Schema(context.Background(), resource.SchemaRequest{}, &resp)
+
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("invalid schema: %v", resp.Diagnostics)
+	}
+
+	return resp.Schema
+}
+
+func testSetModel(t *testing.T, diags diag.Diagnostics) {
+	t.Helper()
+
+	if diags.HasError() {
+		t.Fatalf("failed to set model: %v", diags)
+	}
+}
+
+func testGetState[T any](t *testing.T, state tfsdk.State, diags diag.Diagnostics) T {
+	t.Helper()
+
+	var model T
+
+	if diags.HasError() || state.Raw.IsNull() {
+		return model
+	}
+
+	if getDiags := state.Get(context.Background(), &model); getDiags.HasError() {
+		t.Fatalf("failed to get state: %v", getDiags)
+	}
+
+	return model
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"terraform-provider-setup/internal/provider/clients"
+)
+
+// aptProxyConfigPath is the apt configuration written with the proxy settings of the provider, so that apt also uses
+// them when it is run outside of terraform, e.g. by unattended-upgrades.
+const aptProxyConfigPath = "/etc/apt/apt.conf.d/95setup-proxy"
+
+// proxySettings are the proxies the remote host reaches the network through, as set in the provider configuration.
+type proxySettings struct {
+	HTTP    string
+	HTTPS   string
+	NoProxy string
+}
+
+// enabled returns whether a proxy is set.
+func (s proxySettings) enabled() bool {
+	return s.HTTP != "" || s.HTTPS != ""
+}
+
+// variables returns the names and values of the proxy variables that are set, in both cases as the tools disagree on
+// them.
+func (s proxySettings) variables() [][2]string {
+	var variables [][2]string
+
+	for _, variable := range [][2]string{{"http_proxy", s.HTTP}, {"https_proxy", s.HTTPS}, {"no_proxy", s.NoProxy}} {
+		if variable[1] != "" {
+			variables = append(variables, variable, [2]string{strings.ToUpper(variable[0]), variable[1]})
+		}
+	}
+
+	return variables
+}
+
+// environment returns the shell assignments of the proxy variables.
+func (s proxySettings) environment() []string {
+	var assignments []string
+
+	for _, variable := range s.variables() {
+		assignments = append(assignments, variable[0]+"="+clients.ShellQuote(variable[1]))
+	}
+
+	return assignments
+}
+
+// aptConfig returns the apt configuration of the settings. apt has no equivalent of no_proxy, the hosts it lists are
+// reached directly one by one, while domains and networks cannot be excluded.
+func (s proxySettings) aptConfig() string {
+	var lines []string
+
+	if s.HTTP != "" {
+		lines = append(lines, fmt.Sprintf("Acquire::http::Proxy %q;", s.HTTP))
+	}
+
+	if s.HTTPS != "" {
+		lines = append(lines, fmt.Sprintf("Acquire::https::Proxy %q;", s.HTTPS))
+	}
+
+	for host := range strings.SplitSeq(s.NoProxy, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" || strings.ContainsAny(host, "*/") || strings.HasPrefix(host, ".") {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("Acquire::http::Proxy::%s \"DIRECT\";", host), fmt.Sprintf("Acquire::https::Proxy::%s \"DIRECT\";", host))
+	}
+
+	return "// Managed by terraform-provider-setup from the proxy settings of the provider\n" + joinLines(lines)
+}
+
+// withProxy returns the command with the proxy settings of the provider exported, for the commands reaching the
+// network, e.g. apt-get, curl or pip. It must be wrapped by sudo, not the other way around, as sudo resets the
+// environment.
+func (p *internalProvider) withProxy(command string) string {
+	if !p.proxy.enabled() {
+		return command
+	}
+
+	return "export " + strings.Join(p.proxy.environment(), " ") + "; " + command
+}
+
+// aptProxyConfiguration is the apt proxy configuration of a host, written once.
+type aptProxyConfiguration struct {
+	once sync.Once
+	err  error
+}
+
+// configureAptProxy writes the apt proxy configuration when a proxy is set, and removes it otherwise. It only runs
+// once per host, before the first apt command.
+func (p *internalProvider) configureAptProxy(ctx context.Context) error {
+	p.aptProxyMutex.Lock()
+
+	if p.aptProxy == nil {
+		p.aptProxy = map[string]*aptProxyConfiguration{}
+	}
+
+	configuration, ok := p.aptProxy[clients.HostFrom(ctx)]
+	if !ok {
+		configuration = &aptProxyConfiguration{}
+		p.aptProxy[clients.HostFrom(ctx)] = configuration
+	}
+
+	p.aptProxyMutex.Unlock()
+
+	configuration.once.Do(func() {
+		if p.proxy.enabled() {
+			configuration.err = p.machineAccessClient.WriteFile(ctx, aptProxyConfigPath, "644", "0", "0", p.proxy.aptConfig())
+			return
+		}
+
+		// The configuration of a proxy removed from the provider configuration must not be left behind
+		_, err := p.machineAccessClient.Stat(ctx, aptProxyConfigPath, false)
+		if clients.IsFileNotFound(err) {
+			return
+		}
+
+		out, err := p.machineAccessClient.RunCommand(ctx, p.sudo(clients.ShellCommand("rm", "-f", aptProxyConfigPath)))
+		if err != nil {
+			configuration.err = fmt.Errorf("failed to remove %s. Err=%w\nout = %s", aptProxyConfigPath, err, out)
+		}
+	})
+
+	return configuration.err
+}
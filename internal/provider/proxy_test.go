@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+)
+
+func TestWithProxy(t *testing.T) {
+	t.Run("the command is unchanged without proxy", func(t *testing.T) {
+		// Arrange
+		p := newTestProvider(clients.NewMockMachineAccessClient())
+
+		// Act
+		command := p.withProxy("apt-get update")
+
+		// Assert
+		if command != "apt-get update" {
+			t.Fatalf("unexpected command: %s", command)
+		}
+	})
+
+	t.Run("the proxy variables are exported in both cases", func(t *testing.T) {
+		// Arrange
+		p := newTestProvider(clients.NewMockMachineAccessClient())
+		p.proxy = proxySettings{HTTPS: "http://proxy:3128", NoProxy: "localhost,.internal"}
+
+		// Act
+		command := p.withProxy("apt-get update")
+
+		// Assert
+		expected := "export https_proxy='http://proxy:3128' HTTPS_PROXY='http://proxy:3128' no_proxy='localhost,.internal' NO_PROXY='localhost,.internal'; apt-get update"
+		if command != expected {
+			t.Fatalf("unexpected command: %s", command)
+		}
+	})
+}
+
+func TestProxySettingsAptConfig(t *testing.T) {
+	// Arrange
+	settings := proxySettings{HTTP: "http://proxy:3128", HTTPS: "http://proxy:3128", NoProxy: "localhost, .internal,10.0.0.0/8,*.example.com,mirror"}
+
+	// Act
+	config := settings.aptConfig()
+
+	// Assert
+	expected := []string{
+		"// Managed by terraform-provider-setup from the proxy settings of the provider",
+		`Acquire::http::Proxy "http://proxy:3128";`,
+		`Acquire::https::Proxy "http://proxy:3128";`,
+		`Acquire::http::Proxy::localhost "DIRECT";`,
+		`Acquire::https::Proxy::localhost "DIRECT";`,
+		`Acquire::http::Proxy::mirror "DIRECT";`,
+		`Acquire::https::Proxy::mirror "DIRECT";`,
+	}
+
+	if config != strings.Join(expected, "\n")+"\n" {
+		t.Fatalf("unexpected configuration:\n%s", config)
+	}
+}
+
+func TestConfigureAptProxyWithMock(t *testing.T) {
+	t.Run("the configuration is written once when a proxy is set", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+		p := newTestProvider(mock)
+		p.proxy = proxySettings{HTTP: "http://proxy:3128"}
+
+		// Act
+		err := p.configureAptProxy(context.Background())
+		if err == nil {
+			delete(mock.Files, aptProxyConfigPath)
+			err = p.configureAptProxy(context.Background())
+		}
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := mock.Files[aptProxyConfigPath]; ok {
+			t.Fatal("expected the configuration to be written only once")
+		}
+	})
+
+	t.Run("a stale configuration is removed without proxy", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(aptProxyConfigPath, `Acquire::http::Proxy "http://old:3128";`, clients.FileInfo{Mode: "644"})
+
+		// Act
+		err := newTestProvider(mock).configureAptProxy(context.Background())
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mock.Commands) != 1 || mock.Commands[0] != "rm -f "+aptProxyConfigPath {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("nothing is done without proxy nor configuration", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		err := newTestProvider(mock).configureAptProxy(context.Background())
+
+		// Assert
+		if err != nil || len(mock.Commands) != 0 || len(mock.Files) != 0 {
+			t.Fatalf("unexpected result: %v, %v, %v", err, mock.Commands, mock.Files)
+		}
+	})
+}
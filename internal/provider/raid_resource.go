@@ -0,0 +1,456 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &raidResource{}
+
+const (
+	defaultMdadmConfigPath = "/etc/mdadm/mdadm.conf"
+	// updateInitramfsCommand regenerates the initramfs on Debian and Ubuntu, so that the arrays are assembled at boot.
+	updateInitramfsCommand = "if command -v update-initramfs >/dev/null 2>&1; then update-initramfs -u; fi"
+)
+
+func newRaidResource(p *internalProvider) resource.Resource {
+	return &raidResource{
+		provider: p,
+	}
+}
+
+// raidResource defines the resource implementation.
+type raidResource struct {
+	provider *internalProvider
+}
+
+type raidResourceModel struct {
+	Device        types.String `tfsdk:"device"`
+	Level         types.String `tfsdk:"level"`
+	Devices       types.List   `tfsdk:"devices"`
+	ConfigPath    types.String `tfsdk:"config_path"`
+	AllowDataLoss types.Bool   `tfsdk:"allow_data_loss"`
+	UUID          types.String `tfsdk:"uuid"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *raidResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_raid"
+}
+
+func (r *raidResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "RAID resource that creates a Linux software RAID array with mdadm, or assembles it when its " +
+			"devices already are members of the same array, and records it in the mdadm configuration so that it is " +
+			"assembled at boot. The array is only created on devices holding no filesystem, partition table or other " +
+			"signature: devices with data are refused and have to be wiped by hand. Changing the level or the devices " +
+			"recreates the array, which like destroying it requires allow_data_loss and allow_destructive_operations",
+
+		Attributes: map[string]schema.Attribute{
+			"device": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the array, e.g. '/dev/md0'",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"level": schema.StringAttribute{
+				Required:    true,
+				Description: "The RAID level, one of '0', '1', '4', '5', '6' or '10'",
+				Validators:  []validator.String{oneOf("0", "1", "4", "5", "6", "10")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"devices": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The devices making the array, e.g. ['/dev/sdb', '/dev/sdc']",
+				Validators:  []validator.List{listOf(absolutePath())},
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.RequiresReplace(),
+				},
+			},
+			"config_path": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(defaultMdadmConfigPath),
+				Description: "The mdadm configuration file the array is recorded in, '/etc/mdadm.conf' on RHEL. Defaults to " + defaultMdadmConfigPath,
+				Validators:  []validator.String{absolutePath()},
+			},
+			"allow_data_loss": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether destroying or replacing the resource may stop the array and erase the RAID superblocks of its devices, losing its data. Apply it set to true before destroying the resource. Defaults to false",
+			},
+			"uuid": schema.StringAttribute{
+				Computed:    true,
+				Description: "The UUID of the array",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *raidResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *raidResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan raidResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	device := plan.Device.ValueString()
+
+	devices := stringList(ctx, plan.Devices, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	detail, found, err := r.detail(ctx, device)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the array", err.Error())
+		return
+	}
+
+	switch {
+	case found:
+		// The array is already running, it is only adopted when it has the planned layout
+		if level, members := raidLayout(detail); level != plan.Level.ValueString() || !sameElements(members, devices) {
+			resp.Diagnostics.AddError("Array already exists", fmt.Sprintf("%s already exists with level %s and devices %s, refusing to change it", device, level, strings.Join(members, ", ")))
+			return
+		}
+	default:
+		assemble, err := r.sameArrayMembers(ctx, devices)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to probe the devices", err.Error())
+			return
+		}
+
+		args := append([]string{"--assemble", device}, devices...)
+		if !assemble {
+			if err := requireBlankDevices(ctx, r.provider, devices); err != nil {
+				resp.Diagnostics.AddError("Refusing to create the array", err.Error())
+				return
+			}
+
+			args = append([]string{"--create", device, "--run", "--level=" + plan.Level.ValueString(), "--raid-devices=" + strconv.Itoa(len(devices))}, devices...)
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("mdadm", args...)))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to set up the array", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+
+		detail, _, err = r.detail(ctx, device)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the array", err.Error())
+			return
+		}
+	}
+
+	plan.UUID = types.StringValue(detail["MD_UUID"])
+
+	if !r.record(ctx, plan, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *raidResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state raidResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	detail, found, err := r.detail(ctx, state.Device.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the array", err.Error())
+		return
+	}
+
+	// An array that is not assembled is assembled again by the next apply
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	level, members := raidLayout(detail)
+
+	if !sameElements(members, stringList(ctx, state.Devices, &resp.Diagnostics)) {
+		devices, diags := types.ListValueFrom(ctx, types.StringType, members)
+		resp.Diagnostics.Append(diags...)
+
+		state.Devices = devices
+	}
+
+	state.Level = types.StringValue(level)
+	state.UUID = types.StringValue(detail["MD_UUID"])
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *raidResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state raidResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Only the configuration file can change in place, the array is moved to the new one
+	if plan.ConfigPath != state.ConfigPath {
+		begin, end := raidMarkers(state.Device.ValueString())
+
+		err := r.provider.editSystemFile(ctx, state.ConfigPath.ValueString(), "644", func(lines []string) ([]string, bool) {
+			return removeMarkedBlock(lines, begin, end)
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update the mdadm configuration", err.Error())
+			return
+		}
+	}
+
+	if !r.record(ctx, plan, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *raidResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state raidResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	device := state.Device.ValueString()
+
+	if !state.AllowDataLoss.ValueBool() {
+		resp.Diagnostics.AddError("Data loss refused", fmt.Sprintf("Refusing to destroy the RAID array %s as allow_data_loss is false. "+
+			"Apply it set to true first to allow it, or remove the resource from the state with terraform state rm to leave it on the machine", device))
+		return
+	}
+
+	if !r.provider.allowDestructiveOperation("stop the RAID array "+device+" and erase the RAID superblocks of its devices", &resp.Diagnostics) {
+		return
+	}
+
+	devices := stringList(ctx, state.Devices, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("mdadm", "--stop", device)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stop the array", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("mdadm", append([]string{"--zero-superblock"}, devices...)...)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to erase the RAID superblocks", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	begin, end := raidMarkers(device)
+
+	err = r.provider.editSystemFile(ctx, state.ConfigPath.ValueString(), "644", func(lines []string) ([]string, bool) {
+		return removeMarkedBlock(lines, begin, end)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update the mdadm configuration", err.Error())
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", updateInitramfsCommand)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update the initramfs", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// detail returns the properties of the array, and whether it is running.
+func (r *raidResource) detail(ctx context.Context, device string) (map[string]string, bool, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", "[ -b "+clients.ShellQuote(device)+" ] || exit 0; mdadm --detail --export "+clients.ShellQuote(device))))
+	if err != nil {
+		return nil, false, fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	detail := parseExportedProperties(out)
+
+	return detail, detail["MD_UUID"] != "", nil
+}
+
+// sameArrayMembers returns whether all the devices are members of the same array, which can then be assembled
+// without losing data.
+func (r *raidResource) sameArrayMembers(ctx context.Context, devices []string) (bool, error) {
+	uuid := ""
+
+	for _, device := range devices {
+		signature, err := blockDeviceSignature(ctx, r.provider, device)
+		if err != nil {
+			return false, err
+		}
+
+		if signature["TYPE"] != "linux_raid_member" || (uuid != "" && signature["UUID"] != uuid) {
+			return false, nil
+		}
+
+		uuid = signature["UUID"]
+	}
+
+	return uuid != "", nil
+}
+
+// record writes the array to the mdadm configuration and regenerates the initramfs when it changed.
+func (r *raidResource) record(ctx context.Context, plan raidResourceModel, diags *diag.Diagnostics) bool {
+	device := plan.Device.ValueString()
+	begin, end := raidMarkers(device)
+	block := []string{begin, "ARRAY " + device + " UUID=" + plan.UUID.ValueString(), end}
+	changed := false
+
+	err := r.provider.editSystemFile(ctx, plan.ConfigPath.ValueString(), "644", func(lines []string) ([]string, bool) {
+		lines, changed = setMarkedBlock(lines, block, false)
+		return lines, changed
+	})
+	if err != nil {
+		diags.AddError("Failed to update the mdadm configuration", err.Error())
+		return false
+	}
+
+	if !changed {
+		return true
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", updateInitramfsCommand)))
+	if err != nil {
+		diags.AddError("Failed to update the initramfs", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return false
+	}
+
+	return true
+}
+
+// raidMarkers returns the lines delimiting the array in the mdadm configuration.
+func raidMarkers(device string) (string, string) {
+	return "# BEGIN TERRAFORM RAID " + device, "# END TERRAFORM RAID " + device
+}
+
+// raidLayout returns the level and the devices, ordered by role, of the array described by `mdadm --detail --export`.
+func raidLayout(detail map[string]string) (string, []string) {
+	type member struct {
+		device string
+		role   int
+	}
+
+	members := []member{}
+
+	for key, value := range detail {
+		if !strings.HasPrefix(key, "MD_DEVICE_") || !strings.HasSuffix(key, "_DEV") {
+			continue
+		}
+
+		role, err := strconv.Atoi(detail[strings.TrimSuffix(key, "_DEV")+"_ROLE"])
+		if err != nil {
+			// spares and faulty devices have no numeric role
+			role = len(detail)
+		}
+
+		members = append(members, member{device: value, role: role})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].role != members[j].role {
+			return members[i].role < members[j].role
+		}
+
+		return members[i].device < members[j].device
+	})
+
+	devices := make([]string, 0, len(members))
+	for _, m := range members {
+		devices = append(devices, m.device)
+	}
+
+	return strings.TrimPrefix(detail["MD_LEVEL"], "raid"), devices
+}
+
+// sameElements returns whether both lists hold the same elements, whatever their order.
+func sameElements(a []string, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testRaidDetail = `MD_LEVEL=raid1
+MD_DEVICES=2
+MD_METADATA=1.2
+MD_UUID=3aaa0122:29827cfa:5331ad66:ca767371
+MD_DEVNAME=md0
+MD_DEVICE_dev_sdc_ROLE=1
+MD_DEVICE_dev_sdc_DEV=/dev/sdc
+MD_DEVICE_dev_sdb_ROLE=0
+MD_DEVICE_dev_sdb_DEV=/dev/sdb
+`
+
+func TestRaidResourceWithMock(t *testing.T) {
+	t.Run("create builds the array on blank devices and records it", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("mdadm --detail --export", clients.MockResponse{Once: true}).
+			OnMatch("mdadm --detail --export", clients.MockResponse{Stdout: testRaidDetail}).
+			OnMatch("^blkid -p", clients.MockResponse{ExitCode: 2}).
+			WithFile(defaultMdadmConfigPath, "HOMEHOST <system>\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, diags := testResourceCreate(t, newRaidResource(newTestProvider(mock)), testRaidModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "mdadm --create /dev/md0 --run --level=1 --raid-devices=2 /dev/sdb /dev/sdc") {
+			t.Fatalf("expected the array to be created, got: %v", mock.Commands)
+		}
+
+		expected := "HOMEHOST <system>\n# BEGIN TERRAFORM RAID /dev/md0\nARRAY /dev/md0 UUID=3aaa0122:29827cfa:5331ad66:ca767371\n# END TERRAFORM RAID /dev/md0\n"
+		if mock.Files[defaultMdadmConfigPath] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultMdadmConfigPath])
+		}
+
+		if state.UUID.ValueString() != "3aaa0122:29827cfa:5331ad66:ca767371" {
+			t.Fatalf("unexpected uuid: %s", state.UUID)
+		}
+	})
+
+	t.Run("create refuses devices holding data", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("blkid -p -o export /dev/sdb", clients.MockResponse{ExitCode: 2}).
+			On("blkid -p -o export /dev/sdc", clients.MockResponse{Stdout: "UUID=1b5e\nTYPE=ext4\nUSAGE=filesystem\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newRaidResource(newTestProvider(mock)), testRaidModel())
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags[0].Detail(), "/dev/sdc which holds data (ext4)") {
+			t.Fatalf("expected the devices to be refused, got: %v", diags)
+		}
+
+		if slices.ContainsFunc(mock.Commands, func(command string) bool { return strings.HasPrefix(command, "mdadm --create") }) {
+			t.Fatalf("expected no array to be created, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create assembles the devices of an existing array", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("mdadm --detail --export", clients.MockResponse{Once: true}).
+			OnMatch("mdadm --detail --export", clients.MockResponse{Stdout: testRaidDetail}).
+			OnMatch("^blkid -p", clients.MockResponse{Stdout: "UUID=3aaa0122-2982-7cfa-5331-ad66ca767371\nTYPE=linux_raid_member\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newRaidResource(newTestProvider(mock)), testRaidModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "mdadm --assemble /dev/md0 /dev/sdb /dev/sdc") {
+			t.Fatalf("expected the array to be assembled, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects changed devices", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("mdadm --detail --export", clients.MockResponse{Stdout: strings.ReplaceAll(testRaidDetail, "sdc", "sdd")})
+
+		// Act
+		state, _, diags := testResourceRead(t, newRaidResource(newTestProvider(mock)), testRaidState())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Devices.String() != `["/dev/sdb","/dev/sdd"]` {
+			t.Fatalf("unexpected devices: %s", state.Devices)
+		}
+	})
+
+	t.Run("delete is refused without allow_data_loss", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newRaidResource(newTestProvider(mock)), testRaidState())
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("expected no command, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("delete stops the array and erases the superblocks", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(defaultMdadmConfigPath, "HOMEHOST <system>\n# BEGIN TERRAFORM RAID /dev/md0\nARRAY /dev/md0 UUID=3aaa0122:29827cfa:5331ad66:ca767371\n# END TERRAFORM RAID /dev/md0\n", clients.FileInfo{Mode: "644"})
+
+		state := testRaidState()
+		state.AllowDataLoss = types.BoolValue(true)
+
+		// Act
+		diags := testResourceDelete(t, newRaidResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "mdadm --stop /dev/md0") || !slices.Contains(mock.Commands, "mdadm --zero-superblock /dev/sdb /dev/sdc") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if mock.Files[defaultMdadmConfigPath] != "HOMEHOST <system>\n" {
+			t.Fatalf("unexpected content: %q", mock.Files[defaultMdadmConfigPath])
+		}
+	})
+}
+
+func testRaidModel() raidResourceModel {
+	devices, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"/dev/sdb", "/dev/sdc"})
+
+	return raidResourceModel{
+		Device:        types.StringValue("/dev/md0"),
+		Level:         types.StringValue("1"),
+		Devices:       devices,
+		ConfigPath:    types.StringValue(defaultMdadmConfigPath),
+		AllowDataLoss: types.BoolValue(false),
+		UUID:          types.StringUnknown(),
+	}
+}
+
+func testRaidState() raidResourceModel {
+	model := testRaidModel()
+	model.UUID = types.StringValue("3aaa0122:29827cfa:5331ad66:ca767371")
+
+	return model
+}
@@ -0,0 +1,243 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &rebootResource{}
+
+// bootIDCommand prints a random id generated by the kernel at each boot.
+const bootIDCommand = "cat /proc/sys/kernel/random/boot_id"
+
+func newRebootResource(p *internalProvider) resource.Resource {
+	return &rebootResource{
+		provider:     p,
+		pollInterval: waitForPollInterval,
+	}
+}
+
+// rebootResource defines the resource implementation.
+type rebootResource struct {
+	provider *internalProvider
+
+	// pollInterval is the delay between two attempts to reach the machine while it reboots.
+	pollInterval time.Duration
+}
+
+type rebootResourceModel struct {
+	Triggers types.Map    `tfsdk:"triggers"`
+	Enabled  types.Bool   `tfsdk:"enabled"`
+	Command  types.String `tfsdk:"command"`
+	Timeout  types.String `tfsdk:"timeout"`
+	BootID   types.String `tfsdk:"boot_id"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *rebootResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_reboot"
+}
+
+func (r *rebootResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Reboot resource that reboots the machine when it is created, and again whenever triggers " +
+			"change, then waits for the machine to be reachable over SSH with a new boot id. Make it depend on the " +
+			"resources needing a reboot, e.g. setup_grub_config, and the resources needing the rebooted machine depend " +
+			"on it. Destroying the resource leaves the machine as it is",
+
+		Attributes: map[string]schema.Attribute{
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Arbitrary values that cause a reboot when changed, e.g. the kernel parameters of a setup_grub_config",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"enabled": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether the machine is rebooted, e.g. setup_grub_config.example.reboot_required. When false, the triggers are recorded without rebooting. Defaults to true",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"command": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("reboot"),
+				Description: "The command rebooting the machine, run with elevated privileges, e.g. 'systemctl reboot'. It is run in the background after a short delay, so that it is not killed by the reboot it starts. Defaults to 'reboot'",
+			},
+			"timeout": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("10m"),
+				Description: "The maximum time to wait for the machine to come back, as a duration (e.g. '10m'). Defaults to '10m'",
+			},
+			"boot_id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The boot id of the machine after the reboot, from /proc/sys/kernel/random/boot_id",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *rebootResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *rebootResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan rebootResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	timeout, err := time.ParseDuration(plan.Timeout.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse timeout", err.Error())
+		return
+	}
+
+	bootID, err := r.bootID(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the boot id", err.Error())
+		return
+	}
+
+	if plan.Enabled.ValueBool() {
+		bootID, err = r.reboot(ctx, plan.Command.ValueString(), bootID, timeout)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to reboot the machine", err.Error())
+			return
+		}
+	}
+
+	plan.BootID = types.StringValue(bootID)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *rebootResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
+	// the reboot only happens on creation, there is nothing to read back
+}
+
+func (r *rebootResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan rebootResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Changing the command or the timeout does not reboot the machine again
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *rebootResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// nothing to do, the machine is left as is
+}
+
+// reboot starts the command in the background, and waits for the machine to be reachable with another boot id than
+// the given one. It returns the new boot id.
+func (r *rebootResource) reboot(ctx context.Context, command string, bootID string, timeout time.Duration) (string, error) {
+	// The command is detached from the SSH session, so that the session ends cleanly before the connection drops
+	detached := "nohup " + clients.ShellCommand("sh", "-c", "sleep 2 && "+command) + " >/dev/null 2>&1 &"
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", detached)))
+	if err != nil {
+		return "", fmt.Errorf("failed to start the reboot. Err=%w\nout = %s", err, out)
+	}
+
+	tflog.Info(ctx, "Rebooting the machine, boot id "+bootID)
+
+	// The machine is not rebooted in a dry run, its boot id does not change
+	if r.provider.dryRun {
+		return bootID, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The connection drops while the machine reboots, the client reconnects once it is back
+	lastErr := errors.New("the machine did not reboot")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for the machine to come back after %s, last error: %w", timeout, lastErr)
+		case <-time.After(r.pollInterval):
+		}
+
+		newBootID, err := r.bootID(ctx)
+		if err != nil {
+			tflog.Info(ctx, "Machine is not reachable yet: "+err.Error())
+			lastErr = err
+
+			continue
+		}
+
+		if newBootID != bootID {
+			return newBootID, nil
+		}
+	}
+}
+
+// bootID returns the boot id of the machine.
+func (r *rebootResource) bootID(ctx context.Context) (string, error) {
+	result, err := r.provider.machineAccessClient.Run(clients.WithoutMutation(ctx), bootIDCommand)
+	if err != nil {
+		return "", err
+	}
+
+	bootID := strings.TrimSpace(result.Stdout)
+	if bootID == "" {
+		return "", errors.New("empty boot id")
+	}
+
+	return bootID, nil
+}
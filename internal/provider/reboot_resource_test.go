@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRebootResourceWithMock(t *testing.T) {
+	t.Run("create reboots and waits for a new boot id", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(bootIDCommand, clients.MockResponse{Stdout: "first\n", Once: true}).
+			On(bootIDCommand, clients.MockResponse{Stdout: "first\n", Once: true}).
+			On(bootIDCommand, clients.MockResponse{Err: errors.New("connection refused"), Once: true}).
+			On(bootIDCommand, clients.MockResponse{Stdout: "second\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, testRebootResource(mock), testRebootModel(true))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.BootID.ValueString() != "second" {
+			t.Fatalf("unexpected boot id: %s", state.BootID)
+		}
+
+		if !testRebootCommandRan(mock) {
+			t.Fatalf("expected a reboot, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does not reboot when disabled", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(bootIDCommand, clients.MockResponse{Stdout: "first\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, testRebootResource(mock), testRebootModel(false))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.BootID.ValueString() != "first" || testRebootCommandRan(mock) {
+			t.Fatalf("unexpected reboot: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create times out when the machine does not come back", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(bootIDCommand, clients.MockResponse{Stdout: "first\n", Once: true}).
+			On(bootIDCommand, clients.MockResponse{Err: errors.New("connection refused")})
+
+		model := testRebootModel(true)
+		model.Timeout = types.StringValue("50ms")
+
+		// Act
+		_, diags := testResourceCreate(t, testRebootResource(mock), model)
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "connection refused") {
+			t.Fatalf("expected a timeout with the last error, got: %v", diags)
+		}
+	})
+}
+
+func testRebootResource(mock *clients.MockMachineAccessClient) *rebootResource {
+	r := newRebootResource(newTestProvider(mock)).(*rebootResource)
+	r.pollInterval = time.Millisecond
+
+	return r
+}
+
+func testRebootModel(enabled bool) rebootResourceModel {
+	return rebootResourceModel{
+		Triggers: types.MapNull(types.StringType),
+		Enabled:  types.BoolValue(enabled),
+		Command:  types.StringValue("reboot"),
+		Timeout:  types.StringValue("1m"),
+		BootID:   types.StringUnknown(),
+	}
+}
+
+func testRebootCommandRan(mock *clients.MockMachineAccessClient) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, "sleep 2 && reboot") {
+			return true
+		}
+	}
+
+	return false
+}
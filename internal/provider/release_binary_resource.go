@@ -0,0 +1,514 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	tfpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &releaseBinaryResource{}
+var _ resource.ResourceWithModifyPlan = &releaseBinaryResource{}
+
+func newReleaseBinaryResource(p *internalProvider) resource.Resource {
+	return &releaseBinaryResource{
+		provider: p,
+	}
+}
+
+// releaseBinaryResource defines the resource implementation.
+type releaseBinaryResource struct {
+	provider *internalProvider
+}
+
+type releaseBinaryResourceModel struct {
+	Name          types.String   `tfsdk:"name"`
+	Version       types.String   `tfsdk:"version"`
+	URL           types.String   `tfsdk:"url"`
+	SHA256        types.String   `tfsdk:"sha256"`
+	ChecksumURL   types.String   `tfsdk:"checksum_url"`
+	Architectures types.Map      `tfsdk:"architectures"`
+	ArchivePath   types.String   `tfsdk:"archive_path"`
+	Destination   types.String   `tfsdk:"destination"`
+	Architecture  types.String   `tfsdk:"architecture"`
+	BinarySHA256  types.String   `tfsdk:"binary_sha256"`
+	Timeouts      *timeoutsModel `tfsdk:"timeouts"`
+	Retry         *retryModel    `tfsdk:"retry"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *releaseBinaryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_release_binary"
+}
+
+func (r *releaseBinaryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Release binary resource that installs a pinned version of a single-binary tool, e.g. kubectl, " +
+			"helm or a tool published as GitHub release assets. The release built for the architecture of the machine is " +
+			"downloaded, verified with its sha256 checksum, extracted when it is a .tar.gz, .tgz, .tar.xz or .zip archive, " +
+			"and installed with the mode 0755. In url, checksum_url and archive_path, {version} is replaced by the " +
+			"version and {arch} by the architecture of the release. A binary changed or removed outside of terraform is " +
+			"installed again. Destroying the resource removes the binary",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the binary, e.g. 'kubectl'",
+				Validators:  []validator.String{fileName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"version": schema.StringAttribute{
+				Required:    true,
+				Description: "The version of the release, e.g. '1.30.4'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"url": schema.StringAttribute{
+				Required:    true,
+				Description: "The URL of the release, e.g. 'https://dl.k8s.io/release/v{version}/bin/linux/{arch}/kubectl' or 'https://github.com/cli/cli/releases/download/v{version}/gh_{version}_linux_{arch}.tar.gz'",
+				Validators:  []validator.String{notBlank()},
+			},
+			"sha256": schema.StringAttribute{
+				Optional:    true,
+				Description: "The expected sha256 checksum of the release downloaded for the machine. Either sha256 or checksum_url must be set",
+			},
+			"checksum_url": schema.StringAttribute{
+				Optional: true,
+				Description: "The URL of the checksums of the release, e.g. 'https://dl.k8s.io/release/v{version}/bin/linux/{arch}/kubectl.sha256'. " +
+					"The file either holds the checksum alone, or lists the checksums of the files of the release in the format of sha256sum. Either sha256 or checksum_url must be set",
+			},
+			"architectures": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The names of the architectures in the release, by architecture of the machine in the naming of Docker and Go, e.g. { amd64 = \"x86_64\", arm64 = \"aarch64\" }. The architectures which are not listed keep their name, e.g. 'amd64' or 'arm64'",
+			},
+			"archive_path": schema.StringAttribute{
+				Optional:    true,
+				Description: "The path of the binary in the archive, e.g. 'linux-{arch}/helm'. If not specified, the file of the archive named after the binary is installed",
+			},
+			"destination": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The path where the binary is installed. Defaults to /usr/local/bin/<name>",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"architecture": schema.StringAttribute{
+				Computed:    true,
+				Description: "The architecture of the installed release, in the naming of the release",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"binary_sha256": schema.StringAttribute{
+				Computed:    true,
+				Description: "The sha256 checksum of the installed binary",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeoutsBlock(),
+			"retry":    retryBlock(),
+		},
+	}
+}
+
+func (r *releaseBinaryResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan requires a checksum, and defaults the destination to the name of the binary.
+func (r *releaseBinaryResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan releaseBinaryResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.SHA256.IsNull() == plan.ChecksumURL.IsNull() {
+		resp.Diagnostics.AddError("Invalid checksum", "exactly one of sha256 and checksum_url must be set")
+		return
+	}
+
+	if plan.Destination.IsUnknown() && !plan.Name.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tfpath.Root("destination"), "/usr/local/bin/"+plan.Name.ValueString())...)
+	}
+
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	var state releaseBinaryResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The binary installed again has the checksum of the new release
+	if plan.needsInstall(state) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tfpath.Root("binary_sha256"), types.StringUnknown())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tfpath.Root("architecture"), types.StringUnknown())...)
+	}
+}
+
+func (r *releaseBinaryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan releaseBinaryResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.create())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	r.install(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *releaseBinaryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model releaseBinaryResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// sha256sum exits with 1 when the file does not exist, the other errors are failures to read it
+	checksum, err := r.checksum(ctx, model.Destination.ValueString())
+	if err != nil && !clients.IsExitCode(err, 1) {
+		resp.Diagnostics.AddError("Failed to compute checksum", err.Error())
+		return
+	}
+
+	if err != nil || !strings.EqualFold(checksum, model.BinarySHA256.ValueString()) {
+		// The binary was removed or changed outside of terraform, it must be installed again
+		tflog.Debug(ctx, fmt.Sprintf("The binary %s is not the installed one: %v", model.Destination.ValueString(), err))
+		resp.State.RemoveResource(ctx)
+
+		return
+	}
+}
+
+func (r *releaseBinaryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan releaseBinaryResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state releaseBinaryResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, plan.Timeouts.update())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	if plan.needsInstall(state) {
+		r.install(ctx, &plan, &resp.Diagnostics)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *releaseBinaryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model releaseBinaryResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	ctx, cancel, err := withTimeout(ctx, model.Timeouts.delete())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid timeout", err.Error())
+		return
+	}
+	defer cancel()
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", model.Destination.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove binary", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// needsInstall returns whether the release of the model differs from the installed one.
+func (model releaseBinaryResourceModel) needsInstall(state releaseBinaryResourceModel) bool {
+	return !model.Version.Equal(state.Version) || !model.URL.Equal(state.URL) || !model.ChecksumURL.Equal(state.ChecksumURL) ||
+		!strings.EqualFold(model.SHA256.ValueString(), state.SHA256.ValueString()) || !model.Architectures.Equal(state.Architectures) ||
+		!model.ArchivePath.Equal(state.ArchivePath)
+}
+
+// install downloads the release of the architecture of the machine into a remote temporary directory, verifies its
+// checksum, extracts it when it is an archive, and installs the binary. The computed attributes of the model are set.
+func (r *releaseBinaryResource) install(ctx context.Context, model *releaseBinaryResourceModel, diags *diag.Diagnostics) {
+	architecture, err := r.architecture(ctx, *model)
+	if err != nil {
+		diags.AddError("Unsupported architecture", err.Error())
+		return
+	}
+
+	expand := strings.NewReplacer("{version}", model.Version.ValueString(), "{arch}", architecture).Replace
+	url := expand(model.URL.ValueString())
+	asset := path.Base(url)
+
+	tmpDir, err := r.provider.machineAccessClient.RunCommand(ctx, "mktemp -d")
+	if err != nil {
+		diags.AddError("Failed to create remote temp directory", fmt.Sprintf("Err=%s\nout = %s", err, tmpDir))
+		return
+	}
+
+	tmpDir = strings.TrimSpace(tmpDir)
+
+	defer func() {
+		_, _ = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("rm", "-rf", tmpDir))
+	}()
+
+	if err := r.fetch(ctx, *model, url, tmpDir+"/"+asset); err != nil {
+		diags.AddError("Failed to download release", err.Error())
+		return
+	}
+
+	expected := model.SHA256.ValueString()
+	if model.SHA256.IsNull() {
+		expected, err = r.releaseChecksum(ctx, *model, expand(model.ChecksumURL.ValueString()), tmpDir, asset)
+		if err != nil {
+			diags.AddError("Failed to get the checksum of the release", err.Error())
+			return
+		}
+	}
+
+	checksum, err := r.checksum(ctx, tmpDir+"/"+asset)
+	if err != nil {
+		diags.AddError("Failed to compute checksum", err.Error())
+		return
+	}
+
+	if !strings.EqualFold(checksum, expected) {
+		diags.AddError("Checksum mismatch", fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", url, expected, checksum))
+		return
+	}
+
+	binary, err := r.extract(ctx, *model, expand, tmpDir, asset)
+	if err != nil {
+		diags.AddError("Failed to extract release", err.Error())
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("install", "-m", "0755", binary, model.Destination.ValueString())))
+	if err != nil {
+		diags.AddError("Failed to install binary", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	binaryChecksum, err := r.checksum(ctx, model.Destination.ValueString())
+	if err != nil {
+		diags.AddError("Failed to compute checksum", err.Error())
+		return
+	}
+
+	model.Architecture = types.StringValue(architecture)
+	model.BinarySHA256 = types.StringValue(binaryChecksum)
+}
+
+// architecture returns the architecture of the machine in the naming of the release.
+func (r *releaseBinaryResource) architecture(ctx context.Context, model releaseBinaryResourceModel) (string, error) {
+	architecture := r.provider.capabilities(ctx).Architecture
+	if architecture == "" {
+		return "", fmt.Errorf("the architecture of the machine is unknown")
+	}
+
+	architectures := map[string]string{}
+
+	if diags := model.Architectures.ElementsAs(ctx, &architectures, false); diags.HasError() {
+		return "", fmt.Errorf("failed to read architectures: %v", diags)
+	}
+
+	if renamed, ok := architectures[architecture]; ok {
+		return renamed, nil
+	}
+
+	return architecture, nil
+}
+
+// extract returns the path of the binary of the downloaded release, extracting it first when it is an archive.
+func (r *releaseBinaryResource) extract(ctx context.Context, model releaseBinaryResourceModel, expand func(string) string, tmpDir string, asset string) (string, error) {
+	var command string
+
+	switch {
+	case strings.HasSuffix(asset, ".tar.gz") || strings.HasSuffix(asset, ".tgz"):
+		command = clients.ShellCommand("tar", "-xzf", tmpDir+"/"+asset, "-C", tmpDir+"/extracted")
+	case strings.HasSuffix(asset, ".tar.xz"):
+		command = clients.ShellCommand("tar", "-xJf", tmpDir+"/"+asset, "-C", tmpDir+"/extracted")
+	case strings.HasSuffix(asset, ".zip"):
+		command = clients.ShellCommand("unzip", "-q", tmpDir+"/"+asset, "-d", tmpDir+"/extracted")
+	default:
+		return tmpDir + "/" + asset, nil
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("mkdir", tmpDir+"/extracted")+" && "+command)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s. Err=%w\nout = %s", asset, err, out)
+	}
+
+	if !model.ArchivePath.IsNull() {
+		return tmpDir + "/extracted/" + strings.TrimPrefix(expand(model.ArchivePath.ValueString()), "/"), nil
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("find", tmpDir+"/extracted", "-type", "f", "-name", model.Name.ValueString()))
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s in %s. Err=%w\nout = %s", model.Name.ValueString(), asset, err, out)
+	}
+
+	found := strings.Fields(out)
+	if len(found) != 1 {
+		return "", fmt.Errorf("expected a single file named %s in %s, found %d, set archive_path", model.Name.ValueString(), asset, len(found))
+	}
+
+	return found[0], nil
+}
+
+// releaseChecksum returns the checksum of the asset from the checksums of the release, either the checksum alone or the
+// checksums of the files of the release in the format of sha256sum.
+func (r *releaseBinaryResource) releaseChecksum(ctx context.Context, model releaseBinaryResourceModel, checksumURL string, tmpDir string, asset string) (string, error) {
+	if err := r.fetch(ctx, model, checksumURL, tmpDir+"/checksums"); err != nil {
+		return "", err
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, tmpDir+"/checksums")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the checksums: %w", err)
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+
+		// sha256sum marks the files read in binary mode with a leading *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == asset {
+			return fields[0], nil
+		}
+	}
+
+	if len(lines) > 0 {
+		if fields := strings.Fields(lines[0]); len(fields) == 1 {
+			return fields[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("the checksums of %s have no checksum for %s", checksumURL, asset)
+}
+
+// fetch downloads the url to the destination, with the retries of the model.
+func (r *releaseBinaryResource) fetch(ctx context.Context, model releaseBinaryResourceModel, url string, destination string) error {
+	tflog.Debug(ctx, "Downloading "+url+" to "+destination)
+
+	out, err := retry(ctx, model.Retry, func() (string, error) {
+		return r.provider.machineAccessClient.RunCommand(ctx, r.provider.withProxy(fetchCommand(url, destination)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download %s. Err=%w\nout = %s", url, err, out)
+	}
+
+	return nil
+}
+
+func (r *releaseBinaryResource) checksum(ctx context.Context, filePath string) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sha256sum", filePath)))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute sha256 of %s. Err=%w\nout = %s", filePath, err, out)
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected sha256sum output: %s", out)
+	}
+
+	return fields[0], nil
+}
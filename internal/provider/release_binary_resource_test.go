@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestReleaseBinaryResourceWithMock(t *testing.T) {
+	t.Run("create installs the binary of the architecture verified with its checksum file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.release\n"}).
+			WithFile("/tmp/tmp.release/checksums", "7c3807c0f5c1b30110a2ff1e55da1d112a6d0096201f1beb81b269f582b5d1c5\n", clients.FileInfo{Mode: "644"}).
+			OnMatch(`^sha256sum /tmp/tmp.release/kubectl$`, clients.MockResponse{Stdout: "7c3807c0f5c1b30110a2ff1e55da1d112a6d0096201f1beb81b269f582b5d1c5  /tmp/tmp.release/kubectl\n"}).
+			OnMatch(`^sha256sum /usr/local/bin/kubectl$`, clients.MockResponse{Stdout: "7c3807c0f5c1b30110a2ff1e55da1d112a6d0096201f1beb81b269f582b5d1c5  /usr/local/bin/kubectl\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newReleaseBinaryResource(newTestProviderWithArchitecture(mock, "arm64")), testReleaseBinaryModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !testReleaseBinaryCommandRan(mock, "https://dl.k8s.io/release/v1.30.4/bin/linux/arm64/kubectl.sha256") {
+			t.Fatalf("expected the checksum of the arm64 release to be downloaded, got: %v", mock.Commands)
+		}
+
+		if !testReleaseBinaryCommandRan(mock, "install -m 0755 /tmp/tmp.release/kubectl /usr/local/bin/kubectl") || testReleaseBinaryCommandRan(mock, "tar") {
+			t.Fatalf("expected the binary to be installed as it is, got: %v", mock.Commands)
+		}
+
+		if state.Architecture.ValueString() != "arm64" || state.BinarySHA256.ValueString() != "7c3807c0f5c1b30110a2ff1e55da1d112a6d0096201f1beb81b269f582b5d1c5" {
+			t.Fatalf("unexpected state: %s, %s", state.Architecture.ValueString(), state.BinarySHA256.ValueString())
+		}
+	})
+
+	t.Run("create extracts the binary from an archive named after another architecture", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.release\n"}).
+			WithFile("/tmp/tmp.release/checksums", "aaaa  gh_2.55.0_linux_arm64.tar.gz\nbbbb *gh_2.55.0_linux_x86_64.tar.gz\n", clients.FileInfo{Mode: "644"}).
+			OnMatch(`^sha256sum /tmp/tmp.release/gh_2.55.0_linux_x86_64.tar.gz$`, clients.MockResponse{Stdout: "bbbb  /tmp/tmp.release/gh_2.55.0_linux_x86_64.tar.gz\n"}).
+			OnMatch(`^sha256sum /usr/local/bin/gh$`, clients.MockResponse{Stdout: "cccc  /usr/local/bin/gh\n"}).
+			OnMatch(`^find `, clients.MockResponse{Stdout: "/tmp/tmp.release/extracted/gh_2.55.0_linux_x86_64/bin/gh\n"})
+
+		model := testReleaseBinaryModel()
+		model.Name = types.StringValue("gh")
+		model.Version = types.StringValue("2.55.0")
+		model.URL = types.StringValue("https://github.com/cli/cli/releases/download/v{version}/gh_{version}_linux_{arch}.tar.gz")
+		model.ChecksumURL = types.StringValue("https://github.com/cli/cli/releases/download/v{version}/gh_{version}_checksums.txt")
+		model.Architectures = types.MapValueMust(types.StringType, map[string]attr.Value{"amd64": types.StringValue("x86_64")})
+		model.Destination = types.StringValue("/usr/local/bin/gh")
+
+		// Act
+		state, diags := testResourceCreate(t, newReleaseBinaryResource(newTestProviderWithArchitecture(mock, "amd64")), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !testReleaseBinaryCommandRan(mock, "tar -xzf /tmp/tmp.release/gh_2.55.0_linux_x86_64.tar.gz -C /tmp/tmp.release/extracted") {
+			t.Fatalf("expected the archive to be extracted, got: %v", mock.Commands)
+		}
+
+		if !testReleaseBinaryCommandRan(mock, "install -m 0755 /tmp/tmp.release/extracted/gh_2.55.0_linux_x86_64/bin/gh /usr/local/bin/gh") {
+			t.Fatalf("expected the binary of the archive to be installed, got: %v", mock.Commands)
+		}
+
+		if state.Architecture.ValueString() != "x86_64" {
+			t.Fatalf("unexpected architecture: %s", state.Architecture.ValueString())
+		}
+	})
+
+	t.Run("create does not install a release with another checksum", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("mktemp -d", clients.MockResponse{Stdout: "/tmp/tmp.release\n"}).
+			OnMatch(`^sha256sum `, clients.MockResponse{Stdout: "bbbb  /tmp/tmp.release/kubectl\n"})
+
+		model := testReleaseBinaryModel()
+		model.SHA256 = types.StringValue("aaaa")
+		model.ChecksumURL = types.StringNull()
+
+		// Act
+		_, diags := testResourceCreate(t, newReleaseBinaryResource(newTestProviderWithArchitecture(mock, "amd64")), model)
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "checksum mismatch") {
+			t.Fatalf("expected a checksum mismatch, got: %v", diags)
+		}
+
+		if testReleaseBinaryCommandRan(mock, "install -m") || !testReleaseBinaryCommandRan(mock, "rm -rf /tmp/tmp.release") {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("plan defaults the destination and requires a checksum", func(t *testing.T) {
+		// Arrange
+		plan := testReleaseBinaryModel()
+		plan.Destination = types.StringUnknown()
+		plan.Architecture = types.StringNull()
+		plan.BinarySHA256 = types.StringNull()
+
+		withoutChecksum := plan
+		withoutChecksum.ChecksumURL = types.StringNull()
+
+		// Act
+		planned, diags := testResourceModifyPlan(t, newReleaseBinaryResource(newTestProvider(clients.NewMockMachineAccessClient())).(*releaseBinaryResource), nil, plan)
+		_, withoutChecksumDiags := testResourceModifyPlan(t, newReleaseBinaryResource(newTestProvider(clients.NewMockMachineAccessClient())).(*releaseBinaryResource), nil, withoutChecksum)
+
+		// Assert
+		if diags.HasError() || planned.Destination.ValueString() != "/usr/local/bin/kubectl" {
+			t.Fatalf("unexpected plan: %s, %v", planned.Destination.ValueString(), diags)
+		}
+
+		if !withoutChecksumDiags.HasError() {
+			t.Fatal("expected a missing checksum")
+		}
+	})
+
+	t.Run("read removes the resource when the binary was changed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^sha256sum `, clients.MockResponse{Stdout: "cccc  /usr/local/bin/kubectl\n"})
+
+		state := testReleaseBinaryModel()
+		state.Architecture = types.StringValue("amd64")
+		state.BinarySHA256 = types.StringValue("aaaa")
+
+		// Act
+		_, removed, diags := testResourceRead(t, newReleaseBinaryResource(newTestProvider(mock)), state)
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed, got: %v", diags)
+		}
+	})
+
+	t.Run("read keeps the resource when the checksum cannot be computed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch(`^sha256sum `, clients.MockResponse{Err: errors.New("connection reset by peer")})
+
+		state := testReleaseBinaryModel()
+		state.Architecture = types.StringValue("amd64")
+		state.BinarySHA256 = types.StringValue("aaaa")
+
+		// Act
+		_, removed, diags := testResourceRead(t, newReleaseBinaryResource(newTestProvider(mock)), state)
+
+		// Assert
+		if !diags.HasError() || removed {
+			t.Fatalf("expected an error keeping the resource, got: %v, %v", diags, removed)
+		}
+	})
+}
+
+func testReleaseBinaryModel() releaseBinaryResourceModel {
+	return releaseBinaryResourceModel{
+		Name:          types.StringValue("kubectl"),
+		Version:       types.StringValue("1.30.4"),
+		URL:           types.StringValue("https://dl.k8s.io/release/v{version}/bin/linux/{arch}/kubectl"),
+		SHA256:        types.StringNull(),
+		ChecksumURL:   types.StringValue("https://dl.k8s.io/release/v{version}/bin/linux/{arch}/kubectl.sha256"),
+		Architectures: types.MapNull(types.StringType),
+		ArchivePath:   types.StringNull(),
+		Destination:   types.StringValue("/usr/local/bin/kubectl"),
+		Architecture:  types.StringUnknown(),
+		BinarySHA256:  types.StringUnknown(),
+	}
+}
+
+func testReleaseBinaryCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
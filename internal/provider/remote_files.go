@@ -0,0 +1,291 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+)
+
+// connectingUser returns the uid and gid of the user the provider connects as.
+func connectingUser(ctx context.Context, client clients.MachineAccessClient) (string, string, error) {
+	out, err := client.RunCommand(clients.WithoutMutation(ctx), "id -u && id -g")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get the connecting user. Err=%w\nout = %s", err, out)
+	}
+
+	ids := strings.Fields(out)
+	if len(ids) != 2 {
+		return "", "", fmt.Errorf("unexpected output of id: %s", out)
+	}
+
+	return ids[0], ids[1], nil
+}
+
+// ownerOrConnectingUser returns the uid and gid of the owner of the file, or of the connecting user when the file does
+// not exist yet, for files that are rewritten without an explicit owner.
+func ownerOrConnectingUser(ctx context.Context, client clients.MachineAccessClient, filePath string) (string, string, error) {
+	info, err := client.Stat(ctx, filePath, true)
+	if clients.IsFileNotFound(err) {
+		return connectingUser(ctx, client)
+	}
+
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+
+	return strconv.FormatInt(info.Owner, 10), strconv.FormatInt(info.Group, 10), nil
+}
+
+// lookupID returns the numeric id of a user or group of the database, "passwd" or "group", given either as a name or as
+// a numeric id. Names are looked up on the remote host, so that the users of LDAP or sssd are found too.
+func lookupID(ctx context.Context, client clients.MachineAccessClient, database string, name string) (string, error) {
+	if _, err := strconv.ParseInt(name, 10, 64); err == nil {
+		return name, nil
+	}
+
+	result, err := client.Run(clients.WithoutMutation(ctx), clients.ShellCommand("getent", database, name))
+
+	// getent exits with 2 when the name is not in the database
+	if clients.IsExitCode(err, 2) {
+		if database == "passwd" {
+			return "", fmt.Errorf("the user %s does not exist", name)
+		}
+
+		return "", fmt.Errorf("the group %s does not exist", name)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s in %s. Err=%w\nout = %s", name, database, err, result.Stdout+result.Stderr)
+	}
+
+	fields := strings.Split(strings.TrimSpace(result.Stdout), ":")
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected output of getent: %s", result.Stdout)
+	}
+
+	return fields[2], nil
+}
+
+// lookupOwnership returns the numeric ids of the owner and group, each given either as a name or as a numeric id. They
+// are returned unchanged on Windows, which has no numeric ids.
+func (p *internalProvider) lookupOwnership(ctx context.Context, owner types.String, group types.String) (string, string, error) {
+	if p.osFamily == clients.OSFamilyWindows {
+		return owner.ValueString(), group.ValueString(), nil
+	}
+
+	uid, err := lookupID(ctx, p.machineAccessClient, "passwd", owner.ValueString())
+	if err != nil {
+		return "", "", err
+	}
+
+	gid, err := lookupID(ctx, p.machineAccessClient, "group", group.ValueString())
+	if err != nil {
+		return "", "", err
+	}
+
+	return uid, gid, nil
+}
+
+// ownershipValue returns the configured owner or group when it designates the actual id, so that a name does not show
+// as a change once resolved, and the actual id otherwise.
+func ownershipValue(ctx context.Context, client clients.MachineAccessClient, database string, configured types.String, actual int64) types.String {
+	if !configured.IsNull() && !configured.IsUnknown() {
+		id, err := lookupID(ctx, client, database, configured.ValueString())
+		if err == nil && id == strconv.FormatInt(actual, 10) {
+			return configured
+		}
+	}
+
+	return types.StringValue(strconv.FormatInt(actual, 10))
+}
+
+// modeValue returns the configured mode when it designates the actual mode, so that e.g. '0755' does not show as a
+// change from the '755' printed by stat, and the actual mode otherwise.
+func modeValue(configured types.String, actual string) types.String {
+	if !configured.IsNull() && !configured.IsUnknown() {
+		configuredMode, err := strconv.ParseUint(configured.ValueString(), 8, 32)
+		actualMode, actualErr := strconv.ParseUint(actual, 8, 32)
+
+		if err == nil && actualErr == nil && configuredMode == actualMode {
+			return configured
+		}
+	}
+
+	return types.StringValue(actual)
+}
+
+// ownershipStateUpgrader upgrades the states written when the owner and group attributes were numbers, before they
+// also accepted names, to strings.
+func ownershipStateUpgrader() resource.StateUpgrader {
+	return resource.StateUpgrader{
+		StateUpgrader: func(_ context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+			decoder := json.NewDecoder(bytes.NewReader(req.RawState.JSON))
+			decoder.UseNumber()
+
+			var state map[string]any
+			if err := decoder.Decode(&state); err != nil {
+				resp.Diagnostics.AddError("Failed to decode the prior state", err.Error())
+				return
+			}
+
+			for _, name := range []string{"owner", "group"} {
+				if id, ok := state[name].(json.Number); ok {
+					state[name] = id.String()
+				}
+			}
+
+			upgraded, err := json.Marshal(state)
+			if err != nil {
+				resp.Diagnostics.AddError("Failed to encode the upgraded state", err.Error())
+				return
+			}
+
+			resp.DynamicValue = &tfprotov6.DynamicValue{JSON: upgraded}
+		},
+	}
+}
+
+// readLines returns the lines of the remote file, or no lines when it does not exist.
+func readLines(ctx context.Context, client clients.MachineAccessClient, filePath string) ([]string, error) {
+	content, err := client.ReadFile(ctx, filePath, true)
+	if clients.IsFileNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	content = strings.TrimRight(content, "\n")
+	if content == "" {
+		return nil, nil
+	}
+
+	return strings.Split(content, "\n"), nil
+}
+
+// joinLines returns the content of a file made of the lines.
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// findMarkedBlock returns the indexes of the begin and end marker lines of the first block delimited by them. Spaces
+// around the markers are ignored.
+func findMarkedBlock(lines []string, begin string, end string) (int, int, bool) {
+	for i, line := range lines {
+		if strings.TrimSpace(line) != begin {
+			continue
+		}
+
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == end {
+				return i, j, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// setMarkedBlock returns the lines with the block delimited by its first and last lines replaced, or inserted when
+// missing, at the beginning of the lines when prepend is set and at the end otherwise.
+func setMarkedBlock(lines []string, block []string, prepend bool) ([]string, bool) {
+	start, stop, found := findMarkedBlock(lines, block[0], block[len(block)-1])
+	if !found {
+		if prepend {
+			return append(slices.Clone(block), lines...), true
+		}
+
+		return append(slices.Clone(lines), block...), true
+	}
+
+	if slices.Equal(lines[start:stop+1], block) {
+		return lines, false
+	}
+
+	return slices.Concat(lines[:start], block, lines[stop+1:]), true
+}
+
+// removeMarkedBlock returns the lines without the block delimited by the markers.
+func removeMarkedBlock(lines []string, begin string, end string) ([]string, bool) {
+	start, stop, found := findMarkedBlock(lines, begin, end)
+	if !found {
+		return lines, false
+	}
+
+	return slices.Concat(lines[:start], lines[stop+1:]), true
+}
+
+// editSystemFile rewrites a file owned by root with the result of the edit, creating it with the mode when missing.
+// The file is only written when the edit reports a change.
+func (p *internalProvider) editSystemFile(ctx context.Context, filePath string, mode string, edit func(lines []string) ([]string, bool)) error {
+	defer p.lockFile(ctx, filePath)()
+
+	lines, err := readLines(ctx, p.machineAccessClient, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	newLines, changed := edit(lines)
+	if !changed {
+		return nil
+	}
+
+	return p.machineAccessClient.WriteFile(ctx, filePath, mode, "0", "0", joinLines(newLines))
+}
+
+// editFile rewrites the lines of the remote file with edit, keeping its mode and ownership, while holding the lock of
+// the file. The file is only written when edit reports a change. A missing file is edited as an empty file when create
+// is set, and is then created with mode 644 for the connecting user, otherwise it is an error.
+func (p *internalProvider) editFile(ctx context.Context, filePath string, create bool, edit func(lines []string) ([]string, bool)) error {
+	defer p.lockFile(ctx, filePath)()
+
+	var mode, owner, group string
+
+	info, err := p.machineAccessClient.Stat(ctx, filePath, true)
+	switch {
+	case clients.IsFileNotFound(err) && !create:
+		return fmt.Errorf("the file %s does not exist", filePath)
+	case clients.IsFileNotFound(err):
+		mode = "644"
+
+		owner, group, err = connectingUser(ctx, p.machineAccessClient)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	default:
+		mode = info.Mode
+		owner = strconv.FormatInt(info.Owner, 10)
+		group = strconv.FormatInt(info.Group, 10)
+	}
+
+	lines, err := readLines(ctx, p.machineAccessClient, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	newLines, changed := edit(lines)
+	if !changed {
+		return nil
+	}
+
+	return p.machineAccessClient.WriteFile(ctx, filePath, mode, owner, group, joinLines(newLines))
+}
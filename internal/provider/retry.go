@@ -0,0 +1,110 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryDelay    = 5 * time.Second
+)
+
+// retryModel is the model of the retry block of the resources whose remote operations may fail transiently.
+type retryModel struct {
+	Attempts      types.Int64  `tfsdk:"attempts"`
+	Delay         types.String `tfsdk:"delay"`
+	OnOutputRegex types.String `tfsdk:"on_output_regex"`
+}
+
+// retryBlock returns the schema of the retry block.
+func retryBlock() schema.Block {
+	return schema.SingleNestedBlock{
+		Description: "Retries of the remote operations that fail, e.g. on a network error reaching a mirror, instead of failing the apply. " +
+			"The operations are not retried without this block",
+		Attributes: map[string]schema.Attribute{
+			"attempts": schema.Int64Attribute{
+				Optional:    true,
+				Description: fmt.Sprintf("The maximum number of attempts, including the first one. Defaults to %d", defaultRetryAttempts),
+				Validators:  []validator.Int64{int64Between(1, 100)},
+			},
+			"delay": schema.StringAttribute{
+				Optional:    true,
+				Description: fmt.Sprintf("The duration to wait between two attempts, e.g. '30s'. Defaults to '%s'", defaultRetryDelay),
+				Validators:  []validator.String{duration()},
+			},
+			"on_output_regex": schema.StringAttribute{
+				Optional: true,
+				Description: "A regular expression, e.g. 'Temporary failure resolving|Could not connect', that the output or the error " +
+					"of a failed attempt must match for it to be retried. By default, all the failures are retried",
+				Validators: []validator.String{regularExpression()},
+			},
+		},
+	}
+}
+
+// retry runs the operation until it succeeds or the attempts of the policy are exhausted, waiting for the delay of the
+// policy between two attempts, and returns the output and the error of the last attempt. The operation runs once when
+// the policy is nil, or when its error does not match on_output_regex.
+func retry(ctx context.Context, policy *retryModel, operation func() (string, error)) (string, error) {
+	out, err := operation()
+	if err == nil || policy == nil {
+		return out, err
+	}
+
+	attempts := int64(defaultRetryAttempts)
+	if !policy.Attempts.IsNull() && !policy.Attempts.IsUnknown() {
+		attempts = policy.Attempts.ValueInt64()
+	}
+
+	delay := defaultRetryDelay
+	if !policy.Delay.IsNull() && !policy.Delay.IsUnknown() {
+		parsed, parseErr := time.ParseDuration(policy.Delay.ValueString())
+		if parseErr != nil {
+			return out, fmt.Errorf("failed to parse the retry delay '%s': %w", policy.Delay.ValueString(), parseErr)
+		}
+
+		delay = parsed
+	}
+
+	var pattern *regexp.Regexp
+	if !policy.OnOutputRegex.IsNull() && !policy.OnOutputRegex.IsUnknown() {
+		compiled, parseErr := regexp.Compile(policy.OnOutputRegex.ValueString())
+		if parseErr != nil {
+			return out, fmt.Errorf("failed to parse on_output_regex: %w", parseErr)
+		}
+
+		pattern = compiled
+	}
+
+	for attempt := int64(2); attempt <= attempts; attempt++ {
+		if pattern != nil && !pattern.MatchString(out) && !pattern.MatchString(err.Error()) {
+			return out, err
+		}
+
+		tflog.Warn(ctx, fmt.Sprintf("Attempt %d of %d failed, retrying in %s: %s", attempt-1, attempts, delay, err))
+
+		select {
+		case <-ctx.Done():
+			return out, err
+		case <-time.After(delay):
+		}
+
+		out, err = operation()
+		if err == nil {
+			return out, nil
+		}
+	}
+
+	return out, err
+}
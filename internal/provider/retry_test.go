@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRetry(t *testing.T) {
+	failing := func(failures int, out string) (func() (string, error), *int) {
+		attempts := 0
+
+		return func() (string, error) {
+			attempts++
+			if attempts <= failures {
+				return out, errors.New("exit status 100")
+			}
+
+			return "done", nil
+		}, &attempts
+	}
+
+	t.Run("runs the operation once without a policy", func(t *testing.T) {
+		// Arrange
+		operation, attempts := failing(1, "")
+
+		// Act
+		_, err := retry(context.Background(), nil, operation)
+
+		// Assert
+		if err == nil || *attempts != 1 {
+			t.Fatalf("unexpected result: %v after %d attempts", err, *attempts)
+		}
+	})
+
+	t.Run("retries until the operation succeeds", func(t *testing.T) {
+		// Arrange
+		operation, attempts := failing(2, "")
+
+		// Act
+		out, err := retry(context.Background(), &retryModel{Attempts: types.Int64Value(3), Delay: types.StringValue("1ms"), OnOutputRegex: types.StringNull()}, operation)
+
+		// Assert
+		if err != nil || out != "done" || *attempts != 3 {
+			t.Fatalf("unexpected result: %v, %s after %d attempts", err, out, *attempts)
+		}
+	})
+
+	t.Run("gives up after the attempts", func(t *testing.T) {
+		// Arrange
+		operation, attempts := failing(5, "")
+
+		// Act
+		_, err := retry(context.Background(), &retryModel{Attempts: types.Int64Value(2), Delay: types.StringValue("1ms"), OnOutputRegex: types.StringNull()}, operation)
+
+		// Assert
+		if err == nil || *attempts != 2 {
+			t.Fatalf("unexpected result: %v after %d attempts", err, *attempts)
+		}
+	})
+
+	t.Run("only retries the failures matching on_output_regex", func(t *testing.T) {
+		// Arrange
+		operation, attempts := failing(1, "E: Unable to locate package nginx")
+
+		// Act
+		_, err := retry(context.Background(), &retryModel{Attempts: types.Int64Value(3), Delay: types.StringValue("1ms"), OnOutputRegex: types.StringValue("Temporary failure")}, operation)
+
+		// Assert
+		if err == nil || *attempts != 1 {
+			t.Fatalf("unexpected result: %v after %d attempts", err, *attempts)
+		}
+	})
+}
@@ -0,0 +1,431 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &rsyslogForwardingResource{}
+var _ resource.ResourceWithModifyPlan = &rsyslogForwardingResource{}
+
+const rsyslogDirectory = "/etc/rsyslog.d"
+
+var (
+	rsyslogTargetPattern   = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+	rsyslogSelectorPattern = regexp.MustCompile(`^[^"\n]+$`)
+)
+
+func newRsyslogForwardingResource(p *internalProvider) resource.Resource {
+	return &rsyslogForwardingResource{
+		provider: p,
+	}
+}
+
+// rsyslogForwardingResource defines the resource implementation.
+type rsyslogForwardingResource struct {
+	provider *internalProvider
+}
+
+type rsyslogForwardingResourceModel struct {
+	Name        types.String `tfsdk:"name"`
+	Target      types.String `tfsdk:"target"`
+	Port        types.Int64  `tfsdk:"port"`
+	Protocol    types.String `tfsdk:"protocol"`
+	Selector    types.String `tfsdk:"selector"`
+	TLSCAFile   types.String `tfsdk:"tls_ca_file"`
+	TLSAuthMode types.String `tfsdk:"tls_auth_mode"`
+	Path        types.String `tfsdk:"path"`
+	Content     types.String `tfsdk:"content"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *rsyslogForwardingResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_rsyslog_forwarding"
+}
+
+func (r *rsyslogForwardingResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Rsyslog forwarding resource that sends the logs of the machine to a remote collector, with a " +
+			"file of " + rsyslogDirectory + ". The whole configuration of rsyslog is checked with rsyslogd -N1 after " +
+			"the file is written, the previous file is restored when it is refused, and rsyslog is restarted when the " +
+			"file changed. The logs are queued in memory while the collector is unreachable, and sent once it is back. " +
+			"Destroying the resource removes the file",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the file in " + rsyslogDirectory + ", without the .conf extension, e.g. '90-forward'. The files are read in the order of their names",
+				Validators:  []validator.String{fileName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"target": schema.StringAttribute{
+				Required:    true,
+				Description: "The host name or IP address of the collector",
+				Validators:  []validator.String{rsyslogTarget()},
+			},
+			"port": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The port of the collector. Defaults to 6514 with TLS and to 514 otherwise",
+				Validators:  []validator.Int64{port()},
+			},
+			"protocol": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("tcp"),
+				Description: "The protocol the logs are sent with, 'tcp' or 'udp'. Defaults to 'tcp'",
+				Validators:  []validator.String{oneOf("tcp", "udp")},
+			},
+			"selector": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("*.*"),
+				Description: "The facilities and priorities of the forwarded logs, see rsyslog.conf(5), e.g. 'auth,authpriv.*'. Defaults to '*.*'",
+				Validators:  []validator.String{rsyslogSelector()},
+			},
+			"tls_ca_file": schema.StringAttribute{
+				Optional: true,
+				Description: "The path of the CA certificate the certificate of the collector is verified with. When set, the logs are " +
+					"sent over TLS, which needs the protocol tcp, the rsyslog-gnutls package and rsyslog 8.2108 or later",
+				Validators: []validator.String{absolutePath()},
+			},
+			"tls_auth_mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("x509/name"),
+				Description: "How the collector is authenticated with TLS: 'x509/name' checks that its certificate is valid for the target, 'x509/certvalid' only checks the certificate, and 'anon' checks nothing. Defaults to 'x509/name'",
+				Validators:  []validator.String{oneOf("x509/name", "x509/certvalid", "anon")},
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the rsyslog file",
+			},
+			"content": schema.StringAttribute{
+				Computed:    true,
+				Description: "The content of the rsyslog file. A change made outside of terraform is planned as an update",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *rsyslogForwardingResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+// ModifyPlan renders the configuration, so that a file changed outside of terraform is planned as a change of content.
+func (r *rsyslogForwardingResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() {
+		return
+	}
+
+	var plan rsyslogForwardingResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, err := plan.render()
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("tls_ca_file"), "Invalid rsyslog forwarding", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("path"), plan.path())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), content)...)
+}
+
+func (r *rsyslogForwardingResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan rsyslogForwardingResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *rsyslogForwardingResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model rsyslogForwardingResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, model.path(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be written again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read rsyslog file", err.Error())
+		return
+	}
+
+	model.Content = types.StringValue(content)
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *rsyslogForwardingResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan rsyslogForwardingResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *rsyslogForwardingResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model rsyslogForwardingResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	defer r.provider.lock(ctx, rsyslogLock)()
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", model.path())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove rsyslog file", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	// try-restart leaves a stopped rsyslog as it is
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl try-restart rsyslog"))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to restart rsyslog", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// apply renders the configuration of the plan, sets its computed attributes, and writes it. The whole configuration of
+// rsyslog is checked, as the files of rsyslog.d depend on each other, e.g. two actions can not share a queue file.
+func (r *rsyslogForwardingResource) apply(ctx context.Context, plan *rsyslogForwardingResourceModel, diags *diag.Diagnostics) {
+	content, err := plan.render()
+	if err != nil {
+		diags.AddError("Invalid rsyslog forwarding", err.Error())
+		return
+	}
+
+	plan.Path = types.StringValue(plan.path())
+	plan.Content = types.StringValue(content)
+
+	// The other forwardings of the host change the configuration checked by rsyslogd -N1
+	defer r.provider.lock(ctx, rsyslogLock)()
+
+	previous, err := r.provider.machineAccessClient.ReadFile(ctx, plan.path(), true)
+	found := err == nil
+
+	if err != nil && !clients.IsFileNotFound(err) {
+		diags.AddError("Failed to read rsyslog file", err.Error())
+		return
+	}
+
+	if found && previous == content {
+		return
+	}
+
+	if err := r.provider.machineAccessClient.WriteFile(ctx, plan.path(), "644", "0", "0", content); err != nil {
+		diags.AddError("Failed to write rsyslog file", err.Error())
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("rsyslogd -N1"))
+	if err != nil {
+		if restoreErr := r.restore(ctx, plan.path(), previous, found); restoreErr != nil {
+			diags.AddError("Failed to restore rsyslog file", restoreErr.Error())
+		}
+
+		diags.AddError("Invalid rsyslog configuration", fmt.Sprintf("rsyslogd -N1 refused the configuration, the previous file is restored. Err=%s\nout = %s", err, out))
+
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl restart rsyslog"))
+	if err != nil {
+		diags.AddError("Failed to restart rsyslog", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// restore writes back the previous content of the file, or removes the file when it did not exist.
+func (r *rsyslogForwardingResource) restore(ctx context.Context, filePath string, previous string, found bool) error {
+	if found {
+		return r.provider.machineAccessClient.WriteFile(ctx, filePath, "644", "0", "0", previous)
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", filePath)))
+	if err != nil {
+		return fmt.Errorf("failed to remove %s. Err=%w\nout = %s", filePath, err, out)
+	}
+
+	return nil
+}
+
+func (model rsyslogForwardingResourceModel) path() string {
+	return rsyslogDirectory + "/" + model.Name.ValueString() + ".conf"
+}
+
+// render returns the RainerScript content of the rsyslog file, an omfwd action retrying forever with a queue of its
+// own, so that a collector which is down does not block the local logs.
+func (model rsyslogForwardingResourceModel) render() (string, error) {
+	tls := !model.TLSCAFile.IsNull()
+
+	if tls && model.Protocol.ValueString() != "tcp" {
+		return "", fmt.Errorf("the logs can only be sent over TLS with the protocol tcp")
+	}
+
+	port := int64(514)
+	if tls {
+		port = 6514
+	}
+
+	if !model.Port.IsNull() {
+		port = model.Port.ValueInt64()
+	}
+
+	parameters := []string{
+		`type="omfwd"`,
+		`target="` + model.Target.ValueString() + `"`,
+		`port="` + strconv.FormatInt(port, 10) + `"`,
+		`protocol="` + model.Protocol.ValueString() + `"`,
+	}
+
+	if tls {
+		parameters = append(parameters,
+			`StreamDriver="gtls"`,
+			`StreamDriverMode="1"`,
+			`StreamDriverAuthMode="`+model.TLSAuthMode.ValueString()+`"`,
+			`StreamDriver.CAFile="`+model.TLSCAFile.ValueString()+`"`,
+		)
+
+		if model.TLSAuthMode.ValueString() == "x509/name" {
+			parameters = append(parameters, `StreamDriverPermittedPeers="`+model.Target.ValueString()+`"`)
+		}
+	}
+
+	parameters = append(parameters,
+		`queue.type="LinkedList"`,
+		`queue.size="100000"`,
+		`action.resumeRetryCount="-1"`,
+	)
+
+	lines := []string{
+		"# Forwards the logs to " + model.Target.ValueString(),
+		model.Selector.ValueString() + " action(" + parameters[0],
+	}
+
+	for _, parameter := range parameters[1:] {
+		lines = append(lines, "    "+parameter)
+	}
+
+	lines[len(lines)-1] += ")"
+
+	return joinLines(lines), nil
+}
+
+// rsyslogTarget validates the host names and IP addresses of the collectors.
+func rsyslogTarget() validator.String {
+	return stringValidator{
+		description: "value must be a host name or an IP address",
+		check: func(value string) string {
+			if !rsyslogTargetPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a host name or an IP address", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// rsyslogSelector validates the selectors of rsyslog, a single line without quotes.
+func rsyslogSelector() validator.String {
+	return stringValidator{
+		description: "value must be a selector of rsyslog, e.g. 'auth,authpriv.*'",
+		check: func(value string) string {
+			if !rsyslogSelectorPattern.MatchString(value) || strings.TrimSpace(value) == "" {
+				return fmt.Sprintf("'%s' is not a selector of rsyslog, e.g. 'auth,authpriv.*'", value)
+			}
+
+			return ""
+		},
+	}
+}
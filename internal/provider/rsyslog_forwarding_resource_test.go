@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRsyslogForwardingResourceWithMock(t *testing.T) {
+	t.Run("create writes the forwarding, checks the configuration and restarts rsyslog", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		model := testRsyslogForwardingModel()
+		model.TLSCAFile = types.StringValue("/etc/ssl/certs/collector-ca.pem")
+
+		// Act
+		state, diags := testResourceCreate(t, newRsyslogForwardingResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := `# Forwards the logs to logs.example.com
+*.* action(type="omfwd"
+    target="logs.example.com"
+    port="6514"
+    protocol="tcp"
+    StreamDriver="gtls"
+    StreamDriverMode="1"
+    StreamDriverAuthMode="x509/name"
+    StreamDriver.CAFile="/etc/ssl/certs/collector-ca.pem"
+    StreamDriverPermittedPeers="logs.example.com"
+    queue.type="LinkedList"
+    queue.size="100000"
+    action.resumeRetryCount="-1")
+`
+		if mock.Files["/etc/rsyslog.d/90-forward.conf"] != expected || state.Content.ValueString() != expected {
+			t.Fatalf("unexpected content:\n%s", mock.Files["/etc/rsyslog.d/90-forward.conf"])
+		}
+
+		if strings.Join(mock.Commands, "\n") != "rsyslogd -N1\nsystemctl restart rsyslog" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create does not restart rsyslog when the file is unchanged", func(t *testing.T) {
+		// Arrange
+		content, err := testRsyslogForwardingModel().render()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/rsyslog.d/90-forward.conf", content, clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newRsyslogForwardingResource(newTestProvider(mock)), testRsyslogForwardingModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("update restores the previous file when rsyslogd refuses the configuration", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/rsyslog.d/90-forward.conf", "previous\n", clients.FileInfo{Mode: "644"}).
+			On("rsyslogd -N1", clients.MockResponse{Stderr: "rsyslogd: error during parsing file /etc/rsyslog.d/90-forward.conf", ExitCode: 1})
+
+		// Act
+		_, diags := testResourceUpdate(t, newRsyslogForwardingResource(newTestProvider(mock)), testRsyslogForwardingModel(), testRsyslogForwardingModel())
+
+		// Assert
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "error during parsing") {
+			t.Fatalf("expected the error of rsyslogd, got: %v", diags)
+		}
+
+		if mock.Files["/etc/rsyslog.d/90-forward.conf"] != "previous\n" || testRsyslogCommandRan(mock, "systemctl restart") {
+			t.Fatalf("expected the previous file without a restart, got: %q, %v", mock.Files["/etc/rsyslog.d/90-forward.conf"], mock.Commands)
+		}
+	})
+
+	t.Run("TLS needs the protocol tcp", func(t *testing.T) {
+		// Arrange
+		model := testRsyslogForwardingModel()
+		model.Protocol = types.StringValue("udp")
+		model.TLSCAFile = types.StringValue("/etc/ssl/certs/collector-ca.pem")
+
+		// Act
+		_, err := model.render()
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func testRsyslogForwardingModel() rsyslogForwardingResourceModel {
+	return rsyslogForwardingResourceModel{
+		Name:        types.StringValue("90-forward"),
+		Target:      types.StringValue("logs.example.com"),
+		Port:        types.Int64Null(),
+		Protocol:    types.StringValue("tcp"),
+		Selector:    types.StringValue("*.*"),
+		TLSCAFile:   types.StringNull(),
+		TLSAuthMode: types.StringValue("x509/name"),
+		Path:        types.StringUnknown(),
+		Content:     types.StringUnknown(),
+	}
+}
+
+func testRsyslogCommandRan(mock *clients.MockMachineAccessClient, fragment string) bool {
+	for _, command := range mock.Commands {
+		if strings.Contains(command, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
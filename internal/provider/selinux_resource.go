@@ -0,0 +1,474 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &selinuxResource{}
+
+// selinuxConfigPath is the file setting the SELinux mode applied at boot.
+const selinuxConfigPath = "/etc/selinux/config"
+
+func newSELinuxResource(p *internalProvider) resource.Resource {
+	return &selinuxResource{
+		provider: p,
+	}
+}
+
+// selinuxResource defines the resource implementation.
+type selinuxResource struct {
+	provider *internalProvider
+}
+
+type selinuxResourceModel struct {
+	Mode           types.String `tfsdk:"mode"`
+	Booleans       types.Map    `tfsdk:"booleans"`
+	FileContexts   types.Map    `tfsdk:"file_contexts"`
+	RebootRequired types.Bool   `tfsdk:"reboot_required"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *selinuxResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_selinux"
+}
+
+func (r *selinuxResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "SELinux resource that sets the SELinux mode in " + selinuxConfigPath + " and on the running " +
+			"system, persistent booleans with setsebool -P and file contexts with semanage fcontext, relabeling the " +
+			"matching files with restorecon. Only the configured booleans and file contexts are managed. Destroying " +
+			"the resource removes its file contexts, while the mode and the booleans are left as they are. Enabling or " +
+			"disabling SELinux applies after a reboot, see reboot_required",
+
+		Attributes: map[string]schema.Attribute{
+			"mode": schema.StringAttribute{
+				Optional:    true,
+				Description: "The SELinux mode, one of 'enforcing', 'permissive' or 'disabled'. The mode is left as it is when unset",
+				Validators:  []validator.String{oneOf("enforcing", "permissive", "disabled")},
+			},
+			"booleans": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.BoolType,
+				Description: "The SELinux booleans by name, e.g. { httpd_can_network_connect = true }",
+			},
+			"file_contexts": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The SELinux types of the files by path regular expression, as semanage fcontext expects it, e.g. { \"/srv/www(/.*)?\" = \"httpd_sys_content_t\" }",
+			},
+			"reboot_required": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the running mode differs from the configured one, which happens when SELinux is enabled or disabled, so that the host must be rebooted for the mode to apply",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *selinuxResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *selinuxResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan selinuxResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	r.apply(ctx, &plan, nil, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *selinuxResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state selinuxResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !state.Mode.IsNull() {
+		lines, err := readLines(ctx, r.provider.machineAccessClient, selinuxConfigPath)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read SELinux config file", err.Error())
+			return
+		}
+
+		state.Mode = types.StringNull()
+		for _, line := range lines {
+			if mode, ok := parseConfigKV(line, "SELINUX", "="); ok {
+				state.Mode = types.StringValue(mode)
+			}
+		}
+
+		running, err := r.runningMode(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the running SELinux mode", err.Error())
+			return
+		}
+
+		state.RebootRequired = types.BoolValue(!state.Mode.IsNull() && running != state.Mode.ValueString())
+	}
+
+	if !state.Booleans.IsNull() {
+		current, err := r.booleans(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read SELinux booleans", err.Error())
+			return
+		}
+
+		booleans := map[string]bool{}
+
+		// Only the managed booleans are read, the others may be set by other means
+		for name := range state.Booleans.Elements() {
+			if value, ok := current[name]; ok {
+				booleans[name] = value
+			}
+		}
+
+		state.Booleans, diags = types.MapValueFrom(ctx, types.BoolType, booleans)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if !state.FileContexts.IsNull() {
+		current, err := r.fileContexts(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read SELinux file contexts", err.Error())
+			return
+		}
+
+		fileContexts := map[string]string{}
+
+		for spec := range state.FileContexts.Elements() {
+			if value, ok := current[spec]; ok {
+				fileContexts[spec] = value
+			}
+		}
+
+		state.FileContexts, diags = types.MapValueFrom(ctx, types.StringType, fileContexts)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *selinuxResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan, state selinuxResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &state, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *selinuxResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state selinuxResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	fileContexts := map[string]string{}
+
+	diags = state.FileContexts.ElementsAs(ctx, &fileContexts, false)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.setFileContexts(ctx, nil, fileContexts); err != nil {
+		resp.Diagnostics.AddError("Failed to remove SELinux file contexts", err.Error())
+		return
+	}
+}
+
+// apply sets the mode, the booleans that differ from the previous state and the file contexts of the plan, removes
+// the file contexts of the previous state that are not configured anymore, and sets reboot_required.
+func (r *selinuxResource) apply(ctx context.Context, plan *selinuxResourceModel, previous *selinuxResourceModel, diags *diag.Diagnostics) {
+	booleans, previousBooleans := map[string]bool{}, map[string]bool{}
+	fileContexts, previousFileContexts := map[string]string{}, map[string]string{}
+
+	diags.Append(plan.Booleans.ElementsAs(ctx, &booleans, false)...)
+	diags.Append(plan.FileContexts.ElementsAs(ctx, &fileContexts, false)...)
+
+	if previous != nil {
+		diags.Append(previous.Booleans.ElementsAs(ctx, &previousBooleans, false)...)
+		diags.Append(previous.FileContexts.ElementsAs(ctx, &previousFileContexts, false)...)
+	}
+
+	if diags.HasError() {
+		return
+	}
+
+	plan.RebootRequired = types.BoolValue(false)
+
+	if !plan.Mode.IsNull() {
+		rebootRequired, err := r.setMode(ctx, plan.Mode.ValueString())
+		if err != nil {
+			diags.AddError("Failed to set the SELinux mode", err.Error())
+			return
+		}
+
+		plan.RebootRequired = types.BoolValue(rebootRequired)
+	}
+
+	// setsebool -P rebuilds the policy, so that it is only run for the booleans that change
+	var settings []string
+
+	for _, name := range sortedKeys(booleans) {
+		if previous, ok := previousBooleans[name]; ok && previous == booleans[name] {
+			continue
+		}
+
+		value := "off"
+		if booleans[name] {
+			value = "on"
+		}
+
+		settings = append(settings, name+"="+value)
+	}
+
+	if len(settings) > 0 {
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("setsebool", append([]string{"-P"}, settings...)...)))
+		if err != nil {
+			diags.AddError("Failed to set SELinux booleans", fmt.Sprintf("Err=%s\nout = %s", err, out))
+			return
+		}
+	}
+
+	if err := r.setFileContexts(ctx, fileContexts, previousFileContexts); err != nil {
+		diags.AddError("Failed to set SELinux file contexts", err.Error())
+		return
+	}
+}
+
+// setMode writes the mode to the config file and applies it to the running system when SELinux is enabled. It returns
+// whether a reboot is required for the mode to apply.
+func (r *selinuxResource) setMode(ctx context.Context, mode string) (bool, error) {
+	err := r.provider.editFile(ctx, selinuxConfigPath, false, func(lines []string) ([]string, bool) {
+		return setConfigKV(lines, "SELINUX", "=", "SELINUX="+mode)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	running, err := r.runningMode(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// setenforce switches between enforcing and permissive, enabling or disabling SELinux requires a reboot
+	if running == mode || running == "disabled" || mode == "disabled" {
+		return running != mode, nil
+	}
+
+	enforce := "0"
+	if mode == "enforcing" {
+		enforce = "1"
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("setenforce "+enforce))
+	if err != nil {
+		return false, fmt.Errorf("failed to switch to %s mode. Err=%w\nout = %s", mode, err, out)
+	}
+
+	return false, nil
+}
+
+// setFileContexts adds or modifies the file contexts that differ from the previous ones, removes the previous ones
+// that are not set anymore, and relabels the files they match.
+func (r *selinuxResource) setFileContexts(ctx context.Context, fileContexts map[string]string, previous map[string]string) error {
+	var relabeled []string
+
+	for _, spec := range sortedKeys(previous) {
+		if _, ok := fileContexts[spec]; ok {
+			continue
+		}
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("semanage", "fcontext", "-d", spec)))
+		if err != nil {
+			return fmt.Errorf("failed to remove the file context of %s. Err=%w\nout = %s", spec, err, out)
+		}
+
+		relabeled = append(relabeled, spec)
+	}
+
+	for _, spec := range sortedKeys(fileContexts) {
+		if previous[spec] == fileContexts[spec] {
+			continue
+		}
+
+		// semanage refuses to add an existing file context, which is then modified
+		add := clients.ShellCommand("semanage", "fcontext", "-a", "-t", fileContexts[spec], spec)
+		modify := clients.ShellCommand("semanage", "fcontext", "-m", "-t", fileContexts[spec], spec)
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", add+" || "+modify)))
+		if err != nil {
+			return fmt.Errorf("failed to set the file context of %s. Err=%w\nout = %s", spec, err, out)
+		}
+
+		relabeled = append(relabeled, spec)
+	}
+
+	for _, spec := range relabeled {
+		root := selinuxSpecRoot(spec)
+
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("[ ! -e "+clients.ShellQuote(root)+" ] || "+clients.ShellCommand("restorecon", "-R", root)))
+		if err != nil {
+			return fmt.Errorf("failed to relabel %s. Err=%w\nout = %s", root, err, out)
+		}
+	}
+
+	return nil
+}
+
+// runningMode returns the mode of the running system, e.g. enforcing.
+func (r *selinuxResource) runningMode(ctx context.Context) (string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "getenforce")
+	if err != nil {
+		return "", fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return strings.ToLower(strings.TrimSpace(out)), nil
+}
+
+// booleans returns the current value of the SELinux booleans by name.
+func (r *selinuxResource) booleans(ctx context.Context) (map[string]bool, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "getsebool -a")
+	if err != nil {
+		return nil, fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	booleans := map[string]bool{}
+
+	// httpd_can_network_connect --> on
+	for line := range strings.SplitSeq(out, "\n") {
+		name, value, ok := strings.Cut(line, " --> ")
+		if ok {
+			booleans[strings.TrimSpace(name)] = strings.TrimSpace(value) == "on"
+		}
+	}
+
+	return booleans, nil
+}
+
+// fileContexts returns the SELinux types of the local file contexts by path regular expression.
+func (r *selinuxResource) fileContexts(ctx context.Context) (map[string]string, error) {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("semanage fcontext -l -C"))
+	if err != nil {
+		return nil, fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return parseSELinuxFileContexts(out), nil
+}
+
+// parseSELinuxFileContexts parses the lines of semanage fcontext -l, e.g.
+// "/srv/www(/.*)?    all files    system_u:object_r:httpd_sys_content_t:s0", into the types by path regular expression.
+func parseSELinuxFileContexts(out string) map[string]string {
+	fileContexts := map[string]string{}
+
+	for line := range strings.SplitSeq(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		label := strings.Split(fields[len(fields)-1], ":")
+		if len(label) < 3 {
+			continue
+		}
+
+		fileContexts[fields[0]] = label[2]
+	}
+
+	return fileContexts
+}
+
+// selinuxSpecRoot returns the directory under which the files matched by the path regular expression are, e.g.
+// /srv/www for "/srv/www(/.*)?".
+func selinuxSpecRoot(spec string) string {
+	if index := strings.IndexAny(spec, `()[]*?+\|^$`); index >= 0 {
+		rest := spec[index:]
+		spec = spec[:index]
+
+		// A partial name is not a path, e.g. /var/log/app for "/var/log/app.*", unlike /srv/www for "/srv/www(/.*)?"
+		if !strings.HasSuffix(spec, "/") && !strings.HasPrefix(rest, "(/") {
+			spec = spec[:strings.LastIndex(spec, "/")+1]
+		}
+	}
+
+	if spec != "/" {
+		spec = strings.TrimSuffix(spec, "/")
+	}
+
+	return spec
+}
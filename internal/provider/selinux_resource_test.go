@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestSELinuxResourceWithMock(t *testing.T) {
+	t.Run("create sets the mode, the booleans and the file contexts", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(selinuxConfigPath, "SELINUX=permissive\nSELINUXTYPE=targeted\n", clients.FileInfo{Mode: "644"}).
+			On("getenforce", clients.MockResponse{Stdout: "Permissive\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newSELinuxResource(newTestProvider(mock)), testSELinuxModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files[selinuxConfigPath] != "SELINUX=enforcing\nSELINUXTYPE=targeted\n" {
+			t.Fatalf("unexpected config: %q", mock.Files[selinuxConfigPath])
+		}
+
+		expected := []string{
+			"getenforce",
+			"setenforce 1",
+			"setsebool -P httpd_can_network_connect=on",
+			`sh -c 'semanage fcontext -a -t httpd_sys_content_t '\''/srv/www(/.*)?'\'' || semanage fcontext -m -t httpd_sys_content_t '\''/srv/www(/.*)?'\'''`,
+			"[ ! -e '/srv/www' ] || restorecon -R /srv/www",
+		}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if state.RebootRequired.ValueBool() {
+			t.Fatal("expected no reboot to be required")
+		}
+	})
+
+	t.Run("create requires a reboot to enable SELinux", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(selinuxConfigPath, "SELINUX=disabled\n", clients.FileInfo{Mode: "644"}).
+			On("getenforce", clients.MockResponse{Stdout: "Disabled\n"})
+
+		plan := testSELinuxModel()
+		plan.Booleans = types.MapNull(types.BoolType)
+		plan.FileContexts = types.MapNull(types.StringType)
+
+		// Act
+		state, diags := testResourceCreate(t, newSELinuxResource(newTestProvider(mock)), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.RebootRequired.ValueBool() || !slices.Equal(mock.Commands, []string{"getenforce"}) {
+			t.Fatalf("expected a reboot to be required without setenforce, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("update only changes what differs", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(selinuxConfigPath, "SELINUX=enforcing\n", clients.FileInfo{Mode: "644"}).
+			On("getenforce", clients.MockResponse{Stdout: "Enforcing\n"})
+
+		plan := testSELinuxModel()
+		plan.Booleans = types.MapValueMust(types.BoolType, map[string]attr.Value{
+			"httpd_can_network_connect": types.BoolValue(true),
+			"httpd_use_nfs":             types.BoolValue(false),
+		})
+		plan.FileContexts = types.MapValueMust(types.StringType, map[string]attr.Value{})
+
+		// Act
+		_, diags := testResourceUpdate(t, newSELinuxResource(newTestProvider(mock)), testSELinuxModel(), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := []string{
+			"getenforce",
+			"setsebool -P httpd_use_nfs=off",
+			`semanage fcontext -d '/srv/www(/.*)?'`,
+			"[ ! -e '/srv/www' ] || restorecon -R /srv/www",
+		}
+		if !slices.Equal(mock.Commands, expected) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read only reads the managed settings", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile(selinuxConfigPath, "SELINUX=permissive\n", clients.FileInfo{Mode: "644"}).
+			On("getenforce", clients.MockResponse{Stdout: "Permissive\n"}).
+			On("getsebool -a", clients.MockResponse{Stdout: "httpd_can_network_connect --> off\nhttpd_use_nfs --> on\n"}).
+			On("semanage fcontext -l -C", clients.MockResponse{Stdout: "/srv/www(/.*)?                                     all files          system_u:object_r:public_content_t:s0\n"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newSELinuxResource(newTestProvider(mock)), testSELinuxModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if removed {
+			t.Fatal("expected the resource to be kept")
+		}
+
+		if state.Mode.ValueString() != "permissive" ||
+			state.Booleans.String() != `{"httpd_can_network_connect":false}` ||
+			state.FileContexts.String() != `{"/srv/www(/.*)?":"public_content_t"}` {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+}
+
+func TestSELinuxSpecRoot(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"/srv/www(/.*)?":     "/srv/www",
+		"/var/log/app.*":     "/var/log",
+		"/opt/app/data":      "/opt/app/data",
+		"/web/[^/]*/uploads": "/web",
+	} {
+		if root := selinuxSpecRoot(spec); root != expected {
+			t.Errorf("expected %s for %s, got %s", expected, spec, root)
+		}
+	}
+}
+
+func testSELinuxModel() selinuxResourceModel {
+	return selinuxResourceModel{
+		Mode:           types.StringValue("enforcing"),
+		Booleans:       types.MapValueMust(types.BoolType, map[string]attr.Value{"httpd_can_network_connect": types.BoolValue(true)}),
+		FileContexts:   types.MapValueMust(types.StringType, map[string]attr.Value{"/srv/www(/.*)?": types.StringValue("httpd_sys_content_t")}),
+		RebootRequired: types.BoolUnknown(),
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &serviceReloadResource{}
+
+func newServiceReloadResource(p *internalProvider) resource.Resource {
+	return &serviceReloadResource{
+		provider: p,
+	}
+}
+
+// serviceReloadResource defines the resource implementation.
+type serviceReloadResource struct {
+	provider *internalProvider
+}
+
+type serviceReloadResourceModel struct {
+	Service  types.String `tfsdk:"service"`
+	Triggers types.Map    `tfsdk:"triggers"`
+	Action   types.String `tfsdk:"action"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *serviceReloadResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_service_reload"
+}
+
+func (r *serviceReloadResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Service reload resource that reloads or restarts a systemd service when it is created, and " +
+			"again whenever triggers change. Put the configuration files of the service in triggers, e.g. " +
+			"`{ config = setup_file.nginx.content, site = setup_file.site.content }`: the service is then reloaded " +
+			"exactly once per apply, however many of its files changed, and after all of them are written. " +
+			"Destroying the resource leaves the service as it is",
+
+		Attributes: map[string]schema.Attribute{
+			"service": schema.StringAttribute{
+				Required:    true,
+				Description: "The systemd service to reload, e.g. 'nginx'",
+				Validators:  []validator.String{notBlank()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Arbitrary values that cause a reload when changed, e.g. the contents of the configuration files of the service",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"action": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("try-reload-or-restart"),
+				Description: "The systemctl command run on the service, one of 'reload', 'restart', 'reload-or-restart', 'try-restart' or 'try-reload-or-restart'. The try- commands leave a stopped service stopped. Defaults to 'try-reload-or-restart'",
+				Validators:  []validator.String{oneOf("reload", "restart", "reload-or-restart", "try-restart", "try-reload-or-restart")},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *serviceReloadResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *serviceReloadResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan serviceReloadResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("systemctl", plan.Action.ValueString(), plan.Service.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to reload the service", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *serviceReloadResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
+	// the reload only happens on creation, there is nothing to read back
+}
+
+func (r *serviceReloadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan serviceReloadResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Changing the action does not reload the service again
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *serviceReloadResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// nothing to do, the service is left as is
+}
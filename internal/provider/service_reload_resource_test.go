@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestServiceReloadResourceWithMock(t *testing.T) {
+	t.Run("create reloads the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceCreate(t, newServiceReloadResource(newTestProvider(mock)), testServiceReloadModel())
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"systemctl try-reload-or-restart nginx"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create fails when the service cannot be reloaded", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("systemctl try-reload-or-restart nginx", clients.MockResponse{ExitCode: 1, Stderr: "Job for nginx.service failed"})
+
+		// Act
+		_, diags := testResourceCreate(t, newServiceReloadResource(newTestProvider(mock)), testServiceReloadModel())
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("update of the action does not reload the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		plan := testServiceReloadModel()
+		plan.Action = types.StringValue("restart")
+
+		// Act
+		state, diags := testResourceUpdate(t, newServiceReloadResource(newTestProvider(mock)), testServiceReloadModel(), plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if len(mock.Commands) != 0 || state.Action.ValueString() != "restart" {
+			t.Fatalf("unexpected reload: %v", mock.Commands)
+		}
+	})
+}
+
+func testServiceReloadModel() serviceReloadResourceModel {
+	return serviceReloadResourceModel{
+		Service:  types.StringValue("nginx"),
+		Triggers: types.MapValueMust(types.StringType, map[string]attr.Value{"config": types.StringValue("worker_processes auto;\n")}),
+		Action:   types.StringValue("try-reload-or-restart"),
+	}
+}
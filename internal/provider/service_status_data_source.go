@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource = &serviceStatusDataSource{}
+)
+
+func newServiceStatusDataSource(p *internalProvider) datasource.DataSource {
+	return &serviceStatusDataSource{
+		provider: p,
+	}
+}
+
+type serviceStatusDataSource struct {
+	provider *internalProvider
+}
+
+type serviceStatusDataSourceModel struct {
+	Name          types.String `tfsdk:"name"`
+	Exists        types.Bool   `tfsdk:"exists"`
+	Enabled       types.Bool   `tfsdk:"enabled"`
+	Active        types.Bool   `tfsdk:"active"`
+	UnitFileState types.String `tfsdk:"unit_file_state"`
+	ActiveState   types.String `tfsdk:"active_state"`
+	SubState      types.String `tfsdk:"sub_state"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (d *serviceStatusDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_service_status"
+}
+
+func (d *serviceStatusDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Reads whether a systemd unit exists, is enabled and is active, e.g. to only configure the " +
+			"virtual hosts of nginx when nginx.service exists. A missing unit is not an error",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the unit, e.g. 'nginx.service' or 'nginx', the .service suffix being implied",
+				Validators:  []validator.String{notBlank()},
+			},
+			"exists": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the unit is known to systemd",
+			},
+			"enabled": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the unit is enabled, i.e. started at boot",
+			},
+			"active": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the unit is active, i.e. running for a service",
+			},
+			"unit_file_state": schema.StringAttribute{
+				Computed:    true,
+				Description: "The state of the unit file as reported by systemctl, e.g. 'enabled', 'disabled', 'static' or 'masked', empty when the unit does not exist",
+			},
+			"active_state": schema.StringAttribute{
+				Computed:    true,
+				Description: "The active state as reported by systemctl, e.g. 'active', 'inactive' or 'failed'",
+			},
+			"sub_state": schema.StringAttribute{
+				Computed:    true,
+				Description: "The state specific to the type of the unit as reported by systemctl, e.g. 'running' or 'exited'",
+			},
+			"target_host": targetHostDataSourceAttribute(),
+		},
+	}
+}
+
+func (d *serviceStatusDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = d.provider.onHost(ctx, req.Config)
+
+	var model serviceStatusDataSourceModel
+
+	diags := req.Config.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	out, err := d.provider.machineAccessClient.RunCommand(ctx, clients.ShellCommand("systemctl", "show", "--property=LoadState,UnitFileState,ActiveState,SubState", "--", model.Name.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the status of "+model.Name.ValueString(), fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	properties := parseSystemctlProperties(out)
+
+	model.Exists = types.BoolValue(properties["LoadState"] != "not-found")
+	model.Enabled = types.BoolValue(properties["UnitFileState"] == "enabled" || properties["UnitFileState"] == "enabled-runtime")
+	model.Active = types.BoolValue(properties["ActiveState"] == "active" || properties["ActiveState"] == "reloading")
+	model.UnitFileState = types.StringValue(properties["UnitFileState"])
+	model.ActiveState = types.StringValue(properties["ActiveState"])
+	model.SubState = types.StringValue(properties["SubState"])
+
+	diags = resp.State.Set(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+}
+
+// parseSystemctlProperties returns the properties printed by systemctl show, e.g.
+//
+//	LoadState=loaded
+//	ActiveState=active
+func parseSystemctlProperties(out string) map[string]string {
+	properties := map[string]string{}
+
+	for line := range strings.SplitSeq(out, "\n") {
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			properties[name] = value
+		}
+	}
+
+	return properties
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestServiceStatusDataSourceWithMock(t *testing.T) {
+	const command = "systemctl show --property=LoadState,UnitFileState,ActiveState,SubState -- "
+
+	t.Run("an enabled and running service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(command+"nginx", clients.MockResponse{Stdout: "LoadState=loaded\nActiveState=active\nSubState=running\nUnitFileState=enabled\n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newServiceStatusDataSource(newTestProvider(mock)), serviceStatusDataSourceModel{
+			Name: types.StringValue("nginx"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.Exists.ValueBool() || !state.Enabled.ValueBool() || !state.Active.ValueBool() || state.SubState.ValueString() != "running" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("a missing service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(command+"nginx.service", clients.MockResponse{Stdout: "LoadState=not-found\nActiveState=inactive\nSubState=dead\nUnitFileState=\n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newServiceStatusDataSource(newTestProvider(mock)), serviceStatusDataSourceModel{
+			Name: types.StringValue("nginx.service"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Exists.ValueBool() || state.Enabled.ValueBool() || state.Active.ValueBool() || state.UnitFileState.ValueString() != "" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("a static service that failed", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On(command+"backup", clients.MockResponse{Stdout: "LoadState=loaded\nActiveState=failed\nSubState=failed\nUnitFileState=static\n"})
+
+		// Act
+		state, diags := testDataSourceRead(t, newServiceStatusDataSource(newTestProvider(mock)), serviceStatusDataSourceModel{
+			Name: types.StringValue("backup"),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.Exists.ValueBool() || state.Enabled.ValueBool() || state.Active.ValueBool() || state.ActiveState.ValueString() != "failed" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+}
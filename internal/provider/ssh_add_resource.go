@@ -5,18 +5,25 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &sshAddResource{}
 var _ resource.ResourceWithImportState = &sshAddResource{}
+var _ resource.ResourceWithModifyPlan = &sshAddResource{}
+
+var errAuthorizedKeyExists = errors.New("the public key is already present in the authorized_keys file")
 
 func newSSHAddResource(p *internalProvider) resource.Resource {
 	return &sshAddResource{
@@ -32,8 +39,12 @@ type sshAddResource struct {
 type sshAddResourceModel struct {
 	AuthorizedKeysPath types.String `tfsdk:"authorized_keys_path"`
 	PublicKey          types.String `tfsdk:"public_key"`
+	KeyFile            types.String `tfsdk:"key_file"`
+	Options            types.String `tfsdk:"options"`
 	Comment            types.String `tfsdk:"comment"`
 	ID                 types.String `tfsdk:"id"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (r *sshAddResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -42,25 +53,39 @@ func (r *sshAddResource) Metadata(_ context.Context, req resource.MetadataReques
 
 func (r *sshAddResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "SSH Add resource that adds a public key to an authorized_keys file with an optional comment",
+		MarkdownDescription: "SSH Add resource that adds a public key to an authorized_keys file with optional options and comment. " +
+			"The key is matched by its type and data, so entries with other options or comments are recognized. It can be imported with " +
+			"its id, the path of the authorized_keys file and the public key separated by a colon, e.g. " +
+			"`terraform import setup_ssh_add.key '/home/alice/.ssh/authorized_keys:ssh-ed25519 AAAA...'`",
 
 		Attributes: map[string]schema.Attribute{
 			"authorized_keys_path": schema.StringAttribute{
 				Required:    true,
 				Description: "The path to the authorized_keys file",
+				Validators:  []validator.String{absolutePath()},
 			},
 			"public_key": schema.StringAttribute{
-				Required:    true,
-				Description: "The public key content to add",
+				Optional:    true,
+				Computed:    true,
+				Description: "The public key content to add, e.g. 'ssh-ed25519 AAAA... user@host'. Exactly one of public_key and key_file must be set",
+			},
+			"key_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to a public key file, e.g. ~/.ssh/id_ed25519.pub, on the machine running terraform. Exactly one of public_key and key_file must be set",
+			},
+			"options": schema.StringAttribute{
+				Optional:    true,
+				Description: "The options of the entry, e.g. 'no-port-forwarding,command=\"/usr/bin/backup\"'. See the AUTHORIZED_KEYS FILE FORMAT section of sshd(8)",
 			},
 			"comment": schema.StringAttribute{
 				Optional:    true,
-				Description: "An optional comment to append to the public key",
+				Description: "An optional comment to append to the public key, replacing the comment of the public key if it has one",
 			},
 			"id": schema.StringAttribute{
 				Computed:    true,
 				Description: "Unique identifier for this resource",
 			},
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -69,7 +94,12 @@ func (r *sshAddResource) Configure(_ context.Context, _ resource.ConfigureReques
 
 }
 
-func (r *sshAddResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+func (r *sshAddResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		// The resource is destroyed
+		return
+	}
+
 	var plan sshAddResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -79,69 +109,87 @@ func (r *sshAddResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	// Prepare the key entry
-	publicKey := strings.TrimSpace(plan.PublicKey.ValueString())
-	keyEntry := publicKey
+	var config sshAddResourceModel
 
-	if !plan.Comment.IsNull() && !plan.Comment.IsUnknown() && plan.Comment.ValueString() != "" {
-		keyEntry = fmt.Sprintf("%s %s", publicKey, plan.Comment.ValueString())
+	diags = req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
 	}
 
-	// Ensure the authorized_keys directory exists
-	authorizedKeysDir := plan.AuthorizedKeysPath.ValueString()
-	if strings.Contains(authorizedKeysDir, "/") {
-		dirPath := authorizedKeysDir[:strings.LastIndex(authorizedKeysDir, "/")]
+	if config.PublicKey.IsNull() == config.KeyFile.IsNull() {
+		resp.Diagnostics.AddError("Invalid public key", "Exactly one of public_key and key_file must be set")
+		return
+	}
+
+	if !config.KeyFile.IsNull() {
+		if config.KeyFile.IsUnknown() {
+			return
+		}
 
-		_, err := r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("mkdir -p %s", dirPath))
+		// The file is read while planning, so that a changed key shows in the plan
+		content, err := os.ReadFile(config.KeyFile.ValueString())
 		if err != nil {
-			resp.Diagnostics.AddError("Failed to create authorized_keys directory", err.Error())
+			resp.Diagnostics.AddError("Failed to read key_file", err.Error())
 			return
 		}
+
+		plan.PublicKey = types.StringValue(strings.TrimSpace(string(content)))
 	}
 
-	// Check if the key already exists in the file
-	content, err := r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("cat %s 2>/dev/null || echo ''", plan.AuthorizedKeysPath.ValueString()))
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to read authorized_keys file", err.Error())
+	if plan.PublicKey.IsUnknown() {
 		return
 	}
 
-	// Check if the public key already exists (ignoring comments)
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+	key, ok := parseAuthorizedKey(plan.PublicKey.ValueString())
+	if !ok || key.Options != "" {
+		resp.Diagnostics.AddError("Invalid public key", "The public key must be in the format 'type data [comment]', with the options in the options attribute, got: "+plan.PublicKey.ValueString())
+		return
+	}
 
-		// Extract just the key part (first two fields typically: key-type and key-data)
-		parts := strings.Fields(line)
-		existingParts := strings.Fields(publicKey)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("public_key"), plan.PublicKey)...)
+}
 
-		if len(parts) >= 2 && len(existingParts) >= 2 {
-			if parts[0] == existingParts[0] && parts[1] == existingParts[1] {
-				resp.Diagnostics.AddError("Public key already exists", "The public key is already present in the authorized_keys file")
-				return
-			}
+func (r *sshAddResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan sshAddResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	entry := sshAddEntry(plan)
+
+	// Ensure the authorized_keys directory exists
+	authorizedKeysDir := plan.AuthorizedKeysPath.ValueString()
+	if strings.Contains(authorizedKeysDir, "/") {
+		dirPath := authorizedKeysDir[:strings.LastIndex(authorizedKeysDir, "/")]
+
+		_, err := r.provider.machineAccessClient.Run(ctx, "mkdir -p "+clients.ShellQuote(dirPath))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create authorized_keys directory", err.Error())
+			return
 		}
 	}
 
-	// Append the key to the authorized_keys file
-	_, err = r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("echo '%s' >> %s", keyEntry, plan.AuthorizedKeysPath.ValueString()))
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to add key to authorized_keys", err.Error())
+	err := r.addKeyToFile(ctx, plan.AuthorizedKeysPath.ValueString(), entry)
+	if errors.Is(err, errAuthorizedKeyExists) {
+		resp.Diagnostics.AddError("Public key already exists", "The public key is already present in the authorized_keys file")
 		return
 	}
 
-	// Set appropriate permissions on the authorized_keys file
-	_, err = r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("chmod 600 %s", plan.AuthorizedKeysPath.ValueString()))
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to set permissions on authorized_keys", err.Error())
+		resp.Diagnostics.AddError("Failed to add key to authorized_keys", err.Error())
 		return
 	}
 
 	// Generate a unique ID based on the key and path
-	plan.ID = types.StringValue(fmt.Sprintf("%s:%s", plan.AuthorizedKeysPath.ValueString(), publicKey))
+	plan.ID = types.StringValue(fmt.Sprintf("%s:%s", plan.AuthorizedKeysPath.ValueString(), strings.TrimSpace(plan.PublicKey.ValueString())))
 
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
@@ -152,6 +200,8 @@ func (r *sshAddResource) Create(ctx context.Context, req resource.CreateRequest,
 }
 
 func (r *sshAddResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
 	var model sshAddResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -161,40 +211,28 @@ func (r *sshAddResource) Read(ctx context.Context, req resource.ReadRequest, res
 		return
 	}
 
-	// Read the authorized_keys file
-	content, err := r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("cat %s 2>/dev/null || echo ''", model.AuthorizedKeysPath.ValueString()))
+	lines, err := r.readAuthorizedKeys(ctx, model.AuthorizedKeysPath.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to read authorized_keys file", err.Error())
 		return
 	}
 
-	// Check if our public key still exists
-	publicKey := strings.TrimSpace(model.PublicKey.ValueString())
-	lines := strings.Split(content, "\n")
-	keyExists := false
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+	index, found := findAuthorizedKey(lines, sshAddEntry(model))
+	if !found {
+		// Key no longer exists, remove from state
+		resp.State.RemoveResource(ctx)
+		return
+	}
 
-		// Extract just the key part (first two fields typically: key-type and key-data)
-		parts := strings.Fields(line)
-		existingParts := strings.Fields(publicKey)
+	existing, _ := parseAuthorizedKey(lines[index])
 
-		if len(parts) >= 2 && len(existingParts) >= 2 {
-			if parts[0] == existingParts[0] && parts[1] == existingParts[1] {
-				keyExists = true
-				break
-			}
-		}
+	// Options and comment changed outside of terraform are detected, unless they are left unmanaged
+	if !model.Options.IsNull() || existing.Options != "" {
+		model.Options = types.StringValue(existing.Options)
 	}
 
-	if !keyExists {
-		// Key no longer exists, remove from state
-		resp.State.RemoveResource(ctx)
-		return
+	if !model.Comment.IsNull() {
+		model.Comment = types.StringValue(existing.Comment)
 	}
 
 	diags = resp.State.Set(ctx, model)
@@ -206,6 +244,8 @@ func (r *sshAddResource) Read(ctx context.Context, req resource.ReadRequest, res
 }
 
 func (r *sshAddResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
 	var plan sshAddResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -227,60 +267,38 @@ func (r *sshAddResource) Update(ctx context.Context, req resource.UpdateRequest,
 	// If the authorized_keys_path or public_key changed, we need to remove the old key and add the new one
 	if !plan.AuthorizedKeysPath.Equal(state.AuthorizedKeysPath) || !plan.PublicKey.Equal(state.PublicKey) {
 		// Remove the old key first
-		err := r.removeKeyFromFile(ctx, state.AuthorizedKeysPath.ValueString(), state.PublicKey.ValueString())
+		err := r.removeKeyFromFile(ctx, state.AuthorizedKeysPath.ValueString(), sshAddEntry(state))
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to remove old key", err.Error())
 			return
 		}
 
-		// Add the new key
-		publicKey := strings.TrimSpace(plan.PublicKey.ValueString())
-		keyEntry := publicKey
-
-		if !plan.Comment.IsNull() && !plan.Comment.IsUnknown() && plan.Comment.ValueString() != "" {
-			keyEntry = fmt.Sprintf("%s %s", publicKey, plan.Comment.ValueString())
-		}
-
 		// Ensure the new authorized_keys directory exists
 		authorizedKeysDir := plan.AuthorizedKeysPath.ValueString()
 		if strings.Contains(authorizedKeysDir, "/") {
 			dirPath := authorizedKeysDir[:strings.LastIndex(authorizedKeysDir, "/")]
 
-			_, err := r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("mkdir -p %s", dirPath))
+			_, err := r.provider.machineAccessClient.Run(ctx, "mkdir -p "+clients.ShellQuote(dirPath))
 			if err != nil {
 				resp.Diagnostics.AddError("Failed to create authorized_keys directory", err.Error())
 				return
 			}
 		}
 
-		// Append the new key
-		_, err = r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("echo '%s' >> %s", keyEntry, plan.AuthorizedKeysPath.ValueString()))
-		if err != nil {
-			resp.Diagnostics.AddError("Failed to add new key to authorized_keys", err.Error())
-			return
-		}
-
-		// Set appropriate permissions
-		_, err = r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("chmod 600 %s", plan.AuthorizedKeysPath.ValueString()))
-		if err != nil {
-			resp.Diagnostics.AddError("Failed to set permissions on authorized_keys", err.Error())
-			return
-		}
-
 		// Update ID
-		plan.ID = types.StringValue(fmt.Sprintf("%s:%s", plan.AuthorizedKeysPath.ValueString(), publicKey))
-	} else if !plan.Comment.Equal(state.Comment) {
-		// Only the comment changed, update the key in place
-		err := r.updateKeyComment(ctx, plan.AuthorizedKeysPath.ValueString(), plan.PublicKey.ValueString(), plan.Comment.ValueString())
-		if err != nil {
-			resp.Diagnostics.AddError("Failed to update key comment", err.Error())
-			return
-		}
-
+		plan.ID = types.StringValue(fmt.Sprintf("%s:%s", plan.AuthorizedKeysPath.ValueString(), strings.TrimSpace(plan.PublicKey.ValueString())))
+	} else {
 		// ID stays the same since path and key didn't change
 		plan.ID = state.ID
 	}
 
+	// The entry is replaced in place when only its options or comment changed
+	err := r.setKeyInFile(ctx, plan.AuthorizedKeysPath.ValueString(), sshAddEntry(plan))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to add key to authorized_keys", err.Error())
+		return
+	}
+
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 
@@ -290,6 +308,8 @@ func (r *sshAddResource) Update(ctx context.Context, req resource.UpdateRequest,
 }
 
 func (r *sshAddResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
 	var model sshAddResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -300,7 +320,7 @@ func (r *sshAddResource) Delete(ctx context.Context, req resource.DeleteRequest,
 	}
 
 	// Remove the key from the authorized_keys file
-	err := r.removeKeyFromFile(ctx, model.AuthorizedKeysPath.ValueString(), model.PublicKey.ValueString())
+	err := r.removeKeyFromFile(ctx, model.AuthorizedKeysPath.ValueString(), sshAddEntry(model))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to remove key from authorized_keys", err.Error())
 		return
@@ -308,118 +328,122 @@ func (r *sshAddResource) Delete(ctx context.Context, req resource.DeleteRequest,
 }
 
 func (r *sshAddResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
-}
-
-// Helper function to remove a key from the authorized_keys file
-func (r *sshAddResource) removeKeyFromFile(ctx context.Context, filePath, publicKey string) error {
-	// Read the current content
-	content, err := r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("cat %s 2>/dev/null || echo ''", filePath))
-	if err != nil {
-		return fmt.Errorf("failed to read authorized_keys file: %w", err)
+	// The id is the path of the authorized_keys file and the public key, separated by a colon
+	authorizedKeysPath, publicKey, found := strings.Cut(req.ID, ":")
+	if !found || authorizedKeysPath == "" {
+		resp.Diagnostics.AddError("Invalid import id", "Expected <authorized_keys_path>:<public key>, got: "+req.ID)
+		return
 	}
 
-	publicKey = strings.TrimSpace(publicKey)
-	lines := strings.Split(content, "\n")
+	if _, ok := parseAuthorizedKey(publicKey); !ok {
+		resp.Diagnostics.AddError("Invalid import id", "Failed to parse the public key: "+publicKey)
+		return
+	}
 
-	var newLines []string
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("authorized_keys_path"), authorizedKeysPath)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("public_key"), strings.TrimSpace(publicKey))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), authorizedKeysPath+":"+strings.TrimSpace(publicKey))...)
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+// sshAddEntry returns the authorized_keys entry of the model. The comment attribute replaces the comment of the public
+// key.
+func sshAddEntry(model sshAddResourceModel) authorizedKey {
+	entry, _ := parseAuthorizedKey(model.PublicKey.ValueString())
+	entry.Options = model.Options.ValueString()
 
-		shouldKeep := true
+	if model.Comment.ValueString() != "" {
+		entry.Comment = model.Comment.ValueString()
+	}
 
-		if !strings.HasPrefix(line, "#") {
-			// Extract just the key part (first two fields typically: key-type and key-data)
-			parts := strings.Fields(line)
-			existingParts := strings.Fields(publicKey)
+	return entry
+}
 
-			if len(parts) >= 2 && len(existingParts) >= 2 {
-				if parts[0] == existingParts[0] && parts[1] == existingParts[1] {
-					shouldKeep = false
-				}
-			}
+// findAuthorizedKey returns the index of the line with the same key as the entry.
+func findAuthorizedKey(lines []string, entry authorizedKey) (int, bool) {
+	for i, line := range lines {
+		existing, ok := parseAuthorizedKey(line)
+		if ok && existing.sameKey(entry) {
+			return i, true
 		}
+	}
 
-		if shouldKeep {
-			newLines = append(newLines, line)
-		}
+	return 0, false
+}
+
+// readAuthorizedKeys returns the lines of the authorized_keys file, or no lines when it does not exist.
+func (r *sshAddResource) readAuthorizedKeys(ctx context.Context, filePath string) ([]string, error) {
+	return readLines(ctx, r.provider.machineAccessClient, filePath)
+}
+
+// writeAuthorizedKeys replaces the authorized_keys file through a temporary file, so that sshd never reads a partially
+// written file. The owner of an existing file is kept, a new file is owned by the connecting user.
+func (r *sshAddResource) writeAuthorizedKeys(ctx context.Context, filePath string, lines []string) error {
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
 	}
 
-	// Write the updated content back
-	newContent := strings.Join(newLines, "\n")
-	if newContent != "" {
-		newContent += "\n"
+	owner, group, err := ownerOrConnectingUser(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		return err
 	}
 
-	_, err = r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("echo '%s' > %s", newContent, filePath))
+	err = r.provider.machineAccessClient.WriteFile(ctx, filePath, "600", owner, group, content)
 	if err != nil {
-		return fmt.Errorf("failed to write updated authorized_keys file: %w", err)
+		return fmt.Errorf("failed to write authorized_keys file: %w", err)
 	}
 
 	return nil
 }
 
-// Helper function to update the comment for a key
-func (r *sshAddResource) updateKeyComment(ctx context.Context, filePath, publicKey, comment string) error {
-	// Read the current content
-	content, err := r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("cat %s 2>/dev/null || echo ''", filePath))
+// Helper function to add a key to the authorized_keys file, failing when the file already has it
+func (r *sshAddResource) addKeyToFile(ctx context.Context, filePath string, entry authorizedKey) error {
+	defer r.provider.lockFile(ctx, filePath)()
+
+	lines, err := r.readAuthorizedKeys(ctx, filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read authorized_keys file: %w", err)
 	}
 
-	publicKey = strings.TrimSpace(publicKey)
-	lines := strings.Split(content, "\n")
-
-	var newLines []string
-
-	for _, line := range lines {
-		originalLine := line
-		line = strings.TrimSpace(line)
+	if _, found := findAuthorizedKey(lines, entry); found {
+		return errAuthorizedKeyExists
+	}
 
-		if line == "" {
-			newLines = append(newLines, originalLine)
-			continue
-		}
+	return r.writeAuthorizedKeys(ctx, filePath, append(lines, entry.String()))
+}
 
-		if strings.HasPrefix(line, "#") {
-			newLines = append(newLines, originalLine)
-			continue
-		}
+// Helper function to remove a key from the authorized_keys file
+func (r *sshAddResource) removeKeyFromFile(ctx context.Context, filePath string, entry authorizedKey) error {
+	defer r.provider.lockFile(ctx, filePath)()
 
-		// Check if this is the key we want to update
-		parts := strings.Fields(line)
-		existingParts := strings.Fields(publicKey)
-
-		if len(parts) >= 2 && len(existingParts) >= 2 {
-			if parts[0] == existingParts[0] && parts[1] == existingParts[1] {
-				// This is our key, update it with the new comment
-				if comment != "" {
-					newLines = append(newLines, fmt.Sprintf("%s %s", publicKey, comment))
-				} else {
-					newLines = append(newLines, publicKey)
-				}
-			} else {
-				newLines = append(newLines, originalLine)
-			}
-		} else {
-			newLines = append(newLines, originalLine)
-		}
+	lines, err := r.readAuthorizedKeys(ctx, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read authorized_keys file: %w", err)
 	}
 
-	// Write the updated content back
-	newContent := strings.Join(newLines, "\n")
-	if newContent != "" && !strings.HasSuffix(newContent, "\n") {
-		newContent += "\n"
+	index, found := findAuthorizedKey(lines, entry)
+	if !found {
+		return nil
 	}
 
-	_, err = r.provider.machineAccessClient.RunCommand(ctx, fmt.Sprintf("echo '%s' > %s", newContent, filePath))
+	return r.writeAuthorizedKeys(ctx, filePath, append(lines[:index], lines[index+1:]...))
+}
+
+// Helper function to replace the entry of a key, or to add it when the file does not have it
+func (r *sshAddResource) setKeyInFile(ctx context.Context, filePath string, entry authorizedKey) error {
+	defer r.provider.lockFile(ctx, filePath)()
+
+	lines, err := r.readAuthorizedKeys(ctx, filePath)
 	if err != nil {
-		return fmt.Errorf("failed to write updated authorized_keys file: %w", err)
+		return fmt.Errorf("failed to read authorized_keys file: %w", err)
 	}
 
-	return nil
+	index, found := findAuthorizedKey(lines, entry)
+	if found {
+		lines[index] = entry.String()
+	} else {
+		lines = append(lines, entry.String())
+	}
+
+	return r.writeAuthorizedKeys(ctx, filePath, lines)
 }
@@ -3,10 +3,14 @@ package provider
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -137,6 +141,53 @@ func TestSSHAddResource(t *testing.T) {
 			},
 		})
 	})
+
+	t.Run("Test add SSH public key from a file with options", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		keyFile := filepath.Join(t.TempDir(), "id_ed25519.pub")
+		if err := os.WriteFile(keyFile, []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOptions user@laptop\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + fmt.Sprintf(`
+resource "setup_ssh_add" "test" {
+  authorized_keys_path = "/tmp/authorized_keys_options"
+  key_file             = "%s"
+  options              = "no-port-forwarding,command=\"echo 'hello world'\""
+}
+`, keyFile),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_ssh_add.test", "public_key", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOptions user@laptop"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/authorized_keys_options")
+							if err != nil {
+								return fmt.Errorf("authorized_keys file not found")
+							}
+
+							expectedEntry := "no-port-forwarding,command=\"echo 'hello world'\" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOptions user@laptop\n"
+							if content != expectedEntry {
+								return fmt.Errorf("unexpected authorized_keys content: %s", content)
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
 }
 
 func testSSHAddResourceConfig(authorizedKeysPath, publicKey, comment string) string {
@@ -157,3 +208,170 @@ resource "setup_ssh_add" "test" {
 }
 `, authorizedKeysPath, publicKey)
 }
+
+func TestSSHAddResourceWithMock(t *testing.T) {
+	t.Run("create keeps the other entries and adds the options", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/test/.ssh/authorized_keys", "# managed keys\nfrom=\"10.0.0.1\" ssh-rsa AAAAB3Nza other\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		_, diags := testResourceCreate(t, newSSHAddResource(newTestProvider(mock)), sshAddResourceModel{
+			AuthorizedKeysPath: types.StringValue("/home/test/.ssh/authorized_keys"),
+			PublicKey:          types.StringValue("ssh-ed25519 AAAAC3Nza user@laptop"),
+			KeyFile:            types.StringNull(),
+			Options:            types.StringValue("no-pty"),
+			Comment:            types.StringNull(),
+			ID:                 types.StringUnknown(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "# managed keys\nfrom=\"10.0.0.1\" ssh-rsa AAAAB3Nza other\nno-pty ssh-ed25519 AAAAC3Nza user@laptop\n"
+		if mock.Files["/home/test/.ssh/authorized_keys"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/test/.ssh/authorized_keys"])
+		}
+	})
+
+	t.Run("concurrent creates on the same file keep all the keys", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("id -u && id -g", clients.MockResponse{Stdout: "1000\n1000\n"})
+		r := newSSHAddResource(newTestProvider(mock))
+
+		var wg sync.WaitGroup
+
+		// Act
+		for i := range 10 {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, diags := testResourceCreate(t, r, sshAddResourceModel{
+					AuthorizedKeysPath: types.StringValue("/home/test/.ssh/authorized_keys"),
+					PublicKey:          types.StringValue(fmt.Sprintf("ssh-ed25519 AAAAC3Nza%d", i)),
+					KeyFile:            types.StringNull(),
+					Options:            types.StringNull(),
+					Comment:            types.StringNull(),
+					ID:                 types.StringUnknown(),
+				})
+				if diags.HasError() {
+					t.Error(diags)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// Assert
+		lines := strings.Split(strings.TrimSpace(mock.Files["/home/test/.ssh/authorized_keys"]), "\n")
+		if len(lines) != 10 {
+			t.Fatalf("expected 10 keys, got: %v", lines)
+		}
+
+		if info := mock.FileInfos["/home/test/.ssh/authorized_keys"]; info.Mode != "600" || info.Owner != 1000 {
+			t.Fatalf("unexpected file info: %+v", info)
+		}
+	})
+
+	t.Run("create fails when the key is present with other options", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/tmp/authorized_keys", "command=\"echo hello world\" ssh-ed25519 AAAAC3Nza old comment\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		_, diags := testResourceCreate(t, newSSHAddResource(newTestProvider(mock)), sshAddResourceModel{
+			AuthorizedKeysPath: types.StringValue("/tmp/authorized_keys"),
+			PublicKey:          types.StringValue("ssh-ed25519 AAAAC3Nza"),
+			KeyFile:            types.StringNull(),
+			Options:            types.StringNull(),
+			Comment:            types.StringNull(),
+			ID:                 types.StringUnknown(),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("read detects changed options and comment", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/tmp/authorized_keys", "no-agent-forwarding ssh-ed25519 AAAAC3Nza edited\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newSSHAddResource(newTestProvider(mock)), sshAddResourceModel{
+			AuthorizedKeysPath: types.StringValue("/tmp/authorized_keys"),
+			PublicKey:          types.StringValue("ssh-ed25519 AAAAC3Nza"),
+			KeyFile:            types.StringNull(),
+			Options:            types.StringNull(),
+			Comment:            types.StringValue("laptop"),
+			ID:                 types.StringValue("/tmp/authorized_keys:ssh-ed25519 AAAAC3Nza"),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Options.ValueString() != "no-agent-forwarding" || state.Comment.ValueString() != "edited" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read removes a missing key from the state", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newSSHAddResource(newTestProvider(mock)), sshAddResourceModel{
+			AuthorizedKeysPath: types.StringValue("/tmp/authorized_keys"),
+			PublicKey:          types.StringValue("ssh-ed25519 AAAAC3Nza"),
+			KeyFile:            types.StringNull(),
+			Options:            types.StringNull(),
+			Comment:            types.StringNull(),
+			ID:                 types.StringValue("/tmp/authorized_keys:ssh-ed25519 AAAAC3Nza"),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+	})
+
+	t.Run("import finds the key and its options", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/tmp/authorized_keys", "no-agent-forwarding ssh-ed25519 AAAAC3Nza laptop\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		state, diags := testResourceImportState[sshAddResourceModel](t, newSSHAddResource(newTestProvider(mock)), "/tmp/authorized_keys:ssh-ed25519 AAAAC3Nza")
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.AuthorizedKeysPath.ValueString() != "/tmp/authorized_keys" || state.PublicKey.ValueString() != "ssh-ed25519 AAAAC3Nza" || state.Options.ValueString() != "no-agent-forwarding" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("import fails on an id without a public key", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, diags := testResourceImportState[sshAddResourceModel](t, newSSHAddResource(newTestProvider(mock)), "/tmp/authorized_keys")
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+}
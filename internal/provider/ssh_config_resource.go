@@ -0,0 +1,443 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &sshConfigResource{}
+var _ resource.ResourceWithImportState = &sshConfigResource{}
+
+const sshConfigMarker = "setup_ssh_config"
+
+func newSSHConfigResource(p *internalProvider) resource.Resource {
+	return &sshConfigResource{
+		provider: p,
+	}
+}
+
+// sshConfigResource defines the resource implementation.
+type sshConfigResource struct {
+	provider *internalProvider
+}
+
+type sshConfigResourceModel struct {
+	Path         types.String `tfsdk:"path"`
+	Host         types.String `tfsdk:"host"`
+	HostName     types.String `tfsdk:"hostname"`
+	User         types.String `tfsdk:"user"`
+	Port         types.Int64  `tfsdk:"port"`
+	IdentityFile types.String `tfsdk:"identity_file"`
+	ProxyJump    types.String `tfsdk:"proxy_jump"`
+	Options      types.Map    `tfsdk:"options"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+// sshConfigKeywords maps the keywords of ssh_config(5) that have their own attribute to these attributes.
+var sshConfigKeywords = []struct {
+	keyword   string
+	attribute string
+}{
+	{"HostName", "hostname"},
+	{"User", "user"},
+	{"Port", "port"},
+	{"IdentityFile", "identity_file"},
+	{"ProxyJump", "proxy_jump"},
+}
+
+func (r *sshConfigResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_ssh_config"
+}
+
+func (r *sshConfigResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "SSH config resource that manages a Host block of an OpenSSH client configuration file, e.g. ~/.ssh/config. " +
+			"The block is delimited by marker comments, so that the rest of the file is left untouched. " +
+			"A new block is appended to the file, note that ssh uses the first value found for each option",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the configuration file, e.g. /home/deploy/.ssh/config. The directory is created with mode 700 when missing",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"host": schema.StringAttribute{
+				Required:    true,
+				Description: "The patterns of the Host block, e.g. 'bastion' or '*.internal !db.internal'",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"hostname": schema.StringAttribute{
+				Optional:    true,
+				Description: "The real host name to connect to (HostName)",
+			},
+			"user": schema.StringAttribute{
+				Optional:    true,
+				Description: "The user to log in as (User)",
+			},
+			"port": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The port to connect to (Port)",
+				Validators:  []validator.Int64{port()},
+			},
+			"identity_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "The private key file to authenticate with (IdentityFile)",
+			},
+			"proxy_jump": schema.StringAttribute{
+				Optional:    true,
+				Description: "The jump hosts to connect through (ProxyJump)",
+			},
+			"options": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Other options of the block, by keyword, e.g. { ForwardAgent = \"yes\" }. See ssh_config(5)",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *sshConfigResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *sshConfigResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan sshConfigResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *sshConfigResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model sshConfigResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, model.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read ssh config file", err.Error())
+		return
+	}
+
+	start, end, found := findSSHConfigBlock(lines, model.Host.ValueString())
+	if !found {
+		// The block was removed outside of terraform, it must be added again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	values := map[string]string{}
+	options := map[string]string{}
+
+	// The first line of the block is the Host line
+	for _, line := range lines[start+2 : end] {
+		keyword, value, ok := parseSSHConfigLine(line)
+		if !ok {
+			continue
+		}
+
+		attribute := ""
+
+		for _, known := range sshConfigKeywords {
+			if strings.EqualFold(known.keyword, keyword) {
+				attribute = known.attribute
+			}
+		}
+
+		if attribute != "" {
+			values[attribute] = value
+		} else {
+			options[keyword] = value
+		}
+	}
+
+	model.HostName = optionalString(values, "hostname")
+	model.User = optionalString(values, "user")
+	model.IdentityFile = optionalString(values, "identity_file")
+	model.ProxyJump = optionalString(values, "proxy_jump")
+
+	model.Port = types.Int64Null()
+	if value, ok := values["port"]; ok {
+		port, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to parse Port in ssh config", err.Error())
+			return
+		}
+
+		model.Port = types.Int64Value(port)
+	}
+
+	if !model.Options.IsNull() || len(options) > 0 {
+		model.Options, diags = types.MapValueFrom(ctx, types.StringType, options)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *sshConfigResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan sshConfigResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, plan, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *sshConfigResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model sshConfigResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	filePath := model.Path.ValueString()
+
+	defer r.provider.lockFile(ctx, filePath)()
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read ssh config file", err.Error())
+		return
+	}
+
+	start, end, found := findSSHConfigBlock(lines, model.Host.ValueString())
+	if !found {
+		return
+	}
+
+	err = r.writeConfig(ctx, filePath, append(lines[:start], lines[end+1:]...))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to write ssh config file", err.Error())
+		return
+	}
+}
+
+func (r *sshConfigResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	// The import id is <path>:<host>
+	filePath, host, found := strings.Cut(req.ID, ":")
+	if !found {
+		resp.Diagnostics.AddError("Invalid import id", "The import id must be <path>:<host>, got: "+req.ID)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("path"), filePath)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("host"), host)...)
+}
+
+// apply replaces the block of the host in the file, or appends it when the file does not have it.
+func (r *sshConfigResource) apply(ctx context.Context, plan sshConfigResourceModel, diags *diag.Diagnostics) {
+	block, d := sshConfigBlock(ctx, plan)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return
+	}
+
+	filePath := plan.Path.ValueString()
+
+	defer r.provider.lockFile(ctx, filePath)()
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		diags.AddError("Failed to read ssh config file", err.Error())
+		return
+	}
+
+	start, end, found := findSSHConfigBlock(lines, plan.Host.ValueString())
+	if found {
+		lines = append(lines[:start], append(block, lines[end+1:]...)...)
+	} else {
+		lines = append(lines, block...)
+	}
+
+	if strings.Contains(filePath, "/") {
+		dir := filePath[:strings.LastIndex(filePath, "/")]
+
+		// ssh refuses a configuration writable by others, so a missing directory is created for the user only
+		out, err := r.provider.machineAccessClient.RunCommand(ctx, "mkdir -p -m 700 "+clients.ShellQuote(dir))
+		if err != nil {
+			diags.AddError("Failed to create ssh config directory", "Err="+err.Error()+"\nout = "+out)
+			return
+		}
+	}
+
+	err = r.writeConfig(ctx, filePath, lines)
+	if err != nil {
+		diags.AddError("Failed to write ssh config file", err.Error())
+		return
+	}
+}
+
+func (r *sshConfigResource) writeConfig(ctx context.Context, filePath string, lines []string) error {
+	owner, group, err := ownerOrConnectingUser(ctx, r.provider.machineAccessClient, filePath)
+	if err != nil {
+		return err
+	}
+
+	return r.provider.machineAccessClient.WriteFile(ctx, filePath, "600", owner, group, joinLines(lines))
+}
+
+// sshConfigBlock returns the lines of the block of the model, including its markers.
+func sshConfigBlock(ctx context.Context, model sshConfigResourceModel) ([]string, diag.Diagnostics) {
+	host := model.Host.ValueString()
+
+	block := []string{
+		fmt.Sprintf("# BEGIN %s %s", sshConfigMarker, host),
+		"Host " + host,
+	}
+
+	values := map[string]string{
+		"hostname":      model.HostName.ValueString(),
+		"user":          model.User.ValueString(),
+		"identity_file": model.IdentityFile.ValueString(),
+		"proxy_jump":    model.ProxyJump.ValueString(),
+	}
+
+	if !model.Port.IsNull() {
+		values["port"] = model.Port.String()
+	}
+
+	for _, known := range sshConfigKeywords {
+		if values[known.attribute] != "" {
+			block = append(block, fmt.Sprintf("    %s %s", known.keyword, values[known.attribute]))
+		}
+	}
+
+	options := map[string]string{}
+
+	if !model.Options.IsNull() {
+		diags := model.Options.ElementsAs(ctx, &options, false)
+		if diags.HasError() {
+			return nil, diags
+		}
+	}
+
+	keywords := make([]string, 0, len(options))
+	for keyword := range options {
+		keywords = append(keywords, keyword)
+	}
+
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		block = append(block, fmt.Sprintf("    %s %s", keyword, options[keyword]))
+	}
+
+	block = append(block, fmt.Sprintf("# END %s %s", sshConfigMarker, host))
+
+	return block, nil
+}
+
+// findSSHConfigBlock returns the indexes of the begin and end markers of the block of the host.
+func findSSHConfigBlock(lines []string, host string) (int, int, bool) {
+	return findMarkedBlock(lines, fmt.Sprintf("# BEGIN %s %s", sshConfigMarker, host), fmt.Sprintf("# END %s %s", sshConfigMarker, host))
+}
+
+// parseSSHConfigLine returns the keyword and the value of a line of ssh_config(5), which are separated by spaces or
+// an equal sign.
+func parseSSHConfigLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	index := strings.IndexAny(line, " \t=")
+	if index < 0 {
+		return line, "", true
+	}
+
+	value := strings.TrimLeft(line[index:], " \t")
+	value = strings.TrimPrefix(value, "=")
+
+	return line[:index], strings.TrimSpace(value), true
+}
+
+func optionalString(values map[string]string, key string) types.String {
+	value, ok := values[key]
+	if !ok {
+		return types.StringNull()
+	}
+
+	return types.StringValue(value)
+}
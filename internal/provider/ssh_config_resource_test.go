@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestSSHConfigResource(t *testing.T) {
+	t.Run("Test add a host block to the ssh config", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_ssh_config" "test" {
+  path       = "/tmp/ssh_config_test/config"
+  host       = "bastion"
+  hostname   = "10.0.0.1"
+  user       = "deploy"
+  port       = 2222
+  proxy_jump = "gateway"
+  options = {
+    ForwardAgent = "yes"
+  }
+}
+`,
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_ssh_config.test", "host", "bastion"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/ssh_config_test/config")
+							if err != nil {
+								return fmt.Errorf("ssh config file not found")
+							}
+
+							expected := "# BEGIN setup_ssh_config bastion\nHost bastion\n    HostName 10.0.0.1\n    User deploy\n    Port 2222\n    ProxyJump gateway\n    ForwardAgent yes\n# END setup_ssh_config bastion\n"
+							if content != expected {
+								return fmt.Errorf("unexpected ssh config content: %s", content)
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func TestSSHConfigResourceWithMock(t *testing.T) {
+	t.Run("create replaces an existing block and keeps the rest of the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/config", "Host *\n    ServerAliveInterval 30\n# BEGIN setup_ssh_config bastion\nHost bastion\n    User root\n# END setup_ssh_config bastion\nHost other\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		// Act
+		_, diags := testResourceCreate(t, newSSHConfigResource(newTestProvider(mock)), sshConfigResourceModel{
+			Path:         types.StringValue("/home/deploy/.ssh/config"),
+			Host:         types.StringValue("bastion"),
+			HostName:     types.StringNull(),
+			User:         types.StringValue("deploy"),
+			Port:         types.Int64Null(),
+			IdentityFile: types.StringValue("~/.ssh/bastion"),
+			ProxyJump:    types.StringNull(),
+			Options:      types.MapValueMust(types.StringType, map[string]attr.Value{"StrictHostKeyChecking": types.StringValue("accept-new")}),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "Host *\n    ServerAliveInterval 30\n# BEGIN setup_ssh_config bastion\nHost bastion\n    User deploy\n    IdentityFile ~/.ssh/bastion\n    StrictHostKeyChecking accept-new\n# END setup_ssh_config bastion\nHost other\n"
+		if mock.Files["/home/deploy/.ssh/config"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/deploy/.ssh/config"])
+		}
+	})
+
+	t.Run("read detects changed options", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/config", "# BEGIN setup_ssh_config bastion\nHost bastion\n    user=admin\n    Port 22\n    ForwardX11 yes\n# END setup_ssh_config bastion\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newSSHConfigResource(newTestProvider(mock)), sshConfigResourceModel{
+			Path:         types.StringValue("/home/deploy/.ssh/config"),
+			Host:         types.StringValue("bastion"),
+			HostName:     types.StringNull(),
+			User:         types.StringValue("deploy"),
+			Port:         types.Int64Null(),
+			IdentityFile: types.StringNull(),
+			ProxyJump:    types.StringNull(),
+			Options:      types.MapNull(types.StringType),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.User.ValueString() != "admin" || state.Port.ValueInt64() != 22 || len(state.Options.Elements()) != 1 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("delete removes only the block", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/home/deploy/.ssh/config", "Host *\n# BEGIN setup_ssh_config bastion\nHost bastion\n# END setup_ssh_config bastion\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		diags := testResourceDelete(t, newSSHConfigResource(newTestProvider(mock)), sshConfigResourceModel{
+			Path:         types.StringValue("/home/deploy/.ssh/config"),
+			Host:         types.StringValue("bastion"),
+			HostName:     types.StringNull(),
+			User:         types.StringNull(),
+			Port:         types.Int64Null(),
+			IdentityFile: types.StringNull(),
+			ProxyJump:    types.StringNull(),
+			Options:      types.MapNull(types.StringType),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/home/deploy/.ssh/config"] != "Host *\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/deploy/.ssh/config"])
+		}
+	})
+}
@@ -5,18 +5,28 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"terraform-provider-setup/internal/provider/clients"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/crypto/ssh"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &sshKeyResource{}
 var _ resource.ResourceWithImportState = &sshKeyResource{}
+var _ resource.ResourceWithModifyPlan = &sshKeyResource{}
 
 func newSSHKeyResource(p *internalProvider) resource.Resource {
 	return &sshKeyResource{
@@ -34,6 +44,9 @@ const (
 	keyTypeDSA = "dsa"
 )
 
+// actualFingerprintKey is the key of the private state holding the fingerprint of the key file found by the last read.
+const actualFingerprintKey = "actual_fingerprint"
+
 type sshKeyResourceModel struct {
 	Path      types.String `tfsdk:"path"`
 	KeyType   types.String `tfsdk:"key_type"`
@@ -42,6 +55,16 @@ type sshKeyResourceModel struct {
 	Owner     types.String `tfsdk:"owner"`
 	Group     types.String `tfsdk:"group"`
 	Mode      types.String `tfsdk:"mode"`
+
+	Passphrase     types.String `tfsdk:"passphrase"`
+	Comment        types.String `tfsdk:"comment"`
+	AllowOverwrite types.Bool   `tfsdk:"allow_overwrite"`
+
+	FingerprintSHA256 types.String `tfsdk:"fingerprint_sha256"`
+	ExportPrivateKey  types.Bool   `tfsdk:"export_private_key"`
+	PrivateKey        types.String `tfsdk:"private_key"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (r *sshKeyResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -50,26 +73,38 @@ func (r *sshKeyResource) Metadata(_ context.Context, req resource.MetadataReques
 
 func (r *sshKeyResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "SSH Key resource that generates SSH keys using ssh-keygen on the remote machine",
+		MarkdownDescription: "SSH Key resource that generates SSH keys using ssh-keygen on the remote machine. An existing key can be imported " +
+			"with its path, e.g. `terraform import setup_ssh_key.deploy /home/deploy/.ssh/id_ed25519`",
 
 		Attributes: map[string]schema.Attribute{
 			"path": schema.StringAttribute{
 				Required:    true,
 				Description: "The path where the SSH private key will be stored (public key will be stored at path.pub)",
+				Validators:  []validator.String{absolutePath()},
 			},
 			"key_type": schema.StringAttribute{
 				Optional:    true,
 				Computed:    true,
 				Description: "The type of SSH key to generate (rsa, ed25519, ecdsa, dsa). Defaults to 'rsa'",
+				Validators:  []validator.String{oneOf("rsa", "ed25519", "ecdsa", "dsa")},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"key_size": schema.Int64Attribute{
 				Optional:    true,
 				Computed:    true,
 				Description: "The size of the SSH key in bits. Defaults to 2048 for RSA keys",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
 			},
 			"public_key": schema.StringAttribute{
 				Computed:    true,
 				Description: "The generated public key content",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"owner": schema.StringAttribute{
 				Optional:    true,
@@ -82,7 +117,45 @@ func (r *sshKeyResource) Schema(_ context.Context, _ resource.SchemaRequest, res
 			"mode": schema.StringAttribute{
 				Optional:    true,
 				Description: "The permissions of the SSH key files in octal format (e.g., '0600'). If not specified, defaults to '0600' for private key and '0644' for public key",
+				Validators:  []validator.String{octalMode()},
+			},
+			"passphrase": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "The passphrase encrypting the private key. The key is not encrypted if not specified. Changing it re-encrypts the existing key",
+			},
+			"comment": schema.StringAttribute{
+				Optional:    true,
+				Description: "The comment of the key, e.g. 'deploy@build-server'. Defaults to the comment chosen by ssh-keygen, user@host. Changing it updates the existing key",
+			},
+			"allow_overwrite": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether an existing key at path is replaced by a newly generated key. Defaults to false, which fails instead, as the existing key may be in use",
+			},
+			"fingerprint_sha256": schema.StringAttribute{
+				Computed:    true,
+				Description: "The SHA256 fingerprint of the public key, e.g. 'SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8', as shown by ssh-keygen -l",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"export_private_key": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the private key is read into private_key. Defaults to false. Only enable it when the key must be used elsewhere, as the private key is then stored in the terraform state",
+			},
+			"private_key": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The private key content, encrypted with the passphrase if set. Only set when export_private_key is true",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -91,7 +164,12 @@ func (r *sshKeyResource) Configure(_ context.Context, _ resource.ConfigureReques
 
 }
 
-func (r *sshKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+func (r *sshKeyResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		// The resource is created or destroyed
+		return
+	}
+
 	var plan sshKeyResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -101,48 +179,62 @@ func (r *sshKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	// Set defaults
-	keyType := keyTypeRSA
-	if !plan.KeyType.IsNull() && !plan.KeyType.IsUnknown() {
-		keyType = plan.KeyType.ValueString()
+	var state sshKeyResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
 	}
 
-	keySize := int64(2048)
-	if !plan.KeySize.IsNull() && !plan.KeySize.IsUnknown() {
-		keySize = plan.KeySize.ValueInt64()
+	regenerated := needsRegeneration(plan, state)
+
+	// A key replaced outside of terraform by a key of the same type and size is only detected by its fingerprint
+	actual, diags := req.Private.GetKey(ctx, actualFingerprintKey)
+	resp.Diagnostics.Append(diags...)
+
+	var actualFingerprint string
+	if actual != nil && json.Unmarshal(actual, &actualFingerprint) == nil && !state.FingerprintSHA256.IsNull() && actualFingerprint != state.FingerprintSHA256.ValueString() {
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("fingerprint_sha256"))
+		regenerated = true
 	}
 
-	// Build ssh-keygen command
-	var cmd strings.Builder
+	// The public key changes when the key is generated again or when its comment changes
+	if regenerated || !plan.Comment.Equal(state.Comment) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("public_key"), types.StringUnknown())...)
+	}
 
-	cmd.WriteString("ssh-keygen -t ")
-	cmd.WriteString(keyType)
+	if regenerated {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("fingerprint_sha256"), types.StringUnknown())...)
+	}
 
-	// Only add key size for RSA and DSA keys
-	if keyType == keyTypeRSA || keyType == keyTypeDSA {
-		cmd.WriteString(fmt.Sprintf(" -b %d", keySize))
+	// The private key file is rewritten by any change of the key
+	if regenerated || !plan.Comment.Equal(state.Comment) || !plan.Passphrase.Equal(state.Passphrase) || !plan.ExportPrivateKey.Equal(state.ExportPrivateKey) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("private_key"), types.StringUnknown())...)
 	}
+}
 
-	cmd.WriteString(" -f ")
-	cmd.WriteString(plan.Path.ValueString())
-	cmd.WriteString(" -N ''") // No passphrase
+func (r *sshKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
 
-	// Generate the SSH key
-	_, err := r.provider.machineAccessClient.RunCommand(ctx, cmd.String())
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to generate SSH key", err.Error())
+	var plan sshKeyResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
 		return
 	}
 
-	// Read the public key
-	publicKeyPath := plan.Path.ValueString() + ".pub"
-
-	publicKeyContent, err := r.provider.machineAccessClient.RunCommand(ctx, "cat "+publicKeyPath)
+	err := r.generate(ctx, &plan)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to read public key", err.Error())
+		resp.Diagnostics.AddError("Failed to generate SSH key", err.Error())
 		return
 	}
 
+	publicKeyPath := plan.Path.ValueString() + ".pub"
+
 	// Set owner and group if specified
 	if !plan.Owner.IsNull() && !plan.Owner.IsUnknown() {
 		ownerStr := plan.Owner.ValueString()
@@ -152,22 +244,12 @@ func (r *sshKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 			groupStr = plan.Group.ValueString()
 		}
 
-		// Build chown command with sudo
-		var chownCmd strings.Builder
-		chownCmd.WriteString("sudo chown ")
-		chownCmd.WriteString(ownerStr)
-
+		ownership := ownerStr
 		if groupStr != "" {
-			chownCmd.WriteString(":")
-			chownCmd.WriteString(groupStr)
+			ownership += ":" + groupStr
 		}
 
-		chownCmd.WriteString(" ")
-		chownCmd.WriteString(plan.Path.ValueString())
-		chownCmd.WriteString(" ")
-		chownCmd.WriteString(publicKeyPath)
-
-		_, err := r.provider.machineAccessClient.RunCommand(ctx, chownCmd.String())
+		_, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("chown", ownership, plan.Path.ValueString(), publicKeyPath)))
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to set owner and group", err.Error())
 			return
@@ -178,26 +260,18 @@ func (r *sshKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 	if !plan.Mode.IsNull() && !plan.Mode.IsUnknown() {
 		modeStr := plan.Mode.ValueString()
 
-		// Build chmod command with sudo
-		var chmodCmd strings.Builder
-		chmodCmd.WriteString("sudo chmod ")
-		chmodCmd.WriteString(modeStr)
-		chmodCmd.WriteString(" ")
-		chmodCmd.WriteString(plan.Path.ValueString())
-		chmodCmd.WriteString(" ")
-		chmodCmd.WriteString(publicKeyPath)
-
-		_, err := r.provider.machineAccessClient.RunCommand(ctx, chmodCmd.String())
+		_, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("chmod", modeStr, plan.Path.ValueString(), publicKeyPath)))
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to set file mode", err.Error())
 			return
 		}
 	}
 
-	// Update the model with computed values
-	plan.KeyType = types.StringValue(keyType)
-	plan.KeySize = types.Int64Value(keySize)
-	plan.PublicKey = types.StringValue(strings.TrimSpace(publicKeyContent))
+	err = r.readKey(ctx, &plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read SSH key", err.Error())
+		return
+	}
 
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
@@ -208,6 +282,8 @@ func (r *sshKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 }
 
 func (r *sshKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
 	var model sshKeyResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -217,25 +293,59 @@ func (r *sshKeyResource) Read(ctx context.Context, req resource.ReadRequest, res
 		return
 	}
 
-	// Check if private key exists
-	_, err := r.provider.machineAccessClient.RunCommand(ctx, "test -f "+model.Path.ValueString())
+	// Check if both keys exist, test exits with 1 when a file does not exist, the other errors are failures to check it
+	for _, keyPath := range []string{model.Path.ValueString(), model.Path.ValueString() + ".pub"} {
+		_, err := r.provider.machineAccessClient.Run(ctx, clients.ShellCommand("test", "-f", keyPath))
+		if clients.IsExitCode(err, 1) {
+			// If a key doesn't exist, remove from state
+			resp.State.RemoveResource(ctx)
+			return
+		}
+
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to check the SSH key", err.Error())
+			return
+		}
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.sudoIfOwned(model, "ssh-keygen -l -E sha256 -f "+clients.ShellQuote(model.Path.ValueString())))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read SSH key properties", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+
+	properties, err := parseSSHKeygenFingerprint(out)
 	if err != nil {
-		// If private key doesn't exist, remove from state
-		resp.State.RemoveResource(ctx)
+		resp.Diagnostics.AddError("Failed to parse SSH key properties", err.Error())
 		return
 	}
 
-	// Read the public key
-	publicKeyPath := model.Path.ValueString() + ".pub"
+	// A key replaced outside of terraform by a key of another type or size is generated again by the update
+	model.KeyType = types.StringValue(properties.keyType)
+	if properties.keyType == keyTypeRSA || properties.keyType == keyTypeDSA {
+		model.KeySize = types.Int64Value(properties.bits)
+	}
+
+	// The fingerprint of the state is the one of the generated key, the actual one is compared to it while planning
+	recorded := model.FingerprintSHA256
 
-	publicKeyContent, err := r.provider.machineAccessClient.RunCommand(ctx, "cat "+publicKeyPath)
+	err = r.readKey(ctx, &model)
 	if err != nil {
-		// If public key doesn't exist, remove from state
-		resp.State.RemoveResource(ctx)
+		resp.Diagnostics.AddError("Failed to read SSH key", err.Error())
 		return
 	}
 
-	model.PublicKey = types.StringValue(strings.TrimSpace(publicKeyContent))
+	if !recorded.IsNull() {
+		model.FingerprintSHA256 = recorded
+	}
+
+	actual, err := json.Marshal(properties.fingerprint)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to store the SSH key fingerprint", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, actualFingerprintKey, actual)...)
 
 	diags = resp.State.Set(ctx, model)
 	resp.Diagnostics.Append(diags...)
@@ -246,6 +356,8 @@ func (r *sshKeyResource) Read(ctx context.Context, req resource.ReadRequest, res
 }
 
 func (r *sshKeyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
 	var plan sshKeyResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -265,63 +377,23 @@ func (r *sshKeyResource) Update(ctx context.Context, req resource.UpdateRequest,
 	}
 
 	// If path, key_type, or key_size changed, we need to regenerate the key
-	if !plan.Path.Equal(state.Path) || !plan.KeyType.Equal(state.KeyType) || !plan.KeySize.Equal(state.KeySize) {
+	if needsRegeneration(plan, state) {
 		// Delete old keys first (use sudo if owner was set)
-		var deleteCmd string
-		if !state.Owner.IsNull() && !state.Owner.IsUnknown() {
-			deleteCmd = "sudo rm -f " + state.Path.ValueString() + " " + state.Path.ValueString() + ".pub"
-		} else {
-			deleteCmd = "rm -f " + state.Path.ValueString() + " " + state.Path.ValueString() + ".pub"
-		}
+		deleteCmd := r.sudoIfOwned(state, clients.ShellCommand("rm", "-f", state.Path.ValueString(), state.Path.ValueString()+".pub"))
 
 		_, _ = r.provider.machineAccessClient.RunCommand(ctx, deleteCmd)
 
-		// Set defaults for new key
-		keyType := keyTypeRSA
-		if !plan.KeyType.IsNull() && !plan.KeyType.IsUnknown() {
-			keyType = plan.KeyType.ValueString()
-		}
-
-		keySize := int64(2048)
-		if !plan.KeySize.IsNull() && !plan.KeySize.IsUnknown() {
-			keySize = plan.KeySize.ValueInt64()
-		}
-
-		// Build ssh-keygen command
-		var cmd strings.Builder
-
-		cmd.WriteString("ssh-keygen -t ")
-		cmd.WriteString(keyType)
-
-		// Only add key size for RSA and DSA keys
-		if keyType == keyTypeRSA || keyType == keyTypeDSA {
-			cmd.WriteString(fmt.Sprintf(" -b %d", keySize))
-		}
-
-		cmd.WriteString(" -f ")
-		cmd.WriteString(plan.Path.ValueString())
-		cmd.WriteString(" -N ''") // No passphrase
-
-		// Generate the SSH key
-		_, err := r.provider.machineAccessClient.RunCommand(ctx, cmd.String())
+		err := r.generate(ctx, &plan)
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to generate SSH key", err.Error())
 			return
 		}
-
-		// Read the public key
-		publicKeyPath := plan.Path.ValueString() + ".pub"
-
-		publicKeyContent, err := r.provider.machineAccessClient.RunCommand(ctx, "cat "+publicKeyPath)
+	} else if !plan.Passphrase.Equal(state.Passphrase) || !plan.Comment.Equal(state.Comment) {
+		err := r.updateKey(ctx, &plan, state)
 		if err != nil {
-			resp.Diagnostics.AddError("Failed to read public key", err.Error())
+			resp.Diagnostics.AddError("Failed to update SSH key", err.Error())
 			return
 		}
-
-		// Update the model with computed values
-		plan.KeyType = types.StringValue(keyType)
-		plan.KeySize = types.Int64Value(keySize)
-		plan.PublicKey = types.StringValue(strings.TrimSpace(publicKeyContent))
 	}
 
 	// If owner or group changed, update the ownership
@@ -334,22 +406,12 @@ func (r *sshKeyResource) Update(ctx context.Context, req resource.UpdateRequest,
 				groupStr = plan.Group.ValueString()
 			}
 
-			// Build chown command with sudo
-			var chownCmd strings.Builder
-			chownCmd.WriteString("sudo chown ")
-			chownCmd.WriteString(ownerStr)
-
+			ownership := ownerStr
 			if groupStr != "" {
-				chownCmd.WriteString(":")
-				chownCmd.WriteString(groupStr)
+				ownership += ":" + groupStr
 			}
 
-			chownCmd.WriteString(" ")
-			chownCmd.WriteString(plan.Path.ValueString())
-			chownCmd.WriteString(" ")
-			chownCmd.WriteString(plan.Path.ValueString() + ".pub")
-
-			_, err := r.provider.machineAccessClient.RunCommand(ctx, chownCmd.String())
+			_, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("chown", ownership, plan.Path.ValueString(), plan.Path.ValueString()+".pub")))
 			if err != nil {
 				resp.Diagnostics.AddError("Failed to set owner and group", err.Error())
 				return
@@ -362,16 +424,7 @@ func (r *sshKeyResource) Update(ctx context.Context, req resource.UpdateRequest,
 		if !plan.Mode.IsNull() && !plan.Mode.IsUnknown() {
 			modeStr := plan.Mode.ValueString()
 
-			// Build chmod command with sudo
-			var chmodCmd strings.Builder
-			chmodCmd.WriteString("sudo chmod ")
-			chmodCmd.WriteString(modeStr)
-			chmodCmd.WriteString(" ")
-			chmodCmd.WriteString(plan.Path.ValueString())
-			chmodCmd.WriteString(" ")
-			chmodCmd.WriteString(plan.Path.ValueString() + ".pub")
-
-			_, err := r.provider.machineAccessClient.RunCommand(ctx, chmodCmd.String())
+			_, err := r.provider.machineAccessClient.Run(ctx, r.provider.sudo(clients.ShellCommand("chmod", modeStr, plan.Path.ValueString(), plan.Path.ValueString()+".pub")))
 			if err != nil {
 				resp.Diagnostics.AddError("Failed to set file mode", err.Error())
 				return
@@ -379,6 +432,12 @@ func (r *sshKeyResource) Update(ctx context.Context, req resource.UpdateRequest,
 		}
 	}
 
+	err := r.readKey(ctx, &plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read SSH key", err.Error())
+		return
+	}
+
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 
@@ -388,6 +447,8 @@ func (r *sshKeyResource) Update(ctx context.Context, req resource.UpdateRequest,
 }
 
 func (r *sshKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
 	var model sshKeyResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -399,14 +460,9 @@ func (r *sshKeyResource) Delete(ctx context.Context, req resource.DeleteRequest,
 
 	// Delete both private and public key files
 	// Use sudo if owner is not the current user
-	var deleteCmd string
-	if !model.Owner.IsNull() && !model.Owner.IsUnknown() {
-		deleteCmd = "sudo rm -f " + model.Path.ValueString() + " " + model.Path.ValueString() + ".pub"
-	} else {
-		deleteCmd = "rm -f " + model.Path.ValueString() + " " + model.Path.ValueString() + ".pub"
-	}
+	deleteCmd := r.sudoIfOwned(model, clients.ShellCommand("rm", "-f", model.Path.ValueString(), model.Path.ValueString()+".pub"))
 
-	_, err := r.provider.machineAccessClient.RunCommand(ctx, deleteCmd)
+	_, err := r.provider.machineAccessClient.Run(ctx, deleteCmd)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to delete SSH key", err.Error())
 		return
@@ -415,4 +471,162 @@ func (r *sshKeyResource) Delete(ctx context.Context, req resource.DeleteRequest,
 
 func (r *sshKeyResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
 	resource.ImportStatePassthroughID(ctx, path.Root("path"), req, resp)
+
+	// The type, the size and the public key of the imported key are read from the machine
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("allow_overwrite"), false)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("export_private_key"), false)...)
+}
+
+// needsRegeneration returns whether the planned key differs from the existing key, so that it must be generated again.
+func needsRegeneration(plan sshKeyResourceModel, state sshKeyResourceModel) bool {
+	return !plan.Path.Equal(state.Path) || !plan.KeyType.Equal(state.KeyType) || !plan.KeySize.Equal(state.KeySize)
+}
+
+// generate runs ssh-keygen to create the key of the model, and sets the computed values of the model.
+func (r *sshKeyResource) generate(ctx context.Context, model *sshKeyResourceModel) error {
+	keyType := keyTypeRSA
+	if !model.KeyType.IsNull() && !model.KeyType.IsUnknown() {
+		keyType = model.KeyType.ValueString()
+	}
+
+	keySize := int64(2048)
+	if !model.KeySize.IsNull() && !model.KeySize.IsUnknown() {
+		keySize = model.KeySize.ValueInt64()
+	}
+
+	keyPath := clients.ShellQuote(model.Path.ValueString())
+
+	ctx = clients.WithSensitiveValues(ctx, model.Passphrase.ValueString())
+
+	// ssh-keygen asks whether to overwrite an existing key, which fails without a terminal
+	var cmd strings.Builder
+
+	if model.AllowOverwrite.ValueBool() {
+		cmd.WriteString(fmt.Sprintf("rm -f %s %s && ", keyPath, clients.ShellQuote(model.Path.ValueString()+".pub")))
+	} else {
+		cmd.WriteString(fmt.Sprintf("if [ -e %s ]; then echo 'the key already exists, set allow_overwrite to replace it' >&2; exit 1; fi; ", keyPath))
+	}
+
+	cmd.WriteString("ssh-keygen -q -t ")
+	cmd.WriteString(keyType)
+
+	// Only add key size for RSA and DSA keys
+	if keyType == keyTypeRSA || keyType == keyTypeDSA {
+		cmd.WriteString(fmt.Sprintf(" -b %d", keySize))
+	}
+
+	cmd.WriteString(" -f ")
+	cmd.WriteString(keyPath)
+	cmd.WriteString(" -N ")
+	cmd.WriteString(clients.ShellQuote(model.Passphrase.ValueString()))
+
+	if !model.Comment.IsNull() {
+		cmd.WriteString(" -C ")
+		cmd.WriteString(clients.ShellQuote(model.Comment.ValueString()))
+	}
+
+	result, err := r.provider.machineAccessClient.Run(ctx, cmd.String())
+	if err != nil {
+		return fmt.Errorf("ssh-keygen failed. Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+	}
+
+	model.KeyType = types.StringValue(keyType)
+	model.KeySize = types.Int64Value(keySize)
+
+	return nil
+}
+
+// updateKey changes the passphrase and the comment of the existing key.
+func (r *sshKeyResource) updateKey(ctx context.Context, plan *sshKeyResourceModel, state sshKeyResourceModel) error {
+	keyPath := clients.ShellQuote(plan.Path.ValueString())
+	passphrase := state.Passphrase.ValueString()
+
+	ctx = clients.WithSensitiveValues(ctx, passphrase, plan.Passphrase.ValueString())
+
+	if !plan.Passphrase.Equal(state.Passphrase) {
+		result, err := r.provider.machineAccessClient.Run(ctx, r.sudoIfOwned(*plan, fmt.Sprintf("ssh-keygen -q -p -f %s -P %s -N %s",
+			keyPath, clients.ShellQuote(passphrase), clients.ShellQuote(plan.Passphrase.ValueString()))))
+		if err != nil {
+			return fmt.Errorf("failed to change the passphrase. Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+		}
+
+		passphrase = plan.Passphrase.ValueString()
+	}
+
+	if !plan.Comment.Equal(state.Comment) && !plan.Comment.IsNull() {
+		// -c rewrites both the private and the public key
+		result, err := r.provider.machineAccessClient.Run(ctx, r.sudoIfOwned(*plan, fmt.Sprintf("ssh-keygen -q -c -f %s -P %s -C %s",
+			keyPath, clients.ShellQuote(passphrase), clients.ShellQuote(plan.Comment.ValueString()))))
+		if err != nil {
+			return fmt.Errorf("failed to change the comment. Err=%w\nout = %s", err, result.Stdout+result.Stderr)
+		}
+	}
+
+	return nil
+}
+
+// readKey sets the public key, its fingerprint and, when exported, the private key of the model from the key files.
+func (r *sshKeyResource) readKey(ctx context.Context, model *sshKeyResourceModel) error {
+	publicKeyContent, err := r.provider.machineAccessClient.RunCommand(ctx, r.sudoIfOwned(*model, "cat "+clients.ShellQuote(model.Path.ValueString()+".pub")))
+	if err != nil {
+		return fmt.Errorf("failed to read public key. Err=%w\nout = %s", err, publicKeyContent)
+	}
+
+	model.PublicKey = types.StringValue(strings.TrimSpace(publicKeyContent))
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKeyContent))
+	if err != nil {
+		return fmt.Errorf("failed to parse public key %s: %w", publicKeyContent, err)
+	}
+
+	model.FingerprintSHA256 = types.StringValue(ssh.FingerprintSHA256(publicKey))
+
+	model.PrivateKey = types.StringNull()
+	if model.ExportPrivateKey.ValueBool() {
+		privateKeyContent, err := r.provider.machineAccessClient.RunCommand(ctx, r.sudoIfOwned(*model, "cat "+clients.ShellQuote(model.Path.ValueString())))
+		if err != nil {
+			// The output is not included, as it would be the private key if only the command failed
+			return fmt.Errorf("failed to read private key: %w", err)
+		}
+
+		model.PrivateKey = types.StringValue(privateKeyContent)
+	}
+
+	return nil
+}
+
+// sudoIfOwned returns the command to run with elevated privileges when the key files are owned by another user.
+func (r *sshKeyResource) sudoIfOwned(model sshKeyResourceModel, command string) string {
+	if !model.Owner.IsNull() && !model.Owner.IsUnknown() {
+		return r.provider.sudo(command)
+	}
+
+	return command
+}
+
+type sshKeyProperties struct {
+	bits        int64
+	fingerprint string
+	keyType     string
+}
+
+// parseSSHKeygenFingerprint parses the output of ssh-keygen -l, e.g. '256 SHA256:nThbg6kX... user@host (ED25519)'.
+func parseSSHKeygenFingerprint(out string) (sshKeyProperties, error) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return sshKeyProperties{}, fmt.Errorf("unexpected ssh-keygen output: %s", out)
+	}
+
+	bits, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return sshKeyProperties{}, fmt.Errorf("failed to parse the size of the key in %s: %w", out, err)
+	}
+
+	keyType := strings.ToLower(strings.Trim(fields[len(fields)-1], "()"))
+
+	return sshKeyProperties{
+		bits:        bits,
+		fingerprint: fields[1],
+		keyType:     keyType,
+	}, nil
 }
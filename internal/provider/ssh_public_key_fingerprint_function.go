@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ function.Function = &sshPublicKeyFingerprintFunction{}
+
+func newSSHPublicKeyFingerprintFunction() function.Function {
+	return &sshPublicKeyFingerprintFunction{}
+}
+
+type sshPublicKeyFingerprintFunction struct{}
+
+func (f *sshPublicKeyFingerprintFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "ssh_public_key_fingerprint"
+}
+
+func (f *sshPublicKeyFingerprintFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Returns the SHA256 fingerprint of an SSH public key",
+		Description: "Returns the SHA256 fingerprint of the public key, e.g. 'SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8', " +
+			"as shown by ssh-keygen -l and as the fingerprint_sha256 of setup_ssh_key, failing on an invalid key. The key is " +
+			"given in the format of authorized_keys, with optional options and comment",
+
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "public_key",
+				Description: "The public key, e.g. 'ssh-ed25519 AAAA... user@host'",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *sshPublicKeyFingerprintFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var publicKey string
+
+	resp.Error = req.Arguments.Get(ctx, &publicKey)
+	if resp.Error != nil {
+		return
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("failed to parse the public key: %v", err))
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, ssh.FingerprintSHA256(key))
+}
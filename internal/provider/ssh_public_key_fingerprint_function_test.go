@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestSSHPublicKeyFingerprintFunction(t *testing.T) {
+	t.Run("returns the fingerprint of an authorized key", func(t *testing.T) {
+		// Act
+		fingerprint, err := testFunctionRun(t, newSSHPublicKeyFingerprintFunction(), types.StringValue(`from="10.0.0.0/8" `+testSSHPublicKey+" deploy's key\n"))
+
+		// Assert
+		if err != nil || fingerprint != "SHA256:nt7ghddAza+fXhYx8pdL9eXsgj9orIXWwbIBtC8keWk" {
+			t.Fatalf("unexpected fingerprint: %s, %v", fingerprint, err)
+		}
+	})
+
+	t.Run("rejects an invalid key", func(t *testing.T) {
+		// Act
+		_, err := testFunctionRun(t, newSSHPublicKeyFingerprintFunction(), types.StringValue("ssh-ed25519 not-base64"))
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,489 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	structuredFormatJSON = "json"
+	structuredFormatYAML = "yaml"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &structuredPatchResource{}
+var _ resource.ResourceWithModifyPlan = &structuredPatchResource{}
+
+func newJSONPatchResource(p *internalProvider) resource.Resource {
+	return &structuredPatchResource{
+		provider: p,
+		format:   structuredFormatJSON,
+	}
+}
+
+func newYAMLPatchResource(p *internalProvider) resource.Resource {
+	return &structuredPatchResource{
+		provider: p,
+		format:   structuredFormatYAML,
+	}
+}
+
+// structuredPatchResource defines the resource implementation, shared by the JSON and YAML files.
+type structuredPatchResource struct {
+	provider *internalProvider
+	format   string
+}
+
+type structuredPatchResourceModel struct {
+	Path   types.String `tfsdk:"path"`
+	Patch  types.String `tfsdk:"patch"`
+	Create types.Bool   `tfsdk:"create"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *structuredPatchResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_" + r.format + "_patch"
+}
+
+func (r *structuredPatchResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	format := strings.ToUpper(r.format)
+
+	resp.Schema = schema.Schema{
+		MarkdownDescription: format + " patch resource that merges a JSON merge patch (RFC 7386) into a " + format + " file, " +
+			"e.g. /etc/docker/daemon.json or a netplan configuration. The file is serialized again with sorted keys, so " +
+			"its comments and formatting are not kept. Only the keys of the patch are checked for drift. Destroying the " +
+			"resource leaves the file as it is",
+
+		Attributes: map[string]schema.Attribute{
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the " + format + " file",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"patch": schema.StringAttribute{
+				Required: true,
+				Description: "The JSON object merged into the file, usually built with jsonencode. Nested objects are merged " +
+					"key by key, other values replace the existing ones, and null removes a key",
+			},
+			"create": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether a missing file is created from the patch, with mode 644 and owned by the connecting user. Defaults to false",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *structuredPatchResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *structuredPatchResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan structuredPatchResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() || plan.Patch.IsUnknown() {
+		return
+	}
+
+	_, err := parseMergePatch(plan.Patch.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("patch"), "Invalid patch", err.Error())
+	}
+}
+
+func (r *structuredPatchResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan structuredPatchResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to patch file", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *structuredPatchResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model structuredPatchResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, model.Path.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The file was removed outside of terraform, it must be patched again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read file", err.Error())
+		return
+	}
+
+	document, err := decodeStructured(r.format, content)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse file", err.Error())
+		return
+	}
+
+	patch, err := parseMergePatch(model.Patch.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid patch", err.Error())
+		return
+	}
+
+	// Only the keys of the patch are compared, so that the other keys of the file may change freely
+	actual, err := canonicalJSON(patchedSubset(document, patch))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to serialize file", err.Error())
+		return
+	}
+
+	expected, err := canonicalJSON(patch)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to serialize patch", err.Error())
+		return
+	}
+
+	if actual != expected {
+		model.Patch = types.StringValue(actual)
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *structuredPatchResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan structuredPatchResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := r.apply(ctx, plan)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to patch file", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *structuredPatchResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+
+}
+
+// apply merges the patch into the file.
+func (r *structuredPatchResource) apply(ctx context.Context, plan structuredPatchResourceModel) error {
+	patch, err := parseMergePatch(plan.Patch.ValueString())
+	if err != nil {
+		return err
+	}
+
+	_, err = r.provider.patchStructuredFile(ctx, r.format, plan.Path.ValueString(), plan.Create.ValueBool(), patch)
+
+	return err
+}
+
+// patchStructuredFile merges the patch into the JSON or YAML file, which is only written when its serialization
+// changes. It returns whether the content changed, rather than only its formatting.
+func (p *internalProvider) patchStructuredFile(ctx context.Context, format string, filePath string, create bool, patch map[string]interface{}) (bool, error) {
+	var editErr error
+
+	changed := false
+
+	err := p.editFile(ctx, filePath, create, func(lines []string) ([]string, bool) {
+		content := joinLines(lines)
+
+		document, err := decodeStructured(format, content)
+		if err != nil {
+			editErr = err
+			return lines, false
+		}
+
+		before, err := canonicalJSON(document)
+		if err != nil {
+			editErr = err
+			return lines, false
+		}
+
+		merged := mergePatch(document, patch)
+
+		after, err := canonicalJSON(merged)
+		if err != nil {
+			editErr = err
+			return lines, false
+		}
+
+		patched, err := encodeStructured(format, merged)
+		if err != nil {
+			editErr = err
+			return lines, false
+		}
+
+		if patched == content {
+			return lines, false
+		}
+
+		changed = before != after
+
+		return strings.Split(strings.TrimSuffix(patched, "\n"), "\n"), true
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return changed, editErr
+}
+
+// parseMergePatch parses the patch, which must be a JSON object. Numbers are kept as written.
+func parseMergePatch(patch string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(patch))
+	decoder.UseNumber()
+
+	var parsed interface{}
+
+	err := decoder.Decode(&parsed)
+	if err != nil {
+		return nil, fmt.Errorf("the patch must be valid JSON: %w", err)
+	}
+
+	object, ok := parsed.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the patch must be a JSON object")
+	}
+
+	return object, nil
+}
+
+// mergePatch returns the target with the patch applied, as defined by RFC 7386. The target is not modified.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	merged := map[string]interface{}{}
+	if targetObject, ok := target.(map[string]interface{}); ok {
+		for key, value := range targetObject {
+			merged[key] = value
+		}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		merged[key] = mergePatch(merged[key], value)
+	}
+
+	return merged
+}
+
+// patchedSubset returns the values of the document at the keys of the patch, with null for the missing keys, so that
+// it equals the patch when the patch is applied.
+func patchedSubset(document interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return document
+	}
+
+	documentObject, _ := document.(map[string]interface{})
+	subset := map[string]interface{}{}
+
+	for key, value := range patchObject {
+		existing, found := documentObject[key]
+
+		switch {
+		case !found:
+			subset[key] = nil
+		case value == nil:
+			subset[key] = existing
+		default:
+			subset[key] = patchedSubset(existing, value)
+		}
+	}
+
+	return subset
+}
+
+// canonicalJSON returns the compact JSON of the value with sorted keys, whatever the types of its numbers.
+func canonicalJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	var normalized interface{}
+
+	err = json.Unmarshal(encoded, &normalized)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err = json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// decodeStructured parses the content of a JSON or YAML file. An empty file is an empty document.
+func decodeStructured(format string, content string) (interface{}, error) {
+	if strings.TrimSpace(content) == "" {
+		return map[string]interface{}{}, nil
+	}
+
+	var document interface{}
+
+	if format == structuredFormatYAML {
+		err := yaml.Unmarshal([]byte(content), &document)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+
+		return document, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	return document, nil
+}
+
+// encodeStructured serializes the document with sorted keys, indented with 2 spaces for JSON.
+func encodeStructured(format string, document interface{}) (string, error) {
+	if format == structuredFormatYAML {
+		buffer := bytes.Buffer{}
+
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+
+		err := encoder.Encode(yamlNumbers(document))
+		if err != nil {
+			return "", err
+		}
+
+		return buffer.String(), nil
+	}
+
+	buffer := bytes.Buffer{}
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(document)
+	if err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+// yamlNumbers returns the document with the JSON numbers of the patch converted to integers or floats, which would
+// otherwise be written as YAML strings.
+func yamlNumbers(document interface{}) interface{} {
+	switch value := document.(type) {
+	case json.Number:
+		if integer, err := value.Int64(); err == nil {
+			return integer
+		}
+
+		if float, err := value.Float64(); err == nil {
+			return float
+		}
+
+		return value.String()
+	case map[string]interface{}:
+		converted := map[string]interface{}{}
+		for key, item := range value {
+			converted[key] = yamlNumbers(item)
+		}
+
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			converted[i] = yamlNumbers(item)
+		}
+
+		return converted
+	default:
+		return document
+	}
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestJSONPatchResource(t *testing.T) {
+	t.Run("Test patch a JSON file", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+
+		sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sshClient.RunCommand(context.Background(), `echo '{"debug": true, "log-driver": "syslog"}' > /tmp/daemon.json`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + `
+resource "setup_json_patch" "test" {
+  path  = "/tmp/daemon.json"
+  patch = jsonencode({
+    "log-driver" = "json-file"
+    "log-opts"   = { "max-size" = "10m" }
+  })
+}
+`,
+					Check: func(_ *terraform.State) error {
+						content, err := sshClient.RunCommand(context.Background(), "cat /tmp/daemon.json")
+						if err != nil {
+							return fmt.Errorf("file not found")
+						}
+
+						expected := "{\n  \"debug\": true,\n  \"log-driver\": \"json-file\",\n  \"log-opts\": {\n    \"max-size\": \"10m\"\n  }\n}\n"
+						if content != expected {
+							return fmt.Errorf("unexpected content: %s", content)
+						}
+
+						return nil
+					},
+				},
+			},
+		})
+	})
+}
+
+func TestStructuredPatchResourceWithMock(t *testing.T) {
+	t.Run("create merges the patch into a YAML file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/netplan/50-cloud-init.yaml", "network:\n  version: 2\n  ethernets:\n    eth0:\n      dhcp4: true\n", clients.FileInfo{Mode: "600"})
+
+		// Act
+		_, diags := testResourceCreate(t, newYAMLPatchResource(newTestProvider(mock)), structuredPatchResourceModel{
+			Path:   types.StringValue("/etc/netplan/50-cloud-init.yaml"),
+			Patch:  types.StringValue(`{"network":{"ethernets":{"eth0":{"dhcp4":false,"addresses":["10.0.0.2/24"],"mtu":9000}}}}`),
+			Create: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "network:\n  ethernets:\n    eth0:\n      addresses:\n        - 10.0.0.2/24\n      dhcp4: false\n      mtu: 9000\n  version: 2\n"
+		if mock.Files["/etc/netplan/50-cloud-init.yaml"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/netplan/50-cloud-init.yaml"])
+		}
+
+		if mock.FileInfos["/etc/netplan/50-cloud-init.yaml"].Mode != "600" {
+			t.Fatalf("unexpected mode: %s", mock.FileInfos["/etc/netplan/50-cloud-init.yaml"].Mode)
+		}
+	})
+
+	t.Run("create removes the keys set to null and creates the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("id -u && id -g", clients.MockResponse{Stdout: "0\n0\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newJSONPatchResource(newTestProvider(mock)), structuredPatchResourceModel{
+			Path:   types.StringValue("/etc/docker/daemon.json"),
+			Patch:  types.StringValue(`{"data-root":"/data/docker","debug":null}`),
+			Create: types.BoolValue(true),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/docker/daemon.json"] != "{\n  \"data-root\": \"/data/docker\"\n}\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/docker/daemon.json"])
+		}
+	})
+
+	t.Run("create fails on a file that is not JSON", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", "not json", clients.FileInfo{Mode: "644"})
+
+		// Act
+		_, diags := testResourceCreate(t, newJSONPatchResource(newTestProvider(mock)), structuredPatchResourceModel{
+			Path:   types.StringValue("/etc/docker/daemon.json"),
+			Patch:  types.StringValue(`{"debug":true}`),
+			Create: types.BoolValue(false),
+		})
+
+		// Assert
+		if !diags.HasError() || mock.Files["/etc/docker/daemon.json"] != "not json" {
+			t.Fatalf("expected an error without the file written, got: %v, %q", diags, mock.Files["/etc/docker/daemon.json"])
+		}
+	})
+
+	t.Run("read ignores the keys that are not patched", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/docker/daemon.json", `{"debug":true,"log-opts":{"max-size":"10m","max-file":"3"},"other":1}`, clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newJSONPatchResource(newTestProvider(mock)), structuredPatchResourceModel{
+			Path:   types.StringValue("/etc/docker/daemon.json"),
+			Patch:  types.StringValue(`{"log-opts": {"max-size": "10m"}, "insecure-registries": null}`),
+			Create: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Patch.ValueString() != `{"log-opts": {"max-size": "10m"}, "insecure-registries": null}` {
+			t.Fatalf("unexpected patch: %s", state.Patch)
+		}
+	})
+
+	t.Run("read reports the drift of the patched keys", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/app.yaml", "replicas: 5\nname: app\n", clients.FileInfo{Mode: "644"})
+
+		// Act
+		state, _, diags := testResourceRead(t, newYAMLPatchResource(newTestProvider(mock)), structuredPatchResourceModel{
+			Path:   types.StringValue("/etc/app.yaml"),
+			Patch:  types.StringValue(`{"replicas":3,"debug":false}`),
+			Create: types.BoolValue(false),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Patch.ValueString() != `{"debug":null,"replicas":5}` {
+			t.Fatalf("unexpected patch: %s", state.Patch)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// timeoutsModel is the model of the timeouts block of long-running resources.
+type timeoutsModel struct {
+	Create types.String `tfsdk:"create"`
+	Update types.String `tfsdk:"update"`
+	Delete types.String `tfsdk:"delete"`
+}
+
+// timeoutsBlock returns the schema of the timeouts block.
+func timeoutsBlock() schema.Block {
+	return schema.SingleNestedBlock{
+		Description: "Maximum durations of the operations (e.g. '30m'). Remote commands still running when the timeout expires are killed",
+		Attributes: map[string]schema.Attribute{
+			"create": schema.StringAttribute{
+				Optional:    true,
+				Description: "Timeout of the creation",
+			},
+			"update": schema.StringAttribute{
+				Optional:    true,
+				Description: "Timeout of an update",
+			},
+			"delete": schema.StringAttribute{
+				Optional:    true,
+				Description: "Timeout of the deletion",
+			},
+		},
+	}
+}
+
+func (t *timeoutsModel) create() types.String {
+	if t == nil {
+		return types.StringNull()
+	}
+
+	return t.Create
+}
+
+func (t *timeoutsModel) update() types.String {
+	if t == nil {
+		return types.StringNull()
+	}
+
+	return t.Update
+}
+
+func (t *timeoutsModel) delete() types.String {
+	if t == nil {
+		return types.StringNull()
+	}
+
+	return t.Delete
+}
+
+// withTimeout returns a context that expires after the given duration. The context is returned as is when no timeout
+// is set.
+func withTimeout(ctx context.Context, timeout types.String) (context.Context, context.CancelFunc, error) {
+	if timeout.IsNull() || timeout.IsUnknown() || timeout.ValueString() == "" {
+		return ctx, func() {}, nil
+	}
+
+	duration, err := time.ParseDuration(timeout.ValueString())
+	if err != nil {
+		return ctx, func() {}, fmt.Errorf("failed to parse timeout '%s': %w", timeout.ValueString(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+
+	return ctx, cancel, nil
+}
@@ -0,0 +1,476 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &tlsCertificateResource{}
+var _ resource.ResourceWithModifyPlan = &tlsCertificateResource{}
+var _ resource.ResourceWithUpgradeState = &tlsCertificateResource{}
+
+func newTLSCertificateResource(p *internalProvider) resource.Resource {
+	return &tlsCertificateResource{
+		provider: p,
+	}
+}
+
+// tlsCertificateResource defines the resource implementation.
+type tlsCertificateResource struct {
+	provider *internalProvider
+}
+
+type tlsCertificateResourceModel struct {
+	Certificate     types.String `tfsdk:"certificate"`
+	PrivateKey      types.String `tfsdk:"private_key"`
+	Chain           types.String `tfsdk:"chain"`
+	CertificatePath types.String `tfsdk:"certificate_path"`
+	PrivateKeyPath  types.String `tfsdk:"private_key_path"`
+	ChainPath       types.String `tfsdk:"chain_path"`
+	CertificateMode types.String `tfsdk:"certificate_mode"`
+	PrivateKeyMode  types.String `tfsdk:"private_key_mode"`
+	Owner           types.String `tfsdk:"owner"`
+	Group           types.String `tfsdk:"group"`
+	ReloadService   types.String `tfsdk:"reload_service"`
+	NotAfter        types.String `tfsdk:"not_after"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *tlsCertificateResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_tls_certificate"
+}
+
+func (r *tlsCertificateResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "TLS certificate resource that deploys a certificate, its private key and optionally its chain. " +
+			"The certificate must match the private key. Each file is replaced atomically, and the service given by " +
+			"reload_service is reloaded whenever the files are rotated",
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"certificate": schema.StringAttribute{
+				Required:    true,
+				Description: "The PEM encoded certificate",
+			},
+			"private_key": schema.StringAttribute{
+				Required:    true,
+				Sensitive:   true,
+				Description: "The PEM encoded private key of the certificate",
+			},
+			"chain": schema.StringAttribute{
+				Optional:    true,
+				Description: "The PEM encoded intermediate certificates. Requires chain_path",
+			},
+			"certificate_path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the certificate file, e.g. /etc/ssl/certs/example.com.pem",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"private_key_path": schema.StringAttribute{
+				Required:    true,
+				Description: "The path of the private key file, e.g. /etc/ssl/private/example.com.key",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"chain_path": schema.StringAttribute{
+				Optional:    true,
+				Description: "The path of the chain file. Requires chain",
+				Validators:  []validator.String{absolutePath()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"certificate_mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("644"),
+				Description: "The mode of the certificate and chain files. Defaults to 644",
+			},
+			"private_key_mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("600"),
+				Description: "The mode of the private key file. Defaults to 600",
+			},
+			"owner": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("0"),
+				Description: "The owner of the files, a user name or a numeric UID. Defaults to 0",
+				Validators:  []validator.String{accountName()},
+			},
+			"group": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("0"),
+				Description: "The group of the files, a group name or a numeric GID, e.g. ssl-cert to let a service read the private key with mode 640. Defaults to 0",
+				Validators:  []validator.String{accountName()},
+			},
+			"reload_service": schema.StringAttribute{
+				Optional:    true,
+				Description: "The systemd service reloaded after the files are written, e.g. nginx. A service that is not running is left stopped",
+			},
+			"not_after": schema.StringAttribute{
+				Computed:    true,
+				Description: "The expiration date of the certificate, in RFC 3339 format",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *tlsCertificateResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *tlsCertificateResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan tlsCertificateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Chain.IsNull() != plan.ChainPath.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("chain_path"), "Invalid chain", "chain and chain_path must be set together")
+		return
+	}
+
+	// The certificate may come from another resource and only be known while applying
+	if plan.Certificate.IsUnknown() || plan.PrivateKey.IsUnknown() || plan.Chain.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("not_after"), types.StringUnknown())...)
+		return
+	}
+
+	notAfter, err := validateTLSCertificate(plan.Certificate.ValueString(), plan.PrivateKey.ValueString(), plan.Chain.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("certificate"), "Invalid certificate", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("not_after"), types.StringValue(notAfter))...)
+}
+
+func (r *tlsCertificateResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan tlsCertificateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, true, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *tlsCertificateResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model tlsCertificateResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	certificate, err := r.provider.machineAccessClient.ReadFile(ctx, model.CertificatePath.ValueString(), true)
+	if clients.IsFileNotFound(err) {
+		// The certificate was removed outside of terraform, it must be deployed again
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read certificate file", err.Error())
+		return
+	}
+
+	privateKey, err := r.readOptionalFile(ctx, model.PrivateKeyPath.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read private key file", err.Error())
+		return
+	}
+
+	model.Certificate = types.StringValue(certificate)
+	model.PrivateKey = types.StringValue(privateKey)
+
+	if !model.ChainPath.IsNull() {
+		chain, err := r.readOptionalFile(ctx, model.ChainPath.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read chain file", err.Error())
+			return
+		}
+
+		model.Chain = types.StringValue(chain)
+	}
+
+	model.NotAfter = types.StringNull()
+	if notAfter, err := certificateNotAfter(certificate); err == nil {
+		model.NotAfter = types.StringValue(notAfter)
+	}
+
+	info, err := r.provider.machineAccessClient.Stat(ctx, model.CertificatePath.ValueString(), true)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stat certificate file", err.Error())
+		return
+	}
+
+	model.CertificateMode = types.StringValue(info.Mode)
+	model.Owner = ownershipValue(ctx, r.provider.machineAccessClient, "passwd", model.Owner, info.Owner)
+	model.Group = ownershipValue(ctx, r.provider.machineAccessClient, "group", model.Group, info.Group)
+
+	info, err = r.provider.machineAccessClient.Stat(ctx, model.PrivateKeyPath.ValueString(), true)
+	if err == nil {
+		model.PrivateKeyMode = types.StringValue(info.Mode)
+	} else if !clients.IsFileNotFound(err) {
+		resp.Diagnostics.AddError("Failed to stat private key file", err.Error())
+		return
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *tlsCertificateResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan tlsCertificateResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state tlsCertificateResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// Only a rotation of the files reloads the service, not a change of their modes or ownership
+	rotated := !plan.Certificate.Equal(state.Certificate) || !plan.PrivateKey.Equal(state.PrivateKey) || !plan.Chain.Equal(state.Chain)
+
+	r.apply(ctx, &plan, rotated, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *tlsCertificateResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model tlsCertificateResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	paths := []string{model.CertificatePath.ValueString(), model.PrivateKeyPath.ValueString()}
+	if !model.ChainPath.IsNull() {
+		paths = append(paths, model.ChainPath.ValueString())
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", append([]string{"-f"}, paths...)...)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove certificate files", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+func (r *tlsCertificateResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: ownershipStateUpgrader(),
+	}
+}
+
+// apply validates the certificate and writes the files. The service is reloaded when reload is set.
+func (r *tlsCertificateResource) apply(ctx context.Context, plan *tlsCertificateResourceModel, reload bool, diags *diag.Diagnostics) {
+	notAfter, err := validateTLSCertificate(plan.Certificate.ValueString(), plan.PrivateKey.ValueString(), plan.Chain.ValueString())
+	if err != nil {
+		diags.AddError("Invalid certificate", err.Error())
+		return
+	}
+
+	plan.NotAfter = types.StringValue(notAfter)
+
+	owner, group, err := r.provider.lookupOwnership(ctx, plan.Owner, plan.Group)
+	if err != nil {
+		diags.AddError("Failed to look up the owner of the certificate files", err.Error())
+		return
+	}
+
+	// The private key is written first, so that the new certificate is never served with the previous key
+	err = r.provider.machineAccessClient.WriteFile(ctx, plan.PrivateKeyPath.ValueString(), plan.PrivateKeyMode.ValueString(), owner, group, plan.PrivateKey.ValueString())
+	if err != nil {
+		diags.AddError("Failed to write private key file", err.Error())
+		return
+	}
+
+	if !plan.ChainPath.IsNull() {
+		err = r.provider.machineAccessClient.WriteFile(ctx, plan.ChainPath.ValueString(), plan.CertificateMode.ValueString(), owner, group, plan.Chain.ValueString())
+		if err != nil {
+			diags.AddError("Failed to write chain file", err.Error())
+			return
+		}
+	}
+
+	err = r.provider.machineAccessClient.WriteFile(ctx, plan.CertificatePath.ValueString(), plan.CertificateMode.ValueString(), owner, group, plan.Certificate.ValueString())
+	if err != nil {
+		diags.AddError("Failed to write certificate file", err.Error())
+		return
+	}
+
+	if !reload || plan.ReloadService.IsNull() {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl try-reload-or-restart "+clients.ShellQuote(plan.ReloadService.ValueString())))
+	if err != nil {
+		diags.AddError("Failed to reload service", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+// readOptionalFile returns the content of the file, or an empty string when it does not exist.
+func (r *tlsCertificateResource) readOptionalFile(ctx context.Context, filePath string) (string, error) {
+	content, err := r.provider.machineAccessClient.ReadFile(ctx, filePath, true)
+	if clients.IsFileNotFound(err) {
+		return "", nil
+	}
+
+	return content, err
+}
+
+// validateTLSCertificate checks that the certificate matches the private key and that the chain is made of
+// certificates, then returns the expiration date of the certificate.
+func validateTLSCertificate(certificate string, privateKey string, chain string) (string, error) {
+	_, err := tls.X509KeyPair([]byte(certificate), []byte(privateKey))
+	if err != nil {
+		return "", fmt.Errorf("the certificate does not match the private key: %w", err)
+	}
+
+	if strings.TrimSpace(chain) != "" {
+		err = validatePEMCertificates(chain)
+		if err != nil {
+			return "", fmt.Errorf("invalid chain: %w", err)
+		}
+	}
+
+	return certificateNotAfter(certificate)
+}
+
+// validatePEMCertificates checks that the content is only made of PEM encoded certificates, and at least one.
+func validatePEMCertificates(content string) error {
+	rest := []byte(content)
+	found := false
+
+	for {
+		block, remaining := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		rest = remaining
+
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block %s, only certificates are allowed", block.Type)
+		}
+
+		_, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate: %w", err)
+		}
+
+		found = true
+	}
+
+	if !found || strings.TrimSpace(string(rest)) != "" {
+		return errors.New("the content must only contain PEM encoded certificates")
+	}
+
+	return nil
+}
+
+// certificateNotAfter returns the expiration date of the first certificate of the PEM content.
+func certificateNotAfter(certificate string) (string, error) {
+	block, _ := pem.Decode([]byte(certificate))
+	if block == nil {
+		return "", errors.New("no PEM encoded certificate found")
+	}
+
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return parsed.NotAfter.UTC().Format(time.RFC3339), nil
+}
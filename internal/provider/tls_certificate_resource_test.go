@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestTLSCertificateResource(t *testing.T) {
+	t.Run("Test deploy a certificate and its private key", func(t *testing.T) {
+		// Arrange
+		setup := setupTestEnvironment(t)
+		certificate, privateKey := testSelfSignedCertificate(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + fmt.Sprintf(`
+resource "setup_tls_certificate" "test" {
+  certificate      = <<EOT
+%sEOT
+  private_key      = <<EOT
+%sEOT
+  certificate_path = "/tmp/test.pem"
+  private_key_path = "/tmp/test.key"
+  owner            = 1000
+  group            = 1000
+}
+`, certificate, privateKey),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_tls_certificate.test", "not_after", "2030-01-02T03:04:05Z"),
+						resource.TestCheckResourceAttr("setup_tls_certificate.test", "private_key_mode", "600"),
+						func(_ *terraform.State) error {
+							sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+							if err != nil {
+								return err
+							}
+
+							content, err := sshClient.RunCommand(context.Background(), "cat /tmp/test.pem")
+							if err != nil {
+								return fmt.Errorf("certificate file not found")
+							}
+
+							if content != certificate {
+								return fmt.Errorf("unexpected certificate content: %s", content)
+							}
+
+							return nil
+						},
+					),
+				},
+			},
+		})
+	})
+}
+
+func TestTLSCertificateResourceWithMock(t *testing.T) {
+	certificate, privateKey := testSelfSignedCertificate(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+	model := tlsCertificateResourceModel{
+		Certificate:     types.StringValue(certificate),
+		PrivateKey:      types.StringValue(privateKey),
+		Chain:           types.StringNull(),
+		CertificatePath: types.StringValue("/etc/ssl/certs/example.pem"),
+		PrivateKeyPath:  types.StringValue("/etc/ssl/private/example.key"),
+		ChainPath:       types.StringNull(),
+		CertificateMode: types.StringValue("644"),
+		PrivateKeyMode:  types.StringValue("640"),
+		Owner:           types.StringValue("0"),
+		Group:           types.StringValue("110"),
+		ReloadService:   types.StringValue("nginx"),
+		NotAfter:        types.StringUnknown(),
+	}
+
+	t.Run("create writes the files and reloads the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		state, diags := testResourceCreate(t, newTLSCertificateResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/ssl/certs/example.pem"] != certificate || mock.Files["/etc/ssl/private/example.key"] != privateKey {
+			t.Fatalf("unexpected files: %v", mock.Files)
+		}
+
+		if info := mock.FileInfos["/etc/ssl/private/example.key"]; info.Mode != "640" || info.Group != 110 {
+			t.Fatalf("unexpected private key file info: %+v", info)
+		}
+
+		if mock.Commands[len(mock.Commands)-1] != "systemctl try-reload-or-restart 'nginx'" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+
+		if state.NotAfter.ValueString() != "2030-01-02T03:04:05Z" {
+			t.Fatalf("unexpected not_after: %s", state.NotAfter)
+		}
+	})
+
+	t.Run("create fails when the private key does not match", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+		_, otherKey := testSelfSignedCertificate(t, time.Now().Add(time.Hour))
+
+		plan := model
+		plan.PrivateKey = types.StringValue(otherKey)
+
+		// Act
+		_, diags := testResourceCreate(t, newTLSCertificateResource(newTestProvider(mock)), plan)
+
+		// Assert
+		if !diags.HasError() || len(mock.Files) != 0 {
+			t.Fatalf("expected an error without any file written, got: %v, %v", diags, mock.Files)
+		}
+	})
+
+	t.Run("update of the modes does not reload the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		plan := model
+		plan.PrivateKeyMode = types.StringValue("600")
+
+		// Act
+		_, diags := testResourceUpdate(t, newTLSCertificateResource(newTestProvider(mock)), model, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		for _, command := range mock.Commands {
+			if strings.Contains(command, "systemctl") {
+				t.Fatalf("unexpected reload: %v", mock.Commands)
+			}
+		}
+	})
+
+	t.Run("read detects a certificate replaced outside of terraform", func(t *testing.T) {
+		// Arrange
+		replaced, _ := testSelfSignedCertificate(t, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC))
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/ssl/certs/example.pem", replaced, clients.FileInfo{Mode: "644", Owner: 0, Group: 0}).
+			WithFile("/etc/ssl/private/example.key", privateKey, clients.FileInfo{Mode: "640", Owner: 0, Group: 110})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newTLSCertificateResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if state.Certificate.ValueString() != replaced || state.NotAfter.ValueString() != "2031-01-01T00:00:00Z" || state.Group.ValueString() != "0" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("read removes the resource when the certificate is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newTLSCertificateResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("expected the resource to be removed: %v", diags)
+		}
+	})
+}
+
+func TestValidateTLSCertificate(t *testing.T) {
+	certificate, privateKey := testSelfSignedCertificate(t, time.Now().Add(time.Hour))
+	intermediate, _ := testSelfSignedCertificate(t, time.Now().Add(time.Hour))
+
+	t.Run("accepts a chain of certificates", func(t *testing.T) {
+		// Act
+		_, err := validateTLSCertificate(certificate, privateKey, intermediate+intermediate)
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("rejects a chain with a private key", func(t *testing.T) {
+		// Act
+		_, err := validateTLSCertificate(certificate, privateKey, intermediate+privateKey)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+// testSelfSignedCertificate generates a PEM encoded self-signed certificate expiring at notAfter, and its private key.
+func testSelfSignedCertificate(t *testing.T, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"path"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = &unixPathJoinFunction{}
+
+func newUnixPathJoinFunction() function.Function {
+	return &unixPathJoinFunction{}
+}
+
+type unixPathJoinFunction struct{}
+
+func (f *unixPathJoinFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "unix_path_join"
+}
+
+func (f *unixPathJoinFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Joins the elements of a path of the remote host with slashes",
+		Description: "Joins the elements with slashes and cleans the result, e.g. unix_path_join(\"/etc/\", \"nginx\", \"conf.d/site.conf\") " +
+			"returns '/etc/nginx/conf.d/site.conf', whatever the operating system running terraform. The empty elements are " +
+			"ignored, and the path must not be empty",
+
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "first",
+				Description: "The first element of the path, e.g. an absolute directory",
+			},
+		},
+		VariadicParameter: function.StringParameter{
+			Name:        "elements",
+			Description: "The next elements of the path",
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *unixPathJoinFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var first string
+	var elements []string
+
+	resp.Error = req.Arguments.Get(ctx, &first, &elements)
+	if resp.Error != nil {
+		return
+	}
+
+	joined := path.Join(append([]string{first}, elements...)...)
+	if joined == "" {
+		resp.Error = function.NewArgumentFuncError(0, "the path must have at least one element that is not empty")
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, joined)
+}
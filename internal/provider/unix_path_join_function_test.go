@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestUnixPathJoinFunction(t *testing.T) {
+	t.Run("joins and cleans the elements", func(t *testing.T) {
+		// Act
+		joined, err := testFunctionRun(t, newUnixPathJoinFunction(), types.StringValue("/etc/"), types.TupleValueMust(
+			[]attr.Type{types.StringType, types.StringType, types.StringType},
+			[]attr.Value{types.StringValue("nginx"), types.StringValue(""), types.StringValue("conf.d/../site.conf")},
+		))
+
+		// Assert
+		if err != nil || joined != "/etc/nginx/site.conf" {
+			t.Fatalf("unexpected path: %s, %v", joined, err)
+		}
+	})
+
+	t.Run("rejects an empty path", func(t *testing.T) {
+		// Act
+		_, err := testFunctionRun(t, newUnixPathJoinFunction(), types.StringValue(""), types.TupleValueMust([]attr.Type{}, []attr.Value{}))
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
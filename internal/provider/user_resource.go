@@ -9,10 +9,13 @@ import (
 	"strconv"
 	"strings"
 	"terraform-provider-setup/internal/provider/clients"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
@@ -36,6 +39,14 @@ type userResourceModel struct {
 	Name   types.String `tfsdk:"name"`
 	UID    types.Int64  `tfsdk:"uid"`
 	Groups types.List   `tfsdk:"groups"`
+
+	AuthorizedKeys types.List `tfsdk:"authorized_keys"`
+
+	ExpireDate     types.String `tfsdk:"expire_date"`
+	Locked         types.Bool   `tfsdk:"locked"`
+	PasswordMaxAge types.Int64  `tfsdk:"password_max_age"`
+
+	TargetHost types.String `tfsdk:"target_host"`
 }
 
 func (user *userResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -44,12 +55,14 @@ func (user *userResource) Metadata(_ context.Context, req resource.MetadataReque
 
 func (user *userResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "User resource",
+		MarkdownDescription: "User resource. It can be imported with its name, e.g. `terraform import setup_user.user alice`, " +
+			"the groups being read from the machine",
 
 		Attributes: map[string]schema.Attribute{
 			"name": schema.StringAttribute{
 				Required:    true,
 				Description: "The name of the user",
+				Validators:  []validator.String{notBlank()},
 			},
 			"uid": schema.Int64Attribute{
 				Computed:    true,
@@ -60,6 +73,30 @@ func (user *userResource) Schema(_ context.Context, _ resource.SchemaRequest, re
 				ElementType: types.Int64Type,
 				Description: "The groups the user belongs to, queried by gid",
 			},
+			"authorized_keys": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "The public keys allowed to log in as the user, e.g. 'ssh-ed25519 AAAA... alice@laptop', written to " +
+					"~/.ssh/authorized_keys. ~/.ssh is created with mode 700 and the file with mode 600, both owned by the user. " +
+					"Other keys of the file are left untouched, and the keys removed from the list are removed from the file",
+			},
+			"expire_date": schema.StringAttribute{
+				Optional: true,
+				Description: "The date on which the account is disabled, formatted as YYYY-MM-DD. The expiration date is left " +
+					"as it is when not set",
+				Validators: []validator.String{isoDate()},
+			},
+			"locked": schema.BoolAttribute{
+				Optional: true,
+				Description: "Whether the password of the user is locked (usermod -L), the user may still log in with ssh keys. " +
+					"The lock is left as it is when not set",
+			},
+			"password_max_age": schema.Int64Attribute{
+				Optional: true,
+				Description: "The maximum number of days a password may be used before it must be changed, -1 to disable " +
+					"the check. The maximum age is left as it is when not set",
+			},
+			"target_host": targetHostAttribute(),
 		},
 	}
 }
@@ -69,6 +106,8 @@ func (user *userResource) Configure(_ context.Context, _ resource.ConfigureReque
 }
 
 func (user *userResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = user.provider.mutating(ctx, user, "create", req.Plan)
+
 	var plan userResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -78,10 +117,18 @@ func (user *userResource) Create(ctx context.Context, req resource.CreateRequest
 		return
 	}
 
+	capabilities := user.provider.capabilities(ctx)
+
 	// todo: consider adding a configation for elevated actions
-	out, err := user.provider.machineAccessClient.RunCommand(ctx, "sudo useradd -ms /bin/bash "+plan.Name.String())
+	command := clients.ShellCommand("useradd", "-ms", capabilities.LoginShell(), plan.Name.ValueString())
+	if !capabilities.ShadowUtils {
+		command = clients.ShellCommand("adduser", "-D", "-s", capabilities.LoginShell(), plan.Name.ValueString())
+	}
+
+	out, err := user.provider.machineAccessClient.RunCommand(ctx, user.provider.sudo(command))
 	if err != nil {
-		if exitErr, ok := err.(clients.ExitError); ok && exitErr.ExitCode == 9 {
+		// useradd exits with 9 when the user exists, adduser of BusyBox with 1 and a message telling it is in use
+		if clients.IsExitCode(err, 9) || (!capabilities.ShadowUtils && strings.Contains(out, "in use")) {
 			tflog.Debug(ctx, "User already exists")
 		} else {
 			resp.Diagnostics.AddError("Failed to create user. Err="+err.Error()+"\nout = "+string(out), err.Error())
@@ -89,13 +136,12 @@ func (user *userResource) Create(ctx context.Context, req resource.CreateRequest
 		}
 	}
 
-	uid, err := user.getUID(ctx, plan.Name)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to get uid", err.Error())
+	plan.UID = user.uid(ctx, plan.Name, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	plan.UID = types.Int64Value(uid)
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 
@@ -105,16 +151,31 @@ func (user *userResource) Create(ctx context.Context, req resource.CreateRequest
 
 	if len(plan.Groups.Elements()) > 0 {
 		for _, group := range plan.Groups.Elements() {
-			err := user.addUserToGroup(ctx, plan.Name.String(), group.String())
+			err := user.addUserToGroup(ctx, plan.Name.ValueString(), group.String())
 			if err != nil {
 				resp.Diagnostics.AddError("Failed to add user to group", err.Error())
 				return
 			}
 		}
 	}
+
+	diags = user.applyAuthorizedKeys(ctx, plan.Name.ValueString(), plan.AuthorizedKeys, types.ListNull(types.StringType))
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err = user.applyAccountPolicy(ctx, plan, nil)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to apply the account policy", err.Error())
+		return
+	}
 }
 
 func (user *userResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = user.provider.onHost(ctx, req.State)
+
 	var model userResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -130,18 +191,39 @@ func (user *userResource) Read(ctx context.Context, req resource.ReadRequest, re
 		return
 	}
 
-	if uid != model.UID.ValueInt64() {
-		model.UID = types.Int64Value(uid)
-		diags = resp.State.Set(ctx, model)
+	model.UID = types.Int64Value(uid)
+
+	// Only the listed keys are tracked, a key removed from the file outside of terraform is added again
+	if !model.AuthorizedKeys.IsNull() {
+		model.AuthorizedKeys, diags = user.readAuthorizedKeys(ctx, model.Name.ValueString(), model.AuthorizedKeys)
 		resp.Diagnostics.Append(diags...)
 
 		if diags.HasError() {
 			return
 		}
 	}
+
+	if !model.ExpireDate.IsNull() || !model.Locked.IsNull() || !model.PasswordMaxAge.IsNull() {
+		entry, err := user.getShadowEntry(ctx, model.Name.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read the account policy", err.Error())
+			return
+		}
+
+		entry.update(&model)
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
 }
 
 func (user *userResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = user.provider.mutating(ctx, user, "update", req.Plan)
+
 	var oldModel userResourceModel
 
 	diags := req.State.Get(ctx, &oldModel)
@@ -161,7 +243,12 @@ func (user *userResource) Update(ctx context.Context, req resource.UpdateRequest
 	}
 
 	if oldModel.Name != newModel.Name {
-		_, err := user.provider.machineAccessClient.RunCommand(ctx, "sudo usermod -l "+newModel.Name.String()+" "+oldModel.Name.String())
+		if !user.provider.capabilities(ctx).ShadowUtils {
+			resp.Diagnostics.AddError("Failed to rename user", shadowUtilsRequired("usermod").Error())
+			return
+		}
+
+		_, err := user.provider.machineAccessClient.Run(ctx, user.provider.sudo(clients.ShellCommand("usermod", "-l", newModel.Name.ValueString(), oldModel.Name.ValueString())))
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to update user", err.Error())
 			return
@@ -192,7 +279,7 @@ func (user *userResource) Update(ctx context.Context, req resource.UpdateRequest
 				continue
 			}
 
-			_, err = user.provider.machineAccessClient.RunCommand(ctx, "sudo deluser "+oldModel.Name.String()+" "+groupName)
+			_, err = user.provider.machineAccessClient.Run(ctx, user.provider.sudo(clients.ShellCommand("deluser", oldModel.Name.ValueString(), groupName)))
 			if err != nil {
 				resp.Diagnostics.AddError("Failed to remove user from group", err.Error())
 				return
@@ -212,7 +299,7 @@ func (user *userResource) Update(ctx context.Context, req resource.UpdateRequest
 		}
 
 		if !found {
-			err := user.addUserToGroup(ctx, newModel.Name.String(), newGroup.String())
+			err := user.addUserToGroup(ctx, newModel.Name.ValueString(), newGroup.String())
 			if err != nil {
 				resp.Diagnostics.AddError("Failed to add user to group", err.Error())
 				return
@@ -220,13 +307,25 @@ func (user *userResource) Update(ctx context.Context, req resource.UpdateRequest
 		}
 	}
 
-	uid, err := user.getUID(ctx, newModel.Name)
+	diags = user.applyAuthorizedKeys(ctx, newModel.Name.ValueString(), newModel.AuthorizedKeys, oldModel.AuthorizedKeys)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	err := user.applyAccountPolicy(ctx, newModel, &oldModel)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to get uid", err.Error())
+		resp.Diagnostics.AddError("Failed to apply the account policy", err.Error())
+		return
+	}
+
+	newModel.UID = user.uid(ctx, newModel.Name, &resp.Diagnostics)
+
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	newModel.UID = types.Int64Value(uid)
 	diags = resp.State.Set(ctx, newModel)
 	resp.Diagnostics.Append(diags...)
 
@@ -236,6 +335,8 @@ func (user *userResource) Update(ctx context.Context, req resource.UpdateRequest
 }
 
 func (user *userResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = user.provider.mutating(ctx, user, "delete", req.State)
+
 	var model userResourceModel
 
 	diags := req.State.Get(ctx, &model)
@@ -245,7 +346,16 @@ func (user *userResource) Delete(ctx context.Context, req resource.DeleteRequest
 		return
 	}
 
-	_, err := user.provider.machineAccessClient.RunCommand(ctx, "sudo userdel "+model.Name.String())
+	if !user.provider.allowDestructiveOperation("delete the user "+model.Name.ValueString(), &resp.Diagnostics) {
+		return
+	}
+
+	command := clients.ShellCommand("userdel", model.Name.ValueString())
+	if !user.provider.capabilities(ctx).ShadowUtils {
+		command = clients.ShellCommand("deluser", model.Name.ValueString())
+	}
+
+	_, err := user.provider.machineAccessClient.Run(ctx, user.provider.sudo(command))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to delete user", err.Error())
 		return
@@ -253,11 +363,71 @@ func (user *userResource) Delete(ctx context.Context, req resource.DeleteRequest
 }
 
 func (user *userResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+
+	groups, err := user.getSupplementaryGroups(ctx, req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to get the groups of the user", err.Error())
+		return
+	}
+
+	// The groups are left unmanaged when the user only has its primary group
+	if len(groups) > 0 {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("groups"), groups)...)
+	}
+}
+
+// getSupplementaryGroups returns the gids of the groups the user belongs to, without its primary group.
+func (user *userResource) getSupplementaryGroups(ctx context.Context, name string) ([]int64, error) {
+	out, err := user.provider.machineAccessClient.RunCommand(ctx, "id -g "+clients.ShellQuote(name)+" && id -G "+clients.ShellQuote(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the groups of %s: %w.\n out= %s", name, err, out)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("unexpected id output: %s", out)
+	}
+
+	primary := strings.TrimSpace(lines[0])
+
+	groups := []int64{}
+
+	for _, field := range strings.Fields(lines[1]) {
+		if field == primary {
+			continue
+		}
+
+		gid, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse gid ('%s'): %w", field, err)
+		}
+
+		groups = append(groups, gid)
+	}
+
+	return groups, nil
+}
+
+// uid returns the uid of the user after a change. In a dry run the user may not have been created, its uid is then
+// null.
+func (user *userResource) uid(ctx context.Context, name types.String, diags *diag.Diagnostics) types.Int64 {
+	uid, err := user.getUID(ctx, name)
+	if err != nil && user.provider.dryRun {
+		tflog.Info(ctx, "Dry run, the uid of the user is not known: "+err.Error())
+		return types.Int64Null()
+	}
+
+	if err != nil {
+		diags.AddError("Failed to get uid", err.Error())
+		return types.Int64Null()
+	}
+
+	return types.Int64Value(uid)
 }
 
 func (user *userResource) getUID(ctx context.Context, inputName types.String) (int64, error) {
-	out, err := user.provider.machineAccessClient.RunCommand(ctx, "cat /etc/passwd")
+	out, err := user.provider.machineAccessClient.RunCommand(clients.WithoutMutation(ctx), "cat /etc/passwd")
 	if err != nil {
 		return 0, fmt.Errorf("failed to get passwd file: %w.\n out= %s", err, out)
 	}
@@ -291,7 +461,12 @@ func (user *userResource) getUID(ctx context.Context, inputName types.String) (i
 }
 
 func (user *userResource) addUserToGroup(ctx context.Context, name string, group string) error {
-	_, err := user.provider.machineAccessClient.RunCommand(ctx, "sudo usermod -aG "+group+" "+name)
+	command := clients.ShellCommand("usermod", "-aG", group, name)
+	if !user.provider.capabilities(ctx).ShadowUtils {
+		command = clients.ShellCommand("addgroup", name, group)
+	}
+
+	_, err := user.provider.machineAccessClient.Run(ctx, user.provider.sudo(command))
 	if err != nil {
 		return fmt.Errorf("failed to add user to group: %w", err)
 	}
@@ -323,3 +498,261 @@ func (user *userResource) getGroupNameFromGid(ctx context.Context, gid int64) (s
 
 	return "", fmt.Errorf("group with gid %d not found", gid)
 }
+
+// passwdEntry is the entry of a user in /etc/passwd.
+type passwdEntry struct {
+	uid  string
+	gid  string
+	home string
+}
+
+func (user *userResource) getPasswdEntry(ctx context.Context, name string) (passwdEntry, error) {
+	out, err := user.provider.machineAccessClient.RunCommand(clients.WithoutMutation(ctx), "cat /etc/passwd")
+	if err != nil {
+		return passwdEntry{}, fmt.Errorf("failed to get passwd file: %w.\n out= %s", err, out)
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		lineParts := strings.Split(line, ":")
+		if len(lineParts) >= 6 && lineParts[0] == name {
+			return passwdEntry{uid: lineParts[2], gid: lineParts[3], home: lineParts[5]}, nil
+		}
+	}
+
+	return passwdEntry{}, fmt.Errorf("user %s not found", name)
+}
+
+// authorizedKeysFile returns the authorized_keys resource model of the ~/.ssh/authorized_keys file of the user.
+func (user *userResource) authorizedKeysFile(ctx context.Context, entry passwdEntry, keys types.List) (authorizedKeysResourceModel, diag.Diagnostics) {
+	var lines []string
+
+	if !keys.IsNull() {
+		diags := keys.ElementsAs(ctx, &lines, false)
+		if diags.HasError() {
+			return authorizedKeysResourceModel{}, diags
+		}
+	}
+
+	set, diags := types.SetValueFrom(ctx, types.StringType, lines)
+	if diags.HasError() {
+		return authorizedKeysResourceModel{}, diags
+	}
+
+	return authorizedKeysResourceModel{
+		Path:      types.StringValue(strings.TrimSuffix(entry.home, "/") + "/.ssh/authorized_keys"),
+		Keys:      set,
+		Exclusive: types.BoolValue(false),
+		Mode:      types.StringValue("600"),
+		Owner:     types.StringValue(entry.uid),
+		Group:     types.StringValue(entry.gid),
+	}, diags
+}
+
+// applyAuthorizedKeys writes the keys to the ~/.ssh/authorized_keys file of the user, and removes the previous keys
+// that are not listed anymore. ~/.ssh is created, or fixed, with the mode and ownership that sshd requires.
+func (user *userResource) applyAuthorizedKeys(ctx context.Context, name string, keys types.List, previous types.List) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if keys.IsNull() && previous.IsNull() {
+		return diags
+	}
+
+	entry, err := user.getPasswdEntry(ctx, name)
+	if err != nil {
+		diags.AddError("Failed to get the home directory of the user", err.Error())
+		return diags
+	}
+
+	file, d := user.authorizedKeysFile(ctx, entry, keys)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return diags
+	}
+
+	authorizedKeys := &authorizedKeysResource{provider: user.provider}
+
+	previousFile, d := user.authorizedKeysFile(ctx, entry, previous)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return diags
+	}
+
+	previousKeys, d := authorizedKeys.keys(ctx, previousFile.Keys)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return diags
+	}
+
+	sshDir := strings.TrimSuffix(entry.home, "/") + "/.ssh"
+
+	out, err := user.provider.machineAccessClient.RunCommand(ctx, user.provider.sudo(clients.ShellCommand("install", "-d", "-m", "700", "-o", entry.uid, "-g", entry.gid, sshDir)))
+	if err != nil {
+		diags.AddError("Failed to create "+sshDir, "Err="+err.Error()+"\nout = "+out)
+		return diags
+	}
+
+	authorizedKeys.apply(ctx, &file, previousKeys, &diags)
+
+	return diags
+}
+
+// readAuthorizedKeys returns the listed keys that are still in the ~/.ssh/authorized_keys file of the user.
+func (user *userResource) readAuthorizedKeys(ctx context.Context, name string, keys types.List) (types.List, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	entry, err := user.getPasswdEntry(ctx, name)
+	if err != nil {
+		diags.AddError("Failed to get the home directory of the user", err.Error())
+		return keys, diags
+	}
+
+	file, d := user.authorizedKeysFile(ctx, entry, keys)
+	diags.Append(d...)
+
+	if diags.HasError() {
+		return keys, diags
+	}
+
+	lines, err := readLines(ctx, user.provider.machineAccessClient, file.Path.ValueString())
+	if err != nil {
+		diags.AddError("Failed to read authorized_keys file", err.Error())
+		return keys, diags
+	}
+
+	var listed []string
+
+	diags.Append(keys.ElementsAs(ctx, &listed, false)...)
+
+	if diags.HasError() {
+		return keys, diags
+	}
+
+	present := []string{}
+
+	for _, key := range listed {
+		entry, ok := parseAuthorizedKey(key)
+
+		for _, line := range lines {
+			existing, found := parseAuthorizedKey(line)
+			if ok && found && existing.sameKey(entry) {
+				present = append(present, key)
+				break
+			}
+		}
+	}
+
+	list, d := types.ListValueFrom(ctx, types.StringType, present)
+	diags.Append(d...)
+
+	return list, diags
+}
+
+// applyAccountPolicy sets the expiration date, the lock and the password maximum age of the user that are configured
+// and differ from the previous model, which is nil on creation.
+func (user *userResource) applyAccountPolicy(ctx context.Context, plan userResourceModel, previous *userResourceModel) error {
+	name := plan.Name.ValueString()
+	args := []string{}
+
+	if !plan.ExpireDate.IsNull() && (previous == nil || !plan.ExpireDate.Equal(previous.ExpireDate)) {
+		args = append(args, "-e", plan.ExpireDate.ValueString())
+	}
+
+	if !plan.Locked.IsNull() && (previous == nil || !plan.Locked.Equal(previous.Locked)) {
+		if plan.Locked.ValueBool() {
+			args = append(args, "-L")
+		} else {
+			args = append(args, "-U")
+		}
+	}
+
+	setPasswordMaxAge := !plan.PasswordMaxAge.IsNull() && (previous == nil || !plan.PasswordMaxAge.Equal(previous.PasswordMaxAge))
+
+	if (len(args) > 0 || setPasswordMaxAge) && !user.provider.capabilities(ctx).ShadowUtils {
+		return shadowUtilsRequired("usermod")
+	}
+
+	if len(args) > 0 {
+		out, err := user.provider.machineAccessClient.RunCommand(ctx, user.provider.sudo(clients.ShellCommand("usermod", append(args, name)...)))
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w.\n out= %s", name, err, out)
+		}
+	}
+
+	if setPasswordMaxAge {
+		out, err := user.provider.machineAccessClient.RunCommand(ctx, user.provider.sudo(clients.ShellCommand("chage", "-M", plan.PasswordMaxAge.String(), name)))
+		if err != nil {
+			return fmt.Errorf("failed to set the password maximum age of %s: %w.\n out= %s", name, err, out)
+		}
+	}
+
+	return nil
+}
+
+// shadowEntry is the entry of a user in /etc/shadow.
+type shadowEntry struct {
+	locked bool
+	// maxAge is -1 when the password never has to be changed
+	maxAge int64
+	// expireDate is empty when the account never expires
+	expireDate string
+}
+
+// update sets the attributes of the model that are managed, i.e. not null, from the entry.
+func (entry shadowEntry) update(model *userResourceModel) {
+	if !model.ExpireDate.IsNull() {
+		model.ExpireDate = types.StringValue(entry.expireDate)
+	}
+
+	if !model.Locked.IsNull() {
+		model.Locked = types.BoolValue(entry.locked)
+	}
+
+	if !model.PasswordMaxAge.IsNull() {
+		model.PasswordMaxAge = types.Int64Value(entry.maxAge)
+	}
+}
+
+func (user *userResource) getShadowEntry(ctx context.Context, name string) (shadowEntry, error) {
+	out, err := user.provider.machineAccessClient.RunCommand(ctx, user.provider.sudo(clients.ShellCommand("getent", "shadow", name)))
+	if err != nil {
+		return shadowEntry{}, fmt.Errorf("failed to get the shadow entry of %s: %w.\n out= %s", name, err, out)
+	}
+
+	return parseShadowEntry(strings.TrimSpace(out))
+}
+
+// parseShadowEntry parses a line of /etc/shadow, see shadow(5). The dates are given in days since 1970-01-01.
+func parseShadowEntry(line string) (shadowEntry, error) {
+	fields := strings.Split(line, ":")
+	if len(fields) < 8 {
+		return shadowEntry{}, fmt.Errorf("unexpected shadow entry: %s", line)
+	}
+
+	entry := shadowEntry{
+		locked: strings.HasPrefix(fields[1], "!"),
+		maxAge: -1,
+	}
+
+	if fields[4] != "" {
+		maxAge, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return shadowEntry{}, fmt.Errorf("failed to parse the password maximum age ('%s'): %w", fields[4], err)
+		}
+
+		entry.maxAge = maxAge
+	}
+
+	if fields[7] != "" {
+		days, err := strconv.ParseInt(fields[7], 10, 64)
+		if err != nil {
+			return shadowEntry{}, fmt.Errorf("failed to parse the expiration date ('%s'): %w", fields[7], err)
+		}
+
+		entry.expireDate = time.Unix(days*24*60*60, 0).UTC().Format(time.DateOnly)
+	}
+
+	return entry, nil
+}
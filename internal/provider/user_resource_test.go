@@ -3,10 +3,12 @@ package provider
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"terraform-provider-setup/internal/provider/clients"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
@@ -188,6 +190,345 @@ func TestUserResource(t *testing.T) {
 	})
 }
 
+func TestUserResourceWithMock(t *testing.T) {
+	t.Run("import reads the uid and the supplementary groups", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "root:x:0:0::/root:/bin/bash\nalice:x:1001:1001::/home/alice:/bin/bash\n"}).
+			On("id -g 'alice' && id -G 'alice'", clients.MockResponse{Stdout: "1001\n1001 27 999\n"})
+
+		// Act
+		state, diags := testResourceImportState[userResourceModel](t, newUserResource(newTestProvider(mock)), "alice")
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.Name.ValueString() != "alice" || state.UID.ValueInt64() != 1001 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+
+		if state.Groups.String() != "[27,999]" {
+			t.Fatalf("unexpected groups: %s", state.Groups)
+		}
+	})
+
+	t.Run("import leaves the groups unmanaged when the user only has its primary group", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"}).
+			On("id -g 'alice' && id -G 'alice'", clients.MockResponse{Stdout: "1001\n1001\n"})
+
+		// Act
+		state, diags := testResourceImportState[userResourceModel](t, newUserResource(newTestProvider(mock)), "alice")
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !state.Groups.IsNull() {
+			t.Fatalf("unexpected groups: %s", state.Groups)
+		}
+	})
+
+	t.Run("import fails for an unknown user", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("id -g 'bob' && id -G 'bob'", clients.MockResponse{Stderr: "id: 'bob': no such user", ExitCode: 1})
+
+		// Act
+		_, diags := testResourceImportState[userResourceModel](t, newUserResource(newTestProvider(mock)), "bob")
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("delete is refused when destructive operations are disabled", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		provider := newTestProvider(mock)
+		provider.denyDestructiveOperations = true
+
+		// Act
+		diags := testResourceDelete(t, newUserResource(provider), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Value(1001),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: types.ListNull(types.StringType),
+			ExpireDate:     types.StringNull(),
+			Locked:         types.BoolNull(),
+			PasswordMaxAge: types.Int64Null(),
+		})
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if len(mock.Commands) != 0 {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+	t.Run("create writes the authorized keys", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"})
+
+		keys, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"ssh-ed25519 AAAAC3Nza alice@laptop"})
+
+		// Act
+		state, diags := testResourceCreate(t, newUserResource(newTestProvider(mock)), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Unknown(),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: keys,
+			ExpireDate:     types.StringNull(),
+			Locked:         types.BoolNull(),
+			PasswordMaxAge: types.Int64Null(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "install -d -m 700 -o 1001 -g 1001 /home/alice/.ssh") {
+			t.Fatalf("expected ~/.ssh to be created, got: %v", mock.Commands)
+		}
+
+		if mock.Files["/home/alice/.ssh/authorized_keys"] != "ssh-ed25519 AAAAC3Nza alice@laptop\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/alice/.ssh/authorized_keys"])
+		}
+
+		if mock.FileInfos["/home/alice/.ssh/authorized_keys"].Mode != "600" {
+			t.Fatalf("unexpected file info: %+v", mock.FileInfos["/home/alice/.ssh/authorized_keys"])
+		}
+
+		if !state.AuthorizedKeys.Equal(keys) {
+			t.Fatalf("unexpected keys: %s", state.AuthorizedKeys)
+		}
+	})
+
+	t.Run("read drops the keys removed from the file", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"}).
+			WithFile("/home/alice/.ssh/authorized_keys", "ssh-rsa AAAAB3Nza other\nssh-ed25519 AAAAC3Nza alice@laptop\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		keys, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"ssh-ed25519 AAAAC3Nza alice@laptop", "ssh-ed25519 AAAAC3Nzb alice@desktop"})
+
+		// Act
+		state, _, diags := testResourceRead(t, newUserResource(newTestProvider(mock)), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Value(1001),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: keys,
+			ExpireDate:     types.StringNull(),
+			Locked:         types.BoolNull(),
+			PasswordMaxAge: types.Int64Null(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.AuthorizedKeys.String() != `["ssh-ed25519 AAAAC3Nza alice@laptop"]` {
+			t.Fatalf("unexpected keys: %s", state.AuthorizedKeys)
+		}
+	})
+
+	t.Run("update removes the keys that are not listed anymore and leaves the others", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"}).
+			WithFile("/home/alice/.ssh/authorized_keys", "ssh-rsa AAAAB3Nza other\nssh-ed25519 AAAAC3Nza alice@laptop\n", clients.FileInfo{Mode: "600", Owner: 1001, Group: 1001})
+
+		oldKeys, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"ssh-ed25519 AAAAC3Nza alice@laptop"})
+		newKeys, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"ssh-ed25519 AAAAC3Nzb alice@desktop"})
+
+		state := userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Value(1001),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: oldKeys,
+			ExpireDate:     types.StringNull(),
+			Locked:         types.BoolNull(),
+			PasswordMaxAge: types.Int64Null(),
+		}
+		plan := state
+		plan.AuthorizedKeys = newKeys
+
+		// Act
+		_, diags := testResourceUpdate(t, newUserResource(newTestProvider(mock)), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/home/alice/.ssh/authorized_keys"] != "ssh-rsa AAAAB3Nza other\nssh-ed25519 AAAAC3Nzb alice@desktop\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/home/alice/.ssh/authorized_keys"])
+		}
+	})
+	t.Run("create uses adduser of BusyBox without the shadow utilities", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/sh\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newUserResource(withTestCapabilities(newTestProvider(mock), clients.Capabilities{BusyBox: true})), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Unknown(),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: types.ListNull(types.StringType),
+			ExpireDate:     types.StringNull(),
+			Locked:         types.BoolNull(),
+			PasswordMaxAge: types.Int64Null(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Commands[0] != "adduser -D -s /bin/sh alice" {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+
+	t.Run("create applies the account policy", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"})
+
+		// Act
+		_, diags := testResourceCreate(t, newUserResource(newTestProvider(mock)), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Unknown(),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: types.ListNull(types.StringType),
+			ExpireDate:     types.StringValue("2030-01-31"),
+			Locked:         types.BoolValue(true),
+			PasswordMaxAge: types.Int64Value(90),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		for _, command := range []string{"usermod -e 2030-01-31 -L alice", "chage -M 90 alice"} {
+			if !slices.Contains(mock.Commands, command) {
+				t.Fatalf("expected %s, got: %v", command, mock.Commands)
+			}
+		}
+	})
+
+	t.Run("update only applies the changed policy", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"})
+
+		state := userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Value(1001),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: types.ListNull(types.StringType),
+			ExpireDate:     types.StringValue("2030-01-31"),
+			Locked:         types.BoolValue(true),
+			PasswordMaxAge: types.Int64Value(90),
+		}
+		plan := state
+		plan.Locked = types.BoolValue(false)
+
+		// Act
+		_, diags := testResourceUpdate(t, newUserResource(newTestProvider(mock)), state, plan)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Contains(mock.Commands, "usermod -U alice") {
+			t.Fatalf("expected the user to be unlocked, got: %v", mock.Commands)
+		}
+
+		for _, command := range mock.Commands {
+			if strings.HasPrefix(command, "chage") {
+				t.Fatalf("unexpected command: %s", command)
+			}
+		}
+	})
+
+	t.Run("read reads the managed account policy", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("cat /etc/passwd", clients.MockResponse{Stdout: "alice:x:1001:1001::/home/alice:/bin/bash\n"}).
+			On("getent shadow alice", clients.MockResponse{Stdout: "alice:$6$salt$hash:19000:0:365:7::21945:\n"})
+
+		// Act
+		state, _, diags := testResourceRead(t, newUserResource(newTestProvider(mock)), userResourceModel{
+			Name:           types.StringValue("alice"),
+			UID:            types.Int64Value(1001),
+			Groups:         types.ListNull(types.Int64Type),
+			AuthorizedKeys: types.ListNull(types.StringType),
+			ExpireDate:     types.StringValue("2030-01-31"),
+			Locked:         types.BoolValue(true),
+			PasswordMaxAge: types.Int64Null(),
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if state.ExpireDate.ValueString() != "2030-01-31" || state.Locked.ValueBool() || !state.PasswordMaxAge.IsNull() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+}
+
+func TestParseShadowEntry(t *testing.T) {
+	tests := map[string]shadowEntry{
+		"alice:!$6$salt$hash:19000:0:90:7:::": {locked: true, maxAge: 90},
+		"bob:$6$salt$hash:19000:0::7::0:":     {maxAge: -1, expireDate: "1970-01-01"},
+		"carol:*:19000:0:99999:7::21945:":     {maxAge: 99999, expireDate: "2030-01-31"},
+		"dave:!:19000::::::":                  {locked: true, maxAge: -1},
+	}
+
+	for line, expected := range tests {
+		// Act
+		entry, err := parseShadowEntry(line)
+
+		// Assert
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entry != expected {
+			t.Fatalf("unexpected entry for %s: %+v", line, entry)
+		}
+	}
+
+	t.Run("invalid entries are rejected", func(t *testing.T) {
+		for _, line := range []string{"", "alice:x:19000", "alice:x:19000:0:often:7:::"} {
+			// Act
+			_, err := parseShadowEntry(line)
+
+			// Assert
+			if err == nil {
+				t.Fatalf("expected an error for %q", line)
+			}
+		}
+	})
+}
+
 func testUserResourceConfig(name string, groupName string) string {
 	return fmt.Sprintf(`
 resource "setup_group" "group" {
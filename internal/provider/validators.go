@@ -0,0 +1,424 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	octalModePattern   = regexp.MustCompile(`^[0-7]{3,4}$`)
+	accountNamePattern = regexp.MustCompile(`^([0-9]+|[A-Za-z_][A-Za-z0-9_.-]*\$?)$`)
+	windowsPathPattern = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+	aptOptionPattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9:_.-]*=`)
+	machineIDPattern   = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	sha256Pattern      = regexp.MustCompile(`^[0-9A-Fa-f]{64}$`)
+)
+
+// stringValidator is a validator of string attributes checking their values with check, which returns the reason why
+// the value is invalid, or an empty string when it is valid. Null and unknown values are not checked.
+type stringValidator struct {
+	description string
+	check       func(value string) string
+}
+
+var _ validator.String = stringValidator{}
+
+func (v stringValidator) Description(_ context.Context) string {
+	return v.description
+}
+
+func (v stringValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v stringValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if reason := v.check(req.ConfigValue.ValueString()); reason != "" {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid attribute value", fmt.Sprintf("%s: %s", req.Path, reason))
+	}
+}
+
+// octalMode validates file modes given as 3 or 4 octal digits, e.g. '644' or '0755'.
+func octalMode() validator.String {
+	return stringValidator{
+		description: "value must be a file mode of 3 or 4 octal digits, e.g. '644' or '0755'",
+		check: func(value string) string {
+			if !octalModePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a file mode of 3 or 4 octal digits, e.g. '644' or '0755'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// absolutePath validates absolute paths, either POSIX paths or Windows paths with a drive letter, as the remote
+// commands are not run from a known directory.
+func absolutePath() validator.String {
+	return stringValidator{
+		description: "value must be an absolute path",
+		check: func(value string) string {
+			if !strings.HasPrefix(value, "/") && !windowsPathPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not an absolute path", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// oneOf validates that the value is one of the given values.
+func oneOf(values ...string) validator.String {
+	return stringValidator{
+		description: "value must be one of: '" + strings.Join(values, "', '") + "'",
+		check: func(value string) string {
+			if !slices.Contains(values, value) {
+				return fmt.Sprintf("'%s' is not one of '%s'", value, strings.Join(values, "', '"))
+			}
+
+			return ""
+		},
+	}
+}
+
+// notBlank validates that the value has other characters than whitespaces.
+func notBlank() validator.String {
+	return stringValidator{
+		description: "value must not be empty",
+		check: func(value string) string {
+			if strings.TrimSpace(value) == "" {
+				return "the value must not be empty"
+			}
+
+			return ""
+		},
+	}
+}
+
+// singleLine validates that the value has no line break, e.g. for the values written on a line of a configuration file.
+func singleLine() validator.String {
+	return stringValidator{
+		description: "value must be on a single line",
+		check: func(value string) string {
+			if strings.ContainsAny(value, "\r\n") {
+				return "the value must not contain line breaks"
+			}
+
+			return ""
+		},
+	}
+}
+
+// portString validates TCP ports given as strings.
+func portString() validator.String {
+	return stringValidator{
+		description: "value must be a port between 1 and 65535",
+		check: func(value string) string {
+			port, err := strconv.Atoi(value)
+			if err != nil || !validPort(int64(port)) {
+				return fmt.Sprintf("'%s' is not a port between 1 and 65535", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// isoDate validates dates given as YYYY-MM-DD.
+func isoDate() validator.String {
+	return stringValidator{
+		description: "value must be a date formatted as YYYY-MM-DD, e.g. '2030-01-31'",
+		check: func(value string) string {
+			if _, err := time.Parse(time.DateOnly, value); err != nil {
+				return fmt.Sprintf("'%s' is not a date formatted as YYYY-MM-DD, e.g. '2030-01-31'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// cidrAddress validates IP addresses with a prefix length, e.g. '192.168.1.10/24' or 'fd00::1/64'.
+func cidrAddress() validator.String {
+	return stringValidator{
+		description: "value must be an IP address with a prefix length, e.g. '192.168.1.10/24'",
+		check: func(value string) string {
+			if _, err := netip.ParsePrefix(value); err != nil {
+				return fmt.Sprintf("'%s' is not an IP address with a prefix length, e.g. '192.168.1.10/24'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// ipAddress validates IPv4 and IPv6 addresses.
+func ipAddress() validator.String {
+	return stringValidator{
+		description: "value must be an IP address",
+		check: func(value string) string {
+			if _, err := netip.ParseAddr(value); err != nil {
+				return fmt.Sprintf("'%s' is not an IP address", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// proxyURL validates the URLs of HTTP proxies, e.g. 'http://proxy.example.com:3128'.
+func proxyURL() validator.String {
+	return stringValidator{
+		description: "value must be the URL of an HTTP proxy, e.g. 'http://proxy.example.com:3128'",
+		check: func(value string) string {
+			parsed, err := url.Parse(value)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Sprintf("'%s' is not the URL of an HTTP proxy, e.g. 'http://proxy.example.com:3128'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// accountName validates the owners and groups of files, given either as a name or as a numeric id.
+func accountName() validator.String {
+	return stringValidator{
+		description: "value must be a user or group name, or a numeric id",
+		check: func(value string) string {
+			if !accountNamePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is neither a user or group name nor a numeric id", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// aptOption validates the configuration options of apt-get, e.g. 'Dpkg::Options::=--force-confold'.
+func aptOption() validator.String {
+	return stringValidator{
+		description: "value must be an apt configuration option, e.g. 'Dpkg::Options::=--force-confold'",
+		check: func(value string) string {
+			if !aptOptionPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not an apt configuration option, e.g. 'Dpkg::Options::=--force-confold'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// fileName validates the names of the files of a directory, which have no slash.
+func fileName() validator.String {
+	return stringValidator{
+		description: "value must be a file name, without '/'",
+		check: func(value string) string {
+			if value == "" || value == "." || value == ".." || strings.Contains(value, "/") {
+				return fmt.Sprintf("'%s' is not a file name", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// machineID validates the ids of /etc/machine-id, 32 lowercase hexadecimal characters. The id made of zeros is refused
+// by systemd.
+func machineID() validator.String {
+	return stringValidator{
+		description: "value must be 32 lowercase hexadecimal characters",
+		check: func(value string) string {
+			if !machineIDPattern.MatchString(value) || strings.Trim(value, "0") == "" {
+				return fmt.Sprintf("'%s' is not a machine id of 32 lowercase hexadecimal characters", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// sha256Checksum validates the sha256 checksums, 64 hexadecimal characters of any case.
+func sha256Checksum() validator.String {
+	return stringValidator{
+		description: "value must be a sha256 checksum of 64 hexadecimal characters",
+		check: func(value string) string {
+			if !sha256Pattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a sha256 checksum of 64 hexadecimal characters", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// duration validates the durations of Go, e.g. '30s' or '24h'.
+func duration() validator.String {
+	return stringValidator{
+		description: "value must be a duration, e.g. '30s' or '24h'",
+		check: func(value string) string {
+			if parsed, err := time.ParseDuration(value); err != nil || parsed < 0 {
+				return fmt.Sprintf("'%s' is not a duration, e.g. '30s' or '24h'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// regularExpression validates the regular expressions of Go, e.g. 'Temporary failure|Could not resolve'.
+func regularExpression() validator.String {
+	return stringValidator{
+		description: "value must be a regular expression",
+		check: func(value string) string {
+			if _, err := regexp.Compile(value); err != nil {
+				return fmt.Sprintf("'%s' is not a regular expression: %s", value, err)
+			}
+
+			return ""
+		},
+	}
+}
+
+// listValidator validates each string element of list attributes with a string validator.
+type listValidator struct {
+	element validator.String
+}
+
+var _ validator.List = listValidator{}
+
+func (v listValidator) Description(ctx context.Context) string {
+	return "each " + v.element.Description(ctx)
+}
+
+func (v listValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v listValidator) ValidateList(ctx context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	for i, element := range req.ConfigValue.Elements() {
+		value, ok := element.(types.String)
+		if !ok {
+			continue
+		}
+
+		elementResp := validator.StringResponse{}
+		v.element.ValidateString(ctx, validator.StringRequest{Path: req.Path.AtListIndex(i), PathExpression: req.PathExpression.AtListIndex(i), ConfigValue: value}, &elementResp)
+		resp.Diagnostics.Append(elementResp.Diagnostics...)
+	}
+}
+
+// listOf validates each element of a list of strings with the validator.
+func listOf(element validator.String) validator.List {
+	return listValidator{element: element}
+}
+
+// portValidator validates TCP ports.
+type portValidator struct{}
+
+var _ validator.Int64 = portValidator{}
+
+func (v portValidator) Description(_ context.Context) string {
+	return "value must be a port between 1 and 65535"
+}
+
+func (v portValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v portValidator) ValidateInt64(_ context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if !validPort(req.ConfigValue.ValueInt64()) {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid attribute value", fmt.Sprintf("%s: %d is not a port between 1 and 65535", req.Path, req.ConfigValue.ValueInt64()))
+	}
+}
+
+func port() validator.Int64 {
+	return portValidator{}
+}
+
+func validPort(port int64) bool {
+	return port >= 1 && port <= 65535
+}
+
+// int64RangeValidator validates integers between lower and upper, both included.
+type int64RangeValidator struct {
+	lower int64
+	upper int64
+}
+
+var _ validator.Int64 = int64RangeValidator{}
+
+func (v int64RangeValidator) Description(_ context.Context) string {
+	return fmt.Sprintf("value must be between %d and %d", v.lower, v.upper)
+}
+
+func (v int64RangeValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v int64RangeValidator) ValidateInt64(_ context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if value := req.ConfigValue.ValueInt64(); value < v.lower || value > v.upper {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid attribute value", fmt.Sprintf("%s: %d is not between %d and %d", req.Path, value, v.lower, v.upper))
+	}
+}
+
+func int64Between(lower int64, upper int64) validator.Int64 {
+	return int64RangeValidator{lower: lower, upper: upper}
+}
+
+// int64MinimumValidator validates integers greater than or equal to minimum.
+type int64MinimumValidator struct {
+	minimum int64
+}
+
+var _ validator.Int64 = int64MinimumValidator{}
+
+func (v int64MinimumValidator) Description(_ context.Context) string {
+	return fmt.Sprintf("value must be at least %d", v.minimum)
+}
+
+func (v int64MinimumValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v int64MinimumValidator) ValidateInt64(_ context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if value := req.ConfigValue.ValueInt64(); value < v.minimum {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid attribute value", fmt.Sprintf("%s: %d is less than %d", req.Path, value, v.minimum))
+	}
+}
+
+func int64AtLeast(minimum int64) validator.Int64 {
+	return int64MinimumValidator{minimum: minimum}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"terraform-provider-setup/internal/provider/clients"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &waitForResource{}
+
+// waitForPollInterval is the delay between two attempts to reach the machine.
+const waitForPollInterval = 5 * time.Second
+
+func newWaitForResource(p *internalProvider) resource.Resource {
+	return &waitForResource{
+		provider: p,
+	}
+}
+
+// waitForResource defines the resource implementation.
+type waitForResource struct {
+	provider *internalProvider
+}
+
+type waitForResourceModel struct {
+	Timeout   types.String `tfsdk:"timeout"`
+	CloudInit types.Bool   `tfsdk:"cloud_init"`
+	Triggers  types.Map    `tfsdk:"triggers"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *waitForResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_wait_for"
+}
+
+func (r *waitForResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Waits for the machine to be reachable over SSH and, if it uses cloud-init, for cloud-init to finish. Make the other resources depend on it when the machine was just booted",
+
+		Attributes: map[string]schema.Attribute{
+			"timeout": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("10m"),
+				Description: "The maximum time to wait, as a duration (e.g. '10m'). Defaults to '10m'",
+			},
+			"cloud_init": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+				Description: "Whether to wait for cloud-init to finish (`cloud-init status --wait`). Ignored on machines without cloud-init. Defaults to true",
+			},
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Arbitrary values that cause the wait to be repeated when changed, e.g. the id of the virtual machine",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *waitForResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *waitForResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan waitForResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	timeout, err := time.ParseDuration(plan.Timeout.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to parse timeout", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err = r.waitForConnection(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Machine did not become reachable", err.Error())
+		return
+	}
+
+	if plan.CloudInit.ValueBool() {
+		err = r.waitForCloudInit(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to wait for cloud-init", err.Error())
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *waitForResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
+	// the wait only happens on creation, there is nothing to read back
+}
+
+func (r *waitForResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan waitForResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *waitForResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+	// nothing to do, the machine is left as is
+}
+
+// waitForConnection runs a no-op command until it succeeds or the context expires.
+func (r *waitForResource) waitForConnection(ctx context.Context) error {
+	for {
+		_, err := r.provider.machineAccessClient.Run(ctx, "true")
+		if err == nil {
+			return nil
+		}
+
+		tflog.Info(ctx, "Machine is not reachable yet: "+err.Error())
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out, last error: %w", err)
+		case <-time.After(waitForPollInterval):
+		}
+	}
+}
+
+// waitForCloudInit blocks until cloud-init is done. An exit code of 2 means that cloud-init finished with recoverable
+// errors, which is not treated as a failure.
+func (r *waitForResource) waitForCloudInit(ctx context.Context) error {
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "if command -v cloud-init >/dev/null 2>&1; then cloud-init status --wait; fi")
+
+	if clients.IsExitCode(err, 2) {
+		tflog.Warn(ctx, "cloud-init finished with recoverable errors: "+out)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("cloud-init failed. Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
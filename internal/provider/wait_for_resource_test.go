@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestWaitForResource(t *testing.T) {
+	// Arrange - set up a single Docker container for all test cases
+	setup := setupTestEnvironment(t)
+
+	sshClient, err := clients.CreateSSHMachineAccessClientBuilder("test", "localhost", setup.Port).WithPrivateKeyPath(setup.KeyPath).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Test wait without cloud-init", func(t *testing.T) {
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config: testProviderConfig(setup, "test", "localhost") + testWaitForResourceConfig("1m"),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("setup_wait_for.test", "timeout", "1m"),
+						resource.TestCheckResourceAttr("setup_wait_for.test", "cloud_init", "true"),
+					),
+				},
+			},
+		})
+	})
+
+	t.Run("Test wait with failing cloud-init", func(t *testing.T) {
+		// Arrange - fake a cloud-init that finished with a non recoverable error
+		_, err := sshClient.RunCommand(context.Background(), "printf '#!/bin/sh\\necho status: error\\nexit 1\\n' | sudo tee /usr/local/bin/cloud-init && sudo chmod +x /usr/local/bin/cloud-init")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer func() {
+			_, _ = sshClient.RunCommand(context.Background(), "sudo rm -f /usr/local/bin/cloud-init")
+		}()
+
+		// Act & assert
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: getTestProviderFactories(),
+			Steps: []resource.TestStep{
+				{
+					Config:      testProviderConfig(setup, "test", "localhost") + testWaitForResourceConfig("1m"),
+					ExpectError: regexp.MustCompile("Failed to wait for cloud-init"),
+				},
+			},
+		})
+	})
+}
+
+func testWaitForResourceConfig(timeout string) string {
+	return fmt.Sprintf(`
+resource "setup_wait_for" "test" {
+	timeout = %q
+}
+`, timeout)
+}
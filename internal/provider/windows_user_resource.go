@@ -0,0 +1,366 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &windowsUserResource{}
+var _ resource.ResourceWithImportState = &windowsUserResource{}
+
+func newWindowsUserResource(p *internalProvider) resource.Resource {
+	return &windowsUserResource{
+		provider: p,
+	}
+}
+
+// windowsUserResource defines the resource implementation.
+type windowsUserResource struct {
+	provider *internalProvider
+}
+
+type windowsUserResourceModel struct {
+	Name        types.String `tfsdk:"name"`
+	Password    types.String `tfsdk:"password"`
+	FullName    types.String `tfsdk:"full_name"`
+	Description types.String `tfsdk:"description"`
+	Groups      types.Set    `tfsdk:"groups"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *windowsUserResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_windows_user"
+}
+
+func (r *windowsUserResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Local user of a Windows host. Requires the provider to be configured with os_family = \"windows\"",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the user",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"password": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "The password of the user. The user has no password when not set. Changes made outside of terraform are not detected",
+			},
+			"full_name": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(""),
+				Description: "The full name of the user",
+			},
+			"description": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(""),
+				Description: "The description of the user",
+			},
+			"groups": schema.SetAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Local groups the user is a member of, e.g. 'Administrators'. Membership of other groups is left untouched",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *windowsUserResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *windowsUserResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan windowsUserResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyWindows, &resp.Diagnostics) {
+		return
+	}
+
+	// The password is passed in the script, which is logged
+	ctx = clients.WithSensitiveValues(ctx, plan.Password.ValueString())
+
+	groups, diags := r.groups(ctx, plan.Groups)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	script := fmt.Sprintf("$params = @{ Name = %s; FullName = %s; Description = %s }\n",
+		clients.PowershellQuote(plan.Name.ValueString()),
+		clients.PowershellQuote(plan.FullName.ValueString()),
+		clients.PowershellQuote(plan.Description.ValueString()),
+	)
+
+	if plan.Password.ValueString() != "" {
+		script += "New-LocalUser @params -PasswordNeverExpires -Password " + securePassword(plan.Password.ValueString()) + " | Out-Null\n"
+	} else {
+		script += "New-LocalUser @params -NoPassword | Out-Null\n"
+	}
+
+	script += addGroupMembersScript(plan.Name.ValueString(), groups)
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, script)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create user", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+type windowsUser struct {
+	FullName    string   `json:"full_name"`
+	Description string   `json:"description"`
+	Groups      []string `json:"groups"`
+}
+
+func (r *windowsUserResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var model windowsUserResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	groups, diags := r.groups(ctx, model.Groups)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	quotedGroups := make([]string, 0, len(groups))
+	for _, group := range groups {
+		quotedGroups = append(quotedGroups, clients.PowershellQuote(group))
+	}
+
+	// Only the groups in the state are checked, as membership of other groups is not managed
+	script := fmt.Sprintf(`$user = Get-LocalUser -Name %[1]s -ErrorAction SilentlyContinue
+if (-not $user) { return }
+$groups = @(foreach ($group in @(%[2]s)) { if (Get-LocalGroupMember -Group $group -Member %[1]s -ErrorAction SilentlyContinue) { $group } })
+ConvertTo-Json -Compress @{ full_name = [string]$user.FullName; description = [string]$user.Description; groups = $groups }`,
+		clients.PowershellQuote(model.Name.ValueString()), strings.Join(quotedGroups, ", "))
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, script)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read user", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+
+	if strings.TrimSpace(out) == "" {
+		// The user was removed outside of terraform
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	var user windowsUser
+	if err := json.Unmarshal([]byte(out), &user); err != nil {
+		resp.Diagnostics.AddError("Failed to parse user", err.Error()+"\nout = "+out)
+		return
+	}
+
+	model.FullName = types.StringValue(user.FullName)
+	model.Description = types.StringValue(user.Description)
+
+	if !model.Groups.IsNull() {
+		model.Groups, diags = types.SetValueFrom(ctx, types.StringType, user.Groups)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *windowsUserResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan windowsUserResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	var state windowsUserResourceModel
+
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	// The password is passed in the script, which is logged
+	ctx = clients.WithSensitiveValues(ctx, plan.Password.ValueString())
+
+	newGroups, diags := r.groups(ctx, plan.Groups)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	oldGroups, diags := r.groups(ctx, state.Groups)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	name := clients.PowershellQuote(plan.Name.ValueString())
+
+	script := fmt.Sprintf("Set-LocalUser -Name %s -FullName %s -Description %s\n",
+		name,
+		clients.PowershellQuote(plan.FullName.ValueString()),
+		clients.PowershellQuote(plan.Description.ValueString()),
+	)
+
+	if !plan.Password.Equal(state.Password) && plan.Password.ValueString() != "" {
+		script += fmt.Sprintf("Set-LocalUser -Name %s -Password %s\n", name, securePassword(plan.Password.ValueString()))
+	}
+
+	script += addGroupMembersScript(plan.Name.ValueString(), difference(newGroups, oldGroups))
+
+	for _, group := range difference(oldGroups, newGroups) {
+		script += fmt.Sprintf("Remove-LocalGroupMember -Group %s -Member %s -ErrorAction SilentlyContinue\n", clients.PowershellQuote(group), name)
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, script)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update user", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *windowsUserResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var model windowsUserResourceModel
+
+	diags := req.State.Get(ctx, &model)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.allowDestructiveOperation("delete the user "+model.Name.ValueString(), &resp.Diagnostics) {
+		return
+	}
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "Remove-LocalUser -Name "+clients.PowershellQuote(model.Name.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to delete user", "Err="+err.Error()+"\nout = "+out)
+		return
+	}
+}
+
+func (r *windowsUserResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+}
+
+func (r *windowsUserResource) groups(ctx context.Context, set types.Set) ([]string, diag.Diagnostics) {
+	groups := []string{}
+
+	if set.IsNull() || set.IsUnknown() {
+		return groups, nil
+	}
+
+	diags := set.ElementsAs(ctx, &groups, false)
+
+	return groups, diags
+}
+
+func securePassword(password string) string {
+	return "(ConvertTo-SecureString " + clients.PowershellQuote(password) + " -AsPlainText -Force)"
+}
+
+func addGroupMembersScript(name string, groups []string) string {
+	script := ""
+	for _, group := range groups {
+		script += fmt.Sprintf("Add-LocalGroupMember -Group %s -Member %s\n", clients.PowershellQuote(group), clients.PowershellQuote(name))
+	}
+
+	return script
+}
+
+// difference returns the values of a that are not in b.
+func difference(a []string, b []string) []string {
+	result := []string{}
+
+	for _, value := range a {
+		found := false
+
+		for _, other := range b {
+			if value == other {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
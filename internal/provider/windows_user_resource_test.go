@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestWindowsUserResourceWithMock(t *testing.T) {
+	groups := types.SetValueMust(types.StringType, []attr.Value{types.StringValue("Administrators")})
+
+	t.Run("create adds the user to its groups", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		p := newTestProvider(mock)
+		p.osFamily = clients.OSFamilyWindows
+
+		// Act
+		_, diags := testResourceCreate(t, newWindowsUserResource(p), windowsUserResourceModel{
+			Name:        types.StringValue("deploy"),
+			Password:    types.StringValue("it's secret"),
+			FullName:    types.StringValue("Deploy user"),
+			Description: types.StringValue(""),
+			Groups:      groups,
+		})
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		script := mock.Commands[0]
+		if !strings.Contains(script, "ConvertTo-SecureString 'it''s secret'") || !strings.Contains(script, "Add-LocalGroupMember -Group 'Administrators' -Member 'deploy'") {
+			t.Fatalf("unexpected script: %s", script)
+		}
+	})
+
+	t.Run("read detects a removed group membership", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("Get-LocalUser", clients.MockResponse{Stdout: `{"full_name":"Deploy user","description":"","groups":[]}`})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newWindowsUserResource(newTestProvider(mock)), windowsUserResourceModel{
+			Name:        types.StringValue("deploy"),
+			Password:    types.StringNull(),
+			FullName:    types.StringValue("Deploy user"),
+			Description: types.StringValue(""),
+			Groups:      groups,
+		})
+
+		// Assert
+		if diags.HasError() || removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+
+		if len(state.Groups.Elements()) != 0 {
+			t.Fatalf("unexpected groups: %v", state.Groups)
+		}
+	})
+
+	t.Run("read removes a deleted user from the state", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newWindowsUserResource(newTestProvider(mock)), windowsUserResourceModel{
+			Name:        types.StringValue("deploy"),
+			Password:    types.StringNull(),
+			FullName:    types.StringValue(""),
+			Description: types.StringValue(""),
+			Groups:      types.SetNull(types.StringType),
+		})
+
+		// Assert
+		if diags.HasError() || !removed {
+			t.Fatalf("unexpected result: removed=%v, diags=%v", removed, diags)
+		}
+	})
+}
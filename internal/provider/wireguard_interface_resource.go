@@ -0,0 +1,402 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &wireguardInterfaceResource{}
+
+const (
+	wireguardDirectory      = "/etc/wireguard"
+	wireguardInterfaceBegin = "# BEGIN TERRAFORM INTERFACE"
+	wireguardInterfaceEnd   = "# END TERRAFORM INTERFACE"
+)
+
+var (
+	// wireguardNamePattern matches the names wg-quick accepts for interfaces.
+	wireguardNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_=+.-]{1,15}$`)
+	// wireguardKeyPattern matches base64 encoded curve25519 keys.
+	wireguardKeyPattern = regexp.MustCompile(`^[A-Za-z0-9+/]{42}[AEIMQUYcgkosw048]=$`)
+)
+
+func newWireguardInterfaceResource(p *internalProvider) resource.Resource {
+	return &wireguardInterfaceResource{
+		provider: p,
+	}
+}
+
+// wireguardInterfaceResource defines the resource implementation.
+type wireguardInterfaceResource struct {
+	provider *internalProvider
+}
+
+type wireguardInterfaceResourceModel struct {
+	Name       types.String `tfsdk:"name"`
+	Addresses  types.List   `tfsdk:"addresses"`
+	ListenPort types.Int64  `tfsdk:"listen_port"`
+	PublicKey  types.String `tfsdk:"public_key"`
+	Path       types.String `tfsdk:"path"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *wireguardInterfaceResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_wireguard_interface"
+}
+
+func (r *wireguardInterfaceResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "WireGuard interface resource that writes the [Interface] section of " + wireguardDirectory +
+			"/<name>.conf and enables the wg-quick@<name> service. The private key is generated on the machine, " +
+			"stored next to the configuration with mode 600, and never leaves it; only the public key is exposed. " +
+			"The peers are managed with setup_wireguard_peer. wireguard-tools must be installed, e.g. with " +
+			"setup_apt_packages",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the interface, e.g. 'wg0'",
+				Validators:  []validator.String{wireguardName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"addresses": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The addresses of the interface in CIDR notation, e.g. ['10.8.0.1/24']",
+				Validators:  []validator.List{listOf(cidrAddress())},
+			},
+			"listen_port": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The UDP port the interface listens on. When not set, a random port is used, which is fine for interfaces only connecting to peers with an endpoint",
+				Validators:  []validator.Int64{port()},
+			},
+			"public_key": schema.StringAttribute{
+				Computed:    true,
+				Description: "The public key of the interface, to configure on its peers",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The path of the wg-quick configuration of the interface",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *wireguardInterfaceResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *wireguardInterfaceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan wireguardInterfaceResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	name := plan.Name.ValueString()
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, "command -v wg-quick")
+	if err != nil {
+		resp.Diagnostics.AddError("wireguard-tools is not installed", fmt.Sprintf("wg-quick was not found, install the wireguard-tools package first. Err=%s\nout = %s", err, out))
+		return
+	}
+
+	// The key is only generated once, so that recreating the configuration keeps the identity of the interface
+	keyPath := wireguardKeyPath(name)
+	generate := "umask 077 && mkdir -p " + clients.ShellQuote(wireguardDirectory) + " && { [ -s " + clients.ShellQuote(keyPath) + " ] || wg genkey > " + clients.ShellQuote(keyPath) + "; }"
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", generate)))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to generate the private key", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	publicKey, err := r.publicKey(ctx, name)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to derive the public key", err.Error())
+		return
+	}
+
+	if !r.write(ctx, plan, &resp.Diagnostics) {
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl enable --now "+clients.ShellQuote(wireguardService(name))))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to start the interface", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	plan.PublicKey = types.StringValue(publicKey)
+	plan.Path = types.StringValue(wireguardConfigPath(name))
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *wireguardInterfaceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state wireguardInterfaceResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, wireguardConfigPath(state.Name.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the configuration", err.Error())
+		return
+	}
+
+	settings, found := wireguardBlockSettings(lines, wireguardInterfaceBegin, wireguardInterfaceEnd)
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	addresses, diags := types.ListValueFrom(ctx, types.StringType, splitWireguardList(settings["Address"]))
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	state.Addresses = addresses
+	state.ListenPort = types.Int64Null()
+
+	if listenPort, ok := settings["ListenPort"]; ok {
+		value, err := strconv.ParseInt(listenPort, 10, 64)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to parse the listen port", err.Error())
+			return
+		}
+
+		state.ListenPort = types.Int64Value(value)
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *wireguardInterfaceResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan wireguardInterfaceResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.write(ctx, plan, &resp.Diagnostics) {
+		return
+	}
+
+	// wg-quick only applies addresses and the listen port when bringing the interface up
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl restart "+clients.ShellQuote(wireguardService(plan.Name.ValueString()))))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to restart the interface", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *wireguardInterfaceResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state wireguardInterfaceResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	name := state.Name.ValueString()
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo("systemctl disable --now "+clients.ShellQuote(wireguardService(name))))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to stop the interface", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+
+	out, err = r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("rm", "-f", wireguardConfigPath(name), wireguardKeyPath(name))))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the configuration", fmt.Sprintf("Err=%s\nout = %s", err, out))
+		return
+	}
+}
+
+// write writes the [Interface] section of the configuration, keeping the peers.
+func (r *wireguardInterfaceResource) write(ctx context.Context, plan wireguardInterfaceResourceModel, diags *diag.Diagnostics) bool {
+	name := plan.Name.ValueString()
+
+	addresses := stringList(ctx, plan.Addresses, diags)
+	if diags.HasError() {
+		return false
+	}
+
+	// The private key is read by wg-quick from the key file, so that it is never part of the configuration written
+	block := []string{
+		wireguardInterfaceBegin,
+		"[Interface]",
+		"Address = " + strings.Join(addresses, ", "),
+	}
+
+	if !plan.ListenPort.IsNull() {
+		block = append(block, "ListenPort = "+strconv.FormatInt(plan.ListenPort.ValueInt64(), 10))
+	}
+
+	block = append(block, "PostUp = wg set %i private-key "+wireguardKeyPath(name), wireguardInterfaceEnd)
+
+	err := editWireguardConfig(ctx, r.provider, name, func(lines []string) ([]string, bool) {
+		return setMarkedBlock(lines, block, true)
+	})
+	if err != nil {
+		diags.AddError("Failed to write the configuration", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// publicKey returns the public key matching the private key of the interface.
+func (r *wireguardInterfaceResource) publicKey(ctx context.Context, name string) (string, error) {
+	command := "wg pubkey < " + clients.ShellQuote(wireguardKeyPath(name))
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", command)))
+	if err != nil {
+		return "", fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// editWireguardConfig edits the wg-quick configuration of the interface, which is only readable by root as wg-quick
+// expects.
+func editWireguardConfig(ctx context.Context, p *internalProvider, name string, edit func(lines []string) ([]string, bool)) error {
+	return p.editSystemFile(ctx, wireguardConfigPath(name), "600", edit)
+}
+
+// wireguardBlockSettings returns the `Key = Value` settings of the marked block, and whether the block exists.
+func wireguardBlockSettings(lines []string, begin string, end string) (map[string]string, bool) {
+	start, stop, found := findMarkedBlock(lines, begin, end)
+	if !found {
+		return nil, false
+	}
+
+	settings := map[string]string{}
+
+	for _, line := range lines[start+1 : stop] {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return settings, true
+}
+
+// splitWireguardList splits the comma separated values of a setting.
+func splitWireguardList(value string) []string {
+	values := []string{}
+
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
+
+func wireguardConfigPath(name string) string {
+	return path.Join(wireguardDirectory, name+".conf")
+}
+
+func wireguardKeyPath(name string) string {
+	return path.Join(wireguardDirectory, name+".key")
+}
+
+func wireguardService(name string) string {
+	return "wg-quick@" + name
+}
+
+// wireguardName validates the names of WireGuard interfaces.
+func wireguardName() validator.String {
+	return stringValidator{
+		description: "value must be an interface name of at most 15 letters, digits and '_=+.-'",
+		check: func(value string) string {
+			if !wireguardNamePattern.MatchString(value) {
+				return fmt.Sprintf("'%s' must be an interface name of at most 15 letters, digits and '_=+.-'", value)
+			}
+
+			return ""
+		},
+	}
+}
+
+// wireguardKey validates WireGuard public keys.
+func wireguardKey() validator.String {
+	return stringValidator{
+		description: "value must be a base64 encoded WireGuard key",
+		check: func(value string) string {
+			if !wireguardKeyPattern.MatchString(value) {
+				return fmt.Sprintf("'%s' is not a base64 encoded WireGuard key", value)
+			}
+
+			return ""
+		},
+	}
+}
@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	testWireguardPublicKey = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	testWireguardPeerKey   = "HIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw="
+)
+
+func TestWireguardInterfaceResourceWithMock(t *testing.T) {
+	t.Run("create generates the key, writes the interface and starts the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			OnMatch("^sh -c 'wg pubkey", clients.MockResponse{Stdout: testWireguardPublicKey + "\n"})
+
+		// Act
+		state, diags := testResourceCreate(t, newWireguardInterfaceResource(newTestProvider(mock)), testWireguardInterfaceModel(51820))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/wireguard/wg0.conf"] != "# BEGIN TERRAFORM INTERFACE\n[Interface]\nAddress = 10.8.0.1/24, fd08::1/64\nListenPort = 51820\nPostUp = wg set %i private-key /etc/wireguard/wg0.key\n# END TERRAFORM INTERFACE\n" {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/wireguard/wg0.conf"])
+		}
+
+		if mock.FileInfos["/etc/wireguard/wg0.conf"].Mode != "600" {
+			t.Fatalf("unexpected mode: %s", mock.FileInfos["/etc/wireguard/wg0.conf"].Mode)
+		}
+
+		if !slices.Contains(mock.Commands, "systemctl enable --now 'wg-quick@wg0'") {
+			t.Fatalf("expected the service to be enabled, got: %v", mock.Commands)
+		}
+
+		if state.PublicKey.ValueString() != testWireguardPublicKey {
+			t.Fatalf("unexpected public key: %s", state.PublicKey)
+		}
+	})
+
+	t.Run("create fails when wireguard-tools is missing", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			On("command -v wg-quick", clients.MockResponse{ExitCode: 1})
+
+		// Act
+		_, diags := testResourceCreate(t, newWireguardInterfaceResource(newTestProvider(mock)), testWireguardInterfaceModel(51820))
+
+		// Assert
+		if !diags.HasError() {
+			t.Fatal("expected an error")
+		}
+
+		if _, ok := mock.Files["/etc/wireguard/wg0.conf"]; ok {
+			t.Fatal("expected no configuration to be written")
+		}
+	})
+
+	t.Run("update keeps the peers and restarts the service", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/wireguard/wg0.conf", testWireguardConfig, clients.FileInfo{Mode: "600"})
+
+		// Act
+		_, diags := testResourceUpdate(t, newWireguardInterfaceResource(newTestProvider(mock)), testWireguardInterfaceState(51820), testWireguardInterfaceState(0))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := "# BEGIN TERRAFORM INTERFACE\n[Interface]\nAddress = 10.8.0.1/24, fd08::1/64\nPostUp = wg set %i private-key /etc/wireguard/wg0.key\n# END TERRAFORM INTERFACE\n" +
+			"# BEGIN TERRAFORM PEER " + testWireguardPeerKey + "\n[Peer]\nPublicKey = " + testWireguardPeerKey + "\nAllowedIPs = 10.8.0.2/32\n# END TERRAFORM PEER " + testWireguardPeerKey + "\n"
+		if mock.Files["/etc/wireguard/wg0.conf"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/wireguard/wg0.conf"])
+		}
+
+		if !slices.Contains(mock.Commands, "systemctl restart 'wg-quick@wg0'") {
+			t.Fatalf("expected the service to be restarted, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("read detects drift", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/wireguard/wg0.conf", testWireguardConfig, clients.FileInfo{Mode: "600"})
+
+		// Act
+		state, removed, diags := testResourceRead(t, newWireguardInterfaceResource(newTestProvider(mock)), testWireguardInterfaceState(0))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if removed {
+			t.Fatal("expected the resource to be kept")
+		}
+
+		if state.ListenPort.ValueInt64() != 51820 {
+			t.Fatalf("unexpected listen port: %s", state.ListenPort)
+		}
+	})
+
+	t.Run("read removes the resource when the configuration is gone", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		_, removed, diags := testResourceRead(t, newWireguardInterfaceResource(newTestProvider(mock)), testWireguardInterfaceState(51820))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !removed {
+			t.Fatal("expected the resource to be removed")
+		}
+	})
+
+	t.Run("delete stops the service and removes the configuration and the key", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient()
+
+		// Act
+		diags := testResourceDelete(t, newWireguardInterfaceResource(newTestProvider(mock)), testWireguardInterfaceState(51820))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !slices.Equal(mock.Commands, []string{"systemctl disable --now 'wg-quick@wg0'", "rm -f /etc/wireguard/wg0.conf /etc/wireguard/wg0.key"}) {
+			t.Fatalf("unexpected commands: %v", mock.Commands)
+		}
+	})
+}
+
+const testWireguardConfig = "# BEGIN TERRAFORM INTERFACE\n[Interface]\nAddress = 10.8.0.1/24, fd08::1/64\nListenPort = 51820\nPostUp = wg set %i private-key /etc/wireguard/wg0.key\n# END TERRAFORM INTERFACE\n" +
+	"# BEGIN TERRAFORM PEER " + testWireguardPeerKey + "\n[Peer]\nPublicKey = " + testWireguardPeerKey + "\nAllowedIPs = 10.8.0.2/32\n# END TERRAFORM PEER " + testWireguardPeerKey + "\n"
+
+func testWireguardInterfaceModel(listenPort int64) wireguardInterfaceResourceModel {
+	addresses, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"10.8.0.1/24", "fd08::1/64"})
+
+	model := wireguardInterfaceResourceModel{
+		Name:       types.StringValue("wg0"),
+		Addresses:  addresses,
+		ListenPort: types.Int64Null(),
+		PublicKey:  types.StringUnknown(),
+		Path:       types.StringUnknown(),
+	}
+
+	if listenPort != 0 {
+		model.ListenPort = types.Int64Value(listenPort)
+	}
+
+	return model
+}
+
+func testWireguardInterfaceState(listenPort int64) wireguardInterfaceResourceModel {
+	model := testWireguardInterfaceModel(listenPort)
+	model.PublicKey = types.StringValue(testWireguardPublicKey)
+	model.Path = types.StringValue("/etc/wireguard/wg0.conf")
+
+	return model
+}
@@ -0,0 +1,309 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &wireguardPeerResource{}
+
+func newWireguardPeerResource(p *internalProvider) resource.Resource {
+	return &wireguardPeerResource{
+		provider: p,
+	}
+}
+
+// wireguardPeerResource defines the resource implementation.
+type wireguardPeerResource struct {
+	provider *internalProvider
+}
+
+type wireguardPeerResourceModel struct {
+	Interface           types.String `tfsdk:"interface"`
+	PublicKey           types.String `tfsdk:"public_key"`
+	AllowedIPs          types.List   `tfsdk:"allowed_ips"`
+	Endpoint            types.String `tfsdk:"endpoint"`
+	PersistentKeepalive types.Int64  `tfsdk:"persistent_keepalive"`
+
+	TargetHost types.String `tfsdk:"target_host"`
+}
+
+func (r *wireguardPeerResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_wireguard_peer"
+}
+
+func (r *wireguardPeerResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "WireGuard peer resource that adds a [Peer] section to the wg-quick configuration of an " +
+			"interface managed by setup_wireguard_interface, and reloads the running interface so that the peer is " +
+			"applied without disrupting the other peers",
+
+		Attributes: map[string]schema.Attribute{
+			"interface": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the interface, e.g. setup_wireguard_interface.wg0.name",
+				Validators:  []validator.String{wireguardName()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"public_key": schema.StringAttribute{
+				Required:    true,
+				Description: "The public key of the peer, e.g. the public_key of its setup_wireguard_interface",
+				Validators:  []validator.String{wireguardKey()},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"allowed_ips": schema.ListAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "The addresses in CIDR notation the peer may send from, and traffic to which is routed to the peer, e.g. ['10.8.0.2/32']",
+				Validators:  []validator.List{listOf(cidrAddress())},
+			},
+			"endpoint": schema.StringAttribute{
+				Optional:    true,
+				Description: "The address of the peer as 'host:port', e.g. 'vpn.example.com:51820'. When not set, the peer has to connect first",
+				Validators:  []validator.String{wireguardEndpoint()},
+			},
+			"persistent_keepalive": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The interval in seconds between keepalive packets, to keep the connection open through NAT, e.g. 25. Disabled when not set",
+			},
+			"target_host": targetHostAttribute(),
+		},
+	}
+}
+
+func (r *wireguardPeerResource) Configure(_ context.Context, _ resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+
+}
+
+func (r *wireguardPeerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = r.provider.mutating(ctx, r, "create", req.Plan)
+
+	var plan wireguardPeerResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.provider.requireOSFamily(clients.OSFamilyLinux, &resp.Diagnostics) {
+		return
+	}
+
+	if !r.write(ctx, plan, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+}
+
+func (r *wireguardPeerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = r.provider.onHost(ctx, req.State)
+
+	var state wireguardPeerResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	lines, err := readLines(ctx, r.provider.machineAccessClient, wireguardConfigPath(state.Interface.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read the configuration", err.Error())
+		return
+	}
+
+	begin, end := wireguardPeerMarkers(state.PublicKey.ValueString())
+
+	settings, found := wireguardBlockSettings(lines, begin, end)
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	allowedIPs, diags := types.ListValueFrom(ctx, types.StringType, splitWireguardList(settings["AllowedIPs"]))
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	state.AllowedIPs = allowedIPs
+	state.Endpoint = types.StringNull()
+	state.PersistentKeepalive = types.Int64Null()
+
+	if endpoint, ok := settings["Endpoint"]; ok {
+		state.Endpoint = types.StringValue(endpoint)
+	}
+
+	if keepalive, ok := settings["PersistentKeepalive"]; ok {
+		value, err := strconv.ParseInt(keepalive, 10, 64)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to parse the persistent keepalive", err.Error())
+			return
+		}
+
+		state.PersistentKeepalive = types.Int64Value(value)
+	}
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *wireguardPeerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = r.provider.mutating(ctx, r, "update", req.Plan)
+
+	var plan wireguardPeerResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	if !r.write(ctx, plan, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r *wireguardPeerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = r.provider.mutating(ctx, r, "delete", req.State)
+
+	var state wireguardPeerResourceModel
+
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		return
+	}
+
+	name := state.Interface.ValueString()
+	begin, end := wireguardPeerMarkers(state.PublicKey.ValueString())
+
+	err := editWireguardConfig(ctx, r.provider, name, func(lines []string) ([]string, bool) {
+		return removeMarkedBlock(lines, begin, end)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to remove the peer", err.Error())
+		return
+	}
+
+	if err := r.reload(ctx, name); err != nil {
+		resp.Diagnostics.AddError("Failed to reload the interface", err.Error())
+		return
+	}
+}
+
+// write writes the [Peer] section of the configuration and reloads the interface.
+func (r *wireguardPeerResource) write(ctx context.Context, plan wireguardPeerResourceModel, diags *diag.Diagnostics) bool {
+	name := plan.Interface.ValueString()
+
+	allowedIPs := stringList(ctx, plan.AllowedIPs, diags)
+	if diags.HasError() {
+		return false
+	}
+
+	begin, end := wireguardPeerMarkers(plan.PublicKey.ValueString())
+	block := []string{
+		begin,
+		"[Peer]",
+		"PublicKey = " + plan.PublicKey.ValueString(),
+		"AllowedIPs = " + strings.Join(allowedIPs, ", "),
+	}
+
+	if !plan.Endpoint.IsNull() {
+		block = append(block, "Endpoint = "+plan.Endpoint.ValueString())
+	}
+
+	if !plan.PersistentKeepalive.IsNull() {
+		block = append(block, "PersistentKeepalive = "+strconv.FormatInt(plan.PersistentKeepalive.ValueInt64(), 10))
+	}
+
+	block = append(block, end)
+
+	err := editWireguardConfig(ctx, r.provider, name, func(lines []string) ([]string, bool) {
+		return setMarkedBlock(lines, block, false)
+	})
+	if err != nil {
+		diags.AddError("Failed to write the peer", err.Error())
+		return false
+	}
+
+	if err := r.reload(ctx, name); err != nil {
+		diags.AddError("Failed to reload the interface", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// reload applies the peers of the configuration to the interface when it is running, wg-quick@ reloads with
+// `wg syncconf` which keeps the sessions of the unchanged peers.
+func (r *wireguardPeerResource) reload(ctx context.Context, name string) error {
+	service := clients.ShellQuote(wireguardService(name))
+	command := "if systemctl is-active --quiet " + service + "; then systemctl reload " + service + "; fi"
+
+	out, err := r.provider.machineAccessClient.RunCommand(ctx, r.provider.sudo(clients.ShellCommand("sh", "-c", command)))
+	if err != nil {
+		return fmt.Errorf("Err=%w\nout = %s", err, out)
+	}
+
+	return nil
+}
+
+// wireguardPeerMarkers returns the lines delimiting the section of the peer in the configuration.
+func wireguardPeerMarkers(publicKey string) (string, string) {
+	return "# BEGIN TERRAFORM PEER " + publicKey, "# END TERRAFORM PEER " + publicKey
+}
+
+// wireguardEndpoint validates the endpoints of peers.
+func wireguardEndpoint() validator.String {
+	return stringValidator{
+		description: "value must be 'host:port'",
+		check: func(value string) string {
+			index := strings.LastIndex(value, ":")
+			if index <= 0 || strings.ContainsAny(value, " \t\n") {
+				return fmt.Sprintf("'%s' must be 'host:port'", value)
+			}
+
+			port, err := strconv.ParseInt(value[index+1:], 10, 64)
+			if err != nil || !validPort(port) {
+				return fmt.Sprintf("'%s' must end with a valid port", value)
+			}
+
+			return ""
+		},
+	}
+}
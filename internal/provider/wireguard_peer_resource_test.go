@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"terraform-provider-setup/internal/provider/clients"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const testWireguardInterfaceOnly = "# BEGIN TERRAFORM INTERFACE\n[Interface]\nAddress = 10.8.0.1/24\nPostUp = wg set %i private-key /etc/wireguard/wg0.key\n# END TERRAFORM INTERFACE\n"
+
+func TestWireguardPeerResourceWithMock(t *testing.T) {
+	t.Run("create appends the peer and reloads the interface", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/wireguard/wg0.conf", testWireguardInterfaceOnly, clients.FileInfo{Mode: "600"})
+
+		model := testWireguardPeerModel([]string{"10.8.0.2/32", "192.168.1.0/24"})
+		model.Endpoint = types.StringValue("vpn.example.com:51820")
+		model.PersistentKeepalive = types.Int64Value(25)
+
+		// Act
+		_, diags := testResourceCreate(t, newWireguardPeerResource(newTestProvider(mock)), model)
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		expected := testWireguardInterfaceOnly + "# BEGIN TERRAFORM PEER " + testWireguardPeerKey + "\n[Peer]\nPublicKey = " + testWireguardPeerKey +
+			"\nAllowedIPs = 10.8.0.2/32, 192.168.1.0/24\nEndpoint = vpn.example.com:51820\nPersistentKeepalive = 25\n# END TERRAFORM PEER " + testWireguardPeerKey + "\n"
+		if mock.Files["/etc/wireguard/wg0.conf"] != expected {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/wireguard/wg0.conf"])
+		}
+
+		if !slices.ContainsFunc(mock.Commands, func(command string) bool { return strings.Contains(command, "systemctl reload") }) {
+			t.Fatalf("expected the interface to be reloaded, got: %v", mock.Commands)
+		}
+	})
+
+	t.Run("update replaces the peer in place", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/wireguard/wg0.conf", testWireguardConfig, clients.FileInfo{Mode: "600"})
+
+		// Act
+		_, diags := testResourceUpdate(t, newWireguardPeerResource(newTestProvider(mock)), testWireguardPeerModel([]string{"10.8.0.2/32"}), testWireguardPeerModel([]string{"10.8.0.3/32"}))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if mock.Files["/etc/wireguard/wg0.conf"] != strings.Replace(testWireguardConfig, "10.8.0.2/32", "10.8.0.3/32", 1) {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/wireguard/wg0.conf"])
+		}
+	})
+
+	t.Run("read detects drift and removed peers", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/wireguard/wg0.conf", testWireguardConfig, clients.FileInfo{Mode: "600"})
+
+		model := testWireguardPeerModel([]string{"10.8.0.9/32"})
+		model.Endpoint = types.StringValue("vpn.example.com:51820")
+
+		other := testWireguardPeerModel([]string{"10.8.0.2/32"})
+		other.PublicKey = types.StringValue(testWireguardPublicKey)
+
+		// Act
+		state, removed, diags := testResourceRead(t, newWireguardPeerResource(newTestProvider(mock)), model)
+		_, otherRemoved, otherDiags := testResourceRead(t, newWireguardPeerResource(newTestProvider(mock)), other)
+
+		// Assert
+		if diags.HasError() || otherDiags.HasError() {
+			t.Fatal(diags, otherDiags)
+		}
+
+		if removed || state.AllowedIPs.String() != `["10.8.0.2/32"]` || !state.Endpoint.IsNull() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+
+		if !otherRemoved {
+			t.Fatal("expected the missing peer to be removed")
+		}
+	})
+
+	t.Run("delete removes the peer only", func(t *testing.T) {
+		// Arrange
+		mock := clients.NewMockMachineAccessClient().
+			WithFile("/etc/wireguard/wg0.conf", testWireguardConfig, clients.FileInfo{Mode: "600"})
+
+		// Act
+		diags := testResourceDelete(t, newWireguardPeerResource(newTestProvider(mock)), testWireguardPeerModel([]string{"10.8.0.2/32"}))
+
+		// Assert
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		if !strings.HasSuffix(mock.Files["/etc/wireguard/wg0.conf"], "# END TERRAFORM INTERFACE\n") || strings.Contains(mock.Files["/etc/wireguard/wg0.conf"], "[Peer]") {
+			t.Fatalf("unexpected content: %q", mock.Files["/etc/wireguard/wg0.conf"])
+		}
+	})
+}
+
+func testWireguardPeerModel(allowedIPs []string) wireguardPeerResourceModel {
+	value, _ := types.ListValueFrom(context.Background(), types.StringType, allowedIPs)
+
+	return wireguardPeerResourceModel{
+		Interface:           types.StringValue("wg0"),
+		PublicKey:           types.StringValue(testWireguardPeerKey),
+		AllowedIPs:          value,
+		Endpoint:            types.StringNull(),
+		PersistentKeepalive: types.Int64Null(),
+	}
+}